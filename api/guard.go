@@ -21,8 +21,11 @@ type KeyGenerator interface {
 }
 
 type Key struct {
-	ID         string
-	UserID     string
+	ID     string
+	UserID string
+	// TenantID is the ID of the tenant the key was issued for. It is empty
+	// for the default tenant.
+	TenantID   string
 	APIKey     string
 	CreateDate time.Time
 	UpdateDate time.Time
@@ -71,28 +74,37 @@ func NewGuard(db KeyStore, opts ...Option) (*Guard, error) {
 }
 
 func (s *Guard) APIKeyValid(key string) (string, error) {
+	k, err := s.APIKey(key)
+	return k.UserID, err
+}
+
+// APIKey validates key and returns its details. The returned Key is never nil
+// and has at least the UserID set if key was well formed, even when an error
+// is returned. The master key yields a Key with UserID "master" and no ID.
+func (s *Guard) APIKey(key string) (*Key, error) {
 	if key != "" && key == s.masterKey {
-		return "master", nil
+		return &Key{UserID: "master"}, nil
 	}
 	pair := strings.SplitN(key, ".", 2)
 	if len(pair) < 2 || pair[0] == "" || pair[1] == "" {
-		return "", errors.NewUnauthorizedf("invalid API key %+v", pair)
+		return &Key{}, errors.NewUnauthorizedf("invalid API key %+v", pair)
 	}
 	userID := pair[0]
 	keyStr := pair[1]
 	dbKeys, err := s.db.APIKeysByUserID(userID, 0, 10)
 	if err != nil {
 		if s.db.IsNotFoundError(err) {
-			return userID, errors.NewForbiddenf(invalidAPIKeyErrorf, keyStr, userID)
+			return &Key{UserID: userID}, errors.NewForbiddenf(invalidAPIKeyErrorf, keyStr, userID)
 		}
-		return userID, errors.Newf("get API Key: %v", err)
+		return &Key{UserID: userID}, errors.Newf("get API Key: %v", err)
 	}
 	for _, dbKey := range dbKeys {
 		if dbKey.APIKey == keyStr {
-			return userID, nil
+			dbKey.UserID = userID
+			return &dbKey, nil
 		}
 	}
-	return userID, errors.NewForbiddenf(invalidAPIKeyErrorf, keyStr, userID)
+	return &Key{UserID: userID}, errors.NewForbiddenf(invalidAPIKeyErrorf, keyStr, userID)
 }
 
 func (s *Guard) NewAPIKey(userID string) (*Key, error) {
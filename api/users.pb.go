@@ -16,6 +16,15 @@ It has these top-level messages:
 	Device
 	User
 	GetDetailsReq
+	APIKey
+	Session
+	IssuedToken
+	History
+	UserExport
+	ExportUserReq
+	LinkedIdentity
+	TOTPStatus
+	LoginFailure
 */
 package api
 
@@ -464,6 +473,534 @@ func (m *GetDetailsReq) GetUserID() string {
 	return ""
 }
 
+type APIKey struct {
+	ID          string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	UserID      string `protobuf:"bytes,2,opt,name=userID" json:"userID,omitempty"`
+	Created     string `protobuf:"bytes,3,opt,name=created" json:"created,omitempty"`
+	LastUpdated string `protobuf:"bytes,4,opt,name=lastUpdated" json:"lastUpdated,omitempty"`
+}
+
+func (m *APIKey) Reset()                    { *m = APIKey{} }
+func (m *APIKey) String() string            { return proto.CompactTextString(m) }
+func (*APIKey) ProtoMessage()               {}
+func (*APIKey) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *APIKey) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *APIKey) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *APIKey) GetCreated() string {
+	if m != nil {
+		return m.Created
+	}
+	return ""
+}
+
+func (m *APIKey) GetLastUpdated() string {
+	if m != nil {
+		return m.LastUpdated
+	}
+	return ""
+}
+
+type Session struct {
+	ID        string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	UserID    string `protobuf:"bytes,2,opt,name=userID" json:"userID,omitempty"`
+	DeviceID  string `protobuf:"bytes,3,opt,name=deviceID" json:"deviceID,omitempty"`
+	IPAddress string `protobuf:"bytes,4,opt,name=IPAddress" json:"IPAddress,omitempty"`
+	UserAgent string `protobuf:"bytes,5,opt,name=userAgent" json:"userAgent,omitempty"`
+	Revoked   bool   `protobuf:"varint,6,opt,name=revoked" json:"revoked,omitempty"`
+	Created   string `protobuf:"bytes,7,opt,name=created" json:"created,omitempty"`
+	LastSeen  string `protobuf:"bytes,8,opt,name=lastSeen" json:"lastSeen,omitempty"`
+}
+
+func (m *Session) Reset()                    { *m = Session{} }
+func (m *Session) String() string            { return proto.CompactTextString(m) }
+func (*Session) ProtoMessage()               {}
+func (*Session) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *Session) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *Session) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *Session) GetDeviceID() string {
+	if m != nil {
+		return m.DeviceID
+	}
+	return ""
+}
+
+func (m *Session) GetIPAddress() string {
+	if m != nil {
+		return m.IPAddress
+	}
+	return ""
+}
+
+func (m *Session) GetUserAgent() string {
+	if m != nil {
+		return m.UserAgent
+	}
+	return ""
+}
+
+func (m *Session) GetRevoked() bool {
+	if m != nil {
+		return m.Revoked
+	}
+	return false
+}
+
+func (m *Session) GetCreated() string {
+	if m != nil {
+		return m.Created
+	}
+	return ""
+}
+
+func (m *Session) GetLastSeen() string {
+	if m != nil {
+		return m.LastSeen
+	}
+	return ""
+}
+
+type IssuedToken struct {
+	ID       string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	Address  string `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	APIKeyID string `protobuf:"bytes,3,opt,name=APIKeyID" json:"APIKeyID,omitempty"`
+	FamilyID string `protobuf:"bytes,4,opt,name=familyID" json:"familyID,omitempty"`
+	Used     bool   `protobuf:"varint,5,opt,name=used" json:"used,omitempty"`
+	Revoked  bool   `protobuf:"varint,6,opt,name=revoked" json:"revoked,omitempty"`
+	Issued   string `protobuf:"bytes,7,opt,name=issued" json:"issued,omitempty"`
+	Expires  string `protobuf:"bytes,8,opt,name=expires" json:"expires,omitempty"`
+}
+
+func (m *IssuedToken) Reset()                    { *m = IssuedToken{} }
+func (m *IssuedToken) String() string            { return proto.CompactTextString(m) }
+func (*IssuedToken) ProtoMessage()               {}
+func (*IssuedToken) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *IssuedToken) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *IssuedToken) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *IssuedToken) GetAPIKeyID() string {
+	if m != nil {
+		return m.APIKeyID
+	}
+	return ""
+}
+
+func (m *IssuedToken) GetFamilyID() string {
+	if m != nil {
+		return m.FamilyID
+	}
+	return ""
+}
+
+func (m *IssuedToken) GetUsed() bool {
+	if m != nil {
+		return m.Used
+	}
+	return false
+}
+
+func (m *IssuedToken) GetRevoked() bool {
+	if m != nil {
+		return m.Revoked
+	}
+	return false
+}
+
+func (m *IssuedToken) GetIssued() string {
+	if m != nil {
+		return m.Issued
+	}
+	return ""
+}
+
+func (m *IssuedToken) GetExpires() string {
+	if m != nil {
+		return m.Expires
+	}
+	return ""
+}
+
+type History struct {
+	ID         string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	UserID     string `protobuf:"bytes,2,opt,name=userID" json:"userID,omitempty"`
+	AccessType string `protobuf:"bytes,3,opt,name=accessType" json:"accessType,omitempty"`
+	LoginType  string `protobuf:"bytes,4,opt,name=loginType" json:"loginType,omitempty"`
+	Successful bool   `protobuf:"varint,5,opt,name=successful" json:"successful,omitempty"`
+	IPAddress  string `protobuf:"bytes,6,opt,name=IPAddress" json:"IPAddress,omitempty"`
+	UserAgent  string `protobuf:"bytes,7,opt,name=userAgent" json:"userAgent,omitempty"`
+	Created    string `protobuf:"bytes,8,opt,name=created" json:"created,omitempty"`
+}
+
+func (m *History) Reset()                    { *m = History{} }
+func (m *History) String() string            { return proto.CompactTextString(m) }
+func (*History) ProtoMessage()               {}
+func (*History) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *History) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *History) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *History) GetAccessType() string {
+	if m != nil {
+		return m.AccessType
+	}
+	return ""
+}
+
+func (m *History) GetLoginType() string {
+	if m != nil {
+		return m.LoginType
+	}
+	return ""
+}
+
+func (m *History) GetSuccessful() bool {
+	if m != nil {
+		return m.Successful
+	}
+	return false
+}
+
+func (m *History) GetIPAddress() string {
+	if m != nil {
+		return m.IPAddress
+	}
+	return ""
+}
+
+func (m *History) GetUserAgent() string {
+	if m != nil {
+		return m.UserAgent
+	}
+	return ""
+}
+
+func (m *History) GetCreated() string {
+	if m != nil {
+		return m.Created
+	}
+	return ""
+}
+
+type UserExport struct {
+	User             *User             `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	APIKeys          []*APIKey         `protobuf:"bytes,2,rep,name=APIKeys" json:"APIKeys,omitempty"`
+	Sessions         []*Session        `protobuf:"bytes,3,rep,name=sessions" json:"sessions,omitempty"`
+	RefreshTokens    []*IssuedToken    `protobuf:"bytes,4,rep,name=refreshTokens" json:"refreshTokens,omitempty"`
+	EmailTokens      []*IssuedToken    `protobuf:"bytes,5,rep,name=emailTokens" json:"emailTokens,omitempty"`
+	PhoneTokens      []*IssuedToken    `protobuf:"bytes,6,rep,name=phoneTokens" json:"phoneTokens,omitempty"`
+	LoginHistory     []*History        `protobuf:"bytes,7,rep,name=loginHistory" json:"loginHistory,omitempty"`
+	Exported         string            `protobuf:"bytes,8,opt,name=exported" json:"exported,omitempty"`
+	LinkedIdentities []*LinkedIdentity `protobuf:"bytes,9,rep,name=linkedIdentities" json:"linkedIdentities,omitempty"`
+	Totp             *TOTPStatus       `protobuf:"bytes,10,opt,name=totp" json:"totp,omitempty"`
+	LoginFailures    []*LoginFailure   `protobuf:"bytes,11,rep,name=loginFailures" json:"loginFailures,omitempty"`
+}
+
+func (m *UserExport) Reset()                    { *m = UserExport{} }
+func (m *UserExport) String() string            { return proto.CompactTextString(m) }
+func (*UserExport) ProtoMessage()               {}
+func (*UserExport) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *UserExport) GetUser() *User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *UserExport) GetAPIKeys() []*APIKey {
+	if m != nil {
+		return m.APIKeys
+	}
+	return nil
+}
+
+func (m *UserExport) GetSessions() []*Session {
+	if m != nil {
+		return m.Sessions
+	}
+	return nil
+}
+
+func (m *UserExport) GetRefreshTokens() []*IssuedToken {
+	if m != nil {
+		return m.RefreshTokens
+	}
+	return nil
+}
+
+func (m *UserExport) GetEmailTokens() []*IssuedToken {
+	if m != nil {
+		return m.EmailTokens
+	}
+	return nil
+}
+
+func (m *UserExport) GetPhoneTokens() []*IssuedToken {
+	if m != nil {
+		return m.PhoneTokens
+	}
+	return nil
+}
+
+func (m *UserExport) GetLoginHistory() []*History {
+	if m != nil {
+		return m.LoginHistory
+	}
+	return nil
+}
+
+func (m *UserExport) GetExported() string {
+	if m != nil {
+		return m.Exported
+	}
+	return ""
+}
+
+func (m *UserExport) GetLinkedIdentities() []*LinkedIdentity {
+	if m != nil {
+		return m.LinkedIdentities
+	}
+	return nil
+}
+
+func (m *UserExport) GetTotp() *TOTPStatus {
+	if m != nil {
+		return m.Totp
+	}
+	return nil
+}
+
+func (m *UserExport) GetLoginFailures() []*LoginFailure {
+	if m != nil {
+		return m.LoginFailures
+	}
+	return nil
+}
+
+type ExportUserReq struct {
+	APIKey string `protobuf:"bytes,1,opt,name=APIKey" json:"APIKey,omitempty"`
+	JWT    string `protobuf:"bytes,2,opt,name=JWT" json:"JWT,omitempty"`
+	UserID string `protobuf:"bytes,3,opt,name=userID" json:"userID,omitempty"`
+}
+
+func (m *ExportUserReq) Reset()                    { *m = ExportUserReq{} }
+func (m *ExportUserReq) String() string            { return proto.CompactTextString(m) }
+func (*ExportUserReq) ProtoMessage()               {}
+func (*ExportUserReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ExportUserReq) GetAPIKey() string {
+	if m != nil {
+		return m.APIKey
+	}
+	return ""
+}
+
+func (m *ExportUserReq) GetJWT() string {
+	if m != nil {
+		return m.JWT
+	}
+	return ""
+}
+
+func (m *ExportUserReq) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+type LinkedIdentity struct {
+	ID          string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	UserID      string `protobuf:"bytes,2,opt,name=userID" json:"userID,omitempty"`
+	Issuer      string `protobuf:"bytes,3,opt,name=issuer" json:"issuer,omitempty"`
+	Subject     string `protobuf:"bytes,4,opt,name=subject" json:"subject,omitempty"`
+	Created     string `protobuf:"bytes,5,opt,name=created" json:"created,omitempty"`
+	LastUpdated string `protobuf:"bytes,6,opt,name=lastUpdated" json:"lastUpdated,omitempty"`
+}
+
+func (m *LinkedIdentity) Reset()                    { *m = LinkedIdentity{} }
+func (m *LinkedIdentity) String() string            { return proto.CompactTextString(m) }
+func (*LinkedIdentity) ProtoMessage()               {}
+func (*LinkedIdentity) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *LinkedIdentity) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *LinkedIdentity) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *LinkedIdentity) GetIssuer() string {
+	if m != nil {
+		return m.Issuer
+	}
+	return ""
+}
+
+func (m *LinkedIdentity) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *LinkedIdentity) GetCreated() string {
+	if m != nil {
+		return m.Created
+	}
+	return ""
+}
+
+func (m *LinkedIdentity) GetLastUpdated() string {
+	if m != nil {
+		return m.LastUpdated
+	}
+	return ""
+}
+
+type TOTPStatus struct {
+	Enrolled    bool   `protobuf:"varint,1,opt,name=enrolled" json:"enrolled,omitempty"`
+	Confirmed   bool   `protobuf:"varint,2,opt,name=confirmed" json:"confirmed,omitempty"`
+	Created     string `protobuf:"bytes,3,opt,name=created" json:"created,omitempty"`
+	LastUpdated string `protobuf:"bytes,4,opt,name=lastUpdated" json:"lastUpdated,omitempty"`
+}
+
+func (m *TOTPStatus) Reset()                    { *m = TOTPStatus{} }
+func (m *TOTPStatus) String() string            { return proto.CompactTextString(m) }
+func (*TOTPStatus) ProtoMessage()               {}
+func (*TOTPStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *TOTPStatus) GetEnrolled() bool {
+	if m != nil {
+		return m.Enrolled
+	}
+	return false
+}
+
+func (m *TOTPStatus) GetConfirmed() bool {
+	if m != nil {
+		return m.Confirmed
+	}
+	return false
+}
+
+func (m *TOTPStatus) GetCreated() string {
+	if m != nil {
+		return m.Created
+	}
+	return ""
+}
+
+func (m *TOTPStatus) GetLastUpdated() string {
+	if m != nil {
+		return m.LastUpdated
+	}
+	return ""
+}
+
+type LoginFailure struct {
+	ID         string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	LoginType  string `protobuf:"bytes,2,opt,name=loginType" json:"loginType,omitempty"`
+	Identifier string `protobuf:"bytes,3,opt,name=identifier" json:"identifier,omitempty"`
+	IPAddress  string `protobuf:"bytes,4,opt,name=IPAddress" json:"IPAddress,omitempty"`
+	Created    string `protobuf:"bytes,5,opt,name=created" json:"created,omitempty"`
+}
+
+func (m *LoginFailure) Reset()                    { *m = LoginFailure{} }
+func (m *LoginFailure) String() string            { return proto.CompactTextString(m) }
+func (*LoginFailure) ProtoMessage()               {}
+func (*LoginFailure) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *LoginFailure) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *LoginFailure) GetLoginType() string {
+	if m != nil {
+		return m.LoginType
+	}
+	return ""
+}
+
+func (m *LoginFailure) GetIdentifier() string {
+	if m != nil {
+		return m.Identifier
+	}
+	return ""
+}
+
+func (m *LoginFailure) GetIPAddress() string {
+	if m != nil {
+		return m.IPAddress
+	}
+	return ""
+}
+
+func (m *LoginFailure) GetCreated() string {
+	if m != nil {
+		return m.Created
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*UserName)(nil), "api.UserName")
 	proto.RegisterType((*UserType)(nil), "api.UserType")
@@ -473,6 +1010,15 @@ func init() {
 	proto.RegisterType((*Device)(nil), "api.Device")
 	proto.RegisterType((*User)(nil), "api.User")
 	proto.RegisterType((*GetDetailsReq)(nil), "api.GetDetailsReq")
+	proto.RegisterType((*APIKey)(nil), "api.APIKey")
+	proto.RegisterType((*Session)(nil), "api.Session")
+	proto.RegisterType((*IssuedToken)(nil), "api.IssuedToken")
+	proto.RegisterType((*History)(nil), "api.History")
+	proto.RegisterType((*UserExport)(nil), "api.UserExport")
+	proto.RegisterType((*ExportUserReq)(nil), "api.ExportUserReq")
+	proto.RegisterType((*LinkedIdentity)(nil), "api.LinkedIdentity")
+	proto.RegisterType((*TOTPStatus)(nil), "api.TOTPStatus")
+	proto.RegisterType((*LoginFailure)(nil), "api.LoginFailure")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -484,6 +1030,7 @@ var _ server.Option
 
 type UsersClient interface {
 	GetDetails(ctx context.Context, in *GetDetailsReq, opts ...client.CallOption) (*User, error)
+	ExportUser(ctx context.Context, in *ExportUserReq, opts ...client.CallOption) (*UserExport, error)
 }
 
 type usersClient struct {
@@ -514,10 +1061,21 @@ func (c *usersClient) GetDetails(ctx context.Context, in *GetDetailsReq, opts ..
 	return out, nil
 }
 
+func (c *usersClient) ExportUser(ctx context.Context, in *ExportUserReq, opts ...client.CallOption) (*UserExport, error) {
+	req := c.c.NewRequest(c.serviceName, "Users.ExportUser", in)
+	out := new(UserExport)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Users service
 
 type UsersHandler interface {
 	GetDetails(context.Context, *GetDetailsReq, *User) error
+	ExportUser(context.Context, *ExportUserReq, *UserExport) error
 }
 
 func RegisterUsersHandler(s server.Server, hdlr UsersHandler, opts ...server.HandlerOption) {
@@ -532,40 +1090,77 @@ func (h *Users) GetDetails(ctx context.Context, in *GetDetailsReq, out *User) er
 	return h.UsersHandler.GetDetails(ctx, in, out)
 }
 
+func (h *Users) ExportUser(ctx context.Context, in *ExportUserReq, out *UserExport) error {
+	return h.UsersHandler.ExportUser(ctx, in, out)
+}
+
 func init() { proto.RegisterFile("github.com/tomogoma/authms/api/users.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 511 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x94, 0xdf, 0x8b, 0xd3, 0x40,
-	0x10, 0xc7, 0xc9, 0xcf, 0xa6, 0x13, 0x4e, 0x65, 0x11, 0x09, 0x7d, 0x90, 0x5a, 0x38, 0x38, 0x15,
-	0x5a, 0xa8, 0xfe, 0x03, 0x42, 0xf0, 0x88, 0x1e, 0xa2, 0xcb, 0x9d, 0x3e, 0x6f, 0xd3, 0xbd, 0x76,
-	0x35, 0xe9, 0xc6, 0x6c, 0x52, 0xe8, 0xbb, 0x0f, 0xfa, 0x6f, 0xf8, 0xe8, 0x1f, 0xe8, 0xb3, 0xec,
-	0xe4, 0x47, 0x83, 0x39, 0x4a, 0xa1, 0xdc, 0x5b, 0x66, 0xbe, 0xb3, 0x99, 0x4f, 0x66, 0xbe, 0x1b,
-	0x78, 0xb1, 0x12, 0xc5, 0xba, 0x5c, 0x4c, 0x63, 0x99, 0xce, 0x0a, 0x99, 0xca, 0x95, 0x4c, 0xd9,
-	0x8c, 0x95, 0xc5, 0x3a, 0x55, 0x33, 0x96, 0x89, 0x59, 0xa9, 0x78, 0xae, 0xa6, 0x59, 0x2e, 0x0b,
-	0x49, 0x2c, 0x96, 0x89, 0xc9, 0x0f, 0x03, 0xbc, 0x1b, 0xc5, 0xf3, 0x0f, 0x2c, 0xe5, 0xe4, 0x01,
-	0x98, 0x51, 0x18, 0x18, 0x63, 0xe3, 0x62, 0x48, 0xcd, 0x28, 0x24, 0x4f, 0xc0, 0xd5, 0x07, 0xa2,
-	0x30, 0x30, 0x31, 0x57, 0x47, 0xe4, 0x31, 0x38, 0x5b, 0x96, 0x94, 0x3c, 0xb0, 0x30, 0x5d, 0x05,
-	0x24, 0x80, 0x41, 0x9c, 0x73, 0x56, 0xf0, 0x65, 0x60, 0x63, 0xbe, 0x09, 0xc9, 0x18, 0xfc, 0x84,
-	0xa9, 0xe2, 0x26, 0x5b, 0xa2, 0xea, 0xa0, 0xda, 0x4d, 0x4d, 0xbe, 0x56, 0x14, 0xd7, 0xbb, 0xac,
-	0x4f, 0x41, 0xc0, 0xde, 0xb0, 0x94, 0xd7, 0x0c, 0xf8, 0xdc, 0xed, 0x65, 0x1d, 0xec, 0x65, 0xf7,
-	0x7b, 0xfd, 0x36, 0x00, 0x3e, 0xf3, 0x5c, 0xdc, 0x5e, 0xc9, 0x95, 0xd8, 0x9c, 0xf8, 0xd1, 0x23,
-	0xf0, 0xb6, 0xfa, 0x5d, 0xa2, 0xee, 0xe5, 0xd1, 0x36, 0xee, 0x42, 0x3a, 0x07, 0x21, 0xdd, 0x3e,
-	0xe4, 0x1f, 0x03, 0xbc, 0xb7, 0x2c, 0xe6, 0x0b, 0x29, 0xbf, 0x1d, 0x8d, 0xf8, 0x14, 0xe0, 0xb6,
-	0x3e, 0x13, 0x85, 0x35, 0x67, 0x27, 0x73, 0x6f, 0xb0, 0xbf, 0x0c, 0x70, 0x2e, 0x73, 0x59, 0x66,
-	0x47, 0xed, 0x6e, 0x0c, 0x3e, 0x8b, 0x63, 0xae, 0xd4, 0x15, 0xdf, 0xf2, 0x04, 0x31, 0x4d, 0xda,
-	0x4d, 0x9d, 0xe4, 0xa4, 0x9f, 0x06, 0xb8, 0x21, 0xdf, 0x8a, 0xf8, 0x78, 0x3b, 0x8f, 0xc0, 0x5b,
-	0xe2, 0x89, 0x76, 0x68, 0x6d, 0x7c, 0x12, 0xca, 0x5f, 0x13, 0x6c, 0xed, 0xea, 0x1e, 0xc8, 0x23,
-	0xb0, 0xde, 0x7d, 0xb9, 0xae, 0x29, 0xf4, 0x23, 0x79, 0x06, 0x76, 0xb1, 0xcb, 0x2a, 0x6f, 0xf9,
-	0xf3, 0xb3, 0x29, 0xcb, 0xc4, 0xb4, 0xb9, 0x10, 0x14, 0x25, 0xf2, 0x1c, 0x3c, 0xcd, 0x8b, 0xe3,
-	0xb4, 0xff, 0x2b, 0xd3, 0xb7, 0x97, 0xb6, 0x32, 0x39, 0x07, 0x27, 0x5b, 0xcb, 0x0d, 0x47, 0x28,
-	0x7f, 0xfe, 0x10, 0xeb, 0xf6, 0x96, 0xa7, 0x95, 0xaa, 0xcb, 0x78, 0xca, 0x44, 0x82, 0x2b, 0xbd,
-	0xab, 0x0c, 0x55, 0xdd, 0xb8, 0xf1, 0x50, 0x30, 0xe8, 0x34, 0x6e, 0xec, 0x49, 0x5b, 0x99, 0x4c,
-	0xc0, 0x5d, 0x69, 0x1f, 0xa8, 0xc0, 0x1b, 0x5b, 0x17, 0xfe, 0x1c, 0xb0, 0x10, 0xad, 0x41, 0x6b,
-	0x85, 0x9c, 0xc3, 0xa0, 0x9a, 0xae, 0x0a, 0x86, 0x58, 0xe4, 0x63, 0x51, 0xb5, 0x33, 0xda, 0x68,
-	0xdd, 0xc1, 0xc3, 0xc1, 0xc1, 0xfb, 0xfd, 0xc1, 0x7f, 0x82, 0xb3, 0x4b, 0x5e, 0x84, 0xbc, 0x60,
-	0x22, 0x51, 0x94, 0x7f, 0xd7, 0x9b, 0x7f, 0xf3, 0x31, 0x7a, 0xcf, 0x77, 0xf5, 0x12, 0xea, 0xe8,
-	0x8e, 0x45, 0xec, 0x3d, 0x62, 0x75, 0x3d, 0x32, 0x7f, 0x0d, 0x8e, 0x1e, 0xb4, 0x22, 0x2f, 0x01,
-	0xf6, 0xef, 0x26, 0xa4, 0xfa, 0xc0, 0x6e, 0xb3, 0xd1, 0xb0, 0x5d, 0xcb, 0xc2, 0xc5, 0x3f, 0xed,
-	0xab, 0x7f, 0x01, 0x00, 0x00, 0xff, 0xff, 0xa4, 0xf6, 0x20, 0xa5, 0x97, 0x05, 0x00, 0x00,
+	// 1029 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb5, 0x57, 0xcd, 0x6e, 0x23, 0x45,
+	0x10, 0xd6, 0xf8, 0x77, 0x52, 0x93, 0xec, 0x86, 0x06, 0xad, 0xac, 0x08, 0x50, 0x30, 0x42, 0x5a,
+	0x40, 0x4a, 0xc0, 0x48, 0x70, 0x5c, 0x45, 0x0a, 0xbb, 0x18, 0x22, 0x08, 0x1d, 0x2f, 0x7b, 0x9e,
+	0xd8, 0x6d, 0x67, 0x76, 0xc7, 0xd3, 0xc3, 0xf4, 0x8c, 0xb5, 0xb9, 0xae, 0xf6, 0xc0, 0xde, 0x79,
+	0x02, 0x0e, 0x1c, 0x78, 0x19, 0xce, 0x3c, 0x00, 0xaf, 0xc0, 0x99, 0xea, 0xea, 0xee, 0x99, 0x31,
+	0x4e, 0x8c, 0x91, 0x97, 0x9b, 0xeb, 0xa7, 0xbb, 0xbe, 0xaa, 0xfa, 0xaa, 0x7a, 0x0c, 0x1f, 0xcd,
+	0xa2, 0xfc, 0xaa, 0xb8, 0x3c, 0x1a, 0xcb, 0xf9, 0x71, 0x2e, 0xe7, 0x72, 0x26, 0xe7, 0xe1, 0x71,
+	0x58, 0xe4, 0x57, 0x73, 0x75, 0x1c, 0xa6, 0xd1, 0x71, 0xa1, 0x44, 0xa6, 0x8e, 0xd2, 0x4c, 0xe6,
+	0x92, 0x35, 0x51, 0xd1, 0x7f, 0xe9, 0x81, 0xff, 0x18, 0x95, 0xdf, 0x86, 0x73, 0xc1, 0xee, 0x40,
+	0x63, 0x78, 0xda, 0xf3, 0x0e, 0xbd, 0xfb, 0x3b, 0x1c, 0x7f, 0xb1, 0x7b, 0xd0, 0xd1, 0x07, 0x50,
+	0xd7, 0x20, 0x9d, 0x95, 0xd8, 0x5b, 0xd0, 0x5e, 0x84, 0x71, 0x21, 0x7a, 0x4d, 0x52, 0x1b, 0x81,
+	0xf5, 0xa0, 0x3b, 0xce, 0x44, 0x98, 0x8b, 0x49, 0xaf, 0x45, 0x7a, 0x27, 0xb2, 0x43, 0x08, 0xe2,
+	0x50, 0xe5, 0x8f, 0xd3, 0x09, 0x59, 0xdb, 0x64, 0xad, 0xab, 0xfa, 0x4f, 0x0d, 0x8a, 0xd1, 0x75,
+	0xba, 0x8a, 0x82, 0x41, 0x2b, 0x41, 0x74, 0x16, 0x03, 0xfd, 0xae, 0xc7, 0x6a, 0xae, 0x8d, 0xd5,
+	0x5a, 0x8d, 0xf5, 0x8b, 0x07, 0xf0, 0x83, 0xc8, 0xa2, 0xe9, 0x99, 0x9c, 0x45, 0xc9, 0x96, 0x49,
+	0x1f, 0x80, 0xbf, 0xd0, 0x77, 0x45, 0x36, 0x96, 0xcf, 0x4b, 0xb9, 0x0e, 0xb2, 0xbd, 0x16, 0x64,
+	0x67, 0x15, 0xe4, 0x6f, 0xd8, 0x97, 0x87, 0xe1, 0x58, 0x5c, 0x4a, 0xf9, 0x6c, 0x63, 0x88, 0xef,
+	0x02, 0x4c, 0xed, 0x19, 0xb4, 0x19, 0x9c, 0x35, 0xcd, 0xff, 0x06, 0xf6, 0x95, 0x07, 0xed, 0x47,
+	0x99, 0x2c, 0xd2, 0x8d, 0x7a, 0x87, 0xf7, 0x85, 0xe3, 0xb1, 0x50, 0xea, 0x4c, 0x2c, 0x44, 0x4c,
+	0x30, 0x1b, 0xbc, 0xae, 0xda, 0x8a, 0x49, 0x3f, 0x79, 0xd0, 0x39, 0x15, 0x8b, 0x68, 0xbc, 0x39,
+	0x9d, 0xb1, 0x2c, 0x13, 0x3a, 0x51, 0x16, 0xad, 0x94, 0xb7, 0x82, 0xf2, 0x57, 0x03, 0x5a, 0x9a,
+	0xd5, 0x2b, 0x40, 0xf6, 0xa1, 0xf9, 0xf5, 0x93, 0x91, 0x45, 0xa1, 0x7f, 0xb2, 0xf7, 0xa0, 0x95,
+	0x23, 0xf7, 0x29, 0x7c, 0x30, 0xd8, 0x3b, 0xc2, 0xd1, 0x3c, 0x72, 0x03, 0xc1, 0xc9, 0xc4, 0x3e,
+	0x04, 0x5f, 0xe3, 0xa5, 0x72, 0xb6, 0xfe, 0xe1, 0xa6, 0xa7, 0x97, 0x97, 0x66, 0xf6, 0x01, 0xb4,
+	0xd3, 0x2b, 0x99, 0x08, 0x02, 0x15, 0x0c, 0xee, 0x92, 0x5f, 0x45, 0x79, 0x6e, 0xac, 0xda, 0x4d,
+	0xcc, 0xc3, 0x28, 0xa6, 0x96, 0xde, 0xe4, 0x46, 0x56, 0x1d, 0xd8, 0x71, 0xa8, 0xd7, 0xad, 0x05,
+	0x76, 0xf4, 0xe4, 0xa5, 0x99, 0xf5, 0xa1, 0x33, 0xd3, 0x3c, 0x50, 0x3d, 0xff, 0xb0, 0x89, 0x8e,
+	0x40, 0x8e, 0x44, 0x0d, 0x6e, 0x2d, 0x18, 0xb5, 0x6b, 0xaa, 0xab, 0x7a, 0x3b, 0xe4, 0x14, 0x90,
+	0x93, 0xe9, 0x19, 0x77, 0xb6, 0x7a, 0xe1, 0x61, 0x6d, 0xe1, 0x83, 0xd5, 0xc2, 0x7f, 0x0f, 0x7b,
+	0x8f, 0x44, 0x7e, 0x2a, 0x72, 0x84, 0xaf, 0xb8, 0xf8, 0x51, 0x77, 0xfe, 0xe4, 0x7c, 0xf8, 0x8d,
+	0xb8, 0xb6, 0x4d, 0xb0, 0xd2, 0x0d, 0x8d, 0xa8, 0x38, 0xd2, 0xac, 0x73, 0xa4, 0x1f, 0xbb, 0x1b,
+	0x36, 0x66, 0xd5, 0x36, 0x2b, 0xea, 0x0f, 0x0f, 0xba, 0x17, 0x38, 0x0e, 0x91, 0x4c, 0x5e, 0x0b,
+	0x8b, 0xdf, 0x86, 0x9d, 0xe1, 0xf9, 0xc9, 0x64, 0x92, 0xe1, 0x9d, 0x36, 0x5e, 0xa5, 0xd0, 0x56,
+	0x7d, 0xc7, 0xc9, 0x4c, 0x24, 0xb9, 0xe5, 0x71, 0xa5, 0xd0, 0x79, 0x64, 0x62, 0x21, 0x9f, 0xd9,
+	0xd1, 0xf7, 0xb9, 0x13, 0xeb, 0x19, 0x76, 0x97, 0x33, 0x44, 0x2c, 0x3a, 0x9d, 0x0b, 0x21, 0x12,
+	0x64, 0x02, 0x61, 0x71, 0x72, 0xff, 0x77, 0x0f, 0x82, 0xa1, 0x52, 0x85, 0x98, 0x8c, 0xf0, 0x96,
+	0xd5, 0xfc, 0xf0, 0xd6, 0xd0, 0x22, 0x35, 0x09, 0x3a, 0x51, 0xdf, 0x6a, 0x7a, 0x50, 0x65, 0xe8,
+	0x64, 0x6d, 0x9b, 0x86, 0xf3, 0x28, 0xd6, 0x36, 0x93, 0x60, 0x29, 0xeb, 0x25, 0x84, 0xe9, 0x98,
+	0x11, 0xf5, 0x39, 0xfd, 0x5e, 0x93, 0x15, 0xd6, 0x37, 0x22, 0x78, 0x36, 0x29, 0x2b, 0xe9, 0x13,
+	0xe2, 0x79, 0x1a, 0x21, 0x12, 0x9b, 0x92, 0x13, 0xfb, 0x7f, 0x62, 0xb7, 0xbe, 0x8a, 0x54, 0x2e,
+	0xb3, 0xeb, 0xff, 0xb2, 0xaa, 0xcd, 0xc6, 0x1b, 0xb9, 0xb1, 0xc7, 0x55, 0x5d, 0x69, 0x74, 0x4f,
+	0x62, 0x3d, 0x84, 0x64, 0xb6, 0x1d, 0x2b, 0x15, 0xfa, 0xb4, 0x2a, 0xc8, 0x79, 0x5a, 0xc4, 0x36,
+	0xaf, 0x9a, 0x66, 0xb9, 0xdf, 0x9d, 0xb5, 0xfd, 0xee, 0xde, 0xd0, 0x6f, 0xd7, 0x55, 0x7f, 0xa9,
+	0xab, 0xfd, 0x57, 0x2d, 0x00, 0xbd, 0x6d, 0xbe, 0x7c, 0x9e, 0xca, 0x2c, 0x67, 0xef, 0x50, 0x59,
+	0x33, 0x4a, 0x36, 0x18, 0xec, 0x94, 0xcb, 0x88, 0x2a, 0x9c, 0xe9, 0x39, 0x37, 0xdd, 0xd1, 0x7d,
+	0xac, 0xe6, 0xdc, 0xe8, 0xb8, 0xb3, 0xb1, 0xfb, 0xe0, 0x2b, 0xc3, 0x74, 0x85, 0x65, 0xd0, 0x7e,
+	0xbb, 0xe4, 0x67, 0xe9, 0xcf, 0x4b, 0x2b, 0xfb, 0x1c, 0xf6, 0x32, 0x31, 0xc5, 0x0c, 0xae, 0x88,
+	0x38, 0x9a, 0xc8, 0xda, 0x7d, 0x9f, 0xdc, 0x6b, 0x8c, 0xe2, 0xcb, 0x6e, 0x6c, 0x00, 0x01, 0x2d,
+	0x32, 0x7b, 0xaa, 0x7d, 0xcb, 0xa9, 0xba, 0x93, 0x3e, 0x43, 0x3b, 0xd2, 0x9e, 0xe9, 0xdc, 0x76,
+	0xa6, 0xe6, 0xc4, 0x3e, 0x81, 0x5d, 0xea, 0x90, 0xa5, 0x02, 0x56, 0xb6, 0xca, 0xc6, 0xea, 0xf8,
+	0x92, 0x87, 0x26, 0xad, 0xa0, 0x5a, 0x96, 0xb5, 0x2e, 0x65, 0xf6, 0x00, 0xf6, 0xe3, 0x28, 0x41,
+	0x42, 0x0e, 0x27, 0xd8, 0x95, 0x28, 0x8f, 0xca, 0x7d, 0xf9, 0x26, 0xdd, 0x78, 0x56, 0x37, 0x5e,
+	0xf3, 0x15, 0x67, 0xf6, 0x3e, 0x3e, 0x29, 0x32, 0x4f, 0x69, 0x7b, 0xba, 0xe5, 0x3e, 0xfa, 0x6e,
+	0x74, 0x7e, 0x91, 0x87, 0x79, 0xa1, 0x38, 0x19, 0xd9, 0x17, 0xb0, 0x47, 0x88, 0x1e, 0x62, 0xea,
+	0x85, 0xa6, 0x76, 0x40, 0x21, 0xde, 0x30, 0x21, 0x6a, 0x16, 0xbe, 0xec, 0xa7, 0x57, 0xac, 0xa1,
+	0x01, 0x75, 0xfc, 0xb5, 0xac, 0xd8, 0x5f, 0x3d, 0xb8, 0xb3, 0x9c, 0xd5, 0xc6, 0xd3, 0xe4, 0x66,
+	0x36, 0x73, 0x57, 0x1a, 0x49, 0x73, 0x59, 0x15, 0x97, 0x4f, 0xc5, 0x38, 0x77, 0xaf, 0xb7, 0x15,
+	0xb7, 0xfa, 0xdc, 0x79, 0x81, 0x1f, 0x90, 0x55, 0x25, 0xa9, 0x8b, 0x49, 0x26, 0xe3, 0x18, 0xbd,
+	0x3d, 0xf3, 0x55, 0xe5, 0x64, 0x3d, 0x6a, 0x63, 0x99, 0x4c, 0xa3, 0x6c, 0x8e, 0xc6, 0x06, 0x19,
+	0x2b, 0xc5, 0x56, 0x4f, 0xc4, 0xcf, 0x1e, 0xec, 0xd6, 0x1b, 0xb4, 0x52, 0xab, 0xa5, 0x0d, 0xd2,
+	0xb8, 0x61, 0x83, 0x44, 0x54, 0x65, 0xfc, 0xf8, 0x73, 0x55, 0xab, 0x69, 0xfe, 0xe5, 0xc5, 0xb8,
+	0xb5, 0x7a, 0x83, 0x19, 0xb4, 0x35, 0x23, 0x14, 0xfb, 0x18, 0xa0, 0x7a, 0x83, 0x19, 0x33, 0x1f,
+	0x02, 0xf5, 0x47, 0xf9, 0xa0, 0xda, 0x18, 0xec, 0x53, 0x80, 0x8a, 0x4d, 0xd6, 0x79, 0x89, 0x5e,
+	0x07, 0x77, 0x4b, 0x67, 0xa3, 0xbf, 0xec, 0xd0, 0x9f, 0x98, 0xcf, 0xfe, 0x06, 0xaa, 0xc1, 0x66,
+	0x0e, 0xf2, 0x0c, 0x00, 0x00,
 }
@@ -1,24 +1,32 @@
 package bootstrap
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"time"
 
 	"html/template"
 
 	"github.com/tomogoma/authms/api"
 	"github.com/tomogoma/authms/config"
 	"github.com/tomogoma/authms/db"
+	"github.com/tomogoma/authms/encryption"
 	"github.com/tomogoma/authms/facebook"
+	"github.com/tomogoma/authms/generator"
+	"github.com/tomogoma/authms/keyset"
 	"github.com/tomogoma/authms/logging"
 	"github.com/tomogoma/authms/model"
+	"github.com/tomogoma/authms/oidc"
+	"github.com/tomogoma/authms/passhash"
+	"github.com/tomogoma/authms/pwned"
 	"github.com/tomogoma/authms/sms/africas_talking"
 	"github.com/tomogoma/authms/sms/messagebird"
 	"github.com/tomogoma/authms/sms/twilio"
 	"github.com/tomogoma/authms/smtp"
-	token "github.com/tomogoma/jwt"
+	"golang.org/x/crypto/bcrypt"
 	"path"
 )
 
@@ -44,18 +52,58 @@ func InstantiateRoach(lg logging.Logger, conf *config.General) *db.Roach {
 	return rdb
 }
 
-func InstantiateJWTHandler(lg logging.Logger, conf config.JWT) *token.Handler {
+func InstantiateJWTHandler(lg logging.Logger, conf config.JWT) *keyset.KeySet {
+	var opts []keyset.Option
 	JWTKey := []byte(conf.TokenKey)
-	if len(JWTKey) == 0 {
+	if len(JWTKey) == 0 && (len(conf.SigningKeys) == 0 || conf.TokenKeyFile != "") {
 		var err error
 		JWTKey, err = ioutil.ReadFile(conf.TokenKeyFile)
-		logging.LogFatalOnError(lg, err, "Read JWT key file")
+		if len(conf.SigningKeys) == 0 {
+			logging.LogFatalOnError(lg, err, "Read JWT key file")
+		} else {
+			// The HS256 key only validates tokens issued before
+			// signing keys were configured.
+			logging.LogWarnOnError(lg, err, "Read JWT key file")
+		}
+	}
+	if len(JWTKey) > 0 {
+		opts = append(opts, keyset.WithHS256Key(JWTKey))
 	}
-	jwter, err := token.NewHandler(JWTKey)
+	keys, err := readSigningKeys(conf.SigningKeys)
+	logging.LogFatalOnError(lg, err, "Read JWT signing keys")
+	jwter, err := keyset.New(keys, opts...)
 	logging.LogFatalOnError(lg, err, "Instantiate JWT handler")
 	return jwter
 }
 
+func readSigningKeys(confs []config.SigningKey) ([]keyset.Key, error) {
+	var keys []keyset.Key
+	for _, conf := range confs {
+		PEM, err := ioutil.ReadFile(conf.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read private key file for '%s': %v", conf.ID, err)
+		}
+		privKey, err := keyset.ParsePrivateKey(PEM)
+		if err != nil {
+			return nil, fmt.Errorf("parse private key for '%s': %v", conf.ID, err)
+		}
+		var expiresAt time.Time
+		if conf.ExpiresAt != "" {
+			expiresAt, err = time.Parse(time.RFC3339, conf.ExpiresAt)
+			if err != nil {
+				return nil, fmt.Errorf("parse expiry for '%s': %v", conf.ID, err)
+			}
+		}
+		keys = append(keys, keyset.Key{
+			ID:         conf.ID,
+			PrivateKey: privKey,
+			Retired:    conf.Retired,
+			ExpiresAt:  expiresAt,
+		})
+	}
+	return keys, nil
+}
+
 func InstantiateFacebook(conf config.Facebook) (*facebook.FacebookOAuth, error) {
 	if conf.ID < 1 {
 		return nil, nil
@@ -75,6 +123,102 @@ func InstantiateFacebook(conf config.Facebook) (*facebook.FacebookOAuth, error)
 	return fb, nil
 }
 
+func InstantiateOIDC(confs []config.OIDCProvider) (*oidc.Client, error) {
+	if len(confs) == 0 {
+		return nil, nil
+	}
+	var providers []oidc.ProviderConfig
+	for _, conf := range confs {
+		providers = append(providers, oidc.ProviderConfig{
+			Name:      conf.Name,
+			IssuerURL: conf.IssuerURL,
+			ClientID:  conf.ClientID,
+		})
+	}
+	return oidc.New(providers)
+}
+
+func InstantiateMFAEncrypter(conf config.Auth) (*encryption.AESGCM, error) {
+	key := conf.MFAKey
+	if len(key) == 0 {
+		if conf.MFAKeyFile == "" {
+			return nil, nil
+		}
+		var err error
+		key, err = readFile(conf.MFAKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read MFA key from file: %v", err)
+		}
+	}
+	return encryption.NewAESGCM([]byte(key))
+}
+
+func InstantiatePwnedCorpus(conf config.PwnedPasswords) (*pwned.Corpus, error) {
+	if conf.CorpusPath == "" {
+		return nil, nil
+	}
+	return pwned.New(conf.CorpusPath)
+}
+
+// InstantiatePassHasher returns nil if neither an algorithm nor foreign
+// hash parameters are configured, in which case model.Authentication uses
+// its default. Zero parameters take the defaults of the respective
+// algorithm.
+func InstantiatePassHasher(conf config.PassHashing) (*passhash.Hasher, error) {
+	var opts []passhash.Option
+	if conf.FirebaseScrypt.SignerKey != "" {
+		opt, err := firebaseScryptOpt(conf.FirebaseScrypt)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	switch conf.Algorithm {
+	case "":
+		if len(opts) == 0 {
+			return nil, nil
+		}
+		return passhash.NewBcrypt(bcrypt.DefaultCost, opts...)
+	case passhash.AlgBcrypt:
+		cost := conf.BcryptCost
+		if cost == 0 {
+			cost = bcrypt.DefaultCost
+		}
+		return passhash.NewBcrypt(cost, opts...)
+	case passhash.AlgArgon2id:
+		p := passhash.DefaultArgon2Params
+		if conf.Argon2.Memory > 0 {
+			p.Memory = conf.Argon2.Memory
+		}
+		if conf.Argon2.Iterations > 0 {
+			p.Iterations = conf.Argon2.Iterations
+		}
+		if conf.Argon2.Parallelism > 0 {
+			p.Parallelism = conf.Argon2.Parallelism
+		}
+		return passhash.NewArgon2id(p, opts...)
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm '%s'", conf.Algorithm)
+	}
+}
+
+func firebaseScryptOpt(conf config.FirebaseScrypt) (passhash.Option, error) {
+	signerKey, err := base64.StdEncoding.DecodeString(conf.SignerKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode firebase signer key: %v", err)
+	}
+	saltSep, err := base64.StdEncoding.DecodeString(conf.SaltSeparator)
+	if err != nil {
+		return nil, fmt.Errorf("decode firebase salt separator: %v", err)
+	}
+	return passhash.WithFirebaseScrypt(passhash.FirebaseScryptParams{
+		SignerKey:     signerKey,
+		SaltSeparator: saltSep,
+		Rounds:        conf.Rounds,
+		MemCost:       conf.MemCost,
+	}), nil
+}
+
 func InstantiateSMSer(lg logging.Logger, conf config.SMS) (model.SMSer, error) {
 	if conf.ActiveAPI == "" {
 		lg.WithField(logging.FieldAction, "Instantiate SMS API").Infof("no active SMS API found")
@@ -183,7 +327,39 @@ func InstantiateSMTP(rdb *db.Roach, lg logging.Logger, conf config.SMTP) *smtp.M
 	return emailCl
 }
 
-func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Authentication, *api.Guard, *db.Roach, model.JWTEr, model.SMSer, *smtp.Mailer) {
+// lifetimeOpts converts the configured token lifetimes and OTP formats to
+// model Options. Values not configured keep the model's defaults.
+func lifetimeOpts(conf config.Auth) []model.Option {
+	var opts []model.Option
+	actionLifetimes := map[string]time.Duration{
+		model.ActionInvite:    conf.Lifetimes.Invite,
+		model.ActionResetPass: conf.Lifetimes.ResetPass,
+		model.ActionVerify:    conf.Lifetimes.Verify,
+		model.ActionExtendTkn: conf.Lifetimes.ExtendTkn,
+		model.ActionLogin:     conf.Lifetimes.LoginLink,
+	}
+	for action, d := range actionLifetimes {
+		if d > 0 {
+			opts = append(opts, model.WithActionValidity(action, d))
+		}
+	}
+	if conf.Lifetimes.JWT > 0 {
+		opts = append(opts, model.WithTokenValidity(conf.Lifetimes.JWT))
+	}
+	for grp, d := range conf.Lifetimes.GroupJWTs {
+		opts = append(opts, model.WithGroupTokenValidity(grp, d))
+	}
+	for action, otp := range conf.OTPFormats {
+		alphabet := otp.Alphabet
+		if alphabet == "" {
+			alphabet = generator.NumberChars
+		}
+		opts = append(opts, model.WithOTPFormat(action, otp.Length, alphabet))
+	}
+	return opts
+}
+
+func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Authentication, *api.Guard, *db.Roach, *keyset.KeySet, model.SMSer, *smtp.Mailer) {
 
 	conf := readConfig(confFile, lg)
 
@@ -197,6 +373,15 @@ func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Aut
 		lg.WithField(logging.FieldAction, "Set up OAuth options").Info("using facebook for OAuth")
 		authOpts = append(authOpts, model.WithFacebookCl(fb))
 	}
+	oidcCl, err := InstantiateOIDC(conf.Authentication.OIDCProviders)
+	logging.LogWarnOnError(lg, err, "Set up OAuth options")
+	if oidcCl != nil {
+		for _, p := range conf.Authentication.OIDCProviders {
+			lg.WithField(logging.FieldAction, "Set up OAuth options").
+				Infof("using OpenID Connect provider '%s' (%s)", p.Name, p.IssuerURL)
+		}
+		authOpts = append(authOpts, model.WithOIDCCl(oidcCl))
+	}
 	if len(authOpts) == 0 {
 		lg.WithField(logging.FieldAction, "Set up OAuth options").Info("no OAuth options configured")
 	}
@@ -210,6 +395,41 @@ func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Aut
 	}
 	lg.WithField(logging.FieldAction, "Instantiate SMS API").Info("completed")
 
+	lg.WithField(logging.FieldAction, "Instantiate MFA encrypter").Info("started")
+	mfaEnc, err := InstantiateMFAEncrypter(conf.Authentication)
+	logging.LogWarnOnError(lg, err, "Instantiate MFA encrypter")
+	if mfaEnc != nil {
+		authOpts = append(authOpts, model.WithMFAEncrypter(mfaEnc))
+	} else {
+		lg.WithField(logging.FieldAction, "Instantiate MFA encrypter").
+			Info("no MFA key configured, two-factor authentication disabled")
+	}
+	lg.WithField(logging.FieldAction, "Instantiate MFA encrypter").Info("completed")
+
+	lg.WithField(logging.FieldAction, "Instantiate pwned passwords corpus").Info("started")
+	pwnedCorpus, err := InstantiatePwnedCorpus(conf.Authentication.PwnedPasswords)
+	logging.LogWarnOnError(lg, err, "Instantiate pwned passwords corpus")
+	if pwnedCorpus != nil {
+		threshold := conf.Authentication.PwnedPasswords.Threshold
+		if threshold < 1 {
+			threshold = 1
+		}
+		authOpts = append(authOpts, model.WithPwnedPassCheck(pwnedCorpus,
+			threshold, conf.Authentication.PwnedPasswords.WarnOnLogin))
+	} else {
+		lg.WithField(logging.FieldAction, "Instantiate pwned passwords corpus").
+			Info("no corpus configured, breached passwords allowed")
+	}
+	lg.WithField(logging.FieldAction, "Instantiate pwned passwords corpus").Info("completed")
+
+	lg.WithField(logging.FieldAction, "Instantiate password hasher").Info("started")
+	passHasher, err := InstantiatePassHasher(conf.Authentication.PasswordHashing)
+	logging.LogFatalOnError(lg, err, "Instantiate password hasher")
+	if passHasher != nil {
+		authOpts = append(authOpts, model.WithPassHasher(passHasher, nil))
+	}
+	lg.WithField(logging.FieldAction, "Instantiate password hasher").Info("completed")
+
 	emailCl := InstantiateSMTP(rdb, lg, conf.SMTP)
 	authOpts = append(authOpts, model.WithEmailCl(emailCl))
 
@@ -243,6 +463,18 @@ func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Aut
 		authOpts = append(authOpts, model.WithEmailVerifyTplt(template.ParseFiles(conf.SMTP.VerifyTplFile)))
 	}
 
+	if conf.SMS.LoginTpl != "" {
+		authOpts = append(authOpts, model.WithPhoneLoginTplt(template.New("SMS.LoginTpl").Parse(conf.SMS.LoginTpl)))
+	} else if conf.SMS.LoginTplFile != "" {
+		authOpts = append(authOpts, model.WithPhoneLoginTplt(template.ParseFiles(conf.SMS.LoginTplFile)))
+	}
+
+	if conf.SMTP.LoginLinkTpl != "" {
+		authOpts = append(authOpts, model.WithEmailLoginLinkTplt(template.New("SMTP.LoginLinkTpl").Parse(conf.SMTP.LoginLinkTpl)))
+	} else if conf.SMTP.LoginLinkTplFile != "" {
+		authOpts = append(authOpts, model.WithEmailLoginLinkTplt(template.ParseFiles(conf.SMTP.LoginLinkTplFile)))
+	}
+
 	if conf.SMS.VerifyTpl != "" {
 		authOpts = append(authOpts, model.WithPhoneVerifyTplt(template.New("SMS.VerifyTpl").Parse(conf.SMS.VerifyTpl)))
 	} else if conf.SMS.VerifyTplFile != "" {
@@ -264,7 +496,14 @@ func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Aut
 		model.WithDevLockedToUser(conf.Authentication.LockDevsToUsers),
 		model.WithSelfRegAllowed(conf.Authentication.AllowSelfReg),
 		model.WithVerifyEmailHost(conf.Authentication.VerifyEmailHosts),
+		model.WithLoginLockout(conf.Authentication.BlackListFailCount, conf.Authentication.BlacklistWindow),
+		model.WithLoginOTPThrottle(conf.Authentication.LoginOTPSendLimit, conf.Authentication.LoginOTPWindow),
 	)
+	if conf.Authentication.IPBlackListFailCount > 0 {
+		authOpts = append(authOpts, model.WithIPLockout(conf.Authentication.IPBlackListFailCount))
+	}
+	authOpts = append(authOpts, lifetimeOpts(conf.Authentication)...)
+	authOpts = append(authOpts, model.WithPasswordPolicy(model.PasswordPolicy(conf.Authentication.PasswordPolicy)))
 
 	tg := InstantiateJWTHandler(lg, conf.Token)
 
@@ -280,6 +519,19 @@ func Instantiate(confFile string, lg logging.Logger) (config.General, *model.Aut
 	srvcConfLg.Infof("Locks devices to users: '%t'", conf.Authentication.LockDevsToUsers)
 	srvcConfLg.Infof("Allows self registration: '%t'", conf.Authentication.AllowSelfReg)
 	srvcConfLg.Infof("Verifies Email Hosts: '%t'", conf.Authentication.VerifyEmailHosts)
+	srvcConfLg.Infof("Login lockout fail count: '%d'", conf.Authentication.BlackListFailCount)
+	srvcConfLg.Infof("Login lockout window: '%s'", conf.Authentication.BlacklistWindow)
+	srvcConfLg.Infof("IP lockout fail count: '%d'", conf.Authentication.IPBlackListFailCount)
+	srvcConfLg.Infof("Login OTP send limit: '%d'", conf.Authentication.LoginOTPSendLimit)
+	srvcConfLg.Infof("Login OTP window: '%s'", conf.Authentication.LoginOTPWindow)
+	srvcConfLg.Infof("JWT signing keys: '%d'", len(conf.Token.SigningKeys))
+	srvcConfLg.Infof("Lifetimes: '%+v'", conf.Authentication.Lifetimes)
+	srvcConfLg.Infof("OTP formats: '%+v'", conf.Authentication.OTPFormats)
+	srvcConfLg.Infof("Password policy: '%+v'", conf.Authentication.PasswordPolicy)
+	srvcConfLg.Infof("Password hashing algorithm: '%s'", conf.Authentication.PasswordHashing.Algorithm)
+	srvcConfLg.Infof("Password hashing bcrypt cost: '%d'", conf.Authentication.PasswordHashing.BcryptCost)
+	srvcConfLg.Infof("Password hashing argon2: '%+v'", conf.Authentication.PasswordHashing.Argon2)
+	srvcConfLg.Infof("Imports firebase scrypt hashes: '%t'", conf.Authentication.PasswordHashing.FirebaseScrypt.SignerKey != "")
 	srvcConfLg.Info("completed")
 
 	return *conf, a, g, rdb, tg, sms, emailCl
@@ -75,6 +75,8 @@ EMAIL_RESET_PASS_TPL="` + config.DefaultEmailResetPassTpl() + `"
 PHONE_RESET_PASS_TPL="` + config.DefaultPhoneResetPassTpl() + `"
 EMAIL_VERIFY_TPL="` + config.DefaultEmailVerifyTpl() + `"
 PHONE_VERIFY_TPL="` + config.DefaultPhoneVerifyTpl() + `"
+PHONE_LOGIN_TPL="` + config.DefaultPhoneLoginTpl() + `"
+EMAIL_LOGIN_LINK_TPL="` + config.DefaultEmailLoginLinkTpl() + `"
 DOCS_DIR="` + config.DefaultDocsDir() + `"
 `
 	return ioutil.WriteFile("install/vars.sh", []byte(content), 0755)
@@ -155,6 +157,16 @@ func buildGcloud() error {
 		return errors.Newf("copy phone verification template: %v", err)
 	}
 
+	err = copyIfDestNotExists(path.Join("install", "login_sms.tpl"), config.DefaultPhoneLoginTpl())
+	if err != nil {
+		return errors.Newf("copy phone login template: %v", err)
+	}
+
+	err = copyIfDestNotExists(path.Join("install", "login_link_email.html"), config.DefaultEmailLoginLinkTpl())
+	if err != nil {
+		return errors.Newf("copy email login link template: %v", err)
+	}
+
 	return nil
 }
 
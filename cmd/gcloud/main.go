@@ -15,10 +15,13 @@ func main() {
 
 	config.DefaultConfDir("conf")
 	log := &logrus.Wrapper{}
-	conf, authentication, APIGuard, _, _, _, _ := bootstrap.Instantiate(config.DefaultConfPath(), log)
+	conf, authentication, APIGuard, _, keySet, _, _ := bootstrap.Instantiate(config.DefaultConfPath(), log)
+	tenantAuth := func(tenantID string) httpInternal.Auth {
+		return authentication.ForTenant(tenantID)
+	}
 
-	httpHandler, err := httpInternal.NewHandler(authentication, APIGuard, log,
-		conf.Service.WebAppURL, conf.Service.AllowedOrigins)
+	httpHandler, err := httpInternal.NewHandler(tenantAuth, APIGuard, keySet, log,
+		conf.Service.WebAppURL, conf.Service.AllowedOrigins, conf.Service.TrustedProxies)
 	logging.LogFatalOnError(log, err, "Instantiate http Handler")
 
 	http.Handle("/", httpHandler)
@@ -21,16 +21,21 @@ func main() {
 	confFile := flag.String("conf", config.DefaultConfPath(), "location of config file")
 	flag.Parse()
 	log := &logrus.Wrapper{}
-	conf, authentication, APIGuard, _, _, _, _ := bootstrap.Instantiate(*confFile, log)
+	conf, authentication, APIGuard, _, keySet, _, _ := bootstrap.Instantiate(*confFile, log)
+	tenantAuth := func(tenantID string) http.Auth {
+		return authentication.ForTenant(tenantID)
+	}
 
 	serverRPCQuitCh := make(chan error)
-	rpcSrv, err := rpc.NewHandler(APIGuard, authentication)
+	rpcSrv, err := rpc.NewHandler(APIGuard, func(tenantID string) rpc.UsersModel {
+		return authentication.ForTenant(tenantID)
+	})
 	logging.LogFatalOnError(log, err, "Instantate RPC handler")
 	go serveRPC(conf.Service, rpcSrv, serverRPCQuitCh)
 
 	serverHttpQuitCh := make(chan error)
-	httpHandler, err := http.NewHandler(authentication, APIGuard, log,
-		conf.Service.WebAppURL, conf.Service.AllowedOrigins)
+	httpHandler, err := http.NewHandler(tenantAuth, APIGuard, keySet, log,
+		conf.Service.WebAppURL, conf.Service.AllowedOrigins, conf.Service.TrustedProxies)
 	logging.LogFatalOnError(log, err, "Instantiate HTTP handler")
 	go serveHttp(conf.Service, httpHandler, serverHttpQuitCh)
 
@@ -18,7 +18,10 @@ func main() {
 	flag.Parse()
 
 	logWrapper := &logrus.Wrapper{}
-	conf, authentication, APIGuard, _, _, _, _ := bootstrap.Instantiate(*confPath, logWrapper)
+	conf, authentication, APIGuard, _, keySet, _, _ := bootstrap.Instantiate(*confPath, logWrapper)
+	tenantAuth := func(tenantID string) httpInternal.Auth {
+		return authentication.ForTenant(tenantID)
+	}
 
 	listenNSrvLg := logWrapper.WithField(logging.FieldAction, "Listen and serve")
 
@@ -26,8 +29,8 @@ func main() {
 
 	listenNSrvLg.Infof("Will listen on :'%s'", port)
 
-	httpHandler, err := httpInternal.NewHandler(authentication, APIGuard, listenNSrvLg,
-		conf.Service.WebAppURL, conf.Service.AllowedOrigins)
+	httpHandler, err := httpInternal.NewHandler(tenantAuth, APIGuard, keySet, listenNSrvLg,
+		conf.Service.WebAppURL, conf.Service.AllowedOrigins, conf.Service.TrustedProxies)
 	logging.LogFatalOnError(listenNSrvLg, err, "Instantiate http Handler")
 
 	logging.LogFatalOnError(
@@ -120,3 +120,11 @@ func DefaultEmailVerifyTpl() string {
 func DefaultPhoneVerifyTpl() string {
 	return path.Join(DefaultTplDir(), CanonicalName()+"_phone_verify.tpl")
 }
+
+func DefaultPhoneLoginTpl() string {
+	return path.Join(DefaultTplDir(), CanonicalName()+"_phone_login.tpl")
+}
+
+func DefaultEmailLoginLinkTpl() string {
+	return path.Join(DefaultTplDir(), CanonicalName()+"_email_login_link.html")
+}
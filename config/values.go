@@ -21,6 +21,7 @@ type Service struct {
 	MicroService
 	MasterAPIKey   string   `json:"masterAPIKey" yaml:"masterAPIKey" env:"SRVC_MASTER_API_KEY"`
 	AllowedOrigins []string `json:"allowedOrigins" yaml:"allowedOrigins" env:"-"`
+	TrustedProxies []string `json:"trustedProxies" yaml:"trustedProxies" env:"-"`
 	AppName        string   `json:"appName" yaml:"appName" env:"SRVC_APP_NAME"`
 	WebAppURL      string   `json:"webAppURL" yaml:"webAppURL" env:"SRVC_WEB_APP_URL"`
 	URL            string   `json:"URL" yaml:"URL" env:"SRVC_URL"`
@@ -55,9 +56,11 @@ type SMS struct {
 	InvitationTplFile string         `json:"invitationTpl" yaml:"invitationTpl"`
 	ResetPWDTplFile   string         `json:"resetPwdTpl" yaml:"resetPwdTpl"`
 	VerifyTplFile     string         `json:"verifyTpl" yaml:"verifyTpl"`
+	LoginTplFile      string         `json:"loginTpl" yaml:"loginTpl"`
 	InvitationTpl     string         `json:"-" yaml:"-" env:"SMS_INVITATION_TPL"`
 	ResetPWDTpl       string         `json:"-" yaml:"-" env:"SMS_RESET_PWD_TPL"`
 	VerifyTpl         string         `json:"-" yaml:"-" env:"SMS_VERIFY_TPL"`
+	LoginTpl          string         `json:"-" yaml:"-" env:"SMS_LOGIN_TPL"`
 }
 
 type Facebook struct {
@@ -66,18 +69,105 @@ type Facebook struct {
 	ID         int64  `json:"ID" yaml:"ID" env:"FBK_ID"`
 }
 
+type OIDCProvider struct {
+	Name      string `json:"name" yaml:"name"`
+	IssuerURL string `json:"issuerURL" yaml:"issuerURL"`
+	ClientID  string `json:"clientID" yaml:"clientID"`
+}
+
 type Auth struct {
-	AllowSelfReg       bool          `json:"allowSelfReg" yaml:"allowSelfReg" env:"AUTH_ALLOW_SELFREG"`
-	LockDevsToUsers    bool          `json:"lockDevsToUsers" yaml:"lockDevsToUsers" env:"AUTH_LOCK_DEVS_TO_USERS"`
-	Facebook           Facebook      `json:"facebook" yaml:"facebook"`
-	BlackListFailCount int           `json:"blackListFailCount" yaml:"blackListFailCount" env:"AUTH_BLACKLIST_FAIL_COUNT"`
-	BlacklistWindow    time.Duration `json:"blacklistWindow" yaml:"blacklistWindow" env:"AUTH_BLACKLIST_WINDOW"`
-	VerifyEmailHosts   bool          `json:"verifyEmailHosts" yaml:"verifyEmailHosts" env:"AUTH_VERIFY_EMAIL_HOSTS"`
+	AllowSelfReg         bool           `json:"allowSelfReg" yaml:"allowSelfReg" env:"AUTH_ALLOW_SELFREG"`
+	LockDevsToUsers      bool           `json:"lockDevsToUsers" yaml:"lockDevsToUsers" env:"AUTH_LOCK_DEVS_TO_USERS"`
+	Facebook             Facebook       `json:"facebook" yaml:"facebook"`
+	OIDCProviders        []OIDCProvider `json:"oidcProviders" yaml:"oidcProviders"`
+	BlackListFailCount   int            `json:"blackListFailCount" yaml:"blackListFailCount" env:"AUTH_BLACKLIST_FAIL_COUNT"`
+	BlacklistWindow      time.Duration  `json:"blacklistWindow" yaml:"blacklistWindow" env:"AUTH_BLACKLIST_WINDOW"`
+	IPBlackListFailCount int            `json:"ipBlackListFailCount" yaml:"ipBlackListFailCount" env:"AUTH_IP_BLACKLIST_FAIL_COUNT"`
+	LoginOTPSendLimit    int            `json:"loginOTPSendLimit" yaml:"loginOTPSendLimit" env:"AUTH_LOGIN_OTP_SEND_LIMIT"`
+	LoginOTPWindow       time.Duration  `json:"loginOTPWindow" yaml:"loginOTPWindow" env:"AUTH_LOGIN_OTP_WINDOW"`
+	VerifyEmailHosts     bool           `json:"verifyEmailHosts" yaml:"verifyEmailHosts" env:"AUTH_VERIFY_EMAIL_HOSTS"`
+	MFAKeyFile           string         `json:"mfaKeyFile" yaml:"mfaKeyFile"`
+	MFAKey               string         `json:"-" yaml:"-" env:"AUTH_MFA_KEY"`
+	Lifetimes            Lifetimes      `json:"lifetimes" yaml:"lifetimes"`
+	OTPFormats           map[string]OTP `json:"otpFormats" yaml:"otpFormats" env:"-"`
+	PasswordPolicy       PasswordPolicy `json:"passwordPolicy" yaml:"passwordPolicy"`
+	PwnedPasswords       PwnedPasswords `json:"pwnedPasswords" yaml:"pwnedPasswords"`
+	PasswordHashing      PassHashing    `json:"passwordHashing" yaml:"passwordHashing"`
+}
+
+type PassHashing struct {
+	// Algorithm is one of bcrypt or argon2id.
+	Algorithm  string `json:"algorithm" yaml:"algorithm" env:"AUTH_PASS_HASH_ALGORITHM"`
+	BcryptCost int    `json:"bcryptCost" yaml:"bcryptCost" env:"AUTH_PASS_HASH_BCRYPT_COST"`
+	Argon2     Argon2 `json:"argon2" yaml:"argon2"`
+	// FirebaseScrypt are the hash parameters of a Firebase project users
+	// are imported from.
+	FirebaseScrypt FirebaseScrypt `json:"firebaseScrypt" yaml:"firebaseScrypt"`
+}
+
+type FirebaseScrypt struct {
+	// SignerKey and SaltSeparator are base64 encoded.
+	SignerKey     string `json:"signerKey" yaml:"signerKey" env:"AUTH_PASS_HASH_FIREBASE_SIGNER_KEY"`
+	SaltSeparator string `json:"saltSeparator" yaml:"saltSeparator" env:"AUTH_PASS_HASH_FIREBASE_SALT_SEPARATOR"`
+	Rounds        int    `json:"rounds" yaml:"rounds" env:"AUTH_PASS_HASH_FIREBASE_ROUNDS"`
+	MemCost       int    `json:"memCost" yaml:"memCost" env:"AUTH_PASS_HASH_FIREBASE_MEM_COST"`
+}
+
+type Argon2 struct {
+	// Memory is in KiB.
+	Memory      uint32 `json:"memory" yaml:"memory" env:"AUTH_PASS_HASH_ARGON2_MEMORY"`
+	Iterations  uint32 `json:"iterations" yaml:"iterations" env:"AUTH_PASS_HASH_ARGON2_ITERATIONS"`
+	Parallelism uint8  `json:"parallelism" yaml:"parallelism" env:"AUTH_PASS_HASH_ARGON2_PARALLELISM"`
+}
+
+type PwnedPasswords struct {
+	// CorpusPath is the sorted hash file or directory of range files.
+	CorpusPath  string `json:"corpusPath" yaml:"corpusPath" env:"AUTH_PWNED_CORPUS_PATH"`
+	Threshold   int    `json:"threshold" yaml:"threshold" env:"AUTH_PWNED_THRESHOLD"`
+	WarnOnLogin bool   `json:"warnOnLogin" yaml:"warnOnLogin" env:"AUTH_PWNED_WARN_ON_LOGIN"`
+}
+
+type PasswordPolicy struct {
+	MinLength      int  `json:"minLength" yaml:"minLength" env:"AUTH_PASS_MIN_LENGTH"`
+	MaxLength      int  `json:"maxLength" yaml:"maxLength" env:"AUTH_PASS_MAX_LENGTH"`
+	RequireLower   bool `json:"requireLower" yaml:"requireLower" env:"AUTH_PASS_REQUIRE_LOWER"`
+	RequireUpper   bool `json:"requireUpper" yaml:"requireUpper" env:"AUTH_PASS_REQUIRE_UPPER"`
+	RequireDigit   bool `json:"requireDigit" yaml:"requireDigit" env:"AUTH_PASS_REQUIRE_DIGIT"`
+	RequireSpecial bool `json:"requireSpecial" yaml:"requireSpecial" env:"AUTH_PASS_REQUIRE_SPECIAL"`
+	NoIdentifiers  bool `json:"noIdentifiers" yaml:"noIdentifiers" env:"AUTH_PASS_NO_IDENTIFIERS"`
+	HistoryCount   int  `json:"historyCount" yaml:"historyCount" env:"AUTH_PASS_HISTORY_COUNT"`
+}
+
+// Lifetimes holds how long issued tokens remain valid. Zero values leave
+// the defaults in place.
+type Lifetimes struct {
+	Invite    time.Duration            `json:"invite" yaml:"invite" env:"AUTH_INVITE_VALIDITY"`
+	ResetPass time.Duration            `json:"resetPassword" yaml:"resetPassword" env:"AUTH_RESET_PWD_VALIDITY"`
+	Verify    time.Duration            `json:"verify" yaml:"verify" env:"AUTH_VERIFY_VALIDITY"`
+	ExtendTkn time.Duration            `json:"extendToken" yaml:"extendToken" env:"AUTH_EXTEND_TKN_VALIDITY"`
+	LoginLink time.Duration            `json:"loginLink" yaml:"loginLink" env:"AUTH_LOGIN_LINK_VALIDITY"`
+	JWT       time.Duration            `json:"JWT" yaml:"JWT" env:"AUTH_JWT_VALIDITY"`
+	GroupJWTs map[string]time.Duration `json:"groupJWTs" yaml:"groupJWTs" env:"-"`
+}
+
+// OTP describes the one time codes sent for an action.
+type OTP struct {
+	Length   int    `json:"length" yaml:"length"`
+	Alphabet string `json:"alphabet" yaml:"alphabet"`
 }
 
 type JWT struct {
-	TokenKeyFile string `json:"tokenKeyFile" yaml:"tokenKeyFile"`
-	TokenKey     string `json:"-" yaml:"-" env:"AUTH_JWT_TOKEN_KEY"`
+	TokenKeyFile string       `json:"tokenKeyFile" yaml:"tokenKeyFile"`
+	TokenKey     string       `json:"-" yaml:"-" env:"AUTH_JWT_TOKEN_KEY"`
+	SigningKeys  []SigningKey `json:"signingKeys" yaml:"signingKeys"`
+}
+
+type SigningKey struct {
+	ID             string `json:"id" yaml:"id"`
+	PrivateKeyFile string `json:"privateKeyFile" yaml:"privateKeyFile"`
+	Retired        bool   `json:"retired" yaml:"retired"`
+	// ExpiresAt is an RFC3339 timestamp e.g. 2006-01-02T15:04:05Z
+	ExpiresAt string `json:"expiresAt" yaml:"expiresAt"`
 }
 
 type SMTP struct {
@@ -94,9 +184,11 @@ type SMTP struct {
 	InvitationTplFile string `json:"invitationTpl" yaml:"invitationTpl"`
 	ResetPWDTplFile   string `json:"resetPwdTpl" yaml:"resetPwdTpl"`
 	VerifyTplFile     string `json:"verifyTpl" yaml:"verifyTpl"`
+	LoginLinkTplFile  string `json:"loginLinkTpl" yaml:"loginLinkTpl"`
 	InvitationTpl     string `json:"-" yaml:"-" env:"SMTP_INVITATION_TPL"`
 	ResetPWDTpl       string `json:"-" yaml:"-" env:"SMTP_RESETPWD_TPL"`
 	VerifyTpl         string `json:"-" yaml:"-" env:"SMTP_VERIFY_TPL"`
+	LoginLinkTpl      string `json:"-" yaml:"-" env:"SMTP_LOGIN_LINK_TPL"`
 }
 
 type General struct {
@@ -177,6 +269,7 @@ const (
 	EnvKeyDbName             = "DB_NAME"
 	EnvKeyDbSSLMode          = "DB_SSL_MODE"
 	EnvKeySrvcAllowedOrigins = "SRVC_ALLOWED_ORIGINS"
+	EnvKeySrvcTrustedProxies = "SRVC_TRUSTED_PROXIES"
 	EnvKeyDatabaseURL        = "DATABASE_URL"
 )
 
@@ -188,6 +281,9 @@ func unmarshalServcConf(conf *Service) (env.EnvSet, error) {
 	if allowedOrigins, exists := es[EnvKeySrvcAllowedOrigins]; exists {
 		conf.AllowedOrigins = strings.Split(allowedOrigins, ",")
 	}
+	if trustedProxies, exists := es[EnvKeySrvcTrustedProxies]; exists {
+		conf.TrustedProxies = strings.Split(trustedProxies, ",")
+	}
 	return es, nil
 }
 
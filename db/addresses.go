@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// The functions in this file are shared between TblEmails and TblPhones
+// whose address column is addrCol.
+
+func userAddresses(db *sql.DB, tbl, addrCol, userID string) ([]model.VerifLogin, error) {
+	cols := ColDesc(ColID, ColUserID, addrCol, ColVerified, ColIsPrimary, ColCreateDate, ColUpdateDate)
+	q := `
+		SELECT ` + cols + ` FROM ` + tbl + `
+			WHERE ` + ColUserID + `=$1
+			ORDER BY ` + ColIsPrimary + ` DESC, ` + ColCreateDate + ` ASC`
+	rows, err := db.Query(q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var vls []model.VerifLogin
+	for rows.Next() {
+		vl := model.VerifLogin{}
+		err := rows.Scan(&vl.ID, &vl.UserID, &vl.Address, &vl.Verified,
+			&vl.IsPrimary, &vl.CreateDate, &vl.UpdateDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		vls = append(vls, vl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(vls) == 0 {
+		return nil, errors.NewNotFoundf("no %s found for user", tbl)
+	}
+	return vls, nil
+}
+
+func verifyUserAddress(tx inserter, tbl, addrCol, userID, address string) (*model.VerifLogin, error) {
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return nil, errorNilTx
+	}
+	vl := model.VerifLogin{UserID: userID, Address: address, Verified: true}
+	updCols := ColDesc(ColVerified, ColIsPrimary, ColUpdateDate)
+	retCols := ColDesc(ColID, ColIsPrimary, ColCreateDate, ColUpdateDate)
+	// address becomes userID's primary if they have none.
+	q := `
+	UPDATE ` + tbl + `
+		SET (` + updCols + `)=(TRUE,` + ColIsPrimary + ` OR NOT EXISTS (
+			SELECT ` + ColID + ` FROM ` + tbl + `
+				WHERE ` + ColUserID + `=$1 AND ` + ColIsPrimary + `
+		),CURRENT_TIMESTAMP)
+		WHERE ` + ColUserID + `=$1 AND ` + addrCol + `=$2
+		RETURNING ` + retCols
+	err := tx.QueryRow(q, userID, address).Scan(&vl.ID, &vl.IsPrimary, &vl.CreateDate, &vl.UpdateDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundf("%s for user not found", addrCol)
+		}
+		return nil, err
+	}
+	return &vl, nil
+}
+
+func setPrimaryUserAddress(tx inserter, tbl, addrCol, userID, address string) error {
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return errorNilTx
+	}
+	// demote the current primary first as a user can have only one primary
+	// address at a time (see IdxDescEmailsPrimary and IdxDescPhonesPrimary).
+	updCols := ColDesc(ColIsPrimary, ColUpdateDate)
+	q := `
+	UPDATE ` + tbl + `
+		SET (` + updCols + `)=(FALSE,CURRENT_TIMESTAMP)
+		WHERE ` + ColUserID + `=$1 AND ` + ColIsPrimary + ` AND ` + addrCol + `!=$2`
+	if _, err := tx.Exec(q, userID, address); err != nil {
+		return err
+	}
+	q = `
+	UPDATE ` + tbl + `
+		SET (` + updCols + `)=(TRUE,CURRENT_TIMESTAMP)
+		WHERE ` + ColUserID + `=$1 AND ` + addrCol + `=$2`
+	rslt, err := tx.Exec(q, userID, address)
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFoundf("%s for user not found", addrCol)
+	}
+	return nil
+}
+
+func deleteUserAddress(tx inserter, tbl, addrCol, userID, address string) error {
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return errorNilTx
+	}
+	q := `
+	DELETE FROM ` + tbl + `
+		WHERE ` + ColUserID + `=$1 AND ` + addrCol + `=$2 AND NOT ` + ColIsPrimary
+	rslt, err := tx.Exec(q, userID, address)
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFoundf("non-primary %s for user not found", addrCol)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"github.com/tomogoma/authms/api"
+	"github.com/tomogoma/authms/model"
 	errors "github.com/tomogoma/go-typed-errors"
 	"strconv"
 )
@@ -12,16 +13,17 @@ func (r *Roach) InsertAPIKey(userID, key string) (*api.Key, error) {
 		return nil, err
 	}
 	k := api.Key{UserID: userID, APIKey: key}
-	insCols := ColDesc(ColUserID, ColKey, ColUpdateDate)
-	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColKey, ColUpdateDate)
+	retCols := ColDesc(ColID, ColTenantID, ColCreateDate, ColUpdateDate)
 	q := `
 		INSERT INTO ` + TblAPIKeys + ` (` + insCols + `)
-			VALUES ($1, $2, CURRENT_TIMESTAMP)
+			VALUES (` + userTenantQ("$1") + `, $1, $2, CURRENT_TIMESTAMP)
 			RETURNING ` + retCols
-	err := r.db.QueryRow(q, userID, key).Scan(&k.ID, &k.CreateDate, &k.UpdateDate)
+	err := r.db.QueryRow(q, userID, key).Scan(&k.ID, &k.TenantID, &k.CreateDate, &k.UpdateDate)
 	if err != nil {
 		return nil, err
 	}
+	k.TenantID = tenantVal(k.TenantID)
 	return &k, nil
 }
 
@@ -34,7 +36,7 @@ func (r *Roach) APIKeysByUserID(usrID string, offset, count int64) ([]api.Key, e
 	if err != nil {
 		return nil, errors.NewNotFound("only numeric IDs stored here")
 	}
-	cols := ColDesc(ColID, ColUserID, ColKey, ColCreateDate, ColUpdateDate)
+	cols := ColDesc(ColID, ColTenantID, ColUserID, ColKey, ColCreateDate, ColUpdateDate)
 	q := `
 	SELECT ` + cols + `
 		FROM ` + TblAPIKeys + `
@@ -49,7 +51,48 @@ func (r *Roach) APIKeysByUserID(usrID string, offset, count int64) ([]api.Key, e
 	var ks []api.Key
 	for rows.Next() {
 		k := api.Key{}
-		err := rows.Scan(&k.ID, &k.UserID, &k.APIKey, &k.CreateDate, &k.UpdateDate)
+		err := rows.Scan(&k.ID, &k.TenantID, &k.UserID, &k.APIKey, &k.CreateDate, &k.UpdateDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		k.TenantID = tenantVal(k.TenantID)
+		ks = append(ks, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(ks) == 0 {
+		return nil, errors.NewNotFound("no API Keys found for user")
+	}
+	return ks, nil
+}
+
+// APIKeyMetaByUserID returns metadata of API keys for the provided userID
+// starting with the newest. The keys themselves are not fetched.
+func (r *Roach) APIKeyMetaByUserID(usrID string, offset, count int64) ([]model.APIKey, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	userID, err := strconv.ParseInt(usrID, 10, 64)
+	if err != nil {
+		return nil, errors.NewNotFound("only numeric IDs stored here")
+	}
+	cols := ColDesc(ColID, ColUserID, ColCreateDate, ColUpdateDate)
+	q := `
+	SELECT ` + cols + `
+		FROM ` + TblAPIKeys + `
+		WHERE ` + ColUserID + `=$1
+		ORDER BY ` + ColCreateDate + ` DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(q, userID, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ks []model.APIKey
+	for rows.Next() {
+		k := model.APIKey{}
+		err := rows.Scan(&k.ID, &k.UserID, &k.CreateDate, &k.UpdateDate)
 		if err != nil {
 			return nil, errors.Newf("scan result set row: %v", err)
 		}
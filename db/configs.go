@@ -18,6 +18,19 @@ func (r *Roach) GetSMTPConfig(conf interface{}) error {
 	return r.getConf(keySMTPConf, conf)
 }
 
+// UpsertAttributeSchema upserts the user attribute schema of r's tenant
+// into the db.
+func (r *Roach) UpsertAttributeSchema(s interface{}) error {
+	return r.upsertConf(keyAttrSchema+r.tenantArg(), s)
+}
+
+// GetAttributeSchema fetches the user attribute schema of r's tenant from
+// the db and unmarshals it into s. this method fails if s is nil or not a
+// pointer.
+func (r *Roach) GetAttributeSchema(s interface{}) error {
+	return r.getConf(keyAttrSchema+r.tenantArg(), s)
+}
+
 func (r *Roach) upsertConf(key string, conf interface{}) error {
 	if err := r.InitDBIfNot(); err != nil {
 		return err
@@ -14,12 +14,22 @@ func (r *Roach) InsertUserEmail(userID, email string, verified bool) (*model.Ver
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	return insertUserEmail(r.db, userID, email, verified)
+	return insertUserEmail(r.db, userID, email, verified, true)
 }
 
 // InsertUserEmailAtomic inserts email details for userID.
 func (r *Roach) InsertUserEmailAtomic(tx *sql.Tx, userID, email string, verified bool) (*model.VerifLogin, error) {
-	return insertUserEmail(tx, userID, email, verified)
+	return insertUserEmail(tx, userID, email, verified, true)
+}
+
+// InsertUnverifiedUserEmail inserts email for userID as an unverified,
+// non-primary email. VerifyUserEmailAtomic() makes it userID's primary email if
+// they have none by then.
+func (r *Roach) InsertUnverifiedUserEmail(userID, email string) (*model.VerifLogin, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return insertUserEmail(r.db, userID, email, false, false)
 }
 
 // UpdateUserEmail updates email details for userID.
@@ -35,6 +45,32 @@ func (r *Roach) UpdateUserEmailAtomic(tx *sql.Tx, userID, email string, verified
 	return updateUserEmail(tx, userID, email, verified)
 }
 
+// VerifyUserEmailAtomic marks email, one of userID's emails, as verified using tx.
+func (r *Roach) VerifyUserEmailAtomic(tx *sql.Tx, userID, email string) (*model.VerifLogin, error) {
+	return verifyUserAddress(tx, TblEmails, ColEmail, userID, email)
+}
+
+// UserEmailsByUserID fetches all of userID's emails starting with the primary.
+func (r *Roach) UserEmailsByUserID(userID string) ([]model.VerifLogin, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return userAddresses(r.db, TblEmails, ColEmail, userID)
+}
+
+// SetPrimaryUserEmail makes email userID's primary email in place of the
+// current one.
+func (r *Roach) SetPrimaryUserEmail(userID, email string) error {
+	return r.ExecuteTx(func(tx *sql.Tx) error {
+		return setPrimaryUserAddress(tx, TblEmails, ColEmail, userID, email)
+	})
+}
+
+// DeleteUserEmailAtomic deletes email from userID's non-primary emails using tx.
+func (r *Roach) DeleteUserEmailAtomic(tx *sql.Tx, userID, email string) error {
+	return deleteUserAddress(tx, TblEmails, ColEmail, userID, email)
+}
+
 // InsertEmailToken persists a token for email.
 func (r *Roach) InsertEmailToken(userID, email string, dbt []byte, isUsed bool, expiry time.Time) (*model.DBToken, error) {
 	if err := r.InitDBIfNot(); err != nil {
@@ -57,12 +93,13 @@ func (r *Roach) SetEmailTokenUsedAtomic(tx *sql.Tx, id string) error {
 	return checkRowsAffected(rslt, err, 1)
 }
 
-func (r *Roach) DeleteEmailTokensAtomic(tx *sql.Tx, email string) error {
+// DeleteEmailTokensAtomic deletes userID's tokens sent to email using tx.
+func (r *Roach) DeleteEmailTokensAtomic(tx *sql.Tx, userID, email string) error {
 	if tx == nil {
 		return errors.Newf("tx was nil")
 	}
-	q := `DELETE FROM ` + TblEmailTokens + ` WHERE ` + ColEmail + `=$1`
-	_, err := tx.Exec(q, email)
+	q := `DELETE FROM ` + TblEmailTokens + ` WHERE ` + ColUserID + `=$1 AND ` + ColEmail + `=$2`
+	_, err := tx.Exec(q, userID, email)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.NewNotFound("no email token found")
@@ -107,18 +144,23 @@ func (r *Roach) EmailTokens(userID string, offset, count int64) ([]model.DBToken
 	return dbts, nil
 }
 
-func insertUserEmail(tx inserter, userID, address string, verified bool) (*model.VerifLogin, error) {
+func insertUserEmail(tx inserter, userID, address string, verified, canBePrimary bool) (*model.VerifLogin, error) {
 	if tx == nil || reflect.ValueOf(tx).IsNil() {
 		return nil, errorNilTx
 	}
 	vl := model.VerifLogin{UserID: userID, Address: address, Verified: verified}
-	insCols := ColDesc(ColUserID, ColEmail, ColVerified, ColUpdateDate)
-	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColEmail, ColVerified, ColIsPrimary, ColUpdateDate)
+	retCols := ColDesc(ColID, ColIsPrimary, ColCreateDate, ColUpdateDate)
+	// the first email inserted for a user becomes their primary email unless
+	// canBePrimary is false.
 	q := `
 	INSERT INTO ` + TblEmails + ` (` + insCols + `)
-		VALUES ($1,$2,$3,CURRENT_TIMESTAMP)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,$3,$4 AND NOT EXISTS (
+			SELECT ` + ColID + ` FROM ` + TblEmails + `
+				WHERE ` + ColUserID + `=$1 AND ` + ColIsPrimary + `
+		),CURRENT_TIMESTAMP)
 		RETURNING ` + retCols
-	err := tx.QueryRow(q, userID, address, verified).Scan(&vl.ID, &vl.CreateDate, &vl.UpdateDate)
+	err := tx.QueryRow(q, userID, address, verified, canBePrimary).Scan(&vl.ID, &vl.IsPrimary, &vl.CreateDate, &vl.UpdateDate)
 	if err != nil {
 		return nil, err
 	}
@@ -129,13 +171,13 @@ func updateUserEmail(tx inserter, userID, address string, verified bool) (*model
 	if tx == nil || reflect.ValueOf(tx).IsNil() {
 		return nil, errorNilTx
 	}
-	vl := model.VerifLogin{UserID: userID, Address: address, Verified: verified}
+	vl := model.VerifLogin{UserID: userID, Address: address, Verified: verified, IsPrimary: true}
 	updCols := ColDesc(ColEmail, ColVerified, ColUpdateDate)
 	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
 	q := `
 	UPDATE ` + TblEmails + `
 		SET (` + updCols + `)=($1,$2,CURRENT_TIMESTAMP)
-		WHERE ` + ColUserID + `=$3
+		WHERE ` + ColUserID + `=$3 AND ` + ColIsPrimary + `
 		RETURNING ` + retCols
 	err := tx.QueryRow(q, address, verified, userID).Scan(&vl.ID, &vl.CreateDate, &vl.UpdateDate)
 	if err != nil {
@@ -157,11 +199,11 @@ func insertEmailToken(tx inserter, userID, address string, dbtB []byte, isUsed b
 		Token:   dbtB,
 		IsUsed:  isUsed,
 	}
-	insCols := ColDesc(ColUserID, ColEmail, ColToken, ColIsUsed, ColExpiryDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColEmail, ColToken, ColIsUsed, ColExpiryDate)
 	retCols := ColDesc(ColID, ColIssueDate, ColExpiryDate)
 	q := `
 	INSERT INTO ` + TblEmailTokens + ` (` + insCols + `)
-		VALUES ($1,$2,$3,$4,$5)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,$3,$4,$5)
 		RETURNING ` + retCols
 	err := tx.QueryRow(q, userID, address, dbtB, isUsed, expiry).
 		Scan(&dbt.ID, &dbt.IssueDate, &dbt.ExpiryDate)
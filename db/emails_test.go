@@ -120,6 +120,45 @@ func TestRoach_InsertUserEmail(t *testing.T) {
 	}
 }
 
+func TestRoach_InsertUnverifiedUserEmail(t *testing.T) {
+	conf := setup(t)
+	defer tearDown(t, conf)
+	r := newRoach(t, conf)
+	usr := insertUser(t, r)
+	ret, err := r.InsertUnverifiedUserEmail(usr.ID, "test@mailinator.com")
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if ret.IsPrimary || ret.Verified {
+		t.Errorf("Expected an unverified non-primary email, got %+v", ret)
+	}
+	var vl *model.VerifLogin
+	err = r.ExecuteTx(func(tx *sql.Tx) error {
+		vl, err = r.VerifyUserEmailAtomic(tx, usr.ID, "test@mailinator.com")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Verify email: %v", err)
+	}
+	if !vl.IsPrimary || !vl.Verified {
+		t.Errorf("Expected verified email to become primary, got %+v", vl)
+	}
+	if _, err := r.InsertUserEmail(usr.ID, "test2@mailinator.com", true); err != nil {
+		t.Fatalf("Insert second email: %v", err)
+	}
+	if err := r.SetPrimaryUserEmail(usr.ID, "test2@mailinator.com"); err != nil {
+		t.Fatalf("Set primary email: %v", err)
+	}
+	vls, err := r.UserEmailsByUserID(usr.ID)
+	if err != nil {
+		t.Fatalf("Get emails: %v", err)
+	}
+	if len(vls) != 2 || vls[0].Address != "test2@mailinator.com" ||
+		!vls[0].IsPrimary || vls[1].IsPrimary {
+		t.Errorf("Expected test2@mailinator.com as the only primary, got %+v", vls)
+	}
+}
+
 func TestRoach_UpdateUserEmail(t *testing.T) {
 	conf := setup(t)
 	defer tearDown(t, conf)
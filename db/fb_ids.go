@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
 )
 
 func (r *Roach) InsertUserFbIDAtomic(tx *sql.Tx, userID, fbID string, verified bool) (*model.Facebook, error) {
@@ -11,11 +12,11 @@ func (r *Roach) InsertUserFbIDAtomic(tx *sql.Tx, userID, fbID string, verified b
 		return nil, errorNilTx
 	}
 	fb := model.Facebook{UserID: userID, FacebookID: fbID, Verified: verified}
-	insCols := ColDesc(ColUserID, ColFacebookID, ColVerified, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColFacebookID, ColVerified, ColUpdateDate)
 	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
 	q := `
 	INSERT INTO ` + TblFacebookIDs + ` (` + insCols + `)
-		VALUES ($1,$2,$3,CURRENT_TIMESTAMP)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,$3,CURRENT_TIMESTAMP)
 		RETURNING ` + retCols
 	err := tx.QueryRow(q, userID, fbID, verified).Scan(&fb.ID, &fb.CreateDate, &fb.UpdateDate)
 	if err != nil {
@@ -23,3 +24,19 @@ func (r *Roach) InsertUserFbIDAtomic(tx *sql.Tx, userID, fbID string, verified b
 	}
 	return &fb, nil
 }
+
+// DeleteUserFbID deletes the facebook ID linked to userID.
+func (r *Roach) DeleteUserFbID(userID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblFacebookIDs + ` WHERE ` + ColUserID + `=$1`
+	rslt, err := r.db.Exec(q, userID)
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("facebook ID with userID not found")
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package db
 
 import (
 	"database/sql"
+	"strconv"
 
+	"github.com/lib/pq"
 	"github.com/tomogoma/authms/model"
 	errors "github.com/tomogoma/go-typed-errors"
 )
@@ -13,25 +15,86 @@ func (r *Roach) InsertGroup(name string, acl float32) (*model.Group, error) {
 		return nil, err
 	}
 	grp := model.Group{Name: name, AccessLevel: acl}
-	insCols := ColDesc(ColName, ColAccessLevel, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColName, ColAccessLevel, ColUpdateDate)
 	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
 	q := `
 	INSERT INTO ` + TblGroups + ` (` + insCols + `)
-		VALUES ($1,$2,CURRENT_TIMESTAMP)
+		VALUES ($1,$2,$3,CURRENT_TIMESTAMP)
 		RETURNING ` + retCols
-	err := r.db.QueryRow(q, name, acl).Scan(&grp.ID, &grp.CreateDate, &grp.UpdateDate)
+	err := r.db.QueryRow(q, r.tenantArg(), name, acl).Scan(&grp.ID, &grp.CreateDate, &grp.UpdateDate)
 	if err != nil {
 		return nil, err
 	}
 	return &grp, nil
 }
 
-// Group fetches a group by id.
+// UpdateGroup sets the name and access level of the group having id.
+func (r *Roach) UpdateGroup(id, name string, acl float32) (*model.Group, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	grp := model.Group{ID: id, Name: name, AccessLevel: acl}
+	updCols := ColDesc(ColName, ColAccessLevel, ColUpdateDate)
+	retCols := ColDesc(ColPermissions, ColCreateDate, ColUpdateDate)
+	q := `
+	UPDATE ` + TblGroups + `
+		SET (` + updCols + `)=($1,$2,CURRENT_TIMESTAMP)
+		WHERE ` + ColID + `=$3 AND ` + ColTenantID + `=$4
+		RETURNING ` + retCols
+	err := r.db.QueryRow(q, name, acl, id, r.tenantArg()).
+		Scan(pq.Array(&grp.Permissions), &grp.CreateDate, &grp.UpdateDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("group not found")
+		}
+		return nil, err
+	}
+	return &grp, nil
+}
+
+// SetGroupPermissions replaces the permissions of the group having id with
+// perms.
+func (r *Roach) SetGroupPermissions(id string, perms []string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	updCols := ColDesc(ColPermissions, ColUpdateDate)
+	q := `
+	UPDATE ` + TblGroups + `
+		SET (` + updCols + `)=($1,CURRENT_TIMESTAMP)
+		WHERE ` + ColID + `=$2 AND ` + ColTenantID + `=$3`
+	rslt, err := r.db.Exec(q, pq.Array(perms), id, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("group not found")
+	}
+	return nil
+}
+
+// DeleteGroup deletes the group having id.
+func (r *Roach) DeleteGroup(id string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblGroups + ` WHERE ` + ColID + `=$1 AND ` + ColTenantID + `=$2`
+	rslt, err := r.db.Exec(q, id, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("group not found")
+	}
+	return nil
+}
+
+// Group fetches a group in r's tenant by id.
 func (r *Roach) Group(id string) (*model.Group, error) {
 	return r.groupWhere(ColID+`=$1`, id)
 }
 
-// Group fetches a group by name.
+// GroupByName fetches a group in r's tenant by name.
 func (r *Roach) GroupByName(name string) (*model.Group, error) {
 	return r.groupWhere(ColName+`=$1`, name)
 }
@@ -41,7 +104,8 @@ func (r *Roach) GroupsByUserID(usrID string) ([]model.Group, error) {
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	cols := colDescTbl(TblGroups, ColID, ColName, ColAccessLevel, ColCreateDate, ColUpdateDate)
+	cols := colDescTbl(TblGroups, ColID, ColName, ColAccessLevel, ColPermissions,
+		ColCreateDate, ColUpdateDate)
 	q := `
 		SELECT ` + cols + `
 			FROM ` + TblUsers + `
@@ -58,7 +122,8 @@ func (r *Roach) GroupsByUserID(usrID string) ([]model.Group, error) {
 	var grps []model.Group
 	for rows.Next() {
 		grp := model.Group{}
-		err := rows.Scan(&grp.ID, &grp.Name, &grp.AccessLevel, &grp.CreateDate, &grp.UpdateDate)
+		err := rows.Scan(&grp.ID, &grp.Name, &grp.AccessLevel,
+			pq.Array(&grp.Permissions), &grp.CreateDate, &grp.UpdateDate)
 		if err != nil {
 			return nil, errors.Newf("scan result set row: %v", err)
 		}
@@ -77,13 +142,14 @@ func (r *Roach) Groups(offset, count int64) ([]model.Group, error) {
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	cols := ColDesc(ColID, ColName, ColAccessLevel, ColCreateDate, ColUpdateDate)
+	cols := ColDesc(ColID, ColName, ColAccessLevel, ColPermissions, ColCreateDate, ColUpdateDate)
 	q := `
 		SELECT ` + cols + ` FROM ` + TblGroups + `
+			WHERE ` + ColTenantID + `=$1
 			ORDER BY ` + ColAccessLevel + ` ASC
-			LIMIT $1 OFFSET $2
+			LIMIT $2 OFFSET $3
 	`
-	rows, err := r.db.Query(q, count, offset)
+	rows, err := r.db.Query(q, r.tenantArg(), count, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +157,8 @@ func (r *Roach) Groups(offset, count int64) ([]model.Group, error) {
 	var grps []model.Group
 	for rows.Next() {
 		grp := model.Group{}
-		err := rows.Scan(&grp.ID, &grp.Name, &grp.AccessLevel, &grp.CreateDate, &grp.UpdateDate)
+		err := rows.Scan(&grp.ID, &grp.Name, &grp.AccessLevel,
+			pq.Array(&grp.Permissions), &grp.CreateDate, &grp.UpdateDate)
 		if err != nil {
 			return nil, errors.Newf("scan result set row: %v", err)
 		}
@@ -110,11 +177,14 @@ func (r *Roach) groupWhere(where string, whereArgs ...interface{}) (*model.Group
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	cols := ColDesc(ColID, ColName, ColAccessLevel, ColCreateDate, ColUpdateDate)
-	q := `SELECT ` + cols + ` FROM ` + TblGroups + ` WHERE ` + where
+	cols := ColDesc(ColID, ColName, ColAccessLevel, ColPermissions, ColCreateDate, ColUpdateDate)
+	whereArgs = append(whereArgs, r.tenantArg())
+	q := `SELECT ` + cols + ` FROM ` + TblGroups + `
+		WHERE (` + where + `) AND ` + ColTenantID + `=$` + strconv.Itoa(len(whereArgs))
 	grp := model.Group{}
 	err := r.db.QueryRow(q, whereArgs...).
-		Scan(&grp.ID, &grp.Name, &grp.AccessLevel, &grp.CreateDate, &grp.UpdateDate)
+		Scan(&grp.ID, &grp.Name, &grp.AccessLevel, pq.Array(&grp.Permissions),
+			&grp.CreateDate, &grp.UpdateDate)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.NewNotFound("groups not found")
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// InsertLinkedIdentityAtomic links userID to subject at the OpenID Connect
+// provider issuer using tx.
+func (r *Roach) InsertLinkedIdentityAtomic(tx *sql.Tx, userID, issuer, subject string) (*model.LinkedIdentity, error) {
+	if tx == nil {
+		return nil, errorNilTx
+	}
+	li := model.LinkedIdentity{UserID: userID, Issuer: issuer, Subject: subject}
+	insCols := ColDesc(ColTenantID, ColUserID, ColIssuer, ColSubject, ColUpdateDate)
+	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	q := `
+	INSERT INTO ` + TblLinkedIDs + ` (` + insCols + `)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,$3,CURRENT_TIMESTAMP)
+		RETURNING ` + retCols
+	err := tx.QueryRow(q, userID, issuer, subject).Scan(&li.ID, &li.CreateDate, &li.UpdateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &li, nil
+}
+
+// UserByLinkedIdentity fetches the user linked to subject at the OpenID
+// Connect provider issuer.
+func (r *Roach) UserByLinkedIdentity(issuer, subject string) (*model.User, error) {
+	where := TblUsers + `.` + ColID + ` IN (
+		SELECT ` + ColUserID + ` FROM ` + TblLinkedIDs + `
+			WHERE ` + ColIssuer + `=$1 AND ` + ColSubject + `=$2
+	)`
+	usr, _, err := r.userWhere(where, issuer, subject)
+	return usr, err
+}
+
+// LinkedIdentitiesByUserID fetches the OpenID Connect identities linked to
+// userID.
+func (r *Roach) LinkedIdentitiesByUserID(userID string) ([]model.LinkedIdentity, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColUserID, ColIssuer, ColSubject, ColCreateDate, ColUpdateDate)
+	q := `SELECT ` + cols + ` FROM ` + TblLinkedIDs + ` WHERE ` + ColUserID + `=$1`
+	rows, err := r.db.Query(q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lis []model.LinkedIdentity
+	for rows.Next() {
+		li := model.LinkedIdentity{}
+		err := rows.Scan(&li.ID, &li.UserID, &li.Issuer, &li.Subject, &li.CreateDate, &li.UpdateDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		lis = append(lis, li)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(lis) == 0 {
+		return nil, errors.NewNotFound("no linked identities found for user")
+	}
+	return lis, nil
+}
+
+// DeleteLinkedIdentities unlinks all OpenID Connect identities from userID.
+func (r *Roach) DeleteLinkedIdentities(userID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblLinkedIDs + ` WHERE ` + ColUserID + `=$1`
+	rslt, err := r.db.Exec(q, userID)
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("no linked identities found for user")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// InsertLoginFailureAtomic records a failed login attempt for identifier of
+// loginType from ipAddress using tx.
+func (r *Roach) InsertLoginFailureAtomic(tx *sql.Tx, loginType, identifier, ipAddress string) (*model.LoginFailure, error) {
+	if tx == nil {
+		return nil, errorNilTx
+	}
+	lf := model.LoginFailure{LoginType: loginType, Identifier: identifier, IPAddress: ipAddress}
+	insCols := ColDesc(ColTenantID, ColLoginType, ColIdentifier, ColIPAddress)
+	retCols := ColDesc(ColID, ColCreateDate)
+	q := `
+	INSERT INTO ` + TblLoginFailures + ` (` + insCols + `)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + retCols
+	err := tx.QueryRow(q, r.tenantArg(), loginType, identifier, ipAddress).Scan(&lf.ID, &lf.CreateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &lf, nil
+}
+
+// LoginFailures fetches a maximum of count failed login attempts for
+// identifier of loginType in r's tenant made after since, starting with the
+// newest and skipping the first offset.
+func (r *Roach) LoginFailures(loginType, identifier string, since time.Time, offset, count int64) ([]model.LoginFailure, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return r.loginFailures(r.db, loginType, identifier, since, offset, count)
+}
+
+// LoginFailuresAtomic fetches login failures as LoginFailures() does
+// using tx.
+func (r *Roach) LoginFailuresAtomic(tx *sql.Tx, loginType, identifier string, since time.Time, offset, count int64) ([]model.LoginFailure, error) {
+	return r.loginFailures(tx, loginType, identifier, since, offset, count)
+}
+
+// LoginFailuresByIP fetches a maximum of count failed login attempts in r's
+// tenant made from ipAddress after since, starting with the newest.
+func (r *Roach) LoginFailuresByIP(ipAddress string, since time.Time, count int64) ([]model.LoginFailure, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return r.loginFailuresByIP(r.db, ipAddress, since, count)
+}
+
+// LoginFailuresByIPAtomic fetches login failures as LoginFailuresByIP() does
+// using tx.
+func (r *Roach) LoginFailuresByIPAtomic(tx *sql.Tx, ipAddress string, since time.Time, count int64) ([]model.LoginFailure, error) {
+	return r.loginFailuresByIP(tx, ipAddress, since, count)
+}
+
+// DeleteLoginFailure deletes the failed login attempt with id in r's tenant.
+func (r *Roach) DeleteLoginFailure(id string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblLoginFailures + ` WHERE ` + ColID + `=$1 AND ` + ColTenantID + `=$2`
+	rslt, err := r.db.Exec(q, id, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("login failure not found")
+	}
+	return nil
+}
+
+// DeleteLoginFailures deletes all failed login attempts for identifier of
+// loginType in r's tenant.
+func (r *Roach) DeleteLoginFailures(loginType, identifier string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `
+	DELETE FROM ` + TblLoginFailures + `
+		WHERE ` + ColTenantID + `=$1 AND ` + ColLoginType + `=$2 AND ` + ColIdentifier + `=$3`
+	_, err := r.db.Exec(q, r.tenantArg(), loginType, identifier)
+	return err
+}
+
+// DeleteLoginFailuresByIP deletes all failed login attempts in r's tenant
+// made from ipAddress.
+func (r *Roach) DeleteLoginFailuresByIP(ipAddress string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `
+	DELETE FROM ` + TblLoginFailures + `
+		WHERE ` + ColTenantID + `=$1 AND ` + ColIPAddress + `=$2`
+	_, err := r.db.Exec(q, r.tenantArg(), ipAddress)
+	return err
+}
+
+func (r *Roach) loginFailures(tx querier, loginType, identifier string, since time.Time, offset, count int64) ([]model.LoginFailure, error) {
+	where := ColTenantID + `=$1 AND ` + ColLoginType + `=$2 AND ` + ColIdentifier + `=$3 AND ` + ColCreateDate + `>$4`
+	return queryLoginFailures(tx, where, `$5 OFFSET $6`, r.tenantArg(), loginType, identifier, since, count, offset)
+}
+
+func (r *Roach) loginFailuresByIP(tx querier, ipAddress string, since time.Time, count int64) ([]model.LoginFailure, error) {
+	where := ColTenantID + `=$1 AND ` + ColIPAddress + `=$2 AND ` + ColCreateDate + `>$3`
+	return queryLoginFailures(tx, where, `$4`, r.tenantArg(), ipAddress, since, count)
+}
+
+func queryLoginFailures(tx querier, where, limit string, args ...interface{}) ([]model.LoginFailure, error) {
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return nil, errorNilTx
+	}
+	cols := ColDesc(ColID, ColLoginType, ColIdentifier, ColIPAddress, ColCreateDate)
+	q := `
+	SELECT ` + cols + `
+		FROM ` + TblLoginFailures + `
+		WHERE ` + where + `
+		ORDER BY ` + ColCreateDate + ` DESC
+		LIMIT ` + limit
+	rows, err := tx.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lfs []model.LoginFailure
+	for rows.Next() {
+		lf := model.LoginFailure{}
+		err := rows.Scan(&lf.ID, &lf.LoginType, &lf.Identifier, &lf.IPAddress, &lf.CreateDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		lfs = append(lfs, lf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(lfs) == 0 {
+		return nil, errors.NewNotFound("no login failures found")
+	}
+	return lfs, nil
+}
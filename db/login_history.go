@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// InsertHistory records an access attempt on userID's account.
+func (r *Roach) InsertHistory(userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*model.History, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return insertHistory(r.db, userID, accessType, loginType, ipAddress, userAgent, successful)
+}
+
+// InsertHistoryAtomic records an access attempt on userID's account using tx.
+func (r *Roach) InsertHistoryAtomic(tx *sql.Tx, userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*model.History, error) {
+	return insertHistory(tx, userID, accessType, loginType, ipAddress, userAgent, successful)
+}
+
+// HistoryByUserID fetches access attempts on userID's account starting with
+// the newest.
+func (r *Roach) HistoryByUserID(usrID string, offset, count int64) ([]model.History, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	userID, err := strconv.ParseInt(usrID, 10, 64)
+	if err != nil {
+		return nil, errors.NewNotFound("only numeric IDs stored here")
+	}
+	cols := ColDesc(ColID, ColUserID, ColAccessType, ColLoginType, ColSuccessful,
+		ColIPAddress, ColUserAgent, ColCreateDate)
+	q := `
+	SELECT ` + cols + `
+		FROM ` + TblLoginHistory + `
+		WHERE ` + ColUserID + `=$1
+		ORDER BY ` + ColCreateDate + ` DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(q, userID, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hs []model.History
+	for rows.Next() {
+		h := model.History{}
+		err := rows.Scan(&h.ID, &h.UserID, &h.AccessType, &h.LoginType,
+			&h.Successful, &h.IPAddress, &h.UserAgent, &h.CreateDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		hs = append(hs, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(hs) == 0 {
+		return nil, errors.NewNotFound("no history found for user")
+	}
+	return hs, nil
+}
+
+func insertHistory(tx inserter, userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*model.History, error) {
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return nil, errorNilTx
+	}
+	h := model.History{
+		UserID:     userID,
+		AccessType: accessType,
+		LoginType:  loginType,
+		Successful: successful,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+	}
+	insCols := ColDesc(ColUserID, ColAccessType, ColLoginType, ColSuccessful, ColIPAddress, ColUserAgent)
+	retCols := ColDesc(ColID, ColCreateDate)
+	q := `
+	INSERT INTO ` + TblLoginHistory + ` (` + insCols + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + retCols
+	err := tx.QueryRow(q, userID, accessType, loginType, successful, ipAddress, userAgent).
+		Scan(&h.ID, &h.CreateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
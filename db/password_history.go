@@ -0,0 +1,58 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// InsertPassHistoryAtomic records password (a hash) as one of userID's
+// previous passwords using tx.
+func (r *Roach) InsertPassHistoryAtomic(tx *sql.Tx, userID string, password []byte) error {
+	if tx == nil {
+		return errorNilTx
+	}
+	insCols := ColDesc(ColUserID, ColPassword)
+	q := `INSERT INTO ` + TblPassHistory + ` (` + insCols + `) VALUES ($1, $2)`
+	_, err := tx.Exec(q, userID, password)
+	return err
+}
+
+// PassHistory fetches up to count of userID's previous passwords (hashes)
+// starting with the most recent.
+func (r *Roach) PassHistory(usrID string, count int) ([][]byte, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	userID, err := strconv.ParseInt(usrID, 10, 64)
+	if err != nil {
+		return nil, errors.NewNotFound("only numeric IDs stored here")
+	}
+	q := `
+	SELECT ` + ColPassword + `
+		FROM ` + TblPassHistory + `
+		WHERE ` + ColUserID + `=$1
+		ORDER BY ` + ColCreateDate + ` DESC, ` + ColID + ` DESC
+		LIMIT $2`
+	rows, err := r.db.Query(q, userID, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var passHs [][]byte
+	for rows.Next() {
+		var passH []byte
+		if err := rows.Scan(&passH); err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		passHs = append(passHs, passH)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(passHs) == 0 {
+		return nil, errors.NewNotFound("no password history found for user")
+	}
+	return passHs, nil
+}
@@ -14,12 +14,22 @@ func (r *Roach) InsertUserPhone(userID, phone string, verified bool) (*model.Ver
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	return insertUserPhone(r.db, userID, phone, verified)
+	return insertUserPhone(r.db, userID, phone, verified, true)
 }
 
 // InsertUserPhone inserts phone details for userID.
 func (r *Roach) InsertUserPhoneAtomic(tx *sql.Tx, userID, phone string, verified bool) (*model.VerifLogin, error) {
-	return insertUserPhone(tx, userID, phone, verified)
+	return insertUserPhone(tx, userID, phone, verified, true)
+}
+
+// InsertUnverifiedUserPhone inserts phone for userID as an unverified,
+// non-primary phone. VerifyUserPhoneAtomic() makes it userID's primary phone if
+// they have none by then.
+func (r *Roach) InsertUnverifiedUserPhone(userID, phone string) (*model.VerifLogin, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return insertUserPhone(r.db, userID, phone, false, false)
 }
 
 // UpdateUserPhone updates phone details for userID.
@@ -35,6 +45,32 @@ func (r *Roach) UpdateUserPhoneAtomic(tx *sql.Tx, userID, phone string, verified
 	return updateUserPhone(tx, userID, phone, verified)
 }
 
+// VerifyUserPhoneAtomic marks phone, one of userID's phones, as verified using tx.
+func (r *Roach) VerifyUserPhoneAtomic(tx *sql.Tx, userID, phone string) (*model.VerifLogin, error) {
+	return verifyUserAddress(tx, TblPhones, ColPhone, userID, phone)
+}
+
+// UserPhonesByUserID fetches all of userID's phones starting with the primary.
+func (r *Roach) UserPhonesByUserID(userID string) ([]model.VerifLogin, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return userAddresses(r.db, TblPhones, ColPhone, userID)
+}
+
+// SetPrimaryUserPhone makes phone userID's primary phone in place of the
+// current one.
+func (r *Roach) SetPrimaryUserPhone(userID, phone string) error {
+	return r.ExecuteTx(func(tx *sql.Tx) error {
+		return setPrimaryUserAddress(tx, TblPhones, ColPhone, userID, phone)
+	})
+}
+
+// DeleteUserPhoneAtomic deletes phone from userID's non-primary phones using tx.
+func (r *Roach) DeleteUserPhoneAtomic(tx *sql.Tx, userID, phone string) error {
+	return deleteUserAddress(tx, TblPhones, ColPhone, userID, phone)
+}
+
 // InsertPhoneToken persists a token for phone.
 func (r *Roach) InsertPhoneToken(userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*model.DBToken, error) {
 	if err := r.InitDBIfNot(); err != nil {
@@ -57,12 +93,13 @@ func (r *Roach) SetPhoneTokenUsedAtomic(tx *sql.Tx, id string) error {
 	return checkRowsAffected(rslt, err, 1)
 }
 
-func (r *Roach) DeletePhoneTokensAtomic(tx *sql.Tx, phone string) error {
+// DeletePhoneTokensAtomic deletes userID's tokens sent to phone using tx.
+func (r *Roach) DeletePhoneTokensAtomic(tx *sql.Tx, userID, phone string) error {
 	if tx == nil {
 		return errors.Newf("tx was nil")
 	}
-	q := `DELETE FROM ` + TblPhoneTokens + ` WHERE ` + ColPhone + `=$1`
-	_, err := tx.Exec(q, phone)
+	q := `DELETE FROM ` + TblPhoneTokens + ` WHERE ` + ColUserID + `=$1 AND ` + ColPhone + `=$2`
+	_, err := tx.Exec(q, userID, phone)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.NewNotFound("no phone token found")
@@ -107,18 +144,23 @@ func (r *Roach) PhoneTokens(userID string, offset, count int64) ([]model.DBToken
 	return dbts, nil
 }
 
-func insertUserPhone(tx inserter, userID, phone string, verified bool) (*model.VerifLogin, error) {
+func insertUserPhone(tx inserter, userID, phone string, verified, canBePrimary bool) (*model.VerifLogin, error) {
 	if tx == nil || reflect.ValueOf(tx).IsNil() {
 		return nil, errorNilTx
 	}
 	vl := model.VerifLogin{UserID: userID, Address: phone, Verified: verified}
-	insCols := ColDesc(ColUserID, ColPhone, ColVerified, ColUpdateDate)
-	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColPhone, ColVerified, ColIsPrimary, ColUpdateDate)
+	retCols := ColDesc(ColID, ColIsPrimary, ColCreateDate, ColUpdateDate)
+	// the first phone inserted for a user becomes their primary phone unless
+	// canBePrimary is false.
 	q := `
 	INSERT INTO ` + TblPhones + ` (` + insCols + `)
-		VALUES ($1,$2,$3,CURRENT_TIMESTAMP)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,$3,$4 AND NOT EXISTS (
+			SELECT ` + ColID + ` FROM ` + TblPhones + `
+				WHERE ` + ColUserID + `=$1 AND ` + ColIsPrimary + `
+		),CURRENT_TIMESTAMP)
 		RETURNING ` + retCols
-	err := tx.QueryRow(q, userID, phone, verified).Scan(&vl.ID, &vl.CreateDate, &vl.UpdateDate)
+	err := tx.QueryRow(q, userID, phone, verified, canBePrimary).Scan(&vl.ID, &vl.IsPrimary, &vl.CreateDate, &vl.UpdateDate)
 	if err != nil {
 		return nil, err
 	}
@@ -129,13 +171,13 @@ func updateUserPhone(tx inserter, userID, phone string, verified bool) (*model.V
 	if tx == nil || reflect.ValueOf(tx).IsNil() {
 		return nil, errorNilTx
 	}
-	vl := model.VerifLogin{UserID: userID, Address: phone, Verified: verified}
+	vl := model.VerifLogin{UserID: userID, Address: phone, Verified: verified, IsPrimary: true}
 	updCols := ColDesc(ColPhone, ColVerified, ColUpdateDate)
 	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
 	q := `
 	UPDATE ` + TblPhones + `
 		SET (` + updCols + `)=($1,$2,CURRENT_TIMESTAMP)
-		WHERE ` + ColUserID + `=$3
+		WHERE ` + ColUserID + `=$3 AND ` + ColIsPrimary + `
 		RETURNING ` + retCols
 	err := tx.QueryRow(q, phone, verified, userID).Scan(&vl.ID, &vl.CreateDate, &vl.UpdateDate)
 	if err != nil {
@@ -157,11 +199,11 @@ func insertPhoneToken(tx inserter, userID, phone string, dbtB []byte, isUsed boo
 		Token:   dbtB,
 		IsUsed:  isUsed,
 	}
-	insCols := ColDesc(ColUserID, ColPhone, ColToken, ColIsUsed, ColExpiryDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColPhone, ColToken, ColIsUsed, ColExpiryDate)
 	retCols := ColDesc(ColID, ColIssueDate, ColExpiryDate)
 	q := `
 	INSERT INTO ` + TblPhoneTokens + ` (` + insCols + `)
-		VALUES ($1,$2,$3,$4,$5)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,$3,$4,$5)
 		RETURNING ` + retCols
 	err := tx.QueryRow(q, userID, phone, dbtB, isUsed, expiry).
 		Scan(&dbt.ID, &dbt.IssueDate, &dbt.ExpiryDate)
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// InsertRefreshToken persists a refresh token issued to userID through the
+// client app owning apiKeyID.
+func (r *Roach) InsertRefreshToken(userID, apiKeyID, familyID string, tkn []byte, expiry time.Time) (*model.RefreshToken, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	return insertRefreshToken(r.db, userID, apiKeyID, familyID, tkn, expiry)
+}
+
+// InsertRefreshTokenAtomic persists a refresh token issued to userID through
+// the client app owning apiKeyID using tx.
+func (r *Roach) InsertRefreshTokenAtomic(tx *sql.Tx, userID, apiKeyID, familyID string, tkn []byte, expiry time.Time) (*model.RefreshToken, error) {
+	return insertRefreshToken(tx, userID, apiKeyID, familyID, tkn, expiry)
+}
+
+// RefreshToken fetches the refresh token with id.
+func (r *Roach) RefreshToken(id string) (*model.RefreshToken, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColUserID, ColAPIKeyID, ColFamilyID, ColToken,
+		ColIsUsed, ColIsRevoked, ColIssueDate, ColExpiryDate)
+	q := `SELECT ` + cols + ` FROM ` + TblRefreshTokens + ` WHERE ` + ColID + `=$1`
+	rt := model.RefreshToken{}
+	err := r.db.QueryRow(q, id).Scan(&rt.ID, &rt.UserID, &rt.APIKeyID,
+		&rt.FamilyID, &rt.Token, &rt.IsUsed, &rt.IsRevoked, &rt.IssueDate,
+		&rt.ExpiryDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("refresh token not found")
+		}
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// SetRefreshTokenUsedAtomic marks the refresh token with id as used using tx.
+// It returns a NotFound error if the token is not found or has already been
+// used or revoked so that concurrent exchanges of the same token cannot both
+// succeed.
+func (r *Roach) SetRefreshTokenUsedAtomic(tx *sql.Tx, id string) error {
+	if tx == nil {
+		return errorNilTx
+	}
+	q := `
+	UPDATE ` + TblRefreshTokens + `
+		SET ` + ColIsUsed + ` = $1
+		WHERE ` + ColID + ` = $2 AND ` + ColIsUsed + ` = $3 AND ` + ColIsRevoked + ` = $3`
+	rslt, err := tx.Exec(q, true, id, false)
+	if err != nil {
+		return err
+	}
+	c, err := rslt.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if c == 0 {
+		return errors.NewNotFound("unused refresh token not found")
+	}
+	return nil
+}
+
+// RevokeRefreshTokenFamily revokes all refresh tokens belonging to familyID.
+func (r *Roach) RevokeRefreshTokenFamily(familyID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `UPDATE ` + TblRefreshTokens + ` SET ` + ColIsRevoked + ` = $1 WHERE ` + ColFamilyID + ` = $2`
+	_, err := r.db.Exec(q, true, familyID)
+	return err
+}
+
+// RefreshTokensByUserID fetches refresh tokens issued to userID starting with
+// the newest. The token values are not fetched.
+func (r *Roach) RefreshTokensByUserID(usrID string, offset, count int64) ([]model.RefreshToken, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	userID, err := strconv.ParseInt(usrID, 10, 64)
+	if err != nil {
+		return nil, errors.NewNotFound("only numeric IDs stored here")
+	}
+	cols := ColDesc(ColID, ColUserID, ColAPIKeyID, ColFamilyID, ColIsUsed,
+		ColIsRevoked, ColIssueDate, ColExpiryDate)
+	q := `
+	SELECT ` + cols + `
+		FROM ` + TblRefreshTokens + `
+		WHERE ` + ColUserID + `=$1
+		ORDER BY ` + ColIssueDate + ` DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(q, userID, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rts []model.RefreshToken
+	for rows.Next() {
+		rt := model.RefreshToken{}
+		err := rows.Scan(&rt.ID, &rt.UserID, &rt.APIKeyID, &rt.FamilyID,
+			&rt.IsUsed, &rt.IsRevoked, &rt.IssueDate, &rt.ExpiryDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		rts = append(rts, rt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(rts) == 0 {
+		return nil, errors.NewNotFound("no refresh tokens found for user")
+	}
+	return rts, nil
+}
+
+func insertRefreshToken(tx inserter, userID, apiKeyID, familyID string, tknB []byte, expiry time.Time) (*model.RefreshToken, error) {
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return nil, errorNilTx
+	}
+	rt := model.RefreshToken{
+		UserID:   userID,
+		APIKeyID: apiKeyID,
+		FamilyID: familyID,
+		Token:    tknB,
+	}
+	insCols := ColDesc(ColUserID, ColAPIKeyID, ColFamilyID, ColToken, ColIsRevoked, ColExpiryDate)
+	retCols := ColDesc(ColID, ColIssueDate, ColExpiryDate)
+	q := `
+	INSERT INTO ` + TblRefreshTokens + ` (` + insCols + `)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		RETURNING ` + retCols
+	err := tx.QueryRow(q, userID, apiKeyID, familyID, tknB, false, expiry).
+		Scan(&rt.ID, &rt.IssueDate, &rt.ExpiryDate)
+	if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// InsertRevokedToken adds the JWT identified by tokenID (the jti claim) and
+// issued to userID to the deny-list until expiry. Entries whose expiry has
+// passed are pruned in the process. Revoking an already revoked token is
+// not an error.
+func (r *Roach) InsertRevokedToken(tokenID, userID string, expiry time.Time) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	return r.ExecuteTx(func(tx *sql.Tx) error {
+		q := `DELETE FROM ` + TblRevokedTokens + ` WHERE ` + ColExpiryDate + `<$1`
+		if _, err := tx.Exec(q, time.Now()); err != nil {
+			return err
+		}
+		insCols := ColDesc(ColTokenID, ColUserID, ColExpiryDate)
+		q = `
+		INSERT INTO ` + TblRevokedTokens + ` (` + insCols + `)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (` + ColTokenID + `) DO NOTHING`
+		_, err := tx.Exec(q, tokenID, userID, expiry)
+		return err
+	})
+}
+
+// IsTokenRevoked returns true if the JWT identified by tokenID is in the
+// deny-list.
+func (r *Roach) IsTokenRevoked(tokenID string) (bool, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return false, err
+	}
+	q := `SELECT EXISTS (SELECT 1 FROM ` + TblRevokedTokens + ` WHERE ` + ColTokenID + `=$1)`
+	var revoked bool
+	if err := r.db.QueryRow(q, tokenID).Scan(&revoked); err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/cockroachdb/cockroach-go/crdb"
 	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/model"
 	cockroach "github.com/tomogoma/crdb"
 	errors "github.com/tomogoma/go-typed-errors"
 )
@@ -18,6 +19,15 @@ import (
 // Use NewRoach() to instantiate.
 type Roach struct {
 	errors.NotFoundErrCheck
+	*roachDB
+	// tenantID scopes users, groups and the values unique to them to a
+	// tenant. It is empty for the default tenant.
+	tenantID string
+}
+
+// roachDB is the connection state shared by a Roach and its tenant scoped
+// copies.
+type roachDB struct {
 	dsn              string
 	dbName           string
 	db               *sql.DB
@@ -36,9 +46,14 @@ type inserter interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
 const (
-	keyDBVersion = "db.version"
-	keySMTPConf  = "conf.smtp"
+	keyDBVersion  = "db.version"
+	keySMTPConf   = "conf.smtp"
+	keyAttrSchema = "conf.attributeSchema."
 )
 
 var errorNilTx = errors.Newf("sql Tx was nil")
@@ -47,9 +62,11 @@ var errorNilTx = errors.Newf("sql Tx was nil")
 // when InitDBIfNot() or one of the Execute/Query methods is called.
 func NewRoach(opts ...Option) *Roach {
 	r := &Roach{
-		isDBInit:      false,
-		isDBInitMutex: sync.Mutex{},
-		dbName:        config.CanonicalName(),
+		roachDB: &roachDB{
+			isDBInit:      false,
+			isDBInitMutex: sync.Mutex{},
+			dbName:        config.CanonicalName(),
+		},
 	}
 	for _, f := range opts {
 		f(r)
@@ -57,6 +74,12 @@ func NewRoach(opts ...Option) *Roach {
 	return r
 }
 
+// ForTenant returns a copy of r scoped to the tenant having tenantID. The
+// copy shares r's connection.
+func (r *Roach) ForTenant(tenantID string) model.AuthStore {
+	return &Roach{roachDB: r.roachDB, tenantID: tenantID}
+}
+
 // InitDBIfNot connects to and sets up the DB; creating it and tables if necessary.
 func (r *Roach) InitDBIfNot() error {
 	var err error
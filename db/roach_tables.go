@@ -2,10 +2,11 @@ package db
 
 const (
 	// Database definition version
-	Version = 1
+	Version = 11
 
 	// Table names
 	TblConfigurations = "configurations"
+	TblTenants        = "tenants"
 	TblUserTypes      = "userTypes"
 	TblGroups         = "groups"
 	TblUsers          = "users"
@@ -18,6 +19,13 @@ const (
 	TblPhoneTokens    = "phoneTokens"
 	TblFacebookIDs    = "facebookIDs"
 	TblRefreshTokens  = "refreshTokens"
+	TblLoginFailures  = "loginFailures"
+	TblLoginHistory   = "loginHistory"
+	TblTOTPSecrets    = "totpSecrets"
+	TblLinkedIDs      = "linkedIdentities"
+	TblRevokedTokens  = "revokedTokens"
+	TblSessions       = "sessions"
+	TblPassHistory    = "passwordHistory"
 
 	// DB Table Columns
 	ColID          = "ID"
@@ -43,6 +51,30 @@ const (
 	ColDevID       = "deviceID"
 	ColIsRevoked   = "isRevoked"
 	ColAPIKeyID    = "apiKeyID"
+	ColFamilyID    = "familyID"
+	ColLoginType   = "loginType"
+	ColIdentifier  = "identifier"
+	ColIPAddress   = "ipAddress"
+	ColAccessType  = "accessType"
+	ColSuccessful  = "successful"
+	ColUserAgent   = "userAgent"
+	ColSecret      = "secret"
+	ColIsConfirmed = "isConfirmed"
+	ColLastStep    = "lastStep"
+	ColIssuer      = "issuer"
+	ColSubject     = "subject"
+	ColTokenID     = "tokenID"
+	ColLastSeen    = "lastSeen"
+	ColStatus      = "status"
+	ColStatusRsn   = "statusReason"
+	ColStatusUntil = "statusUntil"
+	ColIsPrimary   = "isPrimary"
+	ColPermissions = "permissions"
+	ColTenantID    = "tenantID"
+	ColSelfReg     = "selfRegistrable"
+	ColReqLgnTypes = "requiredLoginTypes"
+	ColAttributes  = "attributes"
+	ColLgdOutAll   = "loggedOutAll"
 
 	// CREATE TABLE DESCRIPTIONS
 	TblDescConfigurations = `
@@ -55,6 +87,18 @@ const (
 	`
 	TblDescUserTypes = `
 	CREATE TABLE IF NOT EXISTS ` + TblUserTypes + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColName + ` VARCHAR(56) NOT NULL CHECK (` + ColName + ` != ''),
+		` + ColSelfReg + ` BOOL NOT NULL DEFAULT TRUE,
+		` + ColReqLgnTypes + ` STRING[] NOT NULL DEFAULT ARRAY[]:::STRING[],
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColName + `)
+	);
+	`
+	TblDescTenants = `
+	CREATE TABLE IF NOT EXISTS ` + TblTenants + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
 		` + ColName + ` VARCHAR(56) UNIQUE NOT NULL CHECK (` + ColName + ` != ''),
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -64,18 +108,27 @@ const (
 	TblDescGroups = `
 	CREATE TABLE IF NOT EXISTS ` + TblGroups + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
-		` + ColName + ` VARCHAR(56) UNIQUE NOT NULL CHECK (` + ColName + ` != ''),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColName + ` VARCHAR(56) NOT NULL CHECK (` + ColName + ` != ''),
 		` + ColAccessLevel + ` FLOAT NOT NULL CHECK (` + ColAccessLevel + ` BETWEEN 0 AND 10),
+		` + ColPermissions + ` STRING[] NOT NULL DEFAULT ARRAY[]:::STRING[],
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColName + `)
 	);
 	`
 	TblDescUsers = `
 	CREATE TABLE IF NOT EXISTS ` + TblUsers + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
 		` + ColTypeID + ` BIGINT NOT NULL REFERENCES ` + TblUserTypes + ` (` + ColID + `),
 		` + ColGroupID + ` BIGINT NOT NULL REFERENCES ` + TblGroups + ` (` + ColID + `),
 		` + ColPassword + ` BYTEA NOT NULL CHECK ( LENGTH(` + ColPassword + `) >= 8 ),
+		` + ColStatus + ` VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (` + ColStatus + ` IN ('active', 'suspended', 'pending')),
+		` + ColStatusRsn + ` VARCHAR(256) NOT NULL DEFAULT '',
+		` + ColStatusUntil + ` TIMESTAMPTZ,
+		` + ColAttributes + ` JSONB NOT NULL DEFAULT '{}',
+		` + ColLgdOutAll + ` TIMESTAMPTZ,
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
 	);
@@ -83,6 +136,7 @@ const (
 	TblDescAPIKeys = `
 	CREATE TABLE IF NOT EXISTS ` + TblAPIKeys + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
 		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
 		` + ColKey + ` VARCHAR(256) NOT NULL CHECK ( LENGTH(` + ColKey + `) >= 56 ),
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -101,62 +155,86 @@ const (
 	TblDescUserNames = `
 	CREATE TABLE IF NOT EXISTS ` + TblUserNames + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
-		` + ColUserName + ` VARCHAR(56) UNIQUE NOT NULL,
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColUserName + ` VARCHAR(56) NOT NULL,
 		` + ColUserID + ` BIGINT UNIQUE NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColUserName + `)
 	);
 	`
 	TblDescEmails = `
 	CREATE TABLE IF NOT EXISTS ` + TblEmails + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
-		` + ColEmail + ` VARCHAR(128) UNIQUE NOT NULL CHECK (` + ColEmail + ` != ''),
-		` + ColUserID + ` BIGINT UNIQUE NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColEmail + ` VARCHAR(128) NOT NULL CHECK (` + ColEmail + ` != ''),
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
 		` + ColVerified + ` BOOL NOT NULL DEFAULT FALSE,
+		` + ColIsPrimary + ` BOOL NOT NULL DEFAULT FALSE,
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColEmail + `)
 	);
 	`
+	IdxDescEmailsPrimary = `
+	CREATE UNIQUE INDEX IF NOT EXISTS ` + TblEmails + `_primary_idx
+		ON ` + TblEmails + ` (` + ColUserID + `) WHERE ` + ColIsPrimary + `;
+	`
 	TblDescEmailTokens = `
 	CREATE TABLE IF NOT EXISTS ` + TblEmailTokens + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
 		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
-		` + ColEmail + ` VARCHAR(128) NOT NULL REFERENCES ` + TblEmails + ` (` + ColEmail + `),
+		` + ColEmail + ` VARCHAR(128) NOT NULL,
 		` + ColToken + ` BYTEA NOT NULL CHECK (LENGTH(` + ColToken + `)>0),
 		` + ColIsUsed + ` BOOL NOT NULL,
 		` + ColIssueDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColExpiryDate + ` TIMESTAMPTZ NOT NULL
+		` + ColExpiryDate + ` TIMESTAMPTZ NOT NULL,
+		FOREIGN KEY (` + ColTenantID + `, ` + ColEmail + `)
+			REFERENCES ` + TblEmails + ` (` + ColTenantID + `, ` + ColEmail + `)
 	);
 	`
 	TblDescPhones = `
 	CREATE TABLE IF NOT EXISTS ` + TblPhones + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
-		` + ColPhone + ` VARCHAR(56) UNIQUE NOT NULL CHECK (` + ColPhone + ` != ''),
-		` + ColUserID + ` BIGINT UNIQUE NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColPhone + ` VARCHAR(56) NOT NULL CHECK (` + ColPhone + ` != ''),
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
 		` + ColVerified + ` BOOL NOT NULL DEFAULT FALSE,
+		` + ColIsPrimary + ` BOOL NOT NULL DEFAULT FALSE,
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColPhone + `)
 	);
 	`
+	IdxDescPhonesPrimary = `
+	CREATE UNIQUE INDEX IF NOT EXISTS ` + TblPhones + `_primary_idx
+		ON ` + TblPhones + ` (` + ColUserID + `) WHERE ` + ColIsPrimary + `;
+	`
 	TblDescPhoneTokens = `
 	CREATE TABLE IF NOT EXISTS ` + TblPhoneTokens + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
 		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
-		` + ColPhone + ` VARCHAR(56) NOT NULL REFERENCES ` + TblPhones + ` (` + ColPhone + `),
+		` + ColPhone + ` VARCHAR(56) NOT NULL,
 		` + ColToken + ` BYTEA NOT NULL CHECK (LENGTH(` + ColToken + `)>0),
 		` + ColIsUsed + ` BOOL NOT NULL,
 		` + ColIssueDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColExpiryDate + ` TIMESTAMPTZ NOT NULL
+		` + ColExpiryDate + ` TIMESTAMPTZ NOT NULL,
+		FOREIGN KEY (` + ColTenantID + `, ` + ColPhone + `)
+			REFERENCES ` + TblPhones + ` (` + ColTenantID + `, ` + ColPhone + `)
 	);
 	`
 	TblDescFacebookIDs = `
 	CREATE TABLE IF NOT EXISTS ` + TblFacebookIDs + ` (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
-		` + ColFacebookID + ` VARCHAR(512) UNIQUE NOT NULL CHECK(` + ColFacebookID + ` != ''),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColFacebookID + ` VARCHAR(512) NOT NULL CHECK(` + ColFacebookID + ` != ''),
 		` + ColUserID + ` BIGINT UNIQUE NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
 		` + ColVerified + ` BOOL NOT NULL DEFAULT FALSE,
 		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColFacebookID + `)
 	);
 	`
 	TblDescRefreshTokens = `
@@ -164,30 +242,118 @@ const (
 		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
 		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
 		` + ColAPIKeyID + ` BIGINT NOT NULL REFERENCES ` + TblAPIKeys + ` (` + ColID + `),
-		` + ColToken + ` BYTEA NOT NULL,
+		` + ColFamilyID + ` VARCHAR(56) NOT NULL CHECK (` + ColFamilyID + ` != ''),
+		` + ColToken + ` BYTEA NOT NULL CHECK (LENGTH(` + ColToken + `)>0),
+		` + ColIsUsed + ` BOOL NOT NULL DEFAULT FALSE,
 		` + ColIsRevoked + ` BOOL NOT NULL,
 		` + ColIssueDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		` + ColExpiryDate + ` TIMESTAMPTZ NOT NULL
 	);
 	`
+	TblDescLoginFailures = `
+	CREATE TABLE IF NOT EXISTS ` + TblLoginFailures + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColLoginType + ` VARCHAR(56) NOT NULL,
+		` + ColIdentifier + ` VARCHAR(512) NOT NULL,
+		` + ColIPAddress + ` VARCHAR(56) NOT NULL,
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	TblDescLoginHistory = `
+	CREATE TABLE IF NOT EXISTS ` + TblLoginHistory + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColAccessType + ` VARCHAR(56) NOT NULL CHECK (` + ColAccessType + ` != ''),
+		` + ColLoginType + ` VARCHAR(56) NOT NULL,
+		` + ColSuccessful + ` BOOL NOT NULL,
+		` + ColIPAddress + ` VARCHAR(56) NOT NULL,
+		` + ColUserAgent + ` VARCHAR(512) NOT NULL,
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	TblDescTOTPSecrets = `
+	CREATE TABLE IF NOT EXISTS ` + TblTOTPSecrets + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColUserID + ` BIGINT UNIQUE NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColSecret + ` BYTEA NOT NULL CHECK (LENGTH(` + ColSecret + `)>0),
+		` + ColIsConfirmed + ` BOOL NOT NULL DEFAULT FALSE,
+		` + ColLastStep + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL
+	);
+	`
+	TblDescLinkedIDs = `
+	CREATE TABLE IF NOT EXISTS ` + TblLinkedIDs + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTenantID + ` BIGINT NOT NULL DEFAULT 0,
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColIssuer + ` VARCHAR(512) NOT NULL CHECK (` + ColIssuer + ` != ''),
+		` + ColSubject + ` VARCHAR(256) NOT NULL CHECK (` + ColSubject + ` != ''),
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		` + ColUpdateDate + ` TIMESTAMPTZ NOT NULL,
+		UNIQUE (` + ColTenantID + `, ` + ColIssuer + `, ` + ColSubject + `)
+	);
+	`
+	TblDescRevokedTokens = `
+	CREATE TABLE IF NOT EXISTS ` + TblRevokedTokens + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColTokenID + ` VARCHAR(56) UNIQUE NOT NULL CHECK (` + ColTokenID + ` != ''),
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColExpiryDate + ` TIMESTAMPTZ NOT NULL,
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	TblDescSessions = `
+	CREATE TABLE IF NOT EXISTS ` + TblSessions + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColFamilyID + ` VARCHAR(56) NOT NULL,
+		` + ColDevID + ` VARCHAR(256) NOT NULL,
+		` + ColIPAddress + ` VARCHAR(56) NOT NULL,
+		` + ColUserAgent + ` VARCHAR(512) NOT NULL,
+		` + ColIsRevoked + ` BOOL NOT NULL DEFAULT FALSE,
+		` + ColLastSeen + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	TblDescPassHistory = `
+	CREATE TABLE IF NOT EXISTS ` + TblPassHistory + ` (
+		` + ColID + ` BIGSERIAL PRIMARY KEY NOT NULL CHECK (` + ColID + `>0),
+		` + ColUserID + ` BIGINT NOT NULL REFERENCES ` + TblUsers + ` (` + ColID + `),
+		` + ColPassword + ` BYTEA NOT NULL CHECK ( LENGTH(` + ColPassword + `) >= 8 ),
+		` + ColCreateDate + ` TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`
 )
 
 // AllTableDescs lists all CREATE TABLE DESCRIPTIONS in order of dependency
 // (tables with foreign key references listed after parent table descriptions).
+// Index descriptions follow the table they index.
 var AllTableDescs = []string{
 	TblDescConfigurations,
 	TblDescUserTypes,
+	TblDescTenants,
 	TblDescGroups,
 	TblDescUsers,
 	TblDescAPIKeys,
 	TblDescDeviceIDs,
 	TblDescUserNames,
 	TblDescEmails,
+	IdxDescEmailsPrimary,
 	TblDescEmailTokens,
 	TblDescPhones,
+	IdxDescPhonesPrimary,
 	TblDescPhoneTokens,
 	TblDescFacebookIDs,
 	TblDescRefreshTokens,
+	TblDescLoginFailures,
+	TblDescLoginHistory,
+	TblDescTOTPSecrets,
+	TblDescLinkedIDs,
+	TblDescRevokedTokens,
+	TblDescSessions,
+	TblDescPassHistory,
 }
 
 // AllTableNames lists all table names in order of dependency
@@ -195,6 +361,7 @@ var AllTableDescs = []string{
 var AllTableNames = []string{
 	TblConfigurations,
 	TblUserTypes,
+	TblTenants,
 	TblGroups,
 	TblUsers,
 	TblAPIKeys,
@@ -206,4 +373,11 @@ var AllTableNames = []string{
 	TblPhoneTokens,
 	TblFacebookIDs,
 	TblRefreshTokens,
+	TblLoginFailures,
+	TblLoginHistory,
+	TblTOTPSecrets,
+	TblLinkedIDs,
+	TblRevokedTokens,
+	TblSessions,
+	TblPassHistory,
 }
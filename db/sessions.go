@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// InsertSession records a successful login to userID's account. familyID is
+// the ID of the refresh token family issued during the login if any.
+func (r *Roach) InsertSession(userID, familyID, deviceID, ipAddress, userAgent string) (*model.Session, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	s := model.Session{
+		UserID:    userID,
+		FamilyID:  familyID,
+		DeviceID:  deviceID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	insCols := ColDesc(ColUserID, ColFamilyID, ColDevID, ColIPAddress, ColUserAgent)
+	retCols := ColDesc(ColID, ColIsRevoked, ColLastSeen, ColCreateDate)
+	q := `
+	INSERT INTO ` + TblSessions + ` (` + insCols + `)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + retCols
+	err := r.db.QueryRow(q, userID, familyID, deviceID, ipAddress, userAgent).
+		Scan(&s.ID, &s.IsRevoked, &s.LastSeen, &s.CreateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Session fetches the session with id.
+func (r *Roach) Session(id string) (*model.Session, error) {
+	return r.session(ColID+`=$1`, id)
+}
+
+// SessionByFamilyID fetches the session during which the refresh token
+// family familyID was issued.
+func (r *Roach) SessionByFamilyID(familyID string) (*model.Session, error) {
+	return r.session(ColFamilyID+`=$1`, familyID)
+}
+
+// SessionsByUserID fetches sessions on userID's account starting with the
+// most recently seen.
+func (r *Roach) SessionsByUserID(usrID string, offset, count int64) ([]model.Session, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	userID, err := strconv.ParseInt(usrID, 10, 64)
+	if err != nil {
+		return nil, errors.NewNotFound("only numeric IDs stored here")
+	}
+	q := `
+	SELECT ` + sessionCols + `
+		FROM ` + TblSessions + `
+		WHERE ` + ColUserID + `=$1
+		ORDER BY ` + ColLastSeen + ` DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(q, userID, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ss []model.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		ss = append(ss, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(ss) == 0 {
+		return nil, errors.NewNotFound("no sessions found for user")
+	}
+	return ss, nil
+}
+
+// SetSessionLastSeen updates the time the session with id was last used.
+func (r *Roach) SetSessionLastSeen(id string, lastSeen time.Time) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `UPDATE ` + TblSessions + ` SET ` + ColLastSeen + `=$1 WHERE ` + ColID + `=$2`
+	rslt, err := r.db.Exec(q, lastSeen, id)
+	return checkRowsAffected(rslt, err, 1)
+}
+
+// RevokeSession revokes the session with id belonging to userID.
+func (r *Roach) RevokeSession(userID, id string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `UPDATE ` + TblSessions + ` SET ` + ColIsRevoked + `=$1
+		WHERE ` + ColUserID + `=$2 AND ` + ColID + `=$3`
+	rslt, err := r.db.Exec(q, true, userID, id)
+	if err != nil {
+		return err
+	}
+	c, err := rslt.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if c == 0 {
+		return errors.NewNotFound("session not found")
+	}
+	return nil
+}
+
+// RevokeSessions revokes all sessions belonging to userID.
+func (r *Roach) RevokeSessions(userID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `UPDATE ` + TblSessions + ` SET ` + ColIsRevoked + `=$1 WHERE ` + ColUserID + `=$2`
+	_, err := r.db.Exec(q, true, userID)
+	return err
+}
+
+var sessionCols = ColDesc(ColID, ColUserID, ColFamilyID, ColDevID, ColIPAddress,
+	ColUserAgent, ColIsRevoked, ColLastSeen, ColCreateDate)
+
+func (r *Roach) session(where string, args ...interface{}) (*model.Session, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	q := `SELECT ` + sessionCols + ` FROM ` + TblSessions + ` WHERE ` + where
+	s, err := scanSession(r.db.QueryRow(q, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("session not found")
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func scanSession(row scanner) (*model.Session, error) {
+	s := model.Session{}
+	err := row.Scan(&s.ID, &s.UserID, &s.FamilyID, &s.DeviceID, &s.IPAddress,
+		&s.UserAgent, &s.IsRevoked, &s.LastSeen, &s.CreateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
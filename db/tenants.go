@@ -0,0 +1,127 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// defaultTenantID is the value stored in ColTenantID for the default
+// tenant, which has no row in TblTenants.
+const defaultTenantID = "0"
+
+// InsertTenant inserts into the database returning calculated values.
+func (r *Roach) InsertTenant(name string) (*model.Tenant, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	t := model.Tenant{Name: name}
+	insCols := ColDesc(ColName, ColUpdateDate)
+	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	q := `
+	INSERT INTO ` + TblTenants + ` (` + insCols + `)
+		VALUES ($1,CURRENT_TIMESTAMP)
+		RETURNING ` + retCols
+	err := r.db.QueryRow(q, name).Scan(&t.ID, &t.CreateDate, &t.UpdateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Tenant fetches a tenant by id.
+func (r *Roach) Tenant(id string) (*model.Tenant, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColName, ColCreateDate, ColUpdateDate)
+	q := `SELECT ` + cols + ` FROM ` + TblTenants + ` WHERE ` + ColID + `=$1`
+	t := model.Tenant{}
+	err := r.db.QueryRow(q, id).Scan(&t.ID, &t.Name, &t.CreateDate, &t.UpdateDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("tenant not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// TenantByName fetches a tenant by name.
+func (r *Roach) TenantByName(name string) (*model.Tenant, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColName, ColCreateDate, ColUpdateDate)
+	q := `SELECT ` + cols + ` FROM ` + TblTenants + ` WHERE ` + ColName + `=$1`
+	t := model.Tenant{}
+	err := r.db.QueryRow(q, name).Scan(&t.ID, &t.Name, &t.CreateDate, &t.UpdateDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("tenant not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Tenants fetches tenants ordered by name.
+func (r *Roach) Tenants(offset, count int64) ([]model.Tenant, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColName, ColCreateDate, ColUpdateDate)
+	q := `
+		SELECT ` + cols + ` FROM ` + TblTenants + `
+			ORDER BY ` + ColName + ` ASC
+			LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.Query(q, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ts []model.Tenant
+	for rows.Next() {
+		t := model.Tenant{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreateDate, &t.UpdateDate); err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		ts = append(ts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(ts) == 0 {
+		return nil, errors.NewNotFound("no tenants found")
+	}
+	return ts, nil
+}
+
+// tenantArg returns the value stored in ColTenantID for r's tenant.
+func (r *Roach) tenantArg() string {
+	return tenantArg(r.tenantID)
+}
+
+func tenantArg(tenantID string) string {
+	if tenantID == "" {
+		return defaultTenantID
+	}
+	return tenantID
+}
+
+// tenantVal returns the tenant ID for val as read from ColTenantID.
+func tenantVal(val string) string {
+	if val == defaultTenantID {
+		return ""
+	}
+	return val
+}
+
+// userTenantQ is a sub-query yielding the ColTenantID of the user whose
+// ID is the userIDPlaceholder argument e.g. "$1".
+func userTenantQ(userIDPlaceholder string) string {
+	return `(SELECT ` + ColTenantID + ` FROM ` + TblUsers + `
+		WHERE ` + ColID + `=` + userIDPlaceholder + `)`
+}
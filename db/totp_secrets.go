@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/tomogoma/authms/model"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// UpsertTOTPSecret inserts (or replaces) the unconfirmed TOTP secret for userID.
+func (r *Roach) UpsertTOTPSecret(userID string, secret []byte) (*model.TOTPSecret, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	ts := model.TOTPSecret{UserID: userID, Secret: secret}
+	insCols := ColDesc(ColUserID, ColSecret, ColIsConfirmed, ColLastStep, ColUpdateDate)
+	updCols := ColDesc(ColSecret, ColIsConfirmed, ColLastStep, ColUpdateDate)
+	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	q := `
+	INSERT INTO ` + TblTOTPSecrets + ` (` + insCols + `)
+		VALUES ($1, $2, FALSE, 0, CURRENT_TIMESTAMP)
+		ON CONFLICT (` + ColUserID + `)
+		DO UPDATE SET (` + updCols + `) = ($2, FALSE, 0, CURRENT_TIMESTAMP)
+		RETURNING ` + retCols
+	err := r.db.QueryRow(q, userID, secret).Scan(&ts.ID, &ts.CreateDate, &ts.UpdateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// TOTPSecret fetches the TOTP secret for userID.
+func (r *Roach) TOTPSecret(userID string) (*model.TOTPSecret, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColUserID, ColSecret, ColIsConfirmed, ColLastStep,
+		ColCreateDate, ColUpdateDate)
+	q := `SELECT ` + cols + ` FROM ` + TblTOTPSecrets + ` WHERE ` + ColUserID + `=$1`
+	ts := model.TOTPSecret{}
+	err := r.db.QueryRow(q, userID).Scan(&ts.ID, &ts.UserID, &ts.Secret,
+		&ts.IsConfirmed, &ts.LastStep, &ts.CreateDate, &ts.UpdateDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("TOTP secret not found")
+		}
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// ConfirmTOTPSecret marks the TOTP secret for userID as confirmed and step as
+// the last used time step.
+func (r *Roach) ConfirmTOTPSecret(userID string, step int64) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	updCols := ColDesc(ColIsConfirmed, ColLastStep, ColUpdateDate)
+	q := `
+	UPDATE ` + TblTOTPSecrets + `
+		SET (` + updCols + `) = (TRUE, $1, CURRENT_TIMESTAMP)
+		WHERE ` + ColUserID + `=$2`
+	rslt, err := r.db.Exec(q, step, userID)
+	return checkRowsAffected(rslt, err, 1)
+}
+
+// SetTOTPLastStep sets step as the last used time step for userID's TOTP
+// secret. It returns a NotFound error if no secret exists for userID or
+// the last used time step is not before step, preventing reuse of codes.
+func (r *Roach) SetTOTPLastStep(userID string, step int64) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	updCols := ColDesc(ColLastStep, ColUpdateDate)
+	q := `
+	UPDATE ` + TblTOTPSecrets + `
+		SET (` + updCols + `) = ($1, CURRENT_TIMESTAMP)
+		WHERE ` + ColUserID + `=$2 AND ` + ColLastStep + `<$1`
+	rslt, err := r.db.Exec(q, step, userID)
+	if err != nil {
+		return err
+	}
+	c, err := rslt.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if c == 0 {
+		return errors.NewNotFound("no TOTP secret found with an earlier last step")
+	}
+	return nil
+}
+
+// DeleteTOTPSecret deletes the TOTP secret for userID.
+func (r *Roach) DeleteTOTPSecret(userID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblTOTPSecrets + ` WHERE ` + ColUserID + `=$1`
+	_, err := r.db.Exec(q, userID)
+	return err
+}
@@ -2,38 +2,137 @@ package db
 
 import (
 	"database/sql"
+	"strconv"
 
+	"github.com/lib/pq"
 	"github.com/tomogoma/authms/model"
 	errors "github.com/tomogoma/go-typed-errors"
 )
 
 // InsertUserType inserts into the database returning calculated values.
-func (r *Roach) InsertUserType(name string) (*model.UserType, error) {
+func (r *Roach) InsertUserType(name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error) {
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	ut := model.UserType{Name: name}
-	insCols := ColDesc(ColName, ColUpdateDate)
+	if reqLoginTypes == nil {
+		reqLoginTypes = []string{}
+	}
+	ut := model.UserType{Name: name, SelfRegistrable: selfReg, RequiredLoginTypes: reqLoginTypes}
+	insCols := ColDesc(ColTenantID, ColName, ColSelfReg, ColReqLgnTypes, ColUpdateDate)
 	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
 	q := `
 	INSERT INTO ` + TblUserTypes + ` (` + insCols + `)
-		VALUES ($1,CURRENT_TIMESTAMP)
+		VALUES ($1,$2,$3,$4,CURRENT_TIMESTAMP)
+		RETURNING ` + retCols
+	err := r.db.QueryRow(q, r.tenantArg(), name, selfReg, pq.Array(reqLoginTypes)).
+		Scan(&ut.ID, &ut.CreateDate, &ut.UpdateDate)
+	if err != nil {
+		return nil, err
+	}
+	return &ut, nil
+}
+
+// UpdateUserType updates the name, self registration flag and required
+// login types of the user type having id.
+func (r *Roach) UpdateUserType(id, name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	if reqLoginTypes == nil {
+		reqLoginTypes = []string{}
+	}
+	ut := model.UserType{ID: id, Name: name, SelfRegistrable: selfReg, RequiredLoginTypes: reqLoginTypes}
+	updCols := ColDesc(ColName, ColSelfReg, ColReqLgnTypes, ColUpdateDate)
+	retCols := ColDesc(ColCreateDate, ColUpdateDate)
+	q := `
+	UPDATE ` + TblUserTypes + `
+		SET (` + updCols + `)=($1,$2,$3,CURRENT_TIMESTAMP)
+		WHERE ` + ColID + `=$4 AND ` + ColTenantID + `=$5
 		RETURNING ` + retCols
-	err := r.db.QueryRow(q, name).Scan(&ut.ID, &ut.CreateDate, &ut.UpdateDate)
+	err := r.db.QueryRow(q, name, selfReg, pq.Array(reqLoginTypes), id, r.tenantArg()).
+		Scan(&ut.CreateDate, &ut.UpdateDate)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("user type not found")
+		}
 		return nil, err
 	}
 	return &ut, nil
 }
 
+// DeleteUserType deletes the user type having id.
+func (r *Roach) DeleteUserType(id string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblUserTypes + ` WHERE ` + ColID + `=$1 AND ` + ColTenantID + `=$2`
+	rslt, err := r.db.Exec(q, id, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("user type not found")
+	}
+	return nil
+}
+
+// UserType fetches a user type in r's tenant by id.
+func (r *Roach) UserType(id string) (*model.UserType, error) {
+	return r.userTypeWhere(ColID+`=$1`, id)
+}
+
+// UserTypeByName fetches a user type in r's tenant by name.
 func (r *Roach) UserTypeByName(name string) (*model.UserType, error) {
+	return r.userTypeWhere(ColName+`=$1`, name)
+}
+
+// UserTypes fetches user types in r's tenant ordered by name.
+func (r *Roach) UserTypes(offset, count int64) ([]model.UserType, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	cols := ColDesc(ColID, ColName, ColSelfReg, ColReqLgnTypes, ColCreateDate, ColUpdateDate)
+	q := `
+		SELECT ` + cols + ` FROM ` + TblUserTypes + `
+			WHERE ` + ColTenantID + `=$1
+			ORDER BY ` + ColName + ` ASC
+			LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.Query(q, r.tenantArg(), count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var uts []model.UserType
+	for rows.Next() {
+		ut := model.UserType{}
+		err := rows.Scan(&ut.ID, &ut.Name, &ut.SelfRegistrable,
+			pq.Array(&ut.RequiredLoginTypes), &ut.CreateDate, &ut.UpdateDate)
+		if err != nil {
+			return nil, errors.Newf("scan result set row: %v", err)
+		}
+		uts = append(uts, ut)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Newf("iterating result set: %v", err)
+	}
+	if len(uts) == 0 {
+		return nil, errors.NewNotFound("no user types found")
+	}
+	return uts, nil
+}
+
+func (r *Roach) userTypeWhere(where string, whereArgs ...interface{}) (*model.UserType, error) {
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, err
 	}
-	ut := model.UserType{Name: name}
-	cols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
-	q := `SELECT ` + cols + ` FROM ` + TblUserTypes + ` WHERE ` + ColName + `=$1`
-	err := r.db.QueryRow(q, name).Scan(&ut.ID, &ut.CreateDate, &ut.UpdateDate)
+	cols := ColDesc(ColID, ColName, ColSelfReg, ColReqLgnTypes, ColCreateDate, ColUpdateDate)
+	whereArgs = append(whereArgs, r.tenantArg())
+	q := `SELECT ` + cols + ` FROM ` + TblUserTypes + `
+		WHERE (` + where + `) AND ` + ColTenantID + `=$` + strconv.Itoa(len(whereArgs))
+	ut := model.UserType{}
+	err := r.db.QueryRow(q, whereArgs...).Scan(&ut.ID, &ut.Name, &ut.SelfRegistrable,
+		pq.Array(&ut.RequiredLoginTypes), &ut.CreateDate, &ut.UpdateDate)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.NewNotFound("user type not found")
@@ -25,7 +25,7 @@ func TestRoach_InsertUserType(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.testName, func(t *testing.T) {
-			ret, err := r.InsertUserType(tc.utName)
+			ret, err := r.InsertUserType(tc.utName, true, nil)
 			if tc.expErr {
 				if err == nil {
 					t.Fatalf("Expected an error, got nil")
@@ -89,7 +89,7 @@ func TestRoach_UserTypeByName(t *testing.T) {
 }
 
 func insertUserType(t *testing.T, r *db.Roach) *model.UserType {
-	ut, err := r.InsertUserType(uuid.New())
+	ut, err := r.InsertUserType(uuid.New(), true, nil)
 	if err != nil {
 		t.Fatalf("Error setting up: insert usertype: %v", err)
 	}
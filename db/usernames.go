@@ -43,16 +43,32 @@ func (r *Roach) UpdateUsername(userID, username string) (*model.Username, error)
 	return &un, nil
 }
 
+// DeleteUserName deletes userID's username.
+func (r *Roach) DeleteUserName(userID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `DELETE FROM ` + TblUserNames + ` WHERE ` + ColUserID + `=$1`
+	rslt, err := r.db.Exec(q, userID)
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("username with userID not found")
+	}
+	return nil
+}
+
 func insertUserName(tx inserter, userID, username string) (*model.Username, error) {
 	if tx == nil || reflect.ValueOf(tx).IsNil() {
 		return nil, errorNilTx
 	}
 	un := model.Username{UserID: userID, Value: username}
-	insCols := ColDesc(ColUserID, ColUserName, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColUserID, ColUserName, ColUpdateDate)
 	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
 	q := `
 	INSERT INTO ` + TblUserNames + ` (` + insCols + `)
-		VALUES ($1,$2,CURRENT_TIMESTAMP)
+		VALUES (` + userTenantQ("$1") + `,$1,$2,CURRENT_TIMESTAMP)
 		RETURNING ` + retCols
 	err := tx.QueryRow(q, userID, username).Scan(&un.ID, &un.CreateDate, &un.UpdateDate)
 	if err != nil {
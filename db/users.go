@@ -2,7 +2,10 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"reflect"
+	"sort"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/tomogoma/authms/model"
@@ -13,13 +16,16 @@ import (
 
 var (
 	stdUsrCols = ColDesc(
-		colDescTbl(TblUsers, ColID, ColPassword, ColCreateDate, ColUpdateDate),
-		colDescTbl(TblUserTypes, ColID, ColName, ColCreateDate, ColUpdateDate),
+		colDescTbl(TblUsers, ColID, ColPassword, ColStatus, ColStatusRsn,
+			ColStatusUntil, ColAttributes, ColCreateDate, ColUpdateDate),
+		colDescTbl(TblUserTypes, ColID, ColName, ColSelfReg, ColReqLgnTypes,
+			ColCreateDate, ColUpdateDate),
 		colDescTbl(TblUserNames, ColID, ColUserName, ColCreateDate, ColUpdateDate),
 		colDescTbl(TblEmails, ColID, ColEmail, ColVerified, ColCreateDate, ColUpdateDate),
 		colDescTbl(TblPhones, ColID, ColPhone, ColVerified, ColCreateDate, ColUpdateDate),
 		colDescTbl(TblFacebookIDs, ColID, ColFacebookID, ColVerified, ColCreateDate, ColUpdateDate),
-		colDescTbl(TblGroups, ColID, ColName, ColAccessLevel, ColCreateDate, ColUpdateDate),
+		colDescTbl(TblGroups, ColID, ColName, ColAccessLevel, ColPermissions,
+			ColCreateDate, ColUpdateDate),
 	)
 )
 
@@ -42,6 +48,27 @@ func (r *Roach) HasUsers(groupID string) error {
 	return nil
 }
 
+// HasUsersOfType returns nil if any user has the user type having typeID
+// or a NotFound error if none does.
+func (r *Roach) HasUsersOfType(typeID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `
+		SELECT COUNT(` + ColID + `)
+			FROM ` + TblUsers + `
+			WHERE ` + ColTypeID + `=$1`
+	var numUsers int
+	err := r.db.QueryRow(q, typeID).Scan(&numUsers)
+	if err != nil {
+		return err
+	}
+	if numUsers == 0 {
+		return errors.NewNotFound("No users found")
+	}
+	return nil
+}
+
 // InsertUserType inserts into the database returning calculated values.
 func (r *Roach) InsertUserAtomic(tx *sql.Tx, t model.UserType, g model.Group, password []byte) (*model.User, error) {
 	if err := r.InitDBIfNot(); err != nil {
@@ -51,13 +78,14 @@ func (r *Roach) InsertUserAtomic(tx *sql.Tx, t model.UserType, g model.Group, pa
 		return nil, errorNilTx
 	}
 	u := model.User{Type: t, Group: g}
-	insCols := ColDesc(ColTypeID, ColGroupID, ColPassword, ColUpdateDate)
-	retCols := ColDesc(ColID, ColCreateDate, ColUpdateDate)
+	insCols := ColDesc(ColTenantID, ColTypeID, ColGroupID, ColPassword, ColUpdateDate)
+	retCols := ColDesc(ColID, ColStatus, ColCreateDate, ColUpdateDate)
 	q := `
 	INSERT INTO ` + TblUsers + ` (` + insCols + `)
-		VALUES ($1,$2,$3,CURRENT_TIMESTAMP)
+		VALUES ($1,$2,$3,$4,CURRENT_TIMESTAMP)
 		RETURNING ` + retCols
-	err := tx.QueryRow(q, t.ID, g.ID, password).Scan(&u.ID, &u.CreateDate, &u.UpdateDate)
+	err := tx.QueryRow(q, r.tenantArg(), t.ID, g.ID, password).Scan(&u.ID, &u.Status.Value,
+		&u.CreateDate, &u.UpdateDate)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +105,58 @@ func (r *Roach) UpdatePasswordAtomic(tx *sql.Tx, userID string, password []byte)
 	return updatePassword(tx, userID, password)
 }
 
+// DeleteUserAtomic deletes userID's account using tx together with every
+// row referencing it. Refresh tokens issued (to any user) through the
+// account's API keys are deleted along with the API keys.
+func (r *Roach) DeleteUserAtomic(tx *sql.Tx, userID string) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	if tx == nil || reflect.ValueOf(tx).IsNil() {
+		return errorNilTx
+	}
+	q := `
+	DELETE FROM ` + TblRefreshTokens + `
+		WHERE ` + ColUserID + `=$1
+		OR ` + ColAPIKeyID + ` IN (
+			SELECT ` + ColID + ` FROM ` + TblAPIKeys + ` WHERE ` + ColUserID + `=$1
+		)`
+	if _, err := tx.Exec(q, userID); err != nil {
+		return errors.Newf("delete %s: %v", TblRefreshTokens, err)
+	}
+	// tables are listed in reverse order of dependency.
+	for _, tbl := range []string{
+		TblPassHistory,
+		TblSessions,
+		TblRevokedTokens,
+		TblLinkedIDs,
+		TblTOTPSecrets,
+		TblLoginHistory,
+		TblFacebookIDs,
+		TblPhoneTokens,
+		TblPhones,
+		TblEmailTokens,
+		TblEmails,
+		TblUserNames,
+		TblDeviceIDs,
+		TblAPIKeys,
+	} {
+		q := `DELETE FROM ` + tbl + ` WHERE ` + ColUserID + `=$1`
+		if _, err := tx.Exec(q, userID); err != nil {
+			return errors.Newf("delete %s: %v", tbl, err)
+		}
+	}
+	q = `DELETE FROM ` + TblUsers + ` WHERE ` + ColID + `=$1`
+	rslt, err := tx.Exec(q, userID)
+	if err != nil {
+		return errors.Newf("delete %s: %v", TblUsers, err)
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("user not found")
+	}
+	return nil
+}
+
 // User fetches User and password for account with id.
 func (r *Roach) User(id string) (*model.User, []byte, error) {
 	return r.userWhere(TblUsers+`.`+ColID+`=$1`, id)
@@ -92,14 +172,20 @@ func (r *Roach) UserByUsername(username string) (*model.User, []byte, error) {
 	return r.userWhere(TblUserNames+`.`+ColUserName+`=$1`, username)
 }
 
-// UserByPhone fetches User and password for account with phone.
+// UserByPhone fetches User and password for account with phone, whether
+// or not phone is the account's primary phone.
 func (r *Roach) UserByPhone(phone string) (*model.User, []byte, error) {
-	return r.userWhere(TblPhones+`.`+ColPhone+`=$1`, phone)
+	return r.userWhere(TblUsers+`.`+ColID+` IN (
+		SELECT `+ColUserID+` FROM `+TblPhones+` WHERE `+ColPhone+`=$1
+	)`, phone)
 }
 
-// UserByEmail fetches User and password for account with email.
+// UserByEmail fetches User and password for account with email, whether
+// or not email is the account's primary email.
 func (r *Roach) UserByEmail(email string) (*model.User, []byte, error) {
-	return r.userWhere(TblEmails+`.`+ColEmail+`=$1`, email)
+	return r.userWhere(TblUsers+`.`+ColID+` IN (
+		SELECT `+ColUserID+` FROM `+TblEmails+` WHERE `+ColEmail+`=$1
+	)`, email)
 }
 
 // UserByFacebook fetches User and password for account with fbID.
@@ -133,6 +219,42 @@ func (r *Roach) Users(uq model.UsersQuery, offset, count int64) ([]model.User, e
 			where, TblGroups, ColName, in, qOp)
 	}
 
+	if len(uq.StatusesIn) > 0 {
+		in := "("
+		for _, status := range uq.StatusesIn {
+			in = fmt.Sprintf("%s$%d,", in, i)
+			whereArgs = append(whereArgs, status)
+			i++
+		}
+		in = strings.TrimSuffix(in, ",") + ")"
+		where = fmt.Sprintf("%s %s.%s IN %s %s",
+			where, TblUsers, ColStatus, in, qOp)
+	}
+
+	// sorted for a deterministic query.
+	var attrNames []string
+	for name := range uq.AttributesIn {
+		attrNames = append(attrNames, name)
+	}
+	sort.Strings(attrNames)
+	for _, name := range attrNames {
+		vals := uq.AttributesIn[name]
+		if len(vals) == 0 {
+			continue
+		}
+		attr := fmt.Sprintf("%s.%s->>$%d", TblUsers, ColAttributes, i)
+		whereArgs = append(whereArgs, name)
+		i++
+		in := "("
+		for _, val := range vals {
+			in = fmt.Sprintf("%s$%d,", in, i)
+			whereArgs = append(whereArgs, val)
+			i++
+		}
+		in = strings.TrimSuffix(in, ",") + ")"
+		where = fmt.Sprintf("%s %s IN %s %s", where, attr, in, qOp)
+	}
+
 	if len(uq.ProcessedACLs) > 0 {
 
 		aclOp := "OR"
@@ -168,8 +290,13 @@ func (r *Roach) Users(uq model.UsersQuery, offset, count int64) ([]model.User, e
 	}
 
 	where = strings.TrimSuffix(where, qOp)
+	tenantWhere := fmt.Sprintf("%s.%s=$%d", TblUsers, ColTenantID, i)
+	whereArgs = append(whereArgs, r.tenantArg())
+	i++
 	if where != "" {
-		where = fmt.Sprintf("WHERE %s", where)
+		where = fmt.Sprintf("WHERE (%s) AND %s", where, tenantWhere)
+	} else {
+		where = fmt.Sprintf("WHERE %s", tenantWhere)
 	}
 
 	limitStr := fmt.Sprintf("$%d", i)
@@ -191,8 +318,10 @@ func (r *Roach) Users(uq model.UsersQuery, offset, count int64) ([]model.User, e
 				ON ` + TblUsers + `.` + ColID + `=` + TblUserNames + `.` + ColUserID + `
 			LEFT JOIN ` + TblEmails + `
 				ON ` + TblUsers + `.` + ColID + `=` + TblEmails + `.` + ColUserID + `
+					AND ` + TblEmails + `.` + ColIsPrimary + `
 			LEFT JOIN ` + TblPhones + `
 				ON ` + TblUsers + `.` + ColID + `=` + TblPhones + `.` + ColUserID + `
+					AND ` + TblPhones + `.` + ColIsPrimary + `
 			LEFT JOIN ` + TblFacebookIDs + `
 				ON ` + TblUsers + `.` + ColID + `=` + TblFacebookIDs + `.` + ColUserID + `
 			LEFT JOIN ` + TblDeviceIDs + `
@@ -237,10 +366,107 @@ func (r *Roach) SetUserGroup(userID, groupID string) error {
 	return checkRowsAffected(rslt, err, 1)
 }
 
+// SetUserStatus sets the status of userID's account. A zero s.Until is
+// stored as NULL.
+func (r *Roach) SetUserStatus(userID string, s model.UserStatus) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	var until pq.NullTime
+	if !s.Until.IsZero() {
+		until = pq.NullTime{Time: s.Until, Valid: true}
+	}
+	q := `
+	UPDATE ` + TblUsers + `
+		SET (` + ColDesc(ColStatus, ColStatusRsn, ColStatusUntil, ColUpdateDate) + `)
+			= ($1, $2, $3, CURRENT_TIMESTAMP)
+		WHERE ` + ColID + ` = $4 AND ` + ColTenantID + ` = $5`
+	rslt, err := r.db.Exec(q, s.Value, s.Reason, until, userID, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("user not found")
+	}
+	return nil
+}
+
+// SetUserAttributes replaces the custom attributes of userID's account.
+func (r *Roach) SetUserAttributes(userID string, attrs map[string]interface{}) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	if attrs == nil {
+		attrs = map[string]interface{}{}
+	}
+	attrsB, err := json.Marshal(attrs)
+	if err != nil {
+		return errors.Newf("marshal attributes: %v", err)
+	}
+	q := `
+	UPDATE ` + TblUsers + `
+		SET (` + ColDesc(ColAttributes, ColUpdateDate) + `) = ($1, CURRENT_TIMESTAMP)
+		WHERE ` + ColID + ` = $2 AND ` + ColTenantID + ` = $3`
+	rslt, err := r.db.Exec(q, attrsB, userID, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("user not found")
+	}
+	return nil
+}
+
+// UserStatus fetches the status of userID's account including when they
+// last logged out everywhere.
+func (r *Roach) UserStatus(userID string) (*model.UserStatus, error) {
+	if err := r.InitDBIfNot(); err != nil {
+		return nil, err
+	}
+	q := `
+	SELECT ` + ColDesc(ColStatus, ColStatusRsn, ColStatusUntil, ColLgdOutAll) + `
+		FROM ` + TblUsers + `
+		WHERE ` + ColID + ` = $1 AND ` + ColTenantID + ` = $2`
+	s := model.UserStatus{}
+	var until, loggedOutAll pq.NullTime
+	err := r.db.QueryRow(q, userID, r.tenantArg()).Scan(&s.Value, &s.Reason, &until, &loggedOutAll)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFound("user not found")
+		}
+		return nil, err
+	}
+	s.Until = until.Time
+	s.LoggedOutAll = loggedOutAll.Time
+	return &s, nil
+}
+
+// SetLoggedOutAll records at as the time userID last logged out everywhere.
+func (r *Roach) SetLoggedOutAll(userID string, at time.Time) error {
+	if err := r.InitDBIfNot(); err != nil {
+		return err
+	}
+	q := `
+	UPDATE ` + TblUsers + `
+		SET (` + ColDesc(ColLgdOutAll, ColUpdateDate) + `) = ($1, CURRENT_TIMESTAMP)
+		WHERE ` + ColID + ` = $2 AND ` + ColTenantID + ` = $3`
+	rslt, err := r.db.Exec(q, at, userID, r.tenantArg())
+	if err != nil {
+		return err
+	}
+	if c, err := rslt.RowsAffected(); err == nil && c == 0 {
+		return errors.NewNotFound("user not found")
+	}
+	return nil
+}
+
+// userWhere fetches the user in r's tenant matching where.
 func (r *Roach) userWhere(where string, whereArgs ...interface{}) (*model.User, []byte, error) {
 	if err := r.InitDBIfNot(); err != nil {
 		return nil, nil, err
 	}
+	whereArgs = append(whereArgs, r.tenantArg())
+	where = fmt.Sprintf("(%s) AND %s.%s=$%d", where, TblUsers, ColTenantID, len(whereArgs))
 	q := `
 	SELECT ` + stdUsrCols + `
 		FROM ` + TblUsers + `
@@ -252,8 +478,10 @@ func (r *Roach) userWhere(where string, whereArgs ...interface{}) (*model.User,
 				ON ` + TblUsers + `.` + ColID + `=` + TblUserNames + `.` + ColUserID + `
 			LEFT JOIN ` + TblEmails + `
 				ON ` + TblUsers + `.` + ColID + `=` + TblEmails + `.` + ColUserID + `
+					AND ` + TblEmails + `.` + ColIsPrimary + `
 			LEFT JOIN ` + TblPhones + `
 				ON ` + TblUsers + `.` + ColID + `=` + TblPhones + `.` + ColUserID + `
+					AND ` + TblPhones + `.` + ColIsPrimary + `
 			LEFT JOIN ` + TblFacebookIDs + `
 				ON ` + TblUsers + `.` + ColID + `=` + TblFacebookIDs + `.` + ColUserID + `
 			LEFT JOIN ` + TblDeviceIDs + `
@@ -273,6 +501,16 @@ func (r *Roach) userWhere(where string, whereArgs ...interface{}) (*model.User,
 		return nil, nil, errors.Newf("get device IDs for user: %v", err)
 	}
 
+	usr.Emails, err = r.UserEmailsByUserID(usr.ID)
+	if err != nil && !r.IsNotFoundError(err) {
+		return nil, nil, errors.Newf("get emails for user: %v", err)
+	}
+
+	usr.Phones, err = r.UserPhonesByUserID(usr.ID)
+	if err != nil && !r.IsNotFoundError(err) {
+		return nil, nil, errors.Newf("get phones for user: %v", err)
+	}
+
 	return usr, pass, nil
 }
 
@@ -285,20 +523,31 @@ func scanStdUser(sc scanner) (*model.User, []byte, error) {
 	var emailVerified, phoneVerified, fbVerified sql.NullBool
 	var usernameCD, emailCD, phoneCD, fbCD pq.NullTime
 	var usernameUD, emailUD, phoneUD, fbUD pq.NullTime
+	var statusUntil pq.NullTime
+	var attrsB []byte
 
 	err := sc.Scan(
-		&usr.ID, &pass, &usr.CreateDate, &usr.UpdateDate,
-		&usr.Type.ID, &usr.Type.Name, &usr.Type.CreateDate, &usr.Type.UpdateDate,
+		&usr.ID, &pass, &usr.Status.Value, &usr.Status.Reason, &statusUntil,
+		&attrsB, &usr.CreateDate, &usr.UpdateDate,
+		&usr.Type.ID, &usr.Type.Name, &usr.Type.SelfRegistrable,
+		pq.Array(&usr.Type.RequiredLoginTypes), &usr.Type.CreateDate, &usr.Type.UpdateDate,
 		&usernameID, &usernameVal, &usernameCD, &usernameUD,
 		&emailID, &emailVal, &emailVerified, &emailCD, &emailUD,
 		&phoneID, &phoneVal, &phoneVerified, &phoneCD, &phoneUD,
 		&fbID, &fbVal, &fbVerified, &fbCD, &fbUD, &usr.Group.ID, &usr.Group.Name,
-		&usr.Group.AccessLevel, &usr.Group.CreateDate, &usr.Group.UpdateDate,
+		&usr.Group.AccessLevel, pq.Array(&usr.Group.Permissions),
+		&usr.Group.CreateDate, &usr.Group.UpdateDate,
 	)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	usr.Status.Until = statusUntil.Time
+	if len(attrsB) > 0 {
+		if err := json.Unmarshal(attrsB, &usr.Attributes); err != nil {
+			return nil, nil, errors.Newf("unmarshal attributes: %v", err)
+		}
+	}
 	if usernameVal.Valid {
 		usr.UserName.ID = usernameID.String
 		usr.UserName.UserID = usr.ID
@@ -311,6 +560,7 @@ func scanStdUser(sc scanner) (*model.User, []byte, error) {
 		usr.Email.UserID = usr.ID
 		usr.Email.Address = emailVal.String
 		usr.Email.Verified = emailVerified.Bool
+		usr.Email.IsPrimary = true
 		usr.Email.CreateDate = emailCD.Time
 		usr.Email.UpdateDate = emailUD.Time
 	}
@@ -319,6 +569,7 @@ func scanStdUser(sc scanner) (*model.User, []byte, error) {
 		usr.Phone.UserID = usr.ID
 		usr.Phone.Address = phoneVal.String
 		usr.Phone.Verified = phoneVerified.Bool
+		usr.Phone.IsPrimary = true
 		usr.Phone.CreateDate = phoneCD.Time
 		usr.Phone.UpdateDate = phoneUD.Time
 	}
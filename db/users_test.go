@@ -18,7 +18,7 @@ func TestRoach_InsertUserAtomic_nilTx(t *testing.T) {
 	conf := setup(t)
 	defer tearDown(t, conf)
 	r := newRoach(t, conf)
-	ut, err := r.InsertUserType("test")
+	ut, err := r.InsertUserType("test", true, nil)
 	if err != nil {
 		t.Fatalf("Error setting up: insert user type: %v", err)
 	}
@@ -32,7 +32,7 @@ func TestRoach_InsertUserAtomic(t *testing.T) {
 	conf := setup(t)
 	defer tearDown(t, conf)
 	r := newRoach(t, conf)
-	ut, err := r.InsertUserType("test")
+	ut, err := r.InsertUserType("test", true, nil)
 	if err != nil {
 		t.Fatalf("Error setting up: insert user type: %v", err)
 	}
@@ -228,6 +228,47 @@ func TestRoach_User(t *testing.T) {
 	}
 }
 
+func TestRoach_UserStatus(t *testing.T) {
+	conf := setup(t)
+	defer tearDown(t, conf)
+	r := newRoach(t, conf)
+	usr := insertUser(t, r)
+	tt := []struct {
+		name        string
+		r           model.AuthStore
+		expNotFound bool
+	}{
+		{name: "same tenant", r: r},
+		{name: "other tenant", r: r.ForTenant("999"), expNotFound: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := model.UserStatus{Value: model.StatusSuspended, Reason: "testing"}
+			err := tc.r.SetUserStatus(usr.ID, s)
+			if tc.expNotFound {
+				if !r.IsNotFoundError(err) {
+					t.Fatalf("Set status: expected not found, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Set status: got error: %v", err)
+			}
+			actS, err := tc.r.UserStatus(usr.ID)
+			if tc.expNotFound {
+				if !r.IsNotFoundError(err) {
+					t.Fatalf("Get status: expected not found, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get status: got error: %v", err)
+			}
+			if actS.Value != s.Value || actS.Reason != s.Reason {
+				t.Errorf("Status mismatch:\nExpect:\t%+v\nGot:\t%+v", s, actS)
+			}
+		})
+	}
+}
+
 func TestRoach_UserByDeviceID(t *testing.T) {
 	conf := setup(t)
 	defer tearDown(t, conf)
@@ -421,7 +462,7 @@ func TestRoach_UserByFacebook(t *testing.T) {
 }
 
 func insertUser(t *testing.T, r *db.Roach) *model.User {
-	ut, err := r.InsertUserType(uuid.New())
+	ut, err := r.InsertUserType(uuid.New(), true, nil)
 	if err != nil {
 		t.Fatalf("Error setting up: insert user type: %v", err)
 	}
@@ -0,0 +1,59 @@
+// Package encryption provides symmetric encryption of small secrets for
+// storage at rest.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// AESGCM encrypts and decrypts data using AES-256 in GCM mode. Use NewAESGCM()
+// to construct.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM creates an AESGCM from key. key is hashed using SHA-256 to
+// obtain the AES-256 key, it should nevertheless have sufficient entropy.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) == 0 {
+		return nil, errors.New("key was empty")
+	}
+	aesKey := sha256.Sum256(key)
+	block, err := aes.NewCipher(aesKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("new AES cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new GCM: %v", err)
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// Encrypt encrypts plain. The random nonce used is prefixed to the returned
+// cipher text.
+func (e *AESGCM) Encrypt(plain []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %v", err)
+	}
+	return e.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// Decrypt decrypts cipherText produced by Encrypt().
+func (e *AESGCM) Decrypt(cipherText []byte) ([]byte, error) {
+	nonceSize := e.aead.NonceSize()
+	if len(cipherText) < nonceSize {
+		return nil, errors.New("cipher text too short")
+	}
+	plain, err := e.aead.Open(nil, cipherText[:nonceSize], cipherText[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("open: %v", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,70 @@
+package encryption_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tomogoma/authms/encryption"
+)
+
+func TestNewAESGCM(t *testing.T) {
+	tt := []struct {
+		name   string
+		key    []byte
+		expErr bool
+	}{
+		{name: "valid", key: []byte("some secret key"), expErr: false},
+		{name: "empty key", key: nil, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := encryption.NewAESGCM(tc.key)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if e == nil {
+				t.Fatalf("Got nil *AESGCM")
+			}
+		})
+	}
+}
+
+func TestAESGCM_EncryptDecrypt(t *testing.T) {
+	e, err := encryption.NewAESGCM([]byte("some secret key"))
+	if err != nil {
+		t.Fatalf("Error setting up: new AESGCM: %v", err)
+	}
+	plain := []byte("a secret message")
+	cipherText, err := e.Encrypt(plain)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if bytes.Contains(cipherText, plain) {
+		t.Fatalf("Cipher text contains plain text")
+	}
+	got, err := e.Decrypt(cipherText)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("Expected '%s', got '%s'", plain, got)
+	}
+
+	other, err := encryption.NewAESGCM([]byte("some other key"))
+	if err != nil {
+		t.Fatalf("Error setting up: new AESGCM: %v", err)
+	}
+	if _, err := other.Decrypt(cipherText); err == nil {
+		t.Errorf("Expected error decrypting with wrong key, got nil")
+	}
+	cipherText[len(cipherText)-1] ^= 0xff
+	if _, err := e.Decrypt(cipherText); err == nil {
+		t.Errorf("Expected error decrypting tampered cipher text, got nil")
+	}
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"encoding/json"
+
+	"github.com/tomogoma/authms/model"
+)
+
+/**
+ * @api {NULL} AttributeSchema AttributeSchema
+ * @apiName AttributeSchema
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {Object} schema The JSON Schema users' custom attributes must
+ *	conform to.
+ * @apiSuccess {String[]} [claimAttributes] Names of the attributes included
+ *	in JWTs issued to users.
+ */
+type AttributeSchema struct {
+	Schema          json.RawMessage `json:"schema,omitempty"`
+	ClaimAttributes []string        `json:"claimAttributes,omitempty"`
+}
+
+func NewAttributeSchema(as *model.AttributeSchema) *AttributeSchema {
+	if as == nil || !as.HasValue() {
+		return nil
+	}
+	return &AttributeSchema{
+		Schema:          as.Schema,
+		ClaimAttributes: as.ClaimAttributes,
+	}
+}
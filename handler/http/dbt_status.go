@@ -23,6 +23,7 @@ import (
 type DBTStatus struct {
 	ObfuscatedAddress string `json:"obfuscatedAddress,omitempty"`
 	ExpiresAt         string `json:"expiresAt,omitempty"`
+	Nonce             string `json:"nonce,omitempty"`
 }
 
 func NewDBTStatus(dbtS *model.DBTStatus) *DBTStatus {
@@ -32,5 +33,6 @@ func NewDBTStatus(dbtS *model.DBTStatus) *DBTStatus {
 	return &DBTStatus{
 		ObfuscatedAddress: dbtS.ObfuscatedAddress,
 		ExpiresAt:         dbtS.ExpiresAt.Format(config.TimeFormat),
+		Nonce:             dbtS.Nonce,
 	}
 }
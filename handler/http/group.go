@@ -14,15 +14,18 @@ import (
  * @apiSuccess {String} ID Unique ID of the group (can be cast to long Integer).
  * @apiSuccess {String} name The unique group name string value.
  * @apiSuccess {Integer} accessLevel The access level for this group in (0 >= accessLevel <= 10)
+ * @apiSuccess {String[]} [permissions] Permissions granted to members of the
+ *	group in addition to what accessLevel allows e.g. users:read.
  * @apiSuccess {String} created ISO8601 date the group was created.
  * @apiSuccess {String} lastUpdated ISO8601 date the group was last updated.
  */
 type Group struct {
-	ID          string  `json:"ID,omitempty"`
-	Name        string  `json:"name,omitempty"`
-	AccessLevel float32 `json:"accessLevel,omitempty"`
-	CreateDate  string  `json:"created,omitempty"`
-	UpdateDate  string  `json:"lastUpdated,omitempty"`
+	ID          string   `json:"ID,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	AccessLevel float32  `json:"accessLevel,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	CreateDate  string   `json:"created,omitempty"`
+	UpdateDate  string   `json:"lastUpdated,omitempty"`
 }
 
 func NewGroup(g model.Group) *Group {
@@ -33,6 +36,7 @@ func NewGroup(g model.Group) *Group {
 		ID:          g.ID,
 		Name:        g.Name,
 		AccessLevel: g.AccessLevel,
+		Permissions: g.Permissions,
 		CreateDate:  g.CreateDate.Format(config.TimeFormat),
 		UpdateDate:  g.UpdateDate.Format(config.TimeFormat),
 	}
@@ -4,20 +4,31 @@ import (
 	"context"
 	"encoding/json"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
+	"github.com/tomogoma/authms/api"
 	"github.com/tomogoma/authms/config"
 	"github.com/tomogoma/authms/logging"
 	"github.com/tomogoma/authms/model"
+	"github.com/tomogoma/authms/oidc"
 	"github.com/tomogoma/go-typed-errors"
 )
 
 type contextKey string
 
+const (
+	// maxUserAgentLen and maxIPAddressLen are limited by the columns
+	// client info is stored in.
+	maxUserAgentLen = 512
+	maxIPAddressLen = 56
+)
+
 type Auth interface {
 	errors.ToHTTPResponser
 
@@ -26,30 +37,88 @@ type Auth interface {
 	RegisterSelf(loginType, userType, id string, secret []byte) (*model.User, error)
 	RegisterSelfByLockedDevice(loginType, userType, devID, number string, password []byte) (*model.User, error)
 	RegisterOther(JWT, newLoginType, userType, id, groupID string) (*model.User, error)
+	ImportUser(JWT string, ui model.UserImport) (*model.User, error)
 
 	UpdateIdentifier(JWT, forUserID, loginType, newId string) (*model.User, error)
+	AddAddress(JWT, forUserID, loginType, address string) (*model.VerifLogin, error)
+	RemoveAddress(JWT, forUserID, loginType, address string) error
+	SetPrimaryAddress(JWT, forUserID, loginType, address string) (*model.User, error)
+	LinkIdentity(JWT, forUserID, loginType, identifier string, secret []byte) (*model.User, error)
+	UnlinkIdentity(JWT, forUserID, loginType string) error
 
 	UpdatePassword(JWT string, old, newPass []byte) error
-	SetPassword(loginType, onAddr string, dbt, pass []byte) (*model.VerifLogin, error)
+	SetPassword(ci model.ClientInfo, loginType, onAddr string, dbt, pass []byte) (*model.VerifLogin, error)
 
 	SendVerCode(JWT, loginType, toAddr string) (*model.DBTStatus, error)
+	SendConfirmCode(JWT, forUserID, loginType string) (*model.DBTStatus, error)
 	SendPassResetCode(loginType, toAddr string) (*model.DBTStatus, error)
 
-	VerifyAndExtendDBT(lt, forAddr string, dbt []byte) (string, error)
-	VerifyDBT(loginType, forAddr string, dbt []byte) (*model.VerifLogin, error)
-
-	Login(loginType, identifier string, password []byte) (*model.User, error)
+	VerifyAndExtendDBT(ci model.ClientInfo, lt, forAddr string, dbt []byte) (string, error)
+	VerifyDBT(ci model.ClientInfo, loginType, forAddr string, dbt []byte) (*model.VerifLogin, error)
+
+	Login(ci model.ClientInfo, loginType, identifier string, password []byte) (*model.User, error)
+	SendLoginLink(loginType, toAddr string) (*model.DBTStatus, error)
+	LoginByLink(ci model.ClientInfo, loginType, userID, nonce string, dbt []byte) (*model.User, error)
+	SendLoginOTP(loginType, toAddr string) (*model.DBTStatus, error)
+	LoginByOTP(ci model.ClientInfo, loginType, identifier string, code []byte) (*model.User, error)
+	Refresh(ci model.ClientInfo, refreshToken string) (*model.User, error)
+	Introspect(token string) (*model.TokenIntrospection, error)
+	Revoke(ci model.ClientInfo, token string) error
+	Logout(JWT string) error
+	LogoutAll(JWT, userID string) error
+	Sessions(JWT, userID, offset, count string) ([]model.Session, error)
+	RevokeSession(JWT, userID, sessionID string) error
+	VerifyMFA(ci model.ClientInfo, mfaToken, code string) (*model.User, error)
+
+	EnrollTOTP(JWT, userID string) (*model.TOTPEnrollment, error)
+	ConfirmTOTP(JWT, userID, code string) error
+	ResetTOTP(JWT, userID string) error
+
+	LoginLockout(JWT, loginType, identifier string) (*model.Lockout, error)
+	IPLockout(JWT, ipAddress string) (*model.Lockout, error)
+	UnlockLogin(JWT, loginType, identifier string) (*model.Lockout, error)
+	UnlockIP(JWT, ipAddress string) (*model.Lockout, error)
+
+	LoginHistory(JWT, userID, offset, count string) ([]model.History, error)
+	ExportUser(JWT, userID string) (*model.UserExport, error)
 
 	Users(JWT string, q model.UsersQuery, offset, count string) ([]model.User, error)
 	GetUserDetails(JWT, userID string) (*model.User, error)
 	UserID(loginType, identifier string) (string, error)
 	SetUserGroup(JWT, userID, groupID string) (*model.User, error)
+	SetUserStatus(JWT, userID, status, reason, until string) (*model.User, error)
+	UserAttributes(JWT, userID string) (map[string]interface{}, error)
+	SetUserAttributes(JWT, userID string, attrs map[string]interface{}) (map[string]interface{}, error)
+	DeleteUser(ci model.ClientInfo, JWT, userID, confirmLoginType string, confirmation []byte) error
 
 	Groups(JWT, offset, count string) ([]model.Group, error)
+	CreateGroup(JWT, name string, accessLevel float32) (*model.Group, error)
+	UpdateGroup(JWT, groupID, name string, accessLevel float32) (*model.Group, error)
+	SetGroupPermissions(JWT, groupID string, perms []string) (*model.Group, error)
+	DeleteGroup(JWT, groupID string) error
+
+	UserTypes(JWT, offsetStr, countStr string) ([]model.UserType, error)
+	CreateUserType(JWT, name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error)
+	UpdateUserType(JWT, userTypeID, name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error)
+	DeleteUserType(JWT, userTypeID string) error
+
+	AttributeSchema(JWT string) (*model.AttributeSchema, error)
+	SetAttributeSchema(JWT string, schema []byte, claimAttrs []string) (*model.AttributeSchema, error)
+
+	CreateTenant(JWT, name string) (*model.Tenant, error)
+	Tenants(JWT, offsetStr, countStr string) ([]model.Tenant, error)
 }
 
+// TenantAuth returns the Auth for the tenant having tenantID. An empty
+// tenantID refers to the default tenant.
+type TenantAuth func(tenantID string) Auth
+
 type Guard interface {
-	APIKeyValid(key string) (string, error)
+	APIKey(key string) (*api.Key, error)
+}
+
+type KeySet interface {
+	JWKS() oidc.JWKS
 }
 
 type handler struct {
@@ -58,10 +127,14 @@ type handler struct {
 	errors.ClErrCheck
 	errors.NotFoundErrCheck
 
-	auth      Auth
+	authFor   TenantAuth
 	guard     Guard
+	keySet    KeySet
 	logger    logging.Logger
 	webAppURL string
+	// trustedProxies are the networks whose X-Forwarded-For header is
+	// believed when determining the client's IP address.
+	trustedProxies []*net.IPNet
 }
 
 const (
@@ -78,37 +151,62 @@ const (
 	keyCount            = "count"
 	keyUserID           = "userID"
 	keyGroupID          = "groupID"
+	keyUserTypeID       = "userTypeID"
+	keyStatus           = "status"
+	keyReason           = "reason"
+	keyUntil            = "until"
+	keyAddress          = "address"
 	keyAcl              = "acl"
 	keyGroup            = "group"
 	keyMatchAllACLs     = "matchAllACLs"
 	keyMatchAll         = "matchAll"
-
-	ctxKeyLog = contextKey("log")
+	keyAttrPrefix       = "attr."
+	keyLinkTenantID     = "tenantID"
+	keyIdentifier       = "identifier"
+	keyIPAddress        = "ipAddress"
+	keySessionID        = "sessionID"
+	keyDeviceID         = "x-device-id"
+	keyLoginNonce       = "loginNonce"
+	keyTenantID         = "x-tenant-id"
+	keyForwardedFor     = "X-Forwarded-For"
+
+	ctxKeyLog      = contextKey("log")
+	ctxKeyAPIKey   = contextKey("apiKey")
+	ctxKeyTenantID = contextKey("tenantID")
 
 	valTrue   = "true"
 	valDevice = "device"
 )
 
-func NewHandler(a Auth, g Guard, l logging.Logger, webAppURL string, allowedOrigins []string) (http.Handler, error) {
+func NewHandler(a TenantAuth, g Guard, ks KeySet, l logging.Logger, webAppURL string, allowedOrigins, trustedProxies []string) (http.Handler, error) {
 	if a == nil {
 		return nil, errors.New("Auth was nil")
 	}
 	if g == nil {
 		return nil, errors.New("Guard was nil")
 	}
+	if ks == nil {
+		return nil, errors.New("KeySet was nil")
+	}
 	if l == nil {
 		return nil, errors.New("Logger was nil")
 	}
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, errors.Newf("trusted proxies: %v", err)
+	}
 
 	r := mux.NewRouter().PathPrefix(config.WebRootURL()).Subrouter()
-	handler{auth: a, guard: g, logger: l, webAppURL: webAppURL}.handleRoute(r)
+	handler{authFor: a, guard: g, keySet: ks, logger: l, webAppURL: webAppURL,
+		trustedProxies: proxies}.handleRoute(r)
 
 	headersOk := handlers.AllowedHeaders([]string{
 		"X-Requested-With", "Accept", "Content-Type", "Content-Length",
 		"Accept-Encoding", "X-CSRF-Token", "Authorization", "X-api-key",
+		"X-device-id", "X-tenant-id",
 	})
 	originsOk := handlers.AllowedOrigins(allowedOrigins)
-	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"})
+	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"})
 
 	return handlers.CORS(headersOk, originsOk, methodsOk)(r), nil
 }
@@ -119,6 +217,10 @@ func (s handler) handleRoute(r *mux.Router) {
 		Methods(http.MethodGet).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleStatus)))
 
+	r.PathPrefix("/.well-known/jwks.json").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.handleJWKS))
+
 	r.PathPrefix("/first_user").
 		Methods(http.MethodPut).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleRegisterFirst)))
@@ -131,21 +233,165 @@ func (s handler) handleRoute(r *mux.Router) {
 		Methods(http.MethodPost).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleResetPass)))
 
+	r.PathPrefix("/groups/{" + keyGroupID + "}/permissions").
+		Methods(http.MethodPut).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSetGroupPermissions)))
+
+	r.PathPrefix("/groups/{" + keyGroupID + "}").
+		Methods(http.MethodPut).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUpdateGroup)))
+
+	r.PathPrefix("/groups/{" + keyGroupID + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleDeleteGroup)))
+
+	r.PathPrefix("/groups").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleCreateGroup)))
+
 	r.PathPrefix("/groups").
 		Methods(http.MethodGet).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleGroups)))
 
+	r.PathPrefix("/userTypes/{" + keyUserTypeID + "}").
+		Methods(http.MethodPut).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUpdateUserType)))
+
+	r.PathPrefix("/userTypes/{" + keyUserTypeID + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleDeleteUserType)))
+
+	r.PathPrefix("/userTypes").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleCreateUserType)))
+
+	r.PathPrefix("/userTypes").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUserTypes)))
+
+	r.PathPrefix("/attributeSchema").
+		Methods(http.MethodPut).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSetAttributeSchema)))
+
+	r.PathPrefix("/attributeSchema").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleAttributeSchema)))
+
+	r.PathPrefix("/tenants").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleCreateTenant)))
+
+	r.PathPrefix("/tenants").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleTenants)))
+
+	r.PathPrefix("/lockouts/ips/{" + keyIPAddress + "}").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleIPLockout)))
+
+	r.PathPrefix("/lockouts/ips/{" + keyIPAddress + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUnlockIP)))
+
+	r.PathPrefix("/lockouts/{" + keyLoginType + "}/{" + keyIdentifier + "}").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLoginLockout)))
+
+	r.PathPrefix("/lockouts/{" + keyLoginType + "}/{" + keyIdentifier + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUnlockLogin)))
+
 	r.PathPrefix("/users/id").
 		Methods(http.MethodPost).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleIDFetch)))
 
+	r.PathPrefix("/users/import").
+		Methods(http.MethodPut).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleImportUser)))
+
 	r.PathPrefix("/users/{" + keyUserID + "}/set_group/{" + keyGroupID + "}").
 		Methods(http.MethodPost).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSetUserGroup)))
 
+	r.PathPrefix("/users/{" + keyUserID + "}/set_status/{" + keyStatus + "}").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSetUserStatus)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/identities/{" + keyLoginType + "}").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLinkIdentity)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/identities/{" + keyLoginType + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUnlinkIdentity)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/confirm").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSendConfirmCode)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/addresses/{" + keyAddress + "}/primary").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSetPrimaryAddress)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/addresses/{" + keyAddress + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleRemoveAddress)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/addresses").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleAddAddress)))
+
 	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/verify/{" + keyOTP + "}").
 		Methods(http.MethodGet).
-		HandlerFunc(s.prepLogger(s.handleVerifyCode))
+		HandlerFunc(s.prepLogger(s.linkTenant(s.handleVerifyCode)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/login/{" + keyOTP + "}").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.linkTenant(s.handleLoginByLink)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/{" + keyLoginType + "}/login/{" + keyOTP + "}").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLoginByLink)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/mfa/totp/confirm").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleConfirmTOTP)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/mfa/totp").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleEnrollTOTP)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/mfa/totp").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleResetTOTP)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/history").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLoginHistory)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/export").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleExportUser)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/attributes").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUserAttributes)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/attributes").
+		Methods(http.MethodPut).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSetUserAttributes)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/sessions/{" + keySessionID + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleRevokeSession)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/sessions").
+		Methods(http.MethodGet).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSessions)))
+
+	r.PathPrefix("/users/{" + keyUserID + "}/logout_all").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLogoutAll)))
 
 	r.PathPrefix("/users/{" + keyUserID + "}").
 		Methods(http.MethodGet).
@@ -155,10 +401,34 @@ func (s handler) handleRoute(r *mux.Router) {
 		Methods(http.MethodPost).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUpdate)))
 
+	r.PathPrefix("/users/{" + keyUserID + "}").
+		Methods(http.MethodDelete).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleDeleteUser)))
+
 	r.PathPrefix("/users").
 		Methods(http.MethodGet).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleUsers)))
 
+	r.PathPrefix("/token/refresh").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleRefresh)))
+
+	r.PathPrefix("/oauth/introspect").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleIntrospect)))
+
+	r.PathPrefix("/oauth/revoke").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleRevoke)))
+
+	r.PathPrefix("/logout").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLogout)))
+
+	r.PathPrefix("/mfa/verify").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleVerifyMFA)))
+
 	r.PathPrefix("/{" + keyLoginType + "}/register").
 		Methods(http.MethodPut).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleRegistration)))
@@ -167,6 +437,18 @@ func (s handler) handleRoute(r *mux.Router) {
 		Methods(http.MethodPost).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSendVerifCode)))
 
+	r.PathPrefix("/{" + keyLoginType + "}/login/otp/verify").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLoginByOTP)))
+
+	r.PathPrefix("/{" + keyLoginType + "}/login/otp").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSendLoginOTP)))
+
+	r.PathPrefix("/{" + keyLoginType + "}/login/link").
+		Methods(http.MethodPost).
+		HandlerFunc(s.prepLogger(s.guardRoute(s.handleSendLoginLink)))
+
 	r.PathPrefix("/{" + keyLoginType + "}/login").
 		Methods(http.MethodPost).
 		HandlerFunc(s.prepLogger(s.guardRoute(s.handleLogin)))
@@ -196,18 +478,144 @@ func (s handler) prepLogger(next http.HandlerFunc) http.HandlerFunc {
 func (s *handler) guardRoute(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		APIKey := r.Header.Get(keyAPIKey)
-		clUsrID, err := s.guard.APIKeyValid(APIKey)
+		key, err := s.guard.APIKey(APIKey)
 		log := r.Context().Value(ctxKeyLog).(logging.Logger).
-			WithField(logging.FieldClientAppUserID, clUsrID)
+			WithField(logging.FieldClientAppUserID, key.UserID)
 		ctx := context.WithValue(r.Context(), ctxKeyLog, log)
 		if err != nil {
 			s.handleError(w, r.WithContext(ctx), nil, err)
 			return
 		}
+		ctx = context.WithValue(ctx, ctxKeyAPIKey, key)
+		ctx = context.WithValue(ctx, ctxKeyTenantID, tenantID(r, key))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// linkTenant sets the tenant for requests from links sent to users, which
+// carry no API key, to the one named in the tenantID query parameter.
+// The link's token is only valid in the tenant it was issued for.
+func (s *handler) linkTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxKeyTenantID,
+			r.URL.Query().Get(keyLinkTenantID))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// tenantID returns the tenant the request r was made for. This is the
+// tenant key was issued for, or for the master key (which has no ID),
+// the tenant named in the x-tenant-id header.
+func tenantID(r *http.Request, key *api.Key) string {
+	if key.ID == "" {
+		return r.Header.Get(keyTenantID)
+	}
+	return key.TenantID
+}
+
+// auth returns the Auth for the tenant set in r's Context by guardRoute,
+// or for the default tenant if none was set.
+func (s *handler) auth(r *http.Request) Auth {
+	tenantID, _ := r.Context().Value(ctxKeyTenantID).(string)
+	return s.authFor(tenantID)
+}
+
+// parseTrustedProxies parses each of proxies as either a CIDR
+// (e.g. "10.0.0.0/8") or a single IP address.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		ip := net.ParseIP(p)
+		if ip == nil {
+			return nil, errors.Newf("invalid IP address or CIDR '%s'", p)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// isTrustedProxy returns true if addr is an IP address within one of
+// s.trustedProxies.
+func (s *handler) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range s.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address of the client that made request r.
+// This is the peer's address unless the peer is a trusted proxy, in which
+// case X-Forwarded-For is walked from the right (most recent hop) and the
+// first address not belonging to a trusted proxy is used. Entries further
+// left are client-supplied and never believed.
+func (s *handler) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !s.isTrustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values(keyForwardedFor), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !s.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// clientInfo extracts details of the client that made request r.
+// The Context in r should contain an *api.Key with key ctxKeyAPIKey
+// as set by guardRoute.
+func (s *handler) clientInfo(r *http.Request) model.ClientInfo {
+	ci := model.ClientInfo{
+		IPAddress: truncate(s.clientIP(r), maxIPAddressLen),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLen),
+		DeviceID:  r.Header.Get(keyDeviceID),
+	}
+	if key, ok := r.Context().Value(ctxKeyAPIKey).(*api.Key); ok && key != nil {
+		ci.APIKeyID = key.ID
+	}
+	return ci
+}
+
+// truncate returns the first maxLen characters of s replacing invalid
+// UTF-8 sequences, which the store would reject.
+func truncate(s string, maxLen int) string {
+	rs := []rune(strings.ToValidUTF8(s, string(utf8.RuneError)))
+	if len(rs) > maxLen {
+		rs = rs[:maxLen]
+	}
+	return string(rs)
+}
+
 // unmarshalJSONOrRespondError returns true if json is extracted from
 // data into req successfully, otherwise, it writes an error response into
 // w and returns false.
@@ -244,7 +652,7 @@ func (s *handler) unmarshalJSONOrRespondError(w http.ResponseWriter, r *http.Req
  *
  */
 func (s *handler) handleStatus(w http.ResponseWriter, r *http.Request) {
-	canRegFrst, err := s.auth.CanRegisterFirst()
+	canRegFrst, err := s.auth(r).CanRegisterFirst()
 	s.respondOn(w, r, nil, struct {
 		Name          string `json:"name"`
 		Version       string `json:"version"`
@@ -260,12 +668,40 @@ func (s *handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK, err)
 }
 
+/**
+ * @api {get} /.well-known/jwks.json JSON Web Key Set
+ * @apiName JWKS
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiDescription Public keys for verifying JWTs issued by this service.
+ * A JWT is verified using the key whose kid matches the JWT's kid header.
+ * Keys retired during rotation continue to be listed until the tokens they
+ * signed expire, so consumers should cache this set and re-fetch it when a
+ * JWT has an unknown kid.
+ * This endpoint does not require an API key.
+ *
+ * @apiSuccess {Object[]} keys The keys in JWK format (RFC 7517).
+ * @apiSuccess {String} keys.kty Key type (RSA|EC).
+ * @apiSuccess {String} keys.kid Key ID.
+ * @apiSuccess {String} keys.use Key use (sig).
+ * @apiSuccess {String} keys.alg Signing algorithm (RS256|ES256).
+ * @apiSuccess {String} [keys.n] RSA modulus.
+ * @apiSuccess {String} [keys.e] RSA exponent.
+ * @apiSuccess {String} [keys.crv] EC curve (P-256).
+ * @apiSuccess {String} [keys.x] EC x coordinate.
+ * @apiSuccess {String} [keys.y] EC y coordinate.
+ *
+ */
+func (s *handler) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	s.respondOn(w, r, nil, s.keySet.JWKS(), http.StatusOK, nil)
+}
+
 /**
  * @api {get} /users Get Users
  * @apiName GetUsers
  * @apiVersion 0.1.1
  * @apiGroup Auth
- * @apiPermission ^admin
+ * @apiPermission ^admin|users:read
  *
  * @apiHeader x-api-key the api key
  *
@@ -285,9 +721,17 @@ func (s *handler) handleStatus(w http.ResponseWriter, r *http.Request) {
  * @apiParam (URL Query Parameters) {String=true,false} [matchAllACLs=false] Setting
 	this to true will force all acl's provided to be matched using
 	the AND operator, otherwise uses the OR operator.
+ * @apiParam (URL Query Parameters) {String=active,suspended,pending} [status] Filter
+	by account status, one can have multiple statuses e.g.
+	?status=suspended&status=pending, multiple statuses are always filtered
+	using the OR operator.
+ * @apiParam (URL Query Parameters) {String} [attr.[name]] Filter by the
+	value of the custom attribute name e.g. ?attr.locale=sw, one can have
+	multiple values for an attribute e.g. ?attr.locale=en&attr.locale=sw,
+	multiple values are always filtered using the OR operator.
  * @apiParam (URL Query Parameters) {String=true,false} [matchAll=false]
-	Setting this to true will force acl,group filters to be matched using the AND
-	operator, otherwise uses the OR operator.
+	Setting this to true will force acl,group,status,attr filters to be
+	matched using the AND operator, otherwise uses the OR operator.
  * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
  *
  * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-User">users</a>
@@ -301,8 +745,10 @@ func (s *handler) handleUsers(w http.ResponseWriter, r *http.Request) {
 		Count        string   `json:"count"`
 		Groups       []string `json:"group"`
 		ACLs         []string `json:"acl"`
-		MatchAllACLs string   `json:"matchAllACLs"`
-		MatchAll     string   `json:"matchAll"`
+		Statuses     []string            `json:"status"`
+		Attributes   map[string][]string `json:"attr"`
+		MatchAllACLs string              `json:"matchAllACLs"`
+		MatchAll     string              `json:"matchAll"`
 	}{
 		JWT:          q.Get(keyToken),
 		Offset:       q.Get(keyOffset),
@@ -311,14 +757,26 @@ func (s *handler) handleUsers(w http.ResponseWriter, r *http.Request) {
 		MatchAll:     q.Get(keyMatchAll),
 		Groups:       q[keyGroup],
 		ACLs:         q[keyAcl],
+		Statuses:     q[keyStatus],
+	}
+	for key, vals := range q {
+		if !strings.HasPrefix(key, keyAttrPrefix) {
+			continue
+		}
+		if req.Attributes == nil {
+			req.Attributes = make(map[string][]string)
+		}
+		req.Attributes[strings.TrimPrefix(key, keyAttrPrefix)] = vals
 	}
 	uq := model.UsersQuery{
 		AccessLevelsIn: req.ACLs,
 		MatchAllACLs:   strings.EqualFold(req.MatchAllACLs, valTrue),
 		GroupNamesIn:   req.Groups,
+		StatusesIn:     req.Statuses,
+		AttributesIn:   req.Attributes,
 		MatchAll:       strings.EqualFold(req.MatchAll, valTrue),
 	}
-	usrs, err := s.auth.Users(req.JWT, uq, req.Offset, req.Count)
+	usrs, err := s.auth(r).Users(req.JWT, uq, req.Offset, req.Count)
 	s.respondOn(w, r, req, NewUsers(usrs), http.StatusOK, err)
 }
 
@@ -327,7 +785,7 @@ func (s *handler) handleUsers(w http.ResponseWriter, r *http.Request) {
  * @apiName UserDetails
  * @apiVersion 0.1.1
  * @apiGroup Auth
- * @apiPermission owner|^staff
+ * @apiPermission owner|^staff|users:read
  *
  * @apiHeader x-api-key the api key
  *
@@ -347,130 +805,956 @@ func (s *handler) handleUserDetails(w http.ResponseWriter, r *http.Request) {
 		UserID: mux.Vars(r)[keyUserID],
 		JWT:    r.URL.Query().Get(keyToken),
 	}
-	usr, err := s.auth.GetUserDetails(req.JWT, req.UserID)
+	usr, err := s.auth(r).GetUserDetails(req.JWT, req.UserID)
 	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
 }
 
 /**
- * @api {get} /groups Get Groups
- * @apiName GetGroups
- * @apiVersion 0.1.1
+ * @api {DELETE} /users/:userID Delete User
+ * @apiDescription Permanently delete a user's account together with all
+ * records belonging to it e.g. identifiers, sessions and login history.
+ * Users deleting their own account must re-confirm their identity using
+ * their password or a code sent to their email/phone through
+ * <a href="#api-Auth-SendConfirmationCode">Send Confirmation Code</a>.
+ * Admins cannot delete users of a higher access level.
+ * @apiName DeleteUser
+ * @apiVersion 0.1.0
  * @apiGroup Auth
- * @apiPermission ^admin
+ * @apiPermission owner|^admin|users:write
  *
  * @apiHeader x-api-key the api key
  *
- * @apiParam (URL Query Parameters) {String} token the JWT accessed during auth.
- * @apiParam (URL Query Parameters) {Number} [offset=0] The beginning index to fetch groups.
- * @apiParam (URL Query Parameters) {Number} [count=10] The maximum number of groups to fetch.
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+	<a href="#api-Objects-User">User</a> to delete.
  *
  * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
  *
- * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-Group">groups</a>
+ * @apiParam (JSON Request Body) {String=usernames,emails,phones} [confirmLoginType]
+	(owner only) usernames to confirm with the account's password or the
+	loginType of the address a confirmation code was sent to.
+ * @apiParam (JSON Request Body) {String} [confirmation] (owner only) The
+	password or confirmation code.
+ *
+ * @apiSuccess {Boolean} deleted true once the account is deleted.
  *
  */
-func (s *handler) handleGroups(w http.ResponseWriter, r *http.Request) {
+func (s *handler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserID           string `json:"userID"`
+		JWT              string `json:"token"`
+		ConfirmLoginType string `json:"confirmLoginType"`
+		Confirmation     string `json:"confirmation"`
+	}{}
+	if r.ContentLength != 0 && !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.UserID = mux.Vars(r)[keyUserID]
+	req.JWT = r.URL.Query().Get(keyToken)
+	confirmation := req.Confirmation
+	req.Confirmation = "" // prevent logging passwords.
+	err := s.auth(r).DeleteUser(s.clientInfo(r), req.JWT, req.UserID, req.ConfirmLoginType, []byte(confirmation))
+	s.respondOn(w, r, req, &struct {
+		Deleted bool `json:"deleted"`
+	}{Deleted: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {get} /users/:userID/history Login History
+ * @apiDescription Get the access history of a user's account i.e.
+ * login, password reset and verification attempts, starting with the newest.
+ * @apiName LoginHistory
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^staff|users:read
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+	<a href="#api-Objects-User">User</a> whose history is sort.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ * @apiParam (URL Query Parameters) {Number} [offset=0] The beginning index to fetch history.
+ * @apiParam (URL Query Parameters) {Number} [count=10] The maximum number of history items to fetch.
+ *
+ * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-History">history</a>
+ *
+ */
+func (s *handler) handleLoginHistory(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	req := struct {
+		UserID string `json:"userID"`
 		JWT    string `json:"token"`
 		Offset string `json:"offset"`
 		Count  string `json:"count"`
 	}{
+		UserID: mux.Vars(r)[keyUserID],
 		JWT:    q.Get(keyToken),
 		Offset: q.Get(keyOffset),
 		Count:  q.Get(keyCount),
 	}
-	grps, err := s.auth.Groups(req.JWT, req.Offset, req.Count)
-	s.respondOn(w, r, req, NewGroups(grps), http.StatusOK, err)
+	hs, err := s.auth(r).LoginHistory(req.JWT, req.UserID, req.Offset, req.Count)
+	s.respondOn(w, r, req, NewHistories(hs), http.StatusOK, err)
 }
 
 /**
- * @api {put} /first_user First User
- * @apiDescription Register the first super-user (super admin)
- * @apiPermission anyone
- * @apiName FirstUser
+ * @api {get} /users/:userID/export Export User
+ * @apiDescription Get everything held about a user i.e. their details,
+ * devices, API keys (metadata only), sessions, issued tokens and access
+ * history. Passwords and token values are never included.
+ * @apiName ExportUser
  * @apiVersion 0.1.0
- * @apiGroup Setup
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:read
  *
  * @apiHeader x-api-key the api key
  *
- * @apiParam (JSON Request Body) {String=individual,company} userType Type of user.
- * @apiParam (JSON Request Body) {String=usernames,phones,emails,facebook} loginType Type of identifier.
- * @apiParam (JSON Request Body) {String} identifier The user's unique loginType identifier.
- * @apiParam (JSON Request Body) {String} secret The users password
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+	<a href="#api-Objects-User">User</a> whose data is sort.
  *
- * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-User">User</a> for details.
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object} json-body The <a href="#api-Objects-UserExport">UserExport</a>
  *
  */
-func (s *handler) handleRegisterFirst(w http.ResponseWriter, r *http.Request) {
-	req := &struct {
-		UserType   string `json:"userType"`
-		LT         string `json:"loginType"`
-		Identifier string `json:"identifier"`
-		Secret     string `json:"secret"`
-	}{}
-	if !s.unmarshalJSONOrRespondError(w, r, req) {
-		return
+func (s *handler) handleExportUser(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    r.URL.Query().Get(keyToken),
 	}
-	usr, err := s.auth.RegisterFirst(req.LT, req.UserType, req.Identifier, []byte(req.Secret))
-	req.Secret = "" // prevent logging passwords.
-	s.respondOn(w, r, req, NewUser(usr), http.StatusCreated, err)
+	exp, err := s.auth(r).ExportUser(req.JWT, req.UserID)
+	s.respondOn(w, r, req, NewUserExport(exp), http.StatusOK, err)
 }
 
 /**
- * @api {put} /:loginType/register Register
- * @apiDescription  Register new user.
- * @apiPermission ^admin for registering other
- * @apiName Register
+ * @api {get} /users/:userID/attributes User Attributes
+ * @apiDescription Get a user's custom attributes e.g. first name or locale.
+ * @apiName UserAttributes
  * @apiVersion 0.1.0
  * @apiGroup Auth
+ * @apiPermission owner|^staff|users:read
  *
  * @apiHeader x-api-key the api key
  *
- * @apiParam (URL Parameters) {String=usernames,emails,phones,facebook} loginType type of identifier in JSON Body
- *
- * @apiParam (URL Query Parameters) {String=true,device,} selfReg Whether registering self or not:
- * - true for self registration
- * - device for self registration by unique device ID
- * - not-provided for admin to register any other user
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+ *	<a href="#api-Objects-User">User</a> whose attributes are sort.
  *
- * @apiParam (JSON Request Body) {String=individual,company} userType Type of user.
- * @apiParam (JSON Request Body) {String} identifier The 'username' corresponding to loginType.
- * @apiParam (JSON Request Body) {String} [secret] The user's password - required when selfReg set to true or device.
- * @apiParam (JSON Request Body) {String} [groupID] groupID to add this user to - required when selfReg not set.
- * @apiParam (JSON Request Body) {String} [deviceID] the unique device ID for the user - required when selfReg=device.
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
  *
- * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-User">User</a> for details.
+ * @apiSuccess {Object} json-body The user's attributes as a JSON object.
  *
  */
-func (s *handler) handleRegistration(w http.ResponseWriter, r *http.Request) {
-	req := &struct {
-		UserType   string `json:"userType"`
-		Identifier string `json:"identifier"`
-		Secret     string `json:"secret"`
-		GroupID    string `json:"groupID"`
-		DevID      string `json:"deviceID"`
-		LT         string `json:"loginType"`
-		SelfReg    string `json:"selfReg"`
-	}{}
-	if !s.unmarshalJSONOrRespondError(w, r, req) {
-		return
+func (s *handler) handleUserAttributes(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    r.URL.Query().Get(keyToken),
 	}
-	vars := mux.Vars(r)
-	req.LT = vars[keyLoginType]
-	req.SelfReg = r.URL.Query().Get(keySelfReg)
-	var usr *model.User
-	var err error
-	switch strings.ToLower(req.SelfReg) {
-	case valTrue:
-		usr, err = s.auth.RegisterSelf(req.LT, req.UserType, req.Identifier, []byte(req.Secret))
-	case valDevice:
-		usr, err = s.auth.RegisterSelfByLockedDevice(req.LT, req.UserType, req.DevID, req.Identifier, []byte(req.Secret))
-	default:
+	attrs, err := s.auth(r).UserAttributes(req.JWT, req.UserID)
+	s.respondOn(w, r, req, attrs, http.StatusOK, err)
+}
+
+/**
+ * @api {PUT} /users/:userID/attributes Set User Attributes
+ * @apiDescription Replace a user's custom attributes. The attributes must
+ * conform to the <a href="#api-Auth-AttributeSchema">attribute schema</a>,
+ * which must have been set beforehand. Only admins can change the schema's
+ * claimAttributes.
+ * @apiName SetUserAttributes
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+ *	<a href="#api-Objects-User">User</a> whose attributes are to be set.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {Object} json-body The attributes as a JSON
+ *	object e.g. {"firstName": "John", "locale": "sw"}.
+ *
+ * @apiSuccess {Object} json-body The user's attributes as a JSON object.
+ *
+ */
+func (s *handler) handleSetUserAttributes(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID     string                 `json:"userID"`
+		JWT        string                 `json:"token"`
+		Attributes map[string]interface{} `json:"attributes"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    r.URL.Query().Get(keyToken),
+	}
+	if !s.unmarshalJSONOrRespondError(w, r, &req.Attributes) {
+		return
+	}
+	attrs, err := s.auth(r).SetUserAttributes(req.JWT, req.UserID, req.Attributes)
+	s.respondOn(w, r, req, attrs, http.StatusOK, err)
+}
+
+/**
+ * @api {get} /users/:userID/sessions Sessions
+ * @apiDescription Get the sessions (successful logins) on a user's account
+ * starting with the most recently seen.
+ * @apiName Sessions
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^staff|users:read
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+	<a href="#api-Objects-User">User</a> whose sessions are sort.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ * @apiParam (URL Query Parameters) {Number} [offset=0] The beginning index to fetch sessions.
+ * @apiParam (URL Query Parameters) {Number} [count=10] The maximum number of sessions to fetch.
+ *
+ * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-Session">sessions</a>
+ *
+ */
+func (s *handler) handleSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+		Offset string `json:"offset"`
+		Count  string `json:"count"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    q.Get(keyToken),
+		Offset: q.Get(keyOffset),
+		Count:  q.Get(keyCount),
+	}
+	ss, err := s.auth(r).Sessions(req.JWT, req.UserID, req.Offset, req.Count)
+	s.respondOn(w, r, req, NewSessions(ss), http.StatusOK, err)
+}
+
+/**
+ * @api {DELETE} /users/:userID/sessions/:sessionID Revoke Session
+ * @apiDescription End one of a user's sessions. JWTs and refresh tokens
+ * issued during the session are rejected thereafter.
+ * @apiName RevokeSession
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+	<a href="#api-Objects-User">User</a> who owns the session.
+ * @apiParam (URL Parameters) {String} :sessionID The ID of the
+	<a href="#api-Objects-Session">Session</a> to end.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Boolean} revoked true once the session is ended.
+ *
+ */
+func (s *handler) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID    string `json:"userID"`
+		SessionID string `json:"sessionID"`
+		JWT       string `json:"token"`
+	}{
+		UserID:    mux.Vars(r)[keyUserID],
+		SessionID: mux.Vars(r)[keySessionID],
+		JWT:       r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).RevokeSession(req.JWT, req.UserID, req.SessionID)
+	s.respondOn(w, r, req, &struct {
+		Revoked bool `json:"revoked"`
+	}{Revoked: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/logout_all Logout Everywhere
+ * @apiDescription End all of a user's sessions. Refresh tokens issued
+ * during any of the sessions and all JWTs issued to the user so far,
+ * including those issued outside a session, are rejected thereafter.
+ * @apiName LogoutAll
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} :userID The ID of the
+	<a href="#api-Objects-User">User</a> to log out.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Boolean} loggedOut true once all sessions are ended.
+ *
+ */
+func (s *handler) handleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).LogoutAll(req.JWT, req.UserID)
+	s.respondOn(w, r, req, &struct {
+		LoggedOut bool `json:"loggedOut"`
+	}{LoggedOut: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {get} /groups Get Groups
+ * @apiName GetGroups
+ * @apiVersion 0.1.1
+ * @apiGroup Auth
+ * @apiPermission ^admin|groups:read
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT accessed during auth.
+ * @apiParam (URL Query Parameters) {Number} [offset=0] The beginning index to fetch groups.
+ * @apiParam (URL Query Parameters) {Number} [count=10] The maximum number of groups to fetch.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-Group">groups</a>
+ *
+ */
+func (s *handler) handleGroups(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := struct {
+		JWT    string `json:"token"`
+		Offset string `json:"offset"`
+		Count  string `json:"count"`
+	}{
+		JWT:    q.Get(keyToken),
+		Offset: q.Get(keyOffset),
+		Count:  q.Get(keyCount),
+	}
+	grps, err := s.auth(r).Groups(req.JWT, req.Offset, req.Count)
+	s.respondOn(w, r, req, NewGroups(grps), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /groups Create Group
+ * @apiDescription Create a custom group. The built-in group names are
+ * reserved. Admins can only create groups whose access level is at least
+ * as strict as their own.
+ * @apiName CreateGroup
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|groups:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {String} name The unique name of the group.
+ * @apiParam (JSON Request Body) {Number} accessLevel The access level of the
+ *	group in (0 >= accessLevel <= 10), 0 being the most privileged.
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-Group">Group</a> for details.
+ *
+ */
+func (s *handler) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		JWT         string  `json:"token"`
+		Name        string  `json:"name"`
+		AccessLevel float32 `json:"accessLevel"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.JWT = r.URL.Query().Get(keyToken)
+	grp, err := s.auth(r).CreateGroup(req.JWT, req.Name, req.AccessLevel)
+	var rGrp *Group
+	if err == nil {
+		rGrp = NewGroup(*grp)
+	}
+	s.respondOn(w, r, req, rGrp, http.StatusCreated, err)
+}
+
+/**
+ * @api {get} /userTypes Get User Types
+ * @apiName GetUserTypes
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^staff|userTypes:read
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ * @apiParam (URL Query Parameters) {Number} [offset=0] The beginning index to fetch user types.
+ * @apiParam (URL Query Parameters) {Number} [count=10] The maximum number of user types to fetch.
+ *
+ * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-UserType">user types</a>
+ *
+ */
+func (s *handler) handleUserTypes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := struct {
+		JWT    string `json:"token"`
+		Offset string `json:"offset"`
+		Count  string `json:"count"`
+	}{
+		JWT:    q.Get(keyToken),
+		Offset: q.Get(keyOffset),
+		Count:  q.Get(keyCount),
+	}
+	uts, err := s.auth(r).UserTypes(req.JWT, req.Offset, req.Count)
+	s.respondOn(w, r, req, NewUserTypes(uts), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /userTypes Create User Type
+ * @apiDescription Create a user type that users can be registered with.
+ * The built-in user type names (individual and company) are reserved.
+ * @apiName CreateUserType
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|userTypes:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {String} name The unique name of the user type.
+ * @apiParam (JSON Request Body) {Boolean} [selfRegistrable=false] true if users
+ *	may pick the user type when registering themselves.
+ * @apiParam (JSON Request Body) {String[]} [requiredLoginTypes] Login types
+ *	users of the type must be registered with e.g. ["emails"].
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-UserType">UserType</a> for details.
+ *
+ */
+func (s *handler) handleCreateUserType(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		JWT                string   `json:"token"`
+		Name               string   `json:"name"`
+		SelfRegistrable    bool     `json:"selfRegistrable"`
+		RequiredLoginTypes []string `json:"requiredLoginTypes"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.JWT = r.URL.Query().Get(keyToken)
+	ut, err := s.auth(r).CreateUserType(req.JWT, req.Name, req.SelfRegistrable,
+		req.RequiredLoginTypes)
+	var rUT *UserType
+	if err == nil {
+		rUT = NewUserType(*ut)
+	}
+	s.respondOn(w, r, req, rUT, http.StatusCreated, err)
+}
+
+/**
+ * @api {PUT} /userTypes/:userTypeID Update User Type
+ * @apiDescription Update a user type. Built-in user types cannot be renamed.
+ * Changes apply to users registered afterwards.
+ * @apiName UpdateUserType
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|userTypes:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userTypeID The ID of the
+ *	<a href="#api-Objects-UserType">user type</a> to update.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {String} name The unique name of the user type.
+ * @apiParam (JSON Request Body) {Boolean} [selfRegistrable=false] true if users
+ *	may pick the user type when registering themselves.
+ * @apiParam (JSON Request Body) {String[]} [requiredLoginTypes] Login types
+ *	users of the type must be registered with e.g. ["emails"].
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-UserType">UserType</a> for details.
+ *
+ */
+func (s *handler) handleUpdateUserType(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserTypeID         string   `json:"userTypeID"`
+		JWT                string   `json:"token"`
+		Name               string   `json:"name"`
+		SelfRegistrable    bool     `json:"selfRegistrable"`
+		RequiredLoginTypes []string `json:"requiredLoginTypes"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.UserTypeID = mux.Vars(r)[keyUserTypeID]
+	req.JWT = r.URL.Query().Get(keyToken)
+	ut, err := s.auth(r).UpdateUserType(req.JWT, req.UserTypeID, req.Name,
+		req.SelfRegistrable, req.RequiredLoginTypes)
+	var rUT *UserType
+	if err == nil {
+		rUT = NewUserType(*ut)
+	}
+	s.respondOn(w, r, req, rUT, http.StatusOK, err)
+}
+
+/**
+ * @api {DELETE} /userTypes/:userTypeID Delete User Type
+ * @apiDescription Delete a user type. Built-in user types and user types
+ * that still have users cannot be deleted.
+ * @apiName DeleteUserType
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|userTypes:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userTypeID The ID of the
+ *	<a href="#api-Objects-UserType">user type</a> to delete.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Boolean} deleted true once the user type is deleted.
+ *
+ */
+func (s *handler) handleDeleteUserType(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserTypeID string `json:"userTypeID"`
+		JWT        string `json:"token"`
+	}{
+		UserTypeID: mux.Vars(r)[keyUserTypeID],
+		JWT:        r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).DeleteUserType(req.JWT, req.UserTypeID)
+	s.respondOn(w, r, req, &struct {
+		Deleted bool `json:"deleted"`
+	}{Deleted: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {get} /attributeSchema Attribute Schema
+ * @apiDescription Get the JSON Schema users' custom attributes must
+ * conform to.
+ * @apiName AttributeSchema
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-AttributeSchema">AttributeSchema</a> for details.
+ *
+ */
+func (s *handler) handleAttributeSchema(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		JWT string `json:"token"`
+	}{
+		JWT: r.URL.Query().Get(keyToken),
+	}
+	as, err := s.auth(r).AttributeSchema(req.JWT)
+	s.respondOn(w, r, req, NewAttributeSchema(as), http.StatusOK, err)
+}
+
+/**
+ * @api {PUT} /attributeSchema Set Attribute Schema
+ * @apiDescription Set the JSON Schema users' custom attributes must conform
+ * to. The schema must describe an object. The supported keywords are type,
+ * properties, required, additionalProperties, items, enum, minLength,
+ * maxLength, pattern, minimum and maximum. Attributes already set are not
+ * re-validated.
+ * @apiName SetAttributeSchema
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|attributeSchema:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {Object} schema The JSON Schema e.g.
+ *	{"type": "object", "properties": {"locale": {"type": "string"}}}.
+ * @apiParam (JSON Request Body) {String[]} [claimAttributes] Names of
+ *	attributes (declared in schema's properties) to include in JWTs
+ *	issued to users. Users cannot change their own claimAttributes.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-AttributeSchema">AttributeSchema</a> for details.
+ *
+ */
+func (s *handler) handleSetAttributeSchema(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		JWT             string          `json:"token"`
+		Schema          json.RawMessage `json:"schema"`
+		ClaimAttributes []string        `json:"claimAttributes"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.JWT = r.URL.Query().Get(keyToken)
+	as, err := s.auth(r).SetAttributeSchema(req.JWT, req.Schema, req.ClaimAttributes)
+	s.respondOn(w, r, req, NewAttributeSchema(as), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /tenants Create Tenant
+ * @apiDescription Create a tenant (organisation) whose users, groups and
+ * API keys are kept apart from those of other tenants. Requests are made
+ * for a tenant by using an API key issued to one of its users, or the
+ * master API key together with the x-tenant-id header.
+ * @apiName CreateTenant
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission tenants:all
+ *
+ * @apiHeader x-api-key the api key
+ * @apiHeader [x-tenant-id] the tenant to act on when x-api-key is the master key.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {String} name The unique name of the tenant.
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-Tenant">Tenant</a> for details.
+ *
+ */
+func (s *handler) handleCreateTenant(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		JWT  string `json:"token"`
+		Name string `json:"name"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.JWT = r.URL.Query().Get(keyToken)
+	t, err := s.auth(r).CreateTenant(req.JWT, req.Name)
+	var rT *Tenant
+	if err == nil {
+		rT = NewTenant(*t)
+	}
+	s.respondOn(w, r, req, rT, http.StatusCreated, err)
+}
+
+/**
+ * @api {get} /tenants Get Tenants
+ * @apiName GetTenants
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission tenants:all
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ * @apiParam (URL Query Parameters) {Number} [offset=0] The beginning index to fetch tenants.
+ * @apiParam (URL Query Parameters) {Number} [count=10] The maximum number of tenants to fetch.
+ *
+ * @apiSuccess {Object[]} json-body JSON array of <a href="#api-Objects-Tenant">tenants</a>
+ *
+ */
+func (s *handler) handleTenants(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := struct {
+		JWT    string `json:"token"`
+		Offset string `json:"offset"`
+		Count  string `json:"count"`
+	}{
+		JWT:    q.Get(keyToken),
+		Offset: q.Get(keyOffset),
+		Count:  q.Get(keyCount),
+	}
+	ts, err := s.auth(r).Tenants(req.JWT, req.Offset, req.Count)
+	s.respondOn(w, r, req, NewTenants(ts), http.StatusOK, err)
+}
+
+/**
+ * @api {PUT} /groups/:groupID Update Group
+ * @apiDescription Rename a custom group or change its access level.
+ * Built-in groups cannot be updated.
+ * @apiName UpdateGroup
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|groups:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} groupID The ID of the
+ *	<a href="#api-Objects-Group">group</a> to update.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {String} name The unique name of the group.
+ * @apiParam (JSON Request Body) {Number} accessLevel The access level of the
+ *	group in (0 >= accessLevel <= 10), 0 being the most privileged.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-Group">Group</a> for details.
+ *
+ */
+func (s *handler) handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		GroupID     string  `json:"groupID"`
+		JWT         string  `json:"token"`
+		Name        string  `json:"name"`
+		AccessLevel float32 `json:"accessLevel"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.GroupID = mux.Vars(r)[keyGroupID]
+	req.JWT = r.URL.Query().Get(keyToken)
+	grp, err := s.auth(r).UpdateGroup(req.JWT, req.GroupID, req.Name, req.AccessLevel)
+	var rGrp *Group
+	if err == nil {
+		rGrp = NewGroup(*grp)
+	}
+	s.respondOn(w, r, req, rGrp, http.StatusOK, err)
+}
+
+/**
+ * @api {PUT} /groups/:groupID/permissions Set Group Permissions
+ * @apiDescription Replace the permissions granted to members of a custom
+ * group. Permissions let members carry out actions their group's access
+ * level would otherwise not allow. Only permissions held by the updater
+ * can be granted. Members receive the new permissions in JWTs issued
+ * after the update.
+ * @apiName SetGroupPermissions
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|groups:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} groupID The ID of the
+ *	<a href="#api-Objects-Group">group</a> to update.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiParam (JSON Request Body) {String[]=users:read,users:write,groups:read,groups:write,lockouts:read,lockouts:write} permissions
+ *	The permissions to grant. An empty list revokes all permissions.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-Group">Group</a> for details.
+ *
+ */
+func (s *handler) handleSetGroupPermissions(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		GroupID     string   `json:"groupID"`
+		JWT         string   `json:"token"`
+		Permissions []string `json:"permissions"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.GroupID = mux.Vars(r)[keyGroupID]
+	req.JWT = r.URL.Query().Get(keyToken)
+	grp, err := s.auth(r).SetGroupPermissions(req.JWT, req.GroupID, req.Permissions)
+	var rGrp *Group
+	if err == nil {
+		rGrp = NewGroup(*grp)
+	}
+	s.respondOn(w, r, req, rGrp, http.StatusOK, err)
+}
+
+/**
+ * @api {DELETE} /groups/:groupID Delete Group
+ * @apiDescription Delete a custom group. Built-in groups and groups that
+ * still have users cannot be deleted.
+ * @apiName DeleteGroup
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|groups:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} groupID The ID of the
+ *	<a href="#api-Objects-Group">group</a> to delete.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Boolean} deleted true once the group is deleted.
+ *
+ */
+func (s *handler) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		GroupID string `json:"groupID"`
+		JWT     string `json:"token"`
+	}{
+		GroupID: mux.Vars(r)[keyGroupID],
+		JWT:     r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).DeleteGroup(req.JWT, req.GroupID)
+	s.respondOn(w, r, req, &struct {
+		Deleted bool `json:"deleted"`
+	}{Deleted: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {put} /first_user First User
+ * @apiDescription Register the first super-user (super admin)
+ * @apiPermission anyone
+ * @apiName FirstUser
+ * @apiVersion 0.1.0
+ * @apiGroup Setup
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (JSON Request Body) {String} userType Name of the user's
+ *	<a href="#api-Objects-UserType">user type</a> e.g. individual or company.
+ * @apiParam (JSON Request Body) {String=usernames,phones,emails,facebook} loginType Type of identifier.
+ * @apiParam (JSON Request Body) {String} identifier The user's unique loginType identifier.
+ * @apiParam (JSON Request Body) {String} secret The users password
+ *
+ * @apiError (400) PasswordPolicyError The password does not satisfy the
+ *	password policy. See <a href="#api-Objects-PasswordPolicyError">PasswordPolicyError</a>.
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-User">User</a> for details.
+ *
+ */
+func (s *handler) handleRegisterFirst(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserType   string `json:"userType"`
+		LT         string `json:"loginType"`
+		Identifier string `json:"identifier"`
+		Secret     string `json:"secret"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	usr, err := s.auth(r).RegisterFirst(req.LT, req.UserType, req.Identifier, []byte(req.Secret))
+	req.Secret = "" // prevent logging passwords.
+	s.respondOn(w, r, req, NewUser(usr), http.StatusCreated, err)
+}
+
+/**
+ * @api {put} /:loginType/register Register
+ * @apiDescription  Register new user.
+ * @apiPermission ^admin|users:write for registering other
+ * @apiName Register
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String=usernames,emails,phones,facebook,oidc} loginType type of identifier in JSON Body
+ *
+ * @apiParam (URL Query Parameters) {String=true,device,} selfReg Whether registering self or not:
+ * - true for self registration
+ * - device for self registration by unique device ID
+ * - not-provided for admin to register any other user
+ *
+ * @apiParam (JSON Request Body) {String} userType Name of the user's
+ *	<a href="#api-Objects-UserType">user type</a> e.g. individual or company.
+ * @apiParam (JSON Request Body) {String} identifier The 'username' corresponding to loginType.
+ * @apiParam (JSON Request Body) {String} [secret] The user's password - required when selfReg set to true or device.
+	For the oidc loginType, identifier is the id_token and secret is the nonce the id_token was requested with.
+ * @apiParam (JSON Request Body) {String} [groupID] groupID to add this user to - required when selfReg not set.
+ * @apiParam (JSON Request Body) {String} [deviceID] the unique device ID for the user - required when selfReg=device.
+ *
+ * @apiError (400) PasswordPolicyError The password does not satisfy the
+ *	password policy. See <a href="#api-Objects-PasswordPolicyError">PasswordPolicyError</a>.
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-User">User</a> for details.
+ *
+ */
+func (s *handler) handleRegistration(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserType   string `json:"userType"`
+		Identifier string `json:"identifier"`
+		Secret     string `json:"secret"`
+		GroupID    string `json:"groupID"`
+		DevID      string `json:"deviceID"`
+		LT         string `json:"loginType"`
+		SelfReg    string `json:"selfReg"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	vars := mux.Vars(r)
+	req.LT = vars[keyLoginType]
+	req.SelfReg = r.URL.Query().Get(keySelfReg)
+	var usr *model.User
+	var err error
+	switch strings.ToLower(req.SelfReg) {
+	case valTrue:
+		usr, err = s.auth(r).RegisterSelf(req.LT, req.UserType, req.Identifier, []byte(req.Secret))
+	case valDevice:
+		usr, err = s.auth(r).RegisterSelfByLockedDevice(req.LT, req.UserType, req.DevID, req.Identifier, []byte(req.Secret))
+	default:
 		JWT := r.URL.Query().Get(keyToken)
-		usr, err = s.auth.RegisterOther(JWT, req.LT, req.UserType, req.Identifier, req.GroupID)
+		usr, err = s.auth(r).RegisterOther(JWT, req.LT, req.UserType, req.Identifier, req.GroupID)
+	}
+	req.Secret = "" // prevent logging passwords.
+	s.respondOn(w, r, req, NewUser(usr), http.StatusCreated, err)
+}
+
+/**
+ * @api {put} /users/import Import User
+ * @apiDescription Import a user migrated from another system together with
+ * the password hash that system stored for them. The user logs in with
+ * their existing password, after which the hash is upgraded to the native
+ * format. No verification codes are sent to the user.
+ * @apiPermission ^admin|users:write
+ * @apiName ImportUser
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiParam (JSON Request Body) {String} userType Name of the user's
+ *	<a href="#api-Objects-UserType">user type</a> e.g. individual or company.
+ * @apiParam (JSON Request Body) {String} groupID groupID to add this user to.
+ * @apiParam (JSON Request Body) {String} [username] The user's username.
+ * @apiParam (JSON Request Body) {String} [email] The user's email address.
+ * @apiParam (JSON Request Body) {Boolean} [emailVerified=false] Whether email
+ *	was verified by the other system.
+ * @apiParam (JSON Request Body) {String} [phone] The user's phone number.
+ * @apiParam (JSON Request Body) {Boolean} [phoneVerified=false] Whether phone
+ *	was verified by the other system.
+ * @apiParam (JSON Request Body) {String=pbkdf2_sha256,firebase_scrypt,sha512_crypt} passwordHashAlgorithm
+ *	The algorithm that produced passwordHash:
+ *	- pbkdf2_sha256 Django's default e.g. 'pbkdf2_sha256$260000$salt$hash'
+ *	- firebase_scrypt the passwordHash from a Firebase users export.
+ *	- sha512_crypt crypt(3) SHA-512 e.g. '$6$salt$hash'
+ * @apiParam (JSON Request Body) {String} passwordHash The password hash as
+ *	stored by the other system.
+ * @apiParam (JSON Request Body) {String} [passwordSalt] The base64 salt from
+ *	a Firebase users export - required for firebase_scrypt.
+ *
+ * At least one of username, email and phone is required.
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-User">User</a> for details.
+ *
+ */
+func (s *handler) handleImportUser(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		JWT               string `json:"token"`
+		UserType          string `json:"userType"`
+		GroupID           string `json:"groupID"`
+		Username          string `json:"username"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"emailVerified"`
+		Phone             string `json:"phone"`
+		PhoneVerified     bool   `json:"phoneVerified"`
+		PassHashAlgorithm string `json:"passwordHashAlgorithm"`
+		PassHash          string `json:"passwordHash"`
+		PassSalt          string `json:"passwordSalt"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
 	}
-	req.Secret = "" // prevent logging passwords.
+	req.JWT = r.URL.Query().Get(keyToken)
+	usr, err := s.auth(r).ImportUser(req.JWT, model.UserImport{
+		UserType:          req.UserType,
+		GroupID:           req.GroupID,
+		Username:          req.Username,
+		Email:             req.Email,
+		EmailVerified:     req.EmailVerified,
+		Phone:             req.Phone,
+		PhoneVerified:     req.PhoneVerified,
+		PassHashAlgorithm: req.PassHashAlgorithm,
+		PassHash:          req.PassHash,
+		PassSalt:          req.PassSalt,
+	})
+	req.PassHash, req.PassSalt = "", "" // prevent logging password hashes.
 	s.respondOn(w, r, req, NewUser(usr), http.StatusCreated, err)
 }
 
@@ -478,6 +1762,8 @@ func (s *handler) handleRegistration(w http.ResponseWriter, r *http.Request) {
  * @api {POST} /:loginType/login Login
  * @apiDescription User login.
  * See <a href="#api-Auth-Register">Register</a> for loginType options.
+ * Repeated failed login attempts for an identifier or from an IP address
+ * result in a temporary lockout (403) - see <a href="#api-Auth-LoginLockout">Login Lockout</a>.
  * @apiName Login
  * @apiVersion 0.1.0
  * @apiGroup Auth
@@ -485,8 +1771,16 @@ func (s *handler) handleRegistration(w http.ResponseWriter, r *http.Request) {
  * @apiHeader x-api-key the api key
  * @apiHeader Authorization Basic auth containing loginType's identifier and password in the format
 	'Basic: base64Of(identifier:password)'
+ * @apiHeader [x-device-id] The ID of the device the client app runs on, recorded
+	against the <a href="#api-Objects-Session">session</a> started by the login.
  *
- * @apiParam (URL Parameters) {String=usernames,emails,phones,facebook} loginType type of identifier in Authorization header.
+ * @apiParam (URL Parameters) {String=usernames,emails,phones,facebook,oidc} loginType type of identifier in Authorization header.
+	For the oidc loginType, the identifier is an id_token from a configured
+	OpenID Connect provider and the password is the nonce the id_token was requested with.
+ *
+ * @apiSuccess {String} [MFAToken] Provided instead of the JWT if the user has
+ *	two-factor authentication enabled. Exchange it for a JWT using
+ *	<a href="#api-Auth-VerifyMFA">Verify MFA</a>.
  *
  * @apiUse User
  *
@@ -506,8 +1800,445 @@ func (s *handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		s.handleError(w, r, req, err)
 		return
 	}
-	usr, err := s.auth.Login(req.LT, req.Identifier, []byte(secret))
-	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+	usr, err := s.auth(r).Login(s.clientInfo(r), req.LT, req.Identifier, []byte(secret))
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /:loginType/login/link Send Login Link
+ * @apiDescription Send a one time login link to a user's identifier.
+ * Opening the link (or posting its token to
+ * <a href="#api-Auth-LoginByLink">Login By Link</a>) logs the user in.
+ * The link only works together with the nonce returned here, which is also
+ * set as an HttpOnly cookie so that the link works when opened from the
+ * requesting browser.
+ * Only a limited number of links are sent to an identifier within a period,
+ * further requests are rejected (403) until the period elapses.
+ * @apiName SendLoginLink
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String=emails} loginType type of identifier in JSON Body.
+ *
+ * @apiParam (JSON Request Body) {String} identifier The loginType's address to send the link to.
+ *
+ * @apiUse OTPStatus
+ * @apiSuccess {String} nonce The nonce to present with the link's token.
+ *
+ */
+func (s *handler) handleSendLoginLink(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		LT     string `json:"loginType"`
+		ToAddr string `json:"identifier"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.LT = mux.Vars(r)[keyLoginType]
+	dbtStatus, err := s.auth(r).SendLoginLink(req.LT, req.ToAddr)
+	if err == nil {
+		http.SetCookie(w, &http.Cookie{
+			Name:     keyLoginNonce,
+			Value:    dbtStatus.Nonce,
+			Path:     config.WebRootURL(),
+			Expires:  dbtStatus.ExpiresAt,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+		})
+	}
+	s.respondOn(w, r, req, NewDBTStatus(dbtStatus), http.StatusOK, err)
+}
+
+/**
+ * @api {GET|POST} /users/:userID/:loginType/login/:OTP Login By Link
+ * @apiDescription Log in using a link sent by
+ * <a href="#api-Auth-SendLoginLink">Send Login Link</a>.
+ * A GET request (opening the link) reads the nonce from the cookie set
+ * when the link was requested. A POST request may provide the nonce in the
+ * JSON body instead and requires the api key.
+ * The link can only be used once.
+ * @apiName LoginByLink
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader [x-api-key] the api key (POST only)
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the user logging in.
+ * @apiParam (URL Parameters) {String=emails} loginType type of identifier the link was sent to.
+ * @apiParam (URL Parameters) {String} OTP The token in the login link.
+ *
+ * @apiParam (URL Query Parameters) {String} [tenantID] The tenant the link
+ *	was issued for, as included in the link (GET only).
+ *
+ * @apiParam (JSON Request Body) {String} [nonce] The nonce returned by
+ *	<a href="#api-Auth-SendLoginLink">Send Login Link</a> (POST only).
+ *
+ * @apiSuccess {String} [MFAToken] Provided instead of the JWT if the user has
+ *	two-factor authentication enabled. Exchange it for a JWT using
+ *	<a href="#api-Auth-VerifyMFA">Verify MFA</a>.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleLoginByLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := &struct {
+		UserID string `json:"userID"`
+		LT     string `json:"loginType"`
+		Nonce  string `json:"nonce"`
+	}{}
+	if r.Method == http.MethodPost && r.ContentLength != 0 &&
+		!s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.UserID = vars[keyUserID]
+	req.LT = vars[keyLoginType]
+	if req.Nonce == "" {
+		if c, err := r.Cookie(keyLoginNonce); err == nil {
+			req.Nonce = c.Value
+		}
+	}
+	nonce := req.Nonce
+	req.Nonce = "" // prevent logging nonces.
+	usr, err := s.auth(r).LoginByLink(s.clientInfo(r), req.LT, req.UserID, nonce, []byte(vars[keyOTP]))
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /:loginType/login/otp Send Login OTP
+ * @apiDescription Send a one time login code to a user's identifier.
+ * Exchange the code for a JWT using
+ * <a href="#api-Auth-LoginByOTP">Login By OTP</a>.
+ * Only a limited number of codes are sent to an identifier within a period,
+ * further requests are rejected (403) until the period elapses.
+ * @apiName SendLoginOTP
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String=phones} loginType type of identifier in JSON Body.
+ *
+ * @apiParam (JSON Request Body) {String} identifier The loginType's address to send the code to.
+ *
+ * @apiUse OTPStatus
+ *
+ */
+func (s *handler) handleSendLoginOTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		LT     string `json:"loginType"`
+		ToAddr string `json:"identifier"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.LT = mux.Vars(r)[keyLoginType]
+	dbtStatus, err := s.auth(r).SendLoginOTP(req.LT, req.ToAddr)
+	s.respondOn(w, r, req, NewDBTStatus(dbtStatus), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /:loginType/login/otp/verify Login By OTP
+ * @apiDescription Log in using a code sent by
+ * <a href="#api-Auth-SendLoginOTP">Send Login OTP</a>.
+ * Failed attempts count towards lockouts as with <a href="#api-Auth-Login">Login</a>.
+ * @apiName LoginByOTP
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ * @apiHeader [x-device-id] The ID of the device the client app runs on, recorded
+	against the <a href="#api-Objects-Session">session</a> started by the login.
+ *
+ * @apiParam (URL Parameters) {String=phones} loginType type of identifier in JSON Body.
+ *
+ * @apiParam (JSON Request Body) {String} identifier The loginType's address the code was sent to.
+ * @apiParam (JSON Request Body) {String} OTP The code sent.
+ *
+ * @apiSuccess {String} [MFAToken] Provided instead of the JWT if the user has
+ *	two-factor authentication enabled. Exchange it for a JWT using
+ *	<a href="#api-Auth-VerifyMFA">Verify MFA</a>.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleLoginByOTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		LT         string `json:"loginType"`
+		Identifier string `json:"identifier"`
+		OTP        string `json:"OTP"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.LT = mux.Vars(r)[keyLoginType]
+	otp := req.OTP
+	req.OTP = "" // prevent logging codes.
+	usr, err := s.auth(r).LoginByOTP(s.clientInfo(r), req.LT, req.Identifier, []byte(otp))
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /token/refresh Refresh Token
+ * @apiDescription Exchange a refresh token for a new JWT and refresh token.
+ * The refresh token provided is invalidated. Reusing an invalidated refresh
+ * token revokes all refresh tokens descending from the same
+ * <a href="#api-Auth-Login">Login</a>.
+ * A refresh token can only be exchanged using the API key it was issued to.
+ * @apiName RefreshToken
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (JSON Request Body) {String} refreshToken the refresh token received during
+ *	<a href="#api-Auth-Login">Login</a> or a previous refresh.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		RefreshToken string `json:"refreshToken"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	usr, err := s.auth(r).Refresh(s.clientInfo(r), req.RefreshToken)
+	req.RefreshToken = "" // prevent logging tokens.
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /oauth/introspect Introspect Token
+ * @apiDescription Determine whether a JWT is active i.e. was issued by this
+ * service, has not expired and has not been
+ * <a href="#api-Auth-RevokeToken">revoked</a>, as described in RFC 7662.
+ * An inactive token is not an error.
+ * @apiName IntrospectToken
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (JSON Request Body) {String} token the JWT to introspect.
+ *
+ * @apiSuccess {Object} json Object with
+ *	<a href="#api-Objects-TokenIntrospection">TokenIntrospection</a> details.
+ *
+ */
+func (s *handler) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		Token string `json:"token"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	ti, err := s.auth(r).Introspect(req.Token)
+	req.Token = "" // prevent logging tokens.
+	s.respondOn(w, r, req, NewTokenIntrospection(ti), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /oauth/revoke Revoke Token
+ * @apiDescription Revoke a JWT or a refresh token as described in RFC 7009.
+ * A revoked JWT is rejected by all endpoints until it expires.
+ * Revoking a refresh token revokes all refresh tokens descending from the
+ * same <a href="#api-Auth-Login">Login</a>. A refresh token can only be
+ * revoked using the API key it was issued to.
+ * Revoking an invalid, expired or already revoked token succeeds.
+ * @apiName RevokeToken
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (JSON Request Body) {String} token the JWT or refresh token to revoke.
+ *
+ * @apiSuccess {Boolean} revoked true once the token is revoked.
+ *
+ */
+func (s *handler) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		Token string `json:"token"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	err := s.auth(r).Revoke(s.clientInfo(r), req.Token)
+	req.Token = "" // prevent logging tokens.
+	s.respondOn(w, r, req, &struct {
+		Revoked bool `json:"revoked"`
+	}{Revoked: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /logout Logout
+ * @apiDescription End the session during which the JWT was issued. The JWT
+ * and any other JWT or refresh token issued during the session are
+ * rejected thereafter.
+ * @apiName Logout
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Boolean} loggedOut true once the session is ended.
+ *
+ */
+func (s *handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		JWT string `json:"token"`
+	}{
+		JWT: r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).Logout(req.JWT)
+	s.respondOn(w, r, req, &struct {
+		LoggedOut bool `json:"loggedOut"`
+	}{LoggedOut: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /mfa/verify Verify MFA
+ * @apiDescription Complete a <a href="#api-Auth-Login">Login</a> for a user
+ * with two-factor authentication enabled by exchanging the MFAToken
+ * received during login and the current code from the user's authenticator
+ * app for a JWT.
+ * Repeated failed attempts result in a temporary lockout (403).
+ * @apiName VerifyMFA
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (JSON Request Body) {String} MFAToken the MFAToken received during
+ *	<a href="#api-Auth-Login">Login</a>.
+ * @apiParam (JSON Request Body) {String} code the code from the user's authenticator app.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleVerifyMFA(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		MFAToken string `json:"MFAToken"`
+		Code     string `json:"code"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	usr, err := s.auth(r).VerifyMFA(s.clientInfo(r), req.MFAToken, req.Code)
+	req.MFAToken, req.Code = "", "" // prevent logging tokens.
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/mfa/totp Enroll TOTP
+ * @apiDescription Begin enrollment of an authenticator app for two-factor
+ * authentication. Two-factor authentication is only enabled once the
+ * enrollment is confirmed - see <a href="#api-Auth-ConfirmTOTP">Confirm TOTP</a>.
+ * Enrolling again before confirming replaces the previous secret.
+ * @apiName EnrollTOTP
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the
+ *	<a href="#api-Objects-User">user</a> enrolling.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiSuccess (Success 201) {Object} json-body See <a href="#api-Objects-TOTPEnrollment">TOTPEnrollment</a> for details.
+ *
+ */
+func (s *handler) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    r.URL.Query().Get(keyToken),
+	}
+	te, err := s.auth(r).EnrollTOTP(req.JWT, req.UserID)
+	s.respondOn(w, r, req, NewTOTPEnrollment(te), http.StatusCreated, err)
+}
+
+/**
+ * @api {POST} /users/:userID/mfa/totp/confirm Confirm TOTP
+ * @apiDescription Enable two-factor authentication by providing the first
+ * code generated by the authenticator app set up during
+ * <a href="#api-Auth-EnrollTOTP">Enroll TOTP</a>.
+ * @apiName ConfirmTOTP
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the
+ *	<a href="#api-Objects-User">user</a> enrolling.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiParam (JSON Request Body) {String} code the code from the user's authenticator app.
+ *
+ * @apiSuccess {Boolean} MFAEnabled true once two-factor authentication is enabled.
+ *
+ */
+func (s *handler) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+		Code   string `json:"code"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	req.UserID = mux.Vars(r)[keyUserID]
+	req.JWT = r.URL.Query().Get(keyToken)
+	err := s.auth(r).ConfirmTOTP(req.JWT, req.UserID, req.Code)
+	req.Code = "" // prevent logging codes.
+	s.respondOn(w, r, req, &struct {
+		MFAEnabled bool `json:"MFAEnabled"`
+	}{MFAEnabled: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {DELETE} /users/:userID/mfa/totp Reset TOTP
+ * @apiDescription Disable two-factor authentication for a user e.g. after
+ * they lose their authenticator device.
+ * @apiName ResetTOTP
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission ^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the
+ *	<a href="#api-Objects-User">user</a> whose two-factor authentication is to be reset.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiSuccess {Boolean} MFAEnabled false once two-factor authentication is disabled.
+ *
+ */
+func (s *handler) handleResetTOTP(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID string `json:"userID"`
+		JWT    string `json:"token"`
+	}{
+		UserID: mux.Vars(r)[keyUserID],
+		JWT:    r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).ResetTOTP(req.JWT, req.UserID)
+	s.respondOn(w, r, req, &struct {
+		MFAEnabled bool `json:"MFAEnabled"`
+	}{MFAEnabled: err != nil}, http.StatusOK, err)
 }
 
 /**
@@ -534,7 +2265,7 @@ func (s *handler) handleIDFetch(w http.ResponseWriter, r *http.Request) {
 	if !s.unmarshalJSONOrRespondError(w, r, &req) {
 		return
 	}
-	usrID, err := s.auth.UserID(req.LT, req.Identifier)
+	usrID, err := s.auth(r).UserID(req.LT, req.Identifier)
 	s.respondOn(w, r, req, &struct {
 		UserID string `json:"userID,omitempty"`
 	}{UserID: usrID}, http.StatusOK, err)
@@ -571,7 +2302,7 @@ func (s *handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	req.JWT = r.URL.Query().Get(keyToken)
 	req.UserID = mux.Vars(r)[keyUserID]
-	usr, err := s.auth.UpdateIdentifier(req.JWT, req.UserID, req.LT, req.Identifier)
+	usr, err := s.auth(r).UpdateIdentifier(req.JWT, req.UserID, req.LT, req.Identifier)
 	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
 }
 
@@ -580,7 +2311,7 @@ func (s *handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
  * @apiDescription Assign group to user.
  * @apiName SetUserGroup
  * @apiVersion 0.1.0
- * @apiPermission ^admin
+ * @apiPermission ^admin|users:write
  * @apiGroup Auth
  *
  * @apiHeader x-api-key the api key
@@ -604,10 +2335,263 @@ func (s *handler) handleSetUserGroup(w http.ResponseWriter, r *http.Request) {
 		GroupID: vars[keyGroupID],
 		JWT:     r.URL.Query().Get(keyToken),
 	}
-	usr, err := s.auth.SetUserGroup(req.JWT, req.UserID, req.GroupID)
+	usr, err := s.auth(r).SetUserGroup(req.JWT, req.UserID, req.GroupID)
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/set_status/:status Set User's Status
+ * @apiDescription Suspend, reinstate or mark pending a user's account.
+ * A suspended user can neither log in nor use tokens already issued to them.
+ * Setting the status to active reinstates the user.
+ * @apiName SetUserStatus
+ * @apiVersion 0.1.0
+ * @apiPermission ^admin|users:write
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a> to update.
+ * @apiParam (URL Parameters) {String=active,suspended,pending} status The status to set.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ * @apiParam (URL Query Parameters) {String{..256}} [reason] Why the status was set.
+ * @apiParam (URL Query Parameters) {String} [until] ISO8601 date after which
+ *	the status lapses back to active.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleSetUserStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	q := r.URL.Query()
+	req := &struct {
+		UserID string `json:"userID"`
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+		Until  string `json:"until"`
+		JWT    string `json:"token"`
+	}{
+		UserID: vars[keyUserID],
+		Status: vars[keyStatus],
+		Reason: q.Get(keyReason),
+		Until:  q.Get(keyUntil),
+		JWT:    q.Get(keyToken),
+	}
+	usr, err := s.auth(r).SetUserStatus(req.JWT, req.UserID, req.Status, req.Reason, req.Until)
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/:loginType/addresses Add Address
+ * @apiDescription Add an email or phone to a user's account alongside their
+ * primary. A verification code is sent to the address if the notification
+ * method is available. The address cannot be used to log in until it is
+ * verified (see <a href="#api-Auth-VerifyOTP">Verify OTP</a>).
+ * @apiName AddAddress
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a> to update.
+ * @apiParam (URL Parameters) {String=emails,phones} loginType The type of address to add.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiParam (JSON Request Body) {String} address The email or phone to add.
+ *
+ * @apiUse VerifLogin
+ *
+ */
+func (s *handler) handleAddAddress(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserID  string `json:"userID"`
+		LT      string `json:"loginType"`
+		Address string `json:"address"`
+		JWT     string `json:"token"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	vars := mux.Vars(r)
+	req.UserID = vars[keyUserID]
+	req.LT = vars[keyLoginType]
+	req.JWT = r.URL.Query().Get(keyToken)
+	vl, err := s.auth(r).AddAddress(req.JWT, req.UserID, req.LT, req.Address)
+	s.respondOn(w, r, req, NewVerifLogin(vl), http.StatusCreated, err)
+}
+
+/**
+ * @api {DELETE} /users/:userID/:loginType/addresses/:address Remove Address
+ * @apiDescription Remove an email or phone from a user's account together
+ * with any codes sent to it. The primary address cannot be removed,
+ * <a href="#api-Auth-SetPrimaryAddress">set another address as primary</a> first.
+ * @apiName RemoveAddress
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a> to update.
+ * @apiParam (URL Parameters) {String=emails,phones} loginType The type of address to remove.
+ * @apiParam (URL Parameters) {String} address The email or phone to remove.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiSuccess {Boolean} removed true once the address is removed.
+ *
+ */
+func (s *handler) handleRemoveAddress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := &struct {
+		UserID  string `json:"userID"`
+		LT      string `json:"loginType"`
+		Address string `json:"address"`
+		JWT     string `json:"token"`
+	}{
+		UserID:  vars[keyUserID],
+		LT:      vars[keyLoginType],
+		Address: vars[keyAddress],
+		JWT:     r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).RemoveAddress(req.JWT, req.UserID, req.LT, req.Address)
+	s.respondOn(w, r, req, &struct {
+		Removed bool `json:"removed"`
+	}{Removed: err == nil}, http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/:loginType/addresses/:address/primary Set Primary Address
+ * @apiDescription Make one of a user's verified emails or phones their
+ * primary in place of the current one.
+ * @apiName SetPrimaryAddress
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a> to update.
+ * @apiParam (URL Parameters) {String=emails,phones} loginType The type of address.
+ * @apiParam (URL Parameters) {String} address The email or phone to make primary.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleSetPrimaryAddress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := &struct {
+		UserID  string `json:"userID"`
+		LT      string `json:"loginType"`
+		Address string `json:"address"`
+		JWT     string `json:"token"`
+	}{
+		UserID:  vars[keyUserID],
+		LT:      vars[keyLoginType],
+		Address: vars[keyAddress],
+		JWT:     r.URL.Query().Get(keyToken),
+	}
+	usr, err := s.auth(r).SetPrimaryAddress(req.JWT, req.UserID, req.LT, req.Address)
+	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/identities/:loginType Link Identity
+ * @apiDescription Add a way to log in to an existing account e.g. link a
+ * facebook account to a user who registered by phone. The identifier is
+ * validated as during <a href="#api-Auth-Register">Registration</a>.
+ * Only one username, email, phone and facebook account can be linked
+ * (see <a href="#api-Auth-AddAddress">Add Address</a> for more emails and
+ * phones) while any number of OpenID Connect identities can.
+ * @apiName LinkIdentity
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a> to update.
+ * @apiParam (URL Parameters) {String=usernames,emails,phones,facebook,oidc} loginType
+ *	The type of identity to link.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiParam (JSON Request Body) {String} identifier The loginType's identifier
+ *	e.g. the facebook token or OpenID Connect id_token.
+ * @apiParam (JSON Request Body) {String} [secret] The password to log in with
+ *	when linking a username (it replaces the user's password) or the nonce
+ *	the OpenID Connect id_token was requested with.
+ *
+ * @apiError (400) PasswordPolicyError The password does not satisfy the
+ *	password policy. See <a href="#api-Objects-PasswordPolicyError">PasswordPolicyError</a>.
+ *
+ * @apiUse User
+ *
+ */
+func (s *handler) handleLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		UserID     string `json:"userID"`
+		LT         string `json:"loginType"`
+		Identifier string `json:"identifier"`
+		Secret     string `json:"secret"`
+		JWT        string `json:"token"`
+	}{}
+	if !s.unmarshalJSONOrRespondError(w, r, req) {
+		return
+	}
+	vars := mux.Vars(r)
+	req.UserID = vars[keyUserID]
+	req.LT = vars[keyLoginType]
+	req.JWT = r.URL.Query().Get(keyToken)
+	usr, err := s.auth(r).LinkIdentity(req.JWT, req.UserID, req.LT, req.Identifier, []byte(req.Secret))
+	req.Secret = "" // prevent logging passwords.
 	s.respondOn(w, r, req, NewUser(usr), http.StatusOK, err)
 }
 
+/**
+ * @api {DELETE} /users/:userID/identities/:loginType Unlink Identity
+ * @apiDescription Remove a user's username, facebook account or OpenID
+ * Connect identities. This is refused if the user would be left with no way
+ * to log in. Emails and phones are removed through
+ * <a href="#api-Auth-RemoveAddress">Remove Address</a>.
+ * @apiName UnlinkIdentity
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner|^admin|users:write
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a> to update.
+ * @apiParam (URL Parameters) {String=usernames,facebook,oidc} loginType
+ *	The type of identity to unlink.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiSuccess {Boolean} unlinked true once the identity is unlinked.
+ *
+ */
+func (s *handler) handleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := &struct {
+		UserID string `json:"userID"`
+		LT     string `json:"loginType"`
+		JWT    string `json:"token"`
+	}{
+		UserID: vars[keyUserID],
+		LT:     vars[keyLoginType],
+		JWT:    r.URL.Query().Get(keyToken),
+	}
+	err := s.auth(r).UnlinkIdentity(req.JWT, req.UserID, req.LT)
+	s.respondOn(w, r, req, &struct {
+		Unlinked bool `json:"unlinked"`
+	}{Unlinked: err == nil}, http.StatusOK, err)
+}
+
 /**
  * @api {POST} /:loginType/verify Send Verification Code
  * @apiDescription Send OTP to identifier of type loginType for purpose of verifying identifier.
@@ -638,7 +2622,44 @@ func (s *handler) handleSendVerifCode(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	req.LT = vars[keyLoginType]
 	req.JWT = r.URL.Query().Get(keyToken)
-	dbtStatus, err := s.auth.SendVerCode(req.JWT, req.LT, req.ToAddr)
+	dbtStatus, err := s.auth(r).SendVerCode(req.JWT, req.LT, req.ToAddr)
+	s.respondOn(w, r, req, NewDBTStatus(dbtStatus), http.StatusOK, err)
+}
+
+/**
+ * @api {POST} /users/:userID/:loginType/confirm Send Confirmation Code
+ * @apiDescription Send a code to the user's primary address of type loginType
+ * with which they can re-confirm their identity e.g. to
+ * <a href="#api-Auth-DeleteUser">Delete User</a>. The code cannot be used
+ * for anything else.
+ * @apiName SendConfirmationCode
+ * @apiVersion 0.1.0
+ * @apiGroup Auth
+ * @apiPermission owner
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} userID The ID of the <a href="#api-Objects-User">user</a>.
+ * @apiParam (URL Parameters) {String=emails,phones} loginType The type of
+ *	address to send the code to.
+ *
+ * @apiParam (URL Query Parameters) {String} token the JWT provided during login.
+ *
+ * @apiUse OTPStatus
+ *
+ */
+func (s *handler) handleSendConfirmCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := struct {
+		UserID string `json:"userID"`
+		LT     string `json:"loginType"`
+		JWT    string `json:"token"`
+	}{
+		UserID: vars[keyUserID],
+		LT:     vars[keyLoginType],
+		JWT:    r.URL.Query().Get(keyToken),
+	}
+	dbtStatus, err := s.auth(r).SendConfirmCode(req.JWT, req.UserID, req.LT)
 	s.respondOn(w, r, req, NewDBTStatus(dbtStatus), http.StatusOK, err)
 }
 
@@ -659,6 +2680,8 @@ func (s *handler) handleSendVerifCode(w http.ResponseWriter, r *http.Request) {
  *
  * @apiParam (URL Query Parameters) {String=true,} extend set true to return an extended expiry period OTP.
  * @apiParam (URL Query Parameters) {String=true,} redirectToWebApp set true to redirect user to webApp instead of returning a JSON result.
+ * @apiParam (URL Query Parameters) {String} [tenantID] The tenant the link
+ *	was issued for, as included in the link.
  *
  * @apiSuccess {String} [OTP] (if extending OTP) the new OTP with extended expiry
  *
@@ -686,13 +2709,13 @@ func (s *handler) handleVerifyCode(w http.ResponseWriter, r *http.Request) {
 	var err error
 	if strings.EqualFold(req.Extend, valTrue) {
 		var dbt string
-		dbt, err = s.auth.VerifyAndExtendDBT(req.LT, req.UserID, []byte(req.DBT))
+		dbt, err = s.auth(r).VerifyAndExtendDBT(s.clientInfo(r), req.LT, req.UserID, []byte(req.DBT))
 		resp = struct {
 			OTP string `json:"OTP"`
 		}{OTP: dbt}
 	} else {
 		var vl *model.VerifLogin
-		vl, err = s.auth.VerifyDBT(req.LT, req.UserID, []byte(req.DBT))
+		vl, err = s.auth(r).VerifyDBT(s.clientInfo(r), req.LT, req.UserID, []byte(req.DBT))
 		resp = NewVerifLogin(vl)
 	}
 
@@ -728,7 +2751,7 @@ func (s *handler) handleSendPassResetCode(w http.ResponseWriter, r *http.Request
 	if !s.unmarshalJSONOrRespondError(w, r, &req) {
 		return
 	}
-	dbtStatus, err := s.auth.SendPassResetCode(req.LT, req.ToAddr)
+	dbtStatus, err := s.auth(r).SendPassResetCode(req.LT, req.ToAddr)
 	s.respondOn(w, r, req, NewDBTStatus(dbtStatus), http.StatusOK, err)
 }
 
@@ -746,6 +2769,9 @@ func (s *handler) handleSendPassResetCode(w http.ResponseWriter, r *http.Request
  * @apiParam (JSON Request Body) {String} OTP The password reset code sent to user during <a href="#api-Auth-SendPasswordResetOTP">SendPasswordResetOTP</a>.
  * @apiParam (JSON Request Body) {String} newSecret The new password.
  *
+ * @apiError (400) PasswordPolicyError The new password does not satisfy the
+ *	password policy. See <a href="#api-Objects-PasswordPolicyError">PasswordPolicyError</a>.
+ *
  * @apiUse VerifLogin
  *
  */
@@ -759,16 +2785,153 @@ func (s *handler) handleResetPass(w http.ResponseWriter, r *http.Request) {
 	if !s.unmarshalJSONOrRespondError(w, r, &req) {
 		return
 	}
-	vl, err := s.auth.SetPassword(req.LT, req.OnAddress, []byte(req.DBT), []byte(req.NewSecret))
+	vl, err := s.auth(r).SetPassword(s.clientInfo(r), req.LT, req.OnAddress, []byte(req.DBT), []byte(req.NewSecret))
 	s.respondOn(w, r, req, NewVerifLogin(vl), http.StatusOK, err)
 }
 
+/**
+ * @api {GET} /lockouts/:loginType/:identifier Login Lockout
+ * @apiDescription Get the lockout state of a login identifier following
+ * failed login attempts.
+ * @apiName LoginLockout
+ * @apiVersion 0.1.0
+ * @apiPermission ^admin|lockouts:read
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String=usernames,emails,phones} loginType type of identifier.
+ * @apiParam (URL Parameters) {String} identifier the loginType's unique identifier.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-Lockout">Lockout</a>.
+ *
+ */
+func (s *handler) handleLoginLockout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := struct {
+		JWT        string `json:"token"`
+		LT         string `json:"loginType"`
+		Identifier string `json:"identifier"`
+	}{
+		JWT:        r.URL.Query().Get(keyToken),
+		LT:         vars[keyLoginType],
+		Identifier: vars[keyIdentifier],
+	}
+	lo, err := s.auth(r).LoginLockout(req.JWT, req.LT, req.Identifier)
+	s.respondOn(w, r, req, NewLockout(lo), http.StatusOK, err)
+}
+
+/**
+ * @api {DELETE} /lockouts/:loginType/:identifier Unlock Login
+ * @apiDescription Clear failed login attempts for a login identifier
+ * lifting any lockout in effect.
+ * @apiName UnlockLogin
+ * @apiVersion 0.1.0
+ * @apiPermission ^admin|lockouts:write
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String=usernames,emails,phones} loginType type of identifier.
+ * @apiParam (URL Parameters) {String} identifier the loginType's unique identifier.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-Lockout">Lockout</a>.
+ *
+ */
+func (s *handler) handleUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	req := struct {
+		JWT        string `json:"token"`
+		LT         string `json:"loginType"`
+		Identifier string `json:"identifier"`
+	}{
+		JWT:        r.URL.Query().Get(keyToken),
+		LT:         vars[keyLoginType],
+		Identifier: vars[keyIdentifier],
+	}
+	lo, err := s.auth(r).UnlockLogin(req.JWT, req.LT, req.Identifier)
+	s.respondOn(w, r, req, NewLockout(lo), http.StatusOK, err)
+}
+
+/**
+ * @api {GET} /lockouts/ips/:ipAddress IP Lockout
+ * @apiDescription Get the lockout state of an IP address following
+ * failed login attempts.
+ * @apiName IPLockout
+ * @apiVersion 0.1.0
+ * @apiPermission ^admin|lockouts:read
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} ipAddress the IP address.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-Lockout">Lockout</a>.
+ *
+ */
+func (s *handler) handleIPLockout(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		JWT       string `json:"token"`
+		IPAddress string `json:"ipAddress"`
+	}{
+		JWT:       r.URL.Query().Get(keyToken),
+		IPAddress: mux.Vars(r)[keyIPAddress],
+	}
+	lo, err := s.auth(r).IPLockout(req.JWT, req.IPAddress)
+	s.respondOn(w, r, req, NewLockout(lo), http.StatusOK, err)
+}
+
+/**
+ * @api {DELETE} /lockouts/ips/:ipAddress Unlock IP
+ * @apiDescription Clear failed login attempts made from an IP address
+ * lifting any lockout in effect.
+ * @apiName UnlockIP
+ * @apiVersion 0.1.0
+ * @apiPermission ^admin|lockouts:write
+ * @apiGroup Auth
+ *
+ * @apiHeader x-api-key the api key
+ *
+ * @apiParam (URL Parameters) {String} ipAddress the IP address.
+ *
+ * @apiParam (URL Query Parameters) {String} token The JWT provided during auth.
+ *
+ * @apiSuccess {Object} json-body See <a href="#api-Objects-Lockout">Lockout</a>.
+ *
+ */
+func (s *handler) handleUnlockIP(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		JWT       string `json:"token"`
+		IPAddress string `json:"ipAddress"`
+	}{
+		JWT:       r.URL.Query().Get(keyToken),
+		IPAddress: mux.Vars(r)[keyIPAddress],
+	}
+	lo, err := s.auth(r).UnlockIP(req.JWT, req.IPAddress)
+	s.respondOn(w, r, req, NewLockout(lo), http.StatusOK, err)
+}
+
 func (s *handler) handleError(w http.ResponseWriter, r *http.Request, reqData interface{}, err error) {
 	reqDataB, _ := json.Marshal(reqData)
 	log := r.Context().Value(ctxKeyLog).(logging.Logger).
 		WithField(logging.FieldRequest, string(reqDataB))
 
-	if code, ok := s.auth.ToHTTPResponse(err, w); ok {
+	if vs, ok := model.PasswordViolations(err); ok {
+		log.WithField(logging.FieldResponseCode, http.StatusBadRequest).Warn(err)
+		respBytes, _ := json.Marshal(NewPasswordPolicyError(err, vs))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(respBytes)
+		return
+	}
+
+	if code, ok := s.auth(r).ToHTTPResponse(err, w); ok {
 		log.WithField(logging.FieldResponseCode, code).Warn(err)
 		return
 	}
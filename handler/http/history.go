@@ -0,0 +1,60 @@
+package http
+
+import (
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/model"
+)
+
+/**
+ * @api {NULL} History History
+ * @apiName History
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the history item (can be cast to long Integer).
+ * @apiSuccess {String} userID ID of the user whose account was accessed.
+ * @apiSuccess {String=login,reset/password,verify,mfa,confirm} accessType The type of access attempted.
+ * @apiSuccess {String} [loginType] The loginType used during the access attempt.
+ * @apiSuccess {Boolean} successful true if the access attempt succeeded.
+ * @apiSuccess {String} [IPAddress] The IP address the access attempt was made from.
+ * @apiSuccess {String} [userAgent] The user agent of the client app used.
+ * @apiSuccess {String} created ISO8601 date the access attempt was made.
+ */
+type History struct {
+	ID         string `json:"ID,omitempty"`
+	UserID     string `json:"userID,omitempty"`
+	AccessType string `json:"accessType,omitempty"`
+	LoginType  string `json:"loginType,omitempty"`
+	Successful bool   `json:"successful"`
+	IPAddress  string `json:"IPAddress,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	CreateDate string `json:"created,omitempty"`
+}
+
+func NewHistory(h model.History) *History {
+	if !h.HasValue() {
+		return nil
+	}
+	return &History{
+		ID:         h.ID,
+		UserID:     h.UserID,
+		AccessType: h.AccessType,
+		LoginType:  h.LoginType,
+		Successful: h.Successful,
+		IPAddress:  h.IPAddress,
+		UserAgent:  h.UserAgent,
+		CreateDate: h.CreateDate.Format(config.TimeFormat),
+	}
+}
+
+func NewHistories(hs []model.History) []History {
+	var rHs []History
+	for _, h := range hs {
+		rH := NewHistory(h)
+		if rH == nil {
+			continue
+		}
+		rHs = append(rHs, *rH)
+	}
+	return rHs
+}
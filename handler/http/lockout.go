@@ -0,0 +1,45 @@
+package http
+
+import (
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/model"
+)
+
+/**
+ * @api {NULL} Lockout Lockout
+ * @apiName Lockout
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} [loginType] The loginType of the locked identifier.
+ * @apiSuccess {String} [identifier] The (normalized) identifier this lockout state is for.
+ * @apiSuccess {String} [IPAddress] The IP address this lockout state is for.
+ * @apiSuccess {Integer} failCount Number of failed login attempts within the lockout window.
+ * @apiSuccess {Boolean} isLocked true if further login attempts are currently rejected.
+ * @apiSuccess {String} [lockedUntil] ISO8601 date when the lockout lapses.
+ */
+type Lockout struct {
+	LoginType   string `json:"loginType,omitempty"`
+	Identifier  string `json:"identifier,omitempty"`
+	IPAddress   string `json:"IPAddress,omitempty"`
+	FailCount   int    `json:"failCount"`
+	IsLocked    bool   `json:"isLocked"`
+	LockedUntil string `json:"lockedUntil,omitempty"`
+}
+
+func NewLockout(lo *model.Lockout) *Lockout {
+	if lo == nil {
+		return nil
+	}
+	rLo := &Lockout{
+		LoginType:  lo.LoginType,
+		Identifier: lo.Identifier,
+		IPAddress:  lo.IPAddress,
+		FailCount:  lo.FailCount,
+		IsLocked:   lo.IsLocked,
+	}
+	if lo.IsLocked {
+		rLo.LockedUntil = lo.LockedUntil.Format(config.TimeFormat)
+	}
+	return rLo
+}
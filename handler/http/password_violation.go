@@ -0,0 +1,37 @@
+package http
+
+import "github.com/tomogoma/authms/model"
+
+/**
+ * @api {NULL} PasswordPolicyError PasswordPolicyError
+ * @apiName PasswordPolicyError
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ * @apiDescription The body of the 400 (Bad Request) response returned when
+ *	a password chosen by a user does not satisfy the password policy.
+ *
+ * @apiSuccess {String} message Summary of all rules that failed.
+ * @apiSuccess {Object[]} violations Each rule the password failed.
+ * @apiSuccess {String} violations.rule One of minLength, maxLength,
+ *	lowerCase, upperCase, digit, special, noIdentifier, notReused or
+ *	notBreached.
+ * @apiSuccess {String} violations.message Human readable description of
+ *	the rule.
+ */
+type PasswordPolicyError struct {
+	Message    string                  `json:"message"`
+	Violations []PasswordRuleViolation `json:"violations"`
+}
+
+type PasswordRuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func NewPasswordPolicyError(err error, vs []model.PasswordRuleViolation) PasswordPolicyError {
+	rVs := make([]PasswordRuleViolation, len(vs))
+	for i, v := range vs {
+		rVs[i] = PasswordRuleViolation{Rule: v.Rule, Message: v.Message}
+	}
+	return PasswordPolicyError{Message: err.Error(), Violations: rVs}
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/model"
+)
+
+/**
+ * @api {NULL} Session Session
+ * @apiName Session
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the session (can be cast to long Integer).
+ * @apiSuccess {String} userID ID of the user who logged in.
+ * @apiSuccess {String} [deviceID] The device ID provided in the x-device-id
+ *	header during login.
+ * @apiSuccess {String} [IPAddress] The IP address the login was made from.
+ * @apiSuccess {String} [userAgent] The user agent of the client app used.
+ * @apiSuccess {Boolean} revoked true if the session has been ended.
+ * @apiSuccess {String} created ISO8601 date of the login.
+ * @apiSuccess {String} lastSeen ISO8601 date the session was last used.
+ */
+type Session struct {
+	ID         string `json:"ID,omitempty"`
+	UserID     string `json:"userID,omitempty"`
+	DeviceID   string `json:"deviceID,omitempty"`
+	IPAddress  string `json:"IPAddress,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	IsRevoked  bool   `json:"revoked"`
+	CreateDate string `json:"created,omitempty"`
+	LastSeen   string `json:"lastSeen,omitempty"`
+}
+
+func NewSession(s model.Session) *Session {
+	if !s.HasValue() {
+		return nil
+	}
+	return &Session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		DeviceID:   s.DeviceID,
+		IPAddress:  s.IPAddress,
+		UserAgent:  s.UserAgent,
+		IsRevoked:  s.IsRevoked,
+		CreateDate: s.CreateDate.Format(config.TimeFormat),
+		LastSeen:   s.LastSeen.Format(config.TimeFormat),
+	}
+}
+
+func NewSessions(ss []model.Session) []Session {
+	var rSs []Session
+	for _, s := range ss {
+		rS := NewSession(s)
+		if rS == nil {
+			continue
+		}
+		rSs = append(rSs, *rS)
+	}
+	return rSs
+}
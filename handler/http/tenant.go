@@ -0,0 +1,48 @@
+package http
+
+import (
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/model"
+)
+
+/**
+ * @api {NULL} Tenant Tenant
+ * @apiName Tenant
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the tenant (can be cast to long Integer).
+ * @apiSuccess {String} name The unique tenant name string value.
+ * @apiSuccess {String} created ISO8601 date the tenant was created.
+ * @apiSuccess {String} lastUpdated ISO8601 date the tenant was last updated.
+ */
+type Tenant struct {
+	ID         string `json:"ID,omitempty"`
+	Name       string `json:"name,omitempty"`
+	CreateDate string `json:"created,omitempty"`
+	UpdateDate string `json:"lastUpdated,omitempty"`
+}
+
+func NewTenant(t model.Tenant) *Tenant {
+	if !t.HasValue() {
+		return nil
+	}
+	return &Tenant{
+		ID:         t.ID,
+		Name:       t.Name,
+		CreateDate: t.CreateDate.Format(config.TimeFormat),
+		UpdateDate: t.UpdateDate.Format(config.TimeFormat),
+	}
+}
+
+func NewTenants(ts []model.Tenant) []Tenant {
+	var tnts []Tenant
+	for _, t := range ts {
+		tnt := NewTenant(t)
+		if tnt == nil {
+			continue
+		}
+		tnts = append(tnts, *tnt)
+	}
+	return tnts
+}
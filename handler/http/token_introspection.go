@@ -0,0 +1,52 @@
+package http
+
+import "github.com/tomogoma/authms/model"
+
+/**
+ * @api {NULL} TokenIntrospection TokenIntrospection
+ * @apiName TokenIntrospection
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {Boolean} active true if the token is active, false otherwise.
+ *	The remaining fields are only provided for active tokens.
+ * @apiSuccess {String} [jti] The unique ID of the token.
+ * @apiSuccess {String} [userID] The ID of the <a href="#api-Objects-User">user</a>
+ *	the token was issued to.
+ * @apiSuccess {Object} [group] The <a href="#api-Objects-Group">group</a> the user
+ *	belonged to when the token was issued.
+ * @apiSuccess {Object} [attributes] The user's custom attributes configured
+ *	to be included in the token.
+ * @apiSuccess {String} [iss] The issuer of the token.
+ * @apiSuccess {Number} [iat] Unix time when the token was issued.
+ * @apiSuccess {Number} [exp] Unix time when the token expires.
+ */
+type TokenIntrospection struct {
+	Active     bool                   `json:"active"`
+	ID         string                 `json:"jti,omitempty"`
+	UserID     string                 `json:"userID,omitempty"`
+	Group      *Group                 `json:"group,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Issuer     string                 `json:"iss,omitempty"`
+	IssuedAt   int64                  `json:"iat,omitempty"`
+	ExpiresAt  int64                  `json:"exp,omitempty"`
+}
+
+func NewTokenIntrospection(ti *model.TokenIntrospection) *TokenIntrospection {
+	if ti == nil {
+		return nil
+	}
+	if !ti.Active || ti.Claims == nil {
+		return &TokenIntrospection{Active: false}
+	}
+	return &TokenIntrospection{
+		Active:     true,
+		ID:         ti.Claims.Id,
+		UserID:     ti.Claims.UsrID,
+		Group:      NewGroup(ti.Claims.Group),
+		Attributes: ti.Claims.Attributes,
+		Issuer:     ti.Claims.Issuer,
+		IssuedAt:   ti.Claims.IssuedAt,
+		ExpiresAt:  ti.Claims.ExpiresAt,
+	}
+}
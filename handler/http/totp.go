@@ -0,0 +1,29 @@
+package http
+
+import "github.com/tomogoma/authms/model"
+
+/**
+ * @api {NULL} TOTPEnrollment TOTPEnrollment
+ * @apiName TOTPEnrollment
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} secret The base32 encoded TOTP secret for manual entry
+ *	into an authenticator app.
+ * @apiSuccess {String} URI The otpauth:// key URI containing the secret,
+ *	usually presented to the user as a QR code.
+ */
+type TOTPEnrollment struct {
+	Secret string `json:"secret,omitempty"`
+	URI    string `json:"URI,omitempty"`
+}
+
+func NewTOTPEnrollment(te *model.TOTPEnrollment) *TOTPEnrollment {
+	if te == nil {
+		return nil
+	}
+	return &TOTPEnrollment{
+		Secret: te.Secret,
+		URI:    te.URI,
+	}
+}
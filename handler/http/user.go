@@ -1,6 +1,8 @@
 package http
 
 import (
+	"time"
+
 	"github.com/tomogoma/authms/config"
 	"github.com/tomogoma/authms/model"
 )
@@ -15,22 +17,38 @@ import (
 	<a href="#api-Objects-UserType">UserType</a> of this user.
 @apiSuccess {Object} group		The
 	<a href="#api-Objects-Group">group</a> the user belongs to.
+@apiSuccess {String=active,suspended,pending} status	The user's account status.
+@apiSuccess {String} [statusReason]	Why the status was set.
+@apiSuccess {String} [statusUntil]	ISO8601 date after which the status
+	lapses back to active.
 @apiSuccess {String} created		The date the user was created.
 @apiSuccess {String} lastUpdated	date the user was last updated.
 @apiSuccess {String} [JWT]			JSON Web Token for accessing services.
 	This is only provided during <a href="#api-Auth-Login">Login</a>,
 	<a href="#api-Auth-Register">Registration</a>
 	and  <a href="#api-Auth-FirstUser">First User Registration</a>.
+@apiSuccess {String} [refreshToken]	Token for obtaining a new JWT once the current one
+	expires. This is only provided during <a href="#api-Auth-Login">Login</a>
+	and <a href="#api-Auth-RefreshToken">Refresh Token</a>.
 @apiSuccess {Object} [username]		The user's
 	<a href="#api-Objects-Username">username</a> (if this user has one).
-@apiSuccess {Object} [phone]		The user's
+@apiSuccess {Object} [phone]		The user's primary
 	<a href="#api-Objects-VerifLogin">phone</a> (if this user has one).
-@apiSuccess {Object} [email]		The user's
+@apiSuccess {Object} [email]		The user's primary
 	<a href="#api-Objects-VerifLogin">email</a> (if this user has one).
+@apiSuccess {Object[]} [phones]		All of the user's
+	<a href="#api-Objects-VerifLogin">phones</a> including the primary.
+@apiSuccess {Object[]} [emails]		All of the user's
+	<a href="#api-Objects-VerifLogin">emails</a> including the primary.
 @apiSuccess {Object} [facebook] 	The user's
 	<a href="#api-Objects-FacebookID">facebook ID</a> (if this user has one).
 @apiSuccess {Object} [device]		The
 	<a href="#api-Objects-Device">device</a> this user is attached to, if any.
+@apiSuccess {Object} [attributes]	The user's custom attributes e.g.
+	first name or locale.
+@apiSuccess {Boolean} [passwordPwned]	true if the password used during
+	<a href="#api-Auth-Login">Login</a> has appeared in a data breach and
+	should be changed. Only provided if breach warnings are enabled.
  */
 
 /**
@@ -42,35 +60,62 @@ import (
  * @apiUse User
  */
 type User struct {
-	ID         string      `json:"ID,omitempty"`
-	JWT        string      `json:"JWT,omitempty"`
-	Type       *UserType   `json:"type,omitempty"`
-	UserName   *Username   `json:"username,omitempty"`
-	Phone      *VerifLogin `json:"phone,omitempty"`
-	Email      *VerifLogin `json:"email,omitempty"`
-	Facebook   *Facebook   `json:"facebook,omitempty"`
-	Group      *Group      `json:"group,omitempty"`
-	Devices    []Device    `json:"devices,omitempty"`
-	CreateDate string      `json:"created,omitempty"`
-	UpdateDate string      `json:"lastUpdated,omitempty"`
+	ID           string                 `json:"ID,omitempty"`
+	JWT          string                 `json:"JWT,omitempty"`
+	RefreshToken string                 `json:"refreshToken,omitempty"`
+	MFAToken     string                 `json:"MFAToken,omitempty"`
+	Type         *UserType              `json:"type,omitempty"`
+	UserName     *Username              `json:"username,omitempty"`
+	Phone        *VerifLogin            `json:"phone,omitempty"`
+	Email        *VerifLogin            `json:"email,omitempty"`
+	Phones       []VerifLogin           `json:"phones,omitempty"`
+	Emails       []VerifLogin           `json:"emails,omitempty"`
+	Facebook     *Facebook              `json:"facebook,omitempty"`
+	Group        *Group                 `json:"group,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	StatusReason string                 `json:"statusReason,omitempty"`
+	StatusUntil  string                 `json:"statusUntil,omitempty"`
+	Devices      []Device               `json:"devices,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	CreateDate   string                 `json:"created,omitempty"`
+	UpdateDate   string                 `json:"lastUpdated,omitempty"`
+	PassPwned    bool                   `json:"passwordPwned,omitempty"`
 }
 
 func NewUser(user *model.User) *User {
 	if user == nil || !user.HasValue() {
 		return nil
 	}
+	status := user.Status.ValueAt(time.Now())
+	var statusReason, statusUntil string
+	// a lapsed status is not reported.
+	if status == user.Status.Value {
+		statusReason = user.Status.Reason
+		if !user.Status.Until.IsZero() {
+			statusUntil = user.Status.Until.Format(config.TimeFormat)
+		}
+	}
 	return &User{
-		ID:         user.ID,
-		JWT:        user.JWT,
-		Type:       NewUserType(user.Type),
-		UserName:   NewUserName(user.UserName),
-		Phone:      NewVerifLogin(&user.Phone),
-		Email:      NewVerifLogin(&user.Email),
-		Facebook:   NewFacebook(user.Facebook),
-		Group:      NewGroup(user.Group),
-		Devices:    NewDevices(user.Devices),
-		CreateDate: user.CreateDate.Format(config.TimeFormat),
-		UpdateDate: user.UpdateDate.Format(config.TimeFormat),
+		ID:           user.ID,
+		JWT:          user.JWT,
+		RefreshToken: user.RefreshToken,
+		MFAToken:     user.MFAToken,
+		Type:         NewUserType(user.Type),
+		UserName:     NewUserName(user.UserName),
+		Phone:        NewVerifLogin(&user.Phone),
+		Email:        NewVerifLogin(&user.Email),
+		Phones:       NewVerifLogins(user.Phones),
+		Emails:       NewVerifLogins(user.Emails),
+		Facebook:     NewFacebook(user.Facebook),
+		Group:        NewGroup(user.Group),
+		Status:       status,
+		StatusReason: statusReason,
+		StatusUntil:  statusUntil,
+		Devices:      NewDevices(user.Devices),
+		Attributes:   user.Attributes,
+		CreateDate:   user.CreateDate.Format(config.TimeFormat),
+		UpdateDate:   user.UpdateDate.Format(config.TimeFormat),
+		PassPwned:    user.PassPwned,
 	}
 }
 
@@ -0,0 +1,274 @@
+package http
+
+import (
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/model"
+)
+
+/**
+ * @api {NULL} APIKey APIKey
+ * @apiName APIKey
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the API key (can be cast to long Integer).
+ * @apiSuccess {String} userID ID of the user the API key was issued to.
+ * @apiSuccess {String} created ISO8601 date the API key was issued.
+ * @apiSuccess {String} lastUpdated ISO8601 date the API key was last updated.
+ */
+type APIKey struct {
+	ID         string `json:"ID,omitempty"`
+	UserID     string `json:"userID,omitempty"`
+	CreateDate string `json:"created,omitempty"`
+	UpdateDate string `json:"lastUpdated,omitempty"`
+}
+
+func NewAPIKeys(ks []model.APIKey) []APIKey {
+	var rKs []APIKey
+	for _, k := range ks {
+		if !k.HasValue() {
+			continue
+		}
+		rKs = append(rKs, APIKey{
+			ID:         k.ID,
+			UserID:     k.UserID,
+			CreateDate: k.CreateDate.Format(config.TimeFormat),
+			UpdateDate: k.UpdateDate.Format(config.TimeFormat),
+		})
+	}
+	return rKs
+}
+
+/**
+ * @api {NULL} IssuedToken IssuedToken
+ * @apiName IssuedToken
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the token (can be cast to long Integer).
+ * @apiSuccess {String} [address] The email or phone the token was sent to.
+ * @apiSuccess {String} [APIKeyID] ID of the API key of the client app the
+ *	refresh token was issued through.
+ * @apiSuccess {String} [familyID] The refresh token family the refresh
+ *	token belongs to.
+ * @apiSuccess {Boolean} used true if the token has been used.
+ * @apiSuccess {Boolean} revoked true if the token has been revoked.
+ * @apiSuccess {String} issued ISO8601 date the token was issued.
+ * @apiSuccess {String} expires ISO8601 date the token expires.
+ */
+type IssuedToken struct {
+	ID         string `json:"ID,omitempty"`
+	Address    string `json:"address,omitempty"`
+	APIKeyID   string `json:"APIKeyID,omitempty"`
+	FamilyID   string `json:"familyID,omitempty"`
+	IsUsed     bool   `json:"used"`
+	IsRevoked  bool   `json:"revoked"`
+	IssueDate  string `json:"issued,omitempty"`
+	ExpiryDate string `json:"expires,omitempty"`
+}
+
+func NewRefreshTokenRecords(rts []model.RefreshToken) []IssuedToken {
+	var rTs []IssuedToken
+	for _, rt := range rts {
+		if !rt.HasValue() {
+			continue
+		}
+		rTs = append(rTs, IssuedToken{
+			ID:         rt.ID,
+			APIKeyID:   rt.APIKeyID,
+			FamilyID:   rt.FamilyID,
+			IsUsed:     rt.IsUsed,
+			IsRevoked:  rt.IsRevoked,
+			IssueDate:  rt.IssueDate.Format(config.TimeFormat),
+			ExpiryDate: rt.ExpiryDate.Format(config.TimeFormat),
+		})
+	}
+	return rTs
+}
+
+func NewDBTokenRecords(dbts []model.DBToken) []IssuedToken {
+	var rTs []IssuedToken
+	for _, dbt := range dbts {
+		if dbt.ID == "" {
+			continue
+		}
+		rTs = append(rTs, IssuedToken{
+			ID:         dbt.ID,
+			Address:    dbt.Address,
+			IsUsed:     dbt.IsUsed,
+			IssueDate:  dbt.IssueDate.Format(config.TimeFormat),
+			ExpiryDate: dbt.ExpiryDate.Format(config.TimeFormat),
+		})
+	}
+	return rTs
+}
+
+/**
+ * @api {NULL} LinkedIdentity LinkedIdentity
+ * @apiName LinkedIdentity
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the link (can be cast to long Integer).
+ * @apiSuccess {String} userID ID of the user the identity is linked to.
+ * @apiSuccess {String} issuer The OpenID Connect identity provider (iss).
+ * @apiSuccess {String} subject The user's ID at the identity provider (sub).
+ * @apiSuccess {String} created ISO8601 date the identity was linked.
+ * @apiSuccess {String} lastUpdated ISO8601 date the link was last updated.
+ */
+type LinkedIdentity struct {
+	ID         string `json:"ID,omitempty"`
+	UserID     string `json:"userID,omitempty"`
+	Issuer     string `json:"issuer,omitempty"`
+	Subject    string `json:"subject,omitempty"`
+	CreateDate string `json:"created,omitempty"`
+	UpdateDate string `json:"lastUpdated,omitempty"`
+}
+
+func NewLinkedIdentities(lis []model.LinkedIdentity) []LinkedIdentity {
+	var rLIs []LinkedIdentity
+	for _, li := range lis {
+		if !li.HasValue() {
+			continue
+		}
+		rLIs = append(rLIs, LinkedIdentity{
+			ID:         li.ID,
+			UserID:     li.UserID,
+			Issuer:     li.Issuer,
+			Subject:    li.Subject,
+			CreateDate: li.CreateDate.Format(config.TimeFormat),
+			UpdateDate: li.UpdateDate.Format(config.TimeFormat),
+		})
+	}
+	return rLIs
+}
+
+/**
+ * @api {NULL} TOTPStatus TOTPStatus
+ * @apiName TOTPStatus
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {Boolean} enrolled true if the user has enrolled a TOTP
+ *	authenticator.
+ * @apiSuccess {Boolean} confirmed true if the enrollment has been confirmed.
+ * @apiSuccess {String} [created] ISO8601 date the user enrolled.
+ * @apiSuccess {String} [lastUpdated] ISO8601 date the enrollment was last
+ *	updated.
+ */
+type TOTPStatus struct {
+	IsEnrolled  bool   `json:"enrolled"`
+	IsConfirmed bool   `json:"confirmed"`
+	CreateDate  string `json:"created,omitempty"`
+	UpdateDate  string `json:"lastUpdated,omitempty"`
+}
+
+func NewTOTPStatus(ts model.TOTPSecret) TOTPStatus {
+	if !ts.HasValue() {
+		return TOTPStatus{}
+	}
+	return TOTPStatus{
+		IsEnrolled:  true,
+		IsConfirmed: ts.IsConfirmed,
+		CreateDate:  ts.CreateDate.Format(config.TimeFormat),
+		UpdateDate:  ts.UpdateDate.Format(config.TimeFormat),
+	}
+}
+
+/**
+ * @api {NULL} LoginFailure LoginFailure
+ * @apiName LoginFailure
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {String} ID Unique ID of the record (can be cast to long Integer).
+ * @apiSuccess {String} loginType The login type of the failed attempt.
+ * @apiSuccess {String} identifier The identifier the attempt was made with.
+ * @apiSuccess {String} [IPAddress] IP address the attempt was made from.
+ *	Omitted from a <a href="#api-Objects-UserExport">UserExport</a> as the
+ *	attempt may have been made by anyone.
+ * @apiSuccess {String} created ISO8601 date the attempt was made.
+ */
+type LoginFailure struct {
+	ID         string `json:"ID,omitempty"`
+	LoginType  string `json:"loginType,omitempty"`
+	Identifier string `json:"identifier,omitempty"`
+	IPAddress  string `json:"IPAddress,omitempty"`
+	CreateDate string `json:"created,omitempty"`
+}
+
+func NewLoginFailures(lfs []model.LoginFailure) []LoginFailure {
+	var rLFs []LoginFailure
+	for _, lf := range lfs {
+		rLFs = append(rLFs, LoginFailure{
+			ID:         lf.ID,
+			LoginType:  lf.LoginType,
+			Identifier: lf.Identifier,
+			IPAddress:  lf.IPAddress,
+			CreateDate: lf.CreateDate.Format(config.TimeFormat),
+		})
+	}
+	return rLFs
+}
+
+/**
+ * @api {JSON} UserExport UserExport
+ * @apiName UserExport
+ * @apiVersion 0.1.0
+ * @apiGroup Objects
+ *
+ * @apiSuccess {Object} user The <a href="#api-Objects-User">User</a>
+ *	including their devices.
+ * @apiSuccess {Object[]} [APIKeys] <a href="#api-Objects-APIKey">API keys</a>
+ *	issued to the user.
+ * @apiSuccess {Object[]} [sessions] The user's <a href="#api-Objects-Session">sessions</a>.
+ * @apiSuccess {Object[]} [refreshTokens] <a href="#api-Objects-IssuedToken">Refresh tokens</a>
+ *	issued to the user.
+ * @apiSuccess {Object[]} [emailTokens] <a href="#api-Objects-IssuedToken">Tokens</a>
+ *	sent to the user's email.
+ * @apiSuccess {Object[]} [phoneTokens] <a href="#api-Objects-IssuedToken">Tokens</a>
+ *	sent to the user's phone.
+ * @apiSuccess {Object[]} [loginHistory] The user's access
+ *	<a href="#api-Objects-History">history</a>.
+ * @apiSuccess {Object[]} [linkedIdentities] OpenID Connect
+ *	<a href="#api-Objects-LinkedIdentity">identities</a> linked to the user.
+ * @apiSuccess {Object} totp The user's
+ *	<a href="#api-Objects-TOTPStatus">TOTP enrollment</a>. The TOTP secret
+ *	itself is never exported.
+ * @apiSuccess {Object[]} [loginFailures] The recorded failed
+ *	<a href="#api-Objects-LoginFailure">attempts</a> to log in with the
+ *	user's identifiers, without the IP addresses they were made from.
+ * @apiSuccess {String} exported ISO8601 date the export was made.
+ */
+type UserExport struct {
+	User             *User            `json:"user,omitempty"`
+	APIKeys          []APIKey         `json:"APIKeys,omitempty"`
+	Sessions         []Session        `json:"sessions,omitempty"`
+	RefreshTokens    []IssuedToken    `json:"refreshTokens,omitempty"`
+	EmailTokens      []IssuedToken    `json:"emailTokens,omitempty"`
+	PhoneTokens      []IssuedToken    `json:"phoneTokens,omitempty"`
+	LoginHistory     []History        `json:"loginHistory,omitempty"`
+	LinkedIdentities []LinkedIdentity `json:"linkedIdentities,omitempty"`
+	TOTP             TOTPStatus       `json:"totp"`
+	LoginFailures    []LoginFailure   `json:"loginFailures,omitempty"`
+	ExportDate       string           `json:"exported,omitempty"`
+}
+
+func NewUserExport(exp *model.UserExport) *UserExport {
+	if exp == nil {
+		return nil
+	}
+	return &UserExport{
+		User:             NewUser(&exp.User),
+		APIKeys:          NewAPIKeys(exp.APIKeys),
+		Sessions:         NewSessions(exp.Sessions),
+		RefreshTokens:    NewRefreshTokenRecords(exp.RefreshTokens),
+		EmailTokens:      NewDBTokenRecords(exp.EmailTokens),
+		PhoneTokens:      NewDBTokenRecords(exp.PhoneTokens),
+		LoginHistory:     NewHistories(exp.LoginHistory),
+		LinkedIdentities: NewLinkedIdentities(exp.LinkedIdentities),
+		TOTP:             NewTOTPStatus(exp.TOTP),
+		LoginFailures:    NewLoginFailures(exp.LoginFailures),
+		ExportDate:       exp.ExportDate.Format(config.TimeFormat),
+	}
+}
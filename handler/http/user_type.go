@@ -13,14 +13,20 @@ import (
  *
  * @apiSuccess {String} ID Unique ID of the userType (can be cast to long Integer).
  * @apiSuccess {String} name Unique name of the user type.
+ * @apiSuccess {Boolean} [selfRegistrable] true if users may pick the user
+ *	type when registering themselves.
+ * @apiSuccess {String[]} [requiredLoginTypes] Login types users of the type
+ *	must be registered with e.g. emails.
  * @apiSuccess {String} created ISO8601 date the user type was created.
  * @apiSuccess {String} lastUpdated ISO8601 date the user type was last updated.
  */
 type UserType struct {
-	ID         string `json:"ID,omitempty"`
-	Name       string `json:"name,omitempty"`
-	CreateDate string `json:"created,omitempty"`
-	UpdateDate string `json:"lastUpdated,omitempty"`
+	ID                 string   `json:"ID,omitempty"`
+	Name               string   `json:"name,omitempty"`
+	SelfRegistrable    bool     `json:"selfRegistrable,omitempty"`
+	RequiredLoginTypes []string `json:"requiredLoginTypes,omitempty"`
+	CreateDate         string   `json:"created,omitempty"`
+	UpdateDate         string   `json:"lastUpdated,omitempty"`
 }
 
 func NewUserType(ut model.UserType) *UserType {
@@ -28,9 +34,23 @@ func NewUserType(ut model.UserType) *UserType {
 		return nil
 	}
 	return &UserType{
-		ID:         ut.ID,
-		Name:       ut.Name,
-		CreateDate: ut.CreateDate.Format(config.TimeFormat),
-		UpdateDate: ut.UpdateDate.Format(config.TimeFormat),
+		ID:                 ut.ID,
+		Name:               ut.Name,
+		SelfRegistrable:    ut.SelfRegistrable,
+		RequiredLoginTypes: ut.RequiredLoginTypes,
+		CreateDate:         ut.CreateDate.Format(config.TimeFormat),
+		UpdateDate:         ut.UpdateDate.Format(config.TimeFormat),
 	}
 }
+
+func NewUserTypes(uts []model.UserType) []UserType {
+	var rUTs []UserType
+	for _, ut := range uts {
+		rUT := NewUserType(ut)
+		if rUT == nil {
+			continue
+		}
+		rUTs = append(rUTs, *rUT)
+	}
+	return rUTs
+}
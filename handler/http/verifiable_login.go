@@ -12,6 +12,7 @@ import (
  * @apiSuccess {String} userID ID for user who owns this verifiable login.
  * @apiSuccess {String} value The unique verifiable login string value.
  * @apiSuccess {Boolean} verified True if this login is verified, false otherwise.
+ * @apiSuccess {Boolean} primary True if this is the user's primary login of its type.
  * @apiSuccess {String} created ISO8601 date the verifiable login was created.
  * @apiSuccess {String} lastUpdated ISO8601 date the verifiable login was last updated.
  * @apiSuccess {Object} [OTPStatus] The status of a pending verification OTP (if any)
@@ -31,6 +32,7 @@ type VerifLogin struct {
 	UserID     string     `json:"userID,omitempty"`
 	Address    string     `json:"value,omitempty"`
 	Verified   bool       `json:"verified"`
+	IsPrimary  bool       `json:"primary"`
 	OTPStatus  *DBTStatus `json:"OTPStatus,omitempty"`
 	CreateDate string     `json:"created,omitempty"`
 	UpdateDate string     `json:"lastUpdated,omitempty"`
@@ -45,8 +47,21 @@ func NewVerifLogin(vl *model.VerifLogin) *VerifLogin {
 		UserID:     vl.UserID,
 		Address:    vl.Address,
 		Verified:   vl.Verified,
+		IsPrimary:  vl.IsPrimary,
 		OTPStatus:  NewDBTStatus(&vl.OTPStatus),
 		CreateDate: vl.CreateDate.Format(config.TimeFormat),
 		UpdateDate: vl.UpdateDate.Format(config.TimeFormat),
 	}
 }
+
+func NewVerifLogins(vls []model.VerifLogin) []VerifLogin {
+	var rVLs []VerifLogin
+	for _, vl := range vls {
+		rVL := NewVerifLogin(&vl)
+		if rVL == nil {
+			continue
+		}
+		rVLs = append(rVLs, *rVL)
+	}
+	return rVLs
+}
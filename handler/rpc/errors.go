@@ -21,26 +21,26 @@ func (h *UsersHandler) processError(ctx context.Context, err error) error {
 
 	log := ctx.Value(ctxKeyLog).(logging.Logger)
 
-	if h.usersM.IsForbiddenError(err) || h.IsForbiddenError(err) {
+	if h.usersM(ctx).IsForbiddenError(err) || h.IsForbiddenError(err) {
 		log.Warnf("Forbidden: %v", err)
 		return errors.New(Error{Code: http.StatusForbidden, Message: err.Error()})
 	}
-	if h.usersM.IsAuthError(err) || h.IsAuthError(err){
+	if h.usersM(ctx).IsAuthError(err) || h.IsAuthError(err){
 		log.Warnf("Unauthorized: %v", err)
 		return errors.New(Error{Code: http.StatusUnauthorized, Message: err.Error()})
 	}
 
-	if h.usersM.IsClientError(err) || h.IsClientError(err) {
+	if h.usersM(ctx).IsClientError(err) || h.IsClientError(err) {
 		log.Warnf("Bad request: %v", err)
 		return errors.New(Error{Code: http.StatusBadRequest, Message: err.Error()})
 	}
 
-	if h.usersM.IsNotFoundError(err) || h.IsNotFoundError(err) {
+	if h.usersM(ctx).IsNotFoundError(err) || h.IsNotFoundError(err) {
 		log.Warnf("Not found: %v", err)
 		return errors.New(Error{Code: http.StatusNotFound, Message: err.Error()})
 	}
 
-	if h.usersM.IsNotImplementedError(err) || h.IsNotImplementedError(err) {
+	if h.usersM(ctx).IsNotImplementedError(err) || h.IsNotImplementedError(err) {
 		log.Warnf("Not implemented entity: %v", err)
 		return errors.New(Error{Code: http.StatusNotImplemented, Message: err.Error()})
 	}
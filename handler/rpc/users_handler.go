@@ -13,34 +13,40 @@ import (
 )
 
 type Guard interface {
-	APIKeyValid(key string) (string, error)
+	APIKey(key string) (*api.Key, error)
 }
 
 type UsersModel interface {
 	errors.AllErrChecker
 	GetUserDetails(JWT string, userID string) (*model.User, error)
+	ExportUser(JWT, userID string) (*model.UserExport, error)
 }
 
+// TenantUsersModel returns the UsersModel for the tenant having tenantID.
+// An empty tenantID refers to the default tenant.
+type TenantUsersModel func(tenantID string) UsersModel
+
 type UsersHandler struct {
 	errors.AllErrCheck
-	guard  Guard
-	usersM UsersModel
+	guard     Guard
+	usersMFor TenantUsersModel
 }
 
 const (
 	internalErrorMessage = "whoops! Something wicked happened"
 
-	ctxKeyLog = "log"
+	ctxKeyLog      = "log"
+	ctxKeyTenantID = "tenantID"
 )
 
-func NewHandler(g Guard, um UsersModel) (*UsersHandler, error) {
+func NewHandler(g Guard, um TenantUsersModel) (*UsersHandler, error) {
 	if g == nil {
 		return nil, errors.New("nil Guard")
 	}
 	if um == nil {
-		return nil, errors.New("nil UsersModel")
+		return nil, errors.New("nil TenantUsersModel")
 	}
-	return &UsersHandler{usersM: um}, nil
+	return &UsersHandler{guard: g, usersMFor: um}, nil
 }
 
 func LogWrapper(next server.HandlerFunc) server.HandlerFunc {
@@ -72,7 +78,7 @@ func (h *UsersHandler) GetDetails(ctx context.Context, req *api.GetDetailsReq, r
 		return h.processError(ctx, err)
 	}
 
-	usr, err := h.usersM.GetUserDetails(req.JWT, req.UserID)
+	usr, err := h.usersM(ctx).GetUserDetails(req.JWT, req.UserID)
 	if err != nil {
 		return h.processError(ctx, err)
 	}
@@ -81,17 +87,45 @@ func (h *UsersHandler) GetDetails(ctx context.Context, req *api.GetDetailsReq, r
 	return nil
 }
 
+func (h *UsersHandler) ExportUser(ctx context.Context, req *api.ExportUserReq, resp *api.UserExport) error {
+
+	if req == nil || resp == nil {
+		return errors.Newf("req/response had nil value")
+	}
+
+	ctx, err := h.APIKeyValid(ctx, req.APIKey)
+	if err != nil {
+		return h.processError(ctx, err)
+	}
+
+	exp, err := h.usersM(ctx).ExportUser(req.JWT, req.UserID)
+	if err != nil {
+		return h.processError(ctx, err)
+	}
+
+	packageUserExport(exp, resp)
+	return nil
+}
+
 func (h *UsersHandler) APIKeyValid(ctx context.Context, APIKey string) (context.Context, error) {
 
-	clUsrID, err := h.guard.APIKeyValid(APIKey)
+	key, err := h.guard.APIKey(APIKey)
 
 	log := ctx.Value(ctxKeyLog).(logging.Logger).
-		WithField(logging.FieldClientAppUserID, clUsrID)
+		WithField(logging.FieldClientAppUserID, key.UserID)
 	ctx = context.WithValue(ctx, ctxKeyLog, log)
+	ctx = context.WithValue(ctx, ctxKeyTenantID, key.TenantID)
 
 	return ctx, err
 }
 
+// usersM returns the UsersModel for the tenant set in ctx by APIKeyValid,
+// or for the default tenant if none was set.
+func (h *UsersHandler) usersM(ctx context.Context) UsersModel {
+	tenantID, _ := ctx.Value(ctxKeyTenantID).(string)
+	return h.usersMFor(tenantID)
+}
+
 func packageUser(usr *model.User, resp *api.User) {
 	if usr == nil || resp == nil {
 		return
@@ -102,13 +136,28 @@ func packageUser(usr *model.User, resp *api.User) {
 	resp.Created = usr.CreateDate.Format(config.TimeFormat)
 	resp.LastUpdated = usr.UpdateDate.Format(config.TimeFormat)
 
-	packageUserType(&usr.Type, resp.Type)
-	packageUserName(&usr.UserName, resp.Username)
-	packageVerifLogin(&usr.Phone, resp.Phone)
-	packageVerifLogin(&usr.Email, resp.Email)
-	packageFacebook(&usr.Facebook, resp.Facebook)
-	packageGroups([]model.Group{usr.Group}, resp.Groups)
-	packageDevices(usr.Devices, resp.Devices)
+	if usr.Type.HasValue() {
+		resp.Type = &api.UserType{}
+		packageUserType(&usr.Type, resp.Type)
+	}
+	if usr.UserName.HasValue() {
+		resp.Username = &api.UserName{}
+		packageUserName(&usr.UserName, resp.Username)
+	}
+	if usr.Phone.HasValue() {
+		resp.Phone = &api.VerifLogin{}
+		packageVerifLogin(&usr.Phone, resp.Phone)
+	}
+	if usr.Email.HasValue() {
+		resp.Email = &api.VerifLogin{}
+		packageVerifLogin(&usr.Email, resp.Email)
+	}
+	if usr.Facebook.HasValue() {
+		resp.Facebook = &api.Facebook{}
+		packageFacebook(&usr.Facebook, resp.Facebook)
+	}
+	resp.Groups = packageGroups([]model.Group{usr.Group})
+	resp.Devices = packageDevices(usr.Devices)
 }
 
 func packageUserType(ut *model.UserType, resp *api.UserType) {
@@ -167,10 +216,8 @@ func packageGroup(g *model.Group, resp *api.Group) {
 	resp.LastUpdated = g.UpdateDate.Format(config.TimeFormat)
 }
 
-func packageGroups(gs []model.Group, resp []*api.Group) {
-	if gs == nil || resp == nil {
-		return
-	}
+func packageGroups(gs []model.Group) []*api.Group {
+	var resp []*api.Group
 	for _, g := range gs {
 		if !g.HasValue() {
 			continue
@@ -179,6 +226,7 @@ func packageGroups(gs []model.Group, resp []*api.Group) {
 		packageGroup(&g, rg)
 		resp = append(resp, rg)
 	}
+	return resp
 }
 
 func packageDevice(d *model.Device, resp *api.Device) {
@@ -192,10 +240,8 @@ func packageDevice(d *model.Device, resp *api.Device) {
 	resp.LastUpdated = d.UpdateDate.Format(config.TimeFormat)
 }
 
-func packageDevices(ds []model.Device, resp []*api.Device) {
-	if ds == nil || resp == nil {
-		return
-	}
+func packageDevices(ds []model.Device) []*api.Device {
+	var resp []*api.Device
 	for _, d := range ds {
 		if !d.HasValue() {
 			continue
@@ -204,4 +250,120 @@ func packageDevices(ds []model.Device, resp []*api.Device) {
 		packageDevice(&d, rd)
 		resp = append(resp, rd)
 	}
+	return resp
+}
+
+func packageUserExport(exp *model.UserExport, resp *api.UserExport) {
+	if exp == nil || resp == nil {
+		return
+	}
+
+	resp.User = &api.User{}
+	packageUser(&exp.User, resp.User)
+	resp.Exported = exp.ExportDate.Format(config.TimeFormat)
+
+	for _, k := range exp.APIKeys {
+		if !k.HasValue() {
+			continue
+		}
+		resp.APIKeys = append(resp.APIKeys, &api.APIKey{
+			ID:          k.ID,
+			UserID:      k.UserID,
+			Created:     k.CreateDate.Format(config.TimeFormat),
+			LastUpdated: k.UpdateDate.Format(config.TimeFormat),
+		})
+	}
+	for _, s := range exp.Sessions {
+		if !s.HasValue() {
+			continue
+		}
+		resp.Sessions = append(resp.Sessions, &api.Session{
+			ID:        s.ID,
+			UserID:    s.UserID,
+			DeviceID:  s.DeviceID,
+			IPAddress: s.IPAddress,
+			UserAgent: s.UserAgent,
+			Revoked:   s.IsRevoked,
+			Created:   s.CreateDate.Format(config.TimeFormat),
+			LastSeen:  s.LastSeen.Format(config.TimeFormat),
+		})
+	}
+	for _, rt := range exp.RefreshTokens {
+		if !rt.HasValue() {
+			continue
+		}
+		resp.RefreshTokens = append(resp.RefreshTokens, &api.IssuedToken{
+			ID:       rt.ID,
+			APIKeyID: rt.APIKeyID,
+			FamilyID: rt.FamilyID,
+			Used:     rt.IsUsed,
+			Revoked:  rt.IsRevoked,
+			Issued:   rt.IssueDate.Format(config.TimeFormat),
+			Expires:  rt.ExpiryDate.Format(config.TimeFormat),
+		})
+	}
+	resp.EmailTokens = packageDBTokens(exp.EmailTokens)
+	resp.PhoneTokens = packageDBTokens(exp.PhoneTokens)
+	for _, h := range exp.LoginHistory {
+		if !h.HasValue() {
+			continue
+		}
+		resp.LoginHistory = append(resp.LoginHistory, &api.History{
+			ID:         h.ID,
+			UserID:     h.UserID,
+			AccessType: h.AccessType,
+			LoginType:  h.LoginType,
+			Successful: h.Successful,
+			IPAddress:  h.IPAddress,
+			UserAgent:  h.UserAgent,
+			Created:    h.CreateDate.Format(config.TimeFormat),
+		})
+	}
+	for _, li := range exp.LinkedIdentities {
+		if !li.HasValue() {
+			continue
+		}
+		resp.LinkedIdentities = append(resp.LinkedIdentities, &api.LinkedIdentity{
+			ID:          li.ID,
+			UserID:      li.UserID,
+			Issuer:      li.Issuer,
+			Subject:     li.Subject,
+			Created:     li.CreateDate.Format(config.TimeFormat),
+			LastUpdated: li.UpdateDate.Format(config.TimeFormat),
+		})
+	}
+	if exp.TOTP.HasValue() {
+		resp.Totp = &api.TOTPStatus{
+			Enrolled:    true,
+			Confirmed:   exp.TOTP.IsConfirmed,
+			Created:     exp.TOTP.CreateDate.Format(config.TimeFormat),
+			LastUpdated: exp.TOTP.UpdateDate.Format(config.TimeFormat),
+		}
+	}
+	for _, lf := range exp.LoginFailures {
+		resp.LoginFailures = append(resp.LoginFailures, &api.LoginFailure{
+			ID:         lf.ID,
+			LoginType:  lf.LoginType,
+			Identifier: lf.Identifier,
+			IPAddress:  lf.IPAddress,
+			Created:    lf.CreateDate.Format(config.TimeFormat),
+		})
+	}
+}
+
+func packageDBTokens(dbts []model.DBToken) []*api.IssuedToken {
+	var resp []*api.IssuedToken
+	for _, dbt := range dbts {
+		if dbt.ID == "" {
+			continue
+		}
+		resp = append(resp, &api.IssuedToken{
+			ID:      dbt.ID,
+			Address: dbt.Address,
+			Used:    dbt.IsUsed,
+			Issued:  dbt.IssueDate.Format(config.TimeFormat),
+			Expires: dbt.ExpiryDate.Format(config.TimeFormat),
+		})
+	}
+	return resp
 }
@@ -0,0 +1 @@
+Use the code {{.Code}} to log in {{if .AppName}}to {{.AppName}}{{end}}. Do not share this code with anyone.
\ No newline at end of file
@@ -59,6 +59,12 @@ fi
 if [ ! -f "${PHONE_VERIFY_TPL}" ]; then
     cp "verify_sms.tpl" "${PHONE_VERIFY_TPL}" || exit 1
 fi
+if [ ! -f "${PHONE_LOGIN_TPL}" ]; then
+    cp "login_sms.tpl" "${PHONE_LOGIN_TPL}" || exit 1
+fi
+if [ ! -f "${EMAIL_LOGIN_LINK_TPL}" ]; then
+    cp "login_link_email.html" "${EMAIL_LOGIN_LINK_TPL}" || exit 1
+fi
 
 mkdir -p "${INSTALL_DIR}" || exit 1
 cp -f ../bin/app "${INSTALL_FILE}" || exit 1
@@ -16,4 +16,6 @@ EMAIL_RESET_PASS_TPL="/etc/authms/templates/authmsv0_email_reset_pass.html"
 PHONE_RESET_PASS_TPL="/etc/authms/templates/authmsv0_phone_reset_pass.tpl"
 EMAIL_VERIFY_TPL="/etc/authms/templates/authmsv0_email_verify.html"
 PHONE_VERIFY_TPL="/etc/authms/templates/authmsv0_phone_verify.tpl"
+PHONE_LOGIN_TPL="/etc/authms/templates/authmsv0_phone_login.tpl"
+EMAIL_LOGIN_LINK_TPL="/etc/authms/templates/authmsv0_email_login_link.html"
 DOCS_DIR="/etc/authms/docs"
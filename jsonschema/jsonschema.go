@@ -0,0 +1,215 @@
+// Package jsonschema validates JSON values against a subset of JSON Schema
+// (draft-07) sufficient for describing flat profile data.
+//
+// The supported keywords are type, properties, required,
+// additionalProperties (boolean form only), items, enum, minLength,
+// maxLength, pattern, minimum and maximum. Other keywords are ignored as
+// the specification requires of unknown keywords.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+var validTypes = map[string]bool{
+	TypeObject:  true,
+	TypeArray:   true,
+	TypeString:  true,
+	TypeNumber:  true,
+	TypeInteger: true,
+	TypeBoolean: true,
+	TypeNull:    true,
+}
+
+// Schema is a parsed JSON Schema. Use Parse() to construct.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Parse parses the JSON Schema in b returning a ClientError if it is
+// malformed or uses an unsupported type.
+func Parse(b []byte) (*Schema, error) {
+	s := new(Schema)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, errors.NewClientf("invalid JSON schema: %v", err)
+	}
+	if err := s.compile("#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) compile(path string) error {
+	if s.Type != "" && !validTypes[s.Type] {
+		return errors.NewClientf("invalid JSON schema: %s: unsupported type '%s'",
+			path, s.Type)
+	}
+	if s.Pattern != "" {
+		var err error
+		if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return errors.NewClientf("invalid JSON schema: %s: pattern: %v", path, err)
+		}
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return errors.NewClientf("invalid JSON schema: %s/properties/%s was null",
+				path, name)
+		}
+		if err := prop.compile(path + "/properties/" + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.compile(path + "/items"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns a ClientError describing the first violation of s by
+// v, where v is a value as decoded by encoding/json into an interface{}
+// (map[string]interface{}, []interface{}, string, float64, bool or nil).
+func (s *Schema) Validate(v interface{}) error {
+	return s.validate("", v)
+}
+
+func (s *Schema) validate(path string, v interface{}) error {
+
+	if s.Type != "" && !isType(s.Type, v) {
+		return violationf(path, "expected %s", s.Type)
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return violationf(path, "must be one of %v", s.Enum)
+	}
+
+	switch val := v.(type) {
+	case string:
+		length := utf8.RuneCountInString(val)
+		if s.MinLength != nil && length < *s.MinLength {
+			return violationf(path, "must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return violationf(path, "must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			return violationf(path, "must match '%s'", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			return violationf(path, "must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			return violationf(path, "must be at most %v", *s.Maximum)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			break
+		}
+		for i, item := range val {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				return violationf(joinPath(path, name), "is required")
+			}
+		}
+		for name, propV := range val {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return violationf(joinPath(path, name), "is not allowed")
+				}
+				continue
+			}
+			if err := prop.validate(joinPath(path, name), propV); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func isType(typ string, v interface{}) bool {
+	switch typ {
+	case TypeObject:
+		_, ok := v.(map[string]interface{})
+		return ok
+	case TypeArray:
+		_, ok := v.([]interface{})
+		return ok
+	case TypeString:
+		_, ok := v.(string)
+		return ok
+	case TypeNumber:
+		_, ok := v.(float64)
+		return ok
+	case TypeInteger:
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case TypeBoolean:
+		_, ok := v.(bool)
+		return ok
+	case TypeNull:
+		return v == nil
+	default:
+		return false
+	}
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func violationf(path, format string, args ...interface{}) error {
+	if path == "" {
+		path = "value"
+	}
+	return errors.NewClientf(path+" "+format, args...)
+}
@@ -0,0 +1,108 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tomogoma/authms/jsonschema"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+var isClErr = new(errors.ClErrCheck).IsClientError
+
+const profileSchema = `{
+	"type": "object",
+	"properties": {
+		"firstName": {"type": "string", "minLength": 1, "maxLength": 10},
+		"locale": {"type": "string", "enum": ["en", "sw"]},
+		"age": {"type": "integer", "minimum": 0, "maximum": 150},
+		"phone": {"type": "string", "pattern": "^\\+[0-9]+$"},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	},
+	"required": ["firstName"],
+	"additionalProperties": false
+}`
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name     string
+		schema   string
+		expClErr bool
+	}{
+		{name: "valid", schema: profileSchema},
+		{name: "empty schema", schema: `{}`},
+		{name: "malformed JSON", schema: `{"type":`, expClErr: true},
+		{name: "unsupported type", schema: `{"type": "date"}`, expClErr: true},
+		{
+			name:     "nested unsupported type",
+			schema:   `{"properties": {"a": {"type": "date"}}}`,
+			expClErr: true,
+		},
+		{name: "invalid pattern", schema: `{"pattern": "("}`, expClErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := jsonschema.Parse([]byte(tc.schema))
+			if tc.expClErr {
+				if !isClErr(err) {
+					t.Fatalf("Expected client error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if s == nil {
+				t.Fatalf("Got nil schema")
+			}
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := jsonschema.Parse([]byte(profileSchema))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tt := []struct {
+		name     string
+		value    string
+		expClErr bool
+	}{
+		{name: "minimal", value: `{"firstName": "John"}`},
+		{
+			name:  "all properties",
+			value: `{"firstName": "John", "locale": "sw", "age": 30, "phone": "+254712345678", "tags": ["a", "b"]}`,
+		},
+		{name: "not an object", value: `"John"`, expClErr: true},
+		{name: "missing required", value: `{"locale": "en"}`, expClErr: true},
+		{name: "additional property", value: `{"firstName": "John", "x": 1}`, expClErr: true},
+		{name: "wrong type", value: `{"firstName": 1}`, expClErr: true},
+		{name: "too short", value: `{"firstName": ""}`, expClErr: true},
+		{name: "too long", value: `{"firstName": "Johnathan Doe"}`, expClErr: true},
+		{name: "not in enum", value: `{"firstName": "John", "locale": "fr"}`, expClErr: true},
+		{name: "not an integer", value: `{"firstName": "John", "age": 30.5}`, expClErr: true},
+		{name: "below minimum", value: `{"firstName": "John", "age": -1}`, expClErr: true},
+		{name: "above maximum", value: `{"firstName": "John", "age": 151}`, expClErr: true},
+		{name: "pattern mismatch", value: `{"firstName": "John", "phone": "0712"}`, expClErr: true},
+		{name: "bad array item", value: `{"firstName": "John", "tags": ["a", 1]}`, expClErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(tc.value), &v); err != nil {
+				t.Fatalf("Unmarshal test value: %v", err)
+			}
+			err := s.Validate(v)
+			if tc.expClErr {
+				if !isClErr(err) {
+					t.Fatalf("Expected client error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Package keyset signs and validates JWTs using a set of asymmetric keys
+// (RS256 or ES256) whose public halves are published as a JWKS.
+//
+// Every token is signed by the active key and carries the key's ID in the
+// kid header. Keys that are no longer active keep validating tokens until
+// they expire, which allows keys to be rotated without invalidating
+// tokens that are already in circulation.
+package keyset
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/tomogoma/authms/oidc"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+// Key is a private key used to sign tokens.
+type Key struct {
+	// ID is the unique key ID set as the kid header of tokens signed by
+	// this key.
+	ID string
+	// PrivateKey is either an *rsa.PrivateKey (RS256) or a P-256
+	// *ecdsa.PrivateKey (ES256).
+	PrivateKey crypto.Signer
+	// Retired keys are not used to sign new tokens but still validate
+	// tokens they previously signed.
+	Retired bool
+	// ExpiresAt, if not zero, is the time after which the key neither
+	// validates tokens nor is published. It should be later than the
+	// expiry of the last token the key signed.
+	ExpiresAt time.Time
+}
+
+// KeySet handles JWTs signed by a set of Keys. Use New() to construct.
+type KeySet struct {
+	errors.AuthErrCheck
+	mutex    sync.RWMutex
+	keys     []Key
+	hs256Key []byte
+}
+
+// Option is used by New() to pass additional configuration.
+type Option func(*KeySet)
+
+// WithHS256Key sets a legacy HMAC key. Tokens signed with HS256 and no kid
+// are validated using this key. If the KeySet has no active asymmetric key
+// then new tokens are also signed using this key.
+func WithHS256Key(key []byte) Option {
+	return func(ks *KeySet) {
+		ks.hs256Key = key
+	}
+}
+
+var validMethods = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodHS256.Alg(),
+}
+
+// New creates a KeySet with keys. The first unexpired key that is not
+// retired is the active signing key. At least one key or an HS256 key
+// (see WithHS256Key) must be provided.
+func New(keys []Key, opts ...Option) (*KeySet, error) {
+	ks := &KeySet{}
+	for _, opt := range opts {
+		opt(ks)
+	}
+	if len(keys) == 0 && len(ks.hs256Key) == 0 {
+		return nil, errors.New("no signing keys provided")
+	}
+	for _, k := range keys {
+		if err := ks.add(k); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// ParsePrivateKey parses a PEM encoded RSA (PKCS1 or PKCS8) or EC (SEC1 or
+// PKCS8) private key.
+func ParsePrivateKey(PEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(PEM)
+	if block == nil {
+		return nil, errors.New("key is not PEM encoded")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("key is neither an RSA nor an EC private key")
+	}
+	signer, ok := k.(crypto.Signer)
+	if !ok || signingMethod(signer) == nil {
+		return nil, errors.Newf("unsupported private key type %T", k)
+	}
+	return signer, nil
+}
+
+// Rotate makes k the active signing key. Previously active keys are
+// retired and expire after retainFor, which should be no less than the
+// validity of the longest lived token they may have signed.
+func (ks *KeySet) Rotate(k Key, retainFor time.Duration) error {
+	if k.Retired {
+		return errors.New("cannot rotate to a retired key")
+	}
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	if err := ks.add(k); err != nil {
+		return err
+	}
+	expiry := time.Now().Add(retainFor)
+	for i := range ks.keys[:len(ks.keys)-1] {
+		if ks.keys[i].Retired {
+			continue
+		}
+		ks.keys[i].Retired = true
+		if ks.keys[i].ExpiresAt.IsZero() || ks.keys[i].ExpiresAt.After(expiry) {
+			ks.keys[i].ExpiresAt = expiry
+		}
+	}
+	// Move k to the front so that it becomes the active key.
+	ks.keys = append([]Key{k}, ks.keys[:len(ks.keys)-1]...)
+	return nil
+}
+
+// Generate generates a JWT from claims signed by the active key.
+func (ks *KeySet) Generate(claims jwt.Claims) (string, error) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	k, ok := ks.activeKey()
+	if !ok {
+		if len(ks.hs256Key) == 0 {
+			return "", errors.New("no active signing key")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.hs256Key)
+	}
+
+	tkn := jwt.NewWithClaims(signingMethod(k.PrivateKey), claims)
+	tkn.Header["kid"] = k.ID
+	return tkn.SignedString(k.PrivateKey)
+}
+
+// Validate validates token, unmarshalling its claims into cs which must be
+// a non-nil pointer.
+//
+// The returned error will evaluate (*KeySet).IsAuthError(err) to true if
+// the token is invalid or was signed by an unknown or expired key.
+func (ks *KeySet) Validate(token string, cs jwt.Claims) (*jwt.Token, error) {
+	if token == "" {
+		return nil, errors.NewUnauthorized("token was empty")
+	}
+	if cs == nil || reflect.ValueOf(cs).IsNil() {
+		return nil, errors.New("claims not provided")
+	}
+
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	parser := &jwt.Parser{ValidMethods: validMethods}
+	tkn, err := parser.ParseWithClaims(token, cs, ks.validationKey)
+	if err != nil {
+		return nil, errors.NewForbidden("invalid token")
+	}
+	return tkn, nil
+}
+
+// JWKS returns the public keys of all unexpired keys.
+func (ks *KeySet) JWKS() oidc.JWKS {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	set := oidc.JWKS{Keys: []oidc.JWK{}}
+	now := time.Now()
+	for _, k := range ks.keys {
+		if isExpired(k, now) {
+			continue
+		}
+		// Keys are checked on add so this never fails.
+		jwk, _ := oidc.NewJWK(k.ID, k.PrivateKey.Public())
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (ks *KeySet) validationKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if kid != "" || len(ks.hs256Key) == 0 {
+			return nil, errors.NewForbidden("invalid token")
+		}
+		return ks.hs256Key, nil
+	}
+	now := time.Now()
+	for _, k := range ks.keys {
+		if k.ID != kid {
+			continue
+		}
+		if isExpired(k, now) || signingMethod(k.PrivateKey) != t.Method {
+			break
+		}
+		return k.PrivateKey.Public(), nil
+	}
+	return nil, errors.NewForbidden("invalid token")
+}
+
+// add appends k to the set. The caller must hold a write lock if the
+// KeySet is in use.
+func (ks *KeySet) add(k Key) error {
+	if k.ID == "" {
+		return errors.New("key ID was empty")
+	}
+	if k.PrivateKey == nil {
+		return errors.Newf("private key for '%s' was nil", k.ID)
+	}
+	if _, err := oidc.NewJWK(k.ID, k.PrivateKey.Public()); err != nil {
+		return errors.Newf("key '%s': %v", k.ID, err)
+	}
+	for _, existing := range ks.keys {
+		if existing.ID == k.ID {
+			return errors.Newf("duplicate key ID '%s'", k.ID)
+		}
+	}
+	ks.keys = append(ks.keys, k)
+	return nil
+}
+
+func (ks *KeySet) activeKey() (Key, bool) {
+	now := time.Now()
+	for _, k := range ks.keys {
+		if !k.Retired && !isExpired(k, now) {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func isExpired(k Key, now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+func signingMethod(k crypto.Signer) jwt.SigningMethod {
+	switch pk := k.(type) {
+	case *ecdsa.PrivateKey:
+		if pk.Curve == elliptic.P256() {
+			return jwt.SigningMethodES256
+		}
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package keyset_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/tomogoma/authms/keyset"
+)
+
+func TestNew(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	tt := []struct {
+		name   string
+		keys   []keyset.Key
+		opts   []keyset.Option
+		expErr bool
+	}{
+		{name: "RSA key", keys: []keyset.Key{{ID: "rsa", PrivateKey: rsaKey}}},
+		{name: "EC key", keys: []keyset.Key{{ID: "ec", PrivateKey: newECKey(t, elliptic.P256())}}},
+		{name: "HS256 key only", opts: []keyset.Option{keyset.WithHS256Key([]byte("some-key"))}},
+		{name: "no keys", expErr: true},
+		{name: "missing key ID", keys: []keyset.Key{{PrivateKey: rsaKey}}, expErr: true},
+		{name: "nil private key", keys: []keyset.Key{{ID: "rsa"}}, expErr: true},
+		{
+			name:   "unsupported curve",
+			keys:   []keyset.Key{{ID: "ec", PrivateKey: newECKey(t, elliptic.P384())}},
+			expErr: true,
+		},
+		{
+			name:   "duplicate key ID",
+			keys:   []keyset.Key{{ID: "rsa", PrivateKey: rsaKey}, {ID: "rsa", PrivateKey: newRSAKey(t)}},
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ks, err := keyset.New(tc.keys, tc.opts...)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if ks == nil {
+				t.Fatalf("Got nil KeySet")
+			}
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey := newRSAKey(t).(*rsa.PrivateKey)
+	ecKey := newECKey(t, elliptic.P256()).(*ecdsa.PrivateKey)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("Error setting up: marshal EC key: %v", err)
+	}
+	tt := []struct {
+		name   string
+		PEM    []byte
+		expErr bool
+	}{
+		{name: "PKCS1 RSA", PEM: encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))},
+		{name: "SEC1 EC", PEM: encodePEM("EC PRIVATE KEY", ecDER)},
+		{name: "not PEM", PEM: []byte("not a key"), expErr: true},
+		{name: "garbage DER", PEM: encodePEM("PRIVATE KEY", []byte("garbage")), expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			k, err := keyset.ParsePrivateKey(tc.PEM)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if k == nil {
+				t.Fatalf("Got nil key")
+			}
+		})
+	}
+}
+
+func TestKeySet_GenerateValidate(t *testing.T) {
+	tt := []struct {
+		name   string
+		key    crypto.Signer
+		expAlg string
+	}{
+		{name: "RS256", key: newRSAKey(t), expAlg: "RS256"},
+		{name: "ES256", key: newECKey(t, elliptic.P256()), expAlg: "ES256"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := newKeySet(t, []keyset.Key{{ID: "key-1", PrivateKey: tc.key}})
+			tkn, err := ks.Generate(newClaims("user-1"))
+			if err != nil {
+				t.Fatalf("Generate() error: %v", err)
+			}
+			clms := new(jwt.StandardClaims)
+			parsed, err := ks.Validate(tkn, clms)
+			if err != nil {
+				t.Fatalf("Validate() error: %v", err)
+			}
+			if parsed.Header["kid"] != "key-1" {
+				t.Errorf("Expected kid 'key-1', got '%v'", parsed.Header["kid"])
+			}
+			if parsed.Method.Alg() != tc.expAlg {
+				t.Errorf("Expected alg '%s', got '%s'", tc.expAlg, parsed.Method.Alg())
+			}
+			if clms.Subject != "user-1" {
+				t.Errorf("Expected subject 'user-1', got '%s'", clms.Subject)
+			}
+		})
+	}
+}
+
+func TestKeySet_Validate(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	retiredKey := newECKey(t, elliptic.P256())
+	expiredKey := newRSAKey(t)
+	hs256Key := []byte("some-hs256-key")
+	ks := newKeySet(t, []keyset.Key{
+		{ID: "active", PrivateKey: rsaKey},
+		{ID: "retired", PrivateKey: retiredKey, Retired: true},
+		{ID: "expired", PrivateKey: expiredKey, ExpiresAt: time.Now().Add(-1 * time.Minute)},
+	}, keyset.WithHS256Key(hs256Key))
+	otherKS := newKeySet(t, []keyset.Key{{ID: "active", PrivateKey: newRSAKey(t)}})
+
+	tt := []struct {
+		name         string
+		token        func() string
+		expForbidden bool
+	}{
+		{
+			name:  "active key",
+			token: func() string { return sign(t, jwt.SigningMethodRS256, "active", rsaKey) },
+		},
+		{
+			name:  "retired key",
+			token: func() string { return sign(t, jwt.SigningMethodES256, "retired", retiredKey) },
+		},
+		{
+			name:  "legacy HS256 key",
+			token: func() string { return sign(t, jwt.SigningMethodHS256, "", hs256Key) },
+		},
+		{
+			name:         "expired key",
+			token:        func() string { return sign(t, jwt.SigningMethodRS256, "expired", expiredKey) },
+			expForbidden: true,
+		},
+		{
+			name: "signed by other key",
+			token: func() string {
+				tkn, err := otherKS.Generate(newClaims("user-1"))
+				if err != nil {
+					t.Fatalf("Error setting up: generate: %v", err)
+				}
+				return tkn
+			},
+			expForbidden: true,
+		},
+		{
+			name:         "unknown key ID",
+			token:        func() string { return sign(t, jwt.SigningMethodRS256, "none-such", rsaKey) },
+			expForbidden: true,
+		},
+		{
+			name:         "HS256 with key ID",
+			token:        func() string { return sign(t, jwt.SigningMethodHS256, "active", hs256Key) },
+			expForbidden: true,
+		},
+		{
+			name:         "malformed",
+			token:        func() string { return "not.a.token" },
+			expForbidden: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ks.Validate(tc.token(), new(jwt.StandardClaims))
+			if tc.expForbidden {
+				if !ks.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotate(t *testing.T) {
+	ks := newKeySet(t, []keyset.Key{{ID: "old", PrivateKey: newRSAKey(t)}})
+	oldTkn, err := ks.Generate(newClaims("user-1"))
+	if err != nil {
+		t.Fatalf("Error setting up: generate with old key: %v", err)
+	}
+
+	if err := ks.Rotate(keyset.Key{ID: "new", PrivateKey: newECKey(t, elliptic.P256())}, time.Hour); err != nil {
+		t.Fatalf("Rotate() error: %v", err)
+	}
+
+	newTkn, err := ks.Generate(newClaims("user-1"))
+	if err != nil {
+		t.Fatalf("Generate() after rotate error: %v", err)
+	}
+	parsed, err := ks.Validate(newTkn, new(jwt.StandardClaims))
+	if err != nil {
+		t.Fatalf("Validate() new token error: %v", err)
+	}
+	if parsed.Header["kid"] != "new" {
+		t.Errorf("Expected new tokens signed by 'new', got '%v'", parsed.Header["kid"])
+	}
+	if _, err := ks.Validate(oldTkn, new(jwt.StandardClaims)); err != nil {
+		t.Errorf("Expected token signed by retired key to validate, got %v", err)
+	}
+	if jwks := ks.JWKS(); len(jwks.Keys) != 2 {
+		t.Errorf("Expected 2 published keys, got %d", len(jwks.Keys))
+	}
+
+	if err := ks.Rotate(keyset.Key{ID: "newest", PrivateKey: newRSAKey(t)}, -1*time.Minute); err != nil {
+		t.Fatalf("Rotate() second time error: %v", err)
+	}
+	if _, err := ks.Validate(newTkn, new(jwt.StandardClaims)); !ks.IsForbiddenError(err) {
+		t.Errorf("Expected token signed by expired key to be forbidden, got %v", err)
+	}
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 published keys, got %d", len(jwks.Keys))
+	}
+	for _, k := range jwks.Keys {
+		if k.KeyID == "new" {
+			t.Errorf("Expected expired key not to be published")
+		}
+	}
+
+	if err := ks.Rotate(keyset.Key{ID: "newest", PrivateKey: newRSAKey(t)}, time.Hour); err == nil {
+		t.Errorf("Expected an error rotating to a duplicate key ID")
+	}
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	privKeys := map[string]crypto.Signer{
+		"rsa": newRSAKey(t),
+		"ec":  newECKey(t, elliptic.P256()),
+	}
+	ks := newKeySet(t, []keyset.Key{
+		{ID: "rsa", PrivateKey: privKeys["rsa"]},
+		{ID: "ec", PrivateKey: privKeys["ec"], Retired: true},
+	})
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(jwks.Keys))
+	}
+	for _, jwk := range jwks.Keys {
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("Published key '%s' invalid: %v", jwk.KeyID, err)
+		}
+		if !publicKeyMatches(pub, privKeys[jwk.KeyID]) {
+			t.Errorf("Published key '%s' does not match private key", jwk.KeyID)
+		}
+	}
+
+	hmacOnly := newKeySet(t, nil, keyset.WithHS256Key([]byte("some-key")))
+	if jwks := hmacOnly.JWKS(); jwks.Keys == nil || len(jwks.Keys) != 0 {
+		t.Errorf("Expected an empty non-nil key list, got %+v", jwks.Keys)
+	}
+}
+
+func newKeySet(t *testing.T, keys []keyset.Key, opts ...keyset.Option) *keyset.KeySet {
+	ks, err := keyset.New(keys, opts...)
+	if err != nil {
+		t.Fatalf("Error setting up: new KeySet: %v", err)
+	}
+	return ks
+}
+
+func newRSAKey(t *testing.T) crypto.Signer {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error setting up: generate RSA key: %v", err)
+	}
+	return k
+}
+
+func newECKey(t *testing.T, crv elliptic.Curve) crypto.Signer {
+	k, err := ecdsa.GenerateKey(crv, rand.Reader)
+	if err != nil {
+		t.Fatalf("Error setting up: generate EC key: %v", err)
+	}
+	return k
+}
+
+func newClaims(sub string) jwt.Claims {
+	return jwt.StandardClaims{Subject: sub, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	tkn := jwt.NewWithClaims(method, newClaims("user-1"))
+	if kid != "" {
+		tkn.Header["kid"] = kid
+	}
+	str, err := tkn.SignedString(key)
+	if err != nil {
+		t.Fatalf("Error setting up: sign token: %v", err)
+	}
+	return str
+}
+
+func encodePEM(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func publicKeyMatches(pub interface{}, priv crypto.Signer) bool {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		k, ok := priv.Public().(*rsa.PublicKey)
+		return ok && k.N.Cmp(p.N) == 0 && k.E == p.E
+	case *ecdsa.PublicKey:
+		k, ok := priv.Public().(*ecdsa.PublicKey)
+		return ok && k.X.Cmp(p.X) == 0 && k.Y.Cmp(p.Y) == 0
+	}
+	return false
+}
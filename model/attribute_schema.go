@@ -0,0 +1,15 @@
+package model
+
+import "encoding/json"
+
+// AttributeSchema is the JSON Schema a tenant's users' custom Attributes
+// must conform to. The attributes named in ClaimAttributes are included in
+// the JWTs issued to users and so can only be set by admins.
+type AttributeSchema struct {
+	Schema          json.RawMessage
+	ClaimAttributes []string
+}
+
+func (as AttributeSchema) HasValue() bool {
+	return len(as.Schema) > 0
+}
@@ -2,10 +2,14 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"html/template"
 	"net/url"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,6 +18,10 @@ import (
 	"fmt"
 	"github.com/badoux/checkmail"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/jsonschema"
+	"github.com/tomogoma/authms/passhash"
+	"github.com/tomogoma/authms/totp"
 	"github.com/tomogoma/go-typed-errors"
 	"github.com/ttacon/libphonenumber"
 	"golang.org/x/crypto/bcrypt"
@@ -28,18 +36,37 @@ type AuthStore interface {
 	IsNotFoundError(error) bool
 	ExecuteTx(fn func(*sql.Tx) error) error
 
+	// ForTenant returns an AuthStore whose users, groups, API keys and
+	// login identifiers are scoped to the tenant having tenantID.
+	ForTenant(tenantID string) AuthStore
+	InsertTenant(name string) (*Tenant, error)
+	Tenant(id string) (*Tenant, error)
+	TenantByName(name string) (*Tenant, error)
+	Tenants(offset, count int64) ([]Tenant, error)
+
 	InsertGroup(name string, acl float32) (*Group, error)
+	UpdateGroup(id, name string, acl float32) (*Group, error)
+	SetGroupPermissions(id string, perms []string) error
+	DeleteGroup(id string) error
 	Group(string) (*Group, error)
 	GroupByName(string) (*Group, error)
 	Groups(offset, count int64) ([]Group, error)
 
-	InsertUserType(name string) (*UserType, error)
+	InsertUserType(name string, selfReg bool, reqLoginTypes []string) (*UserType, error)
+	UpdateUserType(id, name string, selfReg bool, reqLoginTypes []string) (*UserType, error)
+	DeleteUserType(id string) error
+	UserType(id string) (*UserType, error)
 	UserTypeByName(string) (*UserType, error)
+	UserTypes(offset, count int64) ([]UserType, error)
+	HasUsersOfType(typeID string) error
 
 	HasUsers(groupID string) error
 	InsertUserAtomic(tx *sql.Tx, t UserType, g Group, password []byte) (*User, error)
 	UpdatePassword(userID string, password []byte) error
 	UpdatePasswordAtomic(tx *sql.Tx, userID string, password []byte) error
+	InsertPassHistoryAtomic(tx *sql.Tx, userID string, password []byte) error
+	DeleteUserAtomic(tx *sql.Tx, userID string) error
+	PassHistory(userID string, count int) ([][]byte, error)
 	User(id string) (*User, []byte, error)
 	UserByDeviceID(devID string) (*User, []byte, error)
 	UserByUsername(username string) (*User, []byte, error)
@@ -49,17 +76,30 @@ type AuthStore interface {
 	Users(q UsersQuery, offset, count int64) ([]User, error)
 
 	SetUserGroup(userID, groupID string) error
+	SetUserStatus(userID string, s UserStatus) error
+	UserStatus(userID string) (*UserStatus, error)
+	SetLoggedOutAll(userID string, at time.Time) error
+	SetUserAttributes(userID string, attrs map[string]interface{}) error
+
+	UpsertAttributeSchema(s interface{}) error
+	GetAttributeSchema(s interface{}) error
 
 	InsertUserDeviceAtomic(tx *sql.Tx, userID, devID string) (*Device, error)
 
 	InsertUserName(userID, username string) (*Username, error)
 	InsertUserNameAtomic(tx *sql.Tx, userID, username string) (*Username, error)
+	DeleteUserName(userID string) error
 
 	InsertUserPhone(userID, phone string, verified bool) (*VerifLogin, error)
 	InsertUserPhoneAtomic(tx *sql.Tx, userID, phone string, verified bool) (*VerifLogin, error)
+	InsertUnverifiedUserPhone(userID, phone string) (*VerifLogin, error)
 	UpdateUserPhone(userID, phone string, verified bool) (*VerifLogin, error)
 	UpdateUserPhoneAtomic(tx *sql.Tx, userID, phone string, verified bool) (*VerifLogin, error)
-	DeletePhoneTokensAtomic(tx *sql.Tx, phone string) error
+	VerifyUserPhoneAtomic(tx *sql.Tx, userID, phone string) (*VerifLogin, error)
+	UserPhonesByUserID(userID string) ([]VerifLogin, error)
+	SetPrimaryUserPhone(userID, phone string) error
+	DeleteUserPhoneAtomic(tx *sql.Tx, userID, phone string) error
+	DeletePhoneTokensAtomic(tx *sql.Tx, userID, phone string) error
 
 	InsertPhoneToken(userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*DBToken, error)
 	SetPhoneTokenUsedAtomic(tx *sql.Tx, id string) error
@@ -68,9 +108,14 @@ type AuthStore interface {
 
 	InsertUserEmail(userID, email string, verified bool) (*VerifLogin, error)
 	InsertUserEmailAtomic(tx *sql.Tx, userID, email string, verified bool) (*VerifLogin, error)
+	InsertUnverifiedUserEmail(userID, email string) (*VerifLogin, error)
 	UpdateUserEmail(userID, email string, verified bool) (*VerifLogin, error)
 	UpdateUserEmailAtomic(tx *sql.Tx, userID, email string, verified bool) (*VerifLogin, error)
-	DeleteEmailTokensAtomic(tx *sql.Tx, email string) error
+	VerifyUserEmailAtomic(tx *sql.Tx, userID, email string) (*VerifLogin, error)
+	UserEmailsByUserID(userID string) ([]VerifLogin, error)
+	SetPrimaryUserEmail(userID, email string) error
+	DeleteUserEmailAtomic(tx *sql.Tx, userID, email string) error
+	DeleteEmailTokensAtomic(tx *sql.Tx, userID, email string) error
 
 	InsertEmailToken(userID, email string, dbt []byte, isUsed bool, expiry time.Time) (*DBToken, error)
 	SetEmailTokenUsedAtomic(tx *sql.Tx, id string) error
@@ -78,6 +123,51 @@ type AuthStore interface {
 	EmailTokens(userID string, offset, count int64) ([]DBToken, error)
 
 	InsertUserFbIDAtomic(tx *sql.Tx, userID, fbID string, verified bool) (*Facebook, error)
+	DeleteUserFbID(userID string) error
+
+	InsertLinkedIdentityAtomic(tx *sql.Tx, userID, issuer, subject string) (*LinkedIdentity, error)
+	UserByLinkedIdentity(issuer, subject string) (*User, error)
+	LinkedIdentitiesByUserID(userID string) ([]LinkedIdentity, error)
+	DeleteLinkedIdentities(userID string) error
+
+	InsertRefreshToken(userID, apiKeyID, familyID string, tkn []byte, expiry time.Time) (*RefreshToken, error)
+	InsertRefreshTokenAtomic(tx *sql.Tx, userID, apiKeyID, familyID string, tkn []byte, expiry time.Time) (*RefreshToken, error)
+	RefreshToken(id string) (*RefreshToken, error)
+	SetRefreshTokenUsedAtomic(tx *sql.Tx, id string) error
+	RevokeRefreshTokenFamily(familyID string) error
+	RefreshTokensByUserID(userID string, offset, count int64) ([]RefreshToken, error)
+
+	InsertRevokedToken(tokenID, userID string, expiry time.Time) error
+	IsTokenRevoked(tokenID string) (bool, error)
+
+	InsertSession(userID, familyID, deviceID, ipAddress, userAgent string) (*Session, error)
+	Session(id string) (*Session, error)
+	SessionByFamilyID(familyID string) (*Session, error)
+	SessionsByUserID(userID string, offset, count int64) ([]Session, error)
+	SetSessionLastSeen(id string, lastSeen time.Time) error
+	RevokeSession(userID, id string) error
+	RevokeSessions(userID string) error
+
+	InsertLoginFailureAtomic(tx *sql.Tx, loginType, identifier, ipAddress string) (*LoginFailure, error)
+	LoginFailures(loginType, identifier string, since time.Time, offset, count int64) ([]LoginFailure, error)
+	LoginFailuresAtomic(tx *sql.Tx, loginType, identifier string, since time.Time, offset, count int64) ([]LoginFailure, error)
+	LoginFailuresByIP(ipAddress string, since time.Time, count int64) ([]LoginFailure, error)
+	LoginFailuresByIPAtomic(tx *sql.Tx, ipAddress string, since time.Time, count int64) ([]LoginFailure, error)
+	DeleteLoginFailure(id string) error
+	DeleteLoginFailures(loginType, identifier string) error
+	DeleteLoginFailuresByIP(ipAddress string) error
+
+	InsertHistory(userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*History, error)
+	InsertHistoryAtomic(tx *sql.Tx, userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*History, error)
+	HistoryByUserID(userID string, offset, count int64) ([]History, error)
+
+	APIKeyMetaByUserID(userID string, offset, count int64) ([]APIKey, error)
+
+	UpsertTOTPSecret(userID string, secret []byte) (*TOTPSecret, error)
+	TOTPSecret(userID string) (*TOTPSecret, error)
+	ConfirmTOTPSecret(userID string, step int64) error
+	SetTOTPLastStep(userID string, step int64) error
+	DeleteTOTPSecret(userID string) error
 }
 
 type SecureRandomByteser interface {
@@ -89,6 +179,13 @@ type FacebookCl interface {
 	ValidateToken(string) (string, error)
 }
 
+// OIDCCl validates id_tokens issued by OpenID Connect providers returning
+// the issuer and subject (the user's ID at the issuer).
+type OIDCCl interface {
+	IsAuthError(error) bool
+	ValidateIDToken(idToken, nonce string) (issuer, subject string, err error)
+}
+
 type SMSer interface {
 	SMS(toPhone, message string) error
 }
@@ -98,6 +195,27 @@ type JWTEr interface {
 	Validate(JWT string, claims jwt.Claims) (*jwt.Token, error)
 }
 
+type Encrypter interface {
+	Encrypt(plain []byte) ([]byte, error)
+	Decrypt(cipherText []byte) ([]byte, error)
+}
+
+// PwnedCounter looks up how many times a password has been seen in data
+// breaches.
+type PwnedCounter interface {
+	Count(password []byte) (int, error)
+}
+
+// PassHasher hashes passwords and verifies passwords against their hashes.
+// needsRehash is true if hashed was produced by an outdated algorithm or
+// parameters. IsAuthError returns true for errors resulting from a password
+// mismatch.
+type PassHasher interface {
+	IsAuthError(error) bool
+	Hash(password []byte) ([]byte, error)
+	Verify(hashed, password []byte) (needsRehash bool, err error)
+}
+
 type Mailer interface {
 	SendEmail(email SendMail) error
 }
@@ -109,6 +227,7 @@ type Authentication struct {
 	errors.ErrToHTTP
 	// mandatory parameters
 	db              AuthStore
+	tenantID        string
 	jwter           JWTEr
 	passGen         SecureRandomByteser
 	numGen          SecureRandomByteser
@@ -119,6 +238,7 @@ type Authentication struct {
 	// optional parameters
 	appNameEmptyable     string
 	fbNilable            FacebookCl
+	oidcNilable          OIDCCl
 	smserNilable         SMSer
 	mailerNilable        Mailer
 	webAppURLNilable     *url.URL
@@ -126,6 +246,22 @@ type Authentication struct {
 	invSubjEmptyable     string
 	verSubjEmptyable     string
 	resPassSubjEmptyable string
+	lgnLinkSubjEmptyable string
+	lockoutFailCount     int
+	lockoutWindow        time.Duration
+	ipLockoutFailCount   int
+	otpSendLimit         int
+	otpSendWindow        time.Duration
+	mfaEncNilable        Encrypter
+	tokenValidity        time.Duration
+	grpTokenValidities   map[string]time.Duration
+	actionValidities     map[string]time.Duration
+	actionOTPFormats     map[string]otpFormat
+	passPolicy           PasswordPolicy
+	pwnedNilable         PwnedCounter
+	pwnedThreshold       int
+	warnPwnedOnLogin     bool
+	passHasher           PassHasher
 	// tail values optional depending on need/type for communication
 	loginTpActionTplts map[string]map[string]*template.Template
 }
@@ -153,31 +289,100 @@ const (
 	RegionCodeKE = "KE"
 
 	minPassLen = 8
-	genPassLen = 32
-
-	inviteValidity    = 24 * 30 * time.Hour
-	resetValidity     = 2 * time.Hour
-	verifyValidity    = 5 * time.Minute
-	extendTknValidity = 2 * time.Hour
-	tokenValidity     = 1 * time.Hour
+	// maxStatusReasonLen is the maximum length of the reason given for
+	// a user's status.
+	maxStatusReasonLen = 256
+	genPassLen         = 32
+
+	// maxGroupNameLen and the access level range are limited by the
+	// groups table.
+	maxGroupNameLen = 56
+	minAccessLevel  = float32(0)
+	maxAccessLevel  = float32(10)
+	// maxTenantNameLen and maxUserTypeNameLen are limited by the tenants
+	// and userTypes tables.
+	maxTenantNameLen   = 56
+	maxUserTypeNameLen = 56
+	// maxAttributesSize is the maximum size of a user's JSON encoded
+	// custom attributes.
+	maxAttributesSize = 8192
+
+	// defaults overridable through Options.
+	defInviteValidity    = 24 * 30 * time.Hour
+	defResetValidity     = 2 * time.Hour
+	defVerifyValidity    = 5 * time.Minute
+	defExtendTknValidity = 2 * time.Hour
+	defLoginLinkValidity = 15 * time.Minute
+	defOTPSendLimit      = 3
+	defOTPSendWindow     = 15 * time.Minute
+	defTokenValidity     = 1 * time.Hour
+	defOTPLen            = 6
+
+	// defIPLockoutFailFactor is the default IP lockout fail count as a
+	// multiple of the login lockout fail count.
+	defIPLockoutFailFactor = 10
+
+	refreshTknValidity = 24 * 30 * time.Hour
+	mfaTknValidity     = 5 * time.Minute
+	// loginLinkNonceLen is the length of the nonce binding a login link to
+	// the client that requested it.
+	loginLinkNonceLen = 32
+	// actionConfirm binds codes sent through SendConfirmCode() to
+	// re-confirming a user's identity (see confirmCodeSecret()).
+	actionConfirm = "confirm"
+	// sessionSeenInterval limits how often a session's last seen time is
+	// updated as its JWTs are used.
+	sessionSeenInterval = 1 * time.Minute
+	// exportPageSize is the number of records fetched at a time when
+	// exporting a user's data.
+	exportPageSize = int64(100)
+	// maxAddressesPerType is the maximum number of emails (or phones) a
+	// user can have.
+	maxAddressesPerType = 5
 
 	ActionInvite    = "invite"
 	ActionVerify    = "verify"
 	ActionResetPass = "reset/password"
 	ActionExtendTkn = "extend/token"
+	ActionLogin     = "login"
 
 	LoginTypeUsername = "usernames"
 	LoginTypeEmail    = "emails"
 	LoginTypePhone    = "phones"
 	LoginTypeFacebook = "facebook"
+	LoginTypeOIDC     = "oidc"
 	LoginTypeDev      = "devices"
+	LoginTypeMFA      = "mfa"
+
+	AccessTypeLogin     = "login"
+	AccessTypeResetPass = "reset/password"
+	AccessTypeVerify    = "verify"
+	AccessTypeMFA       = "mfa"
+	AccessTypeConfirm   = "confirm"
 
 	defaultOffset = 0
 	defaultCount  = 10
 )
 
 var (
-	validUserTypes = []string{UserTypeIndividual, UserTypeCompany}
+	// builtInUserTypes are created as needed (self registrable and with
+	// no required login types) and cannot be renamed or deleted through
+	// UpdateUserType() or DeleteUserType().
+	builtInUserTypes = []string{UserTypeIndividual, UserTypeCompany}
+
+	// validRequiredLoginTypes are the login types a UserType may require.
+	validRequiredLoginTypes = []string{LoginTypeUsername, LoginTypeEmail,
+		LoginTypePhone, LoginTypeFacebook, LoginTypeOIDC, LoginTypeDev}
+
+	// builtInGroups are created as needed and cannot be changed through
+	// UpdateGroup() or DeleteGroup().
+	builtInGroups = map[string]float32{
+		GroupSuper:   AccessLevelSuper,
+		GroupAdmin:   AccessLevelAdmin,
+		GroupStaff:   AccessLevelStaff,
+		GroupUser:    AccessLevelUser,
+		GroupVisitor: AccessLevelVisitor,
+	}
 
 	actionNotSupportedErrorF    = "action not supported for request: %s"
 	loginTypeNotSupportedErrorF = "login type not supported for request: %s"
@@ -185,6 +390,9 @@ var (
 	errorBadCreds      = errors.NewUnauthorized("invalid credentials")
 	errorNoneDeviceReg = errors.NewForbidden("registration closed to the public unless from accepted device")
 	errorFbNotAvail    = errors.NewNotImplementedf("facebook registration not available")
+	errorOIDCNotAvail  = errors.NewNotImplementedf("OpenID Connect login not available")
+	errorMFANotAvail   = errors.NewNotImplementedf("two-factor authentication not available")
+	errorAttrsNotAvail = errors.NewNotImplementedf("user attributes not available until an attribute schema is set")
 	errorInsufPriv     = errors.NewForbiddenf("lack sufficient privilege to access this resource")
 )
 
@@ -222,6 +430,7 @@ func NewAuthentication(db AuthStore, j JWTEr, opts ...Option) (*Authentication,
 		lockDevToUser:        c.lockDevToUser,
 		appNameEmptyable:     c.appNameEmptyable,
 		fbNilable:            c.fbNilable,
+		oidcNilable:          c.oidcNilable,
 		smserNilable:         c.smserNilable,
 		mailerNilable:        c.mailerNilable,
 		webAppURLNilable:     c.webAppURLNilable,
@@ -229,10 +438,37 @@ func NewAuthentication(db AuthStore, j JWTEr, opts ...Option) (*Authentication,
 		invSubjEmptyable:     c.invSubjEmptyable,
 		verSubjEmptyable:     c.verSubjEmptyable,
 		resPassSubjEmptyable: c.resPassSubjEmptyable,
+		lgnLinkSubjEmptyable: c.lgnLinkSubjEmptyable,
+		lockoutFailCount:     c.lockoutFailCount,
+		lockoutWindow:        c.lockoutWindow,
+		ipLockoutFailCount:   c.ipLockoutFailCount,
+		otpSendLimit:         c.otpSendLimit,
+		otpSendWindow:        c.otpSendWindow,
+		mfaEncNilable:        c.mfaEncNilable,
+		tokenValidity:        c.tokenValidity,
+		grpTokenValidities:   c.grpTokenValidities,
+		actionValidities:     c.actionValidities,
+		actionOTPFormats:     c.actionOTPFormats,
+		passPolicy:           c.passPolicy,
+		pwnedNilable:         c.pwnedNilable,
+		pwnedThreshold:       c.pwnedThreshold,
+		warnPwnedOnLogin:     c.warnPwnedOnLogin,
+		passHasher:           c.passHasher,
 		loginTpActionTplts:   c.loginTpActionTplts,
 	}, nil
 }
 
+// ForTenant returns a copy of a that operates on the tenant having
+// tenantID. An empty tenantID refers to the default tenant. JWTs issued
+// by the copy are only accepted by the same tenant unless their group has
+// been granted PermTenantsAll.
+func (a *Authentication) ForTenant(tenantID string) *Authentication {
+	ta := *a
+	ta.tenantID = tenantID
+	ta.db = a.db.ForTenant(tenantID)
+	return &ta
+}
+
 func (a *Authentication) CanRegisterFirst() (bool, error) {
 
 	superGrp, err := a.getOrCreateGroup(GroupSuper, AccessLevelSuper)
@@ -284,21 +520,43 @@ func (a *Authentication) RegisterFirst(loginType, userType, id string, secret []
 		}
 		regCondF = a.regFacebookConditions
 		regF = a.regFacebook
+	case LoginTypeOIDC:
+		if a.oidcNilable == nil {
+			return nil, errorOIDCNotAvail
+		}
+		regCondF = a.regOIDCConditions(string(secret))
+		var err error
+		secret, err = a.passGen.SecureRandomBytes(genPassLen)
+		if err != nil {
+			return nil, errors.Newf("generate password: %v", err)
+		}
+		regF = a.regOIDC
 	default:
 		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
+	if !isFederated(loginType) {
+		if err := a.passPolicyValid(secret, "", nil, id); err != nil {
+			return nil, err
+		}
+	}
 
 	superGrp, err := a.getOrCreateGroup(GroupSuper, AccessLevelSuper)
 	if err != nil {
 		return nil, err
 	}
 
+	passH, err := a.hashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
 	// Assume system is a 'person' in group super registering another
 	// of the same group - the first human 'person'.
 	// This bypasses restrictions on registerSelf() e.g.
 	// 1. User can only be a member of the public group.
 	// 2. Self registration may be disabled by config options.
-	return a.registerOther(*superGrp, userType, superGrp.ID, id, secret, regCondF, regF, ActionVerify)
+	return a.registerOther(*superGrp, userType, []string{loginType}, superGrp.ID, id, passH,
+		regCondF, regF, ActionVerify)
 }
 
 // RegisterSelf registers a new user account using id secret combination.
@@ -310,35 +568,43 @@ func (a *Authentication) RegisterSelf(loginType, userType, id string, secret []b
 		return nil, errorNoneDeviceReg
 	}
 
-	regF, regCondF, err := a.regFuncs(loginType)
+	// For OpenID Connect, secret carries the nonce the id_token was
+	// requested with.
+	regF, regCondF, err := a.regFuncs(loginType, string(secret))
 	if err != nil {
 		return nil, err
 	}
-	if loginType == LoginTypeFacebook {
+	if isFederated(loginType) {
 		secret, err = a.passGen.SecureRandomBytes(genPassLen)
 		if err != nil {
 			return nil, errors.Newf("generate secret: %v", err)
 		}
+	} else if err := a.passPolicyValid(secret, "", nil, id); err != nil {
+		return nil, err
 	}
 
-	return a.registerSelf(userType, id, secret, regCondF, regF)
+	return a.registerSelf(userType, []string{loginType}, id, secret, regCondF, regF)
 }
 
 // RegisterSelfByLockedDevice registers a new user account using phone/deviceID/password combination.
 func (a *Authentication) RegisterSelfByLockedDevice(loginType, userType, devID, identifier string, secret []byte) (*User, error) {
 
-	regF, regCondF, err := a.regFuncs(loginType)
+	// For OpenID Connect, secret carries the nonce the id_token was
+	// requested with.
+	regF, regCondF, err := a.regFuncs(loginType, string(secret))
 	if err != nil {
 		return nil, err
 	}
-	if loginType == LoginTypeFacebook {
+	if isFederated(loginType) {
 		secret, err = a.passGen.SecureRandomBytes(genPassLen)
 		if err != nil {
 			return nil, errors.Newf("generate secret: %v", err)
 		}
+	} else if err := a.passPolicyValid(secret, "", nil, identifier); err != nil {
+		return nil, err
 	}
 
-	return a.registerSelf(userType, identifier, secret,
+	return a.registerSelf(userType, []string{LoginTypeDev, loginType}, identifier, secret,
 		func(identifier string) (string, error) {
 			if _, err := a.regDevConditions(devID); err != nil {
 				return "", err
@@ -356,11 +622,11 @@ func (a *Authentication) RegisterSelfByLockedDevice(loginType, userType, devID,
 
 func (a *Authentication) RegisterOther(JWT, newLoginType, userType, id, groupID string) (*User, error) {
 
-	clm := JWTClaim{}
-	if _, err := a.jwter.Validate(JWT, &clm); err != nil {
+	clm, err := a.validateJWT(JWT)
+	if err != nil {
 		return nil, err
 	}
-	if err := claimsHaveAccess(clm, AccessLevelAdmin); err != nil {
+	if err := claimsHavePermission(*clm, PermUsersWrite, AccessLevelAdmin); err != nil {
 		return nil, err
 	}
 
@@ -387,17 +653,86 @@ func (a *Authentication) RegisterOther(JWT, newLoginType, userType, id, groupID
 	if err != nil {
 		return nil, errors.Newf("generate password: %v", err)
 	}
+	passH, err := a.hashPassword(pass)
+	if err != nil {
+		return nil, err
+	}
 
 	// clm.StrongestGroup cannot panic because we validate that JWT claims
 	// to be in either admin or super groups or both.
-	return a.registerOther(clm.Group, userType, groupID, id, pass, regCondF, regF)
+	return a.registerOther(clm.Group, userType, []string{newLoginType}, groupID, id, passH,
+		regCondF, regF)
+}
+
+// ImportUser stores a user migrated from another system with the password
+// hash that system stored for them (see UserImport). No verification codes
+// are sent to the user's email or phone.
+func (a *Authentication) ImportUser(JWT string, ui UserImport) (*User, error) {
+
+	clm, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
+	}
+	if err := claimsHavePermission(*clm, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+
+	if ui.Username == "" && ui.Email == "" && ui.Phone == "" {
+		return nil, errors.NewClient("one of username, email or phone is required")
+	}
+	if ui.Username != "" {
+		if ui.Username, err = a.regUsernameConditions(ui.Username); err != nil {
+			return nil, err
+		}
+	}
+	if ui.Email != "" {
+		if ui.Email, err = a.regEmailConditions(ui.Email); err != nil {
+			return nil, err
+		}
+	}
+	if ui.Phone != "" {
+		if ui.Phone, err = a.regPhoneConditions(ui.Phone); err != nil {
+			return nil, err
+		}
+	}
+
+	passH, err := passhash.Import(ui.PassHashAlgorithm, ui.PassHash, ui.PassSalt)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.registerOther(clm.Group, ui.UserType, ui.loginTypes(), ui.GroupID, "", passH,
+		func(string) (string, error) { return "", nil },
+		func(tx *sql.Tx, actionType, id string, usr *User) error {
+			if ui.Username != "" {
+				if err := a.regUsername(tx, actionType, ui.Username, usr); err != nil {
+					return err
+				}
+			}
+			if ui.Email != "" {
+				email, err := a.db.InsertUserEmailAtomic(tx, usr.ID, ui.Email, ui.EmailVerified)
+				if err != nil {
+					return errors.Newf("insert email: %v", err)
+				}
+				usr.Email = *email
+			}
+			if ui.Phone != "" {
+				phone, err := a.db.InsertUserPhoneAtomic(tx, usr.ID, ui.Phone, ui.PhoneVerified)
+				if err != nil {
+					return errors.Newf("insert phone: %v", err)
+				}
+				usr.Phone = *phone
+			}
+			return nil
+		},
+	)
 }
 
 // UpdateIdentifier updates a user account's visible identifier to newID for
 // loginType.
 func (a *Authentication) UpdateIdentifier(JWT, forUserID, loginType, newId string) (*User, error) {
 
-	if err := a.jwtBelongsToOrHasAccess(JWT, forUserID, AccessLevelAdmin); err != nil {
+	if err := a.jwtBelongsToOrHasPermission(JWT, forUserID, PermUsersWrite, AccessLevelAdmin); err != nil {
 		return nil, err
 	}
 
@@ -434,7 +769,7 @@ func (a *Authentication) UpdateIdentifier(JWT, forUserID, loginType, newId strin
 			return nil, err
 		}
 		usr.Email = *email
-	case LoginTypeFacebook:
+	case LoginTypeFacebook, LoginTypeOIDC:
 		err = errors.NewNotImplemented()
 	default:
 		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
@@ -442,1099 +777,3881 @@ func (a *Authentication) UpdateIdentifier(JWT, forUserID, loginType, newId strin
 	return usr, nil
 }
 
-// UpdatePassword updates a user account's password.
-func (a *Authentication) UpdatePassword(JWT string, old, newPass []byte) error {
-	clm := new(JWTClaim)
-	if _, err := a.jwter.Validate(JWT, clm); err != nil {
-		return err
-	}
-	_, oldPassH, err := a.db.User(clm.UsrID)
-	if err != nil {
-		return errors.Newf("get user: %v", err)
-	}
+// AddAddress adds address to forUserID's emails or phones (determined by
+// loginType) without affecting their primary. A verification code is sent to
+// address if the notification method is available; address cannot be used to
+// log in until it is verified through VerifyDBT(), which also makes it the
+// primary if forUserID has no primary address of its type.
+func (a *Authentication) AddAddress(JWT, forUserID, loginType, address string) (*VerifLogin, error) {
 
-	if err = passwordValid(oldPassH, old); err != nil {
-		return err
-	}
-	newPassH, err := hashIfValid(newPass)
-	if err != nil {
-		return err
-	}
-	if err = a.db.UpdatePassword(clm.UsrID, newPassH); err != nil {
-		return errors.Newf("update password: %v", err)
+	if err := a.jwtBelongsToOrHasPermission(JWT, forUserID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return nil, err
 	}
 
-	return nil
-}
-
-func (a *Authentication) SetUserGroup(JWT, userID, newGrpID string) (*User, error) {
-
-	if newGrpID == "" {
-		return nil, errors.NewClientf("new group ID cannot be empty")
+	if forUserID == "" {
+		return nil, errors.NewClientf("user ID was empty")
 	}
-	if userID == "" {
-		return nil, errors.NewClientf("user ID cannot be empty")
+
+	var regCondsFunc regConditions
+	var insertFunc func(string, string) (*VerifLogin, error)
+	var isMessengerAvail bool
+	switch loginType {
+	case LoginTypeEmail:
+		regCondsFunc = a.regEmailConditions
+		insertFunc = a.db.InsertUnverifiedUserEmail
+		isMessengerAvail = a.mailerNilable != nil
+	case LoginTypePhone:
+		regCondsFunc = a.regPhoneConditions
+		insertFunc = a.db.InsertUnverifiedUserPhone
+		isMessengerAvail = a.smserNilable != nil
+	default:
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
 
-	newGrp, err := a.db.Group(newGrpID)
+	usr, _, err := a.db.User(forUserID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
 			return nil, errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("get group: %v", err)
+		return nil, errors.Newf("get user: %v", err)
 	}
 
-	checkACL := AccessLevelAdmin
-	// access level of updater must be less than or equal to the new Groups Access Level
-	if newGrp.AccessLevel < checkACL {
-		checkACL = newGrp.AccessLevel
+	existing := usr.Emails
+	if loginType == LoginTypePhone {
+		existing = usr.Phones
 	}
-	if err := a.jwtHasAccess(JWT, checkACL); err != nil {
-		return nil, err
+	if len(existing) >= maxAddressesPerType {
+		return nil, errors.NewClientf("a user cannot have more than %d %s addresses",
+			maxAddressesPerType, loginType)
 	}
 
-	usr, _, err := a.db.User(userID)
+	address, err = regCondsFunc(address)
 	if err != nil {
-		if a.db.IsNotFoundError(err) {
-			return nil, errors.NewNotFound(err)
-		}
-		return nil, errors.Newf("get user: %v", err)
+		return nil, err
 	}
 
-	if usr.Group.ID == newGrpID {
-		return nil, errors.NewClientf("new group ID is current user's group ID")
+	vl, err := insertFunc(usr.ID, address)
+	if err != nil {
+		return nil, errors.Newf("insert %s: %v", loginType, err)
 	}
 
-	if usr.Group.AccessLevel == AccessLevelSuper {
-		// TODO only query verified users to prevent locking out on
-		// the basis of user lost password and has no verification means.
-		superUsrsQ := UsersQuery{GroupNamesIn: []string{GroupSuper}}
-		superUsrs, err := a.db.Users(superUsrsQ, 0, 2)
-		if err != nil {
-			return nil, errors.Newf("fetch users:"+
-				" currently in super user's group (expected at least one): %v", err)
-		}
-		if len(superUsrs) < 2 {
-			return nil, errors.NewClientf("the last super user cannot be" +
-				" removed from super group, need assign another before relegating")
-		}
+	if !isMessengerAvail {
+		return vl, nil
 	}
-
-	if err := a.db.SetUserGroup(userID, newGrpID); err != nil {
-		return nil, errors.Newf("set user group: %v", err)
+	otpSt, err := a.genAndSendTokens(nil, ActionVerify, loginType, address, usr.ID)
+	if err != nil {
+		return vl, err
 	}
-	usr.Group = *newGrp
-
-	return usr, nil
+	vl.OTPStatus = *otpSt
+	return vl, nil
 }
 
-// SetPassword updates a user account's password following a SendPassResetCode()
-// request. dbt is the token initially sent to the user for verification.
-// loginType should be similar to the one used during SendPassResetCode().
-func (a *Authentication) SetPassword(loginType, forAddr string, dbt, pass []byte) (*VerifLogin, error) {
+// RemoveAddress removes address from forUserID's emails or phones
+// (determined by loginType) together with any tokens sent to it. The primary
+// address cannot be removed, SetPrimaryAddress() to another address first.
+func (a *Authentication) RemoveAddress(JWT, forUserID, loginType, address string) error {
 
-	var tkn *DBToken
-	var err error
-	var updtVerifiedFunc func(*sql.Tx, string, string, bool) (*VerifLogin, error)
-	var setTokenUsedFunc func(*sql.Tx, string) error
-	var fetchTokensFunc func(string, int64, int64) ([]DBToken, error)
-	var fetchUsrFunc func(string) (*User, []byte, error)
+	if err := a.jwtBelongsToOrHasPermission(JWT, forUserID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return err
+	}
 
-	var usr *User
+	if forUserID == "" {
+		return errors.NewClientf("user ID was empty")
+	}
+
+	var deleteTokensFunc func(*sql.Tx, string, string) error
+	var deleteFunc func(*sql.Tx, string, string) error
+	var err error
 	switch loginType {
 	case LoginTypeEmail:
-		fetchUsrFunc = a.db.UserByEmail
-		fetchTokensFunc = a.db.EmailTokens
-		updtVerifiedFunc = a.db.UpdateUserEmailAtomic
-		setTokenUsedFunc = a.db.SetEmailTokenUsedAtomic
+		address, err = normalizeValidEmail(address, a.verifyEmailHost)
+		deleteTokensFunc = a.db.DeleteEmailTokensAtomic
+		deleteFunc = a.db.DeleteUserEmailAtomic
 	case LoginTypePhone:
-		forAddr, err = formatValidPhone(forAddr)
-		if err != nil {
-			return nil, errors.NewClient(err)
-		}
-		fetchUsrFunc = a.db.UserByPhone
-		fetchTokensFunc = a.db.PhoneTokens
-		updtVerifiedFunc = a.db.UpdateUserPhoneAtomic
-		setTokenUsedFunc = a.db.SetPhoneTokenUsedAtomic
+		address, err = formatValidPhone(address)
+		deleteTokensFunc = a.db.DeletePhoneTokensAtomic
+		deleteFunc = a.db.DeleteUserPhoneAtomic
 	default:
-		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+		return errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+	if err != nil {
+		return errors.NewClient(err)
 	}
 
-	usr, _, err = fetchUsrFunc(forAddr)
+	usr, _, err := a.db.User(forUserID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
-			return nil, errors.NewNotFound("User not found")
+			return errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("fetch user by %s: %v", loginType, err)
+		return errors.Newf("get user: %v", err)
 	}
 
-	tkn, err = a.dbTokenValid(usr.ID, dbt, fetchTokensFunc)
-	if err != nil {
-		return nil, err
+	primary := usr.Email
+	if loginType == LoginTypePhone {
+		primary = usr.Phone
 	}
-
-	passH, err := hashIfValid(pass)
-	if err != nil {
-		return nil, err
+	if primary.Address == address {
+		return errors.NewClientf("cannot remove the primary %s", loginType)
 	}
 
-	var addr *VerifLogin
-	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
-		if err := setTokenUsedFunc(tx, tkn.ID); err != nil {
-			return errors.Newf("update DBT, set used: %v", err)
-		}
-		err = a.db.UpdatePasswordAtomic(tx, usr.ID, passH)
-		if err != nil {
-			return errors.Newf("update password: %v", err)
+	return a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err := deleteFunc(tx, usr.ID, address); err != nil {
+			if a.db.IsNotFoundError(err) {
+				return errors.NewNotFoundf("%s not found for user", address)
+			}
+			return errors.Newf("delete %s: %v", loginType, err)
 		}
-		addr, err = updtVerifiedFunc(tx, usr.ID, tkn.Address, true)
-		if err != nil {
-			return errors.Newf("update phone to verified: %v", err)
+		// TODO archive instead
+		err := deleteTokensFunc(tx, usr.ID, address)
+		if err != nil && !a.db.IsNotFoundError(err) {
+			return errors.Newf("delete %s's tokens: %v", loginType, err)
 		}
 		return nil
 	})
-	return addr, err
 }
 
-// SendVerCode sends a verification code to toAddr to verify the
-// address. loginType determines determines whether toAddr is a phone or an email.
-// subsequent calls to VerifyDBT() or VerifyAndExtendDBT() with the correct code
-// completes the verification.
-func (a *Authentication) SendVerCode(JWT, loginType, toAddr string) (*DBTStatus, error) {
+// SetPrimaryAddress makes address, one of forUserID's verified emails or
+// phones (determined by loginType), forUserID's primary in place of the
+// current one.
+func (a *Authentication) SetPrimaryAddress(JWT, forUserID, loginType, address string) (*User, error) {
 
-	var err error
-	var usr *User
-	var isMessengerAvail bool
+	if err := a.jwtBelongsToOrHasPermission(JWT, forUserID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+
+	if forUserID == "" {
+		return nil, errors.NewClientf("user ID was empty")
+	}
 
+	var setPrimaryFunc func(string, string) error
+	var err error
 	switch loginType {
-	case LoginTypePhone:
-		toAddr, err = formatValidPhone(toAddr)
-		if err != nil {
-			return nil, errors.NewClient(err)
-		}
-		usr, _, err = a.db.UserByPhone(toAddr)
-		isMessengerAvail = a.smserNilable != nil
 	case LoginTypeEmail:
-		usr, _, err = a.db.UserByEmail(toAddr)
-		isMessengerAvail = a.mailerNilable != nil
+		address, err = normalizeValidEmail(address, a.verifyEmailHost)
+		setPrimaryFunc = a.db.SetPrimaryUserEmail
+	case LoginTypePhone:
+		address, err = formatValidPhone(address)
+		setPrimaryFunc = a.db.SetPrimaryUserPhone
 	default:
 		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
+	if err != nil {
+		return nil, errors.NewClient(err)
+	}
 
+	usr, _, err := a.db.User(forUserID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
 			return nil, errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("user by %s: %v", loginType, err)
+		return nil, errors.Newf("get user: %v", err)
 	}
 
-	if !isMessengerAvail {
-		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	all := usr.Emails
+	if loginType == LoginTypePhone {
+		all = usr.Phones
+	}
+	var addr *VerifLogin
+	for i := range all {
+		if all[i].Address == address {
+			addr = &all[i]
+			break
+		}
+	}
+	if addr == nil {
+		return nil, errors.NewNotFoundf("%s not found for user", address)
+	}
+	if !addr.Verified {
+		return nil, errors.NewClientf("%s must be verified before it can be made primary", address)
 	}
 
-	if err := a.jwtBelongsToOrHasAccess(JWT, usr.ID, AccessLevelStaff); err != nil {
-		return nil, err
+	if err := setPrimaryFunc(usr.ID, address); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFoundf("%s not found for user", address)
+		}
+		return nil, errors.Newf("set primary %s: %v", loginType, err)
 	}
 
-	return a.genAndSendTokens(nil, ActionVerify, loginType, toAddr, usr.ID)
+	usr, _, err = a.db.User(usr.ID)
+	if err != nil {
+		return nil, errors.Newf("get updated user: %v", err)
+	}
+	return usr, nil
 }
 
-// SendPassResetCode sends a password reset code to toAddr to allow a user
-// to reset their forgotten password.
-// loginType determines whether toAddr is a phone or an email.
-// subsequent calls to SetPassword() with the correct code completes the
-// password reset.
-func (a *Authentication) SendPassResetCode(loginType, toAddr string) (*DBTStatus, error) {
-	var err error
-	var usr *User
-	var isMessengerAvail bool
+// LinkIdentity adds a way to log in of type loginType to forUserID's
+// account e.g. a facebook account to a user who registered by phone.
+// identifier is validated as during registration e.g. a facebook token
+// must be valid. Only one username, email, phone and facebook account can be
+// linked (see AddAddress() for more emails and phones) while any number of
+// OpenID Connect identities can.
+// secret is the password to log in with a username, which replaces the
+// account's password since accounts registered through facebook or OpenID
+// Connect have none the user knows, or the nonce an OpenID Connect
+// id_token was requested with. It is ignored for other loginTypes.
+func (a *Authentication) LinkIdentity(JWT, forUserID, loginType, identifier string, secret []byte) (*User, error) {
+
+	if err := a.jwtBelongsToOrHasPermission(JWT, forUserID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
 
-	switch loginType {
-	case LoginTypePhone:
-		toAddr, err = formatValidPhone(toAddr)
-		if err != nil {
-			return nil, errors.NewClient(err)
-		}
-		usr, _, err = a.db.UserByPhone(toAddr)
-		isMessengerAvail = a.smserNilable != nil
-	case LoginTypeEmail:
-		usr, _, err = a.db.UserByEmail(toAddr)
-		isMessengerAvail = a.mailerNilable != nil
-	default:
-		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	if forUserID == "" {
+		return nil, errors.NewClientf("user ID was empty")
 	}
 
-	if !isMessengerAvail {
-		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	regF, regCondF, err := a.regFuncs(loginType, string(secret))
+	if err != nil {
+		return nil, err
 	}
 
+	usr, oldPassH, err := a.db.User(forUserID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
-			return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+			return nil, errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("user by %s: %v", loginType, err)
+		return nil, errors.Newf("get user: %v", err)
 	}
 
-	return a.genAndSendTokens(nil, ActionResetPass, loginType, toAddr, usr.ID)
-}
-
-// VerifyAndExtendDBT verifies a user's address and returns a temporary token
-// that can be used to perform actions that would otherwise not be possible on
-// the user's account without a password or a JWT for a limited period of time.
-// See VerifyDBT() for details on verification.
-func (a *Authentication) VerifyAndExtendDBT(lt, userID string, dbt []byte) (string, error) {
-	lv, err := a.verifyDBT(lt, userID, dbt)
+	methods, err := a.loginMethods(*usr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	expiry := time.Now().Add(extendTknValidity)
-	tkn, err := a.genAndInsertToken(nil, expiry, lt, lv.UserID, lv.Address)
+	if loginType != LoginTypeOIDC && methods[loginType] > 0 {
+		return nil, errors.NewConflictf("a %s is already linked to the user", loginType)
+	}
+
+	identifier, err = regCondF(identifier)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(tkn), nil
-}
 
-// VerifyDBT sets a user's address as verified after successful SendVerCode()
-// and subsequent entry of the code by the user.
-// loginType should be similar to the one used during SendVerCode().
-func (a *Authentication) VerifyDBT(loginType, userID string, dbt []byte) (*VerifLogin, error) {
-	return a.verifyDBT(loginType, userID, dbt)
-}
+	var passH []byte
+	if loginType == LoginTypeUsername {
+		err = a.passPolicyValid(secret, usr.ID, oldPassH, identifier, usr.Email.Address)
+		if err != nil {
+			return nil, err
+		}
+		if passH, err = a.hashPassword(secret); err != nil {
+			return nil, err
+		}
+	}
 
-func (a *Authentication) UserID(loginType, identifier string) (string, error) {
-	usr, _, err := a.user(loginType, identifier)
-	if err != nil {
-		if a.IsClientError(err) || a.IsNotFoundError(err) {
-			return "", err
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if passH != nil {
+			if err := a.db.UpdatePasswordAtomic(tx, usr.ID, passH); err != nil {
+				return errors.Newf("update password: %v", err)
+			}
+			if err := a.db.InsertPassHistoryAtomic(tx, usr.ID, oldPassH); err != nil {
+				return errors.Newf("insert password history: %v", err)
+			}
 		}
-		return "", errors.Newf("get user by %s: %v", loginType, err)
+		return regF(tx, ActionVerify, identifier, usr)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return usr.ID, nil
+	return usr, nil
 }
 
-// Login validates a user's credentials and returns the user's information
-// together with a JWT for subsequent requests to this and other micro-services.
-func (a *Authentication) Login(loginType, identifier string, password []byte) (*User, error) {
+// UnlinkIdentity removes forUserID's username, facebook account or
+// OpenID Connect identities (determined by loginType). It is refused if the
+// user would be left with no way to log in. Emails and phones are removed
+// through RemoveAddress().
+func (a *Authentication) UnlinkIdentity(JWT, forUserID, loginType string) error {
 
-	usr, passHB, err := a.user(loginType, identifier)
-	if err != nil {
-		if a.IsClientError(err) || a.IsNotFoundError(err) {
-			return nil, errorBadCreds
-		}
-		return nil, errors.Newf("get user by %s: %v", loginType, err)
+	if err := a.jwtBelongsToOrHasPermission(JWT, forUserID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return err
 	}
 
-	if loginType != LoginTypeFacebook {
-		if err := passwordValid(passHB, password); err != nil {
-			return nil, err
+	if forUserID == "" {
+		return errors.NewClientf("user ID was empty")
+	}
+
+	var unlinkFunc func(string) error
+	switch loginType {
+	case LoginTypeUsername:
+		unlinkFunc = a.db.DeleteUserName
+	case LoginTypeFacebook:
+		unlinkFunc = a.db.DeleteUserFbID
+	case LoginTypeOIDC:
+		unlinkFunc = a.db.DeleteLinkedIdentities
+	case LoginTypeEmail, LoginTypePhone:
+		return errors.NewClientf("%s cannot be unlinked, remove individual addresses instead", loginType)
+	default:
+		return errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+
+	usr, _, err := a.db.User(forUserID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound(err)
 		}
+		return errors.Newf("get user: %v", err)
 	}
 
-	usr.JWT, err = a.jwter.Generate(newJWTClaim(usr.ID, usr.Group))
+	methods, err := a.loginMethods(*usr)
 	if err != nil {
-		return nil, errors.Newf("generate JWT: %v", err)
+		return err
+	}
+	if methods[loginType] == 0 {
+		return errors.NewNotFoundf("no %s is linked to the user", loginType)
+	}
+	numMethods := 0
+	for _, n := range methods {
+		numMethods += n
+	}
+	if numMethods == methods[loginType] {
+		return errors.NewClientf("cannot unlink %s, the user would have no way to log in", loginType)
 	}
 
-	return usr, nil
+	if err := unlinkFunc(usr.ID); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFoundf("no %s is linked to the user", loginType)
+		}
+		return errors.Newf("unlink %s: %v", loginType, err)
+	}
+	return nil
 }
 
-func (a *Authentication) Users(JWT string, q UsersQuery, offsetStr, countStr string) ([]User, error) {
-	if err := a.jwtHasAccess(JWT, AccessLevelAdmin); err != nil {
-		return nil, err
+// UpdatePassword updates a user account's password.
+func (a *Authentication) UpdatePassword(JWT string, old, newPass []byte) error {
+	clm, err := a.validateJWT(JWT)
+	if err != nil {
+		return err
 	}
-	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	usr, oldPassH, err := a.db.User(clm.UsrID)
 	if err != nil {
-		return nil, err
+		return errors.Newf("get user: %v", err)
 	}
-	if err := q.Process(); err != nil {
-		return nil, err
+
+	if _, err = a.passwordValid(oldPassH, old); err != nil {
+		return err
 	}
-	usrs, err := a.db.Users(q, offset, count)
+	err = a.passPolicyValid(newPass, clm.UsrID, oldPassH, usr.UserName.Value, usr.Email.Address)
 	if err != nil {
-		if a.db.IsNotFoundError(err) {
-			return nil, errors.NewNotFound(err)
-		}
-		return nil, errors.Newf("fetch users: %v", err)
+		return err
 	}
-	return usrs, nil
+	newPassH, err := a.hashPassword(newPass)
+	if err != nil {
+		return err
+	}
+	return a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err = a.db.UpdatePasswordAtomic(tx, clm.UsrID, newPassH); err != nil {
+			return errors.Newf("update password: %v", err)
+		}
+		if err = a.db.InsertPassHistoryAtomic(tx, clm.UsrID, oldPassH); err != nil {
+			return errors.Newf("insert password history: %v", err)
+		}
+		return nil
+	})
 }
 
-func (a *Authentication) GetUserDetails(JWT string, userID string) (*User, error) {
-	clms := new(JWTClaim)
-	if _, err := a.jwter.Validate(JWT, clms); err != nil {
-		return nil, err
+func (a *Authentication) SetUserGroup(JWT, userID, newGrpID string) (*User, error) {
+
+	if newGrpID == "" {
+		return nil, errors.NewClientf("new group ID cannot be empty")
 	}
-	if clms.UsrID != userID {
-		if err := claimsHaveAccess(*clms, AccessLevelStaff); err != nil {
-			return nil, err
-		}
+	if userID == "" {
+		return nil, errors.NewClientf("user ID cannot be empty")
 	}
-	usr, _, err := a.db.User(userID)
+
+	newGrp, err := a.db.Group(newGrpID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
 			return nil, errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("fetch user: %v", err)
+		return nil, errors.Newf("get group: %v", err)
 	}
 
-	return usr, nil
-}
-
-func (a *Authentication) Groups(JWT, offsetStr, countStr string) ([]Group, error) {
-	if err := a.jwtHasAccess(JWT, AccessLevelStaff); err != nil {
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
 		return nil, err
 	}
-	offset, count, err := unpackOffsetCount(offsetStr, countStr)
-	if err != nil {
+	if err := claimsHavePermission(*clms, PermUsersWrite, AccessLevelAdmin); err != nil {
 		return nil, err
 	}
-	if _, err := a.preparePrerequisiteGroups(); err != nil {
-		return nil, errors.Newf("prepare pre-requisite groups: %v", err)
+	// access level of updater must be less than or equal to the new Groups Access Level
+	if err := claimsHaveAccess(*clms, newGrp.AccessLevel); err != nil {
+		return nil, err
 	}
-	grps, err := a.db.Groups(offset, count)
+
+	usr, _, err := a.db.User(userID)
 	if err != nil {
-		// not checking not found because pre-requisite groups need exist in db
-		return nil, errors.Newf("fetch groups: %v", err)
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("get user: %v", err)
 	}
-	return grps, nil
-}
 
-func (a *Authentication) preparePrerequisiteGroups() ([]Group, error) {
-	defs := map[string]float32{
-		GroupSuper:   AccessLevelSuper,
-		GroupAdmin:   AccessLevelAdmin,
-		GroupStaff:   AccessLevelStaff,
-		GroupUser:    AccessLevelUser,
-		GroupVisitor: AccessLevelVisitor,
+	if usr.Group.ID == newGrpID {
+		return nil, errors.NewClientf("new group ID is current user's group ID")
 	}
-	var grps []Group
-	for name, acl := range defs {
-		grp, err := a.getOrCreateGroup(name, acl)
+
+	if usr.Group.AccessLevel == AccessLevelSuper {
+		// TODO only query verified users to prevent locking out on
+		// the basis of user lost password and has no verification means.
+		superUsrsQ := UsersQuery{GroupNamesIn: []string{GroupSuper}}
+		superUsrs, err := a.db.Users(superUsrsQ, 0, 2)
 		if err != nil {
-			return nil, errors.Newf("get or create group '%s' with acl %f: %v",
-				name, acl, err)
+			return nil, errors.Newf("fetch users:"+
+				" currently in super user's group (expected at least one): %v", err)
+		}
+		if len(superUsrs) < 2 {
+			return nil, errors.NewClientf("the last super user cannot be" +
+				" removed from super group, need assign another before relegating")
 		}
-		grps = append(grps, *grp)
 	}
-	return grps, nil
-}
 
-func (a *Authentication) usrIdentifierAvail(loginType, addr string, fetchErr error) error {
-	if fetchErr == nil {
-		return errors.NewConflictf("(%s) %s not available", loginType, addr)
-	}
-	if !a.db.IsNotFoundError(fetchErr) {
-		return errors.Newf("user by %s: %v", loginType, fetchErr)
+	if err := a.db.SetUserGroup(userID, newGrpID); err != nil {
+		return nil, errors.Newf("set user group: %v", err)
 	}
-	return nil
-}
+	usr.Group = *newGrp
 
-func (a *Authentication) verifyDBT(loginType, userID string, dbt []byte) (*VerifLogin, error) {
+	return usr, nil
+}
 
-	var tokensFetchFunc func(string, int64, int64) ([]DBToken, error)
-	var updateLoginFunc func(*sql.Tx, string, string, bool) (*VerifLogin, error)
-	var setTokenUsedFunc func(*sql.Tx, string) error
-	var usr *User
-	var err error
+// SetUserStatus sets the status of userID's account to one of StatusActive,
+// StatusSuspended or StatusPending. reason is optional. untilStr is an
+// optional ISO8601 date after which the status lapses back to StatusActive.
+// Setting StatusActive reinstates a suspended user. Only an admin can set
+// a user's status, no one can set their own status and only a super user
+// can set a super user's status.
+func (a *Authentication) SetUserStatus(JWT, userID, status, reason, untilStr string) (*User, error) {
 
-	switch loginType {
-	case LoginTypeEmail:
-		tokensFetchFunc = a.db.EmailTokens
-		updateLoginFunc = a.db.UpdateUserEmailAtomic
-		setTokenUsedFunc = a.db.SetEmailTokenUsedAtomic
-	case LoginTypePhone:
-		tokensFetchFunc = a.db.PhoneTokens
-		updateLoginFunc = a.db.UpdateUserPhoneAtomic
-		setTokenUsedFunc = a.db.SetPhoneTokenUsedAtomic
-	default:
-		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	if userID == "" {
+		return nil, errors.NewClientf("user ID cannot be empty")
+	}
+	if !isValidStatus(status) {
+		return nil, errors.NewClientf("status must be one of %s, %s or %s",
+			StatusActive, StatusSuspended, StatusPending)
+	}
+	if len(reason) > maxStatusReasonLen {
+		return nil, errors.NewClientf("reason cannot be more than %d characters",
+			maxStatusReasonLen)
+	}
+	newStatus := UserStatus{Value: status, Reason: reason}
+	if untilStr != "" {
+		if status == StatusActive {
+			return nil, errors.NewClientf("an active status cannot lapse")
+		}
+		var err error
+		newStatus.Until, err = time.Parse(config.TimeFormat, untilStr)
+		if err != nil {
+			return nil, errors.NewClientf("invalid until date: %v", err)
+		}
+		if !newStatus.Until.After(time.Now()) {
+			return nil, errors.NewClientf("until date must be in the future")
+		}
 	}
 
-	if userID == "" {
-		return nil, errors.NewClientf("userID was empty")
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
 	}
-	usr, _, err = a.db.User(userID)
+	if clms.UsrID == userID {
+		return nil, errors.NewForbiddenf("cannot set own status")
+	}
+
+	usr, _, err := a.db.User(userID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
 			return nil, errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("get %s user: %v", loginType, err)
+		return nil, errors.Newf("get user: %v", err)
 	}
 
-	tkn, err := a.dbTokenValid(usr.ID, dbt, tokensFetchFunc)
-	if err != nil {
+	if err := claimsHavePermission(*clms, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	// access level of updater must be less than or equal to the user's
+	// access level.
+	if err := claimsHaveAccess(*clms, usr.Group.AccessLevel); err != nil {
 		return nil, err
 	}
 
-	var vl *VerifLogin
-	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
-		if err := setTokenUsedFunc(tx, tkn.ID); err != nil {
-			return errors.Newf("update DBT, set used: %v", err)
-		}
-		vl, err = updateLoginFunc(tx, usr.ID, tkn.Address, true)
-		if err != nil {
-			return errors.Newf("update phone to verified: %v", err)
-		}
-		return nil
-	})
-	return vl, err
-}
+	if err := a.db.SetUserStatus(userID, newStatus); err != nil {
+		return nil, errors.Newf("set user status: %v", err)
+	}
+	usr.Status = newStatus
 
-func (a *Authentication) genAndSendTokens(tx *sql.Tx, action, loginType, toAddr, usrID string) (*DBTStatus, error) {
+	return usr, nil
+}
 
-	var validity time.Duration
+// DeleteUser permanently deletes userID's account together with every record
+// belonging to it. A user deleting their own account must re-confirm their
+// identity: confirmLoginType is either LoginTypeUsername, in which case
+// confirmation is the account's password, or LoginTypeEmail/LoginTypePhone,
+// in which case confirmation is a code sent to the account's address
+// through SendConfirmCode(). Failed password re-confirmations count towards
+// lockouts as with Login(). Admins can delete other accounts of the same
+// or a lower access level without re-confirmation.
+func (a *Authentication) DeleteUser(ci ClientInfo, JWT, userID, confirmLoginType string, confirmation []byte) error {
 
-	switch action {
-	case ActionResetPass:
-		validity = resetValidity
-	case ActionVerify:
-		validity = verifyValidity
-	case ActionInvite:
-		validity = inviteValidity
-	case ActionExtendTkn:
-		validity = extendTknValidity
-	default:
-		return nil, errors.NewClientf(actionNotSupportedErrorF, action)
+	if userID == "" {
+		return errors.NewClientf("user ID cannot be empty")
 	}
 
-	expiry := time.Now().Add(validity)
-	URL := ""
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return err
+	}
+	isSelf := clms.UsrID == userID
+	if !isSelf {
+		if err := claimsHavePermission(*clms, PermUsersWrite, AccessLevelAdmin); err != nil {
+			return err
+		}
+	}
 
-	if action == ActionVerify && a.serviceURLNilable != nil {
-		tkn, err := a.genAndInsertToken(tx, expiry, loginType, usrID, toAddr)
+	usr, passH, err := a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound(err)
+		}
+		return errors.Newf("get user: %v", err)
+	}
+
+	var confirmTkn *DBToken
+	var setTokenUsedFunc func(*sql.Tx, string) error
+	if isSelf {
+		confirmTkn, setTokenUsedFunc, err = a.identityConfirmed(ci, usr, passH, confirmLoginType, confirmation)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		useURL := new(url.URL)
-		*useURL = *a.serviceURLNilable
-		// GET /users/:userID/:loginType/verify/:OTP
-		useURL.Path = path.Join(useURL.Path, "users", usrID, loginType, ActionVerify, string(tkn))
-		useURL.RawQuery = url.Values{"redirectToWebApp": []string{"true"}}.Encode()
-		URL = useURL.String()
+	} else if err := claimsHaveAccess(*clms, usr.Group.AccessLevel); err != nil {
+		return err
 	}
 
-	if action != ActionVerify && a.webAppURLNilable != nil {
-		tkn, err := a.genAndInsertToken(tx, expiry, loginType, usrID, toAddr)
+	if usr.Group.AccessLevel == AccessLevelSuper {
+		superUsrsQ := UsersQuery{GroupNamesIn: []string{GroupSuper}}
+		superUsrs, err := a.db.Users(superUsrsQ, 0, 2)
 		if err != nil {
-			return nil, err
+			return errors.Newf("fetch users:"+
+				" currently in super user's group (expected at least one): %v", err)
+		}
+		if len(superUsrs) < 2 {
+			return errors.NewClientf("the last super user cannot be" +
+				" deleted, need assign another first")
 		}
-		useURL := new(url.URL)
-		*useURL = *a.webAppURLNilable
-		useURL.Path = path.Join(useURL.Path, action, loginType, url.PathEscape(toAddr), string(tkn))
-		URL = useURL.String()
 	}
 
-	code, err := a.genAndInsertCode(tx, expiry, loginType, usrID, toAddr)
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if confirmTkn != nil {
+			if err := setTokenUsedFunc(tx, confirmTkn.ID); err != nil {
+				return errors.Newf("set confirmation code used: %v", err)
+			}
+		}
+		if err := a.db.DeleteUserAtomic(tx, usr.ID); err != nil {
+			return errors.Newf("delete user: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var sendData interface{}
-	var subj string
-
-	switch action {
-	case ActionInvite:
-		sendData = InvitationTemplate{AppName: a.appNameEmptyable, URLToken: URL}
-		subj = a.invSubjEmptyable
-	case ActionVerify:
-		sendData = VerificationTemplate{AppName: a.appNameEmptyable, URLToken: URL,
-			Code: string(code)}
-		subj = a.verSubjEmptyable
-	case ActionResetPass:
-		sendData = VerificationTemplate{AppName: a.appNameEmptyable, URLToken: URL,
-			Code: string(code)}
-		subj = a.resPassSubjEmptyable
-	default:
-		return nil, errors.Newf(actionNotSupportedErrorF, action)
+	// login failures are recorded against identifiers rather than the
+	// account and would otherwise outlive it.
+	for loginType, ids := range lockoutIdentifiers(*usr) {
+		for _, identifier := range ids {
+			if err := a.clearLoginFailures(loginType, identifier); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	var obfuscateFunc func(string) string
-	tpl := a.loginTpActionTplts[loginType][action]
-
-	switch loginType {
-	case LoginTypePhone:
-		obfuscateFunc = obfuscatePhone
-		err = a.sendSMS(toAddr, tpl, sendData)
-	case LoginTypeEmail:
-		obfuscateFunc = obfuscateEmail
-		err = a.sendEmail(toAddr, subj, tpl, sendData)
-	default:
-		return nil, errors.Newf(loginTypeNotSupportedErrorF, loginType)
-	}
-	if err != nil {
-		return nil, err
-	}
+// SetPassword updates a user account's password following a SendPassResetCode()
+// request. dbt is the token initially sent to the user for verification.
+// loginType should be similar to the one used during SendPassResetCode().
+func (a *Authentication) SetPassword(ci ClientInfo, loginType, forAddr string, dbt, pass []byte) (*VerifLogin, error) {
 
-	return &DBTStatus{
-		ObfuscatedAddress: obfuscateFunc(toAddr),
-		ExpiresAt:         expiry,
-	}, nil
-}
+	var tkn *DBToken
+	var err error
+	var updtVerifiedFunc func(*sql.Tx, string, string) (*VerifLogin, error)
+	var setTokenUsedFunc func(*sql.Tx, string) error
+	var fetchTokensFunc func(string, int64, int64) ([]DBToken, error)
+	var fetchUsrFunc func(string) (*User, []byte, error)
 
-func (a *Authentication) regFuncs(loginType string) (regFunc, regConditions, error) {
+	var usr *User
 	switch loginType {
-	case LoginTypeUsername:
-		return a.regUsername, a.regUsernameConditions, nil
 	case LoginTypeEmail:
-		return a.regEmail, a.regEmailConditions, nil
+		fetchUsrFunc = a.db.UserByEmail
+		fetchTokensFunc = a.db.EmailTokens
+		updtVerifiedFunc = a.db.VerifyUserEmailAtomic
+		setTokenUsedFunc = a.db.SetEmailTokenUsedAtomic
 	case LoginTypePhone:
-		return a.regPhone, a.regPhoneConditions, nil
-	case LoginTypeFacebook:
-		if a.fbNilable == nil {
-			return nil, nil, errorFbNotAvail
+		forAddr, err = formatValidPhone(forAddr)
+		if err != nil {
+			return nil, errors.NewClient(err)
 		}
-		return a.regFacebook, a.regFacebookConditions, nil
+		fetchUsrFunc = a.db.UserByPhone
+		fetchTokensFunc = a.db.PhoneTokens
+		updtVerifiedFunc = a.db.VerifyUserPhoneAtomic
+		setTokenUsedFunc = a.db.SetPhoneTokenUsedAtomic
 	default:
-		return nil, nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
-	}
-}
-
-func (a *Authentication) registerSelf(userType string, id string, password []byte, rcf regConditions, rf regFunc) (*User, error) {
-
-	if !a.allowSelfReg {
-		return nil, errors.NewForbidden("registration closed from the public")
-	}
-
-	if !inStrs(userType, validUserTypes) {
-		return nil, errors.NewClientf("accountType must be one of %+v", validUserTypes)
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
 
-	passH, err := hashIfValid(password)
+	usr, oldPassH, err := fetchUsrFunc(forAddr)
 	if err != nil {
-		return nil, err
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound("User not found")
+		}
+		return nil, errors.Newf("fetch user by %s: %v", loginType, err)
+	}
+	if !addressUsable(*usr, loginType, forAddr) {
+		return nil, errors.NewNotFound("User not found")
 	}
 
-	id, err = rcf(id)
+	tkn, err = a.dbTokenValid(usr.ID, dbt, fetchTokensFunc)
 	if err != nil {
+		if hErr := a.saveHistory(nil, ci, usr.ID, AccessTypeResetPass, loginType, false); hErr != nil {
+			return nil, hErr
+		}
 		return nil, err
 	}
 
-	grp, err := a.getOrCreateGroup(GroupUser, AccessLevelUser)
+	err = a.passPolicyValid(pass, usr.ID, oldPassH, usr.UserName.Value, usr.Email.Address)
 	if err != nil {
 		return nil, err
 	}
-	ut, err := a.getOrCreateUserType(userType)
+	passH, err := a.hashPassword(pass)
 	if err != nil {
 		return nil, err
 	}
 
-	usr := new(User)
+	var addr *VerifLogin
 	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
-		usr, err = a.db.InsertUserAtomic(tx, *ut, *grp, passH)
+		if err := setTokenUsedFunc(tx, tkn.ID); err != nil {
+			return errors.Newf("update DBT, set used: %v", err)
+		}
+		err = a.db.UpdatePasswordAtomic(tx, usr.ID, passH)
 		if err != nil {
-			return errors.Newf("insert user: %v", err)
+			return errors.Newf("update password: %v", err)
 		}
-		if err := rf(tx, ActionVerify, id, usr); err != nil {
-			return err
+		err = a.db.InsertPassHistoryAtomic(tx, usr.ID, oldPassH)
+		if err != nil {
+			return errors.Newf("insert password history: %v", err)
 		}
-		return nil
+		addr, err = updtVerifiedFunc(tx, usr.ID, tkn.Address)
+		if err != nil {
+			return errors.Newf("update phone to verified: %v", err)
+		}
+		return a.saveHistory(tx, ci, usr.ID, AccessTypeResetPass, loginType, true)
 	})
-	if err != nil {
-		return nil, err
+	return addr, err
+}
+
+// SendVerCode sends a verification code to toAddr to verify the
+// address. loginType determines determines whether toAddr is a phone or an email.
+// subsequent calls to VerifyDBT() or VerifyAndExtendDBT() with the correct code
+// completes the verification.
+func (a *Authentication) SendVerCode(JWT, loginType, toAddr string) (*DBTStatus, error) {
+
+	var err error
+	var usr *User
+	var isMessengerAvail bool
+
+	switch loginType {
+	case LoginTypePhone:
+		toAddr, err = formatValidPhone(toAddr)
+		if err != nil {
+			return nil, errors.NewClient(err)
+		}
+		usr, _, err = a.db.UserByPhone(toAddr)
+		isMessengerAvail = a.smserNilable != nil
+	case LoginTypeEmail:
+		usr, _, err = a.db.UserByEmail(toAddr)
+		isMessengerAvail = a.mailerNilable != nil
+	default:
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
 
-	usr.JWT, err = a.jwter.Generate(newJWTClaim(usr.ID, usr.Group))
 	if err != nil {
-		return nil, errors.Newf("generate JWT: %v", err)
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("user by %s: %v", loginType, err)
 	}
 
-	usr.Group = *grp
-	usr.Type = *ut
+	if !isMessengerAvail {
+		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	}
 
-	return usr, nil
+	if err := a.jwtBelongsToOrHasPermission(JWT, usr.ID, PermUsersWrite, AccessLevelStaff); err != nil {
+		return nil, err
+	}
+
+	return a.genAndSendTokens(nil, ActionVerify, loginType, toAddr, usr.ID)
 }
 
-func (a *Authentication) registerOther(regerLrgstGrp Group, userType, groupID, id string, pass []byte, rcf regConditions, f regFunc, actionType ...string) (*User, error) {
+// SendConfirmCode sends a code to forUserID's primary address of loginType
+// with which they can re-confirm their identity e.g. to DeleteUser(). Only
+// the account's owner can request the code, which is valid as long as
+// verification codes are and cannot be used for anything else.
+func (a *Authentication) SendConfirmCode(JWT, forUserID, loginType string) (*DBTStatus, error) {
 
-	if !inStrs(userType, validUserTypes) {
-		return nil, errors.NewClientf("accountType must be one of %+v", validUserTypes)
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
 	}
-	if groupID == "" {
-		return nil, errors.NewClientf("new user's group ID was not specified")
+	if clms.UsrID != forUserID {
+		return nil, errors.NewForbidden("confirmation codes can only be sent to the account's owner")
 	}
-	usrGroup, err := a.db.Group(groupID)
+
+	usr, _, err := a.db.User(forUserID)
 	if err != nil {
 		if a.db.IsNotFoundError(err) {
-			return nil, errors.NewClient("groupID does not exist")
+			return nil, errors.NewNotFound(err)
 		}
-		return nil, errors.Newf("get group by ID: %v", err)
+		return nil, errors.Newf("get user: %v", err)
 	}
-	if regerLrgstGrp.AccessLevel > usrGroup.AccessLevel {
-		return nil, errors.NewForbiddenf("You do not have enough rights to perform this operation")
+
+	var toAddr string
+	var isMessengerAvail bool
+	switch loginType {
+	case LoginTypePhone:
+		toAddr = usr.Phone.Address
+		isMessengerAvail = a.smserNilable != nil
+	case LoginTypeEmail:
+		toAddr = usr.Email.Address
+		isMessengerAvail = a.mailerNilable != nil
+	default:
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
-	id, err = rcf(id)
-	if err != nil {
-		return nil, err
+	if toAddr == "" {
+		return nil, errors.NewNotFoundf("user has no %s", loginType)
+	}
+	if !isMessengerAvail {
+		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	}
+	if loginType == LoginTypePhone {
+		if err := a.otpSendAllowed(usr.ID, loginType, toAddr); err != nil {
+			return nil, err
+		}
 	}
 
-	ut, err := a.getOrCreateUserType(userType)
+	format, ok := a.actionOTPFormats[ActionVerify]
+	if !ok {
+		format = otpFormat{length: defOTPLen, gen: a.numGen}
+	}
+	code, err := format.gen.SecureRandomBytes(format.length)
 	if err != nil {
-		return nil, err
+		return nil, errors.Newf("generate %s confirmation code", loginType)
+	}
+	expiry := time.Now().Add(a.actionValidities[ActionVerify])
+	if err := a.hashAndInsertToken(nil, expiry, loginType, usr.ID, toAddr, confirmCodeSecret(code)); err != nil {
+		return nil, errors.Newf("%s confirmation code: %v", loginType, err)
 	}
 
-	passH, err := hashIfValid(pass)
+	sendData := VerificationTemplate{AppName: a.appNameEmptyable, Code: string(code)}
+	tpl := a.loginTpActionTplts[loginType][ActionVerify]
+	var obfuscateFunc func(string) string
+	switch loginType {
+	case LoginTypePhone:
+		obfuscateFunc = obfuscatePhone
+		err = a.sendSMS(toAddr, tpl, sendData)
+	case LoginTypeEmail:
+		obfuscateFunc = obfuscateEmail
+		err = a.sendEmail(toAddr, a.verSubjEmptyable, tpl, sendData)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	usr := new(User)
-	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
-		usr, err = a.db.InsertUserAtomic(tx, *ut, *usrGroup, passH)
-		if err != nil {
-			return errors.Newf("insert user: %v", err)
-		}
-		aT := ActionInvite
-		if len(actionType) > 0 {
-			aT = actionType[0]
-		}
-		if err := f(tx, aT, id, usr); err != nil {
-			return err
+	return &DBTStatus{
+		ObfuscatedAddress: obfuscateFunc(toAddr),
+		ExpiresAt:         expiry,
+	}, nil
+}
+
+// SendPassResetCode sends a password reset code to toAddr to allow a user
+// to reset their forgotten password.
+// loginType determines whether toAddr is a phone or an email.
+// subsequent calls to SetPassword() with the correct code completes the
+// password reset.
+func (a *Authentication) SendPassResetCode(loginType, toAddr string) (*DBTStatus, error) {
+	var err error
+	var usr *User
+	var isMessengerAvail bool
+
+	switch loginType {
+	case LoginTypePhone:
+		toAddr, err = formatValidPhone(toAddr)
+		if err != nil {
+			return nil, errors.NewClient(err)
+		}
+		usr, _, err = a.db.UserByPhone(toAddr)
+		isMessengerAvail = a.smserNilable != nil
+	case LoginTypeEmail:
+		usr, _, err = a.db.UserByEmail(toAddr)
+		isMessengerAvail = a.mailerNilable != nil
+	default:
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+
+	if !isMessengerAvail {
+		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	}
+
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+		}
+		return nil, errors.Newf("user by %s: %v", loginType, err)
+	}
+	if !addressUsable(*usr, loginType, toAddr) {
+		return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+	}
+
+	return a.genAndSendTokens(nil, ActionResetPass, loginType, toAddr, usr.ID)
+}
+
+// VerifyAndExtendDBT verifies a user's address and returns a temporary token
+// that can be used to perform actions that would otherwise not be possible on
+// the user's account without a password or a JWT for a limited period of time.
+// See VerifyDBT() for details on verification.
+func (a *Authentication) VerifyAndExtendDBT(ci ClientInfo, lt, userID string, dbt []byte) (string, error) {
+	lv, err := a.verifyDBT(ci, lt, userID, dbt)
+	if err != nil {
+		return "", err
+	}
+	expiry := time.Now().Add(a.actionValidities[ActionExtendTkn])
+	tkn, err := a.genAndInsertToken(nil, expiry, lt, lv.UserID, lv.Address)
+	if err != nil {
+		return "", err
+	}
+	return string(tkn), nil
+}
+
+// VerifyDBT sets a user's address as verified after successful SendVerCode()
+// and subsequent entry of the code by the user.
+// loginType should be similar to the one used during SendVerCode().
+func (a *Authentication) VerifyDBT(ci ClientInfo, loginType, userID string, dbt []byte) (*VerifLogin, error) {
+	return a.verifyDBT(ci, loginType, userID, dbt)
+}
+
+func (a *Authentication) UserID(loginType, identifier string) (string, error) {
+	usr, _, err := a.user(loginType, identifier)
+	if err != nil {
+		if a.IsClientError(err) || a.IsNotFoundError(err) {
+			return "", err
+		}
+		return "", errors.Newf("get user by %s: %v", loginType, err)
+	}
+	return usr.ID, nil
+}
+
+// Login validates a user's credentials and returns the user's information
+// together with a JWT for subsequent requests to this and other micro-services.
+// A refresh token bound to the client app's API key is also issued unless the
+// request was made using the master API key.
+// Login fails with a ForbiddenError if the identifier or ci.IPAddress has
+// been locked out following too many failed login attempts
+// (see WithLoginLockout()).
+func (a *Authentication) Login(ci ClientInfo, loginType, identifier string, password []byte) (*User, error) {
+
+	lockoutID := lockoutIdentifier(loginType, identifier)
+	lf, err := a.beginLoginAttempt(loginType, lockoutID, ci.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var usr *User
+	var passHB []byte
+	if loginType == LoginTypeOIDC {
+		// password carries the nonce the id_token was requested with.
+		usr, err = a.userByOIDC(identifier, string(password))
+	} else {
+		usr, passHB, err = a.user(loginType, identifier)
+	}
+	if err != nil {
+		// An invalid facebook token or id_token is reported as Forbidden.
+		if a.IsClientError(err) || a.IsNotFoundError(err) || a.IsForbiddenError(err) {
+			return nil, errorBadCreds
+		}
+		if a.IsNotImplementedError(err) {
+			return nil, a.abandonLoginAttempt(lf, err)
+		}
+		return nil, a.abandonLoginAttempt(lf, errors.Newf("get user by %s: %v", loginType, err))
+	}
+
+	passPwned := false
+	if !isFederated(loginType) {
+		needsRehash, err := a.passwordValid(passHB, password)
+		if err != nil {
+			if !a.IsForbiddenError(err) {
+				return nil, a.abandonLoginAttempt(lf, err)
+			}
+			return nil, a.attemptFailed(ci, usr.ID, AccessTypeLogin, loginType, err)
+		}
+		if needsRehash {
+			if err := a.rehashPassword(usr.ID, password); err != nil {
+				return nil, a.abandonLoginAttempt(lf, err)
+			}
+		}
+		if a.warnPwnedOnLogin {
+			// The warning is advisory so failure to look up the password
+			// does not prevent login.
+			passPwned, _ = a.passPwned(password)
+		}
+	}
+
+	if err := a.clearLoginFailures(loginType, lockoutID); err != nil {
+		return nil, err
+	}
+
+	if err := a.saveHistory(nil, ci, usr.ID, AccessTypeLogin, loginType, true); err != nil {
+		return nil, err
+	}
+
+	usr, err = a.issueLoginOrMFATokens(ci, usr)
+	if err != nil {
+		return nil, err
+	}
+	usr.PassPwned = passPwned
+	return usr, nil
+}
+
+// SendLoginLink sends a one time login link to toAddr. loginType determines
+// whether toAddr is a phone or an email, though only emails are currently
+// supported. The returned status carries the nonce that must accompany the
+// link's token to LoginByLink(), binding the link to the requester.
+// Links are throttled together with other tokens sent to toAddr as with
+// SendLoginOTP() (see WithLoginOTPThrottle()).
+func (a *Authentication) SendLoginLink(loginType, toAddr string) (*DBTStatus, error) {
+	if loginType != LoginTypeEmail {
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+	if a.mailerNilable == nil {
+		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	}
+	toAddr, err := normalizeValidEmail(toAddr, a.verifyEmailHost)
+	if err != nil {
+		return nil, errors.NewClient(err)
+	}
+	usr, _, err := a.db.UserByEmail(toAddr)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+		}
+		return nil, errors.Newf("user by %s: %v", loginType, err)
+	}
+	if !addressUsable(*usr, loginType, toAddr) {
+		return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+	}
+	if err := a.otpSendAllowed(usr.ID, loginType, toAddr); err != nil {
+		return nil, err
+	}
+	return a.genAndSendTokens(nil, ActionLogin, loginType, toAddr, usr.ID)
+}
+
+// LoginByLink completes a SendLoginLink() returning the user's information
+// together with a JWT as with Login(). dbt is the token from the link and
+// nonce the nonce returned by SendLoginLink(). The link can only be used
+// once.
+func (a *Authentication) LoginByLink(ci ClientInfo, loginType, userID, nonce string, dbt []byte) (*User, error) {
+	if loginType != LoginTypeEmail {
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+	if len(nonce) != loginLinkNonceLen || len(dbt) == 0 {
+		return nil, errors.NewUnauthorized("login link is invalid or was requested from another client")
+	}
+	if userID == "" {
+		return nil, errors.NewClientf("userID was empty")
+	}
+	usr, _, err := a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewUnauthorized("token is invalid")
+		}
+		return nil, errors.Newf("get user: %v", err)
+	}
+
+	// failures count against the user's primary email as they would for
+	// a password login with it.
+	lockoutID := lockoutIdentifier(loginType, usr.Email.Address)
+	lf, err := a.beginLoginAttempt(loginType, lockoutID, ci.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	tkn, err := a.dbTokenValid(usr.ID, loginLinkSecret(nonce, dbt), a.db.EmailTokens)
+	if err != nil {
+		return nil, a.attemptFailed(ci, usr.ID, AccessTypeLogin, loginType, err)
+	}
+
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err := a.db.SetEmailTokenUsedAtomic(tx, tkn.ID); err != nil {
+			return errors.Newf("update DBT, set used: %v", err)
+		}
+		return a.saveHistory(tx, ci, usr.ID, AccessTypeLogin, loginType, true)
+	})
+	if err != nil {
+		return nil, a.abandonLoginAttempt(lf, err)
+	}
+
+	if err := a.clearLoginFailures(loginType, lockoutID); err != nil {
+		return nil, err
+	}
+
+	return a.issueLoginOrMFATokens(ci, usr)
+}
+
+// SendLoginOTP sends a one time login code to toAddr which can be exchanged
+// for the user's JWT through LoginByOTP(). loginType determines whether
+// toAddr is a phone or an email, though only phones are currently supported.
+// The number of codes sent to a number is limited (see WithLoginOTPThrottle()).
+func (a *Authentication) SendLoginOTP(loginType, toAddr string) (*DBTStatus, error) {
+	if loginType != LoginTypePhone {
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+	if a.smserNilable == nil {
+		return nil, errors.NewNotImplementedf("notification method not available for %s", loginType)
+	}
+	toAddr, err := formatValidPhone(toAddr)
+	if err != nil {
+		return nil, errors.NewClient(err)
+	}
+	usr, _, err := a.db.UserByPhone(toAddr)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+		}
+		return nil, errors.Newf("user by %s: %v", loginType, err)
+	}
+	if !addressUsable(*usr, loginType, toAddr) {
+		return nil, errors.NewNotFoundf("%s does not exist", toAddr)
+	}
+	if err := a.otpSendAllowed(usr.ID, loginType, toAddr); err != nil {
+		return nil, err
+	}
+	return a.genAndSendTokens(nil, ActionLogin, loginType, toAddr, usr.ID)
+}
+
+// LoginByOTP completes a SendLoginOTP() returning the user's information
+// together with a JWT as with Login(). identifier is the phone the code was
+// sent to. Failed attempts count towards lockouts as with Login().
+func (a *Authentication) LoginByOTP(ci ClientInfo, loginType, identifier string, code []byte) (*User, error) {
+	if loginType != LoginTypePhone {
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+
+	lockoutID := lockoutIdentifier(loginType, identifier)
+	lf, err := a.beginLoginAttempt(loginType, lockoutID, ci.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	usr, _, err := a.user(loginType, identifier)
+	if err != nil {
+		if a.IsClientError(err) || a.IsNotFoundError(err) {
+			return nil, errorBadCreds
+		}
+		return nil, a.abandonLoginAttempt(lf, errors.Newf("get user by %s: %v", loginType, err))
+	}
+
+	tkn, err := a.dbTokenValid(usr.ID, code, a.db.PhoneTokens)
+	if err == nil && !addressUsable(*usr, loginType, tkn.Address) {
+		// the code was sent to a number the user no longer logs in with.
+		err = errors.NewUnauthorized("token is invalid")
+	}
+	if err != nil {
+		return nil, a.attemptFailed(ci, usr.ID, AccessTypeLogin, loginType, err)
+	}
+
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err := a.db.SetPhoneTokenUsedAtomic(tx, tkn.ID); err != nil {
+			return errors.Newf("update DBT, set used: %v", err)
+		}
+		return a.saveHistory(tx, ci, usr.ID, AccessTypeLogin, loginType, true)
+	})
+	if err != nil {
+		return nil, a.abandonLoginAttempt(lf, err)
+	}
+
+	if err := a.clearLoginFailures(loginType, lockoutID); err != nil {
+		return nil, err
+	}
+
+	return a.issueLoginOrMFATokens(ci, usr)
+}
+
+// VerifyMFA completes a Login() for a user with two-factor authentication
+// enabled. mfaToken is the MFA token returned by Login() and code is the
+// current TOTP code from the user's authenticator app. The JWT (and refresh
+// token) is issued as with Login().
+func (a *Authentication) VerifyMFA(ci ClientInfo, mfaToken, code string) (*User, error) {
+
+	clm := new(mfaClaim)
+	if _, err := a.jwter.Validate(mfaToken, clm); err != nil {
+		return nil, err
+	}
+
+	lf, err := a.beginLoginAttempt(LoginTypeMFA, clm.MFAUsrID, ci.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, secret, err := a.totpSecret(clm.MFAUsrID)
+	if err != nil {
+		return nil, a.abandonLoginAttempt(lf, err)
+	}
+	if !ts.IsConfirmed {
+		return nil, a.abandonLoginAttempt(lf, errors.NewForbidden("two-factor authentication not enabled"))
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), ts.LastStep)
+	if ok {
+		err = a.db.SetTOTPLastStep(ts.UserID, step)
+		if err != nil && !a.db.IsNotFoundError(err) {
+			return nil, a.abandonLoginAttempt(lf, errors.Newf("set TOTP last step: %v", err))
+		}
+		// NotFound means a concurrent request already consumed this code.
+		ok = err == nil
+	}
+	if !ok {
+		return nil, a.attemptFailed(ci, ts.UserID, AccessTypeMFA, LoginTypeMFA,
+			errors.NewUnauthorized("invalid two-factor authentication code"))
+	}
+
+	if err := a.clearLoginFailures(LoginTypeMFA, ts.UserID); err != nil {
+		return nil, err
+	}
+	if err := a.saveHistory(nil, ci, ts.UserID, AccessTypeMFA, LoginTypeMFA, true); err != nil {
+		return nil, err
+	}
+
+	usr, _, err := a.db.User(ts.UserID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewUnauthorized("invalid MFA token")
+		}
+		return nil, errors.Newf("get user: %v", err)
+	}
+	if err := checkNotSuspended(usr.Status); err != nil {
+		return nil, err
+	}
+
+	return a.issueLoginTokens(ci, usr)
+}
+
+// EnrollTOTP generates a new TOTP secret for userID. The secret only takes
+// effect once confirmed through ConfirmTOTP(). Only the owner of the account
+// can enroll.
+func (a *Authentication) EnrollTOTP(JWT, userID string) (*TOTPEnrollment, error) {
+	if a.mfaEncNilable == nil {
+		return nil, errorMFANotAvail
+	}
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
+	}
+	if clms.UsrID != userID {
+		return nil, errorInsufPriv
+	}
+
+	usr, _, err := a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound("user not found")
+		}
+		return nil, errors.Newf("get user: %v", err)
+	}
+
+	ts, err := a.db.TOTPSecret(userID)
+	if err != nil && !a.db.IsNotFoundError(err) {
+		return nil, errors.Newf("get TOTP secret: %v", err)
+	}
+	if err == nil && ts.IsConfirmed {
+		return nil, errors.NewConflict("two-factor authentication already enabled")
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, errors.Newf("generate TOTP secret: %v", err)
+	}
+	secretEnc, err := a.mfaEncNilable.Encrypt(secret)
+	if err != nil {
+		return nil, errors.Newf("encrypt TOTP secret: %v", err)
+	}
+	if _, err := a.db.UpsertTOTPSecret(userID, secretEnc); err != nil {
+		return nil, errors.Newf("save TOTP secret: %v", err)
+	}
+
+	issuer := a.appNameEmptyable
+	if issuer == "" {
+		issuer = config.Name
+	}
+	return &TOTPEnrollment{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.URI(issuer, totpAccountName(*usr), secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication for userID given the first
+// code generated from the secret returned by EnrollTOTP().
+func (a *Authentication) ConfirmTOTP(JWT, userID, code string) error {
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return err
+	}
+	if clms.UsrID != userID {
+		return errorInsufPriv
+	}
+
+	ts, secret, err := a.totpSecret(userID)
+	if err != nil {
+		return err
+	}
+	if ts.IsConfirmed {
+		return errors.NewConflict("two-factor authentication already enabled")
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), ts.LastStep)
+	if !ok {
+		return errors.NewUnauthorized("invalid two-factor authentication code")
+	}
+	if err := a.db.ConfirmTOTPSecret(userID, step); err != nil {
+		return errors.Newf("confirm TOTP secret: %v", err)
+	}
+	return nil
+}
+
+// ResetTOTP disables two-factor authentication for userID e.g. when the user
+// has lost their authenticator device. Only admins can reset.
+func (a *Authentication) ResetTOTP(JWT, userID string) error {
+	if err := a.jwtHasPermission(JWT, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return err
+	}
+	if err := a.userInTenant(userID); err != nil {
+		return err
+	}
+	if err := a.db.DeleteTOTPSecret(userID); err != nil {
+		return errors.Newf("delete TOTP secret: %v", err)
+	}
+	return nil
+}
+
+// Refresh exchanges a refresh token issued during Login() or a previous
+// Refresh() for a new JWT and a new refresh token. The exchanged refresh
+// token is invalidated. Attempting to reuse an invalidated refresh token
+// revokes all refresh tokens descending from the same Login().
+func (a *Authentication) Refresh(ci ClientInfo, refreshTkn string) (*User, error) {
+
+	rt, err := a.refreshToken(refreshTkn)
+	if err != nil {
+		return nil, err
+	}
+
+	if rt.IsUsed || rt.IsRevoked {
+		if err := a.db.RevokeRefreshTokenFamily(rt.FamilyID); err != nil {
+			return nil, errors.Newf("revoke refresh token family: %v", err)
+		}
+		return nil, errors.NewForbidden("refresh token already used or revoked")
+	}
+	if time.Now().After(rt.ExpiryDate) {
+		return nil, errors.NewAuth("refresh token has expired")
+	}
+	if rt.APIKeyID != ci.APIKeyID {
+		return nil, errors.NewForbidden("refresh token was not issued to this client")
+	}
+
+	usr, _, err := a.db.User(rt.UserID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewUnauthorized("invalid refresh token")
+		}
+		return nil, errors.Newf("get user: %v", err)
+	}
+	if err := checkNotSuspended(usr.Status); err != nil {
+		return nil, err
+	}
+
+	sess, err := a.db.SessionByFamilyID(rt.FamilyID)
+	if err != nil {
+		if !a.db.IsNotFoundError(err) {
+			return nil, errors.Newf("get session: %v", err)
+		}
+		// The refresh token family was issued before sessions were tracked.
+		sess, err = a.db.InsertSession(usr.ID, rt.FamilyID, ci.DeviceID, ci.IPAddress, ci.UserAgent)
+		if err != nil {
+			return nil, errors.Newf("insert session: %v", err)
+		}
+	}
+	if sess.IsRevoked {
+		return nil, errors.NewForbidden("session has been logged out")
+	}
+
+	reused := false
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err := a.db.SetRefreshTokenUsedAtomic(tx, rt.ID); err != nil {
+			if a.db.IsNotFoundError(err) {
+				// A concurrent Refresh() exchanged or revoked rt after it
+				// was fetched.
+				reused = true
+				return err
+			}
+			return errors.Newf("set refresh token used: %v", err)
+		}
+		usr.RefreshToken, err = a.genAndInsertRefreshToken(tx, usr.ID, rt.APIKeyID, rt.FamilyID)
+		return err
+	})
+	if reused {
+		if err := a.db.RevokeRefreshTokenFamily(rt.FamilyID); err != nil {
+			return nil, errors.Newf("revoke refresh token family: %v", err)
+		}
+		return nil, errors.NewForbidden("refresh token already used or revoked")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clmAttrs, err := a.claimAttributes(*usr)
+	if err != nil {
+		return nil, errors.Newf("get claim attributes: %v", err)
+	}
+
+	usr.JWT, err = a.jwter.Generate(newJWTClaim(usr.ID, a.tenantID, usr.Group, clmAttrs, sess.ID, a.jwtValidity(usr.Group)))
+	if err != nil {
+		return nil, errors.Newf("generate JWT: %v", err)
+	}
+
+	return usr, nil
+}
+
+// Introspect reports whether token is an active JWT issued by this service
+// and, if so, its claims. A token that is invalid, expired or revoked is
+// reported as inactive rather than as an error.
+func (a *Authentication) Introspect(token string) (*TokenIntrospection, error) {
+	clms, err := a.validateJWT(token)
+	if err != nil {
+		if a.IsAuthError(err) {
+			return &TokenIntrospection{Active: false}, nil
+		}
+		return nil, err
+	}
+	return &TokenIntrospection{Active: true, Claims: clms}, nil
+}
+
+// Revoke revokes token which is either a JWT or a refresh token. A revoked
+// JWT is rejected until it expires, a revoked refresh token can no longer
+// be exchanged through Refresh() and neither can any refresh token
+// descending from the same Login(). A refresh token can only be revoked
+// by the client it was issued to.
+// Revoking an invalid, expired or already revoked token is not an error.
+func (a *Authentication) Revoke(ci ClientInfo, token string) error {
+	if strings.Count(token, ".") == 2 {
+		return a.revokeJWT(token)
+	}
+	return a.revokeRefreshToken(ci, token)
+}
+
+func (a *Authentication) revokeJWT(JWT string) error {
+	clms := new(JWTClaim)
+	if _, err := a.jwter.Validate(JWT, clms); err != nil {
+		if a.IsAuthError(err) {
+			return nil
+		}
+		return errors.Newf("validate JWT: %v", err)
+	}
+	if clms.Id == "" {
+		return errors.NewClient("token does not support revocation")
+	}
+	err := a.db.InsertRevokedToken(clms.Id, clms.UsrID, time.Unix(clms.ExpiresAt, 0))
+	if err != nil {
+		return errors.Newf("insert revoked token: %v", err)
+	}
+	return nil
+}
+
+func (a *Authentication) revokeRefreshToken(ci ClientInfo, refreshTkn string) error {
+	rt, err := a.refreshToken(refreshTkn)
+	if err != nil {
+		if a.IsAuthError(err) {
+			return nil
+		}
+		return err
+	}
+	if rt.APIKeyID != ci.APIKeyID {
+		return errors.NewForbidden("refresh token was not issued to this client")
+	}
+	if err := a.db.RevokeRefreshTokenFamily(rt.FamilyID); err != nil {
+		return errors.Newf("revoke refresh token family: %v", err)
+	}
+	return nil
+}
+
+// LoginHistory fetches the access history of userID's account starting with
+// the newest. Only the owner of the account or staff can access the history.
+func (a *Authentication) LoginHistory(JWT, userID, offsetStr, countStr string) ([]History, error) {
+	if err := a.jwtBelongsToOrHasPermission(JWT, userID, PermUsersRead, AccessLevelStaff); err != nil {
+		return nil, err
+	}
+	if err := a.userInTenant(userID); err != nil {
+		return nil, err
+	}
+	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	if err != nil {
+		return nil, err
+	}
+	hs, err := a.db.HistoryByUserID(userID, offset, count)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("fetch history: %v", err)
+	}
+	return hs, nil
+}
+
+// Logout ends the session JWT was issued under. The JWT, and any other
+// JWT or refresh token issued under the same session, are rejected
+// thereafter. A JWT issued outside a login is revoked on its own.
+func (a *Authentication) Logout(JWT string) error {
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return err
+	}
+	if clms.SessionID == "" {
+		return a.revokeJWT(JWT)
+	}
+	if err := a.db.RevokeSession(clms.UsrID, clms.SessionID); err != nil {
+		return errors.Newf("revoke session: %v", err)
+	}
+	return nil
+}
+
+// LogoutAll ends all of userID's sessions and invalidates every JWT issued
+// to userID up to now, including those issued outside a session. Only the
+// owner of the account or an admin can log out everywhere.
+func (a *Authentication) LogoutAll(JWT, userID string) error {
+	if err := a.jwtBelongsToOrHasPermission(JWT, userID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return err
+	}
+	if err := a.userInTenant(userID); err != nil {
+		return err
+	}
+	if err := a.db.SetLoggedOutAll(userID, time.Now()); err != nil {
+		return errors.Newf("set logged out all: %v", err)
+	}
+	if err := a.db.RevokeSessions(userID); err != nil {
+		return errors.Newf("revoke sessions: %v", err)
+	}
+	return nil
+}
+
+// Sessions fetches userID's sessions starting with the most recently seen.
+// Only the owner of the account or staff can view the sessions.
+func (a *Authentication) Sessions(JWT, userID, offsetStr, countStr string) ([]Session, error) {
+	if err := a.jwtBelongsToOrHasPermission(JWT, userID, PermUsersRead, AccessLevelStaff); err != nil {
+		return nil, err
+	}
+	if err := a.userInTenant(userID); err != nil {
+		return nil, err
+	}
+	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := a.db.SessionsByUserID(userID, offset, count)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("fetch sessions: %v", err)
+	}
+	return ss, nil
+}
+
+// RevokeSession ends userID's session with sessionID. Only the owner of
+// the account or an admin can end a session.
+func (a *Authentication) RevokeSession(JWT, userID, sessionID string) error {
+	if err := a.jwtBelongsToOrHasPermission(JWT, userID, PermUsersWrite, AccessLevelAdmin); err != nil {
+		return err
+	}
+	if err := a.userInTenant(userID); err != nil {
+		return err
+	}
+	if err := a.db.RevokeSession(userID, sessionID); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound(err)
+		}
+		return errors.Newf("revoke session: %v", err)
+	}
+	return nil
+}
+
+// LoginLockout returns the lockout state of identifier of loginType.
+func (a *Authentication) LoginLockout(JWT, loginType, identifier string) (*Lockout, error) {
+	if err := a.jwtHasPermission(JWT, PermLockoutsRead, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	identifier = lockoutIdentifier(loginType, identifier)
+	lo, err := a.loginLockout(loginType, identifier)
+	if err != nil {
+		return nil, errors.Newf("get login lockout: %v", err)
+	}
+	return lo, nil
+}
+
+// IPLockout returns the lockout state of ipAddress in a's tenant. IP
+// lockouts, like login lockouts, are tracked per tenant.
+func (a *Authentication) IPLockout(JWT, ipAddress string) (*Lockout, error) {
+	if err := a.jwtHasPermission(JWT, PermLockoutsRead, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	lo, err := a.ipLockout(ipAddress)
+	if err != nil {
+		return nil, errors.Newf("get IP lockout: %v", err)
+	}
+	return lo, nil
+}
+
+// UnlockLogin clears failed login attempts for identifier of loginType
+// lifting any lockout in effect. It returns the resulting lockout state.
+func (a *Authentication) UnlockLogin(JWT, loginType, identifier string) (*Lockout, error) {
+	if err := a.jwtHasPermission(JWT, PermLockoutsWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	identifier = lockoutIdentifier(loginType, identifier)
+	if err := a.clearLoginFailures(loginType, identifier); err != nil {
+		return nil, err
+	}
+	return &Lockout{LoginType: loginType, Identifier: identifier}, nil
+}
+
+// UnlockIP clears failed login attempts in a's tenant made from ipAddress
+// lifting any lockout in effect. It returns the resulting lockout state.
+func (a *Authentication) UnlockIP(JWT, ipAddress string) (*Lockout, error) {
+	if err := a.jwtHasPermission(JWT, PermLockoutsWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	if ipAddress == "" {
+		return nil, errors.NewClient("IP address cannot be empty")
+	}
+	if err := a.db.DeleteLoginFailuresByIP(ipAddress); err != nil {
+		return nil, errors.Newf("delete login failures by IP: %v", err)
+	}
+	return &Lockout{IPAddress: ipAddress}, nil
+}
+
+func (a *Authentication) Users(JWT string, q UsersQuery, offsetStr, countStr string) ([]User, error) {
+	if err := a.jwtHasPermission(JWT, PermUsersRead, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Process(); err != nil {
+		return nil, err
+	}
+	usrs, err := a.db.Users(q, offset, count)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("fetch users: %v", err)
+	}
+	return usrs, nil
+}
+
+func (a *Authentication) GetUserDetails(JWT string, userID string) (*User, error) {
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
+	}
+	if clms.UsrID != userID {
+		if err := claimsHavePermission(*clms, PermUsersRead, AccessLevelStaff); err != nil {
+			return nil, err
+		}
+	}
+	usr, _, err := a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("fetch user: %v", err)
+	}
+
+	return usr, nil
+}
+
+// ExportUser fetches everything held about userID. Only the owner of the
+// account or an admin can export it.
+func (a *Authentication) ExportUser(JWT, userID string) (*UserExport, error) {
+	if err := a.jwtBelongsToOrHasPermission(JWT, userID, PermUsersRead, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+	usr, _, err := a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("fetch user: %v", err)
+	}
+	exp := &UserExport{User: *usr, ExportDate: time.Now()}
+
+	err = a.exportPages("API keys", func(offset int64) (int, error) {
+		ks, err := a.db.APIKeyMetaByUserID(userID, offset, exportPageSize)
+		exp.APIKeys = append(exp.APIKeys, ks...)
+		return len(ks), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = a.exportPages("sessions", func(offset int64) (int, error) {
+		ss, err := a.db.SessionsByUserID(userID, offset, exportPageSize)
+		exp.Sessions = append(exp.Sessions, ss...)
+		return len(ss), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = a.exportPages("refresh tokens", func(offset int64) (int, error) {
+		rts, err := a.db.RefreshTokensByUserID(userID, offset, exportPageSize)
+		for _, rt := range rts {
+			rt.Token = nil
+			exp.RefreshTokens = append(exp.RefreshTokens, rt)
+		}
+		return len(rts), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = a.exportPages("email tokens", func(offset int64) (int, error) {
+		dbts, err := a.db.EmailTokens(userID, offset, exportPageSize)
+		for _, dbt := range dbts {
+			dbt.Token = nil
+			exp.EmailTokens = append(exp.EmailTokens, dbt)
+		}
+		return len(dbts), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = a.exportPages("phone tokens", func(offset int64) (int, error) {
+		dbts, err := a.db.PhoneTokens(userID, offset, exportPageSize)
+		for _, dbt := range dbts {
+			dbt.Token = nil
+			exp.PhoneTokens = append(exp.PhoneTokens, dbt)
+		}
+		return len(dbts), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = a.exportPages("history", func(offset int64) (int, error) {
+		hs, err := a.db.HistoryByUserID(userID, offset, exportPageSize)
+		exp.LoginHistory = append(exp.LoginHistory, hs...)
+		return len(hs), err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exp.LinkedIdentities, err = a.db.LinkedIdentitiesByUserID(userID)
+	if err != nil && !a.db.IsNotFoundError(err) {
+		return nil, errors.Newf("export linked identities: %v", err)
+	}
+
+	ts, err := a.db.TOTPSecret(userID)
+	if err != nil && !a.db.IsNotFoundError(err) {
+		return nil, errors.Newf("export TOTP secret: %v", err)
+	}
+	if err == nil {
+		ts.Secret = nil
+		exp.TOTP = *ts
+	}
+
+	for loginType, ids := range lockoutIdentifiers(*usr) {
+		for _, identifier := range ids {
+			err = a.exportPages("login failures", func(offset int64) (int, error) {
+				lfs, err := a.db.LoginFailures(loginType, identifier, time.Time{}, offset, exportPageSize)
+				for _, lf := range lfs {
+					// the attempts may have been made by anyone, see UserExport.
+					lf.IPAddress = ""
+					exp.LoginFailures = append(exp.LoginFailures, lf)
+				}
+				return len(lfs), err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return exp, nil
+}
+
+func (a *Authentication) Groups(JWT, offsetStr, countStr string) ([]Group, error) {
+	if err := a.jwtHasPermission(JWT, PermGroupsRead, AccessLevelStaff); err != nil {
+		return nil, err
+	}
+	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.preparePrerequisiteGroups(); err != nil {
+		return nil, errors.Newf("prepare pre-requisite groups: %v", err)
+	}
+	grps, err := a.db.Groups(offset, count)
+	if err != nil {
+		// not checking not found because pre-requisite groups need exist in db
+		return nil, errors.Newf("fetch groups: %v", err)
+	}
+	return grps, nil
+}
+
+// CreateGroup creates a group with name and accessLevel. accessLevel is
+// within the range of minAccessLevel (most privileged) and maxAccessLevel
+// (least privileged). The creator's access level must be equal to or more
+// privileged than accessLevel.
+func (a *Authentication) CreateGroup(JWT, name string, accessLevel float32) (*Group, error) {
+
+	name, err := a.groupValid(name, accessLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.jwtCanManageGroup(JWT, accessLevel); err != nil {
+		return nil, err
+	}
+
+	if err := a.groupNameAvail("", name); err != nil {
+		return nil, err
+	}
+
+	grp, err := a.db.InsertGroup(name, accessLevel)
+	if err != nil {
+		return nil, errors.Newf("insert group: %v", err)
+	}
+	return grp, nil
+}
+
+// UpdateGroup renames the group having groupID to name and sets its
+// accessLevel. Built in groups cannot be updated. The updater's access level
+// must be equal to or more privileged than both the group's current and
+// new accessLevel.
+func (a *Authentication) UpdateGroup(JWT, groupID, name string, accessLevel float32) (*Group, error) {
+
+	if groupID == "" {
+		return nil, errors.NewClientf("group ID cannot be empty")
+	}
+
+	name, err := a.groupValid(name, accessLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	grp, err := a.customGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkACL := accessLevel
+	if grp.AccessLevel < checkACL {
+		checkACL = grp.AccessLevel
+	}
+	if err := a.jwtCanManageGroup(JWT, checkACL); err != nil {
+		return nil, err
+	}
+
+	if err := a.groupNameAvail(grp.ID, name); err != nil {
+		return nil, err
+	}
+
+	grp, err = a.db.UpdateGroup(grp.ID, name, accessLevel)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("update group: %v", err)
+	}
+	return grp, nil
+}
+
+// DeleteGroup deletes the group having groupID. Built in groups and groups
+// that still have users cannot be deleted.
+func (a *Authentication) DeleteGroup(JWT, groupID string) error {
+
+	if groupID == "" {
+		return errors.NewClientf("group ID cannot be empty")
+	}
+
+	grp, err := a.customGroup(groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.jwtCanManageGroup(JWT, grp.AccessLevel); err != nil {
+		return err
+	}
+
+	err = a.db.HasUsers(grp.ID)
+	if err == nil {
+		return errors.NewConflictf("group '%s' still has users,"+
+			" move them to another group first", grp.Name)
+	}
+	if !a.db.IsNotFoundError(err) {
+		return errors.Newf("check group has users: %v", err)
+	}
+
+	if err := a.db.DeleteGroup(grp.ID); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound(err)
+		}
+		return errors.Newf("delete group: %v", err)
+	}
+	return nil
+}
+
+// SetGroupPermissions replaces the permissions granted to the group having
+// groupID with perms. Built in groups cannot be updated. Only permissions
+// held by the updater can be granted. PermTenantsAll can only be granted to
+// groups of the default tenant.
+func (a *Authentication) SetGroupPermissions(JWT, groupID string, perms []string) (*Group, error) {
+
+	if groupID == "" {
+		return nil, errors.NewClientf("group ID cannot be empty")
+	}
+
+	perms, err := permissionsValid(perms)
+	if err != nil {
+		return nil, err
+	}
+
+	grp, err := a.customGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
+	}
+	if err := claimsCanManageGroup(*clms, grp.AccessLevel); err != nil {
+		return nil, err
+	}
+	for _, perm := range perms {
+		if err := a.claimsCanGrant(*clms, perm); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.db.SetGroupPermissions(grp.ID, perms); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("set group permissions: %v", err)
+	}
+	grp.Permissions = perms
+	return grp, nil
+}
+
+// UserTypes fetches user types.
+func (a *Authentication) UserTypes(JWT, offsetStr, countStr string) ([]UserType, error) {
+	if err := a.jwtHasPermission(JWT, PermUserTypesRead, AccessLevelStaff); err != nil {
+		return nil, err
+	}
+	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.preparePrerequisiteUserTypes(); err != nil {
+		return nil, errors.Newf("prepare pre-requisite user types: %v", err)
+	}
+	uts, err := a.db.UserTypes(offset, count)
+	if err != nil {
+		// not checking not found because pre-requisite user types need exist in db
+		return nil, errors.Newf("fetch user types: %v", err)
+	}
+	return uts, nil
+}
+
+// CreateUserType creates a user type named name. selfReg determines whether
+// users may pick the type during self registration. Users of the type must
+// be registered with each of reqLoginTypes.
+func (a *Authentication) CreateUserType(JWT, name string, selfReg bool, reqLoginTypes []string) (*UserType, error) {
+
+	name, reqLoginTypes, err := userTypeValid(name, reqLoginTypes)
+	if err != nil {
+		return nil, err
+	}
+	if inStrs(name, builtInUserTypes) {
+		return nil, errors.NewConflictf("user type name '%s' is reserved", name)
+	}
+
+	if err := a.jwtHasPermission(JWT, PermUserTypesWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := a.userTypeNameAvail("", name); err != nil {
+		return nil, err
+	}
+
+	ut, err := a.db.InsertUserType(name, selfReg, reqLoginTypes)
+	if err != nil {
+		return nil, errors.Newf("insert user type: %v", err)
+	}
+	return ut, nil
+}
+
+// UpdateUserType updates the user type having userTypeID. Built in user
+// types cannot be renamed.
+func (a *Authentication) UpdateUserType(JWT, userTypeID, name string, selfReg bool, reqLoginTypes []string) (*UserType, error) {
+
+	if userTypeID == "" {
+		return nil, errors.NewClientf("user type ID cannot be empty")
+	}
+
+	name, reqLoginTypes, err := userTypeValid(name, reqLoginTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.jwtHasPermission(JWT, PermUserTypesWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+
+	ut, err := a.db.UserType(userTypeID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("get user type: %v", err)
+	}
+	if name != ut.Name && inStrs(ut.Name, builtInUserTypes) {
+		return nil, errors.NewForbiddenf("built in user type '%s' cannot be renamed", ut.Name)
+	}
+	if name != ut.Name && inStrs(name, builtInUserTypes) {
+		return nil, errors.NewConflictf("user type name '%s' is reserved", name)
+	}
+
+	if err := a.userTypeNameAvail(ut.ID, name); err != nil {
+		return nil, err
+	}
+
+	ut, err = a.db.UpdateUserType(ut.ID, name, selfReg, reqLoginTypes)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("update user type: %v", err)
+	}
+	return ut, nil
+}
+
+// DeleteUserType deletes the user type having userTypeID. Built in user
+// types and user types that still have users cannot be deleted.
+func (a *Authentication) DeleteUserType(JWT, userTypeID string) error {
+
+	if userTypeID == "" {
+		return errors.NewClientf("user type ID cannot be empty")
+	}
+
+	if err := a.jwtHasPermission(JWT, PermUserTypesWrite, AccessLevelAdmin); err != nil {
+		return err
+	}
+
+	ut, err := a.db.UserType(userTypeID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound(err)
+		}
+		return errors.Newf("get user type: %v", err)
+	}
+	if inStrs(ut.Name, builtInUserTypes) {
+		return errors.NewForbiddenf("built in user type '%s' cannot be deleted", ut.Name)
+	}
+
+	err = a.db.HasUsersOfType(ut.ID)
+	if err == nil {
+		return errors.NewConflictf("user type '%s' still has users", ut.Name)
+	}
+	if !a.db.IsNotFoundError(err) {
+		return errors.Newf("check user type has users: %v", err)
+	}
+
+	if err := a.db.DeleteUserType(ut.ID); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound(err)
+		}
+		return errors.Newf("delete user type: %v", err)
+	}
+	return nil
+}
+
+// SetAttributeSchema sets the JSON Schema users' custom attributes must
+// conform to. schema must describe an object. claimAttrs names the
+// attributes (declared in schema's properties) to include in issued JWTs.
+// Attributes already stored are not re-validated.
+func (a *Authentication) SetAttributeSchema(JWT string, schema []byte, claimAttrs []string) (*AttributeSchema, error) {
+
+	s, err := jsonschema.Parse(schema)
+	if err != nil {
+		return nil, err
+	}
+	if s.Type != jsonschema.TypeObject {
+		return nil, errors.NewClientf("attribute schema type must be '%s'",
+			jsonschema.TypeObject)
+	}
+	for _, name := range claimAttrs {
+		if _, ok := s.Properties[name]; !ok {
+			return nil, errors.NewClientf("claim attribute '%s' is not a"+
+				" property of the attribute schema", name)
+		}
+	}
+	if claimAttrs == nil {
+		claimAttrs = []string{}
+	}
+
+	if err := a.jwtHasPermission(JWT, PermAttributeSchemaWrite, AccessLevelAdmin); err != nil {
+		return nil, err
+	}
+
+	as := AttributeSchema{Schema: schema, ClaimAttributes: claimAttrs}
+	if err := a.db.UpsertAttributeSchema(as); err != nil {
+		return nil, errors.Newf("upsert attribute schema: %v", err)
+	}
+	return &as, nil
+}
+
+// AttributeSchema fetches the JSON Schema users' custom attributes must
+// conform to.
+func (a *Authentication) AttributeSchema(JWT string) (*AttributeSchema, error) {
+	if _, err := a.validateJWT(JWT); err != nil {
+		return nil, err
+	}
+	return a.attributeSchema()
+}
+
+// UserAttributes fetches userID's custom attributes.
+func (a *Authentication) UserAttributes(JWT, userID string) (map[string]interface{}, error) {
+	usr, err := a.GetUserDetails(JWT, userID)
+	if err != nil {
+		return nil, err
+	}
+	if usr.Attributes == nil {
+		return map[string]interface{}{}, nil
+	}
+	return usr.Attributes, nil
+}
+
+// SetUserAttributes replaces userID's custom attributes with attrs after
+// validating them against the AttributeSchema. Users can set their own
+// attributes except the schema's ClaimAttributes, which are included in
+// their JWTs. Setting those or another user's attributes requires
+// PermUsersWrite or admin access.
+func (a *Authentication) SetUserAttributes(JWT, userID string, attrs map[string]interface{}) (map[string]interface{}, error) {
+
+	if userID == "" {
+		return nil, errors.NewClientf("user ID cannot be empty")
+	}
+	if attrs == nil {
+		attrs = map[string]interface{}{}
+	}
+	// round trip attrs through JSON for the schema to see the same types
+	// it would when attrs are fetched from the db.
+	attrsB, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, errors.NewClientf("invalid attributes: %v", err)
+	}
+	if len(attrsB) > maxAttributesSize {
+		return nil, errors.NewClientf("attributes cannot be larger than %d bytes",
+			maxAttributesSize)
+	}
+	attrs = nil
+	if err := json.Unmarshal(attrsB, &attrs); err != nil {
+		return nil, errors.Newf("unmarshal attributes: %v", err)
+	}
+
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	usr, _, err := a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("get user: %v", err)
+	}
+
+	isAdmin := claimsHavePermission(*clms, PermUsersWrite, AccessLevelAdmin) == nil
+	if clms.UsrID != userID {
+		if !isAdmin {
+			return nil, errorInsufPriv
+		}
+		if err := claimsHaveAccess(*clms, usr.Group.AccessLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	as, err := a.attributeSchema()
+	if err != nil {
+		if a.IsNotFoundError(err) {
+			return nil, errorAttrsNotAvail
+		}
+		return nil, err
+	}
+	if !isAdmin {
+		for _, name := range as.ClaimAttributes {
+			oldV, wasSet := usr.Attributes[name]
+			newV, isSet := attrs[name]
+			if wasSet != isSet || !reflect.DeepEqual(oldV, newV) {
+				return nil, errors.NewForbiddenf("attribute '%s' can only be set by an admin", name)
+			}
+		}
+	}
+	s, err := jsonschema.Parse(as.Schema)
+	if err != nil {
+		return nil, errors.Newf("parse stored attribute schema: %v", err)
+	}
+	if err := s.Validate(attrs); err != nil {
+		return nil, err
+	}
+
+	if err := a.db.SetUserAttributes(userID, attrs); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("set user attributes: %v", err)
+	}
+	return attrs, nil
+}
+
+// CreateTenant creates a tenant named name. The creator's group must have
+// been granted PermTenantsAll.
+func (a *Authentication) CreateTenant(JWT, name string) (*Tenant, error) {
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.NewClient("tenant name cannot be empty")
+	}
+	if len(name) > maxTenantNameLen {
+		return nil, errors.NewClientf("tenant name cannot be longer than %d characters",
+			maxTenantNameLen)
+	}
+
+	if err := a.jwtHasTenantsAll(JWT); err != nil {
+		return nil, err
+	}
+
+	_, err := a.db.TenantByName(name)
+	if err == nil {
+		return nil, errors.NewConflictf("tenant name '%s' not available", name)
+	}
+	if !a.db.IsNotFoundError(err) {
+		return nil, errors.Newf("get tenant by name: %v", err)
+	}
+
+	t, err := a.db.InsertTenant(name)
+	if err != nil {
+		return nil, errors.Newf("insert tenant: %v", err)
+	}
+	return t, nil
+}
+
+// Tenants fetches tenants. The fetcher's group must have been granted
+// PermTenantsAll.
+func (a *Authentication) Tenants(JWT, offsetStr, countStr string) ([]Tenant, error) {
+	if err := a.jwtHasTenantsAll(JWT); err != nil {
+		return nil, err
+	}
+	offset, count, err := unpackOffsetCount(offsetStr, countStr)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := a.db.Tenants(offset, count)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("fetch tenants: %v", err)
+	}
+	return ts, nil
+}
+
+func (a *Authentication) preparePrerequisiteGroups() ([]Group, error) {
+	var grps []Group
+	for name, acl := range builtInGroups {
+		grp, err := a.getOrCreateGroup(name, acl)
+		if err != nil {
+			return nil, errors.Newf("get or create group '%s' with acl %f: %v",
+				name, acl, err)
+		}
+		grps = append(grps, *grp)
+	}
+	return grps, nil
+}
+
+func (a *Authentication) usrIdentifierAvail(loginType, addr string, fetchErr error) error {
+	if fetchErr == nil {
+		return errors.NewConflictf("(%s) %s not available", loginType, addr)
+	}
+	if !a.db.IsNotFoundError(fetchErr) {
+		return errors.Newf("user by %s: %v", loginType, fetchErr)
+	}
+	return nil
+}
+
+func (a *Authentication) verifyDBT(ci ClientInfo, loginType, userID string, dbt []byte) (*VerifLogin, error) {
+
+	var tokensFetchFunc func(string, int64, int64) ([]DBToken, error)
+	var updateLoginFunc func(*sql.Tx, string, string) (*VerifLogin, error)
+	var setTokenUsedFunc func(*sql.Tx, string) error
+	var usr *User
+	var err error
+
+	switch loginType {
+	case LoginTypeEmail:
+		tokensFetchFunc = a.db.EmailTokens
+		updateLoginFunc = a.db.VerifyUserEmailAtomic
+		setTokenUsedFunc = a.db.SetEmailTokenUsedAtomic
+	case LoginTypePhone:
+		tokensFetchFunc = a.db.PhoneTokens
+		updateLoginFunc = a.db.VerifyUserPhoneAtomic
+		setTokenUsedFunc = a.db.SetPhoneTokenUsedAtomic
+	default:
+		return nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+
+	if userID == "" {
+		return nil, errors.NewClientf("userID was empty")
+	}
+	usr, _, err = a.db.User(userID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("get %s user: %v", loginType, err)
+	}
+
+	tkn, err := a.dbTokenValid(usr.ID, dbt, tokensFetchFunc)
+	if err != nil {
+		if hErr := a.saveHistory(nil, ci, usr.ID, AccessTypeVerify, loginType, false); hErr != nil {
+			return nil, hErr
+		}
+		return nil, err
+	}
+
+	var vl *VerifLogin
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err := setTokenUsedFunc(tx, tkn.ID); err != nil {
+			return errors.Newf("update DBT, set used: %v", err)
+		}
+		vl, err = updateLoginFunc(tx, usr.ID, tkn.Address)
+		if err != nil {
+			return errors.Newf("update phone to verified: %v", err)
+		}
+		return a.saveHistory(tx, ci, usr.ID, AccessTypeVerify, loginType, true)
+	})
+	return vl, err
+}
+
+func (a *Authentication) genAndSendTokens(tx *sql.Tx, action, loginType, toAddr, usrID string) (*DBTStatus, error) {
+
+	validity, ok := a.actionValidities[action]
+	if !ok {
+		return nil, errors.NewClientf(actionNotSupportedErrorF, action)
+	}
+
+	expiry := time.Now().Add(validity)
+	URL := ""
+
+	if action == ActionLogin && loginType == LoginTypeEmail {
+		return a.genAndSendLoginLink(expiry, loginType, toAddr, usrID)
+	}
+
+	if action == ActionVerify && a.serviceURLNilable != nil {
+		tkn, err := a.genAndInsertToken(tx, expiry, loginType, usrID, toAddr)
+		if err != nil {
+			return nil, err
+		}
+		useURL := new(url.URL)
+		*useURL = *a.serviceURLNilable
+		// GET /users/:userID/:loginType/verify/:OTP
+		useURL.Path = path.Join(useURL.Path, "users", usrID, loginType, ActionVerify, string(tkn))
+		useURL.RawQuery = a.linkQuery(url.Values{"redirectToWebApp": []string{"true"}})
+		URL = useURL.String()
+	}
+
+	if action != ActionVerify && action != ActionLogin && a.webAppURLNilable != nil {
+		tkn, err := a.genAndInsertToken(tx, expiry, loginType, usrID, toAddr)
+		if err != nil {
+			return nil, err
+		}
+		useURL := new(url.URL)
+		*useURL = *a.webAppURLNilable
+		useURL.Path = path.Join(useURL.Path, action, loginType, url.PathEscape(toAddr), string(tkn))
+		useURL.RawQuery = a.linkQuery(nil)
+		URL = useURL.String()
+	}
+
+	code, err := a.genAndInsertCode(tx, expiry, action, loginType, usrID, toAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var sendData interface{}
+	var subj string
+
+	switch action {
+	case ActionInvite:
+		sendData = InvitationTemplate{AppName: a.appNameEmptyable, URLToken: URL}
+		subj = a.invSubjEmptyable
+	case ActionVerify:
+		sendData = VerificationTemplate{AppName: a.appNameEmptyable, URLToken: URL,
+			Code: string(code)}
+		subj = a.verSubjEmptyable
+	case ActionResetPass:
+		sendData = VerificationTemplate{AppName: a.appNameEmptyable, URLToken: URL,
+			Code: string(code)}
+		subj = a.resPassSubjEmptyable
+	case ActionLogin:
+		sendData = VerificationTemplate{AppName: a.appNameEmptyable, Code: string(code)}
+		subj = a.lgnLinkSubjEmptyable
+	default:
+		return nil, errors.Newf(actionNotSupportedErrorF, action)
+	}
+
+	var obfuscateFunc func(string) string
+	tpl := a.loginTpActionTplts[loginType][action]
+
+	switch loginType {
+	case LoginTypePhone:
+		obfuscateFunc = obfuscatePhone
+		err = a.sendSMS(toAddr, tpl, sendData)
+	case LoginTypeEmail:
+		obfuscateFunc = obfuscateEmail
+		err = a.sendEmail(toAddr, subj, tpl, sendData)
+	default:
+		return nil, errors.Newf(loginTypeNotSupportedErrorF, loginType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DBTStatus{
+		ObfuscatedAddress: obfuscateFunc(toAddr),
+		ExpiresAt:         expiry,
+	}, nil
+}
+
+// regFuncs returns the regFunc and regConditions for loginType. nonce is
+// the nonce an OpenID Connect id_token was requested with.
+func (a *Authentication) regFuncs(loginType, nonce string) (regFunc, regConditions, error) {
+	switch loginType {
+	case LoginTypeUsername:
+		return a.regUsername, a.regUsernameConditions, nil
+	case LoginTypeEmail:
+		return a.regEmail, a.regEmailConditions, nil
+	case LoginTypePhone:
+		return a.regPhone, a.regPhoneConditions, nil
+	case LoginTypeFacebook:
+		if a.fbNilable == nil {
+			return nil, nil, errorFbNotAvail
+		}
+		return a.regFacebook, a.regFacebookConditions, nil
+	case LoginTypeOIDC:
+		if a.oidcNilable == nil {
+			return nil, nil, errorOIDCNotAvail
+		}
+		return a.regOIDC, a.regOIDCConditions(nonce), nil
+	default:
+		return nil, nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+}
+
+// registerSelf registers a user of userType who will be able to log in
+// using loginTypes.
+func (a *Authentication) registerSelf(userType string, loginTypes []string, id string, password []byte, rcf regConditions, rf regFunc) (*User, error) {
+
+	if !a.allowSelfReg {
+		return nil, errors.NewForbidden("registration closed from the public")
+	}
+
+	ut, err := a.registrationUserType(userType, true, loginTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	passH, err := a.hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err = rcf(id)
+	if err != nil {
+		return nil, err
+	}
+
+	grp, err := a.getOrCreateGroup(GroupUser, AccessLevelUser)
+	if err != nil {
+		return nil, err
+	}
+
+	usr := new(User)
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		usr, err = a.db.InsertUserAtomic(tx, *ut, *grp, passH)
+		if err != nil {
+			return errors.Newf("insert user: %v", err)
+		}
+		if err := rf(tx, ActionVerify, id, usr); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usr.JWT, err = a.jwter.Generate(newJWTClaim(usr.ID, a.tenantID, usr.Group, nil, "", a.jwtValidity(usr.Group)))
+	if err != nil {
+		return nil, errors.Newf("generate JWT: %v", err)
+	}
+
+	usr.Group = *grp
+	usr.Type = *ut
+
+	return usr, nil
+}
+
+// registerOther registers a user of userType, who will be able to log in
+// using loginTypes and whose password hash is passH, on behalf of a member
+// of regerLrgstGrp.
+func (a *Authentication) registerOther(regerLrgstGrp Group, userType string, loginTypes []string, groupID, id string, passH []byte, rcf regConditions, f regFunc, actionType ...string) (*User, error) {
+
+	ut, err := a.registrationUserType(userType, false, loginTypes)
+	if err != nil {
+		return nil, err
+	}
+	if groupID == "" {
+		return nil, errors.NewClientf("new user's group ID was not specified")
+	}
+	usrGroup, err := a.db.Group(groupID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewClient("groupID does not exist")
+		}
+		return nil, errors.Newf("get group by ID: %v", err)
+	}
+	if regerLrgstGrp.AccessLevel > usrGroup.AccessLevel {
+		return nil, errors.NewForbiddenf("You do not have enough rights to perform this operation")
+	}
+	id, err = rcf(id)
+	if err != nil {
+		return nil, err
+	}
+
+	usr := new(User)
+	err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+		usr, err = a.db.InsertUserAtomic(tx, *ut, *usrGroup, passH)
+		if err != nil {
+			return errors.Newf("insert user: %v", err)
+		}
+		aT := ActionInvite
+		if len(actionType) > 0 {
+			aT = actionType[0]
+		}
+		if err := f(tx, aT, id, usr); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usr.Group = *usrGroup
+	usr.Type = *ut
+
+	return usr, nil
+}
+
+func (a *Authentication) regUsernameConditions(username string) (string, error) {
+	username, err := normalizeValidUsername(username)
+	if err != nil {
+		return "", errors.NewClient(err)
+	}
+	_, _, err = a.db.UserByUsername(username)
+	return username, a.usrIdentifierAvail(LoginTypeUsername, username, err)
+}
+
+func (a *Authentication) regUsername(tx *sql.Tx, actionType, username string, usr *User) error {
+	uname, err := a.db.InsertUserNameAtomic(tx, usr.ID, username)
+	if err != nil {
+		return errors.Newf("insert username: %v", err)
+	}
+	usr.UserName = *uname
+	return nil
+}
+
+func (a *Authentication) regDevConditions(devID string) (string, error) {
+	_, _, err := a.db.UserByDeviceID(devID)
+	return devID, a.usrIdentifierAvail(LoginTypeDev, devID, err)
+}
+
+func (a *Authentication) regDevice(tx *sql.Tx, actionType, devID string, usr *User) error {
+	dev, err := a.db.InsertUserDeviceAtomic(tx, usr.ID, devID)
+	if err != nil {
+		return errors.Newf("insert device: %v", err)
+	}
+	usr.Devices = []Device{*dev}
+	return nil
+}
+
+func (a *Authentication) regPhoneConditions(number string) (string, error) {
+	number, err := formatValidPhone(number)
+	if err != nil {
+		return "", errors.NewClient(err)
+	}
+	_, _, err = a.db.UserByPhone(number)
+	return number, a.usrIdentifierAvail(LoginTypePhone, number, err)
+}
+
+func (a *Authentication) regPhone(tx *sql.Tx, actionType, number string, usr *User) error {
+	phone, err := a.db.InsertUserPhoneAtomic(tx, usr.ID, number, false)
+	if err != nil {
+		return errors.Newf("insert phone: %v", err)
+	}
+	usr.Phone = *phone
+	if a.smserNilable == nil {
+		return nil
+	}
+	otpSt, err := a.genAndSendTokens(tx, actionType, LoginTypePhone, number, usr.ID)
+	if err != nil {
+		return err
+	}
+	usr.Phone.OTPStatus = *otpSt
+	return nil
+}
+
+func (a *Authentication) regEmailConditions(email string) (string, error) {
+	email, err := normalizeValidEmail(email, a.verifyEmailHost)
+	if err != nil {
+		return "", errors.NewClient(err)
+	}
+	_, _, err = a.db.UserByEmail(email)
+	return email, a.usrIdentifierAvail(LoginTypeEmail, email, err)
+}
+
+func (a *Authentication) regEmail(tx *sql.Tx, actionType, address string, usr *User) error {
+	email, err := a.db.InsertUserEmailAtomic(tx, usr.ID, address, false)
+	if err != nil {
+		return errors.Newf("insert email: %v", err)
+	}
+	usr.Email = *email
+	if a.mailerNilable == nil {
+		return nil
+	}
+	otpSt, err := a.genAndSendTokens(tx, actionType, LoginTypeEmail, address, usr.ID)
+	if err != nil {
+		return err
+	}
+	usr.Email.OTPStatus = *otpSt
+	return nil
+}
+
+func (a *Authentication) regFacebookConditions(fbToken string) (string, error) {
+	if fbToken == "" {
+		return "", errors.NewClient("facebook token cannot be empty")
+	}
+	fbID, err := a.validateFbToken(fbToken)
+	if err != nil {
+		return "", err
+	}
+	_, err = a.db.UserByFacebook(fbID)
+	return fbID, a.usrIdentifierAvail(LoginTypeFacebook, fbID, err)
+}
+
+func (a *Authentication) regFacebook(tx *sql.Tx, actionType, fbID string, usr *User) error {
+	fb, err := a.db.InsertUserFbIDAtomic(tx, usr.ID, fbID, true)
+	if err != nil {
+		return errors.Newf("insert facebook: %v", err)
+	}
+	usr.Facebook = *fb
+	return nil
+}
+
+// regOIDCConditions returns the regConditions for an id_token issued
+// with nonce. The returned identifier is the linked identity of the token's
+// subject at its issuer (see oidcIdentity()).
+func (a *Authentication) regOIDCConditions(nonce string) regConditions {
+	return func(idToken string) (string, error) {
+		if idToken == "" {
+			return "", errors.NewClient("id_token cannot be empty")
+		}
+		iss, sub, err := a.validateOIDCToken(idToken, nonce)
+		if err != nil {
+			return "", err
+		}
+		_, err = a.db.UserByLinkedIdentity(iss, sub)
+		identity := oidcIdentity(iss, sub)
+		return identity, a.usrIdentifierAvail(LoginTypeOIDC, identity, err)
+	}
+}
+
+func (a *Authentication) regOIDC(tx *sql.Tx, actionType, identity string, usr *User) error {
+	iss, sub := splitOIDCIdentity(identity)
+	if _, err := a.db.InsertLinkedIdentityAtomic(tx, usr.ID, iss, sub); err != nil {
+		return errors.Newf("insert linked identity: %v", err)
+	}
+	return nil
+}
+
+func (a *Authentication) updateUsername(usrID, newUsrName string) (*Username, error) {
+	// TODO
+	//_, _, err := a.db.UserByUsername(newUsrName)
+	//if err = a.usrIdentifierAvail(LoginTypeUsername, err); err != nil {
+	//	return nil, err
+	//}
+	//uname, err := a.db.UpdateUserName(clm.UsrID, newUsrName)
+	//if err != nil {
+	//	return nil, errors.Newf("user by phone: %v", err)
+	//}
+	return nil, errors.NewNotImplemented()
+}
+
+func (a *Authentication) updatePhone(usrID string, old VerifLogin, newNum string) (*VerifLogin, error) {
+
+	newNum, err := a.regPhoneConditions(newNum)
+	if err != nil {
+		return nil, err
+	}
+
+	var phone *VerifLogin
+	if old.HasValue() {
+		err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+			// TODO archive instead
+			err := a.db.DeletePhoneTokensAtomic(tx, usrID, old.Address)
+			if err != nil && !a.db.IsNotFoundError(err) {
+				return errors.Newf("delete prev phone's tokens: %v", err)
+			}
+			phone, err = a.db.UpdateUserPhoneAtomic(tx, usrID, newNum, false)
+			if err != nil {
+				return errors.Newf("update phone: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		phone, err = a.db.InsertUserPhone(usrID, newNum, false)
+		if err != nil {
+			return nil, errors.Newf("insert phone: %v", err)
+		}
+	}
+
+	if a.smserNilable == nil {
+		return phone, nil
+	}
+	otpSt, err := a.genAndSendTokens(nil, ActionVerify, LoginTypePhone, newNum, usrID)
+	if err != nil {
+		return phone, err
+	}
+	phone.OTPStatus = *otpSt
+	return phone, nil
+}
+
+func (a *Authentication) updateEmail(usrID string, old VerifLogin, newAddr string) (*VerifLogin, error) {
+
+	newAddr, err := a.regEmailConditions(newAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var email *VerifLogin
+	if old.HasValue() {
+		err = a.db.ExecuteTx(func(tx *sql.Tx) error {
+			// TODO archive instead
+			err := a.db.DeleteEmailTokensAtomic(tx, usrID, old.Address)
+			if err != nil && !a.db.IsNotFoundError(err) {
+				return errors.Newf("delete prev email's tokens: %v", err)
+			}
+			email, err = a.db.UpdateUserEmailAtomic(tx, usrID, newAddr, false)
+			if err != nil {
+				return errors.Newf("update email: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		email, err = a.db.InsertUserEmail(usrID, newAddr, false)
+		if err != nil {
+			return nil, errors.Newf("insert email: %v", err)
+		}
+	}
+
+	if a.mailerNilable == nil {
+		return email, nil
+	}
+	otpSt, err := a.genAndSendTokens(nil, ActionVerify, LoginTypeEmail, newAddr, usrID)
+	if err != nil {
+		return email, err
+	}
+	email.OTPStatus = *otpSt
+	return email, nil
+}
+
+func (a *Authentication) genAndInsertToken(tx *sql.Tx, expiry time.Time, loginType, forUsrID, loginID string) ([]byte, error) {
+	dbt, err := a.urlTokenGen.SecureRandomBytes(56)
+	if err != nil {
+		return nil, errors.Newf("generate %s verification db token", loginType)
+	}
+	if err := a.hashAndInsertToken(tx, expiry, loginType, forUsrID, loginID, dbt); err != nil {
+		return nil, errors.Newf("%s verification db token: %v", loginType, err)
+	}
+	return dbt, nil
+}
+
+func (a *Authentication) genAndInsertCode(tx *sql.Tx, expiry time.Time, action, loginType, forUsrID, loginID string) ([]byte, error) {
+	format, ok := a.actionOTPFormats[action]
+	if !ok {
+		format = otpFormat{length: defOTPLen, gen: a.numGen}
+	}
+	code, err := format.gen.SecureRandomBytes(format.length)
+	if err != nil {
+		return nil, errors.Newf("generate %s verification code", loginType)
+	}
+	if err := a.hashAndInsertToken(tx, expiry, loginType, forUsrID, loginID, code); err != nil {
+		return nil, errors.Newf("%s verification code: %v", loginType, err)
+	}
+	return code, nil
+}
+
+// jwtValidity returns the lifetime of JWTs issued to members of grp.
+func (a *Authentication) jwtValidity(grp Group) time.Duration {
+	if v, ok := a.grpTokenValidities[grp.Name]; ok {
+		return v
+	}
+	return a.tokenValidity
+}
+
+func (a *Authentication) hashAndInsertToken(tx *sql.Tx, expiry time.Time, loginType, forUsrID, loginID string, code []byte) error {
+	codeH, err := hash(code)
+	if err != nil {
+		return errors.Newf("hash for storage: %v", err)
+	}
+
+	var insFuncAtomic func(tx *sql.Tx, userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*DBToken, error)
+	var insFunc func(userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*DBToken, error)
+
+	switch loginType {
+	case LoginTypePhone:
+		insFunc = a.db.InsertPhoneToken
+		insFuncAtomic = a.db.InsertPhoneTokenAtomic
+	case LoginTypeEmail:
+		insFunc = a.db.InsertEmailToken
+		insFuncAtomic = a.db.InsertEmailTokenAtomic
+	default:
+		return errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+	}
+
+	if tx == nil {
+		_, err = insFunc(forUsrID, loginID, codeH, false, expiry)
+	} else {
+		_, err = insFuncAtomic(tx, forUsrID, loginID, codeH, false, expiry)
+	}
+	if err != nil {
+		return errors.Newf("insert: %v", err)
+	}
+
+	return nil
+}
+
+// identityConfirmed returns a forbidden error unless confirmation proves
+// usr's identity. See DeleteUser() for confirmLoginType and confirmation.
+// A password is checked against lockouts and failures recorded as with
+// Login(). If confirmation is a code, the code's token is returned together
+// with the function for marking it used, which the caller must do as it
+// acts on the confirmation.
+func (a *Authentication) identityConfirmed(ci ClientInfo, usr *User, passH []byte, confirmLoginType string, confirmation []byte) (*DBToken, func(*sql.Tx, string) error, error) {
+	if len(confirmation) == 0 {
+		return nil, nil, errors.NewForbidden("password or code re-confirmation required")
+	}
+
+	var addr string
+	var fetchTokensFunc func(string, int64, int64) ([]DBToken, error)
+	var setTokenUsedFunc func(*sql.Tx, string) error
+	switch confirmLoginType {
+	case LoginTypeUsername:
+		loginType, lockoutID := passwordLockoutIdentifier(*usr)
+		lf, err := a.beginLoginAttempt(loginType, lockoutID, ci.IPAddress)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := a.passwordValid(passH, confirmation); err != nil {
+			if !a.IsForbiddenError(err) {
+				return nil, nil, a.abandonLoginAttempt(lf, err)
+			}
+			return nil, nil, a.attemptFailed(ci, usr.ID, AccessTypeConfirm, loginType, err)
+		}
+		if err := a.clearLoginFailures(loginType, lockoutID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, nil
+	case LoginTypeEmail:
+		addr = usr.Email.Address
+		fetchTokensFunc = a.db.EmailTokens
+		setTokenUsedFunc = a.db.SetEmailTokenUsedAtomic
+	case LoginTypePhone:
+		addr = usr.Phone.Address
+		fetchTokensFunc = a.db.PhoneTokens
+		setTokenUsedFunc = a.db.SetPhoneTokenUsedAtomic
+	default:
+		return nil, nil, errors.NewClientf(loginTypeNotSupportedErrorF, confirmLoginType)
+	}
+
+	tkn, err := a.dbTokenValid(usr.ID, confirmCodeSecret(confirmation), fetchTokensFunc)
+	if err != nil {
+		if a.IsUnauthorizedError(err) || a.IsAuthError(err) || a.IsForbiddenError(err) {
+			return nil, nil, errors.NewForbiddenf("confirmation code: %v", err)
+		}
+		return nil, nil, err
+	}
+	if addr == "" || tkn.Address != addr {
+		return nil, nil, errors.NewForbidden("invalid confirmation code")
+	}
+	return tkn, setTokenUsedFunc, nil
+}
+
+// confirmCodeSecret binds a code sent through SendConfirmCode() to
+// re-confirming a user's identity as loginLinkSecret() does for login links
+// so that codes sent for other actions cannot be presented in its place.
+func confirmCodeSecret(code []byte) []byte {
+	sum := sha256.Sum256([]byte(actionConfirm + ":" + string(code)))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// otpSendAllowed returns a forbidden error if the number of codes or links
+// sent to toAddr of loginType (phone or email) within the throttle window
+// has reached the limit. Every code and token sent to the address counts
+// towards the limit.
+func (a *Authentication) otpSendAllowed(userID, loginType, toAddr string) error {
+	if a.otpSendLimit <= 0 {
+		return nil
+	}
+	tokens := a.db.PhoneTokens
+	obfuscatedAddr := obfuscatePhone(toAddr)
+	if loginType == LoginTypeEmail {
+		tokens = a.db.EmailTokens
+		obfuscatedAddr = obfuscateEmail(toAddr)
+	}
+	since := time.Now().Add(-a.otpSendWindow)
+	var sentAt []time.Time
+	offset := int64(0)
+	count := int64(100)
+	for {
+		tkns, err := tokens(userID, offset, count)
+		if a.db.IsNotFoundError(err) {
+			break
+		}
+		if err != nil {
+			return errors.Newf("get %s db tokens: %v", loginType, err)
+		}
+		for _, tkn := range tkns {
+			if tkn.Address == toAddr && tkn.IssueDate.After(since) {
+				sentAt = append(sentAt, tkn.IssueDate)
+			}
+		}
+		if int64(len(tkns)) < count {
+			break
+		}
+		offset = offset + count
+	}
+	if len(sentAt) < a.otpSendLimit {
+		return nil
+	}
+	sort.Slice(sentAt, func(i, j int) bool { return sentAt[i].After(sentAt[j]) })
+	retryAt := sentAt[a.otpSendLimit-1].Add(a.otpSendWindow)
+	return errors.NewForbiddenf("too many messages sent to %s, try again after %s",
+		obfuscatedAddr, retryAt.Format(time.RFC3339))
+}
+
+// issueLoginOrMFATokens returns usr with login tokens issued as with
+// issueLoginTokens() or, if usr has two-factor authentication enabled, only
+// the user's ID and an MFA token to be exchanged through VerifyMFA().
+// Suspended users are refused.
+func (a *Authentication) issueLoginOrMFATokens(ci ClientInfo, usr *User) (*User, error) {
+	if err := checkNotSuspended(usr.Status); err != nil {
+		return nil, err
+	}
+	mfaOn, err := a.mfaEnabled(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaOn {
+		mfaTkn, err := a.jwter.Generate(newMFAClaim(usr.ID))
+		if err != nil {
+			return nil, errors.Newf("generate MFA token: %v", err)
+		}
+		return &User{ID: usr.ID, MFAToken: mfaTkn}, nil
+	}
+	return a.issueLoginTokens(ci, usr)
+}
+
+// linkQuery encodes q for use in a link sent to a user adding the
+// tenantID parameter for links to users outside the default tenant. The
+// link's handler resolves the tenant from tenantID as it cannot rely on
+// an API key.
+func (a *Authentication) linkQuery(q url.Values) string {
+	if a.tenantID == "" {
+		return q.Encode()
+	}
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("tenantID", a.tenantID)
+	return q.Encode()
+}
+
+// genAndSendLoginLink generates a login link token and nonce and sends the
+// link to toAddr. Only the hash of the token bound to the nonce is stored
+// (see loginLinkSecret()), so the link is useless without the nonce.
+func (a *Authentication) genAndSendLoginLink(expiry time.Time, loginType, toAddr, usrID string) (*DBTStatus, error) {
+
+	var baseURL *url.URL
+	var linkPath string
+	if a.serviceURLNilable != nil {
+		// GET /users/:userID/:loginType/login/:OTP
+		baseURL = a.serviceURLNilable
+		linkPath = path.Join("users", usrID, loginType, ActionLogin)
+	} else if a.webAppURLNilable != nil {
+		baseURL = a.webAppURLNilable
+		linkPath = path.Join(ActionLogin, loginType, usrID)
+	} else {
+		return nil, errors.NewNotImplementedf("login links need a service or web app URL")
+	}
+
+	nonce, err := a.urlTokenGen.SecureRandomBytes(loginLinkNonceLen)
+	if err != nil {
+		return nil, errors.Newf("generate login link nonce: %v", err)
+	}
+	tkn, err := a.urlTokenGen.SecureRandomBytes(56)
+	if err != nil {
+		return nil, errors.Newf("generate login link token: %v", err)
+	}
+	err = a.hashAndInsertToken(nil, expiry, loginType, usrID, toAddr, loginLinkSecret(string(nonce), tkn))
+	if err != nil {
+		return nil, errors.Newf("%s login link token: %v", loginType, err)
+	}
+
+	useURL := new(url.URL)
+	*useURL = *baseURL
+	useURL.Path = path.Join(useURL.Path, linkPath, string(tkn))
+	useURL.RawQuery = a.linkQuery(nil)
+	sendData := LoginLinkTemplate{AppName: a.appNameEmptyable, URLToken: useURL.String()}
+
+	tpl := a.loginTpActionTplts[loginType][ActionLogin]
+	if err := a.sendEmail(toAddr, a.lgnLinkSubjEmptyable, tpl, sendData); err != nil {
+		return nil, err
+	}
+
+	return &DBTStatus{
+		ObfuscatedAddress: obfuscateEmail(toAddr),
+		ExpiresAt:         expiry,
+		Nonce:             string(nonce),
+	}, nil
+}
+
+// loginLinkSecret binds a login link token to its nonce. The digest keeps
+// the secret within bcrypt's 72 byte limit and, being longer than the codes
+// and tokens of other actions that share storage with login link tokens,
+// prevents any of those from being presented as a login link.
+func loginLinkSecret(nonce string, tkn []byte) []byte {
+	sum := sha256.Sum256([]byte(ActionLogin + ":" + nonce + ":" + string(tkn)))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// issueLoginTokens starts a new session for usr and sets a new JWT on usr
+// and, unless ci has no API key, a refresh token starting a new refresh
+// token family.
+func (a *Authentication) issueLoginTokens(ci ClientInfo, usr *User) (*User, error) {
+	var famID string
+	if ci.APIKeyID != "" {
+		famIDB, err := a.urlTokenGen.SecureRandomBytes(56)
+		if err != nil {
+			return nil, errors.Newf("generate refresh token family ID: %v", err)
+		}
+		famID = string(famIDB)
+	}
+
+	sess, err := a.db.InsertSession(usr.ID, famID, ci.DeviceID, ci.IPAddress, ci.UserAgent)
+	if err != nil {
+		return nil, errors.Newf("insert session: %v", err)
+	}
+
+	clmAttrs, err := a.claimAttributes(*usr)
+	if err != nil {
+		return nil, errors.Newf("get claim attributes: %v", err)
+	}
+
+	usr.JWT, err = a.jwter.Generate(newJWTClaim(usr.ID, a.tenantID, usr.Group, clmAttrs, sess.ID, a.jwtValidity(usr.Group)))
+	if err != nil {
+		return nil, errors.Newf("generate JWT: %v", err)
+	}
+
+	if famID == "" {
+		return usr, nil
+	}
+	usr.RefreshToken, err = a.genAndInsertRefreshToken(nil, usr.ID, ci.APIKeyID, famID)
+	if err != nil {
+		return nil, err
+	}
+
+	return usr, nil
+}
+
+// refreshToken fetches the refresh token matching refreshTkn which takes the
+// form "<ID>.<secret>" as generated by genAndInsertRefreshToken().
+func (a *Authentication) refreshToken(refreshTkn string) (*RefreshToken, error) {
+	parts := strings.SplitN(refreshTkn, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.NewUnauthorized("invalid refresh token")
+	}
+	rt, err := a.db.RefreshToken(parts[0])
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewUnauthorized("invalid refresh token")
+		}
+		return nil, errors.Newf("get refresh token: %v", err)
+	}
+	if err := bcrypt.CompareHashAndPassword(rt.Token, []byte(parts[1])); err != nil {
+		return nil, errors.NewUnauthorized("invalid refresh token")
+	}
+	return rt, nil
+}
+
+// genAndInsertRefreshToken generates a refresh token and persists its hash
+// using tx if non-nil. The returned token takes the form "<ID>.<secret>".
+func (a *Authentication) genAndInsertRefreshToken(tx *sql.Tx, usrID, apiKeyID, famID string) (string, error) {
+	secret, err := a.urlTokenGen.SecureRandomBytes(56)
+	if err != nil {
+		return "", errors.Newf("generate refresh token: %v", err)
+	}
+	secretH, err := hash(secret)
+	if err != nil {
+		return "", errors.Newf("hash refresh token for storage: %v", err)
+	}
+	expiry := time.Now().Add(refreshTknValidity)
+	var rt *RefreshToken
+	if tx == nil {
+		rt, err = a.db.InsertRefreshToken(usrID, apiKeyID, famID, secretH, expiry)
+	} else {
+		rt, err = a.db.InsertRefreshTokenAtomic(tx, usrID, apiKeyID, famID, secretH, expiry)
+	}
+	if err != nil {
+		return "", errors.Newf("insert refresh token: %v", err)
+	}
+	return rt.ID + "." + string(secret), nil
+}
+
+func (a *Authentication) sendEmail(toAddr, subj string, t *template.Template, data interface{}) error {
+	if a.mailerNilable == nil {
+		return errors.New("Mailer was nil")
+	}
+	emailBf := bytes.NewBuffer(make([]byte, 0, 256))
+	err := t.Execute(emailBf, data)
+	if err != nil {
+		return errors.Newf("email body from template: %v", err)
+	}
+	err = a.mailerNilable.SendEmail(SendMail{
+		ToEmails: []string{toAddr},
+		Subject:  subj,
+		Body:     template.HTML(emailBf.String()),
+	})
+	if err != nil {
+		return errors.Newf("send email: %v", err)
+	}
+	return nil
+}
+
+func (a *Authentication) sendSMS(toPhone string, t *template.Template, data interface{}) error {
+	if a.smserNilable == nil {
+		return errors.New("SMSer was nil")
+	}
+	SMSBf := bytes.NewBuffer(make([]byte, 0, 256))
+	err := t.Execute(SMSBf, data)
+	if err != nil {
+		return errors.Newf("SMS from template: %v", err)
+	}
+	if err := a.smserNilable.SMS(toPhone, SMSBf.String()); err != nil {
+		return errors.Newf("send SMS: %v", err)
+	}
+	return nil
+}
+
+func (a *Authentication) dbTokenValid(userID string, checkDBT []byte,
+	f func(userID string, offset, count int64) ([]DBToken, error)) (*DBToken, error) {
+
+	if len(checkDBT) == 0 {
+		return nil, errors.NewUnauthorized("confirmation token cannot be empty")
+	}
+	if userID == "" {
+		return nil, errors.NewUnauthorized("userID cannot be empty")
+	}
+	offset := int64(0)
+	count := int64(100)
+	var dbt DBToken
+resumeFunc:
+	for {
+		codes, err := f(userID, offset, count)
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewUnauthorized("token is invalid")
+		}
+		if err != nil {
+			return nil, errors.Newf("get phone db tokens: %v", err)
+		}
+		offset = offset + count
+		for _, dbt = range codes {
+			err = bcrypt.CompareHashAndPassword(dbt.Token, checkDBT)
+			if err != nil {
+				continue
+			}
+			break resumeFunc
+		}
+	}
+	if dbt.IsUsed {
+		return nil, errors.NewForbiddenf("token already used")
+	}
+	if time.Now().After(dbt.ExpiryDate) {
+		return nil, errors.NewAuth("token has expired")
+	}
+	return &dbt, nil
+}
+
+// groupValid validates a group's name and accessLevel returning the
+// trimmed name.
+func (a *Authentication) groupValid(name string, accessLevel float32) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.NewClient("group name cannot be empty")
+	}
+	if len(name) > maxGroupNameLen {
+		return "", errors.NewClientf("group name cannot be longer than %d characters",
+			maxGroupNameLen)
+	}
+	if _, ok := builtInGroups[name]; ok {
+		return "", errors.NewConflictf("group name '%s' is reserved", name)
+	}
+	if accessLevel < minAccessLevel || accessLevel > maxAccessLevel {
+		return "", errors.NewClientf("access level must be between %v and %v",
+			minAccessLevel, maxAccessLevel)
+	}
+	return name, nil
+}
+
+// groupNameAvail returns a ConflictError if a group other than the one
+// having groupID is named name.
+func (a *Authentication) groupNameAvail(groupID, name string) error {
+	grp, err := a.db.GroupByName(name)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil
+		}
+		return errors.Newf("get group by name: %v", err)
+	}
+	if grp.ID != groupID {
+		return errors.NewConflictf("group name '%s' not available", name)
+	}
+	return nil
+}
+
+// customGroup fetches the group having groupID returning a ForbiddenError
+// if it is a built in group.
+func (a *Authentication) customGroup(groupID string) (*Group, error) {
+	grp, err := a.db.Group(groupID)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound(err)
+		}
+		return nil, errors.Newf("get group: %v", err)
+	}
+	if _, ok := builtInGroups[grp.Name]; ok {
+		return nil, errors.NewForbiddenf("built in group '%s' cannot be changed", grp.Name)
+	}
+	return grp, nil
+}
+
+func (a *Authentication) jwtCanManageGroup(JWT string, accessLevel float32) error {
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
+		return err
+	}
+	return claimsCanManageGroup(*clms, accessLevel)
+}
+
+// claimsCanManageGroup returns nil if clms allow managing groups and are
+// not less privileged than a group having accessLevel.
+func claimsCanManageGroup(clms JWTClaim, accessLevel float32) error {
+	if err := claimsHavePermission(clms, PermGroupsWrite, AccessLevelAdmin); err != nil {
+		return err
+	}
+	return claimsHaveAccess(clms, accessLevel)
+}
+
+// claimsCanGrant returns a Forbidden error if clms may not grant perm to a
+// group in a's tenant. PermTenantsAll must be held explicitly (or by a
+// super user of the default tenant to bootstrap it) since it carries no
+// access level fallback.
+func (a *Authentication) claimsCanGrant(clms JWTClaim, perm string) error {
+	if perm != PermTenantsAll {
+		if err := claimsHavePermission(clms, perm, AccessLevelAdmin); err != nil {
+			return errors.NewForbiddenf("cannot grant '%s' without holding it", perm)
+		}
+		return nil
+	}
+	if a.tenantID != "" {
+		return errors.NewForbiddenf("'%s' can only be granted in the default tenant", perm)
+	}
+	if clms.HasPermission(PermTenantsAll) {
+		return nil
+	}
+	if clms.TenantID == "" && clms.Group.AccessLevel <= AccessLevelSuper {
+		return nil
+	}
+	return errors.NewForbiddenf("cannot grant '%s' without holding it", perm)
+}
+
+// permissionsValid returns perms without duplicates or a ClientError if
+// any of them is not a known permission.
+func permissionsValid(perms []string) ([]string, error) {
+	seen := make(map[string]bool)
+	valid := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		if !validPermissions[perm] {
+			return nil, errors.NewClientf("unknown permission '%s'", perm)
+		}
+		if seen[perm] {
+			continue
+		}
+		seen[perm] = true
+		valid = append(valid, perm)
+	}
+	return valid, nil
+}
+
+func (a *Authentication) getOrCreateGroup(groupName string, acl float32) (*Group, error) {
+	grp, err := a.db.GroupByName(groupName)
+	if err != nil {
+		if !a.db.IsNotFoundError(err) {
+			return nil, errors.Newf("get group by name: %v", err)
+		}
+		grp, err = a.db.InsertGroup(groupName, acl)
+		if err != nil {
+			return nil, errors.Newf("insert group: %v", err)
+		}
+	}
+	return grp, nil
+}
+
+// registrationUserType fetches the user type named name, creating it if
+// it is a built in user type, and checks that it can be assigned to a user
+// registering themselves (selfReg) with loginTypes.
+func (a *Authentication) registrationUserType(name string, selfReg bool, loginTypes []string) (*UserType, error) {
+	ut, err := a.db.UserTypeByName(name)
+	if err != nil {
+		if !a.db.IsNotFoundError(err) {
+			return nil, errors.Newf("get user type by name: %v", err)
+		}
+		if !inStrs(name, builtInUserTypes) {
+			return nil, errors.NewClientf("user type '%s' does not exist", name)
+		}
+		if ut, err = a.getOrCreateUserType(name); err != nil {
+			return nil, err
+		}
+	}
+	if selfReg && !ut.SelfRegistrable {
+		return nil, errors.NewForbiddenf("user type '%s' cannot be picked"+
+			" during self registration", name)
+	}
+	for _, lt := range ut.RequiredLoginTypes {
+		if !inStrs(lt, loginTypes) {
+			return nil, errors.NewClientf("user type '%s' requires"+
+				" registering with %s", name, lt)
+		}
+	}
+	return ut, nil
+}
+
+// userTypeValid returns name trimmed and reqLoginTypes without duplicates
+// or a ClientError if either is invalid.
+func userTypeValid(name string, reqLoginTypes []string) (string, []string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.NewClient("user type name cannot be empty")
+	}
+	if len(name) > maxUserTypeNameLen {
+		return "", nil, errors.NewClientf("user type name cannot be longer than %d characters",
+			maxUserTypeNameLen)
+	}
+	valid := make([]string, 0, len(reqLoginTypes))
+	for _, lt := range reqLoginTypes {
+		if !inStrs(lt, validRequiredLoginTypes) {
+			return "", nil, errors.NewClientf("required login type must be one of %+v",
+				validRequiredLoginTypes)
+		}
+		if inStrs(lt, valid) {
+			continue
+		}
+		valid = append(valid, lt)
+	}
+	return name, valid, nil
+}
+
+// userTypeNameAvail returns a ConflictError if a user type other than the
+// one having userTypeID is named name.
+func (a *Authentication) userTypeNameAvail(userTypeID, name string) error {
+	ut, err := a.db.UserTypeByName(name)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return nil
+		}
+		return errors.Newf("get user type by name: %v", err)
+	}
+	if ut.ID != userTypeID {
+		return errors.NewConflictf("user type name '%s' not available", name)
+	}
+	return nil
+}
+
+func (a *Authentication) preparePrerequisiteUserTypes() error {
+	for _, name := range builtInUserTypes {
+		if _, err := a.getOrCreateUserType(name); err != nil {
+			return errors.Newf("get or create user type '%s': %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (a *Authentication) getOrCreateUserType(name string) (*UserType, error) {
+	ut, err := a.db.UserTypeByName(name)
+	if err != nil {
+		if !a.db.IsNotFoundError(err) {
+			return nil, errors.Newf("get user type by name: %v", err)
+		}
+		ut, err = a.db.InsertUserType(name, true, nil)
+		if err != nil {
+			return nil, errors.Newf("insert user type: %v", err)
+		}
+	}
+	return ut, nil
+}
+
+// user fetches the user and password hash (if applicable) for the provided parameters.
+// The passH will be non-nil empty if not applicable and successful.
+// It returns a ClientError if the identifier is badly formatted, a NotFoundError if
+// none was found, a ForbiddenError if the loginType was was facebook and the token
+// was invalid or a general error if something went wrong during fetch.
+func (a *Authentication) user(loginType, identifier string) (usr *User, passH []byte, err error) {
+	switch loginType {
+	case LoginTypePhone:
+		identifier, err = formatValidPhone(identifier)
+		if err != nil {
+			return nil, nil, errors.NewClient(err)
+		}
+		usr, passH, err = a.db.UserByPhone(identifier)
+		if err == nil && !addressUsable(*usr, loginType, identifier) {
+			return nil, nil, errors.NewNotFound("user does not exist")
+		}
+	case LoginTypeEmail:
+		identifier, err = normalizeValidEmail(identifier, a.verifyEmailHost)
+		if err != nil {
+			return nil, nil, errors.NewClient(err)
+		}
+		usr, passH, err = a.db.UserByEmail(identifier)
+		if err == nil && !addressUsable(*usr, loginType, identifier) {
+			return nil, nil, errors.NewNotFound("user does not exist")
+		}
+	case LoginTypeUsername:
+		identifier, err = normalizeValidUsername(identifier)
+		if err != nil {
+			return nil, nil, errors.NewClient(err)
+		}
+		usr, passH, err = a.db.UserByUsername(identifier)
+	case LoginTypeFacebook:
+		identifier, err = a.validateFbToken(identifier)
+		if err != nil {
+			return nil, nil, errors.NewForbidden("invalid facebook token")
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		usr, err = a.db.UserByFacebook(identifier)
+		passH = make([]byte, 0)
+	default:
+		return nil, nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
 	}
-
-	usr.Group = *usrGroup
-	usr.Type = *ut
-
-	return usr, nil
-}
-
-func (a *Authentication) regUsernameConditions(username string) (string, error) {
-	username, err := normalizeValidUsername(username)
 	if err != nil {
-		return "", errors.NewClient(err)
+		if a.db.IsNotFoundError(err) {
+			return nil, nil, errors.NewNotFound("user does not exist")
+		}
 	}
-	_, _, err = a.db.UserByUsername(username)
-	return username, a.usrIdentifierAvail(LoginTypeUsername, username, err)
+	return
 }
 
-func (a *Authentication) regUsername(tx *sql.Tx, actionType, username string, usr *User) error {
-	uname, err := a.db.InsertUserNameAtomic(tx, usr.ID, username)
-	if err != nil {
-		return errors.Newf("insert username: %v", err)
+// addressUsable returns true if address, an email or phone (determined by
+// loginType) belonging to usr, can be used to identify usr i.e. it is usr's
+// primary address or it has been verified.
+func addressUsable(usr User, loginType, address string) bool {
+	var primary VerifLogin
+	var all []VerifLogin
+	switch loginType {
+	case LoginTypeEmail:
+		primary, all = usr.Email, usr.Emails
+	case LoginTypePhone:
+		primary, all = usr.Phone, usr.Phones
+	default:
+		return false
 	}
-	usr.UserName = *uname
-	return nil
-}
-
-func (a *Authentication) regDevConditions(devID string) (string, error) {
-	_, _, err := a.db.UserByDeviceID(devID)
-	return devID, a.usrIdentifierAvail(LoginTypeDev, devID, err)
-}
-
-func (a *Authentication) regDevice(tx *sql.Tx, actionType, devID string, usr *User) error {
-	dev, err := a.db.InsertUserDeviceAtomic(tx, usr.ID, devID)
-	if err != nil {
-		return errors.Newf("insert device: %v", err)
+	if primary.Address == address {
+		return true
 	}
-	usr.Devices = []Device{*dev}
-	return nil
-}
-
-func (a *Authentication) regPhoneConditions(number string) (string, error) {
-	number, err := formatValidPhone(number)
-	if err != nil {
-		return "", errors.NewClient(err)
+	for _, vl := range all {
+		if vl.Address == address {
+			return vl.Verified
+		}
 	}
-	_, _, err = a.db.UserByPhone(number)
-	return number, a.usrIdentifierAvail(LoginTypePhone, number, err)
+	return false
 }
 
-func (a *Authentication) regPhone(tx *sql.Tx, actionType, number string, usr *User) error {
-	phone, err := a.db.InsertUserPhoneAtomic(tx, usr.ID, number, false)
-	if err != nil {
-		return errors.Newf("insert phone: %v", err)
+// loginMethods returns the number of identifiers usr can log in with for
+// each loginType.
+func (a *Authentication) loginMethods(usr User) (map[string]int, error) {
+	lis, err := a.db.LinkedIdentitiesByUserID(usr.ID)
+	if err != nil && !a.db.IsNotFoundError(err) {
+		return nil, errors.Newf("get linked identities: %v", err)
 	}
-	usr.Phone = *phone
-	if a.smserNilable == nil {
-		return nil
+	methods := map[string]int{LoginTypeOIDC: len(lis)}
+	if usr.UserName.HasValue() {
+		methods[LoginTypeUsername] = 1
 	}
-	otpSt, err := a.genAndSendTokens(tx, actionType, LoginTypePhone, number, usr.ID)
-	if err != nil {
-		return err
+	if usr.Email.HasValue() {
+		methods[LoginTypeEmail] = 1
 	}
-	usr.Phone.OTPStatus = *otpSt
-	return nil
+	if usr.Phone.HasValue() {
+		methods[LoginTypePhone] = 1
+	}
+	if usr.Facebook.HasValue() {
+		methods[LoginTypeFacebook] = 1
+	}
+	return methods, nil
 }
 
-func (a *Authentication) regEmailConditions(email string) (string, error) {
-	email, err := normalizeValidEmail(email, a.verifyEmailHost)
+// userByOIDC fetches the user linked to the subject of idToken, validating
+// idToken against nonce. Errors are as with user(); failure to reach the
+// token's issuer is returned as a general error.
+func (a *Authentication) userByOIDC(idToken, nonce string) (*User, error) {
+	iss, sub, err := a.validateOIDCToken(idToken, nonce)
 	if err != nil {
-		return "", errors.NewClient(err)
+		if a.IsAuthError(err) {
+			return nil, errors.NewForbidden("invalid id_token")
+		}
+		return nil, err
 	}
-	_, _, err = a.db.UserByEmail(email)
-	return email, a.usrIdentifierAvail(LoginTypeEmail, email, err)
-}
-
-func (a *Authentication) regEmail(tx *sql.Tx, actionType, address string, usr *User) error {
-	email, err := a.db.InsertUserEmailAtomic(tx, usr.ID, address, false)
+	usr, err := a.db.UserByLinkedIdentity(iss, sub)
 	if err != nil {
-		return errors.Newf("insert email: %v", err)
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewNotFound("user does not exist")
+		}
+		return nil, err
 	}
-	usr.Email = *email
-	if a.mailerNilable == nil {
-		return nil
+	return usr, nil
+}
+
+// saveHistory records an access attempt on usrID's account using tx if non-nil.
+func (a *Authentication) saveHistory(tx *sql.Tx, ci ClientInfo, usrID, accessType, loginType string, successful bool) error {
+	var err error
+	if tx == nil {
+		_, err = a.db.InsertHistory(usrID, accessType, loginType, ci.IPAddress, ci.UserAgent, successful)
+	} else {
+		_, err = a.db.InsertHistoryAtomic(tx, usrID, accessType, loginType, ci.IPAddress, ci.UserAgent, successful)
 	}
-	otpSt, err := a.genAndSendTokens(tx, actionType, LoginTypeEmail, address, usr.ID)
 	if err != nil {
-		return err
+		return errors.Newf("save %s history: %v", accessType, err)
 	}
-	usr.Email.OTPStatus = *otpSt
 	return nil
 }
 
-func (a *Authentication) regFacebookConditions(fbToken string) (string, error) {
-	if fbToken == "" {
-		return "", errors.NewClient("facebook token cannot be empty")
-	}
-	fbID, err := a.validateFbToken(fbToken)
+func (a *Authentication) mfaEnabled(usrID string) (bool, error) {
+	ts, err := a.db.TOTPSecret(usrID)
 	if err != nil {
-		return "", err
+		if a.db.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, errors.Newf("get TOTP secret: %v", err)
 	}
-	_, err = a.db.UserByFacebook(fbID)
-	return fbID, a.usrIdentifierAvail(LoginTypeFacebook, fbID, err)
+	return ts.IsConfirmed, nil
 }
 
-func (a *Authentication) regFacebook(tx *sql.Tx, actionType, fbID string, usr *User) error {
-	fb, err := a.db.InsertUserFbIDAtomic(tx, usr.ID, fbID, true)
+// totpSecret fetches the TOTP secret for usrID together with its decrypted
+// value.
+func (a *Authentication) totpSecret(usrID string) (*TOTPSecret, []byte, error) {
+	if a.mfaEncNilable == nil {
+		return nil, nil, errorMFANotAvail
+	}
+	ts, err := a.db.TOTPSecret(usrID)
 	if err != nil {
-		return errors.Newf("insert facebook: %v", err)
+		if a.db.IsNotFoundError(err) {
+			return nil, nil, errors.NewForbidden("two-factor authentication not enabled")
+		}
+		return nil, nil, errors.Newf("get TOTP secret: %v", err)
 	}
-	usr.Facebook = *fb
-	return nil
+	secret, err := a.mfaEncNilable.Decrypt(ts.Secret)
+	if err != nil {
+		return nil, nil, errors.Newf("decrypt TOTP secret: %v", err)
+	}
+	return ts, secret, nil
 }
 
-func (a *Authentication) updateUsername(usrID, newUsrName string) (*Username, error) {
-	// TODO
-	//_, _, err := a.db.UserByUsername(newUsrName)
-	//if err = a.usrIdentifierAvail(LoginTypeUsername, err); err != nil {
-	//	return nil, err
-	//}
-	//uname, err := a.db.UpdateUserName(clm.UsrID, newUsrName)
-	//if err != nil {
-	//	return nil, errors.Newf("user by phone: %v", err)
-	//}
-	return nil, errors.NewNotImplemented()
+func (a *Authentication) lockoutEnabled(loginType string) bool {
+	return a.lockoutFailCount > 0 && !isFederated(loginType)
 }
 
-func (a *Authentication) updatePhone(usrID string, old VerifLogin, newNum string) (*VerifLogin, error) {
-
-	newNum, err := a.regPhoneConditions(newNum)
-	if err != nil {
-		return nil, err
-	}
-
-	var phone *VerifLogin
-	if old.HasValue() {
-		err = a.db.ExecuteTx(func(tx *sql.Tx) error {
-			// TODO archive instead
-			err := a.db.DeletePhoneTokensAtomic(tx, old.Address)
+// beginLoginAttempt records a login attempt for identifier of loginType from
+// ipAddress as a login failure unless either is locked out, in which case it
+// returns a ForbiddenError. The lockouts are checked and the failure recorded
+// in one transaction so that concurrent attempts cannot exceed the fail
+// counts. The returned failure (nil if lockouts are disabled) is cleared by
+// clearLoginFailures() if the attempt succeeds or abandonLoginAttempt() if
+// it fails for reasons other than bad credentials.
+func (a *Authentication) beginLoginAttempt(loginType, identifier, ipAddress string) (*LoginFailure, error) {
+	if !a.lockoutEnabled(loginType) {
+		return nil, nil
+	}
+	since := time.Now().Add(-a.lockoutWindow)
+	var lf *LoginFailure
+	err := a.db.ExecuteTx(func(tx *sql.Tx) error {
+		lfs, err := a.db.LoginFailuresAtomic(tx, loginType, identifier, since, 0, int64(a.lockoutFailCount))
+		if err != nil && !a.db.IsNotFoundError(err) {
+			return errors.Newf("get login lockout: %v", err)
+		}
+		lo := &Lockout{LoginType: loginType, Identifier: identifier}
+		a.fillLockout(lo, lfs, a.lockoutFailCount)
+		if lo.IsLocked {
+			return errors.NewForbiddenf("too many failed login attempts, try again after %s",
+				lo.LockedUntil.Format(time.RFC3339))
+		}
+		if ipAddress != "" && a.ipLockoutFailCount > 0 {
+			lfs, err = a.db.LoginFailuresByIPAtomic(tx, ipAddress, since, int64(a.ipLockoutFailCount))
 			if err != nil && !a.db.IsNotFoundError(err) {
-				return errors.Newf("delete prev phone's tokens: %v", err)
+				return errors.Newf("get IP lockout: %v", err)
 			}
-			phone, err = a.db.UpdateUserPhoneAtomic(tx, usrID, newNum, false)
-			if err != nil {
-				return errors.Newf("update phone: %v", err)
+			lo = &Lockout{IPAddress: ipAddress}
+			a.fillLockout(lo, lfs, a.ipLockoutFailCount)
+			if lo.IsLocked {
+				return errors.NewForbiddenf("too many failed login attempts from this location, try again after %s",
+					lo.LockedUntil.Format(time.RFC3339))
 			}
-			return nil
-		})
-		if err != nil {
-			return nil, err
 		}
-	} else {
-		phone, err = a.db.InsertUserPhone(usrID, newNum, false)
+		lf, err = a.db.InsertLoginFailureAtomic(tx, loginType, identifier, ipAddress)
 		if err != nil {
-			return nil, errors.Newf("insert phone: %v", err)
+			return errors.Newf("record login failure: %v", err)
 		}
-	}
-
-	if a.smserNilable == nil {
-		return phone, nil
-	}
-	otpSt, err := a.genAndSendTokens(nil, ActionVerify, LoginTypePhone, newNum, usrID)
+		return nil
+	})
 	if err != nil {
-		return phone, err
+		return nil, err
 	}
-	phone.OTPStatus = *otpSt
-	return phone, nil
+	return lf, nil
 }
 
-func (a *Authentication) updateEmail(usrID string, old VerifLogin, newAddr string) (*VerifLogin, error) {
-
-	newAddr, err := a.regEmailConditions(newAddr)
-	if err != nil {
-		return nil, err
+// abandonLoginAttempt removes lf, the login failure recorded by
+// beginLoginAttempt(), for an attempt that failed with cause for reasons
+// other than bad credentials. It returns cause if successful.
+func (a *Authentication) abandonLoginAttempt(lf *LoginFailure, cause error) error {
+	if lf == nil {
+		return cause
+	}
+	if err := a.db.DeleteLoginFailure(lf.ID); err != nil && !a.db.IsNotFoundError(err) {
+		return errors.Newf("%v (remove login failure: %v)", cause, err)
 	}
+	return cause
+}
 
-	var email *VerifLogin
-	if old.HasValue() {
-		err = a.db.ExecuteTx(func(tx *sql.Tx) error {
-			// TODO archive instead
-			err := a.db.DeleteEmailTokensAtomic(tx, old.Address)
-			if err != nil && !a.db.IsNotFoundError(err) {
-				return errors.Newf("delete prev email's tokens: %v", err)
-			}
-			email, err = a.db.UpdateUserEmailAtomic(tx, usrID, newAddr, false)
-			if err != nil {
-				return errors.Newf("update email: %v", err)
-			}
-			return nil
-		})
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		email, err = a.db.InsertUserEmail(usrID, newAddr, false)
-		if err != nil {
-			return nil, errors.Newf("insert email: %v", err)
-		}
+// attemptFailed records a failed access attempt on usrID's account in its
+// history. The login failure itself was recorded by beginLoginAttempt().
+// It returns cause if successful.
+func (a *Authentication) attemptFailed(ci ClientInfo, usrID, accessType, loginType string, cause error) error {
+	if err := a.saveHistory(nil, ci, usrID, accessType, loginType, false); err != nil {
+		return err
 	}
+	return cause
+}
 
-	if a.mailerNilable == nil {
-		return email, nil
+func (a *Authentication) clearLoginFailures(loginType, identifier string) error {
+	if !a.lockoutEnabled(loginType) {
+		return nil
 	}
-	otpSt, err := a.genAndSendTokens(nil, ActionVerify, LoginTypeEmail, newAddr, usrID)
-	if err != nil {
-		return email, err
+	if err := a.db.DeleteLoginFailures(loginType, identifier); err != nil {
+		return errors.Newf("clear login failures: %v", err)
 	}
-	email.OTPStatus = *otpSt
-	return email, nil
+	return nil
 }
 
-func (a *Authentication) genAndInsertToken(tx *sql.Tx, expiry time.Time, loginType, forUsrID, loginID string) ([]byte, error) {
-	dbt, err := a.urlTokenGen.SecureRandomBytes(56)
-	if err != nil {
-		return nil, errors.Newf("generate %s verification db token", loginType)
+func (a *Authentication) loginLockout(loginType, identifier string) (*Lockout, error) {
+	lo := &Lockout{LoginType: loginType, Identifier: identifier}
+	if !a.lockoutEnabled(loginType) {
+		return lo, nil
 	}
-	if err := a.hashAndInsertToken(tx, expiry, loginType, forUsrID, loginID, dbt); err != nil {
-		return nil, errors.Newf("%s verification db token: %v", loginType, err)
+	since := time.Now().Add(-a.lockoutWindow)
+	lfs, err := a.db.LoginFailures(loginType, identifier, since, 0, int64(a.lockoutFailCount))
+	if err != nil && !a.db.IsNotFoundError(err) {
+		return nil, err
 	}
-	return dbt, nil
+	a.fillLockout(lo, lfs, a.lockoutFailCount)
+	return lo, nil
 }
 
-func (a *Authentication) genAndInsertCode(tx *sql.Tx, expiry time.Time, loginType, forUsrID, loginID string) ([]byte, error) {
-	code, err := a.numGen.SecureRandomBytes(6)
-	if err != nil {
-		return nil, errors.Newf("generate %s verification code", loginType)
+func (a *Authentication) ipLockout(ipAddress string) (*Lockout, error) {
+	lo := &Lockout{IPAddress: ipAddress}
+	if a.lockoutFailCount <= 0 || a.ipLockoutFailCount <= 0 || ipAddress == "" {
+		return lo, nil
 	}
-	if err := a.hashAndInsertToken(tx, expiry, loginType, forUsrID, loginID, code); err != nil {
-		return nil, errors.Newf("%s verification code: %v", loginType, err)
+	since := time.Now().Add(-a.lockoutWindow)
+	lfs, err := a.db.LoginFailuresByIP(ipAddress, since, int64(a.ipLockoutFailCount))
+	if err != nil && !a.db.IsNotFoundError(err) {
+		return nil, err
 	}
-	return code, nil
+	a.fillLockout(lo, lfs, a.ipLockoutFailCount)
+	return lo, nil
 }
 
-func (a *Authentication) hashAndInsertToken(tx *sql.Tx, expiry time.Time, loginType, forUsrID, loginID string, code []byte) error {
-	codeH, err := hash(code)
-	if err != nil {
-		return errors.Newf("hash for storage: %v", err)
+// fillLockout sets the lockout state of lo given lfs, the most recent
+// failed login attempts within the lockout window starting with the newest,
+// and failCount, the number of failed attempts that result in a lockout.
+func (a *Authentication) fillLockout(lo *Lockout, lfs []LoginFailure, failCount int) {
+	lo.FailCount = len(lfs)
+	if lo.FailCount < failCount {
+		return
 	}
+	lo.IsLocked = true
+	lo.LockedUntil = lfs[failCount-1].CreateDate.Add(a.lockoutWindow)
+}
 
-	var insFuncAtomic func(tx *sql.Tx, userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*DBToken, error)
-	var insFunc func(userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*DBToken, error)
-
-	switch loginType {
-	case LoginTypePhone:
-		insFunc = a.db.InsertPhoneToken
-		insFuncAtomic = a.db.InsertPhoneTokenAtomic
-	case LoginTypeEmail:
-		insFunc = a.db.InsertEmailToken
-		insFuncAtomic = a.db.InsertEmailTokenAtomic
-	default:
-		return errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
+func (a *Authentication) validateFbToken(fbToken string) (string, error) {
+	if a.fbNilable == nil {
+		return "", errorFbNotAvail
+	}
+	fbUsrID, err := a.fbNilable.ValidateToken(fbToken)
+	if err != nil {
+		if a.fbNilable.IsAuthError(err) {
+			return "", errors.NewAuthf("facebook: %v", err)
+		}
+		return "", errors.Newf("validate facebook token: %v", err)
 	}
+	return fbUsrID, nil
+}
 
-	if tx == nil {
-		_, err = insFunc(forUsrID, loginID, codeH, false, expiry)
-	} else {
-		_, err = insFuncAtomic(tx, forUsrID, loginID, codeH, false, expiry)
+func (a *Authentication) validateOIDCToken(idToken, nonce string) (string, string, error) {
+	if a.oidcNilable == nil {
+		return "", "", errorOIDCNotAvail
 	}
+	iss, sub, err := a.oidcNilable.ValidateIDToken(idToken, nonce)
 	if err != nil {
-		return errors.Newf("insert: %v", err)
+		if a.oidcNilable.IsAuthError(err) {
+			return "", "", errors.NewAuthf("OpenID Connect: %v", err)
+		}
+		return "", "", errors.Newf("validate id_token: %v", err)
 	}
-
-	return nil
+	return iss, sub, nil
 }
 
-func (a *Authentication) sendEmail(toAddr, subj string, t *template.Template, data interface{}) error {
-	if a.mailerNilable == nil {
-		return errors.New("Mailer was nil")
+// validateJWT validates JWT returning its claims. A JWT revoked through
+// Revoke() or issued for another tenant (without PermTenantsAll) is
+// rejected.
+func (a *Authentication) validateJWT(JWT string) (*JWTClaim, error) {
+	clms := new(JWTClaim)
+	if _, err := a.jwter.Validate(JWT, clms); err != nil {
+		return nil, err
 	}
-	emailBf := bytes.NewBuffer(make([]byte, 0, 256))
-	err := t.Execute(emailBf, data)
+	if clms.TenantID != a.tenantID && !clms.HasPermission(PermTenantsAll) {
+		return nil, errors.NewForbidden("token was issued for another tenant")
+	}
+	// the user belongs to the tenant the JWT was issued for.
+	usrDB := a.db
+	if clms.TenantID != a.tenantID {
+		usrDB = a.db.ForTenant(clms.TenantID)
+	}
+	status, err := usrDB.UserStatus(clms.UsrID)
 	if err != nil {
-		return errors.Newf("email body from template: %v", err)
+		if a.db.IsNotFoundError(err) {
+			return nil, errors.NewForbidden("user no longer exists")
+		}
+		return nil, errors.Newf("get user status: %v", err)
 	}
-	err = a.mailerNilable.SendEmail(SendMail{
-		ToEmails: []string{toAddr},
-		Subject:  subj,
-		Body:     template.HTML(emailBf.String()),
-	})
+	if err := checkNotSuspended(*status); err != nil {
+		return nil, err
+	}
+	// IssuedAt is in whole seconds so tokens issued within the second of
+	// logging out everywhere are rejected too.
+	if !status.LoggedOutAll.IsZero() && clms.IssuedAt <= status.LoggedOutAll.Unix() {
+		return nil, errors.NewForbidden("token has been revoked")
+	}
+	// JWTs issued before revocation was supported carry no ID.
+	if clms.Id == "" {
+		return clms, nil
+	}
+	revoked, err := a.db.IsTokenRevoked(clms.Id)
 	if err != nil {
-		return errors.Newf("send email: %v", err)
+		return nil, errors.Newf("check JWT revoked: %v", err)
 	}
-	return nil
+	if revoked {
+		return nil, errors.NewForbidden("token has been revoked")
+	}
+	if err := a.checkSession(clms); err != nil {
+		return nil, err
+	}
+	return clms, nil
 }
 
-func (a *Authentication) sendSMS(toPhone string, t *template.Template, data interface{}) error {
-	if a.smserNilable == nil {
-		return errors.New("SMSer was nil")
+// checkNotSuspended returns a Forbidden error if status is currently
+// StatusSuspended.
+func checkNotSuspended(status UserStatus) error {
+	if status.ValueAt(time.Now()) != StatusSuspended {
+		return nil
 	}
-	SMSBf := bytes.NewBuffer(make([]byte, 0, 256))
-	err := t.Execute(SMSBf, data)
-	if err != nil {
-		return errors.Newf("SMS from template: %v", err)
+	msg := "account suspended"
+	if !status.Until.IsZero() {
+		msg += " until " + status.Until.Format(config.TimeFormat)
 	}
-	if err := a.smserNilable.SMS(toPhone, SMSBf.String()); err != nil {
-		return errors.Newf("send SMS: %v", err)
+	if status.Reason != "" {
+		msg += ": " + status.Reason
 	}
-	return nil
+	return errors.NewForbidden(msg)
 }
 
-func (a *Authentication) dbTokenValid(userID string, checkDBT []byte,
-	f func(userID string, offset, count int64) ([]DBToken, error)) (*DBToken, error) {
-
-	if len(checkDBT) == 0 {
-		return nil, errors.NewUnauthorized("confirmation token cannot be empty")
-	}
-	if userID == "" {
-		return nil, errors.NewUnauthorized("userID cannot be empty")
+// checkSession rejects clms if the session they were issued under has
+// been revoked, otherwise it updates the session's last seen time.
+// JWTs issued outside a login (e.g. during self registration) carry no
+// session and are not checked.
+func (a *Authentication) checkSession(clms *JWTClaim) error {
+	if clms.SessionID == "" {
+		return nil
 	}
-	offset := int64(0)
-	count := int64(100)
-	var dbt DBToken
-resumeFunc:
-	for {
-		codes, err := f(userID, offset, count)
+	sess, err := a.db.Session(clms.SessionID)
+	if err != nil {
 		if a.db.IsNotFoundError(err) {
-			return nil, errors.NewUnauthorized("token is invalid")
-		}
-		if err != nil {
-			return nil, errors.Newf("get phone db tokens: %v", err)
-		}
-		offset = offset + count
-		for _, dbt = range codes {
-			err = bcrypt.CompareHashAndPassword(dbt.Token, checkDBT)
-			if err != nil {
-				continue
-			}
-			break resumeFunc
+			return errors.NewForbidden("session not found")
 		}
+		return errors.Newf("get session: %v", err)
 	}
-	if dbt.IsUsed {
-		return nil, errors.NewForbiddenf("token already used")
+	if sess.IsRevoked || sess.UserID != clms.UsrID {
+		return errors.NewForbidden("session has been logged out")
 	}
-	if time.Now().After(dbt.ExpiryDate) {
-		return nil, errors.NewAuth("token has expired")
+	now := time.Now()
+	if now.Sub(sess.LastSeen) < sessionSeenInterval {
+		return nil
 	}
-	return &dbt, nil
+	if err := a.db.SetSessionLastSeen(sess.ID, now); err != nil {
+		return errors.Newf("set session last seen: %v", err)
+	}
+	return nil
 }
 
-func (a *Authentication) getOrCreateGroup(groupName string, acl float32) (*Group, error) {
-	grp, err := a.db.GroupByName(groupName)
-	if err != nil {
-		if !a.db.IsNotFoundError(err) {
-			return nil, errors.Newf("get group by name: %v", err)
-		}
-		grp, err = a.db.InsertGroup(groupName, acl)
+// exportPages calls fetch with increasing offsets until a page comes back
+// short or not found. name describes what is being fetched for errors.
+func (a *Authentication) exportPages(name string, fetch func(offset int64) (int, error)) error {
+	for offset := int64(0); ; offset += exportPageSize {
+		n, err := fetch(offset)
 		if err != nil {
-			return nil, errors.Newf("insert group: %v", err)
+			if a.db.IsNotFoundError(err) {
+				return nil
+			}
+			return errors.Newf("fetch %s: %v", name, err)
+		}
+		if int64(n) < exportPageSize {
+			return nil
 		}
 	}
-	return grp, nil
 }
 
-func (a *Authentication) getOrCreateUserType(name string) (*UserType, error) {
-	ut, err := a.db.UserTypeByName(name)
+func (a *Authentication) jwtBelongsToOrHasPermission(JWT, userID, perm string, acl float32) error {
+	clms, err := a.validateJWT(JWT)
 	if err != nil {
-		if !a.db.IsNotFoundError(err) {
-			return nil, errors.Newf("get user type by name: %v", err)
-		}
-		ut, err = a.db.InsertUserType(name)
-		if err != nil {
-			return nil, errors.Newf("insert user type: %v", err)
-		}
+		return err
 	}
-	return ut, nil
+	if clms.UsrID == userID {
+		return nil
+	}
+	return claimsHavePermission(*clms, perm, acl)
 }
 
-// user fetches the user and password hash (if applicable) for the provided parameters.
-// The passH will be non-nil empty if not applicable and successful.
-// It returns a ClientError if the identifier is badly formatted, a NotFoundError if
-// none was found, a ForbiddenError if the loginType was was facebook and the token
-// was invalid or a general error if something went wrong during fetch.
-func (a *Authentication) user(loginType, identifier string) (usr *User, passH []byte, err error) {
-	switch loginType {
-	case LoginTypePhone:
-		identifier, err = formatValidPhone(identifier)
-		if err != nil {
-			return nil, nil, errors.NewClient(err)
-		}
-		usr, passH, err = a.db.UserByPhone(identifier)
-	case LoginTypeEmail:
-		identifier, err = normalizeValidEmail(identifier, a.verifyEmailHost)
-		if err != nil {
-			return nil, nil, errors.NewClient(err)
-		}
-		usr, passH, err = a.db.UserByEmail(identifier)
-	case LoginTypeUsername:
-		identifier, err = normalizeValidUsername(identifier)
-		if err != nil {
-			return nil, nil, errors.NewClient(err)
-		}
-		usr, passH, err = a.db.UserByUsername(identifier)
-	case LoginTypeFacebook:
-		identifier, err = a.validateFbToken(identifier)
-		if err != nil {
-			return nil, nil, errors.NewForbidden("invalid facebook token")
-		}
-		usr, err = a.db.UserByFacebook(identifier)
-		passH = make([]byte, 0)
-	default:
-		return nil, nil, errors.NewClientf(loginTypeNotSupportedErrorF, loginType)
-	}
+// jwtHasTenantsAll returns a Forbidden error unless JWT's group has been
+// granted PermTenantsAll. There is no access level fallback.
+func (a *Authentication) jwtHasTenantsAll(JWT string) error {
+	clms, err := a.validateJWT(JWT)
 	if err != nil {
+		return err
+	}
+	if !clms.HasPermission(PermTenantsAll) {
+		return errorInsufPriv
+	}
+	return nil
+}
+
+func (a *Authentication) attributeSchema() (*AttributeSchema, error) {
+	as := new(AttributeSchema)
+	if err := a.db.GetAttributeSchema(as); err != nil {
 		if a.db.IsNotFoundError(err) {
-			return nil, nil, errors.NewNotFound("user does not exist")
+			return nil, errors.NewNotFound("attribute schema not configured")
 		}
+		return nil, errors.Newf("get attribute schema: %v", err)
 	}
-	return
+	return as, nil
 }
 
-func (a *Authentication) validateFbToken(fbToken string) (string, error) {
-	if a.fbNilable == nil {
-		return "", errorFbNotAvail
+// claimAttributes picks the custom attributes of usr to be included in
+// its JWT as configured in the AttributeSchema.
+func (a *Authentication) claimAttributes(usr User) (map[string]interface{}, error) {
+	if len(usr.Attributes) == 0 {
+		return nil, nil
 	}
-	fbUsrID, err := a.fbNilable.ValidateToken(fbToken)
+	as, err := a.attributeSchema()
 	if err != nil {
-		if a.fbNilable.IsAuthError(err) {
-			return "", errors.NewAuthf("facebook: %v", err)
+		if a.IsNotFoundError(err) {
+			return nil, nil
 		}
-		return "", errors.Newf("validate facebook token: %v", err)
+		return nil, err
 	}
-	return fbUsrID, nil
+	var attrs map[string]interface{}
+	for _, name := range as.ClaimAttributes {
+		val, ok := usr.Attributes[name]
+		if !ok {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]interface{})
+		}
+		attrs[name] = val
+	}
+	return attrs, nil
 }
 
-func (a *Authentication) jwtBelongsToOrHasAccess(JWT, userID string, acl float32) error {
-	clms := new(JWTClaim)
-	if _, err := a.jwter.Validate(JWT, clms); err != nil {
-		return err
-	}
-	if clms.UsrID == userID {
-		return nil
+// userInTenant returns a NotFound error if userID has no account in a's
+// tenant. It guards store methods that look up a user's records by
+// userID alone.
+func (a *Authentication) userInTenant(userID string) error {
+	if _, _, err := a.db.User(userID); err != nil {
+		if a.db.IsNotFoundError(err) {
+			return errors.NewNotFound("user not found")
+		}
+		return errors.Newf("get user: %v", err)
 	}
-	return claimsHaveAccess(*clms, acl)
+	return nil
 }
 
-func (a *Authentication) jwtHasAccess(JWT string, acl float32) error {
-	clms := new(JWTClaim)
-	if _, err := a.jwter.Validate(JWT, clms); err != nil {
+func (a *Authentication) jwtHasPermission(JWT, perm string, acl float32) error {
+	clms, err := a.validateJWT(JWT)
+	if err != nil {
 		return err
 	}
-	return claimsHaveAccess(*clms, acl)
+	return claimsHavePermission(*clms, perm, acl)
 }
 
 func unpackOffsetCount(offsetStr, countStr string) (int64, int64, error) {
@@ -1574,6 +4691,15 @@ func unpackCount(countStr string) (int64, error) {
 	return count, nil
 }
 
+// claimsHavePermission returns nil if clms' group has been granted perm,
+// falling back to claimsHaveAccess(clms, acl) otherwise.
+func claimsHavePermission(clms JWTClaim, perm string, acl float32) error {
+	if clms.HasPermission(perm) {
+		return nil
+	}
+	return claimsHaveAccess(clms, acl)
+}
+
 func claimsHaveAccess(clms JWTClaim, acl float32) error {
 	if clms.Group.AccessLevel > acl {
 		return errorInsufPriv
@@ -1581,11 +4707,150 @@ func claimsHaveAccess(clms JWTClaim, acl float32) error {
 	return nil
 }
 
-func passwordValid(hashed, password []byte) error {
-	if err := bcrypt.CompareHashAndPassword(hashed, password); err != nil {
-		return errors.NewForbiddenf("invalid username/password combination")
+// lockoutIdentifiers returns the identifiers, by loginType, that failed
+// attempts to log in to usr's account are recorded against (see
+// lockoutIdentifier()).
+func lockoutIdentifiers(usr User) map[string][]string {
+	ids := map[string][]string{LoginTypeMFA: {usr.ID}}
+	add := func(loginType, identifier string) {
+		if identifier == "" {
+			return
+		}
+		identifier = lockoutIdentifier(loginType, identifier)
+		for _, id := range ids[loginType] {
+			if id == identifier {
+				return
+			}
+		}
+		ids[loginType] = append(ids[loginType], identifier)
 	}
-	return nil
+	add(LoginTypeUsername, usr.UserName.Value)
+	add(LoginTypeEmail, usr.Email.Address)
+	add(LoginTypePhone, usr.Phone.Address)
+	for _, vl := range usr.Emails {
+		add(LoginTypeEmail, vl.Address)
+	}
+	for _, vl := range usr.Phones {
+		add(LoginTypePhone, vl.Address)
+	}
+	return ids
+}
+
+// lockoutIdentifier returns a normalized form of identifier that is used to
+// track failed login attempts so that trivial variations of the identifier
+// are not tracked separately.
+// passwordLockoutIdentifier returns the login type and lockout identifier
+// that failed password re-confirmations by usr count against: the first of
+// usr's username, email and phone, any of which logs in with the password.
+func passwordLockoutIdentifier(usr User) (string, string) {
+	switch {
+	case usr.UserName.Value != "":
+		return LoginTypeUsername, lockoutIdentifier(LoginTypeUsername, usr.UserName.Value)
+	case usr.Email.Address != "":
+		return LoginTypeEmail, lockoutIdentifier(LoginTypeEmail, usr.Email.Address)
+	default:
+		return LoginTypePhone, lockoutIdentifier(LoginTypePhone, usr.Phone.Address)
+	}
+}
+
+func lockoutIdentifier(loginType, identifier string) string {
+	var err error
+	normID := identifier
+	switch loginType {
+	case LoginTypePhone:
+		normID, err = formatValidPhone(identifier)
+	case LoginTypeUsername:
+		normID, err = normalizeValidUsername(identifier)
+	case LoginTypeEmail:
+		normID = strings.ToLower(identifier)
+	}
+	if err != nil {
+		return identifier
+	}
+	return normID
+}
+
+// totpAccountName returns the label identifying usr's account in
+// authenticator apps.
+func totpAccountName(usr User) string {
+	switch {
+	case usr.UserName.HasValue():
+		return usr.UserName.Value
+	case usr.Email.HasValue():
+		return usr.Email.Address
+	case usr.Phone.HasValue():
+		return usr.Phone.Address
+	default:
+		return usr.ID
+	}
+}
+
+// isFederated returns true if loginType's identity is asserted by a third
+// party e.g. facebook, rather than by a password.
+func isFederated(loginType string) bool {
+	return loginType == LoginTypeFacebook || loginType == LoginTypeOIDC
+}
+
+// oidcIdentity returns the identifier for subject at OpenID Connect provider
+// issuer. Issuer identifiers have no fragment component so '#' is safe
+// to use as a separator.
+func oidcIdentity(issuer, subject string) string {
+	return issuer + "#" + subject
+}
+
+func splitOIDCIdentity(identity string) (issuer, subject string) {
+	i := strings.Index(identity, "#")
+	if i < 0 {
+		return identity, ""
+	}
+	return identity[:i], identity[i+1:]
+}
+
+// passwordValid returns a ForbiddenError if password does not match hashed.
+// needsRehash is true if hashed was produced with outdated parameters.
+func (a *Authentication) passwordValid(hashed, password []byte) (bool, error) {
+	needsRehash, err := a.passHasher.Verify(hashed, password)
+	if err != nil {
+		if a.passHasher.IsAuthError(err) {
+			return false, errors.NewForbiddenf("invalid username/password combination")
+		}
+		return false, errors.Newf("verify password: %v", err)
+	}
+	return needsRehash, nil
+}
+
+func (a *Authentication) hashPassword(password []byte) ([]byte, error) {
+	passH, err := a.passHasher.Hash(password)
+	if err != nil {
+		return nil, errors.Newf("hash password: %v", err)
+	}
+	return passH, nil
+}
+
+// rehashPassword replaces the user's password hash with one produced using
+// the current PassHasher parameters.
+func (a *Authentication) rehashPassword(userID string, password []byte) error {
+	passH, err := a.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return a.db.ExecuteTx(func(tx *sql.Tx) error {
+		if err := a.db.UpdatePasswordAtomic(tx, userID, passH); err != nil {
+			return errors.Newf("update rehashed password: %v", err)
+		}
+		return nil
+	})
+}
+
+// passMatches returns true if password matches hashed.
+func (a *Authentication) passMatches(hashed, password []byte) (bool, error) {
+	if _, err := a.passHasher.Verify(hashed, password); err != nil {
+		if a.passHasher.IsAuthError(err) {
+			return false, nil
+		}
+		return false, errors.Newf("verify password: %v", err)
+	}
+	return true, nil
 }
 
 func hash(password []byte) ([]byte, error) {
@@ -1596,11 +4861,74 @@ func hash(password []byte) ([]byte, error) {
 	return passH, nil
 }
 
-func hashIfValid(password []byte) ([]byte, error) {
-	if len(password) < minPassLen {
-		return nil, errors.NewClientf("password must be at least %d characters", minPassLen)
+// passPolicyValid returns a client error carrying a PasswordPolicyError if
+// password does not satisfy the PasswordPolicy. userID and currPassH are
+// used to check for reuse and are empty for users yet to be registered.
+// identifiers are the username and email address of the user.
+func (a *Authentication) passPolicyValid(password []byte, userID string, currPassH []byte, identifiers ...string) error {
+	vs := a.passPolicy.violations(password, identifiers...)
+	reused, err := a.passReused(password, userID, currPassH)
+	if err != nil {
+		return err
+	}
+	if reused {
+		vs = append(vs, PasswordRuleViolation{
+			Rule:    PassRuleNotReused,
+			Message: fmt.Sprintf("must not be one of your last %d passwords", a.passPolicy.HistoryCount),
+		})
+	}
+	pwned, err := a.passPwned(password)
+	if err != nil {
+		return errors.Newf("check password breached: %v", err)
+	}
+	if pwned {
+		vs = append(vs, PasswordRuleViolation{
+			Rule:    PassRuleNotBreached,
+			Message: "has appeared in a data breach",
+		})
+	}
+	if len(vs) > 0 {
+		return errors.NewClient(PasswordPolicyError{Violations: vs})
+	}
+	return nil
+}
+
+// passPwned returns true if password has been seen in data breaches at least
+// as many times as the threshold set in WithPwnedPassCheck().
+func (a *Authentication) passPwned(password []byte) (bool, error) {
+	if a.pwnedNilable == nil {
+		return false, nil
+	}
+	count, err := a.pwnedNilable.Count(password)
+	if err != nil {
+		return false, err
+	}
+	return count >= a.pwnedThreshold, nil
+}
+
+func (a *Authentication) passReused(password []byte, userID string, currPassH []byte) (bool, error) {
+	if a.passPolicy.HistoryCount == 0 || userID == "" {
+		return false, nil
+	}
+	if reused, err := a.passMatches(currPassH, password); err != nil || reused {
+		return reused, err
+	}
+	if a.passPolicy.HistoryCount == 1 {
+		return false, nil
+	}
+	passHs, err := a.db.PassHistory(userID, a.passPolicy.HistoryCount-1)
+	if err != nil {
+		if a.db.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, errors.Newf("get password history: %v", err)
+	}
+	for _, passH := range passHs {
+		if reused, err := a.passMatches(passH, password); err != nil || reused {
+			return reused, err
+		}
 	}
-	return hash(password)
+	return false, nil
 }
 
 func inStrs(needle string, haystack []string) bool {
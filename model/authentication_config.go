@@ -4,9 +4,12 @@ import (
 	"errors"
 	"html/template"
 	"reflect"
+	"time"
 
 	"github.com/tomogoma/authms/config"
 	"github.com/tomogoma/authms/generator"
+	"github.com/tomogoma/authms/passhash"
+	"golang.org/x/crypto/bcrypt"
 	"net/url"
 )
 
@@ -94,6 +97,15 @@ func WithFacebookCl(fb FacebookCl) Option {
 	}
 }
 
+// WithOIDCCl sets the OpenID Connect client used to validate id_tokens
+// for the LoginTypeOIDC login type.
+func WithOIDCCl(cl OIDCCl) Option {
+	return func(c *authenticationConfig) error {
+		c.oidcNilable = cl
+		return nil
+	}
+}
+
 // WithSMSCl sets the SMS client to use.
 // You may provide templates
 // for sending SMSes e.g.
@@ -169,6 +181,15 @@ func WithResetPassSubject(s string) Option {
 	}
 }
 
+// WithLoginLinkSubject sets the subject to be used when sending login links
+// to a user.
+func WithLoginLinkSubject(s string) Option {
+	return func(c *authenticationConfig) error {
+		c.lgnLinkSubjEmptyable = s
+		return nil
+	}
+}
+
 // WithPhoneInviteTplt sets the message template to be used when composing SMS
 // invite messages.
 // TODO define valid template values
@@ -217,6 +238,22 @@ func WithPhoneVerifyTplt(t *template.Template, parseErr error) Option {
 	}
 }
 
+// WithPhoneLoginTplt sets the message template to be used when composing phone
+// login code messages.
+// TODO define valid template values
+func WithPhoneLoginTplt(t *template.Template, parseErr error) Option {
+	return func(c *authenticationConfig) error {
+		if parseErr != nil {
+			return parseErr
+		}
+		if t == nil || reflect.ValueOf(t).IsNil() {
+			return errors.New("provided phone login template was nil")
+		}
+		c.loginTpActionTplts[LoginTypePhone][ActionLogin] = t
+		return nil
+	}
+}
+
 // WithEmailInviteTplt sets the message template to be used when composing email
 // invite messages.
 // TODO define valid template values
@@ -265,6 +302,22 @@ func WithEmailVerifyTplt(t *template.Template, parseErr error) Option {
 	}
 }
 
+// WithEmailLoginLinkTplt sets the message template to be used when composing
+// email login link messages.
+// TODO define valid template values
+func WithEmailLoginLinkTplt(t *template.Template, parseErr error) Option {
+	return func(c *authenticationConfig) error {
+		if parseErr != nil {
+			return parseErr
+		}
+		if t == nil || reflect.ValueOf(t).IsNil() {
+			return errors.New("provided email login link template was nil")
+		}
+		c.loginTpActionTplts[LoginTypeEmail][ActionLogin] = t
+		return nil
+	}
+}
+
 func WithVerifyEmailHost(isToVerifyEmailHost bool) Option {
 	return func(c *authenticationConfig) error {
 		c.verifyEmailHost = isToVerifyEmailHost
@@ -272,6 +325,189 @@ func WithVerifyEmailHost(isToVerifyEmailHost bool) Option {
 	}
 }
 
+// WithLoginLockout locks out a login identifier from logging in once
+// failCount failed login attempts have been made with it within window.
+// A failCount of 0 disables lockouts. IP addresses are locked out separately
+// (see WithIPLockout()).
+func WithLoginLockout(failCount int, window time.Duration) Option {
+	return func(c *authenticationConfig) error {
+		if failCount < 0 {
+			return errors.New("login lockout fail count cannot be negative")
+		}
+		if failCount > 0 && window <= 0 {
+			return errors.New("login lockout window must be greater than 0")
+		}
+		c.lockoutFailCount = failCount
+		c.lockoutWindow = window
+		return nil
+	}
+}
+
+// WithIPLockout locks out an IP address from logging in once failCount
+// failed login attempts, across all login identifiers, have been made from it
+// within the WithLoginLockout() window. It has no effect if WithLoginLockout()
+// disables lockouts. A failCount of 0 disables IP lockouts. The default is
+// 10 times the WithLoginLockout() fail count so that the users behind a
+// shared IP address (e.g. NAT) are not locked out by a few mistyped
+// passwords.
+func WithIPLockout(failCount int) Option {
+	return func(c *authenticationConfig) error {
+		if failCount < 0 {
+			return errors.New("IP lockout fail count cannot be negative")
+		}
+		c.ipLockoutFailCount = failCount
+		return nil
+	}
+}
+
+// WithLoginOTPThrottle limits the login codes sent to a phone number, and
+// the login links sent to an email address, to maxSends within window. Other
+// codes and links sent to the address, e.g. for verification, count towards
+// the limit. A maxSends of 0 disables the limit.
+// The default is 3 codes every 15 minutes.
+func WithLoginOTPThrottle(maxSends int, window time.Duration) Option {
+	return func(c *authenticationConfig) error {
+		if maxSends < 0 {
+			return errors.New("login OTP max sends cannot be negative")
+		}
+		if maxSends > 0 && window <= 0 {
+			return errors.New("login OTP throttle window must be greater than 0")
+		}
+		c.otpSendLimit = maxSends
+		c.otpSendWindow = window
+		return nil
+	}
+}
+
+// WithMFAEncrypter sets the Encrypter used to protect two-factor authentication
+// secrets at rest. Two-factor authentication enrollment is not available
+// if this option is not provided.
+func WithMFAEncrypter(e Encrypter) Option {
+	return func(c *authenticationConfig) error {
+		c.mfaEncNilable = e
+		return nil
+	}
+}
+
+// WithTokenValidity sets the lifetime of JWTs issued to users whose group has
+// no lifetime set through WithGroupTokenValidity. The default is 1 hour.
+func WithTokenValidity(d time.Duration) Option {
+	return func(c *authenticationConfig) error {
+		if d <= 0 {
+			return errors.New("token validity must be greater than 0")
+		}
+		c.tokenValidity = d
+		return nil
+	}
+}
+
+// WithGroupTokenValidity sets the lifetime of JWTs issued to members of the
+// group named groupName e.g.
+//     WithGroupTokenValidity(GroupAdmin, 15*time.Minute)
+func WithGroupTokenValidity(groupName string, d time.Duration) Option {
+	return func(c *authenticationConfig) error {
+		if groupName == "" {
+			return errors.New("group name for token validity was empty")
+		}
+		if d <= 0 {
+			return errors.New("group token validity must be greater than 0")
+		}
+		c.grpTokenValidities[groupName] = d
+		return nil
+	}
+}
+
+// WithActionValidity sets how long tokens and codes sent for action remain
+// valid. action is one of ActionInvite, ActionVerify, ActionResetPass,
+// ActionExtendTkn or ActionLogin (login links and codes). The defaults are
+// 30 days, 5 minutes, 2 hours, 2 hours and 15 minutes respectively.
+func WithActionValidity(action string, d time.Duration) Option {
+	return func(c *authenticationConfig) error {
+		if _, ok := c.actionValidities[action]; !ok {
+			return errors.New("validity not supported for action " + action)
+		}
+		if d <= 0 {
+			return errors.New("action validity must be greater than 0")
+		}
+		c.actionValidities[action] = d
+		return nil
+	}
+}
+
+// WithOTPFormat sets the length and the characters of the one time codes
+// sent for action. action is one of ActionInvite, ActionVerify,
+// ActionResetPass or ActionLogin. The default is 6 digits.
+func WithOTPFormat(action string, length int, alphabet string) Option {
+	return func(c *authenticationConfig) error {
+		switch action {
+		case ActionInvite, ActionVerify, ActionResetPass, ActionLogin:
+		default:
+			return errors.New("OTP format not supported for action " + action)
+		}
+		if length <= 0 {
+			return errors.New("OTP length must be greater than 0")
+		}
+		gen, err := generator.NewRandom(alphabet)
+		if err != nil {
+			return err
+		}
+		c.actionOTPFormats[action] = otpFormat{length: length, gen: gen}
+		return nil
+	}
+}
+
+// WithPasswordPolicy sets the rules passwords chosen by users must satisfy.
+// The default policy only requires passwords to be at least 8 characters.
+func WithPasswordPolicy(p PasswordPolicy) Option {
+	return func(c *authenticationConfig) error {
+		if err := p.valid(); err != nil {
+			return err
+		}
+		c.passPolicy = p
+		return nil
+	}
+}
+
+// WithPwnedPassCheck rejects new passwords that have been seen in data
+// breaches at least threshold times according to c. If warnOnLogin is true
+// Login() also flags users whose current password has been breached
+// (see User.PassPwned).
+func WithPwnedPassCheck(c PwnedCounter, threshold int, warnOnLogin bool) Option {
+	return func(ac *authenticationConfig) error {
+		if c == nil || reflect.ValueOf(c).IsNil() {
+			return errors.New("pwned password counter cannot be nil")
+		}
+		if threshold < 1 {
+			return errors.New("pwned password threshold must be at least 1")
+		}
+		ac.pwnedNilable = c
+		ac.pwnedThreshold = threshold
+		ac.warnPwnedOnLogin = warnOnLogin
+		return nil
+	}
+}
+
+// WithPassHasher sets the PassHasher used to hash and verify user passwords.
+// Login() rehashes passwords whose hashes h reports as outdated. The default
+// uses bcrypt at bcrypt.DefaultCost.
+func WithPassHasher(h PassHasher, initErr error) Option {
+	return func(c *authenticationConfig) error {
+		if initErr != nil {
+			return initErr
+		}
+		if h == nil || reflect.ValueOf(h).IsNil() {
+			return errors.New("password hasher cannot be nil")
+		}
+		c.passHasher = h
+		return nil
+	}
+}
+
+type otpFormat struct {
+	length int
+	gen    SecureRandomByteser
+}
+
 type authenticationConfig struct {
 	// mandatory parameters
 	passGen         SecureRandomByteser
@@ -283,6 +519,7 @@ type authenticationConfig struct {
 	// optional parameters
 	appNameEmptyable     string
 	fbNilable            FacebookCl
+	oidcNilable          OIDCCl
 	smserNilable         SMSer
 	mailerNilable        Mailer
 	webAppURLNilable     *url.URL
@@ -290,6 +527,22 @@ type authenticationConfig struct {
 	invSubjEmptyable     string
 	verSubjEmptyable     string
 	resPassSubjEmptyable string
+	lgnLinkSubjEmptyable string
+	lockoutFailCount     int
+	lockoutWindow        time.Duration
+	ipLockoutFailCount   int
+	otpSendLimit         int
+	otpSendWindow        time.Duration
+	mfaEncNilable        Encrypter
+	tokenValidity        time.Duration
+	grpTokenValidities   map[string]time.Duration
+	actionValidities     map[string]time.Duration
+	actionOTPFormats     map[string]otpFormat
+	passPolicy           PasswordPolicy
+	pwnedNilable         PwnedCounter
+	pwnedThreshold       int
+	warnPwnedOnLogin     bool
+	passHasher           PassHasher
 	// tail values optional depending on need/type for communication
 	loginTpActionTplts map[string]map[string]*template.Template
 }
@@ -298,6 +551,20 @@ func (c *authenticationConfig) initializeValues() {
 	c.allowSelfReg = true
 	c.lockDevToUser = false
 	c.verifyEmailHost = true
+	c.tokenValidity = defTokenValidity
+	c.ipLockoutFailCount = -1
+	c.otpSendLimit = defOTPSendLimit
+	c.otpSendWindow = defOTPSendWindow
+	c.grpTokenValidities = make(map[string]time.Duration)
+	c.actionValidities = map[string]time.Duration{
+		ActionInvite:    defInviteValidity,
+		ActionVerify:    defVerifyValidity,
+		ActionResetPass: defResetValidity,
+		ActionExtendTkn: defExtendTknValidity,
+		ActionLogin:     defLoginLinkValidity,
+	}
+	c.actionOTPFormats = make(map[string]otpFormat)
+	c.passPolicy = PasswordPolicy{MinLength: minPassLen}
 	c.loginTpActionTplts = map[string]map[string]*template.Template{
 		LoginTypePhone: make(map[string]*template.Template),
 		LoginTypeEmail: make(map[string]*template.Template),
@@ -314,6 +581,9 @@ func (c *authenticationConfig) assignOptions(opts []Option) error {
 }
 
 func (c *authenticationConfig) fillDefaults() error {
+	if c.ipLockoutFailCount < 0 {
+		c.ipLockoutFailCount = defIPLockoutFailFactor * c.lockoutFailCount
+	}
 	var defaultOpts []Option
 	if c.passGen == nil {
 		defaultOpts = append(
@@ -333,6 +603,12 @@ func (c *authenticationConfig) fillDefaults() error {
 			WithURLTokenGen(generator.NewRandom(generator.AlphaNumericChars)),
 		)
 	}
+	if c.passHasher == nil {
+		defaultOpts = append(
+			defaultOpts,
+			WithPassHasher(passhash.NewBcrypt(bcrypt.DefaultCost)),
+		)
+	}
 	if c.smserNilable != nil {
 		phoneTpls := c.loginTpActionTplts[LoginTypePhone]
 		if _, ok := phoneTpls[ActionInvite]; !ok {
@@ -353,6 +629,12 @@ func (c *authenticationConfig) fillDefaults() error {
 				WithPhoneResetPassTplt(template.ParseFiles(config.DefaultPhoneResetPassTpl())),
 			)
 		}
+		if _, ok := phoneTpls[ActionLogin]; !ok {
+			defaultOpts = append(
+				defaultOpts,
+				WithPhoneLoginTplt(template.ParseFiles(config.DefaultPhoneLoginTpl())),
+			)
+		}
 	}
 	if c.mailerNilable != nil {
 		emailTPls := c.loginTpActionTplts[LoginTypeEmail]
@@ -374,6 +656,12 @@ func (c *authenticationConfig) fillDefaults() error {
 				WithEmailResetPassTplt(template.ParseFiles(config.DefaultEmailResetPassTpl())),
 			)
 		}
+		if _, ok := emailTPls[ActionLogin]; !ok {
+			defaultOpts = append(
+				defaultOpts,
+				WithEmailLoginLinkTplt(template.ParseFiles(config.DefaultEmailLoginLinkTpl())),
+			)
+		}
 	}
 	return c.assignOptions(defaultOpts)
 }
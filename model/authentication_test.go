@@ -1,10 +1,24 @@
 package model_test
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
+	"github.com/tomogoma/authms/config"
+	"github.com/tomogoma/authms/generator"
 	"github.com/tomogoma/authms/model"
+	"github.com/tomogoma/authms/oidc"
+	"github.com/tomogoma/authms/passhash"
 	testingH "github.com/tomogoma/authms/testing"
+	"github.com/tomogoma/authms/totp"
+	typederrs "github.com/tomogoma/go-typed-errors"
+	token "github.com/tomogoma/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -34,6 +48,80 @@ func TestNewAuthentication(t *testing.T) {
 			jwter:  nil,
 			expErr: true,
 		},
+		{
+			name:  "valid lifetimes",
+			db:    &testingH.DBMock{},
+			jwter: &testingH.JWTMock{},
+			opts: []model.Option{
+				model.WithTokenValidity(30 * time.Minute),
+				model.WithGroupTokenValidity(model.GroupAdmin, 15*time.Minute),
+				model.WithActionValidity(model.ActionVerify, 15*time.Minute),
+				model.WithOTPFormat(model.ActionVerify, 8, "0123456789ABCDEF"),
+			},
+		},
+		{
+			name:   "nil pwned counter",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithPwnedPassCheck(nil, 1, false)},
+			expErr: true,
+		},
+		{
+			name:   "zero pwned threshold",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithPwnedPassCheck(&testingH.PwnedMock{}, 0, false)},
+			expErr: true,
+		},
+		{
+			name:   "nil password hasher",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithPassHasher(nil, nil)},
+			expErr: true,
+		},
+		{
+			name:   "password hasher init error",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithPassHasher(passhash.NewBcrypt(0))},
+			expErr: true,
+		},
+		{
+			name:   "zero token validity",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithTokenValidity(0)},
+			expErr: true,
+		},
+		{
+			name:   "empty group token validity name",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithGroupTokenValidity("", time.Minute)},
+			expErr: true,
+		},
+		{
+			name:   "unsupported action validity",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithActionValidity("none-such", time.Minute)},
+			expErr: true,
+		},
+		{
+			name:   "unsupported OTP format action",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithOTPFormat(model.ActionExtendTkn, 6, generator.NumberChars)},
+			expErr: true,
+		},
+		{
+			name:   "bad OTP alphabet",
+			db:     &testingH.DBMock{},
+			jwter:  &testingH.JWTMock{},
+			opts:   []model.Option{model.WithOTPFormat(model.ActionVerify, 6, "1")},
+			expErr: true,
+		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -122,6 +210,30 @@ func TestAuthentication_RegisterSelf(t *testing.T) {
 			expErr:            true,
 			expNotImplemented: true,
 		},
+		{
+			name:  "successful oidc",
+			db:    &testingH.DBMock{},
+			jwter: &testingH.JWTMock{},
+			opts: []model.Option{
+				model.WithOIDCCl(&testingH.OIDCMock{ExpIssuer: "https://idp.test", ExpSubject: "123"}),
+			},
+			loginType:  model.LoginTypeOIDC,
+			userType:   model.UserTypeIndividual,
+			identifier: "an.id.token",
+			secret:     []byte("some-nonce"),
+			expErr:     false,
+		},
+		{
+			name:              "oidc unavailable",
+			db:                &testingH.DBMock{},
+			jwter:             &testingH.JWTMock{},
+			loginType:         model.LoginTypeOIDC,
+			userType:          model.UserTypeIndividual,
+			identifier:        "an.id.token",
+			secret:            []byte("some-nonce"),
+			expErr:            true,
+			expNotImplemented: true,
+		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -161,6 +273,8 @@ func TestAuthentication_Login(t *testing.T) {
 		identifier      string
 		password        []byte
 		loginType       string
+		ci              model.ClientInfo
+		expRefreshTkn   bool
 		expErr          bool
 		expUnauthorized bool
 		expForbidden    bool
@@ -173,11 +287,29 @@ func TestAuthentication_Login(t *testing.T) {
 			password:   validPass,
 			loginType:  model.LoginTypePhone,
 		},
+		{
+			name:          "valid username with API key",
+			db:            &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH},
+			jwter:         &testingH.JWTMock{},
+			identifier:    "johndoe",
+			password:      validPass,
+			loginType:     model.LoginTypeUsername,
+			ci:            model.ClientInfo{APIKeyID: "456"},
+			expRefreshTkn: true,
+		},
+		{
+			name:       "valid username with master API key",
+			db:         &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH},
+			jwter:      &testingH.JWTMock{},
+			identifier: "johndoe",
+			password:   validPass,
+			loginType:  model.LoginTypeUsername,
+		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			a := newAuthentication(t, tc.db, tc.jwter, tc.opts...)
-			usr, err := a.Login(tc.loginType, tc.identifier, tc.password)
+			usr, err := a.Login(tc.ci, tc.loginType, tc.identifier, tc.password)
 			if tc.expErr {
 				if err == nil {
 					t.Fatalf("Expected an error, got nil")
@@ -198,14 +330,4001 @@ func TestAuthentication_Login(t *testing.T) {
 			if usr == nil {
 				t.Fatalf("Got nil user")
 			}
+			if tc.expRefreshTkn != (usr.RefreshToken != "") {
+				t.Fatalf("Expected refresh token %t, got '%s'",
+					tc.expRefreshTkn, usr.RefreshToken)
+			}
 		})
 	}
 }
 
-func newAuthentication(t *testing.T, d model.AuthStore, j model.JWTEr, opts ...model.Option) *model.Authentication {
-	a, err := model.NewAuthentication(d, j, opts...)
+func TestAuthentication_Login_lockout(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
 	if err != nil {
-		t.Fatalf("Error setting up: new authentication: %v", err)
+		t.Fatalf("Error setting up: hash test password: %v", err)
 	}
-	return a
+	recentFlrs := []model.LoginFailure{
+		{CreateDate: time.Now().Add(-1 * time.Minute)},
+		{CreateDate: time.Now().Add(-2 * time.Minute)},
+	}
+	lockoutOpt := model.WithLoginLockout(2, 1*time.Hour)
+	tt := []struct {
+		name            string
+		db              *testingH.DBMock
+		opts            []model.Option
+		password        []byte
+		expFlrRecord    bool
+		expFlrsClear    bool
+		expErr          bool
+		expForbidden    bool
+		expUnauthorized bool
+	}{
+		{
+			name:         "valid clears failures",
+			db:           &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH, ExpLgnFlrs: recentFlrs[:1]},
+			opts:         []model.Option{lockoutOpt},
+			password:     validPass,
+			expFlrsClear: true,
+		},
+		{
+			name:         "bad password recorded",
+			db:           &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH},
+			opts:         []model.Option{lockoutOpt},
+			password:     []byte("some wrong password"),
+			expFlrRecord: true,
+			expErr:       true,
+			expForbidden: true,
+		},
+		{
+			name: "bad password recorded when saving history fails",
+			db: &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH,
+				ExpInsHistErr: errors.New("whoops")},
+			opts:         []model.Option{lockoutOpt},
+			password:     []byte("some wrong password"),
+			expFlrRecord: true,
+			expErr:       true,
+		},
+		{
+			name:            "user not found recorded",
+			db:              &testingH.DBMock{},
+			opts:            []model.Option{lockoutOpt},
+			password:        validPass,
+			expFlrRecord:    true,
+			expErr:          true,
+			expUnauthorized: true,
+		},
+		{
+			name: "lookup error not recorded",
+			db: &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH,
+				ExpUsrBUsrNmErr: errors.New("whoops")},
+			opts:     []model.Option{lockoutOpt},
+			password: validPass,
+			expErr:   true,
+		},
+		{
+			name: "attempt rejected when failure cannot be recorded",
+			db: &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH,
+				ExpInsLgnFlrErr: errors.New("whoops")},
+			opts:     []model.Option{lockoutOpt},
+			password: validPass,
+			expErr:   true,
+		},
+		{
+			name:         "identifier locked out",
+			db:           &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH, ExpLgnFlrs: recentFlrs},
+			opts:         []model.Option{lockoutOpt},
+			password:     validPass,
+			expErr:       true,
+			expForbidden: true,
+		},
+		{
+			name:         "IP locked out",
+			db:           &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH, ExpLgnFlrsBIP: recentFlrs},
+			opts:         []model.Option{lockoutOpt, model.WithIPLockout(2)},
+			password:     validPass,
+			expErr:       true,
+			expForbidden: true,
+		},
+		{
+			name:         "IP below IP lockout fail count",
+			db:           &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH, ExpLgnFlrsBIP: recentFlrs},
+			opts:         []model.Option{lockoutOpt},
+			password:     validPass,
+			expFlrsClear: true,
+		},
+		{
+			name:         "IP lockout disabled",
+			db:           &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH, ExpLgnFlrsBIP: recentFlrs},
+			opts:         []model.Option{lockoutOpt, model.WithIPLockout(0)},
+			password:     validPass,
+			expFlrsClear: true,
+		},
+		{
+			name:     "lockout disabled",
+			db:       &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH, ExpLgnFlrs: recentFlrs},
+			password: validPass,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, &testingH.JWTMock{}, tc.opts...)
+			ci := model.ClientInfo{IPAddress: "127.0.0.1"}
+			_, err := a.Login(ci, model.LoginTypeUsername, "johndoe", tc.password)
+			if flrRecorded := len(tc.db.InsertedLgnFlrs) > 0; tc.expFlrRecord != flrRecorded {
+				t.Errorf("Expected login failure recorded %t, got %t",
+					tc.expFlrRecord, flrRecorded)
+			}
+			if tc.expFlrsClear != tc.db.IsLgnFlrsDeleted {
+				t.Errorf("Expected login failures cleared %t, got %t",
+					tc.expFlrsClear, tc.db.IsLgnFlrsDeleted)
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				if tc.expForbidden != a.IsForbiddenError(err) {
+					t.Fatalf("Expected IsForbiddenError %t, got %v",
+						tc.expForbidden, err)
+				}
+				if tc.expUnauthorized != a.IsUnauthorizedError(err) {
+					t.Fatalf("Expected IsUnauthorizedError %t, got %v",
+						tc.expUnauthorized, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Login_history(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	tt := []struct {
+		name          string
+		db            *testingH.DBMock
+		password      []byte
+		expHist       bool
+		expSuccessful bool
+		expErr        bool
+	}{
+		{
+			name:          "successful",
+			db:            &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH},
+			password:      validPass,
+			expHist:       true,
+			expSuccessful: true,
+		},
+		{
+			name:     "bad password",
+			db:       &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH},
+			password: []byte("some wrong password"),
+			expHist:  true,
+			expErr:   true,
+		},
+		{
+			name:     "user not found",
+			db:       &testingH.DBMock{},
+			password: validPass,
+			expErr:   true,
+		},
+		{
+			name: "save history fails",
+			db: &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH,
+				ExpInsHistErr: errors.New("whoops")},
+			password: validPass,
+			expErr:   true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, &testingH.JWTMock{})
+			ci := model.ClientInfo{IPAddress: "127.0.0.1", UserAgent: "test-agent"}
+			_, err := a.Login(ci, model.LoginTypeUsername, "johndoe", tc.password)
+			if tc.expErr && err == nil {
+				t.Fatalf("Expected an error, got nil")
+			}
+			if !tc.expErr && err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if !tc.expHist {
+				if len(tc.db.InsertedHist) > 0 {
+					t.Fatalf("Expected no history, got %+v", tc.db.InsertedHist)
+				}
+				return
+			}
+			if len(tc.db.InsertedHist) != 1 {
+				t.Fatalf("Expected 1 history item, got %d", len(tc.db.InsertedHist))
+			}
+			h := tc.db.InsertedHist[0]
+			if h.UserID != "123" || h.AccessType != model.AccessTypeLogin ||
+				h.LoginType != model.LoginTypeUsername || h.IPAddress != ci.IPAddress ||
+				h.UserAgent != ci.UserAgent || h.Successful != tc.expSuccessful {
+				t.Errorf("History mismatch: got %+v", h)
+			}
+		})
+	}
+}
+
+func TestAuthentication_LoginLockout(t *testing.T) {
+	now := time.Now()
+	tt := []struct {
+		name           string
+		db             *testingH.DBMock
+		expIsLocked    bool
+		expFailCount   int
+		expLockedUntil time.Time
+	}{
+		{
+			name:         "no failures",
+			db:           &testingH.DBMock{},
+			expFailCount: 0,
+		},
+		{
+			name:         "below threshold",
+			db:           &testingH.DBMock{ExpLgnFlrs: []model.LoginFailure{{CreateDate: now}}},
+			expFailCount: 1,
+		},
+		{
+			name: "locked",
+			db: &testingH.DBMock{ExpLgnFlrs: []model.LoginFailure{
+				{CreateDate: now},
+				{CreateDate: now.Add(-10 * time.Minute)},
+			}},
+			expFailCount:   2,
+			expIsLocked:    true,
+			expLockedUntil: now.Add(50 * time.Minute),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, &testingH.JWTMock{},
+				model.WithLoginLockout(2, 1*time.Hour))
+			lo, err := a.LoginLockout("some.jwt", model.LoginTypeUsername, "johndoe")
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if lo.FailCount != tc.expFailCount {
+				t.Errorf("Expected fail count %d, got %d", tc.expFailCount, lo.FailCount)
+			}
+			if lo.IsLocked != tc.expIsLocked {
+				t.Errorf("Expected is locked %t, got %t", tc.expIsLocked, lo.IsLocked)
+			}
+			if !lo.LockedUntil.Equal(tc.expLockedUntil) {
+				t.Errorf("Expected locked until %v, got %v", tc.expLockedUntil, lo.LockedUntil)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Refresh(t *testing.T) {
+	secret := "a-refresh-token-secret"
+	secretH, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test refresh token: %v", err)
+	}
+	validRT := func() *model.RefreshToken {
+		return &model.RefreshToken{
+			ID:         "789",
+			UserID:     "123",
+			APIKeyID:   "456",
+			FamilyID:   "family",
+			Token:      secretH,
+			ExpiryDate: time.Now().Add(1 * time.Hour),
+		}
+	}
+	tt := []struct {
+		name            string
+		db              *testingH.DBMock
+		ci              model.ClientInfo
+		refreshTkn      string
+		expFmlyRevoked  bool
+		expErr          bool
+		expUnauthorized bool
+		expForbidden    bool
+	}{
+		{
+			name:       "valid",
+			db:         &testingH.DBMock{ExpRfrshTkn: validRT(), ExpUsr: &model.User{ID: "123"}},
+			ci:         model.ClientInfo{APIKeyID: "456"},
+			refreshTkn: "789." + secret,
+		},
+		{
+			name:            "bad format",
+			db:              &testingH.DBMock{ExpRfrshTkn: validRT(), ExpUsr: &model.User{ID: "123"}},
+			ci:              model.ClientInfo{APIKeyID: "456"},
+			refreshTkn:      secret,
+			expErr:          true,
+			expUnauthorized: true,
+		},
+		{
+			name:            "not found",
+			db:              &testingH.DBMock{ExpUsr: &model.User{ID: "123"}},
+			ci:              model.ClientInfo{APIKeyID: "456"},
+			refreshTkn:      "789." + secret,
+			expErr:          true,
+			expUnauthorized: true,
+		},
+		{
+			name:            "wrong secret",
+			db:              &testingH.DBMock{ExpRfrshTkn: validRT(), ExpUsr: &model.User{ID: "123"}},
+			ci:              model.ClientInfo{APIKeyID: "456"},
+			refreshTkn:      "789.some-other-secret",
+			expErr:          true,
+			expUnauthorized: true,
+		},
+		{
+			name: "reused",
+			db: &testingH.DBMock{
+				ExpRfrshTkn: func() *model.RefreshToken {
+					rt := validRT()
+					rt.IsUsed = true
+					return rt
+				}(),
+				ExpUsr: &model.User{ID: "123"},
+			},
+			ci:             model.ClientInfo{APIKeyID: "456"},
+			refreshTkn:     "789." + secret,
+			expFmlyRevoked: true,
+			expErr:         true,
+			expForbidden:   true,
+		},
+		{
+			name: "reused concurrently",
+			db: &testingH.DBMock{ExpRfrshTkn: validRT(), ExpUsr: &model.User{ID: "123"},
+				ExpSetRfrshTknUsdErr: typederrs.NewNotFound("unused refresh token not found")},
+			ci:             model.ClientInfo{APIKeyID: "456"},
+			refreshTkn:     "789." + secret,
+			expFmlyRevoked: true,
+			expErr:         true,
+			expForbidden:   true,
+		},
+		{
+			name: "revoked",
+			db: &testingH.DBMock{
+				ExpRfrshTkn: func() *model.RefreshToken {
+					rt := validRT()
+					rt.IsRevoked = true
+					return rt
+				}(),
+				ExpUsr: &model.User{ID: "123"},
+			},
+			ci:             model.ClientInfo{APIKeyID: "456"},
+			refreshTkn:     "789." + secret,
+			expFmlyRevoked: true,
+			expErr:         true,
+			expForbidden:   true,
+		},
+		{
+			name: "expired",
+			db: &testingH.DBMock{
+				ExpRfrshTkn: func() *model.RefreshToken {
+					rt := validRT()
+					rt.ExpiryDate = time.Now().Add(-1 * time.Minute)
+					return rt
+				}(),
+				ExpUsr: &model.User{ID: "123"},
+			},
+			ci:         model.ClientInfo{APIKeyID: "456"},
+			refreshTkn: "789." + secret,
+			expErr:     true,
+		},
+		{
+			name:         "different API key",
+			db:           &testingH.DBMock{ExpRfrshTkn: validRT(), ExpUsr: &model.User{ID: "123"}},
+			ci:           model.ClientInfo{APIKeyID: "654"},
+			refreshTkn:   "789." + secret,
+			expErr:       true,
+			expForbidden: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, &testingH.JWTMock{})
+			usr, err := a.Refresh(tc.ci, tc.refreshTkn)
+			if fmlyRevoked := tc.db.RevokedRfrshTknFmly != ""; tc.expFmlyRevoked != fmlyRevoked {
+				t.Errorf("Expected refresh token family revoked %t, got %t",
+					tc.expFmlyRevoked, fmlyRevoked)
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				if tc.expUnauthorized != a.IsUnauthorizedError(err) {
+					t.Fatalf("Expected IsUnauthorizedError %t, got %v",
+						tc.expUnauthorized, err)
+				}
+				if tc.expForbidden != a.IsForbiddenError(err) {
+					t.Fatalf("Expected IsForbiddenError %t, got %v",
+						tc.expForbidden, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if usr == nil {
+				t.Fatalf("Got nil user")
+			}
+			if usr.JWT == "" {
+				t.Errorf("Expected a JWT, got empty")
+			}
+			if usr.RefreshToken == "" || usr.RefreshToken == tc.refreshTkn {
+				t.Errorf("Expected a new refresh token, got '%s'", usr.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Login_tokenValidity(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	opts := []model.Option{
+		model.WithTokenValidity(2 * time.Hour),
+		model.WithGroupTokenValidity(model.GroupAdmin, 15*time.Minute),
+	}
+	tt := []struct {
+		name        string
+		group       model.Group
+		expValidity time.Duration
+	}{
+		{
+			name:        "default",
+			group:       model.Group{Name: model.GroupUser, AccessLevel: model.AccessLevelUser},
+			expValidity: 2 * time.Hour,
+		},
+		{
+			name:        "group override",
+			group:       model.Group{Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin},
+			expValidity: 15 * time.Minute,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.group,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH}
+			a := newAuthentication(t, db, newJWTHandler(t), opts...)
+			lgnUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			ti, err := a.Introspect(lgnUsr.JWT)
+			if err != nil || !ti.Active {
+				t.Fatalf("Error setting up: introspect: active %t: %v", ti.Active, err)
+			}
+			validity := time.Duration(ti.Claims.ExpiresAt-ti.Claims.IssuedAt) * time.Second
+			if validity != tc.expValidity {
+				t.Errorf("Expected validity %s, got %s", tc.expValidity, validity)
+			}
+		})
+	}
+}
+
+func TestAuthentication_UpdatePassword(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	prevPassH, err := bcrypt.GenerateFromPassword([]byte("Previous-pass1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash previous password: %v", err)
+	}
+	policy := model.PasswordPolicy{
+		MinLength:      10,
+		MaxLength:      20,
+		RequireLower:   true,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		NoIdentifiers:  true,
+		HistoryCount:   3,
+	}
+	usr := &model.User{ID: "123", UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"},
+		Email: model.VerifLogin{ID: "1", UserID: "123", Address: "jane.doe@mailinator.com"}}
+	tt := []struct {
+		name          string
+		db            *testingH.DBMock
+		newPass       string
+		expViolations []string
+		expErr        bool
+	}{
+		{
+			name:    "valid",
+			db:      &testingH.DBMock{ExpPassHstry: [][]byte{prevPassH}},
+			newPass: "A-valid-pass1",
+		},
+		{
+			name:          "too short and missing classes",
+			db:            &testingH.DBMock{},
+			newPass:       "short",
+			expViolations: []string{model.PassRuleMinLength, model.PassRuleUpperCase, model.PassRuleDigit, model.PassRuleSpecial},
+		},
+		{
+			name:          "too long",
+			db:            &testingH.DBMock{},
+			newPass:       "A-valid-pass1-but-too-long",
+			expViolations: []string{model.PassRuleMaxLength},
+		},
+		{
+			name:          "contains username",
+			db:            &testingH.DBMock{},
+			newPass:       "My-JohnDoe-pass1",
+			expViolations: []string{model.PassRuleNoIdentifier},
+		},
+		{
+			name:          "contains email local-part",
+			db:            &testingH.DBMock{},
+			newPass:       "Jane.Doe-pass1",
+			expViolations: []string{model.PassRuleNoIdentifier},
+		},
+		{
+			name:          "reuses previous password",
+			db:            &testingH.DBMock{ExpPassHstry: [][]byte{prevPassH}},
+			newPass:       "Previous-pass1",
+			expViolations: []string{model.PassRuleNotReused},
+		},
+		{
+			name:    "password history error",
+			db:      &testingH.DBMock{ExpPassHstryErr: errors.New("whoops")},
+			newPass: "A-valid-pass1",
+			expErr:  true,
+		},
+		{
+			name:    "insert password history error",
+			db:      &testingH.DBMock{ExpInsPassHstryAtmErr: errors.New("whoops")},
+			newPass: "A-valid-pass1",
+			expErr:  true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.db.ExpUsrBUsrNm = usr
+			tc.db.ExpUsrBUsrNmPass = validPassH
+			tc.db.ExpUsr = usr
+			tc.db.ExpUsrPass = validPassH
+			a := newAuthentication(t, tc.db, newJWTHandler(t), model.WithPasswordPolicy(policy))
+			lgnUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			err = a.UpdatePassword(lgnUsr.JWT, validPass, []byte(tc.newPass))
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if len(tc.expViolations) > 0 {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				vs, ok := model.PasswordViolations(err)
+				if !ok {
+					t.Fatalf("Expected password violations, got %v", err)
+				}
+				if len(vs) != len(tc.expViolations) {
+					t.Fatalf("Expected %d violations, got %+v", len(tc.expViolations), vs)
+				}
+				for i, v := range vs {
+					if v.Rule != tc.expViolations[i] {
+						t.Errorf("Expected violation %d to be '%s', got '%s'",
+							i, tc.expViolations[i], v.Rule)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(tc.db.InsertedPassHstry) != 1 || string(tc.db.InsertedPassHstry[0]) != string(validPassH) {
+				t.Errorf("Expected previous password to be added to history")
+			}
+		})
+	}
+}
+
+func TestAuthentication_RegisterSelf_pwned(t *testing.T) {
+	tt := []struct {
+		name      string
+		pwned     *testingH.PwnedMock
+		threshold int
+		expBreach bool
+		expErr    bool
+	}{
+		{name: "not breached", pwned: &testingH.PwnedMock{}, threshold: 1},
+		{
+			name:      "breached",
+			pwned:     &testingH.PwnedMock{ExpPwned: map[string]int{"password1": 10}},
+			threshold: 1,
+			expBreach: true,
+		},
+		{
+			name:      "below threshold",
+			pwned:     &testingH.PwnedMock{ExpPwned: map[string]int{"password1": 10}},
+			threshold: 11,
+		},
+		{
+			name:      "lookup error",
+			pwned:     &testingH.PwnedMock{ExpCntErr: errors.New("whoops")},
+			threshold: 1,
+			expErr:    true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, &testingH.DBMock{}, &testingH.JWTMock{},
+				model.WithPwnedPassCheck(tc.pwned, tc.threshold, false))
+			_, err := a.RegisterSelf(model.LoginTypeUsername, model.UserTypeIndividual,
+				"johndoe", []byte("password1"))
+			if tc.expErr {
+				if err == nil || a.IsClientError(err) {
+					t.Fatalf("Expected a non-client error, got %v", err)
+				}
+				return
+			}
+			if tc.expBreach {
+				vs, ok := model.PasswordViolations(err)
+				if !ok || len(vs) != 1 || vs[0].Rule != model.PassRuleNotBreached {
+					t.Fatalf("Expected a %s violation, got %v", model.PassRuleNotBreached, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Login_pwned(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	usr := &model.User{ID: "123", UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+	tt := []struct {
+		name        string
+		pwned       *testingH.PwnedMock
+		warnOnLogin bool
+		expPwned    bool
+	}{
+		{
+			name:        "breached",
+			pwned:       &testingH.PwnedMock{ExpPwned: map[string]int{string(validPass): 1}},
+			warnOnLogin: true,
+			expPwned:    true,
+		},
+		{
+			name:        "not breached",
+			pwned:       &testingH.PwnedMock{},
+			warnOnLogin: true,
+		},
+		{
+			name:  "warning disabled",
+			pwned: &testingH.PwnedMock{ExpPwned: map[string]int{string(validPass): 1}},
+		},
+		{
+			name:        "lookup error",
+			pwned:       &testingH.PwnedMock{ExpCntErr: errors.New("whoops")},
+			warnOnLogin: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH}
+			a := newAuthentication(t, db, newJWTHandler(t),
+				model.WithPwnedPassCheck(tc.pwned, 1, tc.warnOnLogin))
+			lgnUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if lgnUsr.PassPwned != tc.expPwned {
+				t.Errorf("Expected PassPwned %t, got %t", tc.expPwned, lgnUsr.PassPwned)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Login_rehash(t *testing.T) {
+	validPass := []byte("a valid password")
+	bcryptMin, err := passhash.NewBcrypt(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: new bcrypt hasher: %v", err)
+	}
+	argon2id, err := passhash.NewArgon2id(passhash.Argon2Params{
+		Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+	})
+	if err != nil {
+		t.Fatalf("Error setting up: new argon2id hasher: %v", err)
+	}
+	usr := &model.User{ID: "123", UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+	tt := []struct {
+		name       string
+		hashedWith *passhash.Hasher
+		hasher     *passhash.Hasher
+		db         *testingH.DBMock
+		expRehash  bool
+		expErr     bool
+	}{
+		{
+			name:       "current parameters",
+			hashedWith: argon2id,
+			hasher:     argon2id,
+			db:         &testingH.DBMock{ExpUsrBUsrNm: usr},
+		},
+		{
+			name:       "outdated algorithm",
+			hashedWith: bcryptMin,
+			hasher:     argon2id,
+			db:         &testingH.DBMock{ExpUsrBUsrNm: usr},
+			expRehash:  true,
+		},
+		{
+			name:       "update error",
+			hashedWith: bcryptMin,
+			hasher:     argon2id,
+			db:         &testingH.DBMock{ExpUsrBUsrNm: usr, ExpupdPassAtmErr: errors.New("whoops")},
+			expErr:     true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			passH, err := tc.hashedWith.Hash(validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: hash password: %v", err)
+			}
+			tc.db.ExpUsrBUsrNmPass = passH
+			a := newAuthentication(t, tc.db, newJWTHandler(t), model.WithPassHasher(tc.hasher, nil))
+			_, err = a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if !tc.expRehash {
+				if len(tc.db.UpdatedPasses) > 0 {
+					t.Errorf("Expected password not to be rehashed")
+				}
+				return
+			}
+			if len(tc.db.UpdatedPasses) != 1 {
+				t.Fatalf("Expected 1 rehashed password, got %d", len(tc.db.UpdatedPasses))
+			}
+			needsRehash, err := tc.hasher.Verify(tc.db.UpdatedPasses[0], validPass)
+			if err != nil {
+				t.Fatalf("Rehashed password does not verify: %v", err)
+			}
+			if needsRehash {
+				t.Errorf("Expected rehashed password to use current parameters")
+			}
+		})
+	}
+}
+
+func TestAuthentication_ImportUser(t *testing.T) {
+	adminPass := []byte("an admin password")
+	hasher, err := passhash.NewBcrypt(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: new bcrypt hasher: %v", err)
+	}
+	adminPassH, err := hasher.Hash(adminPass)
+	if err != nil {
+		t.Fatalf("Error setting up: hash admin password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "2", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	djangoHash := "pbkdf2_sha256$10000$seasalt$A0LCg3KQUWRQJo0wRS2uE8JFda3ue7VorXA8KW0wTXw="
+	validImport := model.UserImport{
+		UserType:          model.UserTypeIndividual,
+		GroupID:           userGrp.ID,
+		Username:          "janedoe",
+		PassHashAlgorithm: passhash.AlgDjangoPBKDF2SHA256,
+		PassHash:          djangoHash,
+	}
+	tt := []struct {
+		name        string
+		importerGrp model.Group
+		ui          func(ui *model.UserImport)
+		expClErr    bool
+		expErr      bool
+	}{
+		{name: "valid", importerGrp: adminGrp, ui: func(ui *model.UserImport) {}},
+		{
+			name:        "no identifier",
+			importerGrp: adminGrp,
+			ui:          func(ui *model.UserImport) { ui.Username = "" },
+			expClErr:    true,
+		},
+		{
+			name:        "unsupported algorithm",
+			importerGrp: adminGrp,
+			ui:          func(ui *model.UserImport) { ui.PassHashAlgorithm = "md5" },
+			expClErr:    true,
+		},
+		{
+			name:        "malformed hash",
+			importerGrp: adminGrp,
+			ui:          func(ui *model.UserImport) { ui.PassHash = "pbkdf2_sha256$10000$seasalt" },
+			expClErr:    true,
+		},
+		{
+			name:        "not admin",
+			importerGrp: userGrp,
+			ui:          func(ui *model.UserImport) {},
+			expErr:      true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			admin := &model.User{ID: "123", Group: tc.importerGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: admin, ExpUsrBUsrNmPass: adminPassH}
+			a := newAuthentication(t, db, newJWTHandler(t), model.WithPassHasher(hasher, nil))
+			adminUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", adminPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpUsrBUsrNm = nil
+			db.ExpGrp = &userGrp
+
+			ui := validImport
+			tc.ui(&ui)
+			usr, err := a.ImportUser(adminUsr.JWT, ui)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if usr.UserName.Value != ui.Username {
+				t.Errorf("Expected username '%s', got '%s'", ui.Username, usr.UserName.Value)
+			}
+
+			// The imported user logs in with their existing password and
+			// the hash gets upgraded.
+			db.ExpUsrBUsrNm = usr
+			db.ExpUsrBUsrNmPass = []byte(djangoHash)
+			if _, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, ui.Username, []byte("letmein123")); err != nil {
+				t.Fatalf("Imported user login: %v", err)
+			}
+			if len(db.UpdatedPasses) != 1 {
+				t.Fatalf("Expected 1 upgraded password, got %d", len(db.UpdatedPasses))
+			}
+			if needsRehash, err := hasher.Verify(db.UpdatedPasses[0], []byte("letmein123")); err != nil || needsRehash {
+				t.Errorf("Expected upgraded hash in native format: needsRehash %t: %v", needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_LoginByLink(t *testing.T) {
+	tt := []struct {
+		name         string
+		nonce        func(nonce string) string
+		prepTkns     func(tkns []model.DBToken)
+		expUnauthErr bool
+		expAuthErr   bool
+		expForbdErr  bool
+	}{
+		{name: "valid", nonce: func(n string) string { return n }, prepTkns: func([]model.DBToken) {}},
+		{
+			name:         "another client's nonce",
+			nonce:        func(n string) string { return strings.Repeat("a", len(n)) },
+			prepTkns:     func([]model.DBToken) {},
+			expUnauthErr: true,
+		},
+		{
+			name:         "missing nonce",
+			nonce:        func(string) string { return "" },
+			prepTkns:     func([]model.DBToken) {},
+			expUnauthErr: true,
+		},
+		{
+			name:        "used link",
+			nonce:       func(n string) string { return n },
+			prepTkns:    func(tkns []model.DBToken) { tkns[0].IsUsed = true },
+			expForbdErr: true,
+		},
+		{
+			name:       "expired link",
+			nonce:      func(n string) string { return n },
+			prepTkns:   func(tkns []model.DBToken) { tkns[0].ExpiryDate = time.Now().Add(-time.Second) },
+			expAuthErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123",
+				Email: model.VerifLogin{ID: "1", UserID: "123", Address: "johndoe@example.com", Verified: true}}
+			db := &testingH.DBMock{ExpUsrBMail: usr, ExpUsr: usr}
+			mailer := &testingH.MailerMock{}
+			tpl := template.Must(template.New("email").Parse("{{.URLToken}}"))
+			a := newAuthentication(t, db, newJWTHandler(t),
+				model.WithEmailCl(mailer),
+				model.WithServiceURL("https://auth.example.com/api"),
+				model.WithVerifyEmailHost(false),
+				model.WithEmailInviteTplt(tpl, nil),
+				model.WithEmailVerifyTplt(tpl, nil),
+				model.WithEmailResetPassTplt(tpl, nil),
+				model.WithEmailLoginLinkTplt(tpl, nil),
+				model.WithActionValidity(model.ActionLogin, 10*time.Minute),
+			)
+
+			dbts, err := a.SendLoginLink(model.LoginTypeEmail, "johndoe@example.com")
+			if err != nil {
+				t.Fatalf("Error setting up: send login link: %v", err)
+			}
+			if exp := time.Now().Add(10 * time.Minute); dbts.ExpiresAt.After(exp) {
+				t.Errorf("Expected link to expire by %v, got %v", exp, dbts.ExpiresAt)
+			}
+			if len(mailer.SentMails) != 1 {
+				t.Fatalf("Expected 1 email sent, got %d", len(mailer.SentMails))
+			}
+			link := string(mailer.SentMails[0].Body)
+			linkPrefix := "https://auth.example.com/api/users/123/emails/login/"
+			if !strings.HasPrefix(link, linkPrefix) {
+				t.Fatalf("Expected link prefixed with '%s', got '%s'", linkPrefix, link)
+			}
+			if len(db.InsertedMailTkns) != 1 {
+				t.Fatalf("Expected 1 inserted token, got %d", len(db.InsertedMailTkns))
+			}
+			db.ExpMailTkns = db.InsertedMailTkns
+			tc.prepTkns(db.ExpMailTkns)
+
+			ci := model.ClientInfo{APIKeyID: "api-key-id"}
+			dbt := []byte(strings.TrimPrefix(link, linkPrefix))
+			loggedIn, err := a.LoginByLink(ci, model.LoginTypeEmail, usr.ID, tc.nonce(dbts.Nonce), dbt)
+			if tc.expUnauthErr {
+				if !a.IsUnauthorizedError(err) {
+					t.Fatalf("Expected an unauthorized error, got %v", err)
+				}
+				return
+			}
+			if tc.expAuthErr {
+				if !a.IsAuthError(err) {
+					t.Fatalf("Expected an auth error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbdErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if loggedIn.JWT == "" {
+				t.Errorf("Expected a JWT, got none")
+			}
+			if len(db.UsedMailTkns) != 1 || db.UsedMailTkns[0] != db.ExpMailTkns[0].ID {
+				t.Errorf("Expected token '%s' to be marked used, got %v",
+					db.ExpMailTkns[0].ID, db.UsedMailTkns)
+			}
+		})
+	}
+}
+
+func TestAuthentication_SendLoginLink_tenant(t *testing.T) {
+	tt := []struct {
+		name      string
+		tenantID  string
+		expSuffix string
+	}{
+		{name: "default tenant"},
+		{name: "other tenant", tenantID: "42", expSuffix: "?tenantID=42"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123",
+				Email: model.VerifLogin{ID: "1", UserID: "123", Address: "johndoe@example.com", Verified: true}}
+			db := &testingH.DBMock{ExpUsrBMail: usr, ExpUsr: usr}
+			mailer := &testingH.MailerMock{}
+			tpl := template.Must(template.New("email").Parse("{{.URLToken}}"))
+			a := newAuthentication(t, db, newJWTHandler(t),
+				model.WithEmailCl(mailer),
+				model.WithServiceURL("https://auth.example.com/api"),
+				model.WithVerifyEmailHost(false),
+				model.WithEmailInviteTplt(tpl, nil),
+				model.WithEmailVerifyTplt(tpl, nil),
+				model.WithEmailResetPassTplt(tpl, nil),
+				model.WithEmailLoginLinkTplt(tpl, nil),
+			).ForTenant(tc.tenantID)
+
+			if _, err := a.SendLoginLink(model.LoginTypeEmail, "johndoe@example.com"); err != nil {
+				t.Fatalf("Send login link: %v", err)
+			}
+			if len(mailer.SentMails) != 1 {
+				t.Fatalf("Expected 1 email sent, got %d", len(mailer.SentMails))
+			}
+			link := string(mailer.SentMails[0].Body)
+			if tc.expSuffix == "" && strings.Contains(link, "?") {
+				t.Errorf("Expected link without query, got '%s'", link)
+			}
+			if !strings.HasSuffix(link, tc.expSuffix) {
+				t.Errorf("Expected link suffixed with '%s', got '%s'", tc.expSuffix, link)
+			}
+		})
+	}
+}
+
+func TestAuthentication_LoginByOTP(t *testing.T) {
+	phone := "+254712345678"
+	storedPhone := "254712345678"
+	tt := []struct {
+		name         string
+		code         func(code string) string
+		prepTkns     func(tkns []model.DBToken)
+		expUnauthErr bool
+		expAuthErr   bool
+		expForbdErr  bool
+	}{
+		{name: "valid", code: func(c string) string { return c }, prepTkns: func([]model.DBToken) {}},
+		{
+			name:         "wrong code",
+			code:         func(c string) string { return c + "0" },
+			prepTkns:     func([]model.DBToken) {},
+			expUnauthErr: true,
+		},
+		{
+			name:         "code sent to another number",
+			code:         func(c string) string { return c },
+			prepTkns:     func(tkns []model.DBToken) { tkns[0].Address = "254700000000" },
+			expUnauthErr: true,
+		},
+		{
+			name:        "used code",
+			code:        func(c string) string { return c },
+			prepTkns:    func(tkns []model.DBToken) { tkns[0].IsUsed = true },
+			expForbdErr: true,
+		},
+		{
+			name:       "expired code",
+			code:       func(c string) string { return c },
+			prepTkns:   func(tkns []model.DBToken) { tkns[0].ExpiryDate = time.Now().Add(-time.Second) },
+			expAuthErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123",
+				Phone: model.VerifLogin{ID: "1", UserID: "123", Address: storedPhone, Verified: true}}
+			db := &testingH.DBMock{ExpUsrBPhn: usr}
+			smser := &testingH.SMSerMock{}
+			tpl := template.Must(template.New("sms").Parse("{{.Code}}"))
+			a := newAuthentication(t, db, newJWTHandler(t),
+				model.WithSMSCl(smser),
+				model.WithWebAppURL("https://app.example.com"),
+				model.WithPhoneInviteTplt(tpl, nil),
+				model.WithPhoneVerifyTplt(tpl, nil),
+				model.WithPhoneResetPassTplt(tpl, nil),
+				model.WithPhoneLoginTplt(tpl, nil),
+			)
+
+			if _, err := a.SendLoginOTP(model.LoginTypePhone, phone); err != nil {
+				t.Fatalf("Error setting up: send login OTP: %v", err)
+			}
+			if len(smser.SentSMSes) != 1 {
+				t.Fatalf("Expected 1 SMS sent, got %d", len(smser.SentSMSes))
+			}
+			code := smser.SentSMSes[0].Message
+			if len(db.InsertedPhnTkns) != 1 {
+				t.Fatalf("Expected only the code to be stored, got %d tokens",
+					len(db.InsertedPhnTkns))
+			}
+			db.ExpPhnTkns = db.InsertedPhnTkns
+			tc.prepTkns(db.ExpPhnTkns)
+
+			loggedIn, err := a.LoginByOTP(model.ClientInfo{}, model.LoginTypePhone, phone, []byte(tc.code(code)))
+			if tc.expUnauthErr {
+				if !a.IsUnauthorizedError(err) {
+					t.Fatalf("Expected an unauthorized error, got %v", err)
+				}
+				return
+			}
+			if tc.expAuthErr {
+				if !a.IsAuthError(err) {
+					t.Fatalf("Expected an auth error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbdErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if loggedIn.JWT == "" {
+				t.Errorf("Expected a JWT, got none")
+			}
+			if len(db.UsedPhnTkns) != 1 || db.UsedPhnTkns[0] != db.ExpPhnTkns[0].ID {
+				t.Errorf("Expected code '%s' to be marked used, got %v",
+					db.ExpPhnTkns[0].ID, db.UsedPhnTkns)
+			}
+		})
+	}
+}
+
+func TestAuthentication_SendLoginOTP_throttle(t *testing.T) {
+	phone := "+254712345678"
+	storedPhone := "254712345678"
+	recent := model.DBToken{Address: storedPhone, IssueDate: time.Now().Add(-5 * time.Minute)}
+	old := model.DBToken{Address: storedPhone, IssueDate: time.Now().Add(-time.Hour)}
+	otherPhone := model.DBToken{Address: "254700000000", IssueDate: time.Now()}
+	tt := []struct {
+		name        string
+		sent        []model.DBToken
+		opts        []model.Option
+		expForbdErr bool
+	}{
+		{name: "none sent", sent: nil},
+		{name: "below limit", sent: []model.DBToken{recent, recent, old, otherPhone}},
+		{name: "limit reached", sent: []model.DBToken{recent, recent, recent}, expForbdErr: true},
+		{
+			name: "custom limit",
+			sent: []model.DBToken{recent, recent, recent},
+			opts: []model.Option{model.WithLoginOTPThrottle(4, 15*time.Minute)},
+		},
+		{
+			name: "throttle disabled",
+			sent: []model.DBToken{recent, recent, recent},
+			opts: []model.Option{model.WithLoginOTPThrottle(0, 0)},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123",
+				Phone: model.VerifLogin{ID: "1", UserID: "123", Address: storedPhone, Verified: true}}
+			db := &testingH.DBMock{ExpUsrBPhn: usr, ExpPhnTkns: tc.sent}
+			smser := &testingH.SMSerMock{}
+			tpl := template.Must(template.New("sms").Parse("{{.Code}}"))
+			opts := append([]model.Option{
+				model.WithSMSCl(smser),
+				model.WithPhoneInviteTplt(tpl, nil),
+				model.WithPhoneVerifyTplt(tpl, nil),
+				model.WithPhoneResetPassTplt(tpl, nil),
+				model.WithPhoneLoginTplt(tpl, nil),
+			}, tc.opts...)
+			a := newAuthentication(t, db, newJWTHandler(t), opts...)
+			_, err := a.SendLoginOTP(model.LoginTypePhone, phone)
+			if tc.expForbdErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				if len(smser.SentSMSes) != 0 {
+					t.Errorf("Expected no SMS sent, got %d", len(smser.SentSMSes))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(smser.SentSMSes) != 1 {
+				t.Errorf("Expected 1 SMS sent, got %d", len(smser.SentSMSes))
+			}
+		})
+	}
+}
+
+func TestAuthentication_SendLoginLink_throttle(t *testing.T) {
+	email := "johndoe@example.com"
+	recent := model.DBToken{Address: email, IssueDate: time.Now().Add(-5 * time.Minute)}
+	old := model.DBToken{Address: email, IssueDate: time.Now().Add(-time.Hour)}
+	otherEmail := model.DBToken{Address: "janedoe@example.com", IssueDate: time.Now()}
+	tt := []struct {
+		name        string
+		sent        []model.DBToken
+		opts        []model.Option
+		expForbdErr bool
+	}{
+		{name: "none sent", sent: nil},
+		{name: "below limit", sent: []model.DBToken{recent, recent, old, otherEmail}},
+		{name: "limit reached", sent: []model.DBToken{recent, recent, recent}, expForbdErr: true},
+		{
+			name: "throttle disabled",
+			sent: []model.DBToken{recent, recent, recent},
+			opts: []model.Option{model.WithLoginOTPThrottle(0, 0)},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123",
+				Email: model.VerifLogin{ID: "1", UserID: "123", Address: email, Verified: true}}
+			db := &testingH.DBMock{ExpUsrBMail: usr, ExpUsr: usr, ExpMailTkns: tc.sent}
+			mailer := &testingH.MailerMock{}
+			tpl := template.Must(template.New("email").Parse("{{.URLToken}}"))
+			opts := append([]model.Option{
+				model.WithEmailCl(mailer),
+				model.WithServiceURL("https://auth.example.com/api"),
+				model.WithVerifyEmailHost(false),
+				model.WithEmailInviteTplt(tpl, nil),
+				model.WithEmailVerifyTplt(tpl, nil),
+				model.WithEmailResetPassTplt(tpl, nil),
+				model.WithEmailLoginLinkTplt(tpl, nil),
+			}, tc.opts...)
+			a := newAuthentication(t, db, newJWTHandler(t), opts...)
+			_, err := a.SendLoginLink(model.LoginTypeEmail, email)
+			if tc.expForbdErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				if len(mailer.SentMails) != 0 {
+					t.Errorf("Expected no email sent, got %d", len(mailer.SentMails))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(mailer.SentMails) != 1 {
+				t.Errorf("Expected 1 email sent, got %d", len(mailer.SentMails))
+			}
+		})
+	}
+}
+
+func TestAuthentication_DeleteUser(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	code := []byte("123456")
+	codeH, err := bcrypt.GenerateFromPassword(code, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test code: %v", err)
+	}
+	tpl := template.Must(template.New("sms").Parse("{{.Code}}"))
+	phone := "254712345678"
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "2", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	superGrp := model.Group{ID: "3", Name: model.GroupSuper, AccessLevel: model.AccessLevelSuper}
+	tt := []struct {
+		name             string
+		deleterGrp       model.Group
+		deleteOther      bool
+		deleteeGrp       model.Group
+		phnTkns          []model.DBToken
+		sendCode         bool
+		prepTkns         func([]model.DBToken)
+		confirmLoginType string
+		confirmation     []byte
+		expForbdErr      bool
+		expClErr         bool
+		expErr           bool
+	}{
+		{
+			name:             "self by password",
+			deleterGrp:       userGrp,
+			confirmLoginType: model.LoginTypeUsername,
+			confirmation:     pass,
+		},
+		{
+			name:             "self by phone code",
+			deleterGrp:       userGrp,
+			sendCode:         true,
+			confirmLoginType: model.LoginTypePhone,
+		},
+		{
+			name:             "self code sent for another action",
+			deleterGrp:       userGrp,
+			phnTkns:          []model.DBToken{{ID: "1", Address: phone, Token: codeH, ExpiryDate: time.Now().Add(time.Minute)}},
+			confirmLoginType: model.LoginTypePhone,
+			confirmation:     code,
+			expForbdErr:      true,
+		},
+		{
+			name:             "self used code",
+			deleterGrp:       userGrp,
+			sendCode:         true,
+			prepTkns:         func(tkns []model.DBToken) { tkns[0].IsUsed = true },
+			confirmLoginType: model.LoginTypePhone,
+			expForbdErr:      true,
+		},
+		{
+			name:             "self wrong password",
+			deleterGrp:       userGrp,
+			confirmLoginType: model.LoginTypeUsername,
+			confirmation:     []byte("another password"),
+			expForbdErr:      true,
+		},
+		{
+			name:             "self code sent to another number",
+			deleterGrp:       userGrp,
+			sendCode:         true,
+			prepTkns:         func(tkns []model.DBToken) { tkns[0].Address = "254700000000" },
+			confirmLoginType: model.LoginTypePhone,
+			expForbdErr:      true,
+		},
+		{
+			name:        "self without confirmation",
+			deleterGrp:  userGrp,
+			expForbdErr: true,
+		},
+		{name: "admin deletes other", deleterGrp: adminGrp, deleteOther: true, deleteeGrp: userGrp},
+		{
+			name:        "admin deletes super user",
+			deleterGrp:  adminGrp,
+			deleteOther: true,
+			deleteeGrp:  superGrp,
+			expForbdErr: true,
+		},
+		{
+			name:        "super user deletes last super user",
+			deleterGrp:  superGrp,
+			deleteOther: true,
+			deleteeGrp:  superGrp,
+			expClErr:    true,
+		},
+		{name: "user deletes other", deleterGrp: userGrp, deleteOther: true, deleteeGrp: userGrp, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			deleter := &model.User{ID: "123", Group: tc.deleterGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"},
+				Phone:    model.VerifLogin{ID: "1", UserID: "123", Address: phone, Verified: true}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: deleter, ExpUsrBUsrNmPass: passH}
+			smser := &testingH.SMSerMock{}
+			a := newAuthentication(t, db, newJWTHandler(t),
+				model.WithSMSCl(smser),
+				model.WithPhoneInviteTplt(tpl, nil),
+				model.WithPhoneVerifyTplt(tpl, nil),
+				model.WithPhoneResetPassTplt(tpl, nil),
+				model.WithPhoneLoginTplt(tpl, nil),
+			)
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			deletee := deleter
+			if tc.deleteOther {
+				deletee = &model.User{ID: "456", Group: tc.deleteeGrp}
+			}
+			db.ExpUsr = deletee
+			db.ExpUsrPass = passH
+			db.ExpPhnTkns = tc.phnTkns
+			db.ExpUsrs = []model.User{*deletee}
+
+			confirmation := tc.confirmation
+			if tc.sendCode {
+				if _, err := a.SendConfirmCode(loggedIn.JWT, deletee.ID, model.LoginTypePhone); err != nil {
+					t.Fatalf("Error setting up: send confirmation code: %v", err)
+				}
+				if len(smser.SentSMSes) != 1 {
+					t.Fatalf("Error setting up: expected 1 SMS sent, got %d", len(smser.SentSMSes))
+				}
+				confirmation = []byte(smser.SentSMSes[0].Message)
+				db.ExpPhnTkns = db.InsertedPhnTkns
+				if tc.prepTkns != nil {
+					tc.prepTkns(db.ExpPhnTkns)
+				}
+			}
+
+			err = a.DeleteUser(model.ClientInfo{}, loggedIn.JWT, deletee.ID, tc.confirmLoginType, confirmation)
+			if tc.expForbdErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.DeletedUsrs) != 1 || db.DeletedUsrs[0] != deletee.ID {
+				t.Errorf("Expected user '%s' deleted, got %v", deletee.ID, db.DeletedUsrs)
+			}
+			if tc.sendCode && (len(db.UsedPhnTkns) != 1 || db.UsedPhnTkns[0] != db.ExpPhnTkns[0].ID) {
+				t.Errorf("Expected the confirmation code marked used, got %v", db.UsedPhnTkns)
+			}
+		})
+	}
+}
+
+func TestAuthentication_DeleteUser_lockout(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	recentFlrs := []model.LoginFailure{
+		{CreateDate: time.Now().Add(-1 * time.Minute)},
+		{CreateDate: time.Now().Add(-2 * time.Minute)},
+	}
+	tt := []struct {
+		name         string
+		flrs         []model.LoginFailure
+		confirmation []byte
+		expFlrRecord bool
+		expDeleted   bool
+	}{
+		{name: "valid password", confirmation: pass, expDeleted: true},
+		{name: "wrong password recorded", confirmation: []byte("another password"), expFlrRecord: true},
+		{name: "locked out", flrs: recentFlrs, confirmation: pass},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: model.Group{ID: "2", Name: model.GroupUser, AccessLevel: model.AccessLevelUser},
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t), model.WithLoginLockout(2, 1*time.Hour))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpUsr = usr
+			db.ExpUsrPass = passH
+			db.ExpLgnFlrs = tc.flrs
+			db.InsertedHist = nil
+
+			ci := model.ClientInfo{IPAddress: "127.0.0.1"}
+			err = a.DeleteUser(ci, loggedIn.JWT, usr.ID, model.LoginTypeUsername, tc.confirmation)
+			if flrRecorded := len(db.InsertedLgnFlrs) > 0; tc.expFlrRecord != flrRecorded {
+				t.Errorf("Expected login failure recorded %t, got %t", tc.expFlrRecord, flrRecorded)
+			}
+			if tc.expDeleted {
+				if err != nil {
+					t.Fatalf("Got error: %v", err)
+				}
+				return
+			}
+			if !a.IsForbiddenError(err) {
+				t.Fatalf("Expected a forbidden error, got %v", err)
+			}
+			if len(db.DeletedUsrs) != 0 {
+				t.Errorf("Expected no user deleted, got %v", db.DeletedUsrs)
+			}
+			if tc.expFlrRecord && (len(db.InsertedHist) != 1 || db.InsertedHist[0].AccessType != model.AccessTypeConfirm) {
+				t.Errorf("Expected a failed %s attempt in history, got %+v", model.AccessTypeConfirm, db.InsertedHist)
+			}
+		})
+	}
+}
+
+func TestAuthentication_ExportUser(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "2", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	tt := []struct {
+		name        string
+		exporterGrp model.Group
+		exportOther bool
+		noUser      bool
+		expNotFound bool
+		expErr      bool
+	}{
+		{name: "self", exporterGrp: userGrp},
+		{name: "admin exports other", exporterGrp: adminGrp, exportOther: true},
+		{name: "user not found", exporterGrp: adminGrp, exportOther: true, noUser: true, expNotFound: true},
+		{name: "user exports other", exporterGrp: userGrp, exportOther: true, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := &model.User{ID: "123", Group: tc.exporterGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: exporter, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			exportee := &model.User{ID: "123", Group: tc.exporterGrp,
+				Devices: []model.Device{{ID: "1", UserID: "123", DeviceID: "a-device"}}}
+			if tc.exportOther {
+				exportee.ID = "456"
+			}
+			if !tc.noUser {
+				db.ExpUsr = exportee
+			}
+			db.ExpAPIKMetaBUsrID = []model.APIKey{{ID: "1", UserID: exportee.ID}}
+			db.ExpSesss = []model.Session{{ID: "1", UserID: exportee.ID}}
+			db.ExpRfrshTkns = []model.RefreshToken{{ID: "1", UserID: exportee.ID, Token: []byte("secret")}}
+			db.ExpMailTkns = []model.DBToken{{ID: "1", UserID: exportee.ID, Token: []byte("secret")}}
+			db.ExpPhnTkns = []model.DBToken{{ID: "1", UserID: exportee.ID, Token: []byte("secret")}}
+			db.ExpHist = []model.History{{ID: "1", UserID: exportee.ID}}
+			db.ExpLnkdIDs = []model.LinkedIdentity{{ID: "1", UserID: exportee.ID}}
+			db.ExpTOTPScrt = &model.TOTPSecret{ID: "1", UserID: exportee.ID,
+				Secret: []byte("secret"), IsConfirmed: true}
+			db.ExpLgnFlrs = []model.LoginFailure{{ID: "1", LoginType: model.LoginTypeMFA,
+				Identifier: exportee.ID, IPAddress: "127.0.0.1"}}
+
+			exp, err := a.ExportUser(loggedIn.JWT, exportee.ID)
+			if tc.expNotFound {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if exp.User.ID != exportee.ID || len(exp.User.Devices) != 1 {
+				t.Errorf("Expected user %+v, got %+v", exportee, exp.User)
+			}
+			if len(exp.APIKeys) != 1 || len(exp.Sessions) != 1 ||
+				len(exp.LoginHistory) != 1 {
+				t.Errorf("Expected 1 of each API key, session and history, got %+v", exp)
+			}
+			if len(exp.RefreshTokens) != 1 || len(exp.EmailTokens) != 1 ||
+				len(exp.PhoneTokens) != 1 {
+				t.Fatalf("Expected 1 of each issued token type, got %+v", exp)
+			}
+			if exp.RefreshTokens[0].Token != nil || exp.EmailTokens[0].Token != nil ||
+				exp.PhoneTokens[0].Token != nil {
+				t.Errorf("Expected token values removed, got %+v", exp)
+			}
+			if len(exp.LinkedIdentities) != 1 || len(exp.LoginFailures) != 1 {
+				t.Fatalf("Expected 1 of each linked identity and login failure, got %+v", exp)
+			}
+			if exp.LoginFailures[0].IPAddress != "" {
+				t.Errorf("Expected login failure IP address removed, got %+v", exp.LoginFailures[0])
+			}
+			if !exp.TOTP.HasValue() || !exp.TOTP.IsConfirmed {
+				t.Errorf("Expected confirmed TOTP enrollment, got %+v", exp.TOTP)
+			}
+			if exp.TOTP.Secret != nil {
+				t.Errorf("Expected TOTP secret removed, got %+v", exp.TOTP)
+			}
+			if exp.ExportDate.IsZero() {
+				t.Errorf("Expected export date set")
+			}
+		})
+	}
+}
+
+func TestAuthentication_SetUserStatus(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	superGrp := model.Group{ID: "1", Name: model.GroupSuper, AccessLevel: model.AccessLevelSuper}
+	adminGrp := model.Group{ID: "2", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "3", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	until := time.Now().Add(time.Hour).Format(config.TimeFormat)
+	tt := []struct {
+		name        string
+		setterGrp   model.Group
+		setSelf     bool
+		userGrp     model.Group
+		status      string
+		reason      string
+		until       string
+		expClErr    bool
+		expForbdErr bool
+		expErr      bool
+	}{
+		{name: "suspend", setterGrp: adminGrp, userGrp: userGrp, status: model.StatusSuspended, reason: "spamming", until: until},
+		{name: "reinstate", setterGrp: adminGrp, userGrp: userGrp, status: model.StatusActive},
+		{name: "pending", setterGrp: adminGrp, userGrp: userGrp, status: model.StatusPending},
+		{name: "super suspends admin", setterGrp: superGrp, userGrp: adminGrp, status: model.StatusSuspended},
+		{name: "invalid status", setterGrp: adminGrp, userGrp: userGrp, status: "banned", expClErr: true},
+		{name: "until in the past", setterGrp: adminGrp, userGrp: userGrp, status: model.StatusSuspended,
+			until: time.Now().Add(-time.Hour).Format(config.TimeFormat), expClErr: true},
+		{name: "active with until", setterGrp: adminGrp, userGrp: userGrp, status: model.StatusActive, until: until, expClErr: true},
+		{name: "own status", setterGrp: adminGrp, setSelf: true, status: model.StatusSuspended, expForbdErr: true},
+		{name: "user suspends other", setterGrp: userGrp, userGrp: userGrp, status: model.StatusSuspended, expErr: true},
+		{name: "admin suspends super", setterGrp: adminGrp, userGrp: superGrp, status: model.StatusSuspended, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			setter := &model.User{ID: "123", Group: tc.setterGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: setter, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			usr := setter
+			if !tc.setSelf {
+				usr = &model.User{ID: "456", Group: tc.userGrp}
+			}
+			db.ExpUsr = usr
+
+			upd, err := a.SetUserStatus(loggedIn.JWT, usr.ID, tc.status, tc.reason, tc.until)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbdErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.SetUsrStatuses) != 1 {
+				t.Fatalf("Expected 1 status set, got %d", len(db.SetUsrStatuses))
+			}
+			set := db.SetUsrStatuses[0]
+			if set.Value != tc.status || set.Reason != tc.reason {
+				t.Errorf("Expected status %s (%s), got %+v", tc.status, tc.reason, set)
+			}
+			if (tc.until == "") != set.Until.IsZero() {
+				t.Errorf("Expected until '%s', got %v", tc.until, set.Until)
+			}
+			if upd.Status != set {
+				t.Errorf("Expected returned user status %+v, got %+v", set, upd.Status)
+			}
+		})
+	}
+}
+
+func TestAuthentication_suspendedUser(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	userGrp := model.Group{ID: "1", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	tt := []struct {
+		name     string
+		status   model.UserStatus
+		expRefsd bool
+	}{
+		{name: "active", status: model.UserStatus{Value: model.StatusActive}},
+		{name: "pending", status: model.UserStatus{Value: model.StatusPending}},
+		{name: "suspended", status: model.UserStatus{Value: model.StatusSuspended}, expRefsd: true},
+		{
+			name:     "suspended until later",
+			status:   model.UserStatus{Value: model.StatusSuspended, Until: time.Now().Add(time.Hour)},
+			expRefsd: true,
+		},
+		{
+			name:   "suspension lapsed",
+			status: model.UserStatus{Value: model.StatusSuspended, Until: time.Now().Add(-time.Second)},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: userGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH, ExpUsr: usr}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			usr.Status = tc.status
+			db.ExpUsrStatus = &tc.status
+
+			_, err = a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if tc.expRefsd {
+				if !a.IsForbiddenError(err) {
+					t.Errorf("Login: expected a forbidden error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Login: got error: %v", err)
+			}
+
+			_, err = a.GetUserDetails(loggedIn.JWT, usr.ID)
+			if tc.expRefsd {
+				if !a.IsForbiddenError(err) {
+					t.Errorf("GetUserDetails: expected a forbidden error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("GetUserDetails: got error: %v", err)
+			}
+
+			ti, err := a.Introspect(loggedIn.JWT)
+			if err != nil {
+				t.Fatalf("Introspect: got error: %v", err)
+			}
+			if ti.Active == tc.expRefsd {
+				t.Errorf("Introspect: expected active %t, got %t", !tc.expRefsd, ti.Active)
+			}
+		})
+	}
+}
+
+func TestAuthentication_AddAddress(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "2", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	var maxEmails []model.VerifLogin
+	for i := 0; i < 5; i++ {
+		maxEmails = append(maxEmails, model.VerifLogin{Address: fmt.Sprintf("john%d@doe.com", i)})
+	}
+	tt := []struct {
+		name        string
+		adderGrp    model.Group
+		addToOther  bool
+		loginType   string
+		address     string
+		expAddress  string
+		existing    []model.VerifLogin
+		addrTaken   bool
+		expClErr    bool
+		expConflErr bool
+		expErr      bool
+	}{
+		{name: "email", adderGrp: userGrp, loginType: model.LoginTypeEmail,
+			address: "john@doe.com", expAddress: "john@doe.com"},
+		{name: "phone", adderGrp: userGrp, loginType: model.LoginTypePhone,
+			address: "+254712345678", expAddress: "254712345678"},
+		{name: "admin adds to other", adderGrp: adminGrp, addToOther: true,
+			loginType: model.LoginTypeEmail, address: "john@doe.com", expAddress: "john@doe.com"},
+		{name: "user adds to other", adderGrp: userGrp, addToOther: true,
+			loginType: model.LoginTypeEmail, address: "john@doe.com", expErr: true},
+		{name: "address taken", adderGrp: userGrp, loginType: model.LoginTypeEmail,
+			address: "john@doe.com", addrTaken: true, expConflErr: true},
+		{name: "too many addresses", adderGrp: userGrp, loginType: model.LoginTypeEmail,
+			address: "john@doe.com", existing: maxEmails, expClErr: true},
+		{name: "bad address", adderGrp: userGrp, loginType: model.LoginTypeEmail,
+			address: "not an email", expClErr: true},
+		{name: "unsupported loginType", adderGrp: userGrp, loginType: model.LoginTypeUsername,
+			address: "johndoe", expClErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			adder := &model.User{ID: "123", Group: tc.adderGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: adder, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t), model.WithVerifyEmailHost(false))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			usr := adder
+			if tc.addToOther {
+				usr = &model.User{ID: "456", Group: userGrp}
+			}
+			usr.Emails = tc.existing
+			db.ExpUsr = usr
+			if tc.addrTaken {
+				db.ExpUsrBMail = &model.User{ID: "789"}
+			}
+
+			vl, err := a.AddAddress(loggedIn.JWT, usr.ID, tc.loginType, tc.address)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expConflErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if vl.Address != tc.expAddress || vl.UserID != usr.ID {
+				t.Errorf("Expected %s for user %s, got %+v", tc.expAddress, usr.ID, vl)
+			}
+			if vl.Verified {
+				t.Errorf("Expected added address to be unverified")
+			}
+		})
+	}
+}
+
+func TestAuthentication_RemoveAddress(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	primary := model.VerifLogin{ID: "1", UserID: "123", Address: "john@doe.com", IsPrimary: true}
+	other := model.VerifLogin{ID: "2", UserID: "123", Address: "jane@doe.com"}
+	tt := []struct {
+		name      string
+		loginType string
+		address   string
+		expClErr  bool
+	}{
+		{name: "secondary", loginType: model.LoginTypeEmail, address: other.Address},
+		{name: "primary", loginType: model.LoginTypeEmail, address: primary.Address, expClErr: true},
+		{name: "bad phone", loginType: model.LoginTypePhone, address: "not a phone", expClErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Email: primary, Emails: []model.VerifLogin{primary, other},
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t), model.WithVerifyEmailHost(false))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			err = a.RemoveAddress(loggedIn.JWT, usr.ID, tc.loginType, tc.address)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.DeletedMails) != 1 || db.DeletedMails[0] != tc.address {
+				t.Errorf("Expected %s deleted, got %v", tc.address, db.DeletedMails)
+			}
+		})
+	}
+}
+
+func TestAuthentication_SetPrimaryAddress(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	primary := model.VerifLogin{ID: "1", UserID: "123", Address: "254712345678", Verified: true, IsPrimary: true}
+	verified := model.VerifLogin{ID: "2", UserID: "123", Address: "254712345679", Verified: true}
+	unverified := model.VerifLogin{ID: "3", UserID: "123", Address: "254712345670"}
+	tt := []struct {
+		name      string
+		loginType string
+		address   string
+		expClErr  bool
+		expNFErr  bool
+	}{
+		{name: "verified", loginType: model.LoginTypePhone, address: "+254712345679"},
+		{name: "already primary", loginType: model.LoginTypePhone, address: primary.Address},
+		{name: "unverified", loginType: model.LoginTypePhone, address: unverified.Address, expClErr: true},
+		{name: "not user's", loginType: model.LoginTypePhone, address: "254712345671", expNFErr: true},
+		{name: "unsupported loginType", loginType: model.LoginTypeFacebook, address: "123", expClErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Phone: primary,
+				Phones:   []model.VerifLogin{primary, verified, unverified},
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			_, err = a.SetPrimaryAddress(loggedIn.JWT, usr.ID, tc.loginType, tc.address)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expNFErr {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.PrimaryPhns) != 1 {
+				t.Fatalf("Expected 1 primary set, got %v", db.PrimaryPhns)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Login_secondaryAddress(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	primary := model.VerifLogin{ID: "1", UserID: "123", Address: "john@doe.com", IsPrimary: true}
+	verified := model.VerifLogin{ID: "2", UserID: "123", Address: "jd@doe.com", Verified: true}
+	unverified := model.VerifLogin{ID: "3", UserID: "123", Address: "johnd@doe.com"}
+	tt := []struct {
+		name    string
+		address string
+		expErr  bool
+	}{
+		{name: "primary", address: primary.Address},
+		{name: "verified secondary", address: verified.Address},
+		{name: "unverified secondary", address: unverified.Address, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Email: primary,
+				Emails: []model.VerifLogin{primary, verified, unverified}}
+			db := &testingH.DBMock{ExpUsrBMail: usr, ExpUsrBMailPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t), model.WithVerifyEmailHost(false))
+			_, err := a.Login(model.ClientInfo{}, model.LoginTypeEmail, tc.address, pass)
+			if tc.expErr {
+				if !a.IsAuthError(err) {
+					t.Fatalf("Expected an auth error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_LinkIdentity(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	userGrp := model.Group{ID: "1", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	tt := []struct {
+		name        string
+		loginType   string
+		identifier  string
+		secret      []byte
+		noFb        bool
+		fbErr       error
+		hasFb       bool
+		fbTaken     bool
+		linkToOther bool
+		expConflErr bool
+		expNImplErr bool
+		expClErr    bool
+		expErr      bool
+	}{
+		{name: "facebook", loginType: model.LoginTypeFacebook, identifier: "fb-token"},
+		{name: "username", loginType: model.LoginTypeUsername, identifier: "johndoe",
+			secret: []byte("another valid password")},
+		{name: "username without password", loginType: model.LoginTypeUsername,
+			identifier: "johndoe", expClErr: true},
+		{name: "oidc", loginType: model.LoginTypeOIDC, identifier: "id-token",
+			secret: []byte("a-nonce")},
+		{name: "facebook already linked", loginType: model.LoginTypeFacebook,
+			identifier: "fb-token", hasFb: true, expConflErr: true},
+		{name: "facebook linked to other user", loginType: model.LoginTypeFacebook,
+			identifier: "fb-token", fbTaken: true, expConflErr: true},
+		{name: "invalid facebook token", loginType: model.LoginTypeFacebook,
+			identifier: "fb-token", fbErr: errors.New("invalid token"), expErr: true},
+		{name: "facebook not available", loginType: model.LoginTypeFacebook,
+			identifier: "fb-token", noFb: true, expNImplErr: true},
+		{name: "link to other user", loginType: model.LoginTypeFacebook,
+			identifier: "fb-token", linkToOther: true, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			linker := &model.User{ID: "123", Group: userGrp,
+				Phone: model.VerifLogin{ID: "1", UserID: "123", Address: "254712345678"}}
+			db := &testingH.DBMock{ExpUsrBPhn: linker, ExpUsrBPhnPass: passH}
+			oidcCl := &testingH.OIDCMock{ExpIssuer: "https://idp.test", ExpSubject: "123"}
+			opts := []model.Option{model.WithOIDCCl(oidcCl)}
+			if !tc.noFb {
+				opts = append(opts, model.WithFacebookCl(&testingH.FacebookMock{ExpValTknErr: tc.fbErr}))
+			}
+			a := newAuthentication(t, db, newJWTHandler(t), opts...)
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypePhone, "+254712345678", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			usr := linker
+			if tc.linkToOther {
+				usr = &model.User{ID: "456", Group: userGrp}
+			}
+			if tc.hasFb {
+				usr.Facebook = model.Facebook{ID: "1", UserID: usr.ID, FacebookID: "987"}
+			}
+			if tc.fbTaken {
+				db.ExpUsrBFb = &model.User{ID: "789"}
+			}
+			db.ExpUsr = usr
+
+			linked, err := a.LinkIdentity(loggedIn.JWT, usr.ID, tc.loginType, tc.identifier, tc.secret)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expConflErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expNImplErr {
+				if !a.IsNotImplementedError(err) {
+					t.Fatalf("Expected a not implemented error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			switch tc.loginType {
+			case model.LoginTypeFacebook:
+				if !linked.Facebook.HasValue() {
+					t.Errorf("Expected facebook to be linked, got %+v", linked.Facebook)
+				}
+			case model.LoginTypeUsername:
+				if linked.UserName.Value != tc.identifier {
+					t.Errorf("Expected username %s, got %+v", tc.identifier, linked.UserName)
+				}
+				if len(db.UpdatedPasses) == 0 {
+					t.Errorf("Expected the password to be set")
+				}
+			case model.LoginTypeOIDC:
+				if oidcCl.ValTknNonce != string(tc.secret) {
+					t.Errorf("Expected id_token validated with nonce %q, got %q",
+						tc.secret, oidcCl.ValTknNonce)
+				}
+			}
+			if !linked.Phone.HasValue() {
+				t.Errorf("Expected phone to remain linked")
+			}
+		})
+	}
+}
+
+func TestAuthentication_UnlinkIdentity(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	userGrp := model.Group{ID: "1", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	fb := model.Facebook{ID: "1", UserID: "123", FacebookID: "987"}
+	phone := model.VerifLogin{ID: "1", UserID: "123", Address: "254712345678"}
+	lis := []model.LinkedIdentity{
+		{ID: "1", UserID: "123", Issuer: "https://accounts.google.com", Subject: "1"},
+		{ID: "2", UserID: "123", Issuer: "https://login.microsoftonline.com", Subject: "2"},
+	}
+	tt := []struct {
+		name      string
+		loginType string
+		phone     model.VerifLogin
+		fb        model.Facebook
+		lis       []model.LinkedIdentity
+		expClErr  bool
+		expNFErr  bool
+	}{
+		{name: "facebook", loginType: model.LoginTypeFacebook, phone: phone, fb: fb},
+		{name: "oidc", loginType: model.LoginTypeOIDC, fb: fb, lis: lis},
+		{name: "username", loginType: model.LoginTypeUsername, fb: fb},
+		{name: "only facebook", loginType: model.LoginTypeFacebook, fb: fb, expClErr: true},
+		{name: "only oidc", loginType: model.LoginTypeOIDC, lis: lis, expClErr: true},
+		{name: "facebook not linked", loginType: model.LoginTypeFacebook, phone: phone, expNFErr: true},
+		{name: "phone", loginType: model.LoginTypePhone, phone: phone, fb: fb, expClErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: userGrp, Phone: tc.phone, Facebook: tc.fb,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpLnkdIDs = tc.lis
+			if tc.loginType != model.LoginTypeUsername {
+				// the username is only there to log in with.
+				usr.UserName = model.Username{}
+			}
+
+			err = a.UnlinkIdentity(loggedIn.JWT, usr.ID, tc.loginType)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expNFErr {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.Unlinked) != 1 || db.Unlinked[0] != tc.loginType {
+				t.Errorf("Expected %s unlinked, got %v", tc.loginType, db.Unlinked)
+			}
+		})
+	}
+}
+
+func TestAuthentication_CreateGroup(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	staffGrp := model.Group{ID: "2", Name: model.GroupStaff, AccessLevel: model.AccessLevelStaff}
+	tt := []struct {
+		name           string
+		usrGrp         model.Group
+		grpName        string
+		accessLevel    float32
+		grpBNm         *model.Group
+		expClErr       bool
+		expConflictErr bool
+		expForbidErr   bool
+	}{
+		{name: "valid", usrGrp: adminGrp, grpName: " auditors ", accessLevel: 6.5},
+		{name: "empty name", usrGrp: adminGrp, grpName: " ", accessLevel: 6.5, expClErr: true},
+		{name: "built in name", usrGrp: adminGrp, grpName: model.GroupStaff, accessLevel: 6.5, expConflictErr: true},
+		{name: "access level out of range", usrGrp: adminGrp, grpName: "auditors", accessLevel: 10.5, expClErr: true},
+		{name: "more privileged than creator", usrGrp: adminGrp, grpName: "auditors", accessLevel: 2, expForbidErr: true},
+		{name: "non-admin", usrGrp: staffGrp, grpName: "auditors", accessLevel: 8, expForbidErr: true},
+		{
+			name:           "name taken",
+			usrGrp:         adminGrp,
+			grpName:        "auditors",
+			accessLevel:    6.5,
+			grpBNm:         &model.Group{ID: "6", Name: "auditors", AccessLevel: 6},
+			expConflictErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpGrpBNm = tc.grpBNm
+
+			grp, err := a.CreateGroup(loggedIn.JWT, tc.grpName, tc.accessLevel)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if grp == nil || grp.Name != "auditors" || grp.AccessLevel != tc.accessLevel {
+				t.Errorf("Expected group auditors with access level %f, got %+v",
+					tc.accessLevel, grp)
+			}
+		})
+	}
+}
+
+func TestAuthentication_UpdateGroup(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	customGrp := &model.Group{ID: "6", Name: "auditors", AccessLevel: 6}
+	tt := []struct {
+		name           string
+		grp            *model.Group
+		grpID          string
+		grpName        string
+		accessLevel    float32
+		grpBNm         *model.Group
+		expClErr       bool
+		expConflictErr bool
+		expForbidErr   bool
+		expNFErr       bool
+	}{
+		{name: "valid", grp: customGrp, grpID: "6", grpName: "reviewers", accessLevel: 6.5},
+		{name: "same name", grp: customGrp, grpID: "6", grpName: "auditors", accessLevel: 6.5, grpBNm: customGrp},
+		{name: "empty group ID", grp: customGrp, grpName: "reviewers", accessLevel: 6.5, expClErr: true},
+		{name: "group not found", grpID: "6", grpName: "reviewers", accessLevel: 6.5, expNFErr: true},
+		{
+			name:         "built in group",
+			grp:          &model.Group{ID: "2", Name: model.GroupStaff, AccessLevel: model.AccessLevelStaff},
+			grpID:        "2",
+			grpName:      "reviewers",
+			accessLevel:  6.5,
+			expForbidErr: true,
+		},
+		{
+			name:         "group more privileged than updater",
+			grp:          &model.Group{ID: "7", Name: "owners", AccessLevel: 2},
+			grpID:        "7",
+			grpName:      "reviewers",
+			accessLevel:  6.5,
+			expForbidErr: true,
+		},
+		{
+			name:           "name taken",
+			grp:            customGrp,
+			grpID:          "6",
+			grpName:        "reviewers",
+			accessLevel:    6.5,
+			grpBNm:         &model.Group{ID: "8", Name: "reviewers", AccessLevel: 6},
+			expConflictErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: adminGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpGrp = tc.grp
+			db.ExpGrpBNm = tc.grpBNm
+
+			grp, err := a.UpdateGroup(loggedIn.JWT, tc.grpID, tc.grpName, tc.accessLevel)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expNFErr {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if grp == nil || grp.ID != tc.grpID || grp.Name != tc.grpName ||
+				grp.AccessLevel != tc.accessLevel {
+				t.Errorf("Expected group %s updated to %s with access level %f, got %+v",
+					tc.grpID, tc.grpName, tc.accessLevel, grp)
+			}
+		})
+	}
+}
+
+func TestAuthentication_DeleteGroup(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	customGrp := &model.Group{ID: "6", Name: "auditors", AccessLevel: 6}
+	tt := []struct {
+		name           string
+		grp            *model.Group
+		hasUsersErr    error
+		expConflictErr bool
+		expForbidErr   bool
+		expNFErr       bool
+	}{
+		{name: "valid", grp: customGrp, hasUsersErr: typederrs.NewNotFound("no users")},
+		{name: "has users", grp: customGrp, expConflictErr: true},
+		{name: "group not found", expNFErr: true},
+		{
+			name:         "built in group",
+			grp:          &model.Group{ID: "2", Name: model.GroupStaff, AccessLevel: model.AccessLevelStaff},
+			hasUsersErr:  typederrs.NewNotFound("no users"),
+			expForbidErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: adminGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpGrp = tc.grp
+			db.ExpHasUsrsErr = tc.hasUsersErr
+
+			err = a.DeleteGroup(loggedIn.JWT, "6")
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expNFErr {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.DeletedGrps) != 1 || db.DeletedGrps[0] != customGrp.ID {
+				t.Errorf("Expected group %s deleted, got %v", customGrp.ID, db.DeletedGrps)
+			}
+		})
+	}
+}
+
+func TestAuthentication_SetGroupPermissions(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	managersGrp := model.Group{ID: "5", Name: "managers", AccessLevel: 8,
+		Permissions: []string{model.PermGroupsWrite}}
+	customGrp := &model.Group{ID: "6", Name: "auditors", AccessLevel: 9}
+	superGrp := model.Group{ID: "8", Name: model.GroupSuper, AccessLevel: model.AccessLevelSuper}
+	tt := []struct {
+		name         string
+		tenantID     string
+		usrGrp       model.Group
+		grp          *model.Group
+		perms        []string
+		expPerms     []string
+		expClErr     bool
+		expForbidErr bool
+	}{
+		{
+			name:     "admin",
+			usrGrp:   adminGrp,
+			grp:      customGrp,
+			perms:    []string{model.PermUsersRead, model.PermGroupsRead},
+			expPerms: []string{model.PermUsersRead, model.PermGroupsRead},
+		},
+		{
+			name:     "duplicates",
+			usrGrp:   adminGrp,
+			grp:      customGrp,
+			perms:    []string{model.PermUsersRead, model.PermUsersRead},
+			expPerms: []string{model.PermUsersRead},
+		},
+		{name: "revoke all", usrGrp: adminGrp, grp: customGrp, perms: nil, expPerms: []string{}},
+		{
+			name:     "granter holds permission",
+			usrGrp:   managersGrp,
+			grp:      customGrp,
+			perms:    []string{model.PermGroupsWrite},
+			expPerms: []string{model.PermGroupsWrite},
+		},
+		{
+			name:         "granter lacks permission",
+			usrGrp:       managersGrp,
+			grp:          customGrp,
+			perms:        []string{model.PermUsersWrite},
+			expForbidErr: true,
+		},
+		{
+			name:         "group more privileged than granter",
+			usrGrp:       managersGrp,
+			grp:          &model.Group{ID: "7", Name: "owners", AccessLevel: 6},
+			perms:        []string{model.PermGroupsWrite},
+			expForbidErr: true,
+		},
+		{
+			name:         "built in group",
+			usrGrp:       adminGrp,
+			grp:          &model.Group{ID: "2", Name: model.GroupStaff, AccessLevel: model.AccessLevelStaff},
+			perms:        []string{model.PermUsersRead},
+			expForbidErr: true,
+		},
+		{name: "unknown permission", usrGrp: adminGrp, grp: customGrp, perms: []string{"users:*"}, expClErr: true},
+		{
+			name:     "tenants:all by default tenant super",
+			usrGrp:   superGrp,
+			grp:      customGrp,
+			perms:    []string{model.PermTenantsAll},
+			expPerms: []string{model.PermTenantsAll},
+		},
+		{
+			name:         "tenants:all by admin",
+			usrGrp:       adminGrp,
+			grp:          customGrp,
+			perms:        []string{model.PermTenantsAll},
+			expForbidErr: true,
+		},
+		{
+			name:         "tenants:all by other tenant super",
+			tenantID:     "42",
+			usrGrp:       superGrp,
+			grp:          customGrp,
+			perms:        []string{model.PermTenantsAll},
+			expForbidErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t)).ForTenant(tc.tenantID)
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			db.ExpGrp = tc.grp
+
+			grp, err := a.SetGroupPermissions(loggedIn.JWT, tc.grp.ID, tc.perms)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if fmt.Sprint(grp.Permissions) != fmt.Sprint(tc.expPerms) {
+				t.Errorf("Expected permissions %v, got %v", tc.expPerms, grp.Permissions)
+			}
+			if fmt.Sprint(db.GrantedPermissions) != fmt.Sprint(tc.expPerms) {
+				t.Errorf("Expected permissions %v stored, got %v",
+					tc.expPerms, db.GrantedPermissions)
+			}
+		})
+	}
+}
+
+func TestAuthentication_GetUserDetails_permissions(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	tt := []struct {
+		name         string
+		usrGrp       model.Group
+		expForbidErr bool
+	}{
+		{
+			name: "granted permission",
+			usrGrp: model.Group{ID: "6", Name: "auditors", AccessLevel: 9,
+				Permissions: []string{model.PermUsersRead}},
+		},
+		{
+			name:   "access level fallback",
+			usrGrp: model.Group{ID: "3", Name: model.GroupStaff, AccessLevel: model.AccessLevelStaff},
+		},
+		{
+			name: "other permission",
+			usrGrp: model.Group{ID: "6", Name: "auditors", AccessLevel: 9,
+				Permissions: []string{model.PermGroupsRead}},
+			expForbidErr: true,
+		},
+		{
+			name:         "no permission",
+			usrGrp:       model.Group{ID: "4", Name: model.GroupUser, AccessLevel: model.AccessLevelUser},
+			expForbidErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			_, err = a.GetUserDetails(loggedIn.JWT, "456")
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_RegisterSelf_userType(t *testing.T) {
+	tt := []struct {
+		name         string
+		userType     string
+		storedUT     *model.UserType
+		expInserted  bool
+		expClErr     bool
+		expForbidErr bool
+	}{
+		{name: "built in created as needed", userType: model.UserTypeCompany, expInserted: true},
+		{name: "unknown", userType: "partner", expClErr: true},
+		{
+			name:     "custom",
+			userType: "partner",
+			storedUT: &model.UserType{ID: "3", Name: "partner", SelfRegistrable: true},
+		},
+		{
+			name:         "not self registrable",
+			userType:     "partner",
+			storedUT:     &model.UserType{ID: "3", Name: "partner"},
+			expForbidErr: true,
+		},
+		{
+			name:     "required login type satisfied",
+			userType: "partner",
+			storedUT: &model.UserType{ID: "3", Name: "partner", SelfRegistrable: true,
+				RequiredLoginTypes: []string{model.LoginTypeUsername}},
+		},
+		{
+			name:     "required login type missing",
+			userType: "partner",
+			storedUT: &model.UserType{ID: "3", Name: "partner", SelfRegistrable: true,
+				RequiredLoginTypes: []string{model.LoginTypeEmail}},
+			expClErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &testingH.DBMock{ExpUsrTypBNm: tc.storedUT}
+			a := newAuthentication(t, db, &testingH.JWTMock{})
+			usr, err := a.RegisterSelf(model.LoginTypeUsername, tc.userType, "johndoe",
+				[]byte("a valid password"))
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if usr.Type.Name != tc.userType {
+				t.Errorf("Expected user type '%s', got '%s'", tc.userType, usr.Type.Name)
+			}
+			if tc.expInserted != (len(db.InsertedUsrTyps) == 1) {
+				t.Errorf("Expected user type inserted %t, got %v",
+					tc.expInserted, db.InsertedUsrTyps)
+			}
+		})
+	}
+}
+
+func TestAuthentication_CreateUserType(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	staffGrp := model.Group{ID: "2", Name: model.GroupStaff, AccessLevel: model.AccessLevelStaff}
+	tt := []struct {
+		name           string
+		usrGrp         model.Group
+		utName         string
+		reqLoginTypes  []string
+		takenUT        *model.UserType
+		expReqLgnTps   []string
+		expClErr       bool
+		expConflictErr bool
+		expForbidErr   bool
+	}{
+		{
+			name:          "valid",
+			usrGrp:        adminGrp,
+			utName:        " partner ",
+			reqLoginTypes: []string{model.LoginTypeEmail, model.LoginTypeEmail},
+			expReqLgnTps:  []string{model.LoginTypeEmail},
+		},
+		{name: "empty name", usrGrp: adminGrp, utName: " ", expClErr: true},
+		{
+			name:          "invalid required login type",
+			usrGrp:        adminGrp,
+			utName:        "partner",
+			reqLoginTypes: []string{model.LoginTypeMFA},
+			expClErr:      true,
+		},
+		{name: "reserved name", usrGrp: adminGrp, utName: model.UserTypeCompany, expConflictErr: true},
+		{
+			name:           "name taken",
+			usrGrp:         adminGrp,
+			utName:         "partner",
+			takenUT:        &model.UserType{ID: "3", Name: "partner"},
+			expConflictErr: true,
+		},
+		{name: "staff", usrGrp: staffGrp, utName: "partner", expForbidErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpUsrTypBNm: tc.takenUT}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			ut, err := a.CreateUserType(loggedIn.JWT, tc.utName, true, tc.reqLoginTypes)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if ut.Name != strings.TrimSpace(tc.utName) || !ut.SelfRegistrable {
+				t.Errorf("Expected self registrable user type '%s', got %+v",
+					strings.TrimSpace(tc.utName), ut)
+			}
+			if fmt.Sprint(ut.RequiredLoginTypes) != fmt.Sprint(tc.expReqLgnTps) {
+				t.Errorf("Expected required login types %v, got %v",
+					tc.expReqLgnTps, ut.RequiredLoginTypes)
+			}
+		})
+	}
+}
+
+func TestAuthentication_UpdateUserType(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	customUT := &model.UserType{ID: "3", Name: "partner"}
+	companyUT := &model.UserType{ID: "2", Name: model.UserTypeCompany}
+	tt := []struct {
+		name           string
+		ut             *model.UserType
+		utName         string
+		takenUT        *model.UserType
+		expConflictErr bool
+		expForbidErr   bool
+		expNFErr       bool
+	}{
+		{name: "rename custom", ut: customUT, utName: "reseller"},
+		{name: "built in flags", ut: companyUT, utName: model.UserTypeCompany, takenUT: companyUT},
+		{name: "rename built in", ut: companyUT, utName: "business", expForbidErr: true},
+		{name: "take built in name", ut: customUT, utName: model.UserTypeIndividual, expConflictErr: true},
+		{
+			name:           "name taken",
+			ut:             customUT,
+			utName:         "reseller",
+			takenUT:        &model.UserType{ID: "4", Name: "reseller"},
+			expConflictErr: true,
+		},
+		{name: "not found", utName: "reseller", expNFErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: adminGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpUsrTyp: tc.ut, ExpUsrTypBNm: tc.takenUT}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			ut, err := a.UpdateUserType(loggedIn.JWT, "3", tc.utName, false,
+				[]string{model.LoginTypeEmail})
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expNFErr {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if ut.ID != tc.ut.ID || ut.Name != tc.utName {
+				t.Errorf("Expected user type %s named '%s', got %+v", tc.ut.ID, tc.utName, ut)
+			}
+		})
+	}
+}
+
+func TestAuthentication_DeleteUserType(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	customUT := &model.UserType{ID: "3", Name: "partner"}
+	tt := []struct {
+		name           string
+		ut             *model.UserType
+		hasUsersErr    error
+		expConflictErr bool
+		expForbidErr   bool
+		expNFErr       bool
+	}{
+		{name: "valid", ut: customUT, hasUsersErr: typederrs.NewNotFound("no users")},
+		{name: "has users", ut: customUT, expConflictErr: true},
+		{name: "user type not found", expNFErr: true},
+		{
+			name:         "built in user type",
+			ut:           &model.UserType{ID: "1", Name: model.UserTypeIndividual},
+			hasUsersErr:  typederrs.NewNotFound("no users"),
+			expForbidErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: adminGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpUsrTyp: tc.ut, ExpHasUsrsOfTypErr: tc.hasUsersErr}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			err = a.DeleteUserType(loggedIn.JWT, "3")
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expNFErr {
+				if !a.IsNotFoundError(err) {
+					t.Fatalf("Expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(db.DeletedUsrTyps) != 1 || db.DeletedUsrTyps[0] != customUT.ID {
+				t.Errorf("Expected user type %s deleted, got %v", customUT.ID, db.DeletedUsrTyps)
+			}
+		})
+	}
+}
+
+const testAttrSchema = `{
+	"type": "object",
+	"properties": {
+		"firstName": {"type": "string", "maxLength": 20},
+		"locale": {"type": "string", "enum": ["en", "sw"]}
+	},
+	"additionalProperties": false
+}`
+
+func TestAuthentication_SetAttributeSchema(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "4", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	tt := []struct {
+		name         string
+		usrGrp       model.Group
+		schema       string
+		claimAttrs   []string
+		expClErr     bool
+		expForbidErr bool
+	}{
+		{name: "valid", usrGrp: adminGrp, schema: testAttrSchema, claimAttrs: []string{"locale"}},
+		{name: "no claim attributes", usrGrp: adminGrp, schema: testAttrSchema},
+		{name: "malformed schema", usrGrp: adminGrp, schema: `{"type":`, expClErr: true},
+		{name: "not an object schema", usrGrp: adminGrp, schema: `{"type": "string"}`, expClErr: true},
+		{
+			name:       "undeclared claim attribute",
+			usrGrp:     adminGrp,
+			schema:     testAttrSchema,
+			claimAttrs: []string{"lastName"},
+			expClErr:   true,
+		},
+		{name: "not admin", usrGrp: userGrp, schema: testAttrSchema, expForbidErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			as, err := a.SetAttributeSchema(loggedIn.JWT, []byte(tc.schema), tc.claimAttrs)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if string(as.Schema) != tc.schema {
+				t.Errorf("Expected schema %s, got %s", tc.schema, as.Schema)
+			}
+			if db.UpsertedAttrSchema == nil {
+				t.Fatalf("Expected attribute schema upserted")
+			}
+			if len(db.UpsertedAttrSchema.ClaimAttributes) != len(tc.claimAttrs) {
+				t.Errorf("Expected claim attributes %v, got %v", tc.claimAttrs,
+					db.UpsertedAttrSchema.ClaimAttributes)
+			}
+		})
+	}
+}
+
+func TestAuthentication_SetUserAttributes(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	userGrp := model.Group{ID: "4", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	schema := &model.AttributeSchema{Schema: []byte(testAttrSchema)}
+	clmSchema := &model.AttributeSchema{Schema: []byte(testAttrSchema), ClaimAttributes: []string{"locale"}}
+	validAttrs := map[string]interface{}{"firstName": "John", "locale": "sw"}
+	tt := []struct {
+		name              string
+		usrGrp            model.Group
+		userID            string
+		schema            *model.AttributeSchema
+		storedAttrs       map[string]interface{}
+		attrs             map[string]interface{}
+		expClErr          bool
+		expForbidErr      bool
+		expNotImplemented bool
+	}{
+		{name: "own attributes", usrGrp: userGrp, userID: "123", schema: schema, attrs: validAttrs},
+		{name: "clear attributes", usrGrp: userGrp, userID: "123", schema: schema},
+		{name: "admin other user", usrGrp: adminGrp, userID: "456", schema: schema, attrs: validAttrs},
+		{
+			name:         "other user",
+			usrGrp:       userGrp,
+			userID:       "456",
+			schema:       schema,
+			attrs:        validAttrs,
+			expForbidErr: true,
+		},
+		{
+			name:        "own claim attribute unchanged",
+			usrGrp:      userGrp,
+			userID:      "123",
+			schema:      clmSchema,
+			storedAttrs: map[string]interface{}{"firstName": "Jane", "locale": "sw"},
+			attrs:       validAttrs,
+		},
+		{
+			name:         "own claim attribute changed",
+			usrGrp:       userGrp,
+			userID:       "123",
+			schema:       clmSchema,
+			storedAttrs:  map[string]interface{}{"firstName": "John", "locale": "en"},
+			attrs:        validAttrs,
+			expForbidErr: true,
+		},
+		{
+			name:         "own claim attribute added",
+			usrGrp:       userGrp,
+			userID:       "123",
+			schema:       clmSchema,
+			storedAttrs:  map[string]interface{}{"firstName": "John"},
+			attrs:        validAttrs,
+			expForbidErr: true,
+		},
+		{
+			name:        "admin own claim attribute changed",
+			usrGrp:      adminGrp,
+			userID:      "123",
+			schema:      clmSchema,
+			storedAttrs: map[string]interface{}{"firstName": "John", "locale": "en"},
+			attrs:       validAttrs,
+		},
+		{
+			name:     "violates schema",
+			usrGrp:   userGrp,
+			userID:   "123",
+			schema:   schema,
+			attrs:    map[string]interface{}{"locale": "fr"},
+			expClErr: true,
+		},
+		{
+			name:     "undeclared attribute",
+			usrGrp:   userGrp,
+			userID:   "123",
+			schema:   schema,
+			attrs:    map[string]interface{}{"lastName": "Doe"},
+			expClErr: true,
+		},
+		{
+			name:     "too large",
+			usrGrp:   userGrp,
+			userID:   "123",
+			schema:   schema,
+			attrs:    map[string]interface{}{"firstName": strings.Repeat("a", 8192)},
+			expClErr: true,
+		},
+		{
+			name:              "no schema",
+			usrGrp:            userGrp,
+			userID:            "123",
+			attrs:             validAttrs,
+			expNotImplemented: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp, Attributes: tc.storedAttrs,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpAttrSchema: tc.schema}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			attrs, err := a.SetUserAttributes(loggedIn.JWT, tc.userID, tc.attrs)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if tc.expNotImplemented {
+				if !a.IsNotImplementedError(err) {
+					t.Fatalf("Expected a not implemented error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(attrs) != len(tc.attrs) {
+				t.Errorf("Expected attributes %v, got %v", tc.attrs, attrs)
+			}
+			if len(db.SetUsrAttrs) != 1 || len(db.SetUsrAttrs[0]) != len(tc.attrs) {
+				t.Errorf("Expected attributes %v set, got %v", tc.attrs, db.SetUsrAttrs)
+			}
+		})
+	}
+}
+
+func TestAuthentication_Login_claimAttributes(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	userGrp := model.Group{ID: "4", Name: model.GroupUser, AccessLevel: model.AccessLevelUser}
+	attrs := map[string]interface{}{"firstName": "John", "locale": "sw"}
+	tt := []struct {
+		name     string
+		schema   *model.AttributeSchema
+		expAttrs map[string]interface{}
+	}{
+		{
+			name:     "claim attributes",
+			schema:   &model.AttributeSchema{Schema: []byte(testAttrSchema), ClaimAttributes: []string{"locale"}},
+			expAttrs: map[string]interface{}{"locale": "sw"},
+		},
+		{
+			name:   "no claim attributes",
+			schema: &model.AttributeSchema{Schema: []byte(testAttrSchema)},
+		},
+		{name: "no schema"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: userGrp, Attributes: attrs,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpAttrSchema: tc.schema}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Login: got error: %v", err)
+			}
+			ti, err := a.Introspect(loggedIn.JWT)
+			if err != nil {
+				t.Fatalf("Introspect: got error: %v", err)
+			}
+			if !ti.Active {
+				t.Fatalf("Introspect: expected an active token")
+			}
+			if !reflect.DeepEqual(ti.Claims.Attributes, tc.expAttrs) {
+				t.Errorf("Expected claim attributes %v, got %v", tc.expAttrs,
+					ti.Claims.Attributes)
+			}
+		})
+	}
+}
+
+func TestAuthentication_userNotInTenant(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	tt := []struct {
+		name string
+		call func(a *model.Authentication, JWT string) error
+	}{
+		{name: "login history", call: func(a *model.Authentication, JWT string) error {
+			_, err := a.LoginHistory(JWT, "456", "", "")
+			return err
+		}},
+		{name: "sessions", call: func(a *model.Authentication, JWT string) error {
+			_, err := a.Sessions(JWT, "456", "", "")
+			return err
+		}},
+		{name: "logout all", call: func(a *model.Authentication, JWT string) error {
+			return a.LogoutAll(JWT, "456")
+		}},
+		{name: "revoke session", call: func(a *model.Authentication, JWT string) error {
+			return a.RevokeSession(JWT, "456", "789")
+		}},
+		{name: "reset TOTP", call: func(a *model.Authentication, JWT string) error {
+			return a.ResetTOTP(JWT, "456")
+		}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: adminGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpUsrErr: typederrs.NewNotFound("user not found")}
+			a := newAuthentication(t, db, newJWTHandler(t)).ForTenant("42")
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			err = tc.call(a, loggedIn.JWT)
+			if !a.IsNotFoundError(err) {
+				t.Fatalf("Expected a not found error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_ForTenant(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	adminGrp := model.Group{ID: "1", Name: model.GroupAdmin, AccessLevel: model.AccessLevelAdmin}
+	platformGrp := model.Group{ID: "5", Name: "platform", AccessLevel: model.AccessLevelAdmin,
+		Permissions: []string{model.PermTenantsAll}}
+	tt := []struct {
+		name         string
+		usrGrp       model.Group
+		lgnTenantID  string
+		useTenantID  string
+		expForbidErr bool
+	}{
+		{name: "default tenant", usrGrp: adminGrp},
+		{name: "same tenant", usrGrp: adminGrp, lgnTenantID: "42", useTenantID: "42"},
+		{name: "other tenant", usrGrp: adminGrp, lgnTenantID: "42", useTenantID: "43", expForbidErr: true},
+		{name: "default to tenant", usrGrp: adminGrp, useTenantID: "42", expForbidErr: true},
+		{name: "tenant to default", usrGrp: adminGrp, lgnTenantID: "42", expForbidErr: true},
+		{name: "tenants:all", usrGrp: platformGrp, useTenantID: "42"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.ForTenant(tc.lgnTenantID).
+				Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			ta := a.ForTenant(tc.useTenantID)
+			if db.TenantID != tc.useTenantID {
+				t.Errorf("Expected AuthStore for tenant '%s', got '%s'",
+					tc.useTenantID, db.TenantID)
+			}
+			_, err = ta.GetUserDetails(loggedIn.JWT, usr.ID)
+			if tc.expForbidErr {
+				if !ta.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_CreateTenant(t *testing.T) {
+	pass := []byte("a valid password")
+	passH, err := bcrypt.GenerateFromPassword(pass, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	superGrp := model.Group{ID: "1", Name: model.GroupSuper, AccessLevel: model.AccessLevelSuper}
+	platformGrp := model.Group{ID: "5", Name: "platform", AccessLevel: model.AccessLevelAdmin,
+		Permissions: []string{model.PermTenantsAll}}
+	tt := []struct {
+		name           string
+		usrGrp         model.Group
+		tntName        string
+		takenTnt       *model.Tenant
+		expClErr       bool
+		expConflictErr bool
+		expForbidErr   bool
+	}{
+		{name: "tenants:all", usrGrp: platformGrp, tntName: " acme "},
+		{name: "empty name", usrGrp: platformGrp, tntName: " ", expClErr: true},
+		{
+			name:     "name too long",
+			usrGrp:   platformGrp,
+			tntName:  strings.Repeat("a", 57),
+			expClErr: true,
+		},
+		{
+			name:           "name taken",
+			usrGrp:         platformGrp,
+			tntName:        "acme",
+			takenTnt:       &model.Tenant{ID: "42", Name: "acme"},
+			expConflictErr: true,
+		},
+		{name: "super without tenants:all", usrGrp: superGrp, tntName: "acme", expForbidErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usr := &model.User{ID: "123", Group: tc.usrGrp,
+				UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: passH,
+				ExpTntBNm: tc.takenTnt}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			loggedIn, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", pass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+
+			tnt, err := a.CreateTenant(loggedIn.JWT, tc.tntName)
+			if tc.expClErr {
+				if !a.IsClientError(err) {
+					t.Fatalf("Expected a client error, got %v", err)
+				}
+				return
+			}
+			if tc.expConflictErr {
+				if !a.IsConflictError(err) {
+					t.Fatalf("Expected a conflict error, got %v", err)
+				}
+				return
+			}
+			if tc.expForbidErr {
+				if !a.IsForbiddenError(err) {
+					t.Fatalf("Expected a forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if tnt.Name != strings.TrimSpace(tc.tntName) {
+				t.Errorf("Expected tenant name '%s', got '%s'",
+					strings.TrimSpace(tc.tntName), tnt.Name)
+			}
+			if len(db.InsertedTnts) != 1 {
+				t.Errorf("Expected 1 tenant inserted, got %d", len(db.InsertedTnts))
+			}
+		})
+	}
+}
+
+func TestAuthentication_Introspect(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	usr := &model.User{ID: "123", UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+	tt := []struct {
+		name      string
+		db        *testingH.DBMock
+		token     func(JWT string) string
+		expActive bool
+		expErr    bool
+	}{
+		{
+			name:      "active",
+			db:        &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH},
+			token:     func(JWT string) string { return JWT },
+			expActive: true,
+		},
+		{
+			name:  "revoked",
+			db:    &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH, ExpIsTknRvkd: true},
+			token: func(JWT string) string { return JWT },
+		},
+		{
+			name:  "invalid",
+			db:    &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH},
+			token: func(JWT string) string { return JWT + "tampered" },
+		},
+		{
+			name: "check revoked error",
+			db: &testingH.DBMock{ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH,
+				ExpIsTknRvkdErr: errors.New("whoops")},
+			token:  func(JWT string) string { return JWT },
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, newJWTHandler(t))
+			lgnUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			ti, err := a.Introspect(tc.token(lgnUsr.JWT))
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if ti.Active != tc.expActive {
+				t.Fatalf("Expected active %t, got %t", tc.expActive, ti.Active)
+			}
+			if !tc.expActive {
+				return
+			}
+			if ti.Claims == nil || ti.Claims.UsrID != usr.ID {
+				t.Fatalf("Expected claims for user '%s', got %+v", usr.ID, ti.Claims)
+			}
+			if ti.Claims.Id == "" {
+				t.Errorf("Expected a token ID (jti)")
+			}
+		})
+	}
+}
+
+func TestAuthentication_Revoke(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	secret := "a-refresh-token-secret"
+	secretH, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test refresh token: %v", err)
+	}
+	usr := &model.User{ID: "123", UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+	newDB := func() *testingH.DBMock {
+		return &testingH.DBMock{
+			ExpUsrBUsrNm:     usr,
+			ExpUsrBUsrNmPass: validPassH,
+			ExpRfrshTkn: &model.RefreshToken{ID: "789", UserID: "123", APIKeyID: "456",
+				FamilyID: "family", Token: secretH, ExpiryDate: time.Now().Add(1 * time.Hour)},
+		}
+	}
+	tt := []struct {
+		name           string
+		db             *testingH.DBMock
+		ci             model.ClientInfo
+		token          func(JWT string) string
+		expJWTRevoked  bool
+		expFmlyRevoked bool
+		expErr         bool
+	}{
+		{
+			name:          "JWT",
+			db:            newDB(),
+			token:         func(JWT string) string { return JWT },
+			expJWTRevoked: true,
+		},
+		{
+			name:  "invalid JWT",
+			db:    newDB(),
+			token: func(JWT string) string { return JWT + "tampered" },
+		},
+		{
+			name: "insert revoked JWT error",
+			db: func() *testingH.DBMock {
+				db := newDB()
+				db.ExpInsRvkdTknErr = errors.New("whoops")
+				return db
+			}(),
+			token:  func(JWT string) string { return JWT },
+			expErr: true,
+		},
+		{
+			name:           "refresh token",
+			db:             newDB(),
+			ci:             model.ClientInfo{APIKeyID: "456"},
+			token:          func(string) string { return "789." + secret },
+			expFmlyRevoked: true,
+		},
+		{
+			name:  "invalid refresh token",
+			db:    newDB(),
+			ci:    model.ClientInfo{APIKeyID: "456"},
+			token: func(string) string { return "789.some-other-secret" },
+		},
+		{
+			name:   "refresh token of other client",
+			db:     newDB(),
+			ci:     model.ClientInfo{APIKeyID: "654"},
+			token:  func(string) string { return "789." + secret },
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, newJWTHandler(t))
+			lgnUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			err = a.Revoke(tc.ci, tc.token(lgnUsr.JWT))
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if jwtRevoked := len(tc.db.InsertedRvkdTkns) > 0; jwtRevoked != tc.expJWTRevoked {
+				t.Errorf("Expected JWT revoked %t, got %t", tc.expJWTRevoked, jwtRevoked)
+			}
+			if fmlyRevoked := tc.db.RevokedRfrshTknFmly == "family"; fmlyRevoked != tc.expFmlyRevoked {
+				t.Errorf("Expected refresh token family revoked %t, got %t",
+					tc.expFmlyRevoked, fmlyRevoked)
+			}
+		})
+	}
+}
+
+func TestAuthentication_sessions(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	usr := &model.User{
+		ID:       "123",
+		UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"},
+		Group:    model.Group{ID: "1", AccessLevel: model.AccessLevelUser},
+	}
+	ci := model.ClientInfo{IPAddress: "127.0.0.1", UserAgent: "test-agent", DeviceID: "device-1"}
+	tt := []struct {
+		name   string
+		logout func(a *model.Authentication, JWT string) error
+		expErr bool
+	}{
+		{
+			name:   "logout",
+			logout: func(a *model.Authentication, JWT string) error { return a.Logout(JWT) },
+		},
+		{
+			name:   "logout all",
+			logout: func(a *model.Authentication, JWT string) error { return a.LogoutAll(JWT, "123") },
+		},
+		{
+			name: "revoke session",
+			logout: func(a *model.Authentication, JWT string) error {
+				return a.RevokeSession(JWT, "123", "1")
+			},
+		},
+		{
+			name:   "logout all other user",
+			logout: func(a *model.Authentication, JWT string) error { return a.LogoutAll(JWT, "456") },
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH}
+			a := newAuthentication(t, db, newJWTHandler(t))
+			lgnUsr, err := a.Login(ci, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			if len(db.InsertedSesss) != 1 {
+				t.Fatalf("Expected 1 session to be started, got %d", len(db.InsertedSesss))
+			}
+			sess := db.InsertedSesss[0]
+			if sess.UserID != usr.ID || sess.DeviceID != ci.DeviceID ||
+				sess.IPAddress != ci.IPAddress || sess.UserAgent != ci.UserAgent {
+				t.Errorf("Session does not match login details: %+v", sess)
+			}
+			if _, err := a.GetUserDetails(lgnUsr.JWT, usr.ID); err != nil {
+				t.Fatalf("Error setting up: get user details before logout: %v", err)
+			}
+
+			err = tc.logout(a, lgnUsr.JWT)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if _, err := a.GetUserDetails(lgnUsr.JWT, usr.ID); !a.IsForbiddenError(err) {
+				t.Errorf("Expected a forbidden error using JWT after logout, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthentication_LogoutAll_sessionlessJWT(t *testing.T) {
+	usr := &model.User{ID: "123", Group: model.Group{ID: "1", AccessLevel: model.AccessLevelUser}}
+	db := &testingH.DBMock{ExpUsr: usr}
+	j := newJWTHandler(t)
+	a := newAuthentication(t, db, j)
+	newJWT := func(issued time.Time) string {
+		JWT, err := j.Generate(model.JWTClaim{
+			UsrID: usr.ID,
+			Group: usr.Group,
+			StandardClaims: jwt.StandardClaims{
+				Id:        "a-token-ID",
+				IssuedAt:  issued.Unix(),
+				ExpiresAt: issued.Add(time.Hour).Unix(),
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error setting up: generate JWT: %v", err)
+		}
+		return JWT
+	}
+	before := newJWT(time.Now().Add(-time.Minute))
+
+	if err := a.LogoutAll(before, usr.ID); err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if _, err := a.GetUserDetails(before, usr.ID); !a.IsForbiddenError(err) {
+		t.Errorf("Expected a forbidden error using JWT issued before logout all, got %v", err)
+	}
+	// as though logout all was half a minute ago.
+	db.LgdOutAll = time.Now().Add(-30 * time.Second)
+	after := newJWT(time.Now())
+	if _, err := a.GetUserDetails(after, usr.ID); err != nil {
+		t.Errorf("Expected JWT issued after logout all to be valid, got %v", err)
+	}
+}
+
+func newAuthentication(t *testing.T, d model.AuthStore, j model.JWTEr, opts ...model.Option) *model.Authentication {
+	a, err := model.NewAuthentication(d, j, opts...)
+	if err != nil {
+		t.Fatalf("Error setting up: new authentication: %v", err)
+	}
+	return a
+}
+
+func TestAuthentication_Login_mfa(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	tt := []struct {
+		name   string
+		db     *testingH.DBMock
+		expMFA bool
+		expErr bool
+	}{
+		{
+			name: "mfa not enrolled",
+			db:   &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH},
+		},
+		{
+			name: "mfa enrolled but not confirmed",
+			db: &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH,
+				ExpTOTPScrt: &model.TOTPSecret{ID: "1", UserID: "123", Secret: []byte("secret")}},
+		},
+		{
+			name: "mfa enabled",
+			db: &testingH.DBMock{ExpUsrBUsrNm: &model.User{ID: "123"}, ExpUsrBUsrNmPass: validPassH,
+				ExpTOTPScrt: &model.TOTPSecret{ID: "1", UserID: "123", Secret: []byte("secret"), IsConfirmed: true}},
+			expMFA: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAuthentication(t, tc.db, &testingH.JWTMock{},
+				model.WithMFAEncrypter(&testingH.EncrypterMock{}))
+			ci := model.ClientInfo{APIKeyID: "api-key-id"}
+			usr, err := a.Login(ci, model.LoginTypeUsername, "johndoe", validPass)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if tc.expMFA {
+				if usr.MFAToken == "" {
+					t.Errorf("Expected an MFA token, got none")
+				}
+				if usr.JWT != "" || usr.RefreshToken != "" {
+					t.Errorf("Expected no JWT or refresh token, got JWT '%s', refresh token '%s'",
+						usr.JWT, usr.RefreshToken)
+				}
+				return
+			}
+			if usr.MFAToken != "" {
+				t.Errorf("Expected no MFA token, got '%s'", usr.MFAToken)
+			}
+			if usr.JWT == "" {
+				t.Errorf("Expected a JWT, got none")
+			}
+		})
+	}
+}
+
+func TestAuthentication_VerifyMFA(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	secret := []byte("12345678901234567890")
+	now := time.Now()
+	validCode := totp.Code(secret, totp.Step(now), totp.Digits)
+	jwter := newJWTHandler(t)
+	tt := []struct {
+		name     string
+		lastStep int64
+		code     string
+		useJWT   bool
+		expErr   bool
+	}{
+		{
+			name: "valid code",
+			code: validCode,
+		},
+		{
+			name:   "invalid code",
+			code:   "000000",
+			expErr: true,
+		},
+		{
+			name:     "code reused",
+			lastStep: totp.Step(now) + totp.Skew,
+			code:     validCode,
+			expErr:   true,
+		},
+		{
+			name:   "access JWT instead of MFA token",
+			code:   validCode,
+			useJWT: true,
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &model.TOTPSecret{ID: "1", UserID: "123", Secret: secret,
+				IsConfirmed: true, LastStep: tc.lastStep}
+			db := &testingH.DBMock{
+				ExpUsr:           &model.User{ID: "123"},
+				ExpUsrBUsrNm:     &model.User{ID: "123"},
+				ExpUsrBUsrNmPass: validPassH,
+				ExpTOTPScrt:      ts,
+			}
+			a := newAuthentication(t, db, jwter,
+				model.WithMFAEncrypter(&testingH.EncrypterMock{}))
+			ci := model.ClientInfo{IPAddress: "127.0.0.1"}
+			lgnUsr, err := a.Login(ci, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			mfaTkn := lgnUsr.MFAToken
+			if tc.useJWT {
+				ts.IsConfirmed = false
+				lgnUsr, err = a.Login(ci, model.LoginTypeUsername, "johndoe", validPass)
+				if err != nil {
+					t.Fatalf("Error setting up: login: %v", err)
+				}
+				ts.IsConfirmed = true
+				mfaTkn = lgnUsr.JWT
+			}
+			usr, err := a.VerifyMFA(ci, mfaTkn, tc.code)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if usr.JWT == "" {
+				t.Errorf("Expected a JWT, got none")
+			}
+			if _, err := a.VerifyMFA(ci, mfaTkn, tc.code); err == nil {
+				t.Errorf("Expected an error reusing code, got nil")
+			}
+		})
+	}
+}
+
+func TestAuthentication_EnrollTOTP(t *testing.T) {
+	validPass := []byte("a valid password")
+	validPassH, err := bcrypt.GenerateFromPassword(validPass, bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Error setting up: hash test password: %v", err)
+	}
+	jwter := newJWTHandler(t)
+	usr := &model.User{ID: "123", UserName: model.Username{ID: "1", UserID: "123", Value: "johndoe"}}
+	tt := []struct {
+		name   string
+		db     *testingH.DBMock
+		enc    model.Encrypter
+		forUsr string
+		expErr bool
+	}{
+		{
+			name:   "valid",
+			db:     &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH},
+			enc:    &testingH.EncrypterMock{},
+			forUsr: "123",
+		},
+		{
+			name: "re-enroll before confirming",
+			db: &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH,
+				ExpTOTPScrt: &model.TOTPSecret{ID: "1", UserID: "123", Secret: []byte("secret")}},
+			enc:    &testingH.EncrypterMock{},
+			forUsr: "123",
+		},
+		{
+			name:   "other user",
+			db:     &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH},
+			enc:    &testingH.EncrypterMock{},
+			forUsr: "456",
+			expErr: true,
+		},
+		{
+			name:   "no encrypter",
+			db:     &testingH.DBMock{ExpUsr: usr, ExpUsrBUsrNm: usr, ExpUsrBUsrNmPass: validPassH},
+			forUsr: "123",
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []model.Option
+			if tc.enc != nil {
+				opts = append(opts, model.WithMFAEncrypter(tc.enc))
+			}
+			a := newAuthentication(t, tc.db, jwter, opts...)
+			lgnUsr, err := a.Login(model.ClientInfo{}, model.LoginTypeUsername, "johndoe", validPass)
+			if err != nil {
+				t.Fatalf("Error setting up: login: %v", err)
+			}
+			te, err := a.EnrollTOTP(lgnUsr.JWT, tc.forUsr)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if te.Secret == "" || te.URI == "" {
+				t.Fatalf("Expected secret and URI, got %+v", te)
+			}
+			if tc.db.UpsertedTOTPScrt == nil {
+				t.Fatalf("Expected TOTP secret to be saved")
+			}
+			if err := a.ConfirmTOTP(lgnUsr.JWT, tc.forUsr, "000000"); err == nil {
+				t.Errorf("Expected an error confirming invalid code, got nil")
+			}
+			tc.db.ExpTOTPScrt = tc.db.UpsertedTOTPScrt
+			code := totp.Code(tc.db.UpsertedTOTPScrt.Secret, totp.Step(time.Now()), totp.Digits)
+			if err := a.ConfirmTOTP(lgnUsr.JWT, tc.forUsr, code); err != nil {
+				t.Fatalf("Confirm TOTP: %v", err)
+			}
+			if !tc.db.ExpTOTPScrt.IsConfirmed {
+				t.Errorf("Expected TOTP secret to be confirmed")
+			}
+			if _, err := a.EnrollTOTP(lgnUsr.JWT, tc.forUsr); err == nil {
+				t.Errorf("Expected an error enrolling after confirming, got nil")
+			}
+		})
+	}
+}
+
+func TestAuthentication_oidc(t *testing.T) {
+	idp := testingH.NewOIDCStub(t)
+	defer idp.Close()
+	oidcCl, err := oidc.New([]oidc.ProviderConfig{
+		{Name: "stub", IssuerURL: idp.URL, ClientID: testingH.OIDCStubClientID},
+	})
+	if err != nil {
+		t.Fatalf("Error setting up: new OIDC client: %v", err)
+	}
+	validTkn := idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID,
+		idp.Claims("subject-123", "some-nonce"))
+	tt := []struct {
+		name     string
+		idToken  string
+		nonce    string
+		regErr   bool
+		loginErr bool
+	}{
+		{
+			name:    "valid",
+			idToken: validTkn,
+			nonce:   "some-nonce",
+		},
+		{
+			name:     "nonce mismatch",
+			idToken:  validTkn,
+			nonce:    "other-nonce",
+			regErr:   true,
+			loginErr: true,
+		},
+		{
+			name:     "nonce missing",
+			idToken:  validTkn,
+			regErr:   true,
+			loginErr: true,
+		},
+		{
+			name: "wrong audience",
+			idToken: idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID,
+				jwt.MapClaims{"iss": idp.URL, "sub": "subject-123", "aud": "other-client",
+					"exp": time.Now().Add(time.Minute).Unix(), "nonce": "some-nonce"}),
+			nonce:    "some-nonce",
+			regErr:   true,
+			loginErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &testingH.DBMock{}
+			a := newAuthentication(t, db, &testingH.JWTMock{}, model.WithOIDCCl(oidcCl))
+
+			_, err := a.RegisterSelf(model.LoginTypeOIDC, model.UserTypeIndividual,
+				tc.idToken, []byte(tc.nonce))
+			if tc.regErr {
+				if err == nil {
+					t.Fatalf("Expected an error registering, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Register: %v", err)
+				}
+				if len(db.InsertedLnkdIDs) != 1 {
+					t.Fatalf("Expected 1 linked identity, got %d", len(db.InsertedLnkdIDs))
+				}
+				li := db.InsertedLnkdIDs[0]
+				if li.Issuer != idp.URL || li.Subject != "subject-123" {
+					t.Errorf("Linked identity mismatch: got %+v", li)
+				}
+			}
+
+			db.ExpUsrBLnkdID = &model.User{ID: "123"}
+			usr, err := a.Login(model.ClientInfo{}, model.LoginTypeOIDC, tc.idToken, []byte(tc.nonce))
+			if tc.loginErr {
+				if err == nil {
+					t.Fatalf("Expected an error logging in, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			if usr.JWT == "" {
+				t.Errorf("Expected a JWT, got none")
+			}
+		})
+	}
+}
+
+func TestAuthentication_oidc_idpUnavailable(t *testing.T) {
+	idp := testingH.NewOIDCStub(t)
+	oidcCl, err := oidc.New([]oidc.ProviderConfig{
+		{Name: "stub", IssuerURL: idp.URL, ClientID: testingH.OIDCStubClientID},
+	})
+	if err != nil {
+		t.Fatalf("Error setting up: new OIDC client: %v", err)
+	}
+	tkn := idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID,
+		idp.Claims("subject-123", "some-nonce"))
+	idp.Close()
+	db := &testingH.DBMock{ExpUsrBLnkdID: &model.User{ID: "123"}}
+	a := newAuthentication(t, db, &testingH.JWTMock{}, model.WithOIDCCl(oidcCl))
+	_, err = a.Login(model.ClientInfo{}, model.LoginTypeOIDC, tkn, []byte("some-nonce"))
+	if err == nil {
+		t.Fatalf("Expected an error, got nil")
+	}
+	if a.IsAuthError(err) {
+		t.Errorf("Expected a non-auth error, got auth error: %v", err)
+	}
+	if len(db.InsertedLgnFlrs) > 0 {
+		t.Errorf("Expected no login failure recorded, got %+v", db.InsertedLgnFlrs)
+	}
+}
+
+func newJWTHandler(t *testing.T) *token.Handler {
+	j, err := token.NewHandler([]byte("some-jwt-signing-key"))
+	if err != nil {
+		t.Fatalf("Error setting up: new JWT handler: %v", err)
+	}
+	return j
 }
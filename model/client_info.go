@@ -0,0 +1,14 @@
+package model
+
+// ClientInfo holds details of the client from which a request originated.
+type ClientInfo struct {
+	// APIKeyID is the ID of the API key the client app used to access the
+	// service. It is empty if the master API key was used.
+	APIKeyID string
+	// IPAddress is the IP address from which the request originated.
+	IPAddress string
+	// UserAgent is the user agent string of the client app if available.
+	UserAgent string
+	// DeviceID is the ID of the device the client app runs on if provided.
+	DeviceID string
+}
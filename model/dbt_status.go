@@ -5,6 +5,8 @@ import "time"
 type DBTStatus struct {
 	ObfuscatedAddress string
 	ExpiresAt         time.Time
+	// Nonce is only set for login links. See SendLoginLink().
+	Nonce string
 }
 
 func (s DBTStatus) HasValue() bool {
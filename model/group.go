@@ -6,6 +6,9 @@ type Group struct {
 	ID          string
 	Name        string
 	AccessLevel float32
+	// Permissions are granted to members of the group in addition to
+	// whatever their AccessLevel allows.
+	Permissions []string
 	CreateDate  time.Time
 	UpdateDate  time.Time
 }
@@ -13,3 +16,13 @@ type Group struct {
 func (g Group) HasValue() bool {
 	return g.ID != ""
 }
+
+// HasPermission returns true if perm has been granted to the group.
+func (g Group) HasPermission(perm string) bool {
+	for _, p := range g.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// History is a record of an access attempt on a user's account.
+type History struct {
+	ID         string
+	UserID     string
+	AccessType string
+	LoginType  string
+	Successful bool
+	IPAddress  string
+	UserAgent  string
+	CreateDate time.Time
+}
+
+func (h History) HasValue() bool {
+	return h.ID != ""
+}
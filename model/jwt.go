@@ -4,21 +4,69 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
 	"github.com/tomogoma/authms/config"
+	errors "github.com/tomogoma/go-typed-errors"
 )
 
 type JWTClaim struct {
 	UsrID string
-	Group Group
+	// TenantID is the ID of the Tenant the JWT was issued for. It is
+	// empty for the default tenant.
+	TenantID string
+	Group    Group
+	// SessionID is the ID of the Session the JWT was issued under. It is
+	// empty for JWTs issued outside a login.
+	SessionID string
+	// Attributes holds the user's custom attributes named in
+	// AttributeSchema.ClaimAttributes.
+	Attributes map[string]interface{} `json:",omitempty"`
 	jwt.StandardClaims
 }
 
-func newJWTClaim(usrID string, group Group) *JWTClaim {
+// HasPermission returns true if the claim's Group has been granted perm.
+func (c JWTClaim) HasPermission(perm string) bool {
+	return c.Group.HasPermission(perm)
+}
+
+// TokenIntrospection describes the state of a token as determined by
+// Introspect(). Claims is only set for active tokens.
+type TokenIntrospection struct {
+	Active bool
+	Claims *JWTClaim
+}
+
+// mfaClaim is carried by the short-lived token issued during Login() to users
+// who have two-factor authentication enabled. It can only be exchanged for a
+// JWT through VerifyMFA().
+type mfaClaim struct {
+	MFAUsrID string
+	jwt.StandardClaims
+}
+
+func newJWTClaim(usrID, tenantID string, group Group, attrs map[string]interface{}, sessionID string, validity time.Duration) *JWTClaim {
 	issue := time.Now()
-	expiry := issue.Add(tokenValidity)
+	expiry := issue.Add(validity)
 	return &JWTClaim{
-		UsrID: usrID,
-		Group: group,
+		UsrID:      usrID,
+		TenantID:   tenantID,
+		Group:      group,
+		SessionID:  sessionID,
+		Attributes: attrs,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New(),
+			IssuedAt:  issue.Unix(),
+			ExpiresAt: expiry.Unix(),
+			Issuer:    config.CanonicalName(),
+		},
+	}
+}
+
+func newMFAClaim(usrID string) *mfaClaim {
+	issue := time.Now()
+	expiry := issue.Add(mfaTknValidity)
+	return &mfaClaim{
+		MFAUsrID: usrID,
 		StandardClaims: jwt.StandardClaims{
 			IssuedAt:  issue.Unix(),
 			ExpiresAt: expiry.Unix(),
@@ -26,3 +74,19 @@ func newJWTClaim(usrID string, group Group) *JWTClaim {
 		},
 	}
 }
+
+// Valid rejects tokens with no user ID (e.g. an MFA token) in addition
+// to the standard claims validation.
+func (c JWTClaim) Valid() error {
+	if c.UsrID == "" {
+		return errors.New("missing user ID")
+	}
+	return c.StandardClaims.Valid()
+}
+
+func (c mfaClaim) Valid() error {
+	if c.MFAUsrID == "" {
+		return errors.New("missing MFA user ID")
+	}
+	return c.StandardClaims.Valid()
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// LinkedIdentity links a user to their account (subject) at an OpenID Connect
+// identity provider (issuer).
+type LinkedIdentity struct {
+	ID         string
+	UserID     string
+	Issuer     string
+	Subject    string
+	CreateDate time.Time
+	UpdateDate time.Time
+}
+
+func (li LinkedIdentity) HasValue() bool {
+	return li.ID != ""
+}
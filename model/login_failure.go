@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type LoginFailure struct {
+	ID         string
+	LoginType  string
+	Identifier string
+	IPAddress  string
+	CreateDate time.Time
+}
+
+// Lockout describes the lockout state of a login identifier or an IP address
+// following consecutive failed login attempts.
+type Lockout struct {
+	LoginType   string
+	Identifier  string
+	IPAddress   string
+	FailCount   int
+	IsLocked    bool
+	LockedUntil time.Time
+}
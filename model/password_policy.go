@@ -0,0 +1,158 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+const (
+	PassRuleMinLength    = "minLength"
+	PassRuleMaxLength    = "maxLength"
+	PassRuleLowerCase    = "lowerCase"
+	PassRuleUpperCase    = "upperCase"
+	PassRuleDigit        = "digit"
+	PassRuleSpecial      = "special"
+	PassRuleNoIdentifier = "noIdentifier"
+	PassRuleNotReused    = "notReused"
+	PassRuleNotBreached  = "notBreached"
+
+	// minPassIdentifierLen is the length below which identifiers are too
+	// common a substring to be banned from passwords.
+	minPassIdentifierLen = 3
+)
+
+// PasswordPolicy describes the rules passwords chosen by users must satisfy.
+// Zero values disable the respective rule except MinLength which is at
+// least minPassLen.
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RequireLower   bool
+	RequireUpper   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// NoIdentifiers bans passwords containing the user's username or the
+	// local-part of their email address.
+	NoIdentifiers bool
+	// HistoryCount bans reusing the current or the last HistoryCount-1
+	// passwords.
+	HistoryCount int
+}
+
+// PasswordRuleViolation describes a PasswordPolicy rule a password failed.
+type PasswordRuleViolation struct {
+	Rule    string
+	Message string
+}
+
+// PasswordPolicyError is carried by the client error returned when a password
+// does not satisfy the PasswordPolicy. Use PasswordViolations() to extract it.
+type PasswordPolicyError struct {
+	Violations []PasswordRuleViolation
+}
+
+func (e PasswordPolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return "password " + strings.Join(msgs, "; ")
+}
+
+// PasswordViolations returns the rules violated if err resulted from a
+// password that does not satisfy the PasswordPolicy.
+func PasswordViolations(err error) ([]PasswordRuleViolation, bool) {
+	tErr, ok := err.(errors.Error)
+	if !ok {
+		return nil, false
+	}
+	pErr, ok := tErr.Data.(PasswordPolicyError)
+	if !ok {
+		return nil, false
+	}
+	return pErr.Violations, true
+}
+
+func (p PasswordPolicy) valid() error {
+	if p.MinLength < 0 || p.MaxLength < 0 || p.HistoryCount < 0 {
+		return errors.New("password policy values cannot be negative")
+	}
+	if p.MaxLength > 0 && p.MaxLength < p.minLength() {
+		return errors.Newf("password policy max length must be at least %d", p.minLength())
+	}
+	return nil
+}
+
+func (p PasswordPolicy) minLength() int {
+	if p.MinLength < minPassLen {
+		return minPassLen
+	}
+	return p.MinLength
+}
+
+// violations returns the rules password violates excluding PassRuleNotReused
+// which needs the user's password history. identifiers are the username and
+// email address of the user setting the password.
+func (p PasswordPolicy) violations(password []byte, identifiers ...string) []PasswordRuleViolation {
+	var vs []PasswordRuleViolation
+	addViolation := func(rule, msgFormat string, args ...interface{}) {
+		vs = append(vs, PasswordRuleViolation{Rule: rule, Message: fmt.Sprintf(msgFormat, args...)})
+	}
+
+	length := utf8.RuneCount(password)
+	if length < p.minLength() {
+		addViolation(PassRuleMinLength, "must be at least %d characters", p.minLength())
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		addViolation(PassRuleMaxLength, "must be at most %d characters", p.MaxLength)
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSpecial bool
+	for _, r := range string(password) {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSpecial = true
+		}
+	}
+	if p.RequireLower && !hasLower {
+		addViolation(PassRuleLowerCase, "must contain a lower case letter")
+	}
+	if p.RequireUpper && !hasUpper {
+		addViolation(PassRuleUpperCase, "must contain an upper case letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		addViolation(PassRuleDigit, "must contain a digit")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		addViolation(PassRuleSpecial, "must contain a special character")
+	}
+
+	if p.NoIdentifiers {
+		lowerPass := strings.ToLower(string(password))
+		for _, id := range identifiers {
+			if i := strings.Index(id, "@"); i >= 0 {
+				id = id[:i]
+			}
+			id = strings.ToLower(id)
+			if utf8.RuneCountInString(id) < minPassIdentifierLen {
+				continue
+			}
+			if strings.Contains(lowerPass, id) {
+				addViolation(PassRuleNoIdentifier, "must not contain your username or email")
+				break
+			}
+		}
+	}
+
+	return vs
+}
@@ -0,0 +1,34 @@
+package model
+
+// Permissions that can be granted to a Group. A permission lets members of
+// the group carry out an action that would otherwise require a more
+// privileged AccessLevel.
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermGroupsRead     = "groups:read"
+	PermGroupsWrite    = "groups:write"
+	PermLockoutsRead   = "lockouts:read"
+	PermLockoutsWrite  = "lockouts:write"
+	PermUserTypesRead  = "userTypes:read"
+	PermUserTypesWrite = "userTypes:write"
+	// PermAttributeSchemaWrite allows configuring the AttributeSchema users'
+	// custom attributes are validated against.
+	PermAttributeSchemaWrite = "attributeSchema:write"
+	// PermTenantsAll allows acting on, and managing, all tenants. Unlike
+	// the other permissions it has no AccessLevel fallback.
+	PermTenantsAll = "tenants:all"
+)
+
+var validPermissions = map[string]bool{
+	PermUsersRead:            true,
+	PermUsersWrite:           true,
+	PermGroupsRead:           true,
+	PermGroupsWrite:          true,
+	PermLockoutsRead:         true,
+	PermLockoutsWrite:        true,
+	PermUserTypesRead:        true,
+	PermUserTypesWrite:       true,
+	PermAttributeSchemaWrite: true,
+	PermTenantsAll:           true,
+}
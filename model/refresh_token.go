@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	ID         string
+	UserID     string
+	APIKeyID   string
+	FamilyID   string
+	Token      []byte
+	IsUsed     bool
+	IsRevoked  bool
+	IssueDate  time.Time
+	ExpiryDate time.Time
+}
+
+func (rt RefreshToken) HasValue() bool {
+	return rt.ID != ""
+}
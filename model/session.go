@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Session is a successful login to a user's account. JWTs and refresh
+// tokens issued during the login are only valid until the session is
+// revoked.
+type Session struct {
+	ID     string
+	UserID string
+	// FamilyID is the ID of the refresh token family issued during the
+	// login. It is empty if no refresh token was issued.
+	FamilyID   string
+	DeviceID   string
+	IPAddress  string
+	UserAgent  string
+	IsRevoked  bool
+	CreateDate time.Time
+	LastSeen   time.Time
+}
+
+func (s Session) HasValue() bool {
+	return s.ID != ""
+}
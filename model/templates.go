@@ -10,3 +10,8 @@ type VerificationTemplate struct {
 	Code     string
 	AppName  string
 }
+
+type LoginLinkTemplate struct {
+	URLToken string
+	AppName  string
+}
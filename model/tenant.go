@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Tenant is an organisation, typically a client product, whose users,
+// groups and API keys are kept apart from those of other tenants. Usernames,
+// emails, phones and group names need only be unique within a tenant.
+type Tenant struct {
+	ID         string
+	Name       string
+	CreateDate time.Time
+	UpdateDate time.Time
+}
+
+func (t Tenant) HasValue() bool {
+	return t.ID != ""
+}
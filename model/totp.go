@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// TOTPSecret is a user's (encrypted) secret for generating Time-Based One-Time
+// Passwords used in two-factor authentication.
+type TOTPSecret struct {
+	ID          string
+	UserID      string
+	Secret      []byte
+	IsConfirmed bool
+	LastStep    int64
+	CreateDate  time.Time
+	UpdateDate  time.Time
+}
+
+func (ts TOTPSecret) HasValue() bool {
+	return ts.ID != ""
+}
+
+// TOTPEnrollment contains the details a user needs to set up an authenticator
+// app for two-factor authentication.
+type TOTPEnrollment struct {
+	// Secret is the base32 encoded TOTP secret.
+	Secret string
+	// URI is the otpauth:// key URI containing the secret, usually presented
+	// as a QR code.
+	URI string
+}
@@ -8,17 +8,28 @@ import (
 )
 
 type User struct {
-	ID         string
-	JWT        string
-	Type       UserType
-	UserName   Username
-	Phone      VerifLogin
-	Email      VerifLogin
-	Facebook   Facebook
-	Group      Group
-	Devices    []Device
+	ID           string
+	JWT          string
+	RefreshToken string
+	MFAToken     string
+	Type         UserType
+	UserName     Username
+	Phone        VerifLogin
+	Email        VerifLogin
+	Phones       []VerifLogin
+	Emails       []VerifLogin
+	Facebook     Facebook
+	Group        Group
+	Status       UserStatus
+	Devices      []Device
+	// Attributes holds custom profile data e.g. first name or locale,
+	// validated against the tenant's AttributeSchema.
+	Attributes map[string]interface{}
 	CreateDate time.Time
 	UpdateDate time.Time
+	// PassPwned is set during Login() if the password used has appeared
+	// in a data breach (see WithPwnedPassCheck()).
+	PassPwned bool
 }
 
 func (u User) HasValue() bool {
@@ -36,12 +47,27 @@ type UsersQuery struct {
 	AccessLevelsIn []string
 	ProcessedACLs  []NumericQuery
 	GroupNamesIn   []string
-	MatchAll       bool
-	MatchAllACLs   bool
+	StatusesIn     []string
+	// AttributesIn maps an attribute name to the values it may have.
+	AttributesIn map[string][]string
+	MatchAll     bool
+	MatchAllACLs bool
 }
 
 func (uq *UsersQuery) Process() error {
 
+	for i, status := range uq.StatusesIn {
+		if !isValidStatus(status) {
+			return errors.NewClientf("invalid status filter at index %d", i)
+		}
+	}
+
+	for name := range uq.AttributesIn {
+		if name == "" {
+			return errors.NewClient("attribute filter name cannot be empty")
+		}
+	}
+
 	uq.ProcessedACLs = make([]NumericQuery, 0)
 
 	for i, acl := range uq.AccessLevelsIn {
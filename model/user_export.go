@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// APIKey is the metadata of an API key issued to a user. The key itself is
+// never part of it.
+type APIKey struct {
+	ID         string
+	UserID     string
+	CreateDate time.Time
+	UpdateDate time.Time
+}
+
+func (k APIKey) HasValue() bool {
+	return k.ID != ""
+}
+
+// UserExport is everything held about a user as handed to them on request.
+// Secrets (password hashes, token values, API key values, the TOTP secret)
+// are never part of it. The User's Devices are populated. LoginFailures are
+// the recorded failed attempts to log in with each of the user's
+// identifiers. Anyone can attempt to log in with an identifier so their
+// IPAddress is left out: it belongs to whoever made the attempt, not
+// necessarily the user.
+type UserExport struct {
+	User             User
+	APIKeys          []APIKey
+	Sessions         []Session
+	RefreshTokens    []RefreshToken
+	EmailTokens      []DBToken
+	PhoneTokens      []DBToken
+	LoginHistory     []History
+	LinkedIdentities []LinkedIdentity
+	TOTP             TOTPSecret
+	LoginFailures    []LoginFailure
+	ExportDate       time.Time
+}
@@ -0,0 +1,38 @@
+package model
+
+// UserImport describes a user being migrated from another system together
+// with the password hash that system stored for them. The user can log in
+// with their existing password, after which the hash is replaced with one
+// produced by the configured PassHasher.
+type UserImport struct {
+	UserType string
+	GroupID  string
+	// At least one of Username, Email and Phone is required.
+	Username      string
+	Email         string
+	EmailVerified bool
+	Phone         string
+	PhoneVerified bool
+	// PassHashAlgorithm is one of the foreign algorithms supported by
+	// passhash.Import() e.g. passhash.AlgDjangoPBKDF2SHA256.
+	PassHashAlgorithm string
+	PassHash          string
+	// PassSalt is only required by algorithms that store the salt
+	// separately from the hash e.g. passhash.AlgFirebaseScrypt.
+	PassSalt string
+}
+
+// loginTypes returns the login types ui's user will be able to log in with.
+func (ui UserImport) loginTypes() []string {
+	var lts []string
+	if ui.Username != "" {
+		lts = append(lts, LoginTypeUsername)
+	}
+	if ui.Email != "" {
+		lts = append(lts, LoginTypeEmail)
+	}
+	if ui.Phone != "" {
+		lts = append(lts, LoginTypePhone)
+	}
+	return lts
+}
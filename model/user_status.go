@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+	StatusPending   = "pending"
+)
+
+// UserStatus is the standing of a user's account. A suspended user can
+// neither log in nor use tokens already issued to them.
+type UserStatus struct {
+	Value  string
+	Reason string
+	// Until is when the status lapses back to StatusActive. It is zero if
+	// the status does not lapse.
+	Until time.Time
+	// LoggedOutAll is when the user last logged out everywhere; JWTs issued
+	// to them before then are rejected. It is zero if they never have. It
+	// is recorded through SetLoggedOutAll() rather than SetUserStatus().
+	LoggedOutAll time.Time
+}
+
+// ValueAt returns the status in effect at t, accounting for lapsed
+// statuses. An unset status is StatusActive.
+func (s UserStatus) ValueAt(t time.Time) string {
+	if s.Value == "" || (!s.Until.IsZero() && !t.Before(s.Until)) {
+		return StatusActive
+	}
+	return s.Value
+}
+
+func isValidStatus(status string) bool {
+	switch status {
+	case StatusActive, StatusSuspended, StatusPending:
+		return true
+	}
+	return false
+}
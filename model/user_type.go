@@ -2,11 +2,17 @@ package model
 
 import "time"
 
+// UserType classifies users e.g. individual or company.
+// SelfRegistrable is true if users may pick the type when registering
+// themselves. A user of the type must be registered with each of
+// RequiredLoginTypes e.g. companies must supply an email.
 type UserType struct {
-	ID         string
-	Name       string
-	CreateDate time.Time
-	UpdateDate time.Time
+	ID                 string
+	Name               string
+	SelfRegistrable    bool
+	RequiredLoginTypes []string
+	CreateDate         time.Time
+	UpdateDate         time.Time
 }
 
 func (ut UserType) HasValue() bool {
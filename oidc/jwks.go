@@ -0,0 +1,140 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+// JWK is a JSON Web Key as published in a provider's JWKS.
+// Only RSA and P-256 EC signing keys are supported.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK creates the JWK representation of pubKey which must be either an
+// *rsa.PublicKey or a P-256 *ecdsa.PublicKey.
+func NewJWK(kid string, pubKey interface{}) (JWK, error) {
+	switch k := pubKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return JWK{}, fmt.Errorf("unsupported EC curve %s", k.Curve.Params().Name)
+		}
+		return JWK{
+			KeyType:   "EC",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: "ES256",
+			Curve:     "P-256",
+			X:         base64.RawURLEncoding.EncodeToString(padTo(k.X.Bytes(), 32)),
+			Y:         base64.RawURLEncoding.EncodeToString(padTo(k.Y.Bytes(), 32)),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", pubKey)
+	}
+}
+
+// PublicKey returns the *rsa.PublicKey or *ecdsa.PublicKey represented by jwk.
+func (jwk JWK) PublicKey() (interface{}, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode modulus: %v", err)
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode exponent: %v", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve %s", jwk.Curve)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x coordinate: %v", err)
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y coordinate: %v", err)
+		}
+		crv := elliptic.P256()
+		if !crv.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: crv, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.KeyType)
+	}
+}
+
+// fetchJWKS fetches the JWKS at URI returning the signing keys mapped
+// by key ID. Keys that are not for signing or cannot be decoded are skipped.
+func fetchJWKS(cl *http.Client, URI string) (map[string]interface{}, error) {
+	set := new(JWKS)
+	if err := getJSON(cl, URI, set); err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %v", err)
+	}
+	keys := make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		k, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = k
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func padTo(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
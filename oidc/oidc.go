@@ -0,0 +1,276 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// leeway is the clock skew tolerated when validating token timestamps.
+	leeway = 1 * time.Minute
+	// minKeysRefresh is the minimum interval between JWKS re-fetches triggered
+	// by tokens signed with unknown keys.
+	minKeysRefresh = 1 * time.Minute
+)
+
+// ProviderConfig describes an OpenID Connect identity provider.
+type ProviderConfig struct {
+	// Name is a human friendly name for the provider e.g. google.
+	Name string
+	// IssuerURL is the provider's issuer identifier e.g.
+	// https://accounts.google.com. The provider's discovery document is
+	// expected at IssuerURL/.well-known/openid-configuration.
+	IssuerURL string
+	// ClientID is the client ID issued to this app by the provider.
+	ClientID string
+}
+
+// Client validates id_tokens issued by a set of OpenID Connect providers.
+// Use New() to construct.
+type Client struct {
+	errors.AuthErrCheck
+	httpCl    *http.Client
+	providers map[string]*provider
+}
+
+type provider struct {
+	conf ProviderConfig
+
+	mutex       sync.Mutex
+	jwksURI     string
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+type discoveryDoc struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type idClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce"`
+}
+
+// audience is the aud claim which may be either a string or an array of
+// strings.
+type audience []string
+
+// Option is used by New() to pass additional configuration.
+type Option func(*Client)
+
+// WithHTTPClient sets the http client used to fetch discovery documents and
+// JWKS.
+func WithHTTPClient(cl *http.Client) Option {
+	return func(c *Client) {
+		c.httpCl = cl
+	}
+}
+
+var signingMethods = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+}
+
+// New creates a Client that accepts id_tokens from providers.
+// Discovery and JWKS fetching are deferred until a token from the
+// provider is first validated.
+func New(providers []ProviderConfig, opts ...Option) (*Client, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("no OIDC providers specified")
+	}
+	c := &Client{
+		httpCl:    &http.Client{Timeout: 10 * time.Second},
+		providers: make(map[string]*provider),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for _, pc := range providers {
+		if pc.IssuerURL == "" {
+			return nil, errors.Newf("issuer URL for OIDC provider '%s' was empty", pc.Name)
+		}
+		if pc.ClientID == "" {
+			return nil, errors.Newf("client ID for OIDC provider '%s' was empty", pc.Name)
+		}
+		iss := strings.TrimSuffix(pc.IssuerURL, "/")
+		if _, exists := c.providers[iss]; exists {
+			return nil, errors.Newf("duplicate OIDC provider for issuer '%s'", iss)
+		}
+		c.providers[iss] = &provider{conf: pc}
+	}
+	return c, nil
+}
+
+// ValidateIDToken validates idToken returning the issuer and subject (the
+// user's unique ID at the issuer). The token must be signed by a key
+// published by its issuer, be issued to the provider's client ID, be
+// unexpired and carry nonce, which must not be empty so that a token
+// issued for another request cannot be replayed.
+// An AuthError is returned if the token is invalid.
+func (c *Client) ValidateIDToken(idToken, nonce string) (string, string, error) {
+
+	clms := new(idClaims)
+	var p *provider
+	var keyErr error
+	parser := &jwt.Parser{ValidMethods: signingMethods}
+	_, err := parser.ParseWithClaims(idToken, clms, func(t *jwt.Token) (interface{}, error) {
+		p = c.providers[strings.TrimSuffix(clms.Issuer, "/")]
+		if p == nil {
+			return nil, errors.NewAuthf("unknown issuer '%s'", clms.Issuer)
+		}
+		kid, _ := t.Header["kid"].(string)
+		var k interface{}
+		k, keyErr = p.key(c.httpCl, kid)
+		return k, keyErr
+	})
+	if err != nil {
+		if keyErr != nil && !c.IsAuthError(keyErr) {
+			return "", "", errors.Newf("get id_token signing key: %v", keyErr)
+		}
+		return "", "", errors.NewAuthf("invalid id_token: %v", err)
+	}
+
+	if !clms.Audience.contains(p.conf.ClientID) {
+		return "", "", errors.NewAuth("invalid id_token: not issued to this client")
+	}
+	if nonce == "" {
+		return "", "", errors.NewAuth("invalid id_token: nonce required")
+	}
+	if clms.Nonce != nonce {
+		return "", "", errors.NewAuth("invalid id_token: nonce mismatch")
+	}
+	if clms.Subject == "" {
+		return "", "", errors.NewAuth("invalid id_token: missing subject")
+	}
+
+	return p.conf.IssuerURL, clms.Subject, nil
+}
+
+// key fetches the key identified by kid from the provider's JWKS, fetching
+// the JWKS if it has not been fetched or kid is not among the cached keys.
+func (p *provider) key(cl *http.Client, kid string) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if k, err := p.cachedKey(kid); err == nil {
+		return k, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetched) < minKeysRefresh {
+		return nil, errors.NewAuthf("unknown signing key '%s'", kid)
+	}
+
+	if p.jwksURI == "" {
+		if err := p.discover(cl); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := fetchJWKS(cl, p.jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	return p.cachedKey(kid)
+}
+
+func (p *provider) cachedKey(kid string) (interface{}, error) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, nil
+		}
+	}
+	k, ok := p.keys[kid]
+	if !ok {
+		return nil, errors.NewAuthf("unknown signing key '%s'", kid)
+	}
+	return k, nil
+}
+
+func (p *provider) discover(cl *http.Client) error {
+	doc := new(discoveryDoc)
+	URL := strings.TrimSuffix(p.conf.IssuerURL, "/") + discoveryPath
+	if err := getJSON(cl, URL, doc); err != nil {
+		return fmt.Errorf("fetch discovery document for '%s': %v", p.conf.Name, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.conf.IssuerURL, "/") {
+		return fmt.Errorf("discovery document issuer '%s' does not match configured issuer '%s'",
+			doc.Issuer, p.conf.IssuerURL)
+	}
+	if doc.JWKSURI == "" {
+		return fmt.Errorf("discovery document for '%s' has no jwks_uri", p.conf.Name)
+	}
+	p.jwksURI = doc.JWKSURI
+	return nil
+}
+
+func getJSON(cl *http.Client, URL string, into interface{}) error {
+	r, err := cl.Get(URL)
+	if err != nil {
+		return fmt.Errorf("request: %v", err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode >= 400 {
+		return fmt.Errorf("got status (%d): %s", r.StatusCode, r.Status)
+	}
+	rb, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %v", err)
+	}
+	if err := json.Unmarshal(rb, into); err != nil {
+		return fmt.Errorf("unmarshal response: %v", err)
+	}
+	return nil
+}
+
+func (c *idClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 {
+		return errors.NewAuth("missing expiry")
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return errors.NewAuth("token is expired")
+	}
+	if c.IssuedAt != 0 && now.Add(leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.NewAuth("token used before issued")
+	}
+	return nil
+}
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(b, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, candidate := range a {
+		if candidate == aud {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package oidc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/tomogoma/authms/oidc"
+	testingH "github.com/tomogoma/authms/testing"
+)
+
+func TestNew(t *testing.T) {
+	tt := []struct {
+		name      string
+		providers []oidc.ProviderConfig
+		expErr    bool
+	}{
+		{
+			name:      "valid",
+			providers: []oidc.ProviderConfig{{Name: "test", IssuerURL: "https://idp.test", ClientID: "id"}},
+		},
+		{name: "no providers", expErr: true},
+		{
+			name:      "missing issuer",
+			providers: []oidc.ProviderConfig{{Name: "test", ClientID: "id"}},
+			expErr:    true,
+		},
+		{
+			name:      "missing client ID",
+			providers: []oidc.ProviderConfig{{Name: "test", IssuerURL: "https://idp.test"}},
+			expErr:    true,
+		},
+		{
+			name: "duplicate issuer",
+			providers: []oidc.ProviderConfig{
+				{Name: "test", IssuerURL: "https://idp.test", ClientID: "id"},
+				{Name: "test2", IssuerURL: "https://idp.test/", ClientID: "id2"},
+			},
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := oidc.New(tc.providers)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if c == nil {
+				t.Fatalf("Got nil client")
+			}
+		})
+	}
+}
+
+func TestClient_ValidateIDToken(t *testing.T) {
+	idp := testingH.NewOIDCStub(t)
+	defer idp.Close()
+	otherIdP := testingH.NewOIDCStub(t)
+	defer otherIdP.Close()
+
+	c, err := oidc.New([]oidc.ProviderConfig{
+		{Name: "stub", IssuerURL: idp.URL, ClientID: testingH.OIDCStubClientID},
+	})
+	if err != nil {
+		t.Fatalf("Error setting up: new client: %v", err)
+	}
+
+	tt := []struct {
+		name       string
+		token      func() string
+		nonce      string
+		expAuthErr bool
+	}{
+		{
+			name: "valid RS256",
+			token: func() string {
+				return idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, idp.Claims("subject-123", "some-nonce"))
+			},
+			nonce: "some-nonce",
+		},
+		{
+			name: "valid ES256",
+			token: func() string {
+				return idp.Sign(t, jwt.SigningMethodES256, testingH.OIDCStubECKeyID, idp.Claims("subject-123", "some-nonce"))
+			},
+			nonce: "some-nonce",
+		},
+		{
+			name: "valid audience list",
+			token: func() string {
+				clms := idp.Claims("subject-123", "some-nonce")
+				clms["aud"] = []string{"other-client", testingH.OIDCStubClientID}
+				return idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, clms)
+			},
+			nonce: "some-nonce",
+		},
+		{
+			name: "nonce missing",
+			token: func() string {
+				return idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, idp.Claims("subject-123", "some-nonce"))
+			},
+			expAuthErr: true,
+		},
+		{
+			name: "nonce mismatch",
+			token: func() string {
+				return idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, idp.Claims("subject-123", "some-nonce"))
+			},
+			nonce:      "other-nonce",
+			expAuthErr: true,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				clms := idp.Claims("subject-123", "some-nonce")
+				clms["aud"] = "other-client"
+				return idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, clms)
+			},
+			expAuthErr: true,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				clms := idp.Claims("subject-123", "some-nonce")
+				clms["exp"] = time.Now().Add(-1 * time.Hour).Unix()
+				return idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, clms)
+			},
+			expAuthErr: true,
+		},
+		{
+			name: "unknown issuer",
+			token: func() string {
+				return otherIdP.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, otherIdP.Claims("subject-123", "some-nonce"))
+			},
+			expAuthErr: true,
+		},
+		{
+			name: "signed by other key",
+			token: func() string {
+				return otherIdP.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, idp.Claims("subject-123", "some-nonce"))
+			},
+			expAuthErr: true,
+		},
+		{
+			name: "unknown key ID",
+			token: func() string {
+				return idp.Sign(t, jwt.SigningMethodRS256, "none-such", idp.Claims("subject-123", "some-nonce"))
+			},
+			expAuthErr: true,
+		},
+		{
+			name: "symmetric algorithm",
+			token: func() string {
+				return idp.Sign(t, jwt.SigningMethodHS256, testingH.OIDCStubRSAKeyID, idp.Claims("subject-123", "some-nonce"))
+			},
+			expAuthErr: true,
+		},
+		{
+			name:       "malformed",
+			token:      func() string { return "not.a.token" },
+			expAuthErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			iss, sub, err := c.ValidateIDToken(tc.token(), tc.nonce)
+			if tc.expAuthErr {
+				if !c.IsAuthError(err) {
+					t.Fatalf("Expected an auth error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if iss != idp.URL {
+				t.Errorf("Expected issuer '%s', got '%s'", idp.URL, iss)
+			}
+			if sub != "subject-123" {
+				t.Errorf("Expected subject 'subject-123', got '%s'", sub)
+			}
+		})
+	}
+}
+
+func TestClient_ValidateIDToken_idpUnavailable(t *testing.T) {
+	idp := testingH.NewOIDCStub(t)
+	tkn := idp.Sign(t, jwt.SigningMethodRS256, testingH.OIDCStubRSAKeyID, idp.Claims("subject-123", "some-nonce"))
+	c, err := oidc.New([]oidc.ProviderConfig{
+		{Name: "stub", IssuerURL: idp.URL, ClientID: testingH.OIDCStubClientID},
+	})
+	if err != nil {
+		t.Fatalf("Error setting up: new client: %v", err)
+	}
+	idp.Close()
+	_, _, err = c.ValidateIDToken(tkn, "some-nonce")
+	if err == nil {
+		t.Fatalf("Expected an error, got nil")
+	}
+	if c.IsAuthError(err) {
+		t.Errorf("Expected a non-auth error, got auth error: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package passhash
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	errors "github.com/tomogoma/go-typed-errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Foreign algorithms are those whose hashes can be imported from other
+// systems and verified but are never produced by a Hasher. Verify() always
+// reports foreign hashes as needing a rehash.
+const (
+	// AlgDjangoPBKDF2SHA256 is Django's default hasher e.g.
+	//	pbkdf2_sha256$260000$<salt>$<base64 key>
+	AlgDjangoPBKDF2SHA256 = "pbkdf2_sha256"
+	// AlgFirebaseScrypt is Firebase Authentication's modified scrypt. The
+	// project's hash parameters are set through WithFirebaseScrypt().
+	AlgFirebaseScrypt = "firebase_scrypt"
+	// AlgSHA512Crypt is the crypt(3) SHA-512 scheme e.g.
+	//	$6$rounds=5000$<salt>$<hash>
+	AlgSHA512Crypt = "sha512_crypt"
+
+	djangoPBKDF2Prefix   = AlgDjangoPBKDF2SHA256 + "$"
+	firebaseScryptPrefix = "$firebase-scrypt$"
+	sha512CryptPrefix    = "$6$"
+
+	sha512CryptRoundsPrefix = "rounds="
+	sha512CryptDefRounds    = 5000
+	sha512CryptMinRounds    = 1000
+	sha512CryptMaxRounds    = 999999999
+	sha512CryptMaxSaltLen   = 16
+	// sha512CryptMaxPassLen bounds the work done verifying a password,
+	// which grows with the square of its length.
+	sha512CryptMaxPassLen = 4096
+)
+
+// FirebaseScryptParams are a Firebase project's password hash parameters as
+// shown in the Firebase console. SignerKey and SaltSeparator are decoded
+// from their base64 representation.
+type FirebaseScryptParams struct {
+	SignerKey     []byte
+	SaltSeparator []byte
+	Rounds        int
+	MemCost       int
+}
+
+// WithFirebaseScrypt enables verifying hashes imported from a Firebase
+// project with parameters p.
+func WithFirebaseScrypt(p FirebaseScryptParams) Option {
+	return func(h *Hasher) error {
+		if len(p.SignerKey) == 0 {
+			return errors.New("firebase scrypt signer key cannot be empty")
+		}
+		if p.Rounds < 1 || p.MemCost < 1 || p.MemCost > 31 {
+			return errors.New("firebase scrypt rounds must be at least 1" +
+				" and mem cost between 1 and 31")
+		}
+		h.firebase = &p
+		return nil
+	}
+}
+
+// Import converts a hash exported from another system using the foreign
+// algorithm alg into a self-describing hash that Verify() understands.
+// salt is only required for AlgFirebaseScrypt, where hash and salt are the
+// base64 values found in the Firebase users export.
+func Import(alg, hash, salt string) ([]byte, error) {
+	switch alg {
+	case AlgDjangoPBKDF2SHA256:
+		if _, _, _, err := parseDjangoPBKDF2([]byte(hash)); err != nil {
+			return nil, err
+		}
+		return []byte(hash), nil
+	case AlgSHA512Crypt:
+		if _, _, _, _, err := parseSHA512Crypt([]byte(hash)); err != nil {
+			return nil, err
+		}
+		return []byte(hash), nil
+	case AlgFirebaseScrypt:
+		stored := []byte(firebaseScryptPrefix + salt + "$" + hash)
+		if _, _, err := parseFirebaseScrypt(stored); err != nil {
+			return nil, err
+		}
+		return stored, nil
+	default:
+		return nil, errors.NewClientf("unsupported import hash algorithm '%s'", alg)
+	}
+}
+
+// verifyForeign checks password against hashed if hashed was produced by a
+// foreign algorithm. isForeign is false if hashed is not a foreign hash.
+func (h *Hasher) verifyForeign(hashed, password []byte) (isForeign bool, err error) {
+	var valid bool
+	switch {
+	case bytes.HasPrefix(hashed, []byte(djangoPBKDF2Prefix)):
+		valid, err = verifyDjangoPBKDF2(hashed, password)
+	case bytes.HasPrefix(hashed, []byte(sha512CryptPrefix)):
+		valid, err = verifySHA512Crypt(hashed, password)
+	case bytes.HasPrefix(hashed, []byte(firebaseScryptPrefix)):
+		valid, err = h.verifyFirebaseScrypt(hashed, password)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if !valid {
+		return true, errors.NewForbidden("password mismatch")
+	}
+	return true, nil
+}
+
+func parseDjangoPBKDF2(hashed []byte) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(string(hashed), "$")
+	if len(parts) != 4 || parts[0] != AlgDjangoPBKDF2SHA256 {
+		return 0, nil, nil, errors.NewClient("pbkdf2_sha256 hash not in" +
+			" 'pbkdf2_sha256$<iterations>$<salt>$<hash>' format")
+	}
+	iterations, err = strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return 0, nil, nil, errors.NewClientf("invalid pbkdf2_sha256 iterations '%s'", parts[1])
+	}
+	key, err = base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, errors.NewClient("pbkdf2_sha256 hash is not valid base64")
+	}
+	return iterations, []byte(parts[2]), key, nil
+}
+
+func verifyDjangoPBKDF2(hashed, password []byte) (bool, error) {
+	iterations, salt, key, err := parseDjangoPBKDF2(hashed)
+	if err != nil {
+		return false, err
+	}
+	checkKey := pbkdf2.Key(password, salt, iterations, len(key), sha256.New)
+	return subtle.ConstantTimeCompare(key, checkKey) == 1, nil
+}
+
+func parseFirebaseScrypt(hashed []byte) (salt, key []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(string(hashed), firebaseScryptPrefix), "$")
+	if len(parts) != 2 {
+		return nil, nil, errors.NewClient("firebase scrypt hash not in expected format")
+	}
+	salt, err = base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, errors.NewClient("firebase scrypt salt is not valid base64")
+	}
+	key, err = base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(key) == 0 {
+		return nil, nil, errors.NewClient("firebase scrypt hash is not valid base64")
+	}
+	return salt, key, nil
+}
+
+func (h *Hasher) verifyFirebaseScrypt(hashed, password []byte) (bool, error) {
+	if h.firebase == nil {
+		return false, errors.New("firebase scrypt parameters not configured")
+	}
+	salt, key, err := parseFirebaseScrypt(hashed)
+	if err != nil {
+		return false, err
+	}
+	p := h.firebase
+	saltSep := append(append([]byte{}, salt...), p.SaltSeparator...)
+	derived, err := scrypt.Key(password, saltSep, 1<<uint(p.MemCost), p.Rounds, 1, 32)
+	if err != nil {
+		return false, errors.Newf("derive scrypt key: %v", err)
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return false, errors.Newf("new AES cipher: %v", err)
+	}
+	checkKey := make([]byte, len(p.SignerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(checkKey, p.SignerKey)
+	return subtle.ConstantTimeCompare(key, checkKey) == 1, nil
+}
+
+func parseSHA512Crypt(hashed []byte) (rounds int, customRounds bool, salt, hash []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(string(hashed), sha512CryptPrefix), "$")
+	rounds = sha512CryptDefRounds
+	if len(parts) == 3 && strings.HasPrefix(parts[0], sha512CryptRoundsPrefix) {
+		rounds, err = strconv.Atoi(strings.TrimPrefix(parts[0], sha512CryptRoundsPrefix))
+		if err != nil || rounds < 1 {
+			return 0, false, nil, nil, errors.NewClientf("invalid sha512_crypt rounds '%s'", parts[0])
+		}
+		customRounds = true
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || len(parts[1]) != 86 {
+		return 0, false, nil, nil, errors.NewClient("sha512_crypt hash not in" +
+			" '$6$[rounds=<rounds>$]<salt>$<hash>' format")
+	}
+	return rounds, customRounds, []byte(parts[0]), []byte(parts[1]), nil
+}
+
+func verifySHA512Crypt(hashed, password []byte) (bool, error) {
+	rounds, customRounds, salt, _, err := parseSHA512Crypt(hashed)
+	if err != nil {
+		return false, err
+	}
+	if len(password) > sha512CryptMaxPassLen {
+		return false, nil
+	}
+	checkHash := sha512Crypt(password, salt, rounds, customRounds)
+	return subtle.ConstantTimeCompare(hashed, checkHash) == 1, nil
+}
+
+// sha512Crypt implements the SHA-512 based crypt(3) scheme as specified in
+// https://www.akkadia.org/drepper/SHA-crypt.txt.
+func sha512Crypt(password, salt []byte, rounds int, customRounds bool) []byte {
+	if len(salt) > sha512CryptMaxSaltLen {
+		salt = salt[:sha512CryptMaxSaltLen]
+	}
+	if rounds < sha512CryptMinRounds {
+		rounds = sha512CryptMinRounds
+	} else if rounds > sha512CryptMaxRounds {
+		rounds = sha512CryptMaxRounds
+	}
+
+	alt := sha512.New()
+	alt.Write(password)
+	alt.Write(salt)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	a := sha512.New()
+	a.Write(password)
+	a.Write(salt)
+	i := len(password)
+	for ; i > sha512.Size; i -= sha512.Size {
+		a.Write(altSum)
+	}
+	a.Write(altSum[:i])
+	for i = len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			a.Write(altSum)
+		} else {
+			a.Write(password)
+		}
+	}
+	aSum := a.Sum(nil)
+
+	dp := sha512.New()
+	for i = 0; i < len(password); i++ {
+		dp.Write(password)
+	}
+	p := repeatToLen(dp.Sum(nil), len(password))
+
+	ds := sha512.New()
+	for i = 0; i < 16+int(aSum[0]); i++ {
+		ds.Write(salt)
+	}
+	s := repeatToLen(ds.Sum(nil), len(salt))
+
+	c := aSum
+	for i = 0; i < rounds; i++ {
+		r := sha512.New()
+		if i&1 != 0 {
+			r.Write(p)
+		} else {
+			r.Write(c)
+		}
+		if i%3 != 0 {
+			r.Write(s)
+		}
+		if i%7 != 0 {
+			r.Write(p)
+		}
+		if i&1 != 0 {
+			r.Write(c)
+		} else {
+			r.Write(p)
+		}
+		c = r.Sum(nil)
+	}
+
+	out := bytes.NewBufferString(sha512CryptPrefix)
+	if customRounds {
+		out.WriteString(sha512CryptRoundsPrefix + strconv.Itoa(rounds) + "$")
+	}
+	out.Write(salt)
+	out.WriteByte('$')
+	for _, g := range sha512CryptPermutation {
+		writeCryptB64(out, uint(c[g[0]])<<16|uint(c[g[1]])<<8|uint(c[g[2]]), 4)
+	}
+	writeCryptB64(out, uint(c[63]), 2)
+	return out.Bytes()
+}
+
+// sha512CryptPermutation is the order in which digest bytes are encoded, 3
+// at a time, in a sha512_crypt hash.
+var sha512CryptPermutation = [][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41},
+}
+
+const cryptB64Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func writeCryptB64(out *bytes.Buffer, w uint, n int) {
+	for ; n > 0; n-- {
+		out.WriteByte(cryptB64Alphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+func repeatToLen(b []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	for len(out) < length {
+		rem := length - len(out)
+		if rem > len(b) {
+			rem = len(b)
+		}
+		out = append(out, b[:rem]...)
+	}
+	return out
+}
@@ -0,0 +1,250 @@
+package passhash_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/tomogoma/authms/passhash"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Sample parameters and hash from https://github.com/firebase/scrypt.
+var (
+	firebaseParams = passhash.FirebaseScryptParams{
+		SignerKey:     b64Decode("jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA=="),
+		SaltSeparator: b64Decode("Bw=="),
+		Rounds:        8,
+		MemCost:       14,
+	}
+	firebaseHash = "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="
+	firebaseSalt = "42xEC+ixf3L2lw=="
+)
+
+func TestImport(t *testing.T) {
+	tt := []struct {
+		name   string
+		alg    string
+		hash   string
+		salt   string
+		expErr bool
+	}{
+		{
+			name: "django pbkdf2_sha256",
+			alg:  passhash.AlgDjangoPBKDF2SHA256,
+			hash: "pbkdf2_sha256$10000$seasalt$A0LCg3KQUWRQJo0wRS2uE8JFda3ue7VorXA8KW0wTXw=",
+		},
+		{
+			name: "sha512_crypt",
+			alg:  passhash.AlgSHA512Crypt,
+			hash: "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		},
+		{
+			name: "firebase scrypt",
+			alg:  passhash.AlgFirebaseScrypt,
+			hash: firebaseHash,
+			salt: firebaseSalt,
+		},
+		{
+			name:   "django bad iterations",
+			alg:    passhash.AlgDjangoPBKDF2SHA256,
+			hash:   "pbkdf2_sha256$many$seasalt$A0LCg3KQUWRQJo0wRS2uE8JFda3ue7VorXA8KW0wTXw=",
+			expErr: true,
+		},
+		{
+			name:   "django wrong algorithm",
+			alg:    passhash.AlgDjangoPBKDF2SHA256,
+			hash:   "pbkdf2_sha1$10000$seasalt$A0LCg3KQUWRQJo0wRS2uE8JFda3ue7VorXA8KW0wTXw=",
+			expErr: true,
+		},
+		{
+			name:   "sha512_crypt truncated",
+			alg:    passhash.AlgSHA512Crypt,
+			hash:   "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl",
+			expErr: true,
+		},
+		{
+			name:   "firebase bad base64",
+			alg:    passhash.AlgFirebaseScrypt,
+			hash:   "not base64!",
+			salt:   firebaseSalt,
+			expErr: true,
+		},
+		{
+			name:   "unsupported algorithm",
+			alg:    "md5",
+			hash:   "5f4dcc3b5aa765d61d8327deb882cf99",
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			hashed, err := passhash.Import(tc.alg, tc.hash, tc.salt)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if len(hashed) == 0 {
+				t.Errorf("Got empty hash")
+			}
+		})
+	}
+}
+
+func TestHasher_Verify_foreign(t *testing.T) {
+	h, err := passhash.NewBcrypt(bcrypt.MinCost, passhash.WithFirebaseScrypt(firebaseParams))
+	if err != nil {
+		t.Fatalf("Error setting up: new hasher: %v", err)
+	}
+	tt := []struct {
+		name       string
+		alg        string
+		hash       string
+		salt       string
+		password   string
+		expAuthErr bool
+	}{
+		{
+			name:     "django pbkdf2_sha256",
+			alg:      passhash.AlgDjangoPBKDF2SHA256,
+			hash:     "pbkdf2_sha256$10000$seasalt$A0LCg3KQUWRQJo0wRS2uE8JFda3ue7VorXA8KW0wTXw=",
+			password: "letmein123",
+		},
+		{
+			name:       "django pbkdf2_sha256 mismatch",
+			alg:        passhash.AlgDjangoPBKDF2SHA256,
+			hash:       "pbkdf2_sha256$10000$seasalt$A0LCg3KQUWRQJo0wRS2uE8JFda3ue7VorXA8KW0wTXw=",
+			password:   "letmein124",
+			expAuthErr: true,
+		},
+		// sha512_crypt samples from https://www.akkadia.org/drepper/SHA-crypt.txt
+		{
+			name:     "sha512_crypt default rounds",
+			alg:      passhash.AlgSHA512Crypt,
+			hash:     "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+			password: "Hello world!",
+		},
+		{
+			name:     "sha512_crypt custom rounds long salt",
+			alg:      passhash.AlgSHA512Crypt,
+			hash:     "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+			password: "Hello world!",
+		},
+		{
+			name:       "sha512_crypt mismatch",
+			alg:        passhash.AlgSHA512Crypt,
+			hash:       "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+			password:   "Hello world?",
+			expAuthErr: true,
+		},
+		{
+			name:       "sha512_crypt password too long",
+			alg:        passhash.AlgSHA512Crypt,
+			hash:       "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+			password:   strings.Repeat("a", 4097),
+			expAuthErr: true,
+		},
+		{
+			name:     "firebase scrypt",
+			alg:      passhash.AlgFirebaseScrypt,
+			hash:     firebaseHash,
+			salt:     firebaseSalt,
+			password: "user1password",
+		},
+		{
+			name:       "firebase scrypt mismatch",
+			alg:        passhash.AlgFirebaseScrypt,
+			hash:       firebaseHash,
+			salt:       firebaseSalt,
+			password:   "user2password",
+			expAuthErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			hashed, err := passhash.Import(tc.alg, tc.hash, tc.salt)
+			if err != nil {
+				t.Fatalf("Error setting up: import hash: %v", err)
+			}
+			needsRehash, err := h.Verify(hashed, []byte(tc.password))
+			if tc.expAuthErr {
+				if !h.IsAuthError(err) {
+					t.Fatalf("Expected an auth error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if !needsRehash {
+				t.Errorf("Expected foreign hash to need rehash")
+			}
+		})
+	}
+}
+
+func TestHasher_Verify_firebaseNotConfigured(t *testing.T) {
+	h := newBcrypt(t, bcrypt.MinCost)
+	hashed, err := passhash.Import(passhash.AlgFirebaseScrypt, firebaseHash, firebaseSalt)
+	if err != nil {
+		t.Fatalf("Error setting up: import hash: %v", err)
+	}
+	_, err = h.Verify(hashed, []byte("user1password"))
+	if err == nil || h.IsAuthError(err) {
+		t.Errorf("Expected a non-auth error, got %v", err)
+	}
+}
+
+func TestWithFirebaseScrypt(t *testing.T) {
+	tt := []struct {
+		name   string
+		modify func(p *passhash.FirebaseScryptParams)
+		expErr bool
+	}{
+		{name: "valid", modify: func(p *passhash.FirebaseScryptParams) {}},
+		{
+			name:   "no signer key",
+			modify: func(p *passhash.FirebaseScryptParams) { p.SignerKey = nil },
+			expErr: true,
+		},
+		{
+			name:   "no rounds",
+			modify: func(p *passhash.FirebaseScryptParams) { p.Rounds = 0 },
+			expErr: true,
+		},
+		{
+			name:   "mem cost too high",
+			modify: func(p *passhash.FirebaseScryptParams) { p.MemCost = 32 },
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := firebaseParams
+			tc.modify(&p)
+			_, err := passhash.NewArgon2id(testArgon2Params, passhash.WithFirebaseScrypt(p))
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+		})
+	}
+}
+
+func b64Decode(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
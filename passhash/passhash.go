@@ -0,0 +1,182 @@
+// Package passhash hashes passwords into self-describing strings that
+// record the algorithm and parameters used, so that hashes produced with
+// outdated parameters can be detected and replaced.
+//
+// bcrypt hashes use the standard modular crypt format e.g.
+//
+//	$2a$10$...
+//
+// argon2id hashes use the PHC string format e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<base64 salt>$<base64 key>
+package passhash
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	errors "github.com/tomogoma/go-typed-errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+
+	argon2idPrefix = "$argon2id$"
+)
+
+// Argon2Params are the argon2id cost parameters.
+type Argon2Params struct {
+	// Memory is the amount of memory used in KiB.
+	Memory uint32
+	// Iterations is the number of passes over the memory.
+	Iterations uint32
+	// Parallelism is the number of threads used.
+	Parallelism uint8
+	// SaltLength and KeyLength are in bytes.
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2Params are the parameters recommended by the argon2
+// package documentation for interactive logins.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes passwords using its algorithm and parameters and verifies
+// passwords against hashes produced by any supported algorithm, including
+// foreign hashes imported through Import(). Use NewBcrypt() or NewArgon2id()
+// to construct.
+type Hasher struct {
+	errors.AuthErrCheck
+	alg        string
+	bcryptCost int
+	argon2     Argon2Params
+	firebase   *FirebaseScryptParams
+}
+
+// Option configures optional Hasher parameters. Use the With... functions
+// to create Options.
+type Option func(*Hasher) error
+
+// NewBcrypt creates a Hasher that hashes using bcrypt at cost.
+func NewBcrypt(cost int, opts ...Option) (*Hasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, errors.Newf("bcrypt cost must be between %d and %d",
+			bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return newHasher(&Hasher{alg: AlgBcrypt, bcryptCost: cost}, opts)
+}
+
+// NewArgon2id creates a Hasher that hashes using argon2id with p.
+func NewArgon2id(p Argon2Params, opts ...Option) (*Hasher, error) {
+	if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 {
+		return nil, errors.New("argon2id requires at least 1 iteration, 1 thread" +
+			" and 8KiB of memory per thread")
+	}
+	if p.SaltLength < 8 || p.KeyLength < 16 {
+		return nil, errors.New("argon2id salt length must be at least 8" +
+			" and key length at least 16 bytes")
+	}
+	return newHasher(&Hasher{alg: AlgArgon2id, argon2: p}, opts)
+}
+
+func newHasher(h *Hasher, opts []Option) (*Hasher, error) {
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Hash hashes password.
+func (h *Hasher) Hash(password []byte) ([]byte, error) {
+	if h.alg == AlgBcrypt {
+		return bcrypt.GenerateFromPassword(password, h.bcryptCost)
+	}
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Newf("generate salt: %v", err)
+	}
+	key := argon2.IDKey(password, salt, h.argon2.Iterations, h.argon2.Memory,
+		h.argon2.Parallelism, h.argon2.KeyLength)
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix,
+		argon2.Version, h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key))), nil
+}
+
+// Verify checks password against hashed. needsRehash is true if hashed was
+// not produced using h's algorithm and parameters, in which case password
+// should be hashed afresh and stored in place of hashed. This is always the
+// case for foreign hashes.
+//
+// The returned error will evaluate (*Hasher).IsAuthError(err) to true if
+// password does not match hashed.
+func (h *Hasher) Verify(hashed, password []byte) (bool, error) {
+	if bytes.HasPrefix(hashed, []byte(argon2idPrefix)) {
+		return h.verifyArgon2id(hashed, password)
+	}
+	if isForeign, err := h.verifyForeign(hashed, password); isForeign {
+		return err == nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword(hashed, password); err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, errors.NewForbidden("password mismatch")
+		}
+		return false, errors.Newf("compare bcrypt hash: %v", err)
+	}
+	cost, err := bcrypt.Cost(hashed)
+	if err != nil {
+		return false, errors.Newf("read bcrypt cost: %v", err)
+	}
+	return h.alg != AlgBcrypt || cost != h.bcryptCost, nil
+}
+
+func (h *Hasher) verifyArgon2id(hashed, password []byte) (bool, error) {
+	parts := strings.Split(string(hashed), "$")
+	if len(parts) != 6 {
+		return false, errors.New("argon2id hash not in PHC string format")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, errors.Newf("parse argon2id version: %v", err)
+	}
+	if version != argon2.Version {
+		return false, errors.Newf("unsupported argon2 version %d", version)
+	}
+	var p Argon2Params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return false, errors.Newf("parse argon2id parameters: %v", err)
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return false, errors.Newf("decode argon2id salt: %v", err)
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil {
+		return false, errors.Newf("decode argon2id key: %v", err)
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	checkKey := argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, checkKey) != 1 {
+		return false, errors.NewForbidden("password mismatch")
+	}
+	return h.alg != AlgArgon2id || p != h.argon2, nil
+}
+
+var b64 = base64.RawStdEncoding
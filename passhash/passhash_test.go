@@ -0,0 +1,266 @@
+package passhash_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tomogoma/authms/passhash"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params are cheap enough to keep the tests fast.
+var testArgon2Params = passhash.Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestNewBcrypt(t *testing.T) {
+	tt := []struct {
+		name   string
+		cost   int
+		expErr bool
+	}{
+		{name: "min cost", cost: bcrypt.MinCost},
+		{name: "max cost", cost: bcrypt.MaxCost},
+		{name: "cost too low", cost: bcrypt.MinCost - 1, expErr: true},
+		{name: "cost too high", cost: bcrypt.MaxCost + 1, expErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := passhash.NewBcrypt(tc.cost)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if h == nil {
+				t.Fatalf("Got nil Hasher")
+			}
+		})
+	}
+}
+
+func TestNewArgon2id(t *testing.T) {
+	tt := []struct {
+		name   string
+		p      passhash.Argon2Params
+		expErr bool
+	}{
+		{name: "default", p: passhash.DefaultArgon2Params},
+		{name: "minimal", p: testArgon2Params},
+		{
+			name:   "no iterations",
+			p:      withParams(func(p *passhash.Argon2Params) { p.Iterations = 0 }),
+			expErr: true,
+		},
+		{
+			name:   "no parallelism",
+			p:      withParams(func(p *passhash.Argon2Params) { p.Parallelism = 0 }),
+			expErr: true,
+		},
+		{
+			name:   "memory too low",
+			p:      withParams(func(p *passhash.Argon2Params) { p.Memory = 7 }),
+			expErr: true,
+		},
+		{
+			name:   "salt too short",
+			p:      withParams(func(p *passhash.Argon2Params) { p.SaltLength = 7 }),
+			expErr: true,
+		},
+		{
+			name:   "key too short",
+			p:      withParams(func(p *passhash.Argon2Params) { p.KeyLength = 15 }),
+			expErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := passhash.NewArgon2id(tc.p)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if h == nil {
+				t.Fatalf("Got nil Hasher")
+			}
+		})
+	}
+}
+
+func TestHasher_Hash(t *testing.T) {
+	tt := []struct {
+		name      string
+		h         *passhash.Hasher
+		expPrefix string
+	}{
+		{name: "bcrypt", h: newBcrypt(t, bcrypt.MinCost), expPrefix: "$2a$04$"},
+		{
+			name:      "argon2id",
+			h:         newArgon2id(t, testArgon2Params),
+			expPrefix: "$argon2id$v=19$m=64,t=1,p=1$",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pass := []byte("a password")
+			hashed, err := tc.h.Hash(pass)
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if !strings.HasPrefix(string(hashed), tc.expPrefix) {
+				t.Errorf("Expected hash prefixed with '%s', got '%s'", tc.expPrefix, hashed)
+			}
+			hashed2, err := tc.h.Hash(pass)
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if string(hashed) == string(hashed2) {
+				t.Errorf("Expected hashes of the same password to be salted differently")
+			}
+		})
+	}
+}
+
+func TestHasher_Verify(t *testing.T) {
+	pass := []byte("a password")
+	bcryptMin := newBcrypt(t, bcrypt.MinCost)
+	bcryptMin1 := newBcrypt(t, bcrypt.MinCost+1)
+	argon := newArgon2id(t, testArgon2Params)
+	argonMoreMem := newArgon2id(t, withParams(func(p *passhash.Argon2Params) { p.Memory = 128 }))
+	argonLongerKey := newArgon2id(t, withParams(func(p *passhash.Argon2Params) { p.KeyLength = 64 }))
+	tt := []struct {
+		name           string
+		hashedWith     *passhash.Hasher
+		verifyWith     *passhash.Hasher
+		password       []byte
+		expNeedsRehash bool
+		expAuthErr     bool
+	}{
+		{name: "bcrypt current", hashedWith: bcryptMin, verifyWith: bcryptMin, password: pass},
+		{
+			name:           "bcrypt outdated cost",
+			hashedWith:     bcryptMin,
+			verifyWith:     bcryptMin1,
+			password:       pass,
+			expNeedsRehash: true,
+		},
+		{
+			name:           "bcrypt to argon2id",
+			hashedWith:     bcryptMin,
+			verifyWith:     argon,
+			password:       pass,
+			expNeedsRehash: true,
+		},
+		{
+			name:       "bcrypt mismatch",
+			hashedWith: bcryptMin,
+			verifyWith: bcryptMin,
+			password:   []byte("another password"),
+			expAuthErr: true,
+		},
+		{name: "argon2id current", hashedWith: argon, verifyWith: argon, password: pass},
+		{
+			name:           "argon2id outdated memory",
+			hashedWith:     argon,
+			verifyWith:     argonMoreMem,
+			password:       pass,
+			expNeedsRehash: true,
+		},
+		{
+			name:           "argon2id outdated key length",
+			hashedWith:     argon,
+			verifyWith:     argonLongerKey,
+			password:       pass,
+			expNeedsRehash: true,
+		},
+		{
+			name:           "argon2id to bcrypt",
+			hashedWith:     argon,
+			verifyWith:     bcryptMin,
+			password:       pass,
+			expNeedsRehash: true,
+		},
+		{
+			name:       "argon2id mismatch",
+			hashedWith: argon,
+			verifyWith: argon,
+			password:   []byte("another password"),
+			expAuthErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			hashed, err := tc.hashedWith.Hash(pass)
+			if err != nil {
+				t.Fatalf("Error setting up: hash password: %v", err)
+			}
+			needsRehash, err := tc.verifyWith.Verify(hashed, tc.password)
+			if tc.expAuthErr {
+				if !tc.verifyWith.IsAuthError(err) {
+					t.Fatalf("Expected an auth error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if needsRehash != tc.expNeedsRehash {
+				t.Errorf("Expected needsRehash %t, got %t", tc.expNeedsRehash, needsRehash)
+			}
+		})
+	}
+}
+
+func TestHasher_Verify_malformed(t *testing.T) {
+	h := newArgon2id(t, testArgon2Params)
+	for _, hashed := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$a2V5a2V5a2V5a2V5a2V5",
+		"not a hash",
+	} {
+		_, err := h.Verify([]byte(hashed), []byte("a password"))
+		if err == nil {
+			t.Errorf("%s: Expected an error, got nil", hashed)
+		}
+		if h.IsAuthError(err) {
+			t.Errorf("%s: Expected a non-auth error, got %v", hashed, err)
+		}
+	}
+}
+
+func withParams(modify func(p *passhash.Argon2Params)) passhash.Argon2Params {
+	p := testArgon2Params
+	modify(&p)
+	return p
+}
+
+func newBcrypt(t *testing.T, cost int) *passhash.Hasher {
+	h, err := passhash.NewBcrypt(cost)
+	if err != nil {
+		t.Fatalf("Error setting up: new bcrypt hasher: %v", err)
+	}
+	return h
+}
+
+func newArgon2id(t *testing.T, p passhash.Argon2Params) *passhash.Hasher {
+	h, err := passhash.NewArgon2id(p)
+	if err != nil {
+		t.Fatalf("Error setting up: new argon2id hasher: %v", err)
+	}
+	return h
+}
@@ -0,0 +1,160 @@
+// Package pwned looks up passwords in a local copy of the Pwned Passwords
+// (https://haveibeenpwned.com/Passwords) SHA-1 corpus so that breached
+// passwords can be detected without network access.
+//
+// Two layouts of the download are supported:
+//   - A single file of 'HASH:COUNT' lines sorted by HASH, where HASH is the
+//     full upper case hex SHA-1 hash of a password.
+//   - A directory of range files named after the first 5 hex characters of
+//     the hashes they contain (e.g. 5BAA6.txt), each holding 'SUFFIX:COUNT'
+//     lines where SUFFIX is the remaining 35 characters, as served by the
+//     range API.
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	errors "github.com/tomogoma/go-typed-errors"
+)
+
+const prefixLen = 5
+
+// Corpus is a local copy of the Pwned Passwords corpus. Use New() to
+// construct.
+type Corpus struct {
+	path  string
+	isDir bool
+}
+
+// New creates a Corpus from path which is either the sorted hash file or
+// the directory of range files.
+func New(path string) (*Corpus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Newf("stat corpus: %v", err)
+	}
+	return &Corpus{path: path, isDir: info.IsDir()}, nil
+}
+
+// Count returns the number of times password has been seen in breaches or
+// 0 if it has not been seen.
+func (c *Corpus) Count(password []byte) (int, error) {
+	sum := sha1.Sum(password)
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if c.isDir {
+		return c.rangeCount(hash)
+	}
+	return c.fileCount(hash)
+}
+
+// rangeCount scans the range file for hash's prefix. A missing range file
+// is an error since the corpus would be incomplete.
+func (c *Corpus) rangeCount(hash string) (int, error) {
+	f, err := os.Open(filepath.Join(c.path, hash[:prefixLen]+".txt"))
+	if err != nil {
+		return 0, errors.Newf("open range file: %v", err)
+	}
+	defer f.Close()
+	suffix := hash[prefixLen:]
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineHash, count, err := parseLine(scanner.Text())
+		if err != nil {
+			return 0, err
+		}
+		if strings.EqualFold(lineHash, suffix) {
+			return count, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Newf("read range file: %v", err)
+	}
+	return 0, nil
+}
+
+// fileCount binary searches the sorted hash file for hash.
+func (c *Corpus) fileCount(hash string) (int, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return 0, errors.Newf("open corpus: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, errors.Newf("stat corpus: %v", err)
+	}
+
+	// The line holding hash, if any, starts within [lo, hi).
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineFrom(f, mid, info.Size())
+		if err != nil {
+			return 0, err
+		}
+		if line == "" || start >= hi {
+			hi = mid
+			continue
+		}
+		lineHash, count, err := parseLine(line)
+		if err != nil {
+			return 0, err
+		}
+		switch strings.Compare(strings.ToUpper(lineHash), hash) {
+		case 0:
+			return count, nil
+		case -1:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineFrom returns the first complete line starting at or after offset
+// together with its starting offset. line is empty at the end of the file.
+func lineFrom(f *os.File, offset, size int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		// Start from the previous byte so that a line starting exactly
+		// at offset is not skipped.
+		start = offset - 1
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	if offset > 0 {
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return size, "", nil
+		}
+		if err != nil {
+			return 0, "", errors.Newf("read corpus: %v", err)
+		}
+		start += int64(len(skipped))
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", errors.Newf("read corpus: %v", err)
+	}
+	return start, strings.TrimSuffix(line, "\n"), nil
+}
+
+func parseLine(line string) (string, int, error) {
+	line = strings.TrimSpace(line)
+	sep := strings.IndexByte(line, ':')
+	if sep < 0 {
+		return "", 0, errors.Newf("corpus line '%s' not in HASH:COUNT format", line)
+	}
+	count, err := strconv.Atoi(line[sep+1:])
+	if err != nil {
+		return "", 0, errors.Newf("corpus line '%s' has invalid count: %v", line, err)
+	}
+	return line[:sep], count, nil
+}
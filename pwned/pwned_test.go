@@ -0,0 +1,119 @@
+package pwned_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tomogoma/authms/pwned"
+)
+
+var breached = map[string]int{
+	"password": 3861493,
+	"123456":   37359195,
+	"qwerty":   3810555,
+	"letmein":  197341,
+	"dragon":   1074328,
+}
+
+func TestCorpus_Count_file(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	var lines []string
+	for pass, count := range breached {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(pass), count))
+	}
+	// Padding so that the binary search has to traverse several lines.
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(fmt.Sprintf("padding-%d", i)), i+1))
+	}
+	sort.Strings(lines)
+	fName := filepath.Join(dir, "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := ioutil.WriteFile(fName, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600); err != nil {
+		t.Fatalf("Error setting up: write corpus: %v", err)
+	}
+
+	c, err := pwned.New(fName)
+	if err != nil {
+		t.Fatalf("Error setting up: new corpus: %v", err)
+	}
+	testCount(t, c)
+	for _, pass := range []string{"padding-0", "padding-499"} {
+		if count, err := c.Count([]byte(pass)); err != nil || count == 0 {
+			t.Errorf("Expected '%s' to be found, got count %d: %v", pass, count, err)
+		}
+	}
+}
+
+func TestCorpus_Count_rangeDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ranges := make(map[string][]string)
+	for pass, count := range breached {
+		h := sha1Hex(pass)
+		ranges[h[:5]] = append(ranges[h[:5]], fmt.Sprintf("%s:%d", h[5:], count))
+	}
+	// A range file for a password that has not been breached.
+	ranges[sha1Hex("not breached")[:5]] = []string{}
+	for prefix, lines := range ranges {
+		fName := filepath.Join(dir, prefix+".txt")
+		if err := ioutil.WriteFile(fName, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+			t.Fatalf("Error setting up: write range file: %v", err)
+		}
+	}
+
+	c, err := pwned.New(dir)
+	if err != nil {
+		t.Fatalf("Error setting up: new corpus: %v", err)
+	}
+	testCount(t, c)
+	if _, err := c.Count([]byte("range file missing")); err == nil {
+		t.Errorf("Expected an error for a missing range file, got nil")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := pwned.New(filepath.Join(os.TempDir(), "none-such-corpus")); err == nil {
+		t.Errorf("Expected an error, got nil")
+	}
+}
+
+func testCount(t *testing.T, c *pwned.Corpus) {
+	for pass, expCount := range breached {
+		count, err := c.Count([]byte(pass))
+		if err != nil {
+			t.Fatalf("%s: Got error: %v", pass, err)
+		}
+		if count != expCount {
+			t.Errorf("%s: Expected count %d, got %d", pass, expCount, count)
+		}
+	}
+	count, err := c.Count([]byte("not breached"))
+	if err != nil {
+		t.Fatalf("not breached: Got error: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("not breached: Expected count 0, got %d", count)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "pwned")
+	if err != nil {
+		t.Fatalf("Error setting up: create temp dir: %v", err)
+	}
+	return dir
+}
+
+func sha1Hex(pass string) string {
+	sum := sha1.Sum([]byte(pass))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
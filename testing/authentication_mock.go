@@ -23,12 +23,100 @@ type AuthenticationMock struct {
 	ExpRegOtherUser *model.User
 	ExpRegOtherErr  error
 
+	ExpImportUser    *model.User
+	ExpImportUserErr error
+
+	ExpDeleteUserErr error
+
+	ExpExportUser    *model.UserExport
+	ExpExportUserErr error
+
 	ExpUpdIDerUser *model.User
 	ExpUpdIDerErr  error
 
+	ExpSetUsrStatusUser *model.User
+	ExpSetUsrStatusErr  error
+
+	ExpUsrAttrs       map[string]interface{}
+	ExpUsrAttrsErr    error
+	ExpSetUsrAttrs    map[string]interface{}
+	ExpSetUsrAttrsErr error
+
+	ExpAttrSchema       *model.AttributeSchema
+	ExpAttrSchemaErr    error
+	ExpSetAttrSchema    *model.AttributeSchema
+	ExpSetAttrSchemaErr error
+
+	ExpAddAddrVL       *model.VerifLogin
+	ExpAddAddrErr      error
+	ExpRmAddrErr       error
+	ExpSetPrimAddrUser *model.User
+	ExpSetPrimAddrErr  error
+
+	ExpLinkIDUser  *model.User
+	ExpLinkIDErr   error
+	ExpUnlinkIDErr error
+
+	ExpCreateGrp      *model.Group
+	ExpCreateGrpErr   error
+	ExpUpdGrp         *model.Group
+	ExpUpdGrpErr      error
+	ExpSetGrpPerms    *model.Group
+	ExpSetGrpPermsErr error
+	ExpDelGrpErr      error
+
+	ExpUsrTyps         []model.UserType
+	ExpUsrTypsErr      error
+	ExpCreateUsrTyp    *model.UserType
+	ExpCreateUsrTypErr error
+	ExpUpdUsrTyp       *model.UserType
+	ExpUpdUsrTypErr    error
+	ExpDelUsrTypErr    error
+
+	ExpCreateTnt    *model.Tenant
+	ExpCreateTntErr error
+	ExpTnts         []model.Tenant
+	ExpTntsErr      error
+
 	ExpLoginUser *model.User
 	ExpLoginErr  error
 
+	ExpSendLoginLinkDBTS *model.DBTStatus
+	ExpSendLoginLinkErr  error
+	ExpLoginByLinkUser   *model.User
+	ExpLoginByLinkErr    error
+	ExpSendLoginOTPDBTS  *model.DBTStatus
+	ExpSendLoginOTPErr   error
+	ExpLoginByOTPUser    *model.User
+	ExpLoginByOTPErr     error
+
+	ExpRefreshUser *model.User
+	ExpRefreshErr  error
+
+	ExpIntrospection *model.TokenIntrospection
+	ExpIntrospectErr error
+	ExpRevokeErr     error
+
+	ExpLogoutErr    error
+	ExpLogoutAllErr error
+	ExpSesss        []model.Session
+	ExpSesssErr     error
+	ExpRvkSessErr   error
+
+	ExpLockout    *model.Lockout
+	ExpLockoutErr error
+
+	ExpLgnHist    []model.History
+	ExpLgnHistErr error
+
+	ExpVerMFAUser *model.User
+	ExpVerMFAErr  error
+
+	ExpTOTPEnrlmnt  *model.TOTPEnrollment
+	ExpEnrlTOTPErr  error
+	ExpCnfrmTOTPErr error
+	ExpRstTOTPErr   error
+
 	ExpSetPassVerLogin *model.VerifLogin
 	ExpSetPassErr      error
 
@@ -37,6 +125,8 @@ type AuthenticationMock struct {
 
 	ExpSndVerCodeDBTStts *model.DBTStatus
 	ExpSndVerCodeErr     error
+	ExpSndCnfCodeDBTStts *model.DBTStatus
+	ExpSndCnfCodeErr     error
 
 	ExpSndPassRstDBTStts *model.DBTStatus
 	ExpSndPassRstErr     error
@@ -70,6 +160,90 @@ func (a *AuthenticationMock) RegisterOther(JWT, newLoginType, userType, id, grou
 	return a.ExpRegOtherUser, a.ExpRegOtherErr
 }
 
+func (a *AuthenticationMock) ImportUser(JWT string, ui model.UserImport) (*model.User, error) {
+	return a.ExpImportUser, a.ExpImportUserErr
+}
+
+func (a *AuthenticationMock) SetUserStatus(JWT, userID, status, reason, until string) (*model.User, error) {
+	return a.ExpSetUsrStatusUser, a.ExpSetUsrStatusErr
+}
+
+func (a *AuthenticationMock) UserAttributes(JWT, userID string) (map[string]interface{}, error) {
+	return a.ExpUsrAttrs, a.ExpUsrAttrsErr
+}
+
+func (a *AuthenticationMock) SetUserAttributes(JWT, userID string, attrs map[string]interface{}) (map[string]interface{}, error) {
+	return a.ExpSetUsrAttrs, a.ExpSetUsrAttrsErr
+}
+
+func (a *AuthenticationMock) AddAddress(JWT, forUserID, loginType, address string) (*model.VerifLogin, error) {
+	return a.ExpAddAddrVL, a.ExpAddAddrErr
+}
+
+func (a *AuthenticationMock) RemoveAddress(JWT, forUserID, loginType, address string) error {
+	return a.ExpRmAddrErr
+}
+
+func (a *AuthenticationMock) SetPrimaryAddress(JWT, forUserID, loginType, address string) (*model.User, error) {
+	return a.ExpSetPrimAddrUser, a.ExpSetPrimAddrErr
+}
+
+func (a *AuthenticationMock) LinkIdentity(JWT, forUserID, loginType, identifier string, secret []byte) (*model.User, error) {
+	return a.ExpLinkIDUser, a.ExpLinkIDErr
+}
+
+func (a *AuthenticationMock) UnlinkIdentity(JWT, forUserID, loginType string) error {
+	return a.ExpUnlinkIDErr
+}
+
+func (a *AuthenticationMock) CreateGroup(JWT, name string, accessLevel float32) (*model.Group, error) {
+	return a.ExpCreateGrp, a.ExpCreateGrpErr
+}
+
+func (a *AuthenticationMock) UpdateGroup(JWT, groupID, name string, accessLevel float32) (*model.Group, error) {
+	return a.ExpUpdGrp, a.ExpUpdGrpErr
+}
+
+func (a *AuthenticationMock) SetGroupPermissions(JWT, groupID string, perms []string) (*model.Group, error) {
+	return a.ExpSetGrpPerms, a.ExpSetGrpPermsErr
+}
+
+func (a *AuthenticationMock) DeleteGroup(JWT, groupID string) error {
+	return a.ExpDelGrpErr
+}
+
+func (a *AuthenticationMock) UserTypes(JWT, offsetStr, countStr string) ([]model.UserType, error) {
+	return a.ExpUsrTyps, a.ExpUsrTypsErr
+}
+
+func (a *AuthenticationMock) CreateUserType(JWT, name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error) {
+	return a.ExpCreateUsrTyp, a.ExpCreateUsrTypErr
+}
+
+func (a *AuthenticationMock) UpdateUserType(JWT, userTypeID, name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error) {
+	return a.ExpUpdUsrTyp, a.ExpUpdUsrTypErr
+}
+
+func (a *AuthenticationMock) DeleteUserType(JWT, userTypeID string) error {
+	return a.ExpDelUsrTypErr
+}
+
+func (a *AuthenticationMock) AttributeSchema(JWT string) (*model.AttributeSchema, error) {
+	return a.ExpAttrSchema, a.ExpAttrSchemaErr
+}
+
+func (a *AuthenticationMock) SetAttributeSchema(JWT string, schema []byte, claimAttrs []string) (*model.AttributeSchema, error) {
+	return a.ExpSetAttrSchema, a.ExpSetAttrSchemaErr
+}
+
+func (a *AuthenticationMock) CreateTenant(JWT, name string) (*model.Tenant, error) {
+	return a.ExpCreateTnt, a.ExpCreateTntErr
+}
+
+func (a *AuthenticationMock) Tenants(JWT, offsetStr, countStr string) ([]model.Tenant, error) {
+	return a.ExpTnts, a.ExpTntsErr
+}
+
 func (a *AuthenticationMock) UpdateIdentifier(JWT, loginType, newId string) (*model.User, error) {
 	return a.ExpUpdIDerUser, a.ExpUpdIDerErr
 }
@@ -78,7 +252,7 @@ func (a *AuthenticationMock) UpdatePassword(JWT string, old, newPass []byte) err
 	return a.ExpUpdPassErr
 }
 
-func (a *AuthenticationMock) SetPassword(loginType, userID string, dbt, pass []byte) (*model.VerifLogin, error) {
+func (a *AuthenticationMock) SetPassword(ci model.ClientInfo, loginType, userID string, dbt, pass []byte) (*model.VerifLogin, error) {
 	return a.ExpSetPassVerLogin, a.ExpSetPassErr
 }
 
@@ -86,18 +260,110 @@ func (a *AuthenticationMock) SendVerCode(JWT, loginType, toAddr string) (*model.
 	return a.ExpSndVerCodeDBTStts, a.ExpSndVerCodeErr
 }
 
+func (a *AuthenticationMock) SendConfirmCode(JWT, forUserID, loginType string) (*model.DBTStatus, error) {
+	return a.ExpSndCnfCodeDBTStts, a.ExpSndCnfCodeErr
+}
+
 func (a *AuthenticationMock) SendPassResetCode(loginType, toAddr string) (*model.DBTStatus, error) {
 	return a.ExpSndPassRstDBTStts, a.ExpSndPassRstErr
 }
 
-func (a *AuthenticationMock) VerifyAndExtendDBT(lt, usrID string, dbt []byte) (string, error) {
+func (a *AuthenticationMock) VerifyAndExtendDBT(ci model.ClientInfo, lt, usrID string, dbt []byte) (string, error) {
 	return a.ExpVerExtDBT, a.ExpVerExtDBTErr
 }
 
-func (a *AuthenticationMock) VerifyDBT(loginType, userID string, dbt []byte) (*model.VerifLogin, error) {
+func (a *AuthenticationMock) VerifyDBT(ci model.ClientInfo, loginType, userID string, dbt []byte) (*model.VerifLogin, error) {
 	return a.ExpVerDBTVerLogin, a.ExpVerDBTErr
 }
 
-func (a *AuthenticationMock) Login(loginType, identifier string, password []byte) (*model.User, error) {
+func (a *AuthenticationMock) Login(ci model.ClientInfo, loginType, identifier string, password []byte) (*model.User, error) {
 	return a.ExpLoginUser, a.ExpLoginErr
 }
+
+func (a *AuthenticationMock) SendLoginLink(loginType, toAddr string) (*model.DBTStatus, error) {
+	return a.ExpSendLoginLinkDBTS, a.ExpSendLoginLinkErr
+}
+
+func (a *AuthenticationMock) LoginByLink(ci model.ClientInfo, loginType, userID, nonce string, dbt []byte) (*model.User, error) {
+	return a.ExpLoginByLinkUser, a.ExpLoginByLinkErr
+}
+
+func (a *AuthenticationMock) SendLoginOTP(loginType, toAddr string) (*model.DBTStatus, error) {
+	return a.ExpSendLoginOTPDBTS, a.ExpSendLoginOTPErr
+}
+
+func (a *AuthenticationMock) LoginByOTP(ci model.ClientInfo, loginType, identifier string, code []byte) (*model.User, error) {
+	return a.ExpLoginByOTPUser, a.ExpLoginByOTPErr
+}
+
+func (a *AuthenticationMock) DeleteUser(ci model.ClientInfo, JWT, userID, confirmLoginType string, confirmation []byte) error {
+	return a.ExpDeleteUserErr
+}
+
+func (a *AuthenticationMock) ExportUser(JWT, userID string) (*model.UserExport, error) {
+	return a.ExpExportUser, a.ExpExportUserErr
+}
+
+func (a *AuthenticationMock) Refresh(ci model.ClientInfo, refreshToken string) (*model.User, error) {
+	return a.ExpRefreshUser, a.ExpRefreshErr
+}
+
+func (a *AuthenticationMock) Introspect(token string) (*model.TokenIntrospection, error) {
+	return a.ExpIntrospection, a.ExpIntrospectErr
+}
+
+func (a *AuthenticationMock) Revoke(ci model.ClientInfo, token string) error {
+	return a.ExpRevokeErr
+}
+
+func (a *AuthenticationMock) Logout(JWT string) error {
+	return a.ExpLogoutErr
+}
+
+func (a *AuthenticationMock) LogoutAll(JWT, userID string) error {
+	return a.ExpLogoutAllErr
+}
+
+func (a *AuthenticationMock) Sessions(JWT, userID, offset, count string) ([]model.Session, error) {
+	return a.ExpSesss, a.ExpSesssErr
+}
+
+func (a *AuthenticationMock) RevokeSession(JWT, userID, sessionID string) error {
+	return a.ExpRvkSessErr
+}
+
+func (a *AuthenticationMock) LoginLockout(JWT, loginType, identifier string) (*model.Lockout, error) {
+	return a.ExpLockout, a.ExpLockoutErr
+}
+
+func (a *AuthenticationMock) IPLockout(JWT, ipAddress string) (*model.Lockout, error) {
+	return a.ExpLockout, a.ExpLockoutErr
+}
+
+func (a *AuthenticationMock) UnlockLogin(JWT, loginType, identifier string) (*model.Lockout, error) {
+	return a.ExpLockout, a.ExpLockoutErr
+}
+
+func (a *AuthenticationMock) UnlockIP(JWT, ipAddress string) (*model.Lockout, error) {
+	return a.ExpLockout, a.ExpLockoutErr
+}
+
+func (a *AuthenticationMock) LoginHistory(JWT, userID, offset, count string) ([]model.History, error) {
+	return a.ExpLgnHist, a.ExpLgnHistErr
+}
+
+func (a *AuthenticationMock) VerifyMFA(ci model.ClientInfo, mfaToken, code string) (*model.User, error) {
+	return a.ExpVerMFAUser, a.ExpVerMFAErr
+}
+
+func (a *AuthenticationMock) EnrollTOTP(JWT, userID string) (*model.TOTPEnrollment, error) {
+	return a.ExpTOTPEnrlmnt, a.ExpEnrlTOTPErr
+}
+
+func (a *AuthenticationMock) ConfirmTOTP(JWT, userID, code string) error {
+	return a.ExpCnfrmTOTPErr
+}
+
+func (a *AuthenticationMock) ResetTOTP(JWT, userID string) error {
+	return a.ExpRstTOTPErr
+}
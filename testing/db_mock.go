@@ -3,6 +3,7 @@ package testing
 import (
 	"database/sql"
 	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/tomogoma/authms/api"
@@ -14,18 +15,55 @@ import (
 type DBMock struct {
 	errors.NotFoundErrCheck
 
-	ExpInsGrpErr    error
-	ExpGrpBNm       *model.Group
-	ExpGrpBNmErr    error
-	ExpGrp          *model.Group
-	ExpGrpErr       error
-	ExpGrps         []model.Group
-	ExpGrpsErr      error
-	ExpSetUsrGrpErr error
+	ExpInsGrpErr       error
+	ExpGrpBNm          *model.Group
+	ExpGrpBNmErr       error
+	ExpGrp             *model.Group
+	ExpGrpErr          error
+	ExpGrps            []model.Group
+	ExpGrpsErr         error
+	ExpSetUsrGrpErr    error
+	ExpUpdGrpErr       error
+	ExpSetGrpPermsErr  error
+	GrantedPermissions []string
+	ExpDelGrpErr       error
+	DeletedGrps        []string
+
+	TenantID     string
+	ExpInsTntErr error
+	ExpTnt       *model.Tenant
+	ExpTntErr    error
+	ExpTntBNm    *model.Tenant
+	ExpTntBNmErr error
+	ExpTnts      []model.Tenant
+	ExpTntsErr   error
+	InsertedTnts []model.Tenant
+
+	ExpSetUsrStatusErr error
+	ExpUsrStatus       *model.UserStatus
+	ExpUsrStatusErr    error
+	SetUsrStatuses     []model.UserStatus
+	ExpLgdOutAllErr    error
+	LgdOutAll          time.Time
+
+	ExpSetUsrAttrsErr   error
+	SetUsrAttrs         []map[string]interface{}
+	ExpAttrSchema       *model.AttributeSchema
+	ExpAttrSchemaErr    error
+	ExpUpsAttrSchemaErr error
+	UpsertedAttrSchema  *model.AttributeSchema
 
 	ExpInsUsrTypErr error
 	ExpUsrTypBNm    *model.UserType
 	ExpUsrTypBNmErr error
+	ExpUsrTyp       *model.UserType
+	ExpUsrTypErr    error
+	ExpUsrTyps      []model.UserType
+	ExpUsrTypsErr   error
+	ExpUpdUsrTypErr error
+	ExpDelUsrTypErr error
+	InsertedUsrTyps []model.UserType
+	DeletedUsrTyps  []string
 
 	ExpInsUsrErr    error
 	ExpInsUsrAtmErr error
@@ -46,16 +84,23 @@ type DBMock struct {
 
 	ExpupdPassErr    error
 	ExpupdPassAtmErr error
+	UpdatedPasses    [][]byte
 	ExpUsrPass       []byte
 	ExpUsrBDevPass   []byte
 	ExpUsrBUsrNmPass []byte
 	ExpUsrBPhnPass   []byte
 	ExpUsrBMailPass  []byte
 
+	ExpDelUsrAtmErr error
+	DeletedUsrs     []string
+
 	ExpInsAPIKErr     error
 	ExpAPIKsBUsrID    []api.Key
 	ExpAPIKsBUsrIDErr error
 
+	ExpAPIKMetaBUsrID    []model.APIKey
+	ExpAPIKMetaBUsrIDErr error
+
 	ExpAddUsrTGrpAtmcErr error
 
 	ExpInsDevAtmErr error
@@ -64,6 +109,7 @@ type DBMock struct {
 	ExpInsUsrNmAtmErr error
 	ExpUpdUsrNm       *model.Username
 	ExpUpdUsrNmErr    error
+	ExpDelUsrNmErr    error
 
 	ExpInsUsrPhnErr    error
 	ExpInsUsrPhnAtmErr error
@@ -72,12 +118,22 @@ type DBMock struct {
 	ExpUpdUsrPhnAtm    *model.VerifLogin
 	ExpUpdUsrPhnAtmErr error
 
+	ExpVrfyUsrPhnAtmErr error
+	ExpUsrPhns          []model.VerifLogin
+	ExpUsrPhnsErr       error
+	ExpSetPrimUsrPhnErr error
+	PrimaryPhns         []string
+	ExpDelUsrPhnAtmErr  error
+	DeletedPhns         []string
+
 	ExpInsPhnTknErr    error
 	ExpInsPhnTknAtmErr error
 	ExpPhnTkns         []model.DBToken
 	ExpPhnTknsErr      error
 	ExpDelPhnTknsErr   error
 	ExpSetPhnTknUsdErr error
+	InsertedPhnTkns    []model.DBToken
+	UsedPhnTkns        []string
 
 	ExpInsUsrMailErr    error
 	ExpInsUsrMailAtmErr error
@@ -86,20 +142,102 @@ type DBMock struct {
 	ExpUpdUsrMailAtm    *model.VerifLogin
 	ExpUpdUsrMailAtmErr error
 
+	ExpVrfyUsrMailAtmErr error
+	ExpUsrMails          []model.VerifLogin
+	ExpUsrMailsErr       error
+	ExpSetPrimUsrMailErr error
+	PrimaryMails         []string
+	ExpDelUsrMailAtmErr  error
+	DeletedMails         []string
+
 	ExpInsMailTknErr    error
 	ExpInsMailTknAtmErr error
 	ExpMailTkns         []model.DBToken
 	ExpMailTknsErr      error
 	ExpDelMailTknsErr   error
 	ExpSetMailTknUsdErr error
+	InsertedMailTkns    []model.DBToken
+	UsedMailTkns        []string
 
 	ExpInsFbAtmErr error
+	ExpDelFbErr    error
+
+	ExpInsLnkdIDAtmErr error
+	ExpUsrBLnkdID      *model.User
+	ExpUsrBLnkdIDErr   error
+	InsertedLnkdIDs    []model.LinkedIdentity
+	ExpLnkdIDs         []model.LinkedIdentity
+	ExpLnkdIDsErr      error
+	ExpDelLnkdIDsErr   error
+
+	Unlinked []string
+
+	ExpInsRfrshTknErr     error
+	ExpInsRfrshTknAtmErr  error
+	ExpRfrshTkn           *model.RefreshToken
+	ExpRfrshTknErr        error
+	ExpSetRfrshTknUsdErr  error
+	ExpRvkRfrshTknFmlyErr error
+	RevokedRfrshTknFmly   string
+	ExpRfrshTkns          []model.RefreshToken
+	ExpRfrshTknsErr       error
+
+	ExpInsRvkdTknErr error
+	ExpIsTknRvkd     bool
+	ExpIsTknRvkdErr  error
+	InsertedRvkdTkns []string
+
+	ExpInsSessErr      error
+	ExpSess            *model.Session
+	ExpSessErr         error
+	ExpSessBFmly       *model.Session
+	ExpSessBFmlyErr    error
+	ExpSesss           []model.Session
+	ExpSesssErr        error
+	ExpSetSessLstSnErr error
+	ExpRvkSessErr      error
+	ExpRvkSesssErr     error
+	InsertedSesss      []model.Session
+	RevokedSessIDs     []string
+	IsSesssRevoked     bool
+
+	ExpInsPassHstryAtmErr error
+	ExpPassHstry          [][]byte
+	ExpPassHstryErr       error
+	InsertedPassHstry     [][]byte
+
+	ExpInsLgnFlrErr     error
+	ExpLgnFlrs          []model.LoginFailure
+	ExpLgnFlrsErr       error
+	ExpLgnFlrsBIP       []model.LoginFailure
+	ExpLgnFlrsBIPErr    error
+	ExpDelLgnFlrErr     error
+	ExpDelLgnFlrsErr    error
+	ExpDelLgnFlrsBIPErr error
+	InsertedLgnFlrs     []model.LoginFailure
+	IsLgnFlrsDeleted    bool
+
+	ExpInsHistErr    error
+	ExpInsHistAtmErr error
+	ExpHist          []model.History
+	ExpHistErr       error
+	InsertedHist     []model.History
+
+	ExpUpsTOTPScrtErr   error
+	ExpTOTPScrt         *model.TOTPSecret
+	ExpTOTPScrtErr      error
+	ExpCnfrmTOTPScrtErr error
+	ExpSetTOTPLstStpErr error
+	ExpDelTOTPScrtErr   error
+	UpsertedTOTPScrt    *model.TOTPSecret
+	IsTOTPScrtDeleted   bool
 
 	ExpUpsSMTPConfErr error
 	ExpSMTPConf       smtp.Config
 	ExpSMTPConfErr    error
 
-	ExpHasUsrsErr error
+	ExpHasUsrsErr      error
+	ExpHasUsrsOfTypErr error
 
 	isInTx bool
 }
@@ -134,6 +272,48 @@ func (db *DBMock) UpsertSMTPConfig(conf interface{}) error {
 	return db.ExpUpsSMTPConfErr
 }
 
+// ForTenant records tenantID in TenantID and returns db itself.
+func (db *DBMock) ForTenant(tenantID string) model.AuthStore {
+	db.TenantID = tenantID
+	return db
+}
+
+func (db *DBMock) InsertTenant(name string) (*model.Tenant, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsTntErr != nil {
+		return nil, db.ExpInsTntErr
+	}
+	t := model.Tenant{ID: currentID(), Name: name}
+	db.InsertedTnts = append(db.InsertedTnts, t)
+	return &t, nil
+}
+
+func (db *DBMock) Tenant(id string) (*model.Tenant, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpTnt == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpTnt, db.ExpTntErr
+}
+
+func (db *DBMock) TenantByName(name string) (*model.Tenant, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpTntBNm == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpTntBNm, db.ExpTntBNmErr
+}
+
+func (db *DBMock) Tenants(offset, count int64) ([]model.Tenant, error) {
+	return db.ExpTnts, db.ExpTntsErr
+}
+
 func (db *DBMock) GroupByName(string) (*model.Group, error) {
 	if db.isInTx {
 		return nil, errors.Newf("direct db call while in tx")
@@ -162,6 +342,78 @@ func (db *DBMock) SetUserGroup(userID, groupID string) error {
 	return db.ExpSetUsrGrpErr
 }
 
+func (db *DBMock) SetUserStatus(userID string, s model.UserStatus) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSetUsrStatusErr != nil {
+		return db.ExpSetUsrStatusErr
+	}
+	db.SetUsrStatuses = append(db.SetUsrStatuses, s)
+	return nil
+}
+
+// UserStatus returns ExpUsrStatus, or an unset (active) status if
+// ExpUsrStatus is nil, logged out everywhere at LgdOutAll.
+func (db *DBMock) UserStatus(userID string) (*model.UserStatus, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUsrStatusErr != nil {
+		return nil, db.ExpUsrStatusErr
+	}
+	s := model.UserStatus{}
+	if db.ExpUsrStatus != nil {
+		s = *db.ExpUsrStatus
+	}
+	s.LoggedOutAll = db.LgdOutAll
+	return &s, nil
+}
+
+func (db *DBMock) SetLoggedOutAll(userID string, at time.Time) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpLgdOutAllErr != nil {
+		return db.ExpLgdOutAllErr
+	}
+	db.LgdOutAll = at
+	return nil
+}
+
+func (db *DBMock) SetUserAttributes(userID string, attrs map[string]interface{}) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSetUsrAttrsErr != nil {
+		return db.ExpSetUsrAttrsErr
+	}
+	db.SetUsrAttrs = append(db.SetUsrAttrs, attrs)
+	return nil
+}
+
+// GetAttributeSchema sets s (a *model.AttributeSchema) to ExpAttrSchema,
+// or returns a NotFound error if ExpAttrSchema is nil.
+func (db *DBMock) GetAttributeSchema(s interface{}) error {
+	if db.ExpAttrSchemaErr != nil {
+		return db.ExpAttrSchemaErr
+	}
+	if db.ExpAttrSchema == nil {
+		return errors.NewNotFound("attribute schema not found")
+	}
+	*s.(*model.AttributeSchema) = *db.ExpAttrSchema
+	return nil
+}
+
+func (db *DBMock) UpsertAttributeSchema(s interface{}) error {
+	if db.ExpUpsAttrSchemaErr != nil {
+		return db.ExpUpsAttrSchemaErr
+	}
+	as := s.(model.AttributeSchema)
+	db.UpsertedAttrSchema = &as
+	return nil
+}
+
 func (db *DBMock) InsertGroup(name string, acl float32) (*model.Group, error) {
 	if db.isInTx {
 		return nil, errors.Newf("direct db call while in tx")
@@ -172,6 +424,38 @@ func (db *DBMock) InsertGroup(name string, acl float32) (*model.Group, error) {
 	return &model.Group{ID: currentID(), Name: name, AccessLevel: acl}, db.ExpInsGrpErr
 }
 
+func (db *DBMock) UpdateGroup(id, name string, acl float32) (*model.Group, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUpdGrpErr != nil {
+		return nil, db.ExpUpdGrpErr
+	}
+	return &model.Group{ID: id, Name: name, AccessLevel: acl}, nil
+}
+
+func (db *DBMock) SetGroupPermissions(id string, perms []string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSetGrpPermsErr != nil {
+		return db.ExpSetGrpPermsErr
+	}
+	db.GrantedPermissions = perms
+	return nil
+}
+
+func (db *DBMock) DeleteGroup(id string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpDelGrpErr != nil {
+		return db.ExpDelGrpErr
+	}
+	db.DeletedGrps = append(db.DeletedGrps, id)
+	return nil
+}
+
 func (db *DBMock) AddUserToGroupAtomic(tx *sql.Tx, userID, groupID string) error {
 	return db.ExpAddUsrTGrpAtmcErr
 }
@@ -186,14 +470,57 @@ func (db *DBMock) UserTypeByName(string) (*model.UserType, error) {
 	return db.ExpUsrTypBNm, db.ExpUsrTypBNmErr
 }
 
-func (db *DBMock) InsertUserType(name string) (*model.UserType, error) {
+func (db *DBMock) InsertUserType(name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error) {
 	if db.isInTx {
 		return nil, errors.Newf("direct db call while in tx")
 	}
 	if db.ExpInsUsrTypErr != nil {
 		return nil, db.ExpInsUsrTypErr
 	}
-	return &model.UserType{ID: currentID(), Name: name}, db.ExpInsUsrTypErr
+	ut := model.UserType{ID: currentID(), Name: name, SelfRegistrable: selfReg,
+		RequiredLoginTypes: reqLoginTypes}
+	db.InsertedUsrTyps = append(db.InsertedUsrTyps, ut)
+	return &ut, nil
+}
+
+func (db *DBMock) UpdateUserType(id, name string, selfReg bool, reqLoginTypes []string) (*model.UserType, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUpdUsrTypErr != nil {
+		return nil, db.ExpUpdUsrTypErr
+	}
+	return &model.UserType{ID: id, Name: name, SelfRegistrable: selfReg,
+		RequiredLoginTypes: reqLoginTypes}, nil
+}
+
+func (db *DBMock) DeleteUserType(id string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpDelUsrTypErr != nil {
+		return db.ExpDelUsrTypErr
+	}
+	db.DeletedUsrTyps = append(db.DeletedUsrTyps, id)
+	return nil
+}
+
+func (db *DBMock) UserType(id string) (*model.UserType, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUsrTyp == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpUsrTyp, db.ExpUsrTypErr
+}
+
+func (db *DBMock) UserTypes(offset, count int64) ([]model.UserType, error) {
+	return db.ExpUsrTyps, db.ExpUsrTypsErr
+}
+
+func (db *DBMock) HasUsersOfType(typeID string) error {
+	return db.ExpHasUsrsOfTypErr
 }
 
 func (db *DBMock) InsertUserAtomic(tx *sql.Tx, t model.UserType, g model.Group, password []byte) (*model.User, error) {
@@ -262,7 +589,43 @@ func (db *DBMock) UpdatePassword(userID string, password []byte) error {
 }
 
 func (db *DBMock) UpdatePasswordAtomic(tx *sql.Tx, userID string, password []byte) error {
-	return db.ExpupdPassAtmErr
+	if db.ExpupdPassAtmErr != nil {
+		return db.ExpupdPassAtmErr
+	}
+	db.UpdatedPasses = append(db.UpdatedPasses, password)
+	return nil
+}
+
+func (db *DBMock) DeleteUserAtomic(tx *sql.Tx, userID string) error {
+	if db.ExpDelUsrAtmErr != nil {
+		return db.ExpDelUsrAtmErr
+	}
+	db.DeletedUsrs = append(db.DeletedUsrs, userID)
+	return nil
+}
+
+func (db *DBMock) InsertPassHistoryAtomic(tx *sql.Tx, userID string, password []byte) error {
+	if db.ExpInsPassHstryAtmErr != nil {
+		return db.ExpInsPassHstryAtmErr
+	}
+	db.InsertedPassHstry = append(db.InsertedPassHstry, password)
+	return nil
+}
+
+func (db *DBMock) PassHistory(userID string, count int) ([][]byte, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpPassHstryErr != nil {
+		return nil, db.ExpPassHstryErr
+	}
+	if len(db.ExpPassHstry) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	if count < len(db.ExpPassHstry) {
+		return db.ExpPassHstry[:count], nil
+	}
+	return db.ExpPassHstry, nil
 }
 
 func (db *DBMock) UpdateUserPhoneAtomic(tx *sql.Tx, userID, phone string, verified bool) (*model.VerifLogin, error) {
@@ -279,6 +642,55 @@ func (db *DBMock) UpdateUserEmailAtomic(tx *sql.Tx, userID, email string, verifi
 	return db.ExpUpdUsrMailAtm, db.ExpUpdUsrMailAtmErr
 }
 
+func (db *DBMock) VerifyUserPhoneAtomic(tx *sql.Tx, userID, phone string) (*model.VerifLogin, error) {
+	if db.ExpVrfyUsrPhnAtmErr != nil {
+		return nil, db.ExpVrfyUsrPhnAtmErr
+	}
+	return &model.VerifLogin{ID: currentID(), UserID: userID, Address: phone, Verified: true}, nil
+}
+
+func (db *DBMock) UserPhonesByUserID(userID string) ([]model.VerifLogin, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUsrPhnsErr != nil {
+		return nil, db.ExpUsrPhnsErr
+	}
+	if len(db.ExpUsrPhns) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpUsrPhns, nil
+}
+
+func (db *DBMock) SetPrimaryUserPhone(userID, phone string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSetPrimUsrPhnErr != nil {
+		return db.ExpSetPrimUsrPhnErr
+	}
+	db.PrimaryPhns = append(db.PrimaryPhns, phone)
+	return nil
+}
+
+func (db *DBMock) DeleteUserPhoneAtomic(tx *sql.Tx, userID, phone string) error {
+	if db.ExpDelUsrPhnAtmErr != nil {
+		return db.ExpDelUsrPhnAtmErr
+	}
+	db.DeletedPhns = append(db.DeletedPhns, phone)
+	return nil
+}
+
+func (db *DBMock) InsertUnverifiedUserPhone(userID, phone string) (*model.VerifLogin, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsUsrPhnErr != nil {
+		return nil, db.ExpInsUsrPhnErr
+	}
+	return &model.VerifLogin{ID: currentID(), UserID: userID, Address: phone}, nil
+}
+
 func (db *DBMock) InsertUserPhone(userID, phone string, verified bool) (*model.VerifLogin, error) {
 	if db.isInTx {
 		return nil, errors.Newf("direct db call while in tx")
@@ -289,12 +701,65 @@ func (db *DBMock) InsertUserPhone(userID, phone string, verified bool) (*model.V
 	return &model.VerifLogin{ID: currentID(), UserID: userID, Address: phone, Verified: verified}, db.ExpInsUsrPhnErr
 }
 
-func (db *DBMock) DeletePhoneTokensAtomic(tx *sql.Tx, phone string) error {
+func (db *DBMock) DeletePhoneTokensAtomic(tx *sql.Tx, userID, phone string) error {
 	return db.ExpDelPhnTknsErr
 }
 
 func (db *DBMock) SetPhoneTokenUsedAtomic(tx *sql.Tx, id string) error {
-	return db.ExpSetPhnTknUsdErr
+	if db.ExpSetPhnTknUsdErr != nil {
+		return db.ExpSetPhnTknUsdErr
+	}
+	db.UsedPhnTkns = append(db.UsedPhnTkns, id)
+	return nil
+}
+
+func (db *DBMock) VerifyUserEmailAtomic(tx *sql.Tx, userID, email string) (*model.VerifLogin, error) {
+	if db.ExpVrfyUsrMailAtmErr != nil {
+		return nil, db.ExpVrfyUsrMailAtmErr
+	}
+	return &model.VerifLogin{ID: currentID(), UserID: userID, Address: email, Verified: true}, nil
+}
+
+func (db *DBMock) UserEmailsByUserID(userID string) ([]model.VerifLogin, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUsrMailsErr != nil {
+		return nil, db.ExpUsrMailsErr
+	}
+	if len(db.ExpUsrMails) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpUsrMails, nil
+}
+
+func (db *DBMock) SetPrimaryUserEmail(userID, email string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSetPrimUsrMailErr != nil {
+		return db.ExpSetPrimUsrMailErr
+	}
+	db.PrimaryMails = append(db.PrimaryMails, email)
+	return nil
+}
+
+func (db *DBMock) DeleteUserEmailAtomic(tx *sql.Tx, userID, email string) error {
+	if db.ExpDelUsrMailAtmErr != nil {
+		return db.ExpDelUsrMailAtmErr
+	}
+	db.DeletedMails = append(db.DeletedMails, email)
+	return nil
+}
+
+func (db *DBMock) InsertUnverifiedUserEmail(userID, email string) (*model.VerifLogin, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsUsrMailErr != nil {
+		return nil, db.ExpInsUsrMailErr
+	}
+	return &model.VerifLogin{ID: currentID(), UserID: userID, Address: email}, nil
 }
 
 func (db *DBMock) InsertUserEmail(userID, email string, verified bool) (*model.VerifLogin, error) {
@@ -307,12 +772,16 @@ func (db *DBMock) InsertUserEmail(userID, email string, verified bool) (*model.V
 	return &model.VerifLogin{ID: currentID(), UserID: userID, Address: email, Verified: verified}, db.ExpInsUsrMailErr
 }
 
-func (db *DBMock) DeleteEmailTokensAtomic(tx *sql.Tx, email string) error {
+func (db *DBMock) DeleteEmailTokensAtomic(tx *sql.Tx, userID, email string) error {
 	return db.ExpDelMailTknsErr
 }
 
 func (db *DBMock) SetEmailTokenUsedAtomic(tx *sql.Tx, id string) error {
-	return db.ExpSetMailTknUsdErr
+	if db.ExpSetMailTknUsdErr != nil {
+		return db.ExpSetMailTknUsdErr
+	}
+	db.UsedMailTkns = append(db.UsedMailTkns, id)
+	return nil
 }
 
 func (db *DBMock) InsertUserName(userID, username string) (*model.Username, error) {
@@ -353,6 +822,61 @@ func (db *DBMock) InsertUserFbIDAtomic(tx *sql.Tx, userID, fbID string, verified
 	return &model.Facebook{ID: currentID(), UserID: userID, FacebookID: fbID, Verified: verified}, db.ExpInsFbAtmErr
 }
 
+func (db *DBMock) InsertLinkedIdentityAtomic(tx *sql.Tx, userID, issuer, subject string) (*model.LinkedIdentity, error) {
+	if db.ExpInsLnkdIDAtmErr != nil {
+		return nil, db.ExpInsLnkdIDAtmErr
+	}
+	li := model.LinkedIdentity{ID: currentID(), UserID: userID, Issuer: issuer, Subject: subject}
+	db.InsertedLnkdIDs = append(db.InsertedLnkdIDs, li)
+	return &li, nil
+}
+
+func (db *DBMock) LinkedIdentitiesByUserID(userID string) ([]model.LinkedIdentity, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpLnkdIDsErr != nil {
+		return nil, db.ExpLnkdIDsErr
+	}
+	if len(db.ExpLnkdIDs) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpLnkdIDs, nil
+}
+
+func (db *DBMock) DeleteLinkedIdentities(userID string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpDelLnkdIDsErr != nil {
+		return db.ExpDelLnkdIDsErr
+	}
+	db.Unlinked = append(db.Unlinked, model.LoginTypeOIDC)
+	return nil
+}
+
+func (db *DBMock) DeleteUserFbID(userID string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpDelFbErr != nil {
+		return db.ExpDelFbErr
+	}
+	db.Unlinked = append(db.Unlinked, model.LoginTypeFacebook)
+	return nil
+}
+
+func (db *DBMock) DeleteUserName(userID string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpDelUsrNmErr != nil {
+		return db.ExpDelUsrNmErr
+	}
+	db.Unlinked = append(db.Unlinked, model.LoginTypeUsername)
+	return nil
+}
+
 func (db *DBMock) InsertUserDeviceAtomic(tx *sql.Tx, userID, devID string) (*model.Device, error) {
 	if db.ExpInsDevAtmErr != nil {
 		return nil, db.ExpInsDevAtmErr
@@ -367,7 +891,10 @@ func (db *DBMock) InsertPhoneToken(userID, phone string, dbt []byte, isUsed bool
 	if db.ExpInsPhnTknErr != nil {
 		return nil, db.ExpInsPhnTknErr
 	}
-	return &model.DBToken{ID: currentID(), UserID: userID, Address: phone, Token: dbt}, db.ExpInsPhnTknErr
+	tkn := model.DBToken{ID: currentID(), UserID: userID, Address: phone,
+		Token: dbt, IsUsed: isUsed, IssueDate: time.Now(), ExpiryDate: expiry}
+	db.InsertedPhnTkns = append(db.InsertedPhnTkns, tkn)
+	return &tkn, nil
 }
 
 func (db *DBMock) InsertEmailToken(userID, email string, dbt []byte, isUsed bool, expiry time.Time) (*model.DBToken, error) {
@@ -377,7 +904,10 @@ func (db *DBMock) InsertEmailToken(userID, email string, dbt []byte, isUsed bool
 	if db.ExpInsMailTknErr != nil {
 		return nil, db.ExpInsMailTknErr
 	}
-	return &model.DBToken{ID: currentID(), UserID: userID, Address: email, Token: dbt}, db.ExpInsMailTknErr
+	tkn := model.DBToken{ID: currentID(), UserID: userID, Address: email,
+		Token: dbt, IsUsed: isUsed, IssueDate: time.Now(), ExpiryDate: expiry}
+	db.InsertedMailTkns = append(db.InsertedMailTkns, tkn)
+	return &tkn, nil
 }
 
 func (db *DBMock) InsertPhoneTokenAtomic(tx *sql.Tx, userID, phone string, dbt []byte, isUsed bool, expiry time.Time) (*model.DBToken, error) {
@@ -398,20 +928,20 @@ func (db *DBMock) PhoneTokens(userID string, offset, count int64) ([]model.DBTok
 	if db.isInTx {
 		return nil, errors.Newf("direct db call while in tx")
 	}
-	if len(db.ExpPhnTkns) == 0 {
+	if int64(len(db.ExpPhnTkns)) <= offset {
 		return nil, errors.NewNotFound("not found")
 	}
-	return db.ExpPhnTkns, db.ExpPhnTknsErr
+	return db.ExpPhnTkns[offset:], db.ExpPhnTknsErr
 }
 
 func (db *DBMock) EmailTokens(userID string, offset, count int64) ([]model.DBToken, error) {
 	if db.isInTx {
 		return nil, errors.Newf("direct db call while in tx")
 	}
-	if len(db.ExpMailTkns) == 0 {
+	if int64(len(db.ExpMailTkns)) <= offset {
 		return nil, errors.NewNotFound("not found")
 	}
-	return db.ExpMailTkns, db.ExpMailTknsErr
+	return db.ExpMailTkns[offset:], db.ExpMailTknsErr
 }
 
 func (db *DBMock) User(id string) (*model.User, []byte, error) {
@@ -464,6 +994,16 @@ func (db *DBMock) UserByFacebook(facebookID string) (*model.User, error) {
 	return db.ExpUsrBFb, db.ExpUsrBFbErr
 }
 
+func (db *DBMock) UserByLinkedIdentity(issuer, subject string) (*model.User, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUsrBLnkdID == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpUsrBLnkdID, db.ExpUsrBLnkdIDErr
+}
+
 func (db *DBMock) UserByDeviceID(devID string) (*model.User, []byte, error) {
 	if db.isInTx {
 		return nil, nil, errors.Newf("direct db call while in tx")
@@ -473,3 +1013,362 @@ func (db *DBMock) UserByDeviceID(devID string) (*model.User, []byte, error) {
 	}
 	return db.ExpUsrBDev, db.ExpUsrBDevPass, db.ExpUsrBDevErr
 }
+
+func (db *DBMock) InsertRefreshToken(userID, apiKeyID, familyID string, tkn []byte, expiry time.Time) (*model.RefreshToken, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsRfrshTknErr != nil {
+		return nil, db.ExpInsRfrshTknErr
+	}
+	return &model.RefreshToken{ID: currentID(), UserID: userID, APIKeyID: apiKeyID,
+		FamilyID: familyID, Token: tkn, ExpiryDate: expiry}, db.ExpInsRfrshTknErr
+}
+
+func (db *DBMock) InsertRefreshTokenAtomic(tx *sql.Tx, userID, apiKeyID, familyID string, tkn []byte, expiry time.Time) (*model.RefreshToken, error) {
+	if db.ExpInsRfrshTknAtmErr != nil {
+		return nil, db.ExpInsRfrshTknAtmErr
+	}
+	return &model.RefreshToken{ID: currentID(), UserID: userID, APIKeyID: apiKeyID,
+		FamilyID: familyID, Token: tkn, ExpiryDate: expiry}, db.ExpInsRfrshTknAtmErr
+}
+
+func (db *DBMock) RefreshToken(id string) (*model.RefreshToken, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpRfrshTkn == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpRfrshTkn, db.ExpRfrshTknErr
+}
+
+func (db *DBMock) SetRefreshTokenUsedAtomic(tx *sql.Tx, id string) error {
+	return db.ExpSetRfrshTknUsdErr
+}
+
+func (db *DBMock) RevokeRefreshTokenFamily(familyID string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	db.RevokedRfrshTknFmly = familyID
+	return db.ExpRvkRfrshTknFmlyErr
+}
+
+func (db *DBMock) RefreshTokensByUserID(userID string, offset, count int64) ([]model.RefreshToken, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpRfrshTknsErr != nil {
+		return nil, db.ExpRfrshTknsErr
+	}
+	if len(db.ExpRfrshTkns) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpRfrshTkns, nil
+}
+
+func (db *DBMock) InsertRevokedToken(tokenID, userID string, expiry time.Time) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsRvkdTknErr != nil {
+		return db.ExpInsRvkdTknErr
+	}
+	db.InsertedRvkdTkns = append(db.InsertedRvkdTkns, tokenID)
+	return nil
+}
+
+func (db *DBMock) IsTokenRevoked(tokenID string) (bool, error) {
+	if db.isInTx {
+		return false, errors.Newf("direct db call while in tx")
+	}
+	return db.ExpIsTknRvkd, db.ExpIsTknRvkdErr
+}
+
+func (db *DBMock) InsertSession(userID, familyID, deviceID, ipAddress, userAgent string) (*model.Session, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsSessErr != nil {
+		return nil, db.ExpInsSessErr
+	}
+	s := model.Session{
+		ID:         strconv.Itoa(len(db.InsertedSesss) + 1),
+		UserID:     userID,
+		FamilyID:   familyID,
+		DeviceID:   deviceID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		CreateDate: time.Now(),
+		LastSeen:   time.Now(),
+	}
+	db.InsertedSesss = append(db.InsertedSesss, s)
+	return &s, nil
+}
+
+// Session returns the matching session from InsertedSesss, falling back to
+// ExpSess.
+func (db *DBMock) Session(id string) (*model.Session, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSessErr != nil {
+		return nil, db.ExpSessErr
+	}
+	for _, s := range db.InsertedSesss {
+		if s.ID == id {
+			s.IsRevoked = db.IsSesssRevoked
+			for _, revokedID := range db.RevokedSessIDs {
+				s.IsRevoked = s.IsRevoked || revokedID == s.ID
+			}
+			return &s, nil
+		}
+	}
+	if db.ExpSess == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpSess, nil
+}
+
+func (db *DBMock) SessionByFamilyID(familyID string) (*model.Session, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSessBFmly == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpSessBFmly, db.ExpSessBFmlyErr
+}
+
+func (db *DBMock) SessionsByUserID(userID string, offset, count int64) ([]model.Session, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSesssErr != nil {
+		return nil, db.ExpSesssErr
+	}
+	if len(db.ExpSesss) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpSesss, nil
+}
+
+func (db *DBMock) SetSessionLastSeen(id string, lastSeen time.Time) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	return db.ExpSetSessLstSnErr
+}
+
+func (db *DBMock) RevokeSession(userID, id string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpRvkSessErr != nil {
+		return db.ExpRvkSessErr
+	}
+	db.RevokedSessIDs = append(db.RevokedSessIDs, id)
+	return nil
+}
+
+func (db *DBMock) RevokeSessions(userID string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpRvkSesssErr != nil {
+		return db.ExpRvkSesssErr
+	}
+	db.IsSesssRevoked = true
+	return nil
+}
+
+func (db *DBMock) InsertLoginFailureAtomic(tx *sql.Tx, loginType, identifier, ipAddress string) (*model.LoginFailure, error) {
+	if db.ExpInsLgnFlrErr != nil {
+		return nil, db.ExpInsLgnFlrErr
+	}
+	lf := model.LoginFailure{ID: currentID(), LoginType: loginType,
+		Identifier: identifier, IPAddress: ipAddress, CreateDate: time.Now()}
+	db.InsertedLgnFlrs = append(db.InsertedLgnFlrs, lf)
+	return &lf, nil
+}
+
+func (db *DBMock) LoginFailures(loginType, identifier string, since time.Time, offset, count int64) ([]model.LoginFailure, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	return db.LoginFailuresAtomic(nil, loginType, identifier, since, offset, count)
+}
+
+func (db *DBMock) LoginFailuresAtomic(tx *sql.Tx, loginType, identifier string, since time.Time, offset, count int64) ([]model.LoginFailure, error) {
+	if int64(len(db.ExpLgnFlrs)) <= offset {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpLgnFlrs[offset:], db.ExpLgnFlrsErr
+}
+
+func (db *DBMock) LoginFailuresByIP(ipAddress string, since time.Time, count int64) ([]model.LoginFailure, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	return db.LoginFailuresByIPAtomic(nil, ipAddress, since, count)
+}
+
+func (db *DBMock) LoginFailuresByIPAtomic(tx *sql.Tx, ipAddress string, since time.Time, count int64) ([]model.LoginFailure, error) {
+	if len(db.ExpLgnFlrsBIP) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpLgnFlrsBIP, db.ExpLgnFlrsBIPErr
+}
+
+func (db *DBMock) DeleteLoginFailure(id string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpDelLgnFlrErr != nil {
+		return db.ExpDelLgnFlrErr
+	}
+	db.removeLoginFailures(func(lf model.LoginFailure) bool { return lf.ID == id })
+	return nil
+}
+
+func (db *DBMock) DeleteLoginFailures(loginType, identifier string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	db.IsLgnFlrsDeleted = db.ExpDelLgnFlrsErr == nil
+	if db.ExpDelLgnFlrsErr != nil {
+		return db.ExpDelLgnFlrsErr
+	}
+	db.removeLoginFailures(func(lf model.LoginFailure) bool {
+		return lf.LoginType == loginType && lf.Identifier == identifier
+	})
+	return nil
+}
+
+func (db *DBMock) DeleteLoginFailuresByIP(ipAddress string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	return db.ExpDelLgnFlrsBIPErr
+}
+
+// removeLoginFailures drops InsertedLgnFlrs matching match as the store
+// would on deleting them.
+func (db *DBMock) removeLoginFailures(match func(model.LoginFailure) bool) {
+	var kept []model.LoginFailure
+	for _, lf := range db.InsertedLgnFlrs {
+		if !match(lf) {
+			kept = append(kept, lf)
+		}
+	}
+	db.InsertedLgnFlrs = kept
+}
+
+func (db *DBMock) InsertHistory(userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*model.History, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpInsHistErr != nil {
+		return nil, db.ExpInsHistErr
+	}
+	return db.insertHistory(userID, accessType, loginType, ipAddress, userAgent, successful), nil
+}
+
+func (db *DBMock) InsertHistoryAtomic(tx *sql.Tx, userID, accessType, loginType, ipAddress, userAgent string, successful bool) (*model.History, error) {
+	if db.ExpInsHistAtmErr != nil {
+		return nil, db.ExpInsHistAtmErr
+	}
+	return db.insertHistory(userID, accessType, loginType, ipAddress, userAgent, successful), nil
+}
+
+func (db *DBMock) insertHistory(userID, accessType, loginType, ipAddress, userAgent string, successful bool) *model.History {
+	h := model.History{ID: currentID(), UserID: userID, AccessType: accessType,
+		LoginType: loginType, Successful: successful, IPAddress: ipAddress,
+		UserAgent: userAgent, CreateDate: time.Now()}
+	db.InsertedHist = append(db.InsertedHist, h)
+	return &h
+}
+
+func (db *DBMock) HistoryByUserID(userID string, offset, count int64) ([]model.History, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if len(db.ExpHist) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpHist, db.ExpHistErr
+}
+
+func (db *DBMock) APIKeyMetaByUserID(userID string, offset, count int64) ([]model.APIKey, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpAPIKMetaBUsrIDErr != nil {
+		return nil, db.ExpAPIKMetaBUsrIDErr
+	}
+	if len(db.ExpAPIKMetaBUsrID) == 0 {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpAPIKMetaBUsrID, nil
+}
+
+func (db *DBMock) UpsertTOTPSecret(userID string, secret []byte) (*model.TOTPSecret, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpUpsTOTPScrtErr != nil {
+		return nil, db.ExpUpsTOTPScrtErr
+	}
+	db.UpsertedTOTPScrt = &model.TOTPSecret{ID: currentID(), UserID: userID,
+		Secret: secret, CreateDate: time.Now(), UpdateDate: time.Now()}
+	return db.UpsertedTOTPScrt, nil
+}
+
+func (db *DBMock) TOTPSecret(userID string) (*model.TOTPSecret, error) {
+	if db.isInTx {
+		return nil, errors.Newf("direct db call while in tx")
+	}
+	if db.ExpTOTPScrt == nil {
+		return nil, errors.NewNotFound("not found")
+	}
+	return db.ExpTOTPScrt, db.ExpTOTPScrtErr
+}
+
+func (db *DBMock) ConfirmTOTPSecret(userID string, step int64) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpCnfrmTOTPScrtErr != nil {
+		return db.ExpCnfrmTOTPScrtErr
+	}
+	if db.ExpTOTPScrt != nil {
+		db.ExpTOTPScrt.IsConfirmed = true
+		db.ExpTOTPScrt.LastStep = step
+	}
+	return nil
+}
+
+func (db *DBMock) SetTOTPLastStep(userID string, step int64) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	if db.ExpSetTOTPLstStpErr != nil {
+		return db.ExpSetTOTPLstStpErr
+	}
+	if db.ExpTOTPScrt != nil {
+		if db.ExpTOTPScrt.LastStep >= step {
+			return errors.NewNotFound("not found")
+		}
+		db.ExpTOTPScrt.LastStep = step
+	}
+	return nil
+}
+
+func (db *DBMock) DeleteTOTPSecret(userID string) error {
+	if db.isInTx {
+		return errors.Newf("direct db call while in tx")
+	}
+	db.IsTOTPScrtDeleted = db.ExpDelTOTPScrtErr == nil
+	return db.ExpDelTOTPScrtErr
+}
@@ -0,0 +1,15 @@
+package testing
+
+// EncrypterMock returns the input unchanged when encrypting and decrypting.
+type EncrypterMock struct {
+	ExpEncErr error
+	ExpDecErr error
+}
+
+func (e *EncrypterMock) Encrypt(plain []byte) ([]byte, error) {
+	return plain, e.ExpEncErr
+}
+
+func (e *EncrypterMock) Decrypt(cipherText []byte) ([]byte, error) {
+	return cipherText, e.ExpDecErr
+}
@@ -7,6 +7,8 @@ import (
 type GuardMock struct {
 	ExpAPIKValidUsrID string
 	ExpAPIKValidErr   error
+	ExpAPIK           *api.Key
+	ExpAPIKErr        error
 	ExpNewAPIK        *api.Key
 	ExpNewAPIKErr     error
 }
@@ -14,6 +16,9 @@ type GuardMock struct {
 func (g *GuardMock) APIKeyValid(key string) (string, error) {
 	return g.ExpAPIKValidUsrID, g.ExpAPIKValidErr
 }
+func (g *GuardMock) APIKey(key string) (*api.Key, error) {
+	return g.ExpAPIK, g.ExpAPIKErr
+}
 func (g *GuardMock) NewAPIKey(userID string) (*api.Key, error) {
 	return g.ExpNewAPIK, g.ExpNewAPIKErr
 }
@@ -0,0 +1,17 @@
+package testing
+
+import "github.com/tomogoma/authms/model"
+
+// MailerMock records the emails sent through it.
+type MailerMock struct {
+	ExpSendErr error
+	SentMails  []model.SendMail
+}
+
+func (m *MailerMock) SendEmail(email model.SendMail) error {
+	if m.ExpSendErr != nil {
+		return m.ExpSendErr
+	}
+	m.SentMails = append(m.SentMails, email)
+	return nil
+}
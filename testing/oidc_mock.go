@@ -0,0 +1,19 @@
+package testing
+
+import errors "github.com/tomogoma/go-typed-errors"
+
+type OIDCMock struct {
+	errors.AuthErrCheck
+	ExpIssuer    string
+	ExpSubject   string
+	ExpValTknErr error
+	ValTknNonce  string
+}
+
+func (o *OIDCMock) ValidateIDToken(idToken, nonce string) (string, string, error) {
+	o.ValTknNonce = nonce
+	if o.ExpValTknErr != nil {
+		return "", "", o.ExpValTknErr
+	}
+	return o.ExpIssuer, o.ExpSubject, nil
+}
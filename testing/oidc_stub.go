@@ -0,0 +1,93 @@
+package testing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/tomogoma/authms/oidc"
+)
+
+const (
+	OIDCStubClientID = "test-client-id"
+	OIDCStubRSAKeyID = "rsa-key"
+	OIDCStubECKeyID  = "ec-key"
+)
+
+// OIDCStub is an in-process OpenID Connect provider serving discovery and
+// JWKS documents. It signs id_tokens with local keys. Close() when done.
+type OIDCStub struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func NewOIDCStub(t *testing.T) *OIDCStub {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error setting up: generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error setting up: generate EC key: %v", err)
+	}
+	idp := &OIDCStub{rsaKey: rsaKey, ecKey: ecKey}
+	rsaJWK, err := oidc.NewJWK(OIDCStubRSAKeyID, &rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Error setting up: RSA JWK: %v", err)
+	}
+	ecJWK, err := oidc.NewJWK(OIDCStubECKeyID, &ecKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Error setting up: EC JWK: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   idp.URL,
+			"jwks_uri": idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.JWKS{Keys: []oidc.JWK{rsaJWK, ecJWK}})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+// Claims returns valid id_token claims for subject issued with nonce.
+func (idp *OIDCStub) Claims(subject, nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   idp.URL,
+		"sub":   subject,
+		"aud":   OIDCStubClientID,
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": nonce,
+	}
+}
+
+// Sign signs clms using method. RS256 and ES256 tokens are signed with the
+// stub's published keys, HS256 tokens with an arbitrary shared secret.
+func (idp *OIDCStub) Sign(t *testing.T, method jwt.SigningMethod, kid string, clms jwt.MapClaims) string {
+	tkn := jwt.NewWithClaims(method, clms)
+	tkn.Header["kid"] = kid
+	var key interface{} = idp.rsaKey
+	switch method {
+	case jwt.SigningMethodES256:
+		key = idp.ecKey
+	case jwt.SigningMethodHS256:
+		key = []byte("some-shared-secret")
+	}
+	signed, err := tkn.SignedString(key)
+	if err != nil {
+		t.Fatalf("Error setting up: sign id_token: %v", err)
+	}
+	return signed
+}
@@ -0,0 +1,17 @@
+package testing
+
+// PwnedMock reports the passwords in ExpPwned as breached with their
+// respective counts.
+type PwnedMock struct {
+	ExpPwned      map[string]int
+	ExpCntErr     error
+	CountedPasses []string
+}
+
+func (p *PwnedMock) Count(password []byte) (int, error) {
+	p.CountedPasses = append(p.CountedPasses, string(password))
+	if p.ExpCntErr != nil {
+		return 0, p.ExpCntErr
+	}
+	return p.ExpPwned[string(password)], nil
+}
@@ -0,0 +1,20 @@
+package testing
+
+// SMSerMock records the SMSes sent through it.
+type SMSerMock struct {
+	ExpSMSErr error
+	SentSMSes []SentSMS
+}
+
+type SentSMS struct {
+	ToPhone string
+	Message string
+}
+
+func (s *SMSerMock) SMS(toPhone, message string) error {
+	if s.ExpSMSErr != nil {
+		return s.ExpSMSErr
+	}
+	s.SentSMSes = append(s.SentSMSes, SentSMS{ToPhone: toPhone, Message: message})
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package totp implements RFC 6238 Time-Based One-Time Passwords using
+// HMAC-SHA1 as supported by common authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// SecretLength is the length of secrets generated by NewSecret() as
+	// recommended by RFC 4226.
+	SecretLength = 20
+	// Period is the number of seconds each code is valid for.
+	Period = 30
+	// Digits is the number of digits in a code.
+	Digits = 6
+	// Skew is the number of periods before and after the current one
+	// within which a code is accepted to allow for clock drift.
+	Skew = 1
+)
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret generates a new random secret.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("read random bytes: %v", err)
+	}
+	return secret, nil
+}
+
+// EncodeSecret returns the base32 representation of secret as expected by
+// authenticator apps.
+func EncodeSecret(secret []byte) string {
+	return b32NoPadding.EncodeToString(secret)
+}
+
+// URI returns the otpauth:// key URI for provisioning secret into an
+// authenticator app. The issuer and account are used by the app to label
+// the code.
+func URI(issuer, account string, secret []byte) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(secret))
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(Digits))
+	q.Set("period", strconv.Itoa(Period))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// Step returns the time step that t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// Code returns the code of length digits for secret at time step.
+func Code(secret []byte, step int64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, bin%mod)
+}
+
+// Validate checks code against secret at time t allowing for Skew. It
+// returns the time step the code matched so that callers can reject
+// reuse of a code by only accepting steps greater than afterStep.
+// ok is false if the code did not match any step greater than afterStep.
+func Validate(secret []byte, code string, t time.Time, afterStep int64) (step int64, ok bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(t)
+	for i := int64(-Skew); i <= Skew; i++ {
+		step = current + i
+		if step <= afterStep {
+			continue
+		}
+		if hmac.Equal([]byte(Code(secret, step, Digits)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
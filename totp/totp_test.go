@@ -0,0 +1,106 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomogoma/authms/totp"
+)
+
+// rfc6238Secret is the SHA1 seed used in the RFC 6238 Appendix B test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	tt := []struct {
+		unixTime int64
+		expCode  string
+	}{
+		{unixTime: 59, expCode: "94287082"},
+		{unixTime: 1111111109, expCode: "07081804"},
+		{unixTime: 1111111111, expCode: "14050471"},
+		{unixTime: 1234567890, expCode: "89005924"},
+		{unixTime: 2000000000, expCode: "69279037"},
+		{unixTime: 20000000000, expCode: "65353130"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expCode, func(t *testing.T) {
+			step := totp.Step(time.Unix(tc.unixTime, 0))
+			code := totp.Code(rfc6238Secret, step, 8)
+			if code != tc.expCode {
+				t.Errorf("Expected code %s, got %s", tc.expCode, code)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	currStep := totp.Step(now)
+	tt := []struct {
+		name      string
+		code      string
+		afterStep int64
+		expStep   int64
+		expOK     bool
+	}{
+		{
+			name:    "current step",
+			code:    totp.Code(rfc6238Secret, currStep, totp.Digits),
+			expStep: currStep,
+			expOK:   true,
+		},
+		{
+			name:    "previous step within skew",
+			code:    totp.Code(rfc6238Secret, currStep-1, totp.Digits),
+			expStep: currStep - 1,
+			expOK:   true,
+		},
+		{
+			name:    "next step within skew",
+			code:    totp.Code(rfc6238Secret, currStep+1, totp.Digits),
+			expStep: currStep + 1,
+			expOK:   true,
+		},
+		{
+			name:  "outside skew",
+			code:  totp.Code(rfc6238Secret, currStep-2, totp.Digits),
+			expOK: false,
+		},
+		{
+			name:      "already used step",
+			code:      totp.Code(rfc6238Secret, currStep, totp.Digits),
+			afterStep: currStep,
+			expOK:     false,
+		},
+		{
+			name:  "bad length",
+			code:  "1234",
+			expOK: false,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := totp.Validate(rfc6238Secret, tc.code, now, tc.afterStep)
+			if ok != tc.expOK {
+				t.Fatalf("Expected ok %t, got %t", tc.expOK, ok)
+			}
+			if ok && step != tc.expStep {
+				t.Errorf("Expected step %d, got %d", tc.expStep, step)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("Acme", "john@example.com", rfc6238Secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Acme:john@example.com?") {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+totp.EncodeSecret(rfc6238Secret)) {
+		t.Errorf("URI does not contain secret: %s", uri)
+	}
+	if !strings.Contains(uri, "issuer=Acme") {
+		t.Errorf("URI does not contain issuer: %s", uri)
+	}
+}